@@ -0,0 +1,45 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BenchmarkMongo runs the benchmarks against a real MongoDB: go test
+// -tags integration -run '^$' -bench Mongo with MONGODB_URI set
+func BenchmarkMongo(b *testing.B) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		b.Skip("MONGODB_URI not set, skipping integration benchmark")
+	}
+
+	ctx := context.Background()
+	db, err := New(NewMongoOptions().SetUri(mongodbUri).SetTimeout(5000).Build())
+	if err != nil {
+		b.Fatalf("failed to create database instance: %v", err)
+	}
+	defer db.Client.Close(ctx)
+
+	reset := func(collection string) func() {
+		return func() {
+			if _, err := db.Client.DeleteMany(ctx, "bench", collection, bson.M{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	reset("events")()
+	defer reset("events")()
+	defer reset("inserted")()
+	if _, err := db.Client.InsertMany(ctx, "bench", "events", benchEvents(100)); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Find", func(b *testing.B) { benchFind(b, db) })
+	b.Run("FindOne", func(b *testing.B) { benchFindOne(b, db) })
+	b.Run("InsertMany", func(b *testing.B) { benchInsertMany(b, db, reset("inserted")) })
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// benchEvent is the shape of the event listing the benchmarks decode
+type benchEvent struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Camera    string             `bson:"camera"`
+	Kind      string             `bson:"kind"`
+	Score     float64            `bson:"score"`
+	Labels    []string           `bson:"labels"`
+	Region    benchRegion        `bson:"region"`
+	Timestamp time.Time          `bson:"timestamp"`
+}
+
+type benchRegion struct {
+	X      int `bson:"x"`
+	Y      int `bson:"y"`
+	Width  int `bson:"width"`
+	Height int `bson:"height"`
+}
+
+// benchEvents returns n event documents
+func benchEvents(n int) []any {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	docs := make([]any, n)
+	for i := range docs {
+		docs[i] = bson.M{
+			"_id":       primitive.NewObjectID(),
+			"camera":    fmt.Sprintf("camera-%d", i%8),
+			"kind":      "motion",
+			"score":     float64(i%100) / 100,
+			"labels":    bson.A{"person", "car"},
+			"region":    bson.M{"x": i, "y": i * 2, "width": 640, "height": 480},
+			"timestamp": start.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return docs
+}
+
+// benchDatabase seeds n events into the fake
+func benchDatabase(b *testing.B, n int) *Database {
+	fake := NewFakeDatabase()
+	if err := fake.Seed("bench", "events", benchEvents(n)...); err != nil {
+		b.Fatal(err)
+	}
+	return &Database{Client: fake}
+}
+
+func BenchmarkFind(b *testing.B) {
+	benchFind(b, benchDatabase(b, 100))
+}
+
+func BenchmarkFindOne(b *testing.B) {
+	benchFindOne(b, benchDatabase(b, 100))
+}
+
+func BenchmarkInsertMany(b *testing.B) {
+	fake := NewFakeDatabase()
+	benchInsertMany(b, &Database{Client: fake}, fake.Reset)
+}
+
+// BenchmarkFindEach scans a cursor of generated events, reporting the peak
+// live heap of the scan as peak-B. FindEach stays flat as the scan grows,
+// while FindInto, which keeps every document, grows with it.
+func BenchmarkFindEach(b *testing.B) {
+	ctx := context.Background()
+	for _, n := range []int{1000, 10000, 100000} {
+		mock := NewMockDatabase()
+		mock.FindCursorFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+			return &streamCursor{n: n}, nil
+		}
+		db := &Database{Client: mock}
+
+		b.Run(fmt.Sprintf("FindEach/%d", n), func(b *testing.B) {
+			var peak uint64
+			for b.Loop() {
+				base := liveHeap()
+				seen := 0
+				err := FindEachAs(ctx, db, "bench", "events", bson.M{}, func(e benchEvent) error {
+					seen++
+					if seen%1000 == 0 {
+						peak = max(peak, heapGrowth(base))
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(peak), "peak-B")
+		})
+		b.Run(fmt.Sprintf("FindInto/%d", n), func(b *testing.B) {
+			var peak uint64
+			for b.Loop() {
+				base := liveHeap()
+				var events []benchEvent
+				if err := FindInto(ctx, db, "bench", "events", bson.M{}, &events); err != nil {
+					b.Fatal(err)
+				}
+				peak = max(peak, heapGrowth(base))
+				runtime.KeepAlive(events)
+			}
+			b.ReportMetric(float64(peak), "peak-B")
+		})
+	}
+}
+
+// liveHeap returns the bytes of live heap objects after a collection
+func liveHeap() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// heapGrowth returns how much the live heap grew from base
+func heapGrowth(base uint64) uint64 {
+	if live := liveHeap(); live > base {
+		return live - base
+	}
+	return 0
+}
+
+// streamCursor yields n generated events one at a time without holding them,
+// like a server cursor
+type streamCursor struct {
+	n       int
+	pos     int
+	current bson.M
+}
+
+func (c *streamCursor) Next(ctx context.Context) bool {
+	if c.pos >= c.n || ctx.Err() != nil {
+		c.current = nil
+		return false
+	}
+	c.current = benchEvents(1)[0].(bson.M)
+	c.pos++
+	return true
+}
+
+func (c *streamCursor) Decode(val any) error {
+	return decodeDocument(c.current, val)
+}
+
+func (c *streamCursor) Err() error {
+	return nil
+}
+
+func (c *streamCursor) Close(ctx context.Context) error {
+	return nil
+}
+
+// benchFind lists the events of db as maps and as structs, the latter into
+// a new slice and into a reused one. The collection
+// holds the events of benchDatabase.
+func benchFind(b *testing.B, db *Database) {
+	ctx := context.Background()
+	filter := bson.M{"kind": "motion"}
+
+	b.Run("Map", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := db.Client.Find(ctx, "bench", "events", filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("FindAs", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := FindAs[benchEvent](ctx, db, "bench", "events", filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("FindInto", func(b *testing.B) {
+		b.ReportAllocs()
+		var events []benchEvent
+		for b.Loop() {
+			if err := FindInto(ctx, db, "bench", "events", filter, &events); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func benchFindOne(b *testing.B, db *Database) {
+	ctx := context.Background()
+	filter := bson.M{"camera": "camera-3"}
+
+	b.Run("Map", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := db.Client.FindOne(ctx, "bench", "events", filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("FindOneAs", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := FindOneAs[benchEvent](ctx, db, "bench", "events", filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// benchInsertMany inserts batches of 100 events, calling reset before each
+// batch to start from an empty collection
+func benchInsertMany(b *testing.B, db *Database, reset func()) {
+	ctx := context.Background()
+	docs := benchEvents(100)
+	b.ReportAllocs()
+	for b.Loop() {
+		b.StopTimer()
+		reset()
+		for _, doc := range docs {
+			doc.(bson.M)["_id"] = primitive.NewObjectID()
+		}
+		b.StartTimer()
+		if _, err := db.Client.InsertMany(ctx, "bench", "inserted", docs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Defaults of BulkWriterConfig
+const (
+	defaultBulkBatchSize     = 1000
+	defaultBulkFlushInterval = time.Second
+	defaultBulkMaxInFlight   = 2
+)
+
+// ErrBulkWriterClosed is returned by Add and Flush once Close was called
+var ErrBulkWriterClosed = errors.New("bulk writer closed")
+
+// BulkWriterConfig configures NewBulkWriter
+type BulkWriterConfig struct {
+	// BatchSize is the number of documents that triggers a flush, 1000 by
+	// default
+	BatchSize int
+	// FlushInterval is the longest a document waits in the buffer, one
+	// second by default
+	FlushInterval time.Duration
+	// MaxInFlight is the number of flushes that may run at once, two by
+	// default; Add blocks while that many are running and the buffer is full
+	MaxInFlight int
+	// Ordered stops a batch at its first failing document instead of writing
+	// the others
+	Ordered bool
+	// OnError receives the documents of a flush that were not written and
+	// the error, for the caller to requeue or drop them. It is called from
+	// the flushing goroutine and must be safe for concurrent use; when nil,
+	// failures are logged to Logger.
+	OnError func(docs []any, err error)
+	// Logger receives failures when OnError is nil, slog.Default() by
+	// default
+	Logger *slog.Logger
+	// Clock schedules the interval flushes, the system clock by default; a
+	// TestClock drives them in tests
+	Clock Clock
+}
+
+// BulkWriter buffers documents and writes them to one collection in
+// batches, for high-frequency ingestion where one InsertOne per document
+// costs a round trip each. It is safe for concurrent use.
+type BulkWriter struct {
+	client     DatabaseInterface
+	db         string
+	collection string
+	cfg        BulkWriterConfig
+
+	mu     sync.Mutex
+	buffer []any
+	closed bool
+
+	// inFlight holds a token per running flush
+	inFlight chan struct{}
+	flushes  sync.WaitGroup
+
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewBulkWriter returns a BulkWriter for dbName.collection of db and starts
+// its interval flushes; Close stops them. Documents are inserted with
+// InsertMany, and a batch holding write models, such as
+// mongo.NewUpdateOneModel(), is written with BulkWrite, inserting the plain
+// documents next to them.
+//
+//	w := database.NewBulkWriter(db, "vault", "events", database.BulkWriterConfig{
+//		BatchSize:     500,
+//		FlushInterval: 200 * time.Millisecond,
+//		OnError: func(docs []any, err error) {
+//			requeue(docs)
+//		},
+//	})
+//	defer w.Close(ctx)
+//	for event := range events {
+//		if err := w.Add(ctx, event); err != nil {
+//			return err
+//		}
+//	}
+func NewBulkWriter(db *Database, dbName string, collection string, cfg BulkWriterConfig) *BulkWriter {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBulkBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultBulkFlushInterval
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = defaultBulkMaxInFlight
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	w := &BulkWriter{
+		client:     db.Client,
+		db:         dbName,
+		collection: collection,
+		cfg:        cfg,
+		inFlight:   make(chan struct{}, cfg.MaxInFlight),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go w.tick()
+	return w
+}
+
+// Add buffers doc and starts a flush in the background once the buffer
+// holds BatchSize documents. When MaxInFlight flushes are running, Add waits
+// for one to finish, or for ctx to end, in which case doc is not added.
+func (w *BulkWriter) Add(ctx context.Context, doc any) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrBulkWriterClosed
+	}
+	if len(w.buffer)+1 < w.cfg.BatchSize {
+		w.buffer = append(w.buffer, doc)
+		w.mu.Unlock()
+		return nil
+	}
+	w.mu.Unlock()
+
+	// The document fills the buffer: take a flush slot before adding it
+	if err := w.acquire(ctx); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		w.release()
+		return ErrBulkWriterClosed
+	}
+	w.buffer = append(w.buffer, doc)
+	if len(w.buffer) < w.cfg.BatchSize {
+		// An interval flush emptied the buffer meanwhile
+		w.mu.Unlock()
+		w.release()
+		return nil
+	}
+	batch := w.take()
+	w.flushes.Add(1)
+	w.mu.Unlock()
+
+	go func() {
+		defer w.flushes.Done()
+		defer w.release()
+		w.write(context.WithoutCancel(ctx), batch)
+	}()
+	return nil
+}
+
+// Flush writes the buffered documents and waits for the flushes running in
+// the background. It returns the error of the documents it wrote, which are
+// passed to OnError too, or ctx's error when ctx ends first.
+func (w *BulkWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return ErrBulkWriterClosed
+	}
+	return w.flush(ctx)
+}
+
+// Close stops the interval flushes, writes the buffered documents and waits
+// for every flush to finish. Add fails afterwards; calling Close again does
+// nothing.
+func (w *BulkWriter) Close(ctx context.Context) error {
+	var err error
+	w.once.Do(func() {
+		close(w.stop)
+		<-w.stopped
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		err = w.flush(ctx)
+	})
+	return err
+}
+
+// flush writes the buffer in the caller's goroutine and waits for the
+// background flushes
+func (w *BulkWriter) flush(ctx context.Context) error {
+	if err := w.acquire(ctx); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	batch := w.take()
+	w.mu.Unlock()
+	var err error
+	if len(batch) > 0 {
+		err = w.write(ctx, batch)
+	}
+	w.release()
+
+	done := make(chan struct{})
+	go func() {
+		w.flushes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tick flushes the buffer every FlushInterval until Close
+func (w *BulkWriter) tick() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-clockAfter(w.cfg.Clock, w.cfg.FlushInterval):
+		}
+		select {
+		case w.inFlight <- struct{}{}:
+		case <-w.stop:
+			return
+		}
+		w.mu.Lock()
+		batch := w.take()
+		if len(batch) > 0 {
+			w.flushes.Add(1)
+		}
+		w.mu.Unlock()
+		if len(batch) == 0 {
+			w.release()
+			continue
+		}
+		go func() {
+			defer w.flushes.Done()
+			defer w.release()
+			w.write(context.Background(), batch)
+		}()
+	}
+}
+
+// take empties the buffer; the caller holds w.mu
+func (w *BulkWriter) take() []any {
+	batch := w.buffer
+	w.buffer = nil
+	return batch
+}
+
+func (w *BulkWriter) acquire(ctx context.Context) error {
+	select {
+	case w.inFlight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *BulkWriter) release() {
+	<-w.inFlight
+}
+
+// write writes batch and reports the documents that were not written
+func (w *BulkWriter) write(ctx context.Context, batch []any) error {
+	models := false
+	for _, doc := range batch {
+		if _, ok := doc.(mongo.WriteModel); ok {
+			models = true
+			break
+		}
+	}
+	var err error
+	if models {
+		writes := make([]any, len(batch))
+		for i, doc := range batch {
+			if _, ok := doc.(mongo.WriteModel); ok {
+				writes[i] = doc
+			} else {
+				writes[i] = mongo.NewInsertOneModel().SetDocument(doc)
+			}
+		}
+		_, err = w.client.BulkWrite(ctx, w.db, w.collection, writes, moptions.BulkWrite().SetOrdered(w.cfg.Ordered))
+	} else {
+		_, err = w.client.InsertMany(ctx, w.db, w.collection, batch, moptions.InsertMany().SetOrdered(w.cfg.Ordered))
+	}
+	if err == nil {
+		return nil
+	}
+	failed := failedDocuments(batch, err, w.cfg.Ordered)
+	err = fmt.Errorf("bulk writer %s.%s: %d of %d documents not written: %w", w.db, w.collection, len(failed), len(batch), err)
+	if w.cfg.OnError != nil {
+		w.cfg.OnError(failed, err)
+	} else {
+		w.cfg.Logger.Warn("bulk write failed", "db", w.db, "collection", w.collection, "documents", len(failed), "error", err)
+	}
+	return err
+}
+
+// failedDocuments returns the documents of batch a write that failed with
+// err did not write: those of its write errors, and for an ordered write
+// every one after the first of them. Without write errors none was written.
+func failedDocuments(batch []any, err error, ordered bool) []any {
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || len(bwe.WriteErrors) == 0 || bwe.WriteConcernError != nil {
+		return batch
+	}
+	if ordered {
+		return batch[bwe.WriteErrors[0].Index:]
+	}
+	failed := make([]any, 0, len(bwe.WriteErrors))
+	for _, we := range bwe.WriteErrors {
+		if we.Index >= 0 && we.Index < len(batch) {
+			failed = append(failed, batch[we.Index])
+		}
+	}
+	return failed
+}
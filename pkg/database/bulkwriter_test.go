@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// insertedBatches returns the documents of every InsertMany call of mock
+func insertedBatches(mock *MockDatabase) [][]any {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	var batches [][]any
+	for _, call := range mock.InsertManyCalls {
+		batches = append(batches, call.Documents)
+	}
+	return batches
+}
+
+func TestBulkWriter(t *testing.T) {
+	ctx := context.Background()
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("BatchSize", func(t *testing.T) {
+		mock := NewMockDatabase()
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{BatchSize: 3, MaxInFlight: 1, FlushInterval: time.Hour})
+		for i := range 7 {
+			if err := w.Add(ctx, bson.M{"n": i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := [][]any{
+			{bson.M{"n": 0}, bson.M{"n": 1}, bson.M{"n": 2}},
+			{bson.M{"n": 3}, bson.M{"n": 4}, bson.M{"n": 5}},
+			{bson.M{"n": 6}},
+		}
+		if got := insertedBatches(mock); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected batches %v, got %v", want, got)
+		}
+		if err := w.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if calls := len(insertedBatches(mock)); calls != 3 {
+			t.Errorf("expected Close to have nothing left to write, got %d calls", calls)
+		}
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		mock := NewMockDatabase()
+		clock := NewTestClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{FlushInterval: time.Second, Clock: clock})
+		defer w.Close(ctx)
+		w.Add(ctx, bson.M{"n": 0})
+		w.Add(ctx, bson.M{"n": 1})
+		eventually(t, "the interval flush to be scheduled", func() bool { return clock.Timers() == 1 })
+		clock.Advance(999 * time.Millisecond)
+		if got := insertedBatches(mock); len(got) != 0 {
+			t.Fatalf("expected no flush before the interval, got %v", got)
+		}
+		clock.Advance(time.Millisecond)
+		eventually(t, "the buffer to be flushed", func() bool { return len(insertedBatches(mock)) == 1 })
+		if got := insertedBatches(mock)[0]; len(got) != 2 {
+			t.Errorf("expected both documents, got %v", got)
+		}
+		// An interval with an empty buffer writes nothing
+		eventually(t, "the next flush to be scheduled", func() bool { return clock.Timers() == 1 })
+		clock.Advance(time.Second)
+		eventually(t, "the next flush to be scheduled", func() bool { return clock.Timers() == 1 })
+		if got := len(insertedBatches(mock)); got != 1 {
+			t.Errorf("expected no write of an empty buffer, got %d calls", got)
+		}
+	})
+
+	t.Run("OnError", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.InsertManyFunc = func(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+			return nil, mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Index: 1, Code: 11000}}}}
+		}
+		var mu sync.Mutex
+		var failed []any
+		var reported error
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{
+			BatchSize: 3,
+			OnError: func(docs []any, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				failed, reported = append(failed, docs...), err
+			},
+		})
+		for i := range 3 {
+			w.Add(ctx, bson.M{"n": i})
+		}
+		if err := w.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !reflect.DeepEqual(failed, []any{bson.M{"n": 1}}) || !errors.As(reported, new(mongo.BulkWriteException)) {
+			t.Errorf("expected the failed document and the write error, got %v, %v", failed, reported)
+		}
+	})
+
+	t.Run("FlushError", func(t *testing.T) {
+		mock := NewMockDatabase()
+		failure := errors.New("connection reset")
+		mock.QueueInsertMany(nil, failure)
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{Logger: quiet})
+		defer w.Close(ctx)
+		w.Add(ctx, bson.M{"n": 0})
+		if err := w.Flush(ctx); !errors.Is(err, failure) {
+			t.Errorf("expected the flush to fail, got %v", err)
+		}
+	})
+
+	t.Run("Backpressure", func(t *testing.T) {
+		mock := NewMockDatabase()
+		unblock := make(chan struct{})
+		mock.InsertManyFunc = func(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+			<-unblock
+			return generatedIDs(len(documents)), nil
+		}
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{BatchSize: 2, MaxInFlight: 1, FlushInterval: time.Hour})
+		w.Add(ctx, bson.M{"n": 0})
+		w.Add(ctx, bson.M{"n": 1}) // flushes, and the flush blocks
+		w.Add(ctx, bson.M{"n": 2})
+
+		timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if err := w.Add(timeout, bson.M{"n": 3}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected Add to wait for the running flush, got %v", err)
+		}
+		added := make(chan error, 1)
+		go func() { added <- w.Add(ctx, bson.M{"n": 3}) }()
+		select {
+		case err := <-added:
+			t.Fatalf("expected Add to block, got %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(unblock)
+		if err := <-added; err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		want := [][]any{{bson.M{"n": 0}, bson.M{"n": 1}}, {bson.M{"n": 2}, bson.M{"n": 3}}}
+		if got := insertedBatches(mock); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		mock := NewMockDatabase()
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{})
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range 250 {
+					w.Add(ctx, bson.M{"n": i*250 + j})
+				}
+			}()
+		}
+		wg.Wait()
+		if err := w.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		written := 0
+		for _, batch := range insertedBatches(mock) {
+			written += len(batch)
+		}
+		if written != 1000 {
+			t.Errorf("expected every document to be written by Close, got %d", written)
+		}
+		if err := w.Add(ctx, bson.M{}); !errors.Is(err, ErrBulkWriterClosed) {
+			t.Errorf("expected Add to fail after Close, got %v", err)
+		}
+		if err := w.Close(ctx); err != nil {
+			t.Errorf("expected a second Close to do nothing, got %v", err)
+		}
+	})
+
+	t.Run("WriteModels", func(t *testing.T) {
+		mock := NewMockDatabase()
+		w := NewBulkWriter(&Database{Client: mock}, "vault", "events", BulkWriterConfig{})
+		w.Add(ctx, bson.M{"n": 0})
+		w.Add(ctx, mongo.NewUpdateOneModel().SetFilter(bson.M{"n": 0}).SetUpdate(bson.M{"$set": bson.M{"seen": true}}))
+		if err := w.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+		calls := mock.BulkWriteCalls
+		if len(calls) != 1 || len(calls[0].Models) != 2 || len(mock.InsertManyCalls) != 0 {
+			t.Fatalf("expected one BulkWrite, got %+v", calls)
+		}
+		if _, ok := calls[0].Models[0].(*mongo.InsertOneModel); !ok {
+			t.Errorf("expected the document as an insert, got %T", calls[0].Models[0])
+		}
+	})
+}
+
+func TestFailedDocuments(t *testing.T) {
+	batch := []any{"a", "b", "c", "d"}
+	writeErrors := mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		{WriteError: mongo.WriteError{Index: 1}},
+		{WriteError: mongo.WriteError{Index: 3}},
+	}}
+	tests := []struct {
+		name    string
+		err     error
+		ordered bool
+		want    []any
+	}{
+		{"Unordered", writeErrors, false, []any{"b", "d"}},
+		{"Ordered", writeErrors, true, []any{"b", "c", "d"}},
+		{"Wrapped", errors.Join(ErrDuplicateKey, writeErrors), false, []any{"b", "d"}},
+		{"WriteConcern", mongo.BulkWriteException{WriteErrors: writeErrors.WriteErrors, WriteConcernError: &mongo.WriteConcernError{}}, false, batch},
+		{"Other", errors.New("connection reset"), false, batch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failedDocuments(batch, tt.err, tt.ordered); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultIDChunkSize is how many IDs FindByIDs puts in one $in query when no
+// IDChunkSize is given
+const defaultIDChunkSize = 1000
+
+// IDChunkSize sets how many distinct IDs FindByIDs puts in one $in query.
+// Pass it among the opts of FindByIDs; longer lists are split into several
+// queries, which keeps each query and its results under the server's document
+// size limit.
+type IDChunkSize int
+
+// FindByIDs returns the documents with the given _id values in the order of
+// ids, fetching them with one $in query per chunk of IDChunkSize distinct IDs
+// rather than one FindOne each. The result has one entry per ID: the entry of
+// an ID no document has is nil, and an ID given twice yields its document
+// twice. IDs match as the server matches them, so an ID finds the document
+// stored under the ObjectID it encodes to and a number finds the document
+// stored under any numeric type of the same value. Any other opts are passed
+// on to every Find; a projection must keep _id.
+//
+//	docs, err := db.FindByIDs(ctx, "vault", "events", ids)
+//	for i, doc := range docs {
+//		if doc == nil {
+//			log.Printf("event %v not found", ids[i])
+//		}
+//	}
+func (d *Database) FindByIDs(ctx context.Context, db string, collection string, ids []any, opts ...any) ([]any, error) {
+	chunkSize := defaultIDChunkSize
+	findOpts := make([]any, 0, len(opts))
+	for _, opt := range opts {
+		if n, ok := opt.(IDChunkSize); ok {
+			chunkSize = int(n)
+			continue
+		}
+		findOpts = append(findOpts, opt)
+	}
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("find by ids: chunk size must be at least 1, got %d", chunkSize)
+	}
+
+	keys := make([]string, len(ids))
+	var distinct []any
+	seen := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		keys[i] = idKey(id)
+		if !seen[keys[i]] {
+			seen[keys[i]] = true
+			distinct = append(distinct, id)
+		}
+	}
+
+	found := make(map[string]any, len(distinct))
+	for start := 0; start < len(distinct); start += chunkSize {
+		chunk := distinct[start:min(start+chunkSize, len(distinct))]
+		result, err := d.Client.Find(ctx, db, collection, bson.M{"_id": bson.M{"$in": chunk}}, findOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("find by ids: ids %d to %d: %w", start, start+len(chunk)-1, err)
+		}
+		docs, _ := result.([]any)
+		for _, doc := range docs {
+			fields, _ := normalizeDocument(doc).(map[string]any)
+			id, ok := fields["_id"]
+			if !ok {
+				return nil, fmt.Errorf("find by ids: document without _id: %v", doc)
+			}
+			found[idKey(id)] = doc
+		}
+	}
+
+	out := make([]any, len(ids))
+	for i, key := range keys {
+		out[i] = found[key]
+	}
+	return out, nil
+}
+
+// idKey returns a key equal for the _id values the server considers equal:
+// numbers of any type by value, times by instant, and types with their own
+// BSON encoding, such as ID, as the value they encode to
+func idKey(id any) string {
+	v := normalizeDocument(id)
+	if f, ok := toFloat(v); ok {
+		return fmt.Sprintf("number:%v", f)
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return fmt.Sprintf("time:%d", t.UnixMilli())
+	case primitive.DateTime:
+		return fmt.Sprintf("time:%d", int64(t))
+	}
+	return fmt.Sprintf("%T:%v", v, v)
+}
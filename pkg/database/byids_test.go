@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFindByIDs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Chunked", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		seeded := make([]any, 10000)
+		docs := make([]any, len(seeded))
+		for i := range seeded {
+			seeded[i] = primitive.NewObjectID()
+			docs[i] = bson.M{"_id": seeded[i], "order": i}
+		}
+		if err := fake.Seed("vault", "events", docs...); err != nil {
+			t.Fatal(err)
+		}
+		mock := NewMockDatabase()
+		mock.FindFunc = fake.Find
+		db := &Database{Client: mock}
+
+		// Reversed, with every tenth ID missing and the second ID again at
+		// the end
+		ids := make([]any, 0, len(seeded)+1)
+		missing := map[int]bool{}
+		for i := len(seeded) - 1; i >= 0; i-- {
+			if i%10 == 0 {
+				missing[len(ids)] = true
+				ids = append(ids, primitive.NewObjectID())
+				continue
+			}
+			ids = append(ids, seeded[i])
+		}
+		ids = append(ids, ids[1])
+
+		got, err := db.FindByIDs(ctx, "vault", "events", ids, IDChunkSize(1500))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(ids) {
+			t.Fatalf("expected %d results, got %d", len(ids), len(got))
+		}
+		for i, doc := range got {
+			if missing[i] {
+				if doc != nil {
+					t.Fatalf("expected nil for missing id %d, got %v", i, doc)
+				}
+				continue
+			}
+			fields := normalizeDocument(doc).(map[string]any)
+			if fields["_id"] != ids[i] {
+				t.Fatalf("expected result %d to have _id %v, got %v", i, ids[i], fields["_id"])
+			}
+		}
+		if finds := len(mock.FindCalls); finds != 7 {
+			t.Errorf("expected 7 queries of up to 1500 ids for 10000 distinct ids, got %d", finds)
+		}
+	})
+
+	t.Run("MatchesStoredType", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		id := NewID()
+		if err := fake.Seed("vault", "events",
+			bson.M{"_id": id.ObjectID(), "kind": "motion"},
+			bson.M{"_id": int64(7), "kind": "tamper"},
+			bson.M{"_id": "camera-1", "kind": "offline"},
+		); err != nil {
+			t.Fatal(err)
+		}
+		db := &Database{Client: fake}
+
+		got, err := db.FindByIDs(ctx, "vault", "events", []any{"camera-1", 7, id, "camera-2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds := make([]any, len(got))
+		for i, doc := range got {
+			if doc != nil {
+				kinds[i] = normalizeDocument(doc).(map[string]any)["kind"]
+			}
+		}
+		want := []any{"offline", "tamper", "motion", nil}
+		for i := range want {
+			if kinds[i] != want[i] {
+				t.Errorf("expected kinds %v, got %v", want, kinds)
+				break
+			}
+		}
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{bson.M{"_id": "b"}, bson.M{"_id": "a"}}, nil)
+		mock.QueueFind([]any{bson.M{"_id": "c"}}, nil)
+		db := &Database{Client: mock}
+
+		got, err := db.FindByIDs(ctx, "vault", "events", []any{"a", "b", "a", "c"}, IDChunkSize(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"a", "b", "a", "c"}
+		for i, doc := range got {
+			if doc.(bson.M)["_id"] != want[i] {
+				t.Errorf("expected ids %v, got %v", want, got)
+				break
+			}
+		}
+		if len(mock.FindCalls) != 2 {
+			t.Fatalf("expected 2 queries, got %d", len(mock.FindCalls))
+		}
+		in := mock.FindCalls[0].Filter.(bson.M)["_id"].(bson.M)["$in"].([]any)
+		if len(in) != 2 || in[0] != "a" || in[1] != "b" {
+			t.Errorf("expected the first query for a and b once each, got %v", in)
+		}
+		for _, call := range mock.FindCalls {
+			if len(call.Opts) != 0 {
+				t.Errorf("expected IDChunkSize not to reach Find, got %v", call.Opts)
+			}
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		failed := errors.New("connection reset")
+		mock := NewMockDatabase().ExpectFind(nil, failed)
+		db := &Database{Client: mock}
+
+		if _, err := db.FindByIDs(ctx, "vault", "events", []any{"a"}); !errors.Is(err, failed) {
+			t.Errorf("expected the Find error, got %v", err)
+		}
+		if _, err := db.FindByIDs(ctx, "vault", "events", []any{"a"}, IDChunkSize(0)); err == nil {
+			t.Error("expected an error for a chunk size of 0")
+		}
+		got, err := db.FindByIDs(ctx, "vault", "events", nil)
+		if err != nil || len(got) != 0 || len(mock.FindCalls) != 1 {
+			t.Errorf("expected no query for no ids, got %v, %v after %d queries", got, err, len(mock.FindCalls))
+		}
+	})
+}
@@ -0,0 +1,585 @@
+package database
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCacheTTL is how long WithCache serves a result when no TTL is set
+const defaultCacheTTL = time.Minute
+
+// defaultCacheEntries bounds the in-memory cache of WithCache when
+// MaxEntries is not set
+const defaultCacheEntries = 10000
+
+// CacheKey identifies a cached read: the operation, Find or FindOne, on a
+// namespace with a filter and options in canonical form, so filters that
+// differ only in the order of their keys share an entry
+type CacheKey struct {
+	DB         string
+	Collection string
+	Operation  string
+	Filter     string
+	Options    string
+}
+
+// String returns the key as one string, for caches such as Redis that key
+// entries by string
+func (k CacheKey) String() string {
+	return k.DB + "." + k.Collection + ":" + k.Operation + ":" + k.Filter + ":" + k.Options
+}
+
+// CacheEntry is a cached result and the time it stops being fresh
+type CacheEntry struct {
+	Value   any
+	Expires time.Time
+}
+
+// Cache stores the results of a CachedClient. Implement it to keep results
+// outside the process, such as in Redis; NewMemoryCache is the in-memory
+// implementation. Get returns an entry even after it expired, as the client
+// decides what to do with it. A cache that fails to reach its store should
+// report a miss, so reads fall back to the database.
+type Cache interface {
+	Get(ctx context.Context, key CacheKey) (CacheEntry, bool)
+	Set(ctx context.Context, key CacheKey, entry CacheEntry)
+	// Invalidate drops every entry of db.collection
+	Invalidate(ctx context.Context, db string, collection string)
+	// InvalidateFilter drops the entries of db.collection with the filter,
+	// given in the canonical form of CacheKey.Filter
+	InvalidateFilter(ctx context.Context, db string, collection string, filter string)
+}
+
+// CacheConfig configures WithCache
+type CacheConfig struct {
+	// TTL is how long a result is served from the cache, one minute by
+	// default
+	TTL time.Duration
+	// TTLs overrides TTL for the namespaces it names as "db.collection". A
+	// TTL of zero or less turns caching off for the namespace.
+	TTLs map[string]time.Duration
+	// MaxEntries bounds the default in-memory cache, 10000 entries by
+	// default; the least recently used entries are dropped first
+	MaxEntries int
+	// Cache stores the results, an in-memory cache of MaxEntries by default
+	Cache Cache
+	// StaleWhileRevalidate serves an entry for up to this long after it
+	// expired while one background read per entry refreshes it, so a hot
+	// entry expiring does not send every reader to the database at once.
+	// Zero, the default, turns it off.
+	StaleWhileRevalidate time.Duration
+	// Logger receives the errors of background refreshes, slog.Default() by
+	// default
+	Logger *slog.Logger
+	// Clock supplies the time, the system clock by default
+	Clock Clock
+	// OnHit and OnMiss are called for every cached read, for hit rate
+	// metrics; they must be safe for concurrent use
+	OnHit  func(db string, collection string)
+	OnMiss func(db string, collection string)
+}
+
+// CacheStats counts the reads of a CachedClient served from the cache and
+// from the database
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	// Stale counts the hits served after their entry expired, with
+	// StaleWhileRevalidate
+	Stale int64
+}
+
+// WithCache returns client with a read-through cache for Find and FindOne,
+// for lookups such as settings and feature flags that are read far more often
+// than they change. Results are keyed on the namespace, the filter in
+// canonical form and the options, and served until the TTL of their
+// namespace. Any write through the returned client drops the cached results
+// of the namespaces it touches; writes made elsewhere are seen once the TTL
+// runs out, or after Invalidate or InvalidateNamespace. Errors are never
+// cached. The returned client is a
+// *CachedClient.
+//
+//	flags := database.WithCache(db.Client, database.CacheConfig{
+//		TTLs: map[string]time.Duration{"vault.settings": 5 * time.Minute},
+//	})
+func WithCache(client DatabaseInterface, cfg CacheConfig) DatabaseInterface {
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultCacheTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultCacheEntries
+	}
+	if cfg.Cache == nil {
+		cfg.Cache = NewMemoryCache(cfg.MaxEntries)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	return &CachedClient{
+		DatabaseInterface: client,
+		cfg:               cfg,
+		generations:       map[string]uint64{},
+		refreshing:        map[CacheKey]bool{},
+	}
+}
+
+// CachedClient is the client of WithCache. Reads other than Find and FindOne
+// pass through uncached.
+type CachedClient struct {
+	DatabaseInterface
+	cfg    CacheConfig
+	hits   atomic.Int64
+	misses atomic.Int64
+	stale  atomic.Int64
+
+	mu sync.Mutex
+	// generations counts the writes to each namespace, so a read that
+	// overlapped a write does not cache what it read before the write
+	generations map[string]uint64
+	// refreshing holds the keys being refreshed in the background
+	refreshing map[CacheKey]bool
+	refreshes  sync.WaitGroup
+}
+
+var _ Indexer = (*CachedClient)(nil)
+
+// Stats returns the hits and misses of the reads so far
+func (c *CachedClient) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Stale: c.stale.Load()}
+}
+
+// InvalidateNamespace drops the cached results of db.collection, for writes
+// made by other services, such as those a change stream reports
+func (c *CachedClient) InvalidateNamespace(db string, collection string) {
+	c.invalidate(context.Background(), db, collection)
+}
+
+// Invalidate drops the cached results of db.collection read with filter,
+// under any options. The filter matches in canonical form, so it may spell
+// the filter of the read with its keys in another order.
+func (c *CachedClient) Invalidate(db string, collection string, filter any) {
+	key, ok := cacheKey("", db, collection, filter, nil)
+	if !ok {
+		return
+	}
+	c.bump(db, collection)
+	c.cfg.Cache.InvalidateFilter(context.Background(), db, collection, key.Filter)
+}
+
+func (c *CachedClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return c.read(ctx, "Find", db, collection, filter, opts, func(ctx context.Context) (any, error) {
+		return c.DatabaseInterface.Find(ctx, db, collection, filter, opts...)
+	})
+}
+
+func (c *CachedClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return c.read(ctx, "FindOne", db, collection, filter, opts, func(ctx context.Context) (any, error) {
+		return c.DatabaseInterface.FindOne(ctx, db, collection, filter, opts...)
+	})
+}
+
+// read serves a read from the cache, or from fetch and then caches it
+func (c *CachedClient) read(ctx context.Context, operation string, db string, collection string, filter any, opts []any, fetch func(ctx context.Context) (any, error)) (any, error) {
+	ttl := c.ttl(db, collection)
+	key, ok := cacheKey(operation, db, collection, filter, opts)
+	if ttl <= 0 || !ok {
+		return fetch(ctx)
+	}
+	if entry, ok := c.cfg.Cache.Get(ctx, key); ok {
+		now := c.cfg.Clock.Now()
+		fresh := now.Before(entry.Expires)
+		if fresh || now.Before(entry.Expires.Add(c.cfg.StaleWhileRevalidate)) {
+			c.hits.Add(1)
+			if c.cfg.OnHit != nil {
+				c.cfg.OnHit(db, collection)
+			}
+			if !fresh {
+				c.stale.Add(1)
+				c.refresh(ctx, key, ttl, fetch)
+			}
+			return entry.Value, nil
+		}
+	}
+	c.misses.Add(1)
+	if c.cfg.OnMiss != nil {
+		c.cfg.OnMiss(db, collection)
+	}
+	return c.fill(ctx, key, ttl, fetch)
+}
+
+// fill reads with fetch and caches the result, unless a write to the
+// namespace overlapped the read
+func (c *CachedClient) fill(ctx context.Context, key CacheKey, ttl time.Duration, fetch func(ctx context.Context) (any, error)) (any, error) {
+	generation := c.generation(key.DB, key.Collection)
+	result, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.generation(key.DB, key.Collection) == generation {
+		c.cfg.Cache.Set(ctx, key, CacheEntry{Value: result, Expires: c.cfg.Clock.Now().Add(ttl)})
+	}
+	return result, nil
+}
+
+// refresh fills the entry of key in the background, unless a refresh of it
+// is already running. It outlives the read that started it, so it keeps the
+// values of ctx but not its cancellation, and logs its error rather than
+// returning it.
+func (c *CachedClient) refresh(ctx context.Context, key CacheKey, ttl time.Duration, fetch func(ctx context.Context) (any, error)) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	c.refreshes.Add(1)
+	go func() {
+		defer c.refreshes.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		if _, err := c.fill(context.WithoutCancel(ctx), key, ttl, fetch); err != nil {
+			c.cfg.Logger.Warn("cache refresh failed",
+				"db", key.DB, "collection", key.Collection, "operation", key.Operation, "error", err)
+		}
+	}()
+}
+
+// ttl returns the TTL of db.collection
+func (c *CachedClient) ttl(db string, collection string) time.Duration {
+	if ttl, ok := c.cfg.TTLs[db+"."+collection]; ok {
+		return ttl
+	}
+	return c.cfg.TTL
+}
+
+func (c *CachedClient) generation(db string, collection string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[db+"."+collection]
+}
+
+// invalidate drops the cached results of db.collection after a write to it
+func (c *CachedClient) invalidate(ctx context.Context, db string, collection string) {
+	c.bump(db, collection)
+	c.cfg.Cache.Invalidate(ctx, db, collection)
+}
+
+// bump records a write to db.collection, so the reads running now do not
+// cache their results
+func (c *CachedClient) bump(db string, collection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[db+"."+collection]++
+}
+
+// Aggregate invalidates the collection that a $out or $merge stage writes to
+func (c *CachedClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	result, err := c.DatabaseInterface.Aggregate(ctx, db, collection, pipeline, opts...)
+	for _, stage := range pipelineStagesOf(pipeline) {
+		if targetDB, target, ok := aggregateTarget(db, stage); ok {
+			c.invalidate(ctx, targetDB, target)
+		}
+	}
+	return result, err
+}
+
+func (c *CachedClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.InsertOne(ctx, db, collection, document, opts...)
+}
+
+func (c *CachedClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.InsertMany(ctx, db, collection, documents, opts...)
+}
+
+func (c *CachedClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.UpdateOne(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *CachedClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.UpdateMany(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *CachedClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.ReplaceOne(ctx, db, collection, filter, replacement, opts...)
+}
+
+func (c *CachedClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.DeleteOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *CachedClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.DeleteMany(ctx, db, collection, filter, opts...)
+}
+
+func (c *CachedClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.FindOneAndUpdate(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *CachedClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	defer c.invalidate(ctx, db, collection)
+	return c.DatabaseInterface.BulkWrite(ctx, db, collection, models, opts...)
+}
+
+// Unwrap returns the wrapped client
+func (c *CachedClient) Unwrap() DatabaseInterface {
+	return c.DatabaseInterface
+}
+
+// EnsureIndexes passes index creation on to the wrapped client
+func (c *CachedClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	return EnsureIndexes(ctx, c.DatabaseInterface, db, collection, specs...)
+}
+
+// aggregateTarget returns the namespace a $out or $merge stage writes to
+func aggregateTarget(db string, stage PipelineStage) (string, string, bool) {
+	spec := stage.Spec
+	switch stage.Name {
+	case "$out":
+	case "$merge":
+		if m, ok := spec.(map[string]any); ok {
+			spec = m["into"]
+		}
+	default:
+		return "", "", false
+	}
+	switch t := spec.(type) {
+	case string:
+		return db, t, true
+	case map[string]any:
+		coll, _ := t["coll"].(string)
+		if target, ok := t["db"].(string); ok {
+			db = target
+		}
+		return db, coll, coll != ""
+	}
+	return "", "", false
+}
+
+// cacheKey returns the key of a read. Reads whose filter or options hold
+// values without a stable form, such as functions, are not cached.
+func cacheKey(operation string, db string, collection string, filter any, opts []any) (CacheKey, bool) {
+	var f, o strings.Builder
+	if !writeCanonical(&f, reflect.ValueOf(normalizeDocument(filter))) {
+		return CacheKey{}, false
+	}
+	if !writeCanonical(&o, reflect.ValueOf(opts)) {
+		return CacheKey{}, false
+	}
+	return CacheKey{DB: db, Collection: collection, Operation: operation, Filter: f.String(), Options: o.String()}, true
+}
+
+// writeCanonical writes v in a form that is equal for equal values: map keys
+// are sorted, pointers are followed, and scalars carry their type. Slices,
+// including bson.D, keep their order, since it matters for sorts. It reports
+// false for values without such a form.
+func writeCanonical(b *strings.Builder, v reflect.Value) bool {
+	if !v.IsValid() {
+		b.WriteString("null")
+		return true
+	}
+	if !v.CanInterface() {
+		return false
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		fmt.Fprintf(b, "time(%s)", t.UTC().Format(time.RFC3339Nano))
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("null")
+			return true
+		}
+		return writeCanonical(b, v.Elem())
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%q:", fmt.Sprint(k.Interface()))
+			if !writeCanonical(b, v.MapIndex(k)) {
+				return false
+			}
+		}
+		b.WriteByte('}')
+		return true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			fmt.Fprintf(b, "%s(%x)", v.Type(), v.Interface())
+			return true
+		}
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if !writeCanonical(b, v.Index(i)) {
+				return false
+			}
+		}
+		b.WriteByte(']')
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				// Values such as Decimal128 print their unexported fields
+				fmt.Fprintf(b, "%s(%#v)", v.Type(), v.Interface())
+				return true
+			}
+		}
+		fmt.Fprintf(b, "%s{", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			fmt.Fprintf(b, "%s:", v.Type().Field(i).Name)
+			if !writeCanonical(b, v.Field(i)) {
+				return false
+			}
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+		return true
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	}
+	fmt.Fprintf(b, "%s(%#v)", v.Type(), v.Interface())
+	return true
+}
+
+// MemoryCache is a Cache in process memory holding up to a maximum number of
+// entries, dropping the least recently used first. Values are copied in and
+// out, so callers cannot change a cached result. It is safe for concurrent
+// use.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    *list.List
+	byKey      map[CacheKey]*list.Element
+	byNS       map[string]map[*list.Element]struct{}
+}
+
+type memoryEntry struct {
+	key   CacheKey
+	entry CacheEntry
+}
+
+var _ Cache = (*MemoryCache)(nil)
+
+// NewMemoryCache creates a MemoryCache of up to maxEntries entries
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheEntries
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		byKey:      map[CacheKey]*list.Element{},
+		byNS:       map[string]map[*list.Element]struct{}{},
+	}
+}
+
+// Len returns the number of entries
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.entries.Len()
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key CacheKey) (CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.byKey[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	m.entries.MoveToFront(el)
+	entry := el.Value.(*memoryEntry).entry
+	entry.Value = cloneResult(entry.Value)
+	return entry, true
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key CacheKey, entry CacheEntry) {
+	entry.Value = cloneResult(entry.Value)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.byKey[key]; ok {
+		el.Value.(*memoryEntry).entry = entry
+		m.entries.MoveToFront(el)
+		return
+	}
+	el := m.entries.PushFront(&memoryEntry{key: key, entry: entry})
+	m.byKey[key] = el
+	ns := key.DB + "." + key.Collection
+	if m.byNS[ns] == nil {
+		m.byNS[ns] = map[*list.Element]struct{}{}
+	}
+	m.byNS[ns][el] = struct{}{}
+	for m.entries.Len() > m.maxEntries {
+		m.remove(m.entries.Back())
+	}
+}
+
+func (m *MemoryCache) Invalidate(ctx context.Context, db string, collection string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for el := range m.byNS[db+"."+collection] {
+		m.remove(el)
+	}
+}
+
+func (m *MemoryCache) InvalidateFilter(ctx context.Context, db string, collection string, filter string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for el := range m.byNS[db+"."+collection] {
+		if el.Value.(*memoryEntry).key.Filter == filter {
+			m.remove(el)
+		}
+	}
+}
+
+// remove drops an entry; the caller holds m.mu
+func (m *MemoryCache) remove(el *list.Element) {
+	key := el.Value.(*memoryEntry).key
+	m.entries.Remove(el)
+	delete(m.byKey, key)
+	ns := key.DB + "." + key.Collection
+	delete(m.byNS[ns], el)
+	if len(m.byNS[ns]) == 0 {
+		delete(m.byNS, ns)
+	}
+}
@@ -0,0 +1,406 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCacheKeyCanonical(t *testing.T) {
+	oid := primitive.NewObjectID()
+	tests := []struct {
+		name  string
+		a, b  any
+		aOpts []any
+		bOpts []any
+		same  bool
+	}{
+		{"MapOrder", bson.M{"camera": "c1", "kind": "motion", "score": bson.M{"$gt": 0.5, "$lt": 0.9}}, map[string]any{"score": map[string]any{"$lt": 0.9, "$gt": 0.5}, "kind": "motion", "camera": "c1"}, nil, nil, true},
+		{"DocumentAndMap", bson.D{{Key: "camera", Value: "c1"}, {Key: "kind", Value: "motion"}}, bson.M{"kind": "motion", "camera": "c1"}, nil, nil, true},
+		{"ObjectID", bson.M{"_id": oid}, bson.M{"_id": oid}, nil, nil, true},
+		{"Struct", struct {
+			Camera string `bson:"camera"`
+		}{"c1"}, bson.M{"camera": "c1"}, nil, nil, true},
+		{"Values", bson.M{"camera": "c1"}, bson.M{"camera": "c2"}, nil, nil, false},
+		{"StringAndNumber", bson.M{"n": "7"}, bson.M{"n": 7}, nil, nil, false},
+		{"Nil", nil, bson.M{}, nil, nil, false},
+		{"Limit", bson.M{}, bson.M{}, []any{moptions.Find().SetLimit(1)}, []any{moptions.Find().SetLimit(2)}, false},
+		{"SameOptions", bson.M{}, bson.M{}, []any{moptions.Find().SetLimit(1)}, []any{moptions.Find().SetLimit(1)}, true},
+		{"SortOrder", bson.M{}, bson.M{},
+			[]any{moptions.Find().SetSort(bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}})},
+			[]any{moptions.Find().SetSort(bson.D{{Key: "b", Value: 1}, {Key: "a", Value: 1}})}, false},
+		{"Decimal", bson.M{"n": primitive.NewDecimal128(0, 1)}, bson.M{"n": primitive.NewDecimal128(0, 2)}, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := cacheKey("Find", "vault", "events", tt.a, tt.aOpts)
+			b, okB := cacheKey("Find", "vault", "events", tt.b, tt.bOpts)
+			if !okA || !okB {
+				t.Fatalf("expected both keys to be cacheable")
+			}
+			if (a == b) != tt.same {
+				t.Errorf("expected same=%v, got keys\n%s\n%s", tt.same, a, b)
+			}
+		})
+	}
+
+	t.Run("Uncacheable", func(t *testing.T) {
+		if _, ok := cacheKey("Find", "vault", "events", bson.M{"fn": func() {}}, nil); ok {
+			t.Error("expected a filter holding a function not to be cacheable")
+		}
+	})
+}
+
+func TestWithCache(t *testing.T) {
+	ctx := context.Background()
+	clock := NewTestClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	setup := func(cfg CacheConfig) (*MockDatabase, *CachedClient) {
+		mock := NewMockDatabase()
+		mock.ExpectFindOne(bson.M{"_id": "flags", "beta": true}, nil)
+		mock.ExpectFind([]any{bson.M{"_id": "c1"}}, nil)
+		cfg.Clock = clock
+		return mock, WithCache(mock, cfg).(*CachedClient)
+	}
+
+	t.Run("ReadThrough", func(t *testing.T) {
+		var hits, misses atomic.Int64
+		mock, cached := setup(CacheConfig{
+			OnHit:  func(db, collection string) { hits.Add(1) },
+			OnMiss: func(db, collection string) { misses.Add(1) },
+		})
+		for range 3 {
+			if _, err := cached.FindOne(ctx, "vault", "settings", bson.M{"_id": "flags"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Find is cached apart from FindOne with the same filter
+		if _, err := cached.Find(ctx, "vault", "settings", bson.M{"_id": "flags"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(mock.FindOneCalls) != 1 || len(mock.FindCalls) != 1 {
+			t.Errorf("expected 1 FindOne and 1 Find to reach the client, got %d and %d", len(mock.FindOneCalls), len(mock.FindCalls))
+		}
+		if stats := cached.Stats(); stats.Hits != 2 || stats.Misses != 2 || hits.Load() != 2 || misses.Load() != 2 {
+			t.Errorf("expected 2 hits and 2 misses, got %+v and hooks %d/%d", stats, hits.Load(), misses.Load())
+		}
+	})
+
+	t.Run("FilterOrder", func(t *testing.T) {
+		mock, cached := setup(CacheConfig{})
+		cached.FindOne(ctx, "vault", "cameras", bson.D{{Key: "site", Value: "hq"}, {Key: "active", Value: true}})
+		cached.FindOne(ctx, "vault", "cameras", bson.D{{Key: "active", Value: true}, {Key: "site", Value: "hq"}})
+		cached.FindOne(ctx, "vault", "cameras", map[string]any{"site": "hq", "active": true})
+		if len(mock.FindOneCalls) != 1 {
+			t.Errorf("expected the three spellings to share an entry, got %d calls", len(mock.FindOneCalls))
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		mock, cached := setup(CacheConfig{
+			TTL:  time.Minute,
+			TTLs: map[string]time.Duration{"vault.settings": time.Hour, "vault.events": 0},
+		})
+		read := func() {
+			for _, coll := range []string{"settings", "cameras", "events"} {
+				cached.FindOne(ctx, "vault", coll, bson.M{})
+			}
+		}
+		read()
+		read()
+		if len(mock.FindOneCalls) != 4 {
+			t.Fatalf("expected events to be uncached, got %d calls", len(mock.FindOneCalls))
+		}
+		clock.Advance(2 * time.Minute)
+		read()
+		if len(mock.FindOneCalls) != 6 {
+			t.Errorf("expected cameras to expire after a minute and settings to stay, got %d calls", len(mock.FindOneCalls))
+		}
+	})
+
+	t.Run("WriteInvalidates", func(t *testing.T) {
+		writes := []struct {
+			name  string
+			write func(c *CachedClient) error
+		}{
+			{"InsertOne", func(c *CachedClient) error { _, err := c.InsertOne(ctx, "vault", "settings", bson.M{}); return err }},
+			{"InsertMany", func(c *CachedClient) error {
+				_, err := c.InsertMany(ctx, "vault", "settings", []any{bson.M{}})
+				return err
+			}},
+			{"UpdateOne", func(c *CachedClient) error {
+				_, err := c.UpdateOne(ctx, "vault", "settings", bson.M{}, bson.M{"$set": bson.M{"beta": false}})
+				return err
+			}},
+			{"UpdateMany", func(c *CachedClient) error {
+				_, err := c.UpdateMany(ctx, "vault", "settings", bson.M{}, bson.M{"$set": bson.M{"beta": false}})
+				return err
+			}},
+			{"ReplaceOne", func(c *CachedClient) error {
+				_, err := c.ReplaceOne(ctx, "vault", "settings", bson.M{}, bson.M{})
+				return err
+			}},
+			{"DeleteOne", func(c *CachedClient) error { _, err := c.DeleteOne(ctx, "vault", "settings", bson.M{}); return err }},
+			{"DeleteMany", func(c *CachedClient) error { _, err := c.DeleteMany(ctx, "vault", "settings", bson.M{}); return err }},
+			{"FindOneAndUpdate", func(c *CachedClient) error {
+				_, err := c.FindOneAndUpdate(ctx, "vault", "settings", bson.M{}, bson.M{"$set": bson.M{"beta": false}})
+				return err
+			}},
+			{"BulkWrite", func(c *CachedClient) error { _, err := c.BulkWrite(ctx, "vault", "settings", nil); return err }},
+			{"AggregateOut", func(c *CachedClient) error {
+				_, err := c.Aggregate(ctx, "vault", "events", bson.A{bson.M{"$out": "settings"}})
+				return err
+			}},
+			{"AggregateMerge", func(c *CachedClient) error {
+				_, err := c.Aggregate(ctx, "vault", "events", bson.A{bson.M{"$merge": bson.M{"into": bson.M{"db": "vault", "coll": "settings"}}}})
+				return err
+			}},
+		}
+		for _, tt := range writes {
+			t.Run(tt.name, func(t *testing.T) {
+				mock, cached := setup(CacheConfig{})
+				cached.FindOne(ctx, "vault", "settings", bson.M{"_id": "flags"})
+				cached.FindOne(ctx, "vault", "cameras", bson.M{})
+				tt.write(cached)
+				cached.FindOne(ctx, "vault", "settings", bson.M{"_id": "flags"})
+				cached.FindOne(ctx, "vault", "cameras", bson.M{})
+				if len(mock.FindOneCalls) != 3 {
+					t.Errorf("expected only the settings read to reach the client again, got %d calls", len(mock.FindOneCalls))
+				}
+			})
+		}
+	})
+
+	t.Run("AggregateReadKeepsCache", func(t *testing.T) {
+		mock, cached := setup(CacheConfig{})
+		cached.FindOne(ctx, "vault", "events", bson.M{})
+		cached.Aggregate(ctx, "vault", "events", bson.A{bson.M{"$match": bson.M{}}})
+		cached.FindOne(ctx, "vault", "events", bson.M{})
+		if len(mock.FindOneCalls) != 1 {
+			t.Errorf("expected a read-only pipeline to keep the cache, got %d calls", len(mock.FindOneCalls))
+		}
+	})
+
+	t.Run("ReadDuringWrite", func(t *testing.T) {
+		mock, cached := setup(CacheConfig{})
+		writing := true
+		mock.FindOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+			if writing {
+				// A write lands while the read is on its way back
+				writing = false
+				cached.UpdateOne(ctx, db, collection, bson.M{}, bson.M{"$set": bson.M{"beta": false}})
+				return bson.M{"beta": true}, nil
+			}
+			return bson.M{"beta": false}, nil
+		}
+		cached.FindOne(ctx, "vault", "settings", bson.M{})
+		got, _ := cached.FindOne(ctx, "vault", "settings", bson.M{})
+		if got.(bson.M)["beta"] != false {
+			t.Errorf("expected the read that overlapped the write not to be cached, got %v", got)
+		}
+	})
+
+	t.Run("ErrorsNotCached", func(t *testing.T) {
+		mock, cached := setup(CacheConfig{})
+		failed := errors.New("timeout")
+		mock.ExpectFindOne(nil, failed)
+		if _, err := cached.FindOne(ctx, "vault", "settings", bson.M{}); !errors.Is(err, failed) {
+			t.Fatalf("expected the error, got %v", err)
+		}
+		mock.ExpectFindOne(bson.M{"beta": true}, nil)
+		if got, err := cached.FindOne(ctx, "vault", "settings", bson.M{}); err != nil || got == nil {
+			t.Errorf("expected the read after the error to reach the client, got %v, %v", got, err)
+		}
+	})
+
+	t.Run("CopiesResults", func(t *testing.T) {
+		_, cached := setup(CacheConfig{})
+		first, _ := cached.FindOne(ctx, "vault", "settings", bson.M{})
+		first.(bson.M)["beta"] = false
+		second, _ := cached.FindOne(ctx, "vault", "settings", bson.M{})
+		second.(bson.M)["beta"] = "changed"
+		third, _ := cached.FindOne(ctx, "vault", "settings", bson.M{})
+		if third.(bson.M)["beta"] != true {
+			t.Errorf("expected callers not to change the cached result, got %v", third)
+		}
+	})
+
+	t.Run("Unwrap", func(t *testing.T) {
+		mock, cached := setup(CacheConfig{})
+		if inner, ok := implementation[*MockDatabase](cached); !ok || inner != mock {
+			t.Error("expected the mock behind the cache")
+		}
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	key := func(coll, filter string) CacheKey {
+		return CacheKey{DB: "vault", Collection: coll, Operation: "FindOne", Filter: filter}
+	}
+
+	t.Run("LRU", func(t *testing.T) {
+		cache := NewMemoryCache(2)
+		cache.Set(ctx, key("a", "1"), CacheEntry{Value: 1})
+		cache.Set(ctx, key("a", "2"), CacheEntry{Value: 2})
+		cache.Get(ctx, key("a", "1"))
+		cache.Set(ctx, key("a", "3"), CacheEntry{Value: 3})
+		if _, ok := cache.Get(ctx, key("a", "2")); ok {
+			t.Error("expected the least recently used entry to be dropped")
+		}
+		for _, filter := range []string{"1", "3"} {
+			if _, ok := cache.Get(ctx, key("a", filter)); !ok {
+				t.Errorf("expected entry %s to stay", filter)
+			}
+		}
+		if cache.Len() != 2 {
+			t.Errorf("expected 2 entries, got %d", cache.Len())
+		}
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		cache := NewMemoryCache(10)
+		cache.Set(ctx, key("a", "1"), CacheEntry{Value: 1})
+		cache.Set(ctx, key("a", "2"), CacheEntry{Value: 2})
+		cache.Set(ctx, key("b", "1"), CacheEntry{Value: 3})
+		cache.Invalidate(ctx, "vault", "a")
+		if cache.Len() != 1 {
+			t.Errorf("expected only vault.b to stay, got %d entries", cache.Len())
+		}
+		if _, ok := cache.Get(ctx, key("b", "1")); !ok {
+			t.Error("expected vault.b to stay")
+		}
+	})
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockDatabase().ExpectFindOne(bson.M{"beta": true}, nil)
+	cached := WithCache(mock, CacheConfig{}).(*CachedClient)
+	read := func(coll string, filter any, opts ...any) {
+		if _, err := cached.FindOne(ctx, "vault", coll, filter, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read("settings", bson.M{"_id": "flags", "site": "hq"})
+	read("settings", bson.M{"_id": "flags", "site": "hq"}, moptions.FindOne().SetProjection(bson.M{"beta": 1}))
+	read("settings", bson.M{"_id": "limits"})
+	read("cameras", bson.M{"_id": "flags", "site": "hq"})
+
+	// The filter in another key order drops both reads with it
+	cached.Invalidate("vault", "settings", bson.D{{Key: "site", Value: "hq"}, {Key: "_id", Value: "flags"}})
+	read("settings", bson.M{"_id": "flags", "site": "hq"})
+	read("settings", bson.M{"_id": "flags", "site": "hq"}, moptions.FindOne().SetProjection(bson.M{"beta": 1}))
+	read("settings", bson.M{"_id": "limits"})
+	read("cameras", bson.M{"_id": "flags", "site": "hq"})
+	if len(mock.FindOneCalls) != 6 {
+		t.Fatalf("expected only the two reads with the filter to reach the client again, got %d calls", len(mock.FindOneCalls))
+	}
+
+	cached.InvalidateNamespace("vault", "settings")
+	read("settings", bson.M{"_id": "limits"})
+	read("cameras", bson.M{"_id": "flags", "site": "hq"})
+	if len(mock.FindOneCalls) != 7 {
+		t.Errorf("expected only the settings read to reach the client again, got %d calls", len(mock.FindOneCalls))
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	clock := NewTestClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t.Run("SingleFlight", func(t *testing.T) {
+		var calls atomic.Int64
+		started, release := make(chan struct{}), make(chan struct{})
+		mock := NewMockDatabase()
+		mock.FindOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+			if calls.Add(1) == 1 {
+				return bson.M{"version": 1}, nil
+			}
+			if calls.Load() == 2 {
+				close(started)
+			}
+			<-release
+			return bson.M{"version": 2}, nil
+		}
+		cached := WithCache(mock, CacheConfig{TTL: time.Minute, StaleWhileRevalidate: time.Hour, Clock: clock}).(*CachedClient)
+		cached.FindOne(ctx, "vault", "settings", bson.M{})
+		clock.Advance(2 * time.Minute)
+
+		// Every reader gets the stale value at once while one refresh is
+		// blocked in the database
+		var wg sync.WaitGroup
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := cached.FindOne(ctx, "vault", "settings", bson.M{})
+				if err != nil || got.(bson.M)["version"] != 1 {
+					t.Errorf("expected the stale version 1, got %v, %v", got, err)
+				}
+			}()
+		}
+		wg.Wait()
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a background refresh")
+		}
+		if n := calls.Load(); n != 2 {
+			t.Errorf("expected one refresh for 50 stale reads, got %d", n-1)
+		}
+		close(release)
+		cached.refreshes.Wait()
+
+		got, _ := cached.FindOne(ctx, "vault", "settings", bson.M{})
+		if got.(bson.M)["version"] != 2 {
+			t.Errorf("expected the refreshed version 2, got %v", got)
+		}
+		if stats := cached.Stats(); stats.Stale != 50 || stats.Misses != 1 {
+			t.Errorf("expected 50 stale hits and 1 miss, got %+v", stats)
+		}
+	})
+
+	t.Run("RefreshError", func(t *testing.T) {
+		var logs strings.Builder
+		mock := NewMockDatabase().ExpectFindOne(bson.M{"version": 1}, nil)
+		cached := WithCache(mock, CacheConfig{
+			TTL:                  time.Minute,
+			StaleWhileRevalidate: time.Hour,
+			Clock:                clock,
+			Logger:               slog.New(slog.NewTextHandler(&logs, nil)),
+		}).(*CachedClient)
+		cached.FindOne(ctx, "vault", "settings", bson.M{})
+		clock.Advance(2 * time.Minute)
+
+		mock.ExpectFindOne(nil, errors.New("primary stepped down"))
+		got, err := cached.FindOne(ctx, "vault", "settings", bson.M{})
+		if err != nil || got.(bson.M)["version"] != 1 {
+			t.Errorf("expected the stale value without the refresh error, got %v, %v", got, err)
+		}
+		cached.refreshes.Wait()
+		if !strings.Contains(logs.String(), "primary stepped down") {
+			t.Errorf("expected the refresh error to be logged, got %q", logs.String())
+		}
+	})
+
+	t.Run("TooStale", func(t *testing.T) {
+		mock := NewMockDatabase().ExpectFindOne(bson.M{"version": 1}, nil)
+		cached := WithCache(mock, CacheConfig{TTL: time.Minute, StaleWhileRevalidate: time.Minute, Clock: clock}).(*CachedClient)
+		cached.FindOne(ctx, "vault", "settings", bson.M{})
+		clock.Advance(3 * time.Minute)
+		cached.FindOne(ctx, "vault", "settings", bson.M{})
+		if len(mock.FindOneCalls) != 2 || cached.Stats().Stale != 0 {
+			t.Errorf("expected an entry past the stale window to be read again in line, got %d calls and %+v", len(mock.FindOneCalls), cached.Stats())
+		}
+	})
+}
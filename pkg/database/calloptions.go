@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ErrInvalidCallOption is returned by an operation whose context carries a
+// per-call override the client cannot apply, such as an unknown read
+// preference mode
+var ErrInvalidCallOption = errors.New("invalid call option")
+
+// callOptionsKey is the context key of CallOptions
+type callOptionsKey struct{}
+
+// CallOptions are the per-call overrides a context carries. MongoClient
+// applies them to the operations run with the context in place of the
+// client's ReadPreference and WriteConcern; the mock records them with each
+// call.
+type CallOptions struct {
+	// ReadPreference is a read preference mode such as "primary"
+	ReadPreference string
+	// WriteConcern is "majority" after WithWriteConcernMajority
+	WriteConcern string
+	// Comment is attached to the command and shows up in the server's logs,
+	// profiler and currentOp
+	Comment string
+}
+
+// WithReadPreference returns a context whose operations read with the read
+// preference mode, one of "primary", "primaryPreferred", "secondary",
+// "secondaryPreferred" and "nearest", for a strongly consistent read among
+// otherwise secondary-preferred traffic. An unknown mode fails the
+// operations with ErrInvalidCallOption; an empty mode restores the client's.
+//
+//	ctx = database.WithReadPreference(ctx, "primary")
+//	doc, err := db.Client.FindOne(ctx, "vault", "accounts", database.ByID(id))
+func WithReadPreference(ctx context.Context, mode string) context.Context {
+	opts := CallOptionsFrom(ctx)
+	opts.ReadPreference = mode
+	return context.WithValue(ctx, callOptionsKey{}, opts)
+}
+
+// WithWriteConcernMajority returns a context whose writes are acknowledged
+// by a majority of the replica set members
+func WithWriteConcernMajority(ctx context.Context) context.Context {
+	opts := CallOptionsFrom(ctx)
+	opts.WriteConcern = "majority"
+	return context.WithValue(ctx, callOptionsKey{}, opts)
+}
+
+// WithComment returns a context whose operations carry comment, so a job's
+// queries can be told apart in the server's logs and profiler. It replaces
+// a comment passed in the operation's options; an empty comment removes it.
+func WithComment(ctx context.Context, comment string) context.Context {
+	opts := CallOptionsFrom(ctx)
+	opts.Comment = comment
+	return context.WithValue(ctx, callOptionsKey{}, opts)
+}
+
+// CallOptionsFrom returns the per-call overrides ctx carries
+func CallOptionsFrom(ctx context.Context) CallOptions {
+	if ctx == nil {
+		return CallOptions{}
+	}
+	opts, _ := ctx.Value(callOptionsKey{}).(CallOptions)
+	return opts
+}
+
+// callOptions returns the per-call overrides of ctx, failing with
+// ErrInvalidCallOption when one cannot be applied
+func callOptions(ctx context.Context) (CallOptions, error) {
+	opts := CallOptionsFrom(ctx)
+	if _, err := opts.readPreference(); err != nil {
+		return opts, err
+	}
+	if opts.WriteConcern != "" && opts.WriteConcern != "majority" {
+		return opts, fmt.Errorf("%w: unknown write concern %q", ErrInvalidCallOption, opts.WriteConcern)
+	}
+	return opts, nil
+}
+
+// readPreference returns the read preference of the overrides, nil when
+// they leave the client's in place
+func (o CallOptions) readPreference() (*readpref.ReadPref, error) {
+	if o.ReadPreference == "" {
+		return nil, nil
+	}
+	mode, err := readpref.ModeFromString(o.ReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read preference: %w", ErrInvalidCallOption, err)
+	}
+	rp, err := readpref.New(mode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read preference: %w", ErrInvalidCallOption, err)
+	}
+	return rp, nil
+}
+
+// collectionOptions returns the collection options applying the read
+// preference and write concern of validated overrides
+func (o CallOptions) collectionOptions() *moptions.CollectionOptions {
+	opts := moptions.Collection()
+	if rp, _ := o.readPreference(); rp != nil {
+		opts.SetReadPreference(rp)
+	}
+	if o.WriteConcern != "" {
+		opts.SetWriteConcern(writeConcern(o.WriteConcern))
+	}
+	return opts
+}
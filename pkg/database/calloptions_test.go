@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestCallOptions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Compose", func(t *testing.T) {
+		if got := CallOptionsFrom(ctx); got != (CallOptions{}) {
+			t.Errorf("expected no overrides, got %+v", got)
+		}
+		inner := WithComment(WithWriteConcernMajority(WithReadPreference(ctx, "secondary")), "backfill-job-42")
+		outer := WithReadPreference(inner, "primary")
+		want := CallOptions{ReadPreference: "primary", WriteConcern: "majority", Comment: "backfill-job-42"}
+		if got := CallOptionsFrom(outer); got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+		if got := CallOptionsFrom(inner).ReadPreference; got != "secondary" {
+			t.Errorf("expected the parent context to keep its read preference, got %q", got)
+		}
+		if got := CallOptionsFrom(WithComment(outer, "")); got.Comment != "" || got.ReadPreference != "primary" {
+			t.Errorf("expected an empty comment to remove only the comment, got %+v", got)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			ctx     context.Context
+			wantErr bool
+		}{
+			{"None", ctx, false},
+			{"Primary", WithReadPreference(ctx, "primary"), false},
+			{"SecondaryPreferred", WithReadPreference(ctx, "secondaryPreferred"), false},
+			{"UnknownMode", WithReadPreference(ctx, "fastest"), true},
+			{"Majority", WithWriteConcernMajority(ctx), false},
+			{"Comment", WithComment(ctx, "backfill-job-42"), false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := callOptions(tt.ctx)
+				if tt.wantErr != errors.Is(err, ErrInvalidCallOption) {
+					t.Errorf("expected error %v, got %v", tt.wantErr, err)
+				}
+			})
+		}
+	})
+
+	t.Run("MongoClient", func(t *testing.T) {
+		client, err := mongo.Connect(ctx, moptions.Client().ApplyURI("mongodb://localhost:1").SetReadPreference(readpref.SecondaryPreferred()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Disconnect(ctx)
+		m := &MongoClient{Client: client}
+
+		if _, call, err := m.collection(ctx, "vault", "accounts"); err != nil || call != (CallOptions{}) {
+			t.Fatalf("expected no overrides, got %+v, %v", call, err)
+		}
+		if opts := (CallOptions{}).collectionOptions(); opts.ReadPreference != nil || opts.WriteConcern != nil {
+			t.Errorf("expected the client's read preference and write concern without overrides, got %+v", opts)
+		}
+
+		_, call, err := m.collection(WithComment(WithWriteConcernMajority(WithReadPreference(ctx, "primary")), "audit"), "vault", "accounts")
+		if err != nil {
+			t.Fatal(err)
+		}
+		coll := call.collectionOptions()
+		if coll.ReadPreference == nil || coll.ReadPreference.Mode() != readpref.PrimaryMode {
+			t.Errorf("expected the per-call read preference, got %v", coll.ReadPreference)
+		}
+		if coll.WriteConcern == nil || coll.WriteConcern.W != "majority" {
+			t.Errorf("expected the majority write concern, got %v", coll.WriteConcern)
+		}
+		opts := withComment([]any{moptions.Find().SetLimit(1)}, call.Comment, moptions.Find().SetComment)
+		if len(opts) != 2 || opts[1].Comment == nil || *opts[1].Comment != "audit" {
+			t.Errorf("expected the comment to follow the caller's options, got %+v", opts)
+		}
+		if opts := withComment([]any{}, call.Comment, moptions.Update().SetComment); len(opts) != 1 || opts[0].Comment != "audit" {
+			t.Errorf("expected the comment on update options, got %+v", opts)
+		}
+		if opts := withComment([]any{}, "", moptions.Find().SetComment); len(opts) != 0 {
+			t.Errorf("expected no option without a comment, got %+v", opts)
+		}
+
+		if _, err := m.FindOne(WithReadPreference(ctx, "fastest"), "vault", "accounts", bson.M{}); !errors.Is(err, ErrInvalidCallOption) {
+			t.Errorf("expected an unknown read preference to fail the call before it is sent, got %v", err)
+		}
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		strong := WithComment(WithReadPreference(ctx, "primary"), "critical-read")
+		mock.FindOne(strong, "vault", "accounts", bson.M{})
+		mock.UpdateOne(WithWriteConcernMajority(ctx), "vault", "accounts", bson.M{}, bson.M{"$set": bson.M{"a": 1}})
+		mock.Find(ctx, "vault", "accounts", bson.M{})
+
+		if got := mock.FindOneCalls[0].CallOptions; got.ReadPreference != "primary" || got.Comment != "critical-read" {
+			t.Errorf("expected the FindOne record to carry the overrides, got %+v", got)
+		}
+		if got := mock.UpdateOneCalls[0].CallOptions.WriteConcern; got != "majority" {
+			t.Errorf("expected the UpdateOne record to carry the write concern, got %q", got)
+		}
+		if got := mock.FindCalls[0].CallOptions; got != (CallOptions{}) {
+			t.Errorf("expected no overrides on the Find record, got %+v", got)
+		}
+		history := mock.History()
+		if got := history[0].CallOptions.ReadPreference; got != "primary" {
+			t.Errorf("expected the history to carry the overrides, got %q", got)
+		}
+		for _, call := range history {
+			for _, arg := range call.Args {
+				if _, ok := arg.(CallOptions); ok {
+					t.Errorf("expected the overrides to stay out of the %s arguments", call.Operation)
+				}
+			}
+		}
+
+		_, err := mock.FindOne(WithReadPreference(ctx, "fastest"), "vault", "accounts", bson.M{})
+		if !errors.Is(err, ErrInvalidCallOption) {
+			t.Errorf("expected an unknown read preference to fail the call, got %v", err)
+		}
+		if last := mock.History()[len(mock.History())-1]; last.Source != SourceInvalid {
+			t.Errorf("expected the call to be recorded as invalid, got %q", last.Source)
+		}
+	})
+
+	t.Run("Fake", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if _, err := fake.InsertOne(WithWriteConcernMajority(ctx), "vault", "accounts", bson.M{"_id": 1}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fake.FindOne(WithReadPreference(ctx, "nearest"), "vault", "accounts", bson.M{"_id": 1}); err != nil {
+			t.Errorf("expected a valid read preference to be accepted, got %v", err)
+		}
+		if _, err := fake.Count(WithReadPreference(ctx, "fastest"), "vault", "accounts", bson.M{}); !errors.Is(err, ErrInvalidCallOption) {
+			t.Errorf("expected an unknown read preference to fail the call, got %v", err)
+		}
+	})
+}
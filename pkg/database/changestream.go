@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeStream is an open change stream, as returned by Watch.
+// *mongo.ChangeStream implements it.
+type ChangeStream interface {
+	Next(ctx context.Context) bool
+	Decode(val any) error
+	// ResumeToken returns the token to resume after the current event
+	ResumeToken() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// Watcher is implemented by clients that open change streams
+type Watcher interface {
+	Watch(ctx context.Context, db string, collection string, pipeline any, opts ...any) (ChangeStream, error)
+}
+
+// ChangeEvent is a change stream event
+type ChangeEvent struct {
+	// ID is the resume token of the event
+	ID                bson.Raw            `bson:"_id,omitempty"`
+	OperationType     string              `bson:"operationType"`
+	Namespace         ChangeNamespace     `bson:"ns"`
+	DocumentKey       bson.M              `bson:"documentKey,omitempty"`
+	FullDocument      bson.M              `bson:"fullDocument,omitempty"`
+	UpdateDescription *UpdateDescription  `bson:"updateDescription,omitempty"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime,omitempty"`
+	WallTime          time.Time           `bson:"wallTime,omitempty"`
+}
+
+// ChangeNamespace is the namespace a ChangeEvent happened in
+type ChangeNamespace struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"coll"`
+}
+
+// UpdateDescription lists the fields an update event changed
+type UpdateDescription struct {
+	UpdatedFields bson.M   `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+// Watch opens a change stream on db.collection, filtered by an aggregation
+// pipeline such as mongo.Pipeline{{{Key: "$match", Value: ...}}}; nil
+// watches every event. opts are *options.ChangeStreamOptions. The client
+// must implement Watcher, as MongoClient and MockDatabase do; change streams
+// need a replica set or sharded cluster.
+func Watch(ctx context.Context, client DatabaseInterface, db string, collection string, pipeline any, opts ...any) (ChangeStream, error) {
+	watcher, ok := implementation[Watcher](client)
+	if !ok {
+		return nil, fmt.Errorf("client %T cannot watch change streams", client)
+	}
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+	return watcher.Watch(ctx, db, collection, pipeline, opts...)
+}
+
+// ResumeTokenStore keeps the resume token of a subscription, so it continues
+// after the last handled event when restarted
+type ResumeTokenStore interface {
+	// LoadResumeToken returns the token saved under key, nil when none is
+	LoadResumeToken(ctx context.Context, key string) (bson.Raw, error)
+	SaveResumeToken(ctx context.Context, key string, token bson.Raw) error
+}
+
+// MemoryResumeTokenStore is a ResumeTokenStore for one process, for tests
+// and subscriptions that may miss events across restarts
+type MemoryResumeTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]bson.Raw
+}
+
+// NewMemoryResumeTokenStore creates an empty MemoryResumeTokenStore
+func NewMemoryResumeTokenStore() *MemoryResumeTokenStore {
+	return &MemoryResumeTokenStore{tokens: map[string]bson.Raw{}}
+}
+
+// LoadResumeToken implements ResumeTokenStore
+func (s *MemoryResumeTokenStore) LoadResumeToken(ctx context.Context, key string) (bson.Raw, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens[key], nil
+}
+
+// SaveResumeToken implements ResumeTokenStore
+func (s *MemoryResumeTokenStore) SaveResumeToken(ctx context.Context, key string, token bson.Raw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[key] = append(bson.Raw(nil), token...)
+	return nil
+}
+
+// CollectionResumeTokenStore is a ResumeTokenStore keeping one document per
+// key, {_id: key, token: ..., updated_at: ...}, in a collection
+type CollectionResumeTokenStore struct {
+	Client     DatabaseInterface
+	DB         string
+	Collection string
+}
+
+// LoadResumeToken implements ResumeTokenStore
+func (s *CollectionResumeTokenStore) LoadResumeToken(ctx context.Context, key string) (bson.Raw, error) {
+	result, err := s.Client.FindOne(ctx, s.DB, s.Collection, bson.M{"_id": key})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load resume token %s: %w", key, err)
+	}
+	var doc struct {
+		Token bson.Raw `bson:"token"`
+	}
+	if err := decodeDocument(result, &doc); err != nil {
+		return nil, fmt.Errorf("load resume token %s: %w", key, err)
+	}
+	return doc.Token, nil
+}
+
+// SaveResumeToken implements ResumeTokenStore
+func (s *CollectionResumeTokenStore) SaveResumeToken(ctx context.Context, key string, token bson.Raw) error {
+	_, err := s.Client.ReplaceOne(ctx, s.DB, s.Collection, bson.M{"_id": key},
+		bson.M{"_id": key, "token": token, "updated_at": time.Now().UTC()}, moptions.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("save resume token %s: %w", key, err)
+	}
+	return nil
+}
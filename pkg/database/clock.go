@@ -0,0 +1,119 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the fake what time it is, so time-based behavior such as TTL
+// expiry can be tested without sleeping
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock is a Clock that also schedules, so background work that runs
+// on an interval can be tested without sleeping. Helpers taking a Clock use
+// After when it implements TimerClock and the system timers otherwise.
+type TimerClock interface {
+	Clock
+	// After returns a channel that receives the clock's time once d passed
+	After(d time.Duration) <-chan time.Time
+}
+
+// clockAfter waits for d on clock when it is a TimerClock
+func clockAfter(clock Clock, d time.Duration) <-chan time.Time {
+	if timers, ok := clock.(TimerClock); ok {
+		return timers.After(d)
+	}
+	return time.After(d)
+}
+
+// systemClock is the Clock the fake uses unless SetClock replaces it
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// TestClock is a TimerClock that only moves when told to. It is safe for
+// concurrent use.
+type TestClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []testTimer
+}
+
+// testTimer is a pending After of a TestClock
+type testTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewTestClock creates a TestClock stopped at now
+func NewTestClock(now time.Time) *TestClock {
+	return &TestClock{now: now}
+}
+
+// Now returns the clock's current time
+func (c *TestClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *TestClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Set moves the clock to now
+func (c *TestClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+	c.fire()
+}
+
+// After returns a channel that receives the clock's time once Advance or
+// Set moved it d past now
+func (c *TestClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, testTimer{at: c.now.Add(d), ch: ch})
+	c.fire()
+	return ch
+}
+
+// Timers returns the number of After channels still waiting, so tests can
+// wait for background work to schedule itself before advancing the clock
+func (c *TestClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// fire delivers the timers that are due; the caller holds c.mu
+func (c *TestClock) fire() {
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = pending
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTestClockAfter(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := NewTestClock(start)
+	fired := func(ch <-chan time.Time) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if !fired(clock.After(0)) {
+		t.Error("expected a zero duration to fire at once")
+	}
+	second, minute := clock.After(time.Second), clock.After(time.Minute)
+	if clock.Timers() != 2 {
+		t.Fatalf("expected 2 pending timers, got %d", clock.Timers())
+	}
+	clock.Advance(999 * time.Millisecond)
+	if fired(second) {
+		t.Error("expected no timer to fire early")
+	}
+	clock.Advance(time.Millisecond)
+	if !fired(second) || fired(minute) || clock.Timers() != 1 {
+		t.Errorf("expected only the one second timer to fire, %d pending", clock.Timers())
+	}
+	clock.Set(start.Add(time.Hour))
+	if !fired(minute) || clock.Timers() != 0 {
+		t.Error("expected Set to fire the due timer")
+	}
+}
@@ -0,0 +1,333 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// BSONOptions configures how documents are encoded and decoded. The real
+// client applies them to its connection and the typed helpers such as FindAs
+// decode with them, so both agree.
+type BSONOptions struct {
+	// UUIDs decodes binary subtype 4 values into UUID instead of
+	// primitive.Binary when the target is any, including in nested documents
+	UUIDs bool
+	// NilSliceAsEmpty encodes nil slices as empty arrays instead of null
+	NilSliceAsEmpty bool
+	// NilMapAsEmpty encodes nil maps as empty documents instead of null
+	NilMapAsEmpty bool
+	// DefaultDocumentM decodes documents into bson.M instead of bson.D when
+	// the target is any
+	DefaultDocumentM bool
+	// UseLocalTimeZone decodes dates into time.Time in the local time zone
+	// instead of UTC
+	UseLocalTimeZone bool
+	// Codecs registers custom encoders and decoders for specific types
+	Codecs []Codec
+}
+
+// Codec pairs an encoder and a decoder for one Go type. Either may be nil to
+// keep the default for that direction.
+type Codec struct {
+	Type    reflect.Type
+	Encoder bsoncodec.ValueEncoder
+	Decoder bsoncodec.ValueDecoder
+}
+
+// Registry returns the codec registry described by the options
+func (o *BSONOptions) Registry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	o.register(registry)
+	return registry
+}
+
+// register adds the codecs of the options to registry
+func (o *BSONOptions) register(registry *bsoncodec.Registry) {
+	if o == nil {
+		return
+	}
+	if o.UUIDs {
+		registry.RegisterTypeDecoder(reflect.TypeOf((*any)(nil)).Elem(), uuidInterfaceDecoder{bsoncodec.NewEmptyInterfaceCodec()})
+	}
+	for _, codec := range o.Codecs {
+		if codec.Encoder != nil {
+			registry.RegisterTypeEncoder(codec.Type, codec.Encoder)
+		}
+		if codec.Decoder != nil {
+			registry.RegisterTypeDecoder(codec.Type, codec.Decoder)
+		}
+	}
+}
+
+// clientOptions applies the options to a driver client configuration
+func (o *BSONOptions) clientOptions(opts *moptions.ClientOptions) *moptions.ClientOptions {
+	if o == nil {
+		return opts
+	}
+	return opts.
+		SetRegistry(o.Registry()).
+		SetBSONOptions(&moptions.BSONOptions{
+			NilSliceAsEmpty:  o.NilSliceAsEmpty,
+			NilMapAsEmpty:    o.NilMapAsEmpty,
+			DefaultDocumentM: o.DefaultDocumentM,
+			UseLocalTimeZone: o.UseLocalTimeZone,
+		})
+}
+
+// codec encodes and decodes values with one registry built from BSONOptions.
+// Its encoders and decoders are pooled, so decoding many documents does not
+// set up a writer, an encoder and a decoder for each of them.
+type codec struct {
+	options  *BSONOptions
+	registry *bsoncodec.Registry
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func newCodec(options *BSONOptions) *codec {
+	registry := bson.NewRegistry()
+	maps := mapEncoder{nilMapAsEmpty: options != nil && options.NilMapAsEmpty}
+	registry.RegisterTypeEncoder(reflect.TypeFor[map[string]any](), maps)
+	registry.RegisterTypeEncoder(reflect.TypeFor[bson.M](), maps)
+	options.register(registry)
+	return &codec{options: options, registry: registry}
+}
+
+// mapEncoder encodes map[string]any and bson.M like the driver's map codec,
+// without the copy its reflection makes of every key and value. The fake and
+// the mock hold documents as such maps, so encoding them is most of the cost
+// of decoding their results into structs.
+type mapEncoder struct {
+	nilMapAsEmpty bool
+}
+
+func (e mapEncoder) EncodeValue(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	var m map[string]any
+	switch t := val.Interface().(type) {
+	case map[string]any:
+		m = t
+	case bson.M:
+		m = t
+	default:
+		return bsoncodec.ValueEncoderError{Name: "mapEncoder", Types: []reflect.Type{val.Type()}, Received: val}
+	}
+	if m == nil && !e.nilMapAsEmpty {
+		return vw.WriteNull()
+	}
+	dw, err := vw.WriteDocument()
+	if err != nil {
+		return err
+	}
+	for key, value := range m {
+		evw, err := dw.WriteDocumentElement(key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			if err := evw.WriteNull(); err != nil {
+				return err
+			}
+			continue
+		}
+		v := reflect.ValueOf(value)
+		encoder, err := ec.LookupEncoder(v.Type())
+		if err != nil {
+			return err
+		}
+		if err := encoder.EncodeValue(ec, evw, v); err != nil {
+			return err
+		}
+	}
+	return dw.WriteDocumentEnd()
+}
+
+// pooledEncoder encodes documents into the slice of its appendWriter. The
+// value writer keeps its scratch buffer between documents.
+type pooledEncoder struct {
+	out appendWriter
+	enc *bson.Encoder
+}
+
+// appendWriter appends what is written to it to dst
+type appendWriter struct {
+	dst []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.dst = append(w.dst, p...)
+	return len(p), nil
+}
+
+func (c *codec) encoder() (*pooledEncoder, error) {
+	if e, ok := c.encoders.Get().(*pooledEncoder); ok {
+		return e, nil
+	}
+	e := &pooledEncoder{}
+	vw, err := bsonrw.NewBSONValueWriter(&e.out)
+	if err != nil {
+		return nil, err
+	}
+	if e.enc, err = bson.NewEncoder(vw); err != nil {
+		return nil, err
+	}
+	if err := e.enc.SetRegistry(c.registry); err != nil {
+		return nil, err
+	}
+	if c.options != nil && c.options.NilSliceAsEmpty {
+		e.enc.NilSliceAsEmpty()
+	}
+	if c.options != nil && c.options.NilMapAsEmpty {
+		e.enc.NilMapAsEmpty()
+	}
+	return e, nil
+}
+
+func (c *codec) decoder() (*bson.Decoder, error) {
+	if dec, ok := c.decoders.Get().(*bson.Decoder); ok {
+		return dec, nil
+	}
+	dec := &bson.Decoder{}
+	if err := dec.SetRegistry(c.registry); err != nil {
+		return nil, err
+	}
+	if c.options != nil && c.options.DefaultDocumentM {
+		dec.DefaultDocumentM()
+	}
+	if c.options != nil && c.options.UseLocalTimeZone {
+		dec.UseLocalTimeZone()
+	}
+	return dec, nil
+}
+
+func (c *codec) marshal(v any) ([]byte, error) {
+	return c.appendMarshal(nil, v)
+}
+
+// appendMarshal encodes v and appends the document to dst
+func (c *codec) appendMarshal(dst []byte, v any) ([]byte, error) {
+	e, err := c.encoder()
+	if err != nil {
+		return dst, err
+	}
+	e.out.dst = dst
+	err = e.enc.Encode(v)
+	dst, e.out.dst = e.out.dst, nil
+	if err != nil {
+		// A failed encode can leave the writer mid-document
+		return dst, err
+	}
+	c.encoders.Put(e)
+	return dst, nil
+}
+
+// unmarshal decodes data into val. Decoded binary values may refer to data,
+// so data must not be reused afterwards.
+func (c *codec) unmarshal(data []byte, val any) error {
+	dec, err := c.decoder()
+	if err != nil {
+		return err
+	}
+	if err := dec.Reset(bsonrw.NewBSONDocumentReader(data)); err != nil {
+		return err
+	}
+	err = dec.Decode(val)
+	dec.Reset(nil)
+	c.decoders.Put(dec)
+	return err
+}
+
+// decode decodes doc into val through BSON, as decodeDocument does, using
+// the registry and flags of the options
+func (c *codec) decode(doc any, val any) error {
+	_, err := c.decodeAppend(nil, doc, val)
+	return err
+}
+
+// decodeAppend decodes doc into val like decode, encoding doc at the end of
+// buf and returning buf grown by it. Decoding many documents into one buf
+// saves allocating a buffer for each; buf is never overwritten, so values
+// decoded from it stay valid.
+func (c *codec) decodeAppend(buf []byte, doc any, val any) ([]byte, error) {
+	if doc == nil {
+		return buf, errNoCurrentDocument
+	}
+	start := len(buf)
+	buf, err := c.appendMarshal(buf, doc)
+	if err != nil {
+		return buf[:start], err
+	}
+	return buf, c.unmarshal(buf[start:len(buf):len(buf)], val)
+}
+
+// UUID is a universally unique identifier stored as BSON binary subtype 4
+type UUID [16]byte
+
+// ParseUUID parses the canonical 8-4-4-4-12 hex form of a UUID
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(strings.ReplaceAll(s, "-", ""))); err != nil {
+		return u, fmt.Errorf("invalid UUID %q: %w", s, err)
+	}
+	return u, nil
+}
+
+// String returns the canonical 8-4-4-4-12 hex form
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// MarshalBSONValue encodes the UUID as binary subtype 4
+func (u UUID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: u[:]})
+}
+
+// UnmarshalBSONValue decodes binary subtype 4, or the legacy subtype 3
+func (u *UUID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t != bson.TypeBinary {
+		return fmt.Errorf("cannot decode %s into a UUID", t)
+	}
+	subtype, bin, _, ok := bsoncore.ReadBinary(data)
+	if !ok || (subtype != bson.TypeBinaryUUID && subtype != bson.TypeBinaryUUIDOld) || len(bin) != len(u) {
+		return fmt.Errorf("cannot decode binary subtype %d of %d bytes into a UUID", subtype, len(bin))
+	}
+	copy(u[:], bin)
+	return nil
+}
+
+// uuidInterfaceDecoder decodes binary subtype 4 values held in any as UUID
+// and leaves everything else to the default decoder. The default is a field
+// rather than embedded so the registry cannot bypass DecodeValue.
+type uuidInterfaceDecoder struct {
+	fallback *bsoncodec.EmptyInterfaceCodec
+}
+
+func (d uuidInterfaceDecoder) DecodeValue(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if vr.Type() != bson.TypeBinary || val.Type() != reflect.TypeOf((*any)(nil)).Elem() {
+		return d.fallback.DecodeValue(dc, vr, val)
+	}
+	data, subtype, err := vr.ReadBinary()
+	if err != nil {
+		return err
+	}
+	if subtype == bson.TypeBinaryUUID && len(data) == len(UUID{}) {
+		val.Set(reflect.ValueOf(UUID(data)))
+		return nil
+	}
+	val.Set(reflect.ValueOf(primitive.Binary{Subtype: subtype, Data: data}))
+	return nil
+}
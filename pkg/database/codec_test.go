@@ -0,0 +1,357 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type payment struct {
+	ID     UUID                 `bson:"_id"`
+	Amount primitive.Decimal128 `bson:"amount"`
+	PaidAt time.Time            `bson:"paidAt"`
+}
+
+func TestFindAsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	id, err := ParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	if err != nil {
+		t.Fatalf("failed to parse UUID: %v", err)
+	}
+	amount, _ := primitive.ParseDecimal128("1999.95")
+	want := payment{ID: id, Amount: amount, PaidAt: time.Date(2024, 3, 1, 10, 30, 0, 125e6, time.UTC)}
+
+	fake := NewFakeDatabase()
+	if _, err := fake.InsertOne(ctx, "shop", "payments", want); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	stored := fake.Documents("shop", "payments")[0]["_id"].(primitive.Binary)
+	if stored.Subtype != bson.TypeBinaryUUID {
+		t.Errorf("expected the UUID stored as binary subtype 4, got %d", stored.Subtype)
+	}
+
+	db, err := New(NewMongoOptions().SetUri("mongodb://localhost").SetTimeout(5000).
+		SetBSONOptions(BSONOptions{UUIDs: true}).Build(), fake)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+
+	got, err := FindAs[payment](ctx, db, "shop", "payments", bson.M{"_id": id})
+	if err != nil || len(got) != 1 || got[0] != want {
+		t.Errorf("expected %+v, got %+v, %v", want, got, err)
+	}
+	one, err := FindOneAs[payment](ctx, db, "shop", "payments", bson.M{"amount": amount})
+	if err != nil || one != want {
+		t.Errorf("expected %+v, got %+v, %v", want, one, err)
+	}
+}
+
+func TestFindInto(t *testing.T) {
+	ctx := context.Background()
+	type event struct {
+		Camera  string `bson:"camera"`
+		Snippet []byte `bson:"snippet,omitempty"`
+		Score   int    `bson:"score,omitempty"`
+	}
+	fake := NewFakeDatabase()
+	for i := range 3 {
+		if err := fake.Seed("vault", "events", bson.M{"camera": fmt.Sprintf("camera-%d", i), "snippet": []byte{byte(i), byte(i)}, "order": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &Database{Client: fake}
+	sorted := moptions.Find().SetSort(bson.M{"order": 1})
+
+	t.Run("ReusesSlice", func(t *testing.T) {
+		results := make([]event, 5, 8)
+		results[0].Score = 42
+		backing := &results[0]
+		if err := FindInto(ctx, db, "vault", "events", bson.M{}, &results, sorted); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 3 || &results[0] != backing {
+			t.Fatalf("expected 3 results in the caller's array, got %d", len(results))
+		}
+		for i, e := range results {
+			if e.Camera != fmt.Sprintf("camera-%d", i) || string(e.Snippet) != string([]byte{byte(i), byte(i)}) || e.Score != 0 {
+				t.Errorf("unexpected result %d: %+v", i, e)
+			}
+		}
+	})
+
+	t.Run("BSONOptions", func(t *testing.T) {
+		id, _ := ParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+		if _, err := fake.InsertOne(ctx, "shop", "payments", payment{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+		var got []map[string]any
+		withUUIDs := &Database{Client: fake, Options: NewMongoOptions().SetBSONOptions(BSONOptions{UUIDs: true}).Build()}
+		if err := FindInto(ctx, withUUIDs, "shop", "payments", bson.M{}, &got); err != nil || len(got) != 1 || got[0]["_id"] != id {
+			t.Errorf("expected the UUID option to apply, got %v, %v", got, err)
+		}
+		withUUIDs.Options.BSON = nil
+		if err := FindInto(ctx, withUUIDs, "shop", "payments", bson.M{}, &got); err != nil || got[0]["_id"] == id {
+			t.Errorf("expected changed options to apply, got %v, %v", got, err)
+		}
+	})
+
+	t.Run("CursorError", func(t *testing.T) {
+		mock := NewMockDatabase().QueueFindCursor([]any{bson.M{"camera": "front"}, bson.M{"camera": "back"}}, 1, errors.New("connection reset"))
+		var results []event
+		if err := FindInto(ctx, &Database{Client: mock}, "vault", "events", bson.M{}, &results); err == nil || err.Error() != "connection reset" {
+			t.Errorf("expected the cursor error, got %v", err)
+		}
+		if len(results) != 1 || results[0].Camera != "front" {
+			t.Errorf("expected the documents before the error, got %+v", results)
+		}
+	})
+
+	t.Run("DecodeError", func(t *testing.T) {
+		mock := NewMockDatabase().QueueFindCursor([]any{bson.M{"camera": "front"}, bson.M{"camera": 7}}, -1, nil)
+		var results []event
+		err := FindInto(ctx, &Database{Client: mock}, "vault", "events", bson.M{}, &results)
+		if err == nil || !strings.Contains(err.Error(), "decode document 1") || len(results) != 1 {
+			t.Errorf("expected a decode error after one document, got %v, %+v", err, results)
+		}
+	})
+}
+
+func TestBSONOptions(t *testing.T) {
+	id, _ := ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	type cents int64
+	centsCodec := Codec{
+		Type: reflect.TypeOf(cents(0)),
+		Encoder: bsoncodec.ValueEncoderFunc(func(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, v reflect.Value) error {
+			return vw.WriteString(fmt.Sprintf("%d.%02d", v.Int()/100, v.Int()%100))
+		}),
+		Decoder: bsoncodec.ValueDecoderFunc(func(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, v reflect.Value) error {
+			s, err := vr.ReadString()
+			if err != nil {
+				return err
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			v.SetInt(int64(f*100 + 0.5))
+			return err
+		}),
+	}
+
+	tests := []struct {
+		name    string
+		options *BSONOptions
+		doc     any
+		check   func(t *testing.T, decoded any)
+	}{
+		{
+			name:    "UUIDsDecodeIntoUUID",
+			options: &BSONOptions{UUIDs: true, DefaultDocumentM: true},
+			doc:     bson.M{"id": id, "nested": bson.M{"id": id}, "raw": primitive.Binary{Data: []byte{1}}},
+			check: func(t *testing.T, decoded any) {
+				doc := decoded.(bson.M)
+				if doc["id"] != id || doc["nested"].(bson.M)["id"] != id {
+					t.Errorf("expected UUIDs, got %v", doc)
+				}
+				if _, ok := doc["raw"].(primitive.Binary); !ok {
+					t.Errorf("expected other binaries unchanged, got %T", doc["raw"])
+				}
+			},
+		},
+		{
+			name: "UUIDsOffDecodeIntoBinary",
+			doc:  bson.M{"id": id},
+			check: func(t *testing.T, decoded any) {
+				if _, ok := normalizeDocument(decoded).(map[string]any)["id"].(primitive.Binary); !ok {
+					t.Errorf("expected primitive.Binary, got %v", decoded)
+				}
+			},
+		},
+		{
+			name:    "DefaultDocumentM",
+			options: &BSONOptions{DefaultDocumentM: true},
+			doc:     bson.M{"nested": bson.M{"n": 1}},
+			check: func(t *testing.T, decoded any) {
+				if _, ok := decoded.(bson.M)["nested"].(bson.M); !ok {
+					t.Errorf("expected bson.M documents, got %#v", decoded)
+				}
+			},
+		},
+		{
+			name: "DefaultDocumentD",
+			doc:  bson.M{"nested": bson.M{"n": 1}},
+			check: func(t *testing.T, decoded any) {
+				if _, ok := decoded.(bson.D); !ok {
+					t.Errorf("expected bson.D documents, got %#v", decoded)
+				}
+			},
+		},
+		{
+			name:    "NilSliceAsEmpty",
+			options: &BSONOptions{NilSliceAsEmpty: true, NilMapAsEmpty: true},
+			doc: struct {
+				Tags []string         `bson:"tags"`
+				Meta map[string]int32 `bson:"meta"`
+			}{},
+			check: func(t *testing.T, decoded any) {
+				doc := normalizeDocument(decoded).(map[string]any)
+				if tags, ok := doc["tags"].([]any); !ok || len(tags) != 0 {
+					t.Errorf("expected an empty array, got %#v", doc["tags"])
+				}
+				if meta, ok := doc["meta"].(map[string]any); !ok || len(meta) != 0 {
+					t.Errorf("expected an empty document, got %#v", doc["meta"])
+				}
+			},
+		},
+		{
+			name:    "UseLocalTimeZone",
+			options: &BSONOptions{UseLocalTimeZone: true},
+			doc:     bson.M{"at": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+			check: func(t *testing.T, decoded any) {
+				var out struct {
+					At time.Time `bson:"at"`
+				}
+				c := newCodec(&BSONOptions{UseLocalTimeZone: true})
+				if err := c.decode(decoded, &out); err != nil || out.At.Location() != time.Local {
+					t.Errorf("expected a local time, got %v, %v", out.At, err)
+				}
+			},
+		},
+		{
+			name:    "CustomCodec",
+			options: &BSONOptions{Codecs: []Codec{centsCodec}},
+			doc: struct {
+				Price cents `bson:"price"`
+			}{1234},
+			check: func(t *testing.T, decoded any) {
+				if price := normalizeDocument(decoded).(map[string]any)["price"]; price != "12.34" {
+					t.Errorf("expected the custom encoding, got %#v", price)
+				}
+				var out struct {
+					Price cents `bson:"price"`
+				}
+				c := newCodec(&BSONOptions{Codecs: []Codec{centsCodec}})
+				if err := c.decode(decoded, &out); err != nil || out.Price != 1234 {
+					t.Errorf("expected the custom decoding, got %v, %v", out.Price, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decoded any
+			if err := newCodec(tt.options).decode(tt.doc, &decoded); err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			tt.check(t, decoded)
+		})
+	}
+}
+
+func TestMapEncoder(t *testing.T) {
+	doc := map[string]any{
+		"camera": "front",
+		"none":   nil,
+		"region": bson.M{"x": int32(1), "tags": []any{"a", map[string]any{"b": 2.5}}},
+		"empty":  map[string]any(nil),
+		"id":     primitive.NewObjectID(),
+	}
+	tests := []struct {
+		name    string
+		options *BSONOptions
+	}{
+		{"Default", nil},
+		{"NilMapAsEmpty", &BSONOptions{NilMapAsEmpty: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCodec(tt.options).marshal(doc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want, err := newCodec(tt.options).driverMarshal(doc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var gotDoc, wantDoc bson.M
+			if err := bson.Unmarshal(got, &gotDoc); err != nil {
+				t.Fatal(err)
+			}
+			if err := bson.Unmarshal(want, &wantDoc); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotDoc, wantDoc) {
+				t.Errorf("expected the driver's encoding\n%v\ngot\n%v", wantDoc, gotDoc)
+			}
+		})
+	}
+}
+
+// driverMarshal encodes v with the driver's own map codec
+func (c *codec) driverMarshal(v any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	vw, err := bsonrw.NewBSONValueWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := bson.NewEncoder(vw)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.SetRegistry(c.options.Registry()); err != nil {
+		return nil, err
+	}
+	if c.options != nil && c.options.NilMapAsEmpty {
+		enc.NilMapAsEmpty()
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func TestUUID(t *testing.T) {
+	const s = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	id, err := ParseUUID(s)
+	if err != nil || id.String() != s {
+		t.Errorf("expected %s, got %s, %v", s, id, err)
+	}
+	for _, invalid := range []string{"", "6ba7b8109dad11d180b400c04fd430c8", "6ba7b810-9dad-11d1-80b4-00c04fd430zz"} {
+		if _, err := ParseUUID(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+
+	var out struct {
+		ID UUID `bson:"id"`
+	}
+	if err := decodeDocument(bson.M{"id": "not binary"}, &out); err == nil {
+		t.Error("expected an error decoding a string into a UUID")
+	}
+	if err := decodeDocument(bson.M{"id": primitive.Binary{Data: id[:]}}, &out); err == nil {
+		t.Error("expected an error decoding binary subtype 0 into a UUID")
+	}
+}
+
+func TestSetBSONOptions(t *testing.T) {
+	opts := NewMongoOptions().SetBSONOptions(BSONOptions{NilSliceAsEmpty: true}).Build()
+	client := opts.BSON.clientOptions(moptions.Client())
+	if client.Registry == nil || client.BSONOptions == nil || !client.BSONOptions.NilSliceAsEmpty {
+		t.Errorf("expected the registry and BSON options on the client, got %+v", client)
+	}
+
+	unset := NewMongoOptions().Build()
+	if client := unset.BSON.clientOptions(moptions.Client()); client.Registry != nil || client.BSONOptions != nil {
+		t.Error("expected the driver defaults without BSON options")
+	}
+}
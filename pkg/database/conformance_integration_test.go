@@ -0,0 +1,29 @@
+//go:build integration
+
+package database
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMongoConformance runs the fake's conformance scenario against a real
+// MongoDB: go test -tags integration with MONGODB_URI set
+func TestMongoConformance(t *testing.T) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+
+	opts := NewMongoOptions().
+		SetUri(mongodbUri).
+		SetTimeout(5000).
+		Build()
+
+	db, err := New(opts)
+	if err != nil {
+		t.Fatalf("failed to create database instance: %v", err)
+	}
+
+	runConformance(t, db.Client, "database_conformance")
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// runConformance runs one scenario against any DatabaseInterface so the fake
+// can be checked against a real MongoDB with the same expectations
+func runConformance(t *testing.T, client DatabaseInterface, db string) {
+	ctx := context.Background()
+	const collection = "conformance"
+
+	if _, err := client.DeleteMany(ctx, db, collection, bson.M{}); err != nil {
+		t.Fatalf("failed to clear collection: %v", err)
+	}
+	t.Cleanup(func() { client.DeleteMany(ctx, db, collection, bson.M{}) })
+
+	_, err := client.InsertMany(ctx, db, collection, []any{
+		bson.M{"_id": 1, "name": "alice", "age": 31, "tags": bson.A{"admin"}},
+		bson.M{"_id": 2, "name": "bob", "age": 25},
+		bson.M{"_id": 3, "name": "carol", "age": 31, "tags": bson.A{"ops", "admin"}},
+		bson.M{"_id": 4, "name": "dave"},
+		bson.M{"_id": 5, "name": "erin", "age": "unknown"},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	find := func(t *testing.T, filter any, opts *moptions.FindOptions) []any {
+		t.Helper()
+		result, err := client.Find(ctx, db, collection, filter, opts)
+		if err != nil {
+			t.Fatalf("find failed: %v", err)
+		}
+		docs, _ := result.([]any)
+		out := make([]any, len(docs))
+		for i, doc := range docs {
+			out[i] = normalizeDocument(doc)
+		}
+		return out
+	}
+
+	t.Run("SortAcrossTypes", func(t *testing.T) {
+		got := find(t, bson.M{}, moptions.Find().SetSort(bson.D{{Key: "age", Value: 1}, {Key: "name", Value: -1}}).
+			SetProjection(bson.M{"_id": 1}))
+		want := []any{
+			map[string]any{"_id": 4},
+			map[string]any{"_id": 2},
+			map[string]any{"_id": 3},
+			map[string]any{"_id": 1},
+			map[string]any{"_id": 5},
+		}
+		if !valuesEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		got := find(t, bson.M{"age": bson.M{"$exists": true}}, moptions.Find().SetSort(bson.M{"name": 1}).SetSkip(1).SetLimit(2).
+			SetProjection(bson.M{"name": 1, "_id": 0}))
+		want := []any{map[string]any{"name": "bob"}, map[string]any{"name": "carol"}}
+		if !valuesEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("ExclusionProjection", func(t *testing.T) {
+		got := find(t, bson.M{"tags": "admin"}, moptions.Find().SetSort(bson.M{"_id": -1}).SetProjection(bson.M{"tags": 0, "age": 0}))
+		want := []any{map[string]any{"_id": 3, "name": "carol"}, map[string]any{"_id": 1, "name": "alice"}}
+		if !valuesEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	aggregate := func(t *testing.T, pipeline any) []any {
+		t.Helper()
+		result, err := client.Aggregate(ctx, db, collection, pipeline)
+		if err != nil {
+			t.Fatalf("aggregate failed: %v", err)
+		}
+		docs, _ := result.([]any)
+		out := make([]any, len(docs))
+		for i, doc := range docs {
+			out[i] = normalizeDocument(doc)
+		}
+		return out
+	}
+
+	t.Run("AggregateGroupByAge", func(t *testing.T) {
+		got := aggregate(t, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"age": bson.M{"$gte": 0}}}},
+			{{Key: "$group", Value: bson.M{"_id": "$age", "n": bson.M{"$sum": 1}, "first": bson.M{"$min": "$name"}}}},
+			{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		})
+		want := []any{
+			map[string]any{"_id": 25, "n": 1, "first": "bob"},
+			map[string]any{"_id": 31, "n": 2, "first": "alice"},
+		}
+		if !valuesEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("AggregateUnwindCount", func(t *testing.T) {
+		got := aggregate(t, mongo.Pipeline{
+			{{Key: "$unwind", Value: "$tags"}},
+			{{Key: "$match", Value: bson.M{"tags": "admin"}}},
+			{{Key: "$project", Value: bson.M{"_id": 0, "who": "$name"}}},
+			{{Key: "$sort", Value: bson.M{"who": -1}}},
+		})
+		want := []any{map[string]any{"who": "carol"}, map[string]any{"who": "alice"}}
+		if !valuesEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		if got := aggregate(t, bson.A{bson.M{"$unwind": "$tags"}, bson.M{"$count": "tags"}}); !valuesEqual(got, []any{map[string]any{"tags": 3}}) {
+			t.Errorf("expected 3 tags, got %v", got)
+		}
+	})
+
+	t.Run("UpdateThenRead", func(t *testing.T) {
+		res, err := client.UpdateMany(ctx, db, collection, bson.M{"age": 31}, bson.M{"$inc": bson.M{"age": 1}, "$push": bson.M{"tags": "senior"}})
+		if err != nil || res.MatchedCount != 2 || res.ModifiedCount != 2 {
+			t.Fatalf("expected 2 updated, got %+v, %v", res, err)
+		}
+		got := find(t, bson.M{"tags": "senior", "age": 32}, moptions.Find().SetSort(bson.M{"_id": 1}).SetProjection(bson.M{"_id": 1}))
+		if !valuesEqual(got, []any{map[string]any{"_id": 1}, map[string]any{"_id": 3}}) {
+			t.Errorf("expected alice and carol, got %v", got)
+		}
+	})
+}
+
+func TestFakeConformance(t *testing.T) {
+	runConformance(t, NewFakeDatabase(), "conformance")
+}
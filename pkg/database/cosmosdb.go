@@ -0,0 +1,28 @@
+package database
+
+// NewCosmosDBOptions creates a builder with the settings the Azure Cosmos DB
+// for MongoDB API needs: TLS, retryable writes off, which Cosmos DB does not
+// support, SCRAM-SHA-1, the only mechanism the RU-based API accepts, against
+// the admin database and the globaldb replica set every account reports.
+// host is usually <account>.mongo.cosmos.azure.com:10255, username the
+// account name and password its primary key. Every setting can still be
+// overridden.
+//
+// Cosmos DB throttles requests above the provisioned throughput; such errors
+// are transient, match ErrThrottled, and RetryAfter returns the delay the
+// server asks for.
+//
+//	opts := database.NewCosmosDBOptions("acme.mongo.cosmos.azure.com:10255", "acme", key).
+//		SetTimeoutDuration(10 * time.Second).
+//		Build()
+func NewCosmosDBOptions(host string, username string, password string) *MongoOptionsBuilder {
+	return NewMongoOptions().
+		SetHost(host).
+		SetUsername(username).
+		SetPassword(password).
+		SetAuthSource("admin").
+		SetAuthMechanism("SCRAM-SHA-1").
+		SetReplicaSet("globaldb").
+		SetRetryWrites(false).
+		SetTLS(true)
+}
@@ -0,0 +1,44 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestCosmosDBPreset connects with the Cosmos DB preset and round trips a
+// document: go test -tags integration with COSMOSDB_HOST, COSMOSDB_USERNAME
+// and COSMOSDB_PASSWORD set
+func TestCosmosDBPreset(t *testing.T) {
+	host := os.Getenv("COSMOSDB_HOST")
+	if host == "" {
+		t.Skip("COSMOSDB_HOST not set, skipping integration test")
+	}
+
+	opts := NewCosmosDBOptions(host, os.Getenv("COSMOSDB_USERNAME"), os.Getenv("COSMOSDB_PASSWORD")).
+		SetTimeout(10000).
+		Build()
+	db, err := New(opts)
+	if err != nil {
+		t.Fatalf("failed to create database instance: %v", err)
+	}
+	ctx := context.Background()
+	defer db.Client.Close(ctx)
+
+	if err := db.Client.Ping(ctx); err != nil {
+		t.Fatalf("failed to ping: %v", err)
+	}
+	id, err := db.Client.InsertOne(ctx, "database_integration", "cosmos", bson.M{"name": "front"})
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	defer db.Client.DeleteOne(ctx, "database_integration", "cosmos", bson.M{"_id": id})
+
+	if _, err := db.Client.FindOne(ctx, "database_integration", "cosmos", bson.M{"_id": id}); err != nil {
+		t.Errorf("failed to read the document back: %v", err)
+	}
+}
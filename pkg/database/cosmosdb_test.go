@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestNewCosmosDBOptions(t *testing.T) {
+	opts := NewCosmosDBOptions("acme.mongo.cosmos.azure.com:10255", "acme", "key==").SetTimeout(1000).Build()
+
+	if opts.RetryWrites {
+		t.Error("expected retry writes to be off")
+	}
+	if !opts.TLS || opts.CAFile != "" {
+		t.Errorf("expected TLS with the system certificate authorities, got %v, %q", opts.TLS, opts.CAFile)
+	}
+	if opts.AuthSource != "admin" || opts.AuthMechanism != "SCRAM-SHA-1" || opts.ReplicaSet != "globaldb" {
+		t.Errorf("expected admin, SCRAM-SHA-1 and globaldb, got %q, %q, %q", opts.AuthSource, opts.AuthMechanism, opts.ReplicaSet)
+	}
+
+	clientOpts, err := buildClientOptions(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if clientOpts.TLSConfig == nil || clientOpts.RetryWrites == nil || *clientOpts.RetryWrites {
+		t.Errorf("expected TLS and no retry writes, got %v, %v", clientOpts.TLSConfig, clientOpts.RetryWrites)
+	}
+	if clientOpts.ReplicaSet == nil || *clientOpts.ReplicaSet != "globaldb" || clientOpts.Auth.Password != "key==" {
+		t.Errorf("expected replica set globaldb and the key, got %v, %+v", clientOpts.ReplicaSet, clientOpts.Auth)
+	}
+}
+
+func TestThrottling(t *testing.T) {
+	// The shape of a Cosmos DB throttled write
+	write := mongo.WriteException{WriteErrors: []mongo.WriteError{{
+		Code:    16500,
+		Message: "Error=16500, RetryAfterMs=72, Details='Response status code does not indicate success: TooManyRequests (429)'",
+	}}}
+
+	tests := []struct {
+		name       string
+		err        error
+		throttled  bool
+		retryAfter time.Duration
+		hasDelay   bool
+	}{
+		{name: "Command", err: ThrottledError(250 * time.Millisecond), throttled: true, retryAfter: 250 * time.Millisecond, hasDelay: true},
+		{name: "Write", err: mapError(write), throttled: true, retryAfter: 72 * time.Millisecond, hasDelay: true},
+		{name: "Unmapped", err: write, throttled: true, retryAfter: 72 * time.Millisecond, hasDelay: true},
+		{name: "WithoutDelay", err: mapError(mongo.CommandError{Code: 16500, Message: "Request rate is large"}), throttled: true},
+		{name: "Other", err: mapError(mongo.CommandError{Code: 13})},
+		{name: "Nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if IsThrottled(tt.err) != tt.throttled {
+				t.Errorf("IsThrottled(%v) = %v", tt.err, !tt.throttled)
+			}
+			if IsTransient(tt.err) != tt.throttled {
+				t.Errorf("IsTransient(%v) = %v", tt.err, !tt.throttled)
+			}
+			delay, ok := RetryAfter(tt.err)
+			if ok != tt.hasDelay || delay != tt.retryAfter {
+				t.Errorf("RetryAfter(%v) = %v, %v, expected %v, %v", tt.err, delay, ok, tt.retryAfter, tt.hasDelay)
+			}
+		})
+	}
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueThrottled(time.Second)
+		_, err := mock.InsertOne(context.Background(), "vault", "cameras", bson.M{"name": "front"})
+		if !errors.Is(err, ErrThrottled) {
+			t.Fatalf("expected ErrThrottled, got %v", err)
+		}
+		if delay, ok := RetryAfter(err); !ok || delay != time.Second {
+			t.Errorf("expected a one second delay, got %v, %v", delay, ok)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCountLimit is how many documents the limited count of a
+// CountBounded strategy counts up to when no Limit is given
+const DefaultCountLimit = 10000
+
+// EstimatedCounter is implemented by clients that count the documents of a
+// collection from its metadata rather than by scanning it
+type EstimatedCounter interface {
+	EstimatedCount(ctx context.Context, db string, collection string) (int64, error)
+}
+
+// CountMode selects how CountWithStrategy counts
+type CountMode int
+
+const (
+	// CountModeExact counts the matching documents like Count
+	CountModeExact CountMode = iota
+	// CountModeEstimatedIfUnfiltered reads the count of an empty filter from
+	// the collection's metadata
+	CountModeEstimatedIfUnfiltered
+	// CountModeBounded counts within a server-side time limit and falls back
+	// to an estimate or a lower bound when it runs out
+	CountModeBounded
+)
+
+// CountStrategy configures CountWithStrategy. Use CountExact,
+// CountEstimatedIfUnfiltered or CountBounded.
+type CountStrategy struct {
+	Mode CountMode
+	// MaxTime bounds the exact count of CountModeBounded on the server
+	MaxTime time.Duration
+	// FallbackToEstimate makes CountModeBounded fall back to the metadata
+	// count of the collection rather than a limited count
+	FallbackToEstimate bool
+	// Limit is how many documents the limited count of CountModeBounded
+	// counts up to, DefaultCountLimit when zero
+	Limit int64
+}
+
+// CountExact counts every matching document, however long that takes
+var CountExact = CountStrategy{Mode: CountModeExact}
+
+// CountEstimatedIfUnfiltered returns the metadata count of the collection,
+// which is instant but may be off after an unclean shutdown or during
+// chunk migrations, when the filter is empty, and counts exactly otherwise
+var CountEstimatedIfUnfiltered = CountStrategy{Mode: CountModeEstimatedIfUnfiltered}
+
+// CountBounded returns a strategy that counts exactly within maxTime on the
+// server. When the count runs out of time it falls back to the metadata
+// count of the collection with fallbackToEstimate, or else counts up to
+// Limit documents, returning Limit as a "more than" value when it gets there.
+// With a filter the metadata count is the total of the collection, an upper
+// bound of the exact count.
+//
+//	strategy := database.CountBounded(2*time.Second, false)
+//	strategy.Limit = 1000 // render "1,000+" past a thousand
+func CountBounded(maxTime time.Duration, fallbackToEstimate bool) CountStrategy {
+	return CountStrategy{Mode: CountModeBounded, MaxTime: maxTime, FallbackToEstimate: fallbackToEstimate}
+}
+
+// CountWithStrategy counts the documents of db.collection matching filter
+// with strategy, and reports whether the count is exact, so a UI can render
+// an estimate as "~12,400" or a limited count as "10,000+". Estimates use the
+// client's EstimatedCount, as on MongoClient and FakeDatabase; a client
+// without it, such as the mock, counts exactly instead of estimating an
+// unfiltered count and falls back to the limited count.
+//
+//	n, exact, err := db.CountWithStrategy(ctx, "vault", "events", filter, database.CountBounded(2*time.Second, false))
+func (d *Database) CountWithStrategy(ctx context.Context, db string, collection string, filter any, strategy CountStrategy) (int64, bool, error) {
+	switch strategy.Mode {
+	case CountModeExact:
+		n, err := d.Client.Count(ctx, db, collection, filter)
+		return n, err == nil, err
+	case CountModeEstimatedIfUnfiltered:
+		if counter, ok := implementation[EstimatedCounter](d.Client); ok && emptyFilter(filter) {
+			n, err := counter.EstimatedCount(ctx, db, collection)
+			return n, false, err
+		}
+		n, err := d.Client.Count(ctx, db, collection, filter)
+		return n, err == nil, err
+	case CountModeBounded:
+		return d.countBounded(ctx, db, collection, filter, strategy)
+	}
+	return 0, false, fmt.Errorf("count %s.%s: unknown count mode %d", db, collection, strategy.Mode)
+}
+
+func (d *Database) countBounded(ctx context.Context, db string, collection string, filter any, strategy CountStrategy) (int64, bool, error) {
+	if strategy.MaxTime <= 0 {
+		return 0, false, fmt.Errorf("count %s.%s: bounded count needs a positive MaxTime, got %v", db, collection, strategy.MaxTime)
+	}
+	limit := strategy.Limit
+	if limit == 0 {
+		limit = DefaultCountLimit
+	}
+	if limit < 0 {
+		return 0, false, fmt.Errorf("count %s.%s: limit must not be negative, got %d", db, collection, limit)
+	}
+
+	n, err := d.Client.Count(ctx, db, collection, filter, moptions.Count().SetMaxTime(strategy.MaxTime))
+	if err == nil {
+		return n, true, nil
+	}
+	if !IsTimeout(err) || ctx.Err() != nil {
+		return 0, false, err
+	}
+
+	if strategy.FallbackToEstimate {
+		if counter, ok := implementation[EstimatedCounter](d.Client); ok {
+			n, err := counter.EstimatedCount(ctx, db, collection)
+			if err != nil {
+				return 0, false, fmt.Errorf("count %s.%s: estimate after timeout: %w", db, collection, err)
+			}
+			return n, false, nil
+		}
+	}
+	n, err = d.Client.Count(ctx, db, collection, filter, moptions.Count().SetLimit(limit).SetMaxTime(strategy.MaxTime))
+	if err != nil {
+		return 0, false, fmt.Errorf("count %s.%s: limited count after timeout: %w", db, collection, err)
+	}
+	return n, n < limit, nil
+}
+
+// emptyFilter reports whether filter matches every document
+func emptyFilter(filter any) bool {
+	if filter == nil {
+		return true
+	}
+	doc, ok := normalizeDocument(filter).(map[string]any)
+	return ok && len(doc) == 0
+}
+
+// EstimatedCount implements EstimatedCounter with estimatedDocumentCount
+func (m *MongoClient) EstimatedCount(ctx context.Context, db string, collection string) (int64, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := coll.EstimatedDocumentCount(ctx, withComment(nil, call.Comment, moptions.EstimatedDocumentCount().SetComment)...)
+	return n, mapError(err)
+}
+
+// EstimatedCount implements EstimatedCounter. The fake's metadata is always
+// accurate, so it returns the number of unexpired documents, or of
+// documents a view outputs.
+func (f *FakeDatabase) EstimatedCount(ctx context.Context, db string, collection string) (int64, error) {
+	if err := f.ready(ctx); err != nil {
+		return 0, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	docs, err := f.source(fakeNamespace{db, collection})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
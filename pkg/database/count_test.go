@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// estimatingMock is a mock whose collections all estimate to n documents
+type estimatingMock struct {
+	*MockDatabase
+	n int64
+}
+
+func (m estimatingMock) EstimatedCount(ctx context.Context, db string, collection string) (int64, error) {
+	return m.n, nil
+}
+
+func TestCountWithStrategy(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	fake.Seed("vault", "events",
+		bson.M{"_id": 1, "type": "motion", "tenant_id": "acme"},
+		bson.M{"_id": 2, "type": "motion", "tenant_id": "globex"},
+		bson.M{"_id": 3, "type": "sound", "tenant_id": "acme"},
+	)
+	db := &Database{Client: fake}
+
+	tests := []struct {
+		name      string
+		filter    any
+		strategy  CountStrategy
+		want      int64
+		wantExact bool
+	}{
+		{"Exact", bson.M{"type": "motion"}, CountExact, 2, true},
+		{"EstimatedUnfiltered", nil, CountEstimatedIfUnfiltered, 3, false},
+		{"EstimatedEmptyFilter", bson.D{}, CountEstimatedIfUnfiltered, 3, false},
+		{"EstimatedFiltered", bson.M{"type": "sound"}, CountEstimatedIfUnfiltered, 1, true},
+		{"BoundedInTime", bson.M{"type": "motion"}, CountBounded(time.Second, true), 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, exact, err := db.CountWithStrategy(ctx, "vault", "events", tt.filter, tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want || exact != tt.wantExact {
+				t.Errorf("expected %d (exact %v), got %d (exact %v)", tt.want, tt.wantExact, n, exact)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, _, err := db.CountWithStrategy(ctx, "vault", "events", nil, CountBounded(0, false)); err == nil {
+			t.Error("expected a bounded count without MaxTime to be rejected")
+		}
+		if _, _, err := db.CountWithStrategy(ctx, "vault", "events", nil, CountStrategy{Mode: 9}); err == nil {
+			t.Error("expected an unknown mode to be rejected")
+		}
+	})
+
+	t.Run("Tenant", func(t *testing.T) {
+		n, exact, err := db.ForTenant("acme", TenantConfig{}).CountWithStrategy(ctx, "vault", "events", nil, CountEstimatedIfUnfiltered)
+		if err != nil || n != 2 || exact {
+			t.Errorf("expected the estimate to count only the tenant's documents, got %d (exact %v), %v", n, exact, err)
+		}
+	})
+}
+
+func TestCountBoundedFallback(t *testing.T) {
+	ctx := context.Background()
+	filter := bson.M{"type": "motion"}
+
+	t.Run("LimitedCount", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueServerTimeout().QueueCount(500, nil)
+		strategy := CountBounded(2*time.Second, false)
+		strategy.Limit = 500
+		n, exact, err := (&Database{Client: mock}).CountWithStrategy(ctx, "vault", "events", filter, strategy)
+		if err != nil || n != 500 || exact {
+			t.Fatalf("expected the limit as a lower bound, got %d (exact %v), %v", n, exact, err)
+		}
+		if len(mock.CountCalls) != 2 {
+			t.Fatalf("expected the timed out count and a limited count, got %d calls", len(mock.CountCalls))
+		}
+		first := optionsOf[moptions.CountOptions](mock.CountCalls[0].Opts)
+		if len(first) != 1 || first[0].MaxTime == nil || *first[0].MaxTime != 2*time.Second {
+			t.Errorf("expected the first count to carry MaxTime, got %+v", first)
+		}
+		limited := optionsOf[moptions.CountOptions](mock.CountCalls[1].Opts)
+		if len(limited) != 1 || limited[0].Limit == nil || *limited[0].Limit != 500 {
+			t.Errorf("expected the second count to be limited, got %+v", limited)
+		}
+	})
+
+	t.Run("LimitNotReached", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueServerTimeout().QueueCount(42, nil)
+		n, exact, err := (&Database{Client: mock}).CountWithStrategy(ctx, "vault", "events", filter, CountBounded(time.Second, false))
+		if err != nil || n != 42 || !exact {
+			t.Errorf("expected a limited count under the limit to be exact, got %d (exact %v), %v", n, exact, err)
+		}
+	})
+
+	t.Run("Estimate", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueServerTimeout()
+		n, exact, err := (&Database{Client: estimatingMock{mock, 12400}}).CountWithStrategy(ctx, "vault", "events", filter, CountBounded(time.Second, true))
+		if err != nil || n != 12400 || exact {
+			t.Errorf("expected the estimate, got %d (exact %v), %v", n, exact, err)
+		}
+		if len(mock.CountCalls) != 1 {
+			t.Errorf("expected no limited count, got %d calls", len(mock.CountCalls))
+		}
+	})
+
+	t.Run("EstimateUnsupported", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueServerTimeout().QueueCount(DefaultCountLimit, nil)
+		n, exact, err := (&Database{Client: mock}).CountWithStrategy(ctx, "vault", "events", filter, CountBounded(time.Second, true))
+		if err != nil || n != DefaultCountLimit || exact {
+			t.Errorf("expected the limited count without an estimate, got %d (exact %v), %v", n, exact, err)
+		}
+	})
+
+	t.Run("OtherError", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueUnauthorized()
+		_, _, err := (&Database{Client: mock}).CountWithStrategy(ctx, "vault", "events", filter, CountBounded(time.Second, true))
+		if !errors.Is(err, ErrUnauthorized) || len(mock.CountCalls) != 1 {
+			t.Errorf("expected errors other than timeouts to be returned, got %v after %d calls", err, len(mock.CountCalls))
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// CredentialProvider supplies the username and password of the client, such
+// as short-lived credentials leased from HashiCorp Vault. It is asked when the
+// client connects and again on every Reconnect. A zero expiresAt means the
+// credentials do not expire.
+type CredentialProvider interface {
+	Fetch(ctx context.Context) (username string, password string, expiresAt time.Time, err error)
+}
+
+// SetCredentialProvider fetches the username and password from p when the
+// client connects, instead of taking them from SetUsername and SetPassword.
+// The connections the driver opens later reuse them; call Reconnect to fetch
+// new ones.
+func (b *MongoOptionsBuilder) SetCredentialProvider(p CredentialProvider) *MongoOptionsBuilder {
+	b.options.CredentialProvider = p
+	return b
+}
+
+// NewStaticCredentialProvider returns a provider of fixed credentials that
+// never expire
+func NewStaticCredentialProvider(username string, password string) CredentialProvider {
+	return staticCredentials{username: username, password: password}
+}
+
+type staticCredentials struct {
+	username string
+	password string
+}
+
+func (c staticCredentials) Fetch(ctx context.Context) (string, string, time.Time, error) {
+	return c.username, c.password, time.Time{}, nil
+}
+
+// NewFileCredentialProvider returns a provider that reads the username and
+// the password from two files on every fetch, such as a mounted Kubernetes
+// secret or the output of a Vault Agent template, so a rotated secret is
+// picked up by the next Reconnect. Trailing whitespace is trimmed.
+func NewFileCredentialProvider(usernameFile string, passwordFile string) CredentialProvider {
+	return fileCredentials{usernameFile: usernameFile, passwordFile: passwordFile}
+}
+
+type fileCredentials struct {
+	usernameFile string
+	passwordFile string
+}
+
+func (c fileCredentials) Fetch(ctx context.Context) (string, string, time.Time, error) {
+	username, err := os.ReadFile(c.usernameFile)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	password, err := os.ReadFile(c.passwordFile)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return strings.TrimRight(string(username), " \t\r\n"), strings.TrimRight(string(password), " \t\r\n"), time.Time{}, nil
+}
+
+// resolveCredentials returns options with the username and password fetched
+// from its credential provider, or options itself when it has none
+func resolveCredentials(ctx context.Context, options *MongoOptions) (*MongoOptions, error) {
+	if options.CredentialProvider == nil {
+		return options, nil
+	}
+	username, password, expiresAt, err := options.CredentialProvider.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch credentials: %w", err)
+	}
+	if username == "" || password == "" {
+		return nil, errors.New("fetch credentials: the provider returned an empty username or password")
+	}
+	if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+		return nil, fmt.Errorf("fetch credentials: the credentials expired at %s", expiresAt.Format(time.RFC3339))
+	}
+	resolved := *options
+	resolved.Username = username
+	resolved.Password = password
+	return &resolved, nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Cursor iterates over query results one document at a time so large result
+// sets do not have to be held in memory. *mongo.Cursor satisfies it.
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(val any) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// documentCursor is a Cursor over documents held in memory, whose current
+// document FindInto decodes with the database's BSON options
+type documentCursor interface {
+	document() any
+}
+
+// errNoCurrentDocument is returned by Decode before Next or after the cursor ends
+var errNoCurrentDocument = errors.New("cursor: Decode called without a current document")
+
+// sliceCursor is a Cursor over documents already held in memory, reporting
+// final from Err once they are exhausted
+type sliceCursor struct {
+	mu      sync.Mutex
+	docs    []any
+	final   error
+	pos     int
+	current any
+	err     error
+	closed  bool
+}
+
+func newSliceCursor(docs []any, final error) *sliceCursor {
+	return &sliceCursor{docs: docs, final: final}
+}
+
+func (c *sliceCursor) Next(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = nil
+	if c.closed || c.err != nil {
+		return false
+	}
+	if c.pos >= len(c.docs) {
+		c.err = c.final
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		c.err = err
+		return false
+	}
+	c.current = c.docs[c.pos]
+	c.pos++
+	return true
+}
+
+func (c *sliceCursor) Decode(val any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return decodeDocument(c.current, val)
+}
+
+func (c *sliceCursor) document() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+}
+
+func (c *sliceCursor) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+func (c *sliceCursor) Close(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	return nil
+}
+
+// decodeDocument decodes doc into val through BSON, the way the driver
+// decodes a cursor's current document into maps and structs
+func decodeDocument(doc any, val any) error {
+	if doc == nil {
+		return errNoCurrentDocument
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, val)
+}
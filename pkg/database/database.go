@@ -2,40 +2,139 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-playground/validator/v10"
 )
 
 type DatabaseInterface interface {
 	Ping(context.Context) error
+	Close(context.Context) error
 	Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error)
 	FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error)
+	FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error)
+	Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error)
+	Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error)
+	Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error)
+	InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error)
+	InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error)
+	UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error)
+	UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error)
+	ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error)
+	DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error)
+	DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error)
+	FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error)
+	BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error)
 }
 
 // Database represents a database client instance
 type Database struct {
 	Options *MongoOptions
 	Client  DatabaseInterface
+
+	// connect creates a client from options for Reconnect and
+	// RotateCredentials, which swap it into live; both are nil when the
+	// client was passed to New
+	connect func(ctx context.Context, opts *MongoOptions) (DatabaseInterface, error)
+	live    *liveClient
+	// swapMu serializes the replacements of the client, and guards
+	// reconnectOptions, the options of the current client
+	swapMu           sync.Mutex
+	reconnectOptions *MongoOptions
+	// lastPing is the result of the last Ping
+	lastPing atomic.Pointer[PingResult]
+	// bsonCodec caches the codec of Options.BSON for the typed reads
+	bsonCodec atomic.Pointer[codec]
 }
 
+// New creates a Database from opts, connecting a MongoDB client unless a
+// client is passed. It returns either a usable Database or an error, never
+// both: when validation, connecting or the verification of
+// SetVerifyConnection fails, any client it created is closed. A client passed
+// in is left to the caller to close.
 func New(opts *MongoOptions, client ...DatabaseInterface) (*Database, error) {
+	return NewWithContext(context.Background(), opts, client...)
+}
+
+// NewWithContext is New bounded by ctx as well as the timeout of opts, so a
+// startup deadline or a cancellation, such as on SIGTERM during a slow boot,
+// ends connecting, the warmup and the verification ping.
+func NewWithContext(ctx context.Context, opts *MongoOptions, client ...DatabaseInterface) (*Database, error) {
+	if len(client) == 0 {
+		return newDatabase(ctx, opts, nil, connectMongo)
+	}
+	if client[0] == nil {
+		return nil, errors.New("database: the client passed to New is nil")
+	}
+	return newDatabase(ctx, opts, client[0], nil)
+}
+
+// newDatabase validates opts and creates a Database with client, or with a
+// client from connect that Reconnect and RotateCredentials can replace
+func newDatabase(ctx context.Context, opts *MongoOptions, client DatabaseInterface, connect func(ctx context.Context, opts *MongoOptions) (DatabaseInterface, error)) (*Database, error) {
+	if opts == nil {
+		return nil, errors.New("database: options are required")
+	}
+	if opts.buildErr != nil {
+		return nil, opts.buildErr
+	}
 	// Validate Database configuration
 	validate := validator.New()
 	err := validate.Struct(opts)
 	if err != nil {
 		return nil, err
 	}
+	if err := opts.validateTimeout(); err != nil {
+		return nil, err
+	}
+	if err := opts.validateSocketPath(); err != nil {
+		return nil, err
+	}
+	if err := opts.AutoEncryption.Validate(); err != nil {
+		return nil, err
+	}
 
 	// If no client provided, create default production client
-	var m DatabaseInterface
-	if len(client) == 0 {
-		m, err = NewMongoClient(opts)
-	} else {
-		m, err = client[0], nil
+	d := &Database{Options: opts}
+	if connect != nil {
+		client, err = connect(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.VerifyConnection {
+		if err := verifyConnection(ctx, opts, client); err != nil {
+			if connect != nil {
+				client.Close(context.WithoutCancel(ctx))
+			}
+			return nil, err
+		}
 	}
+	if connect != nil {
+		d.connect, d.reconnectOptions = connect, opts
+		d.live = newLiveClient(client)
+		client = d.live
+	}
+	if opts.ReadOnly {
+		client = Wrap(client, WithReadOnly())
+	}
+	d.Client = client
+
+	return d, nil
+}
 
-	return &Database{
-		Options: opts,
-		Client:  m,
-	}, err
+// verifyConnection pings client within the timeout of opts
+func verifyConnection(ctx context.Context, opts *MongoOptions, client DatabaseInterface) error {
+	if timeout := opts.connectTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("verify connection: %w", err)
+	}
+	return nil
 }
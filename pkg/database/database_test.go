@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFailures(t *testing.T) {
+	uri := func() *MongoOptionsBuilder {
+		return NewMongoOptions().SetUri("mongodb://localhost").SetTimeout(1000)
+	}
+	// closedAddress is a port nothing listens on
+	closedAddress := func(t *testing.T) string {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer ln.Close()
+		return ln.Addr().String()
+	}
+
+	tests := []struct {
+		name    string
+		new     func(t *testing.T) (*Database, error)
+		wantErr string
+	}{
+		{"NilOptions", func(t *testing.T) (*Database, error) {
+			return New(nil)
+		}, "options are required"},
+		{"NilClient", func(t *testing.T) (*Database, error) {
+			return New(uri().Build(), nil)
+		}, "client passed to New is nil"},
+		{"BuildError", func(t *testing.T) (*Database, error) {
+			return New(NewMongoOptionsForProfile("qa").SetUri("mongodb://localhost").Build())
+		}, "unknown profile"},
+		{"Validation", func(t *testing.T) (*Database, error) {
+			return New(NewMongoOptions().SetTimeout(1000).Build())
+		}, "required_without_all"},
+		{"Timeout", func(t *testing.T) (*Database, error) {
+			return New(NewMongoOptions().SetUri("mongodb://localhost").Build())
+		}, "timeout is required"},
+		{"SocketPath", func(t *testing.T) (*Database, error) {
+			return New(NewMongoOptions().SetSocketPath("mongodb.sock").SetUsername("app").SetPassword("secret").SetAuthSource("admin").SetTimeout(1000).Build())
+		}, "must be absolute"},
+		{"AutoEncryption", func(t *testing.T) (*Database, error) {
+			return New(uri().SetAutoEncryption(nil, "", nil, false).Build())
+		}, "key vault namespace is required"},
+		{"ClientOptions", func(t *testing.T) (*Database, error) {
+			return New(uri().SetReadConcern("eventual").Build())
+		}, "unknown read concern"},
+		{"Credentials", func(t *testing.T) (*Database, error) {
+			return New(uri().SetCredentialProvider(&rotatingProvider{err: errors.New("vault sealed")}).Build())
+		}, "vault sealed"},
+		{"Warmup", func(t *testing.T) (*Database, error) {
+			return New(NewMongoOptions().SetUri("mongodb://" + closedAddress(t) + "/?directConnection=true").SetTimeoutDuration(200 * time.Millisecond).SetConnectMode(Eager).Build())
+		}, "warmup"},
+		{"VerifyConnection", func(t *testing.T) (*Database, error) {
+			return New(NewMongoOptions().SetUri("mongodb://" + closedAddress(t) + "/?directConnection=true").SetTimeoutDuration(200 * time.Millisecond).SetVerifyConnection(true).Build())
+		}, "verify connection"},
+		{"VerifyInjectedClient", func(t *testing.T) (*Database, error) {
+			return New(uri().SetVerifyConnection(true).Build(), NewMockDatabase().ExpectPing(errors.New("connection refused")))
+		}, "verify connection: connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := tt.new(t)
+			if db != nil {
+				t.Errorf("expected no Database alongside the error, got %+v", db)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("InjectedClientLeftOpen", func(t *testing.T) {
+		mock := NewMockDatabase().ExpectPing(errors.New("connection refused"))
+		if _, err := New(uri().SetVerifyConnection(true).Build(), mock); err == nil {
+			t.Fatal("expected the verification to fail")
+		}
+		if mock.Closed() {
+			t.Error("expected a client passed to New to be left to the caller")
+		}
+	})
+
+	t.Run("VerifyConnectionSucceeds", func(t *testing.T) {
+		mock := NewMockDatabase()
+		db, err := New(uri().SetVerifyConnection(true).Build(), mock)
+		if err != nil || db == nil {
+			t.Fatalf("expected a Database, got %v, %v", db, err)
+		}
+		mock.AssertCalled(t, "Ping", 1)
+		if err := db.Client.Close(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestNewWithContext(t *testing.T) {
+	// 10.255.255.1 is unroutable, so connecting hangs until a deadline
+	unroutable := func() *MongoOptionsBuilder {
+		return NewMongoOptions().SetUri("mongodb://10.255.255.1:27017/?directConnection=true").SetTimeoutDuration(30 * time.Second)
+	}
+
+	tests := []struct {
+		name    string
+		options *MongoOptions
+	}{
+		{"VerifyConnection", unroutable().SetVerifyConnection(true).Build()},
+		{"Warmup", unroutable().SetConnectMode(Eager).Build()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			db, err := NewWithContext(ctx, tt.options)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("expected the caller's deadline to end construction, took %s", elapsed)
+			}
+			if db != nil || err == nil {
+				t.Fatalf("expected an error and no Database, got %v, %v", db, err)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected context.DeadlineExceeded, got %v", err)
+			}
+		})
+	}
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		if _, err := NewWithContext(ctx, unroutable().SetVerifyConnection(true).Build()); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("expected the cancellation to end construction, took %s", elapsed)
+		}
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		provider := credentialFunc(func(ctx context.Context) (string, string, time.Time, error) {
+			return "", "", time.Time{}, ctx.Err()
+		})
+		if _, err := NewWithContext(ctx, unroutable().SetCredentialProvider(provider).Build()); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the provider to get the caller's context, got %v", err)
+		}
+	})
+}
+
+type credentialFunc func(ctx context.Context) (string, string, time.Time, error)
+
+func (f credentialFunc) Fetch(ctx context.Context) (string, string, time.Time, error) {
+	return f(ctx)
+}
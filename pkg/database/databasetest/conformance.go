@@ -0,0 +1,430 @@
+// Package databasetest verifies that an implementation of
+// database.DatabaseInterface honours the interface's contract.
+package databasetest
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/uug-ai/database/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Database is the database every conformance check runs in
+const Database = "databasetest"
+
+// operation calls one interface method and returns its error
+type operation struct {
+	name string
+	call func(ctx context.Context, client database.DatabaseInterface, collection string) error
+}
+
+// operations covers every method of DatabaseInterface except Close
+var operations = []operation{
+	{"Ping", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		return c.Ping(ctx)
+	}},
+	{"Find", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.Find(ctx, Database, coll, bson.M{})
+		return err
+	}},
+	{"FindOne", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.FindOne(ctx, Database, coll, bson.M{})
+		return err
+	}},
+	{"FindCursor", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		cursor, err := c.FindCursor(ctx, Database, coll, bson.M{})
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(context.Background())
+		for cursor.Next(ctx) {
+		}
+		return cursor.Err()
+	}},
+	{"Aggregate", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.Aggregate(ctx, Database, coll, mongo.Pipeline{})
+		return err
+	}},
+	{"Count", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.Count(ctx, Database, coll, bson.M{})
+		return err
+	}},
+	{"Distinct", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.Distinct(ctx, Database, coll, "name", bson.M{})
+		return err
+	}},
+	{"InsertOne", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.InsertOne(ctx, Database, coll, bson.M{"name": "insert"})
+		return err
+	}},
+	{"InsertMany", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.InsertMany(ctx, Database, coll, []any{bson.M{"name": "insert"}})
+		return err
+	}},
+	{"UpdateOne", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.UpdateOne(ctx, Database, coll, bson.M{"name": "missing"}, bson.M{"$set": bson.M{"n": 1}})
+		return err
+	}},
+	{"UpdateMany", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.UpdateMany(ctx, Database, coll, bson.M{"name": "missing"}, bson.M{"$set": bson.M{"n": 1}})
+		return err
+	}},
+	{"ReplaceOne", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.ReplaceOne(ctx, Database, coll, bson.M{"name": "missing"}, bson.M{"name": "replaced"})
+		return err
+	}},
+	{"DeleteOne", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.DeleteOne(ctx, Database, coll, bson.M{"name": "missing"})
+		return err
+	}},
+	{"DeleteMany", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.DeleteMany(ctx, Database, coll, bson.M{"name": "missing"})
+		return err
+	}},
+	{"FindOneAndUpdate", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.FindOneAndUpdate(ctx, Database, coll, bson.M{"name": "missing"}, bson.M{"$set": bson.M{"n": 1}})
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return err
+	}},
+	{"BulkWrite", func(ctx context.Context, c database.DatabaseInterface, coll string) error {
+		_, err := c.BulkWrite(ctx, Database, coll, []any{mongo.NewDeleteOneModel().SetFilter(bson.M{"name": "missing"})})
+		return err
+	}},
+}
+
+// RunConformance checks the documented contract of every DatabaseInterface
+// method against the clients newClient returns: the not-found sentinel, the
+// shape of empty results, isolation of ForTenant scopes, independent copies
+// of results, context cancellation and behaviour after Close. Each subtest
+// gets its own client, which is closed when the subtest ends, and works in its
+// own collection of the Database database, cleared before use.
+//
+// Implementations that do not store documents, such as MockDatabase with its
+// defaults, pass the round-trip checks trivially.
+func RunConformance(t *testing.T, newClient func() database.DatabaseInterface) {
+	t.Helper()
+
+	// open returns a fresh client and an empty collection named after the test
+	open := func(t *testing.T) (database.DatabaseInterface, string) {
+		t.Helper()
+		client := newClient()
+		t.Cleanup(func() { client.Close(context.Background()) })
+
+		collection := collectionName(t)
+		if _, err := client.DeleteMany(context.Background(), Database, collection, bson.M{}); err != nil {
+			t.Fatalf("failed to clear %s.%s: %v", Database, collection, err)
+		}
+		return client, collection
+	}
+
+	t.Run("Ping", func(t *testing.T) {
+		client, _ := open(t)
+		if err := client.Ping(context.Background()); err != nil {
+			t.Errorf("Ping: expected nil error, got %v", err)
+		}
+	})
+
+	t.Run("EmptyResults", func(t *testing.T) {
+		client, coll := open(t)
+		checkEmptyResults(t, client, coll)
+	})
+
+	t.Run("NoMatchWrites", func(t *testing.T) {
+		client, coll := open(t)
+		checkNoMatchWrites(t, client, coll)
+	})
+
+	t.Run("InsertedIDs", func(t *testing.T) {
+		client, coll := open(t)
+		ctx := context.Background()
+
+		id, err := client.InsertOne(ctx, Database, coll, bson.M{"name": "alice"})
+		if err != nil || id == nil {
+			t.Errorf("InsertOne: expected an _id, got %v, %v", id, err)
+		}
+		ids, err := client.InsertMany(ctx, Database, coll, []any{bson.M{"name": "bob"}, bson.M{"name": "carol"}})
+		if err != nil || len(ids) != 2 || ids[0] == nil || ids[1] == nil {
+			t.Errorf("InsertMany: expected 2 _ids in insertion order, got %v, %v", ids, err)
+		}
+	})
+
+	t.Run("TenantIsolation", func(t *testing.T) {
+		client, coll := open(t)
+		checkTenantIsolation(t, client, coll)
+	})
+
+	t.Run("ResultsAreCopies", func(t *testing.T) {
+		client, coll := open(t)
+		checkCopies(t, client, coll)
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		client, coll := open(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, op := range operations {
+			if err := op.call(ctx, client, coll); !errors.Is(err, context.Canceled) {
+				t.Errorf("%s: expected an error matching context.Canceled, got %v", op.name, err)
+			}
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		client, coll := open(t)
+		ctx := context.Background()
+
+		if err := client.Close(ctx); err != nil {
+			t.Fatalf("Close: expected nil error, got %v", err)
+		}
+		if err := client.Close(ctx); err != nil {
+			t.Errorf("Close: closing twice should not be an error, got %v", err)
+		}
+		for _, op := range operations {
+			if err := op.call(ctx, client, coll); !errors.Is(err, database.ErrClientClosed) {
+				t.Errorf("%s: expected an error matching database.ErrClientClosed after Close, got %v", op.name, err)
+			}
+		}
+	})
+}
+
+func checkEmptyResults(t *testing.T, client database.DatabaseInterface, coll string) {
+	t.Helper()
+	ctx := context.Background()
+
+	found, err := client.Find(ctx, Database, coll, bson.M{})
+	if docs, ok := found.([]any); err != nil || !ok || docs == nil || len(docs) != 0 {
+		t.Errorf("Find: expected an empty, non-nil []any, got %#v, %v", found, err)
+	}
+	if doc, err := client.FindOne(ctx, Database, coll, bson.M{}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("FindOne: expected mongo.ErrNoDocuments, got %v, %v", doc, err)
+	}
+
+	cursor, err := client.FindCursor(ctx, Database, coll, bson.M{})
+	if err != nil {
+		t.Errorf("FindCursor: expected nil error, got %v", err)
+	} else {
+		if cursor.Next(ctx) {
+			t.Error("FindCursor: expected no documents")
+		}
+		if err := cursor.Err(); err != nil {
+			t.Errorf("FindCursor: expected nil cursor error, got %v", err)
+		}
+		if err := cursor.Close(ctx); err != nil {
+			t.Errorf("FindCursor: expected Close to succeed, got %v", err)
+		}
+	}
+
+	aggregated, err := client.Aggregate(ctx, Database, coll, mongo.Pipeline{})
+	if docs, ok := aggregated.([]any); err != nil || !ok || docs == nil || len(docs) != 0 {
+		t.Errorf("Aggregate: expected an empty, non-nil []any, got %#v, %v", aggregated, err)
+	}
+	if n, err := client.Count(ctx, Database, coll, bson.M{}); err != nil || n != 0 {
+		t.Errorf("Count: expected 0, got %d, %v", n, err)
+	}
+	if values, err := client.Distinct(ctx, Database, coll, "name", bson.M{}); err != nil || values == nil || len(values) != 0 {
+		t.Errorf("Distinct: expected an empty, non-nil slice, got %#v, %v", values, err)
+	}
+}
+
+func checkNoMatchWrites(t *testing.T, client database.DatabaseInterface, coll string) {
+	t.Helper()
+	ctx := context.Background()
+	filter := bson.M{"name": "missing"}
+	update := bson.M{"$set": bson.M{"n": 1}}
+
+	updates := map[string]func() (*database.UpdateResult, error){
+		"UpdateOne":  func() (*database.UpdateResult, error) { return client.UpdateOne(ctx, Database, coll, filter, update) },
+		"UpdateMany": func() (*database.UpdateResult, error) { return client.UpdateMany(ctx, Database, coll, filter, update) },
+		"ReplaceOne": func() (*database.UpdateResult, error) {
+			return client.ReplaceOne(ctx, Database, coll, filter, bson.M{"name": "replaced"})
+		},
+	}
+	for name, call := range updates {
+		if res, err := call(); err != nil || res == nil || res.MatchedCount != 0 || res.ModifiedCount != 0 || res.UpsertedID != nil {
+			t.Errorf("%s: expected a non-nil result matching nothing, got %+v, %v", name, res, err)
+		}
+	}
+
+	if n, err := client.DeleteOne(ctx, Database, coll, filter); err != nil || n != 0 {
+		t.Errorf("DeleteOne: expected 0, got %d, %v", n, err)
+	}
+	if n, err := client.DeleteMany(ctx, Database, coll, filter); err != nil || n != 0 {
+		t.Errorf("DeleteMany: expected 0, got %d, %v", n, err)
+	}
+	if doc, err := client.FindOneAndUpdate(ctx, Database, coll, filter, update); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("FindOneAndUpdate: expected mongo.ErrNoDocuments, got %v, %v", doc, err)
+	}
+
+	res, err := client.BulkWrite(ctx, Database, coll, []any{
+		mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update),
+		mongo.NewDeleteManyModel().SetFilter(filter),
+	})
+	if err != nil || res == nil || res.MatchedCount != 0 || res.DeletedCount != 0 {
+		t.Errorf("BulkWrite: expected a non-nil result matching nothing, got %+v, %v", res, err)
+	}
+}
+
+// checkTenantIsolation verifies that clients scoped with ForTenant neither
+// see nor change each other's documents
+func checkTenantIsolation(t *testing.T, client database.DatabaseInterface, coll string) {
+	t.Helper()
+	ctx := context.Background()
+	db := &database.Database{Client: client}
+	acme := db.ForTenant("acme", database.TenantConfig{}).Client
+	globex := db.ForTenant("globex", database.TenantConfig{}).Client
+
+	if _, err := acme.InsertOne(ctx, Database, coll, bson.M{"_id": "acme-1", "name": "front"}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+	if _, err := globex.InsertOne(ctx, Database, coll, bson.M{"_id": "globex-1", "name": "front"}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	if doc, err := globex.FindOne(ctx, Database, coll, bson.M{"_id": "acme-1"}); !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("FindOne: expected another tenant's document hidden, got %v, %v", doc, err)
+	}
+	if n, err := globex.Count(ctx, Database, coll, bson.M{"$or": bson.A{bson.M{"_id": "acme-1"}, bson.M{"name": "back"}}}); err != nil || n != 0 {
+		t.Errorf("Count: expected 0 across tenants, got %d, %v", n, err)
+	}
+	if res, err := globex.UpdateMany(ctx, Database, coll, bson.M{"_id": "acme-1"}, bson.M{"$set": bson.M{"name": "moved"}}); err != nil || res == nil || res.MatchedCount != 0 {
+		t.Errorf("UpdateMany: expected to match nothing across tenants, got %+v, %v", res, err)
+	}
+	if n, err := globex.DeleteMany(ctx, Database, coll, bson.M{"_id": "acme-1"}); err != nil || n != 0 {
+		t.Errorf("DeleteMany: expected to delete nothing across tenants, got %d, %v", n, err)
+	}
+
+	doc, err := acme.FindOne(ctx, Database, coll, bson.M{"_id": "acme-1"})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return // the implementation does not store documents
+	}
+	var fields bson.M
+	if data, merr := bson.Marshal(doc); err != nil || merr != nil || bson.Unmarshal(data, &fields) != nil {
+		t.Fatalf("FindOne: %v, %v", doc, err)
+	}
+	if fields["tenant_id"] != "acme" || fields["name"] != "front" {
+		t.Errorf("FindOne: expected the acme document unchanged and stamped, got %v", fields)
+	}
+	if n, err := acme.Count(ctx, Database, coll, bson.M{}); err != nil || n != 1 {
+		t.Errorf("Count: expected 1 acme document, got %d, %v", n, err)
+	}
+}
+
+// checkCopies verifies that neither the caller's input nor a returned result
+// shares memory with what the implementation keeps
+func checkCopies(t *testing.T, client database.DatabaseInterface, coll string) {
+	t.Helper()
+	ctx := context.Background()
+
+	input := bson.M{"_id": "copy", "nested": bson.M{"n": int32(1)}, "tags": bson.A{"a"}}
+	if _, err := client.InsertOne(ctx, Database, coll, input); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+	mutate(input)
+
+	for _, read := range []string{"FindOne", "Find"} {
+		doc, err := readCopy(ctx, client, coll, read)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return // the implementation does not store documents
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", read, err)
+		}
+		if !unchanged(doc) {
+			t.Fatalf("%s: mutating the inserted document changed the stored one: %v", read, doc)
+		}
+		mutate(doc)
+
+		again, err := readCopy(ctx, client, coll, read)
+		if err != nil || !unchanged(again) {
+			t.Errorf("%s: mutating a result changed later results: %v, %v", read, again, err)
+		}
+	}
+}
+
+// readCopy returns the "copy" document through FindOne or Find
+func readCopy(ctx context.Context, client database.DatabaseInterface, coll string, read string) (any, error) {
+	if read == "FindOne" {
+		return client.FindOne(ctx, Database, coll, bson.M{"_id": "copy"})
+	}
+	result, err := client.Find(ctx, Database, coll, bson.M{"_id": "copy"})
+	if err != nil {
+		return nil, err
+	}
+	docs, _ := result.([]any)
+	if len(docs) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return docs[0], nil
+}
+
+// unchanged reports whether doc still holds the values checkCopies inserted
+func unchanged(doc any) bool {
+	var fields bson.M
+	data, err := bson.Marshal(doc)
+	if err != nil || bson.Unmarshal(data, &fields) != nil {
+		return false
+	}
+	nested, _ := fields["nested"].(bson.M)
+	tags, _ := fields["tags"].(bson.A)
+	return nested["n"] == int32(1) && len(tags) == 1 && tags[0] == "a"
+}
+
+// mutate changes every value of a document except its _id in place,
+// whichever of the driver's document types it is
+func mutate(doc any) {
+	switch d := doc.(type) {
+	case bson.M:
+		for key, value := range d {
+			if key != "_id" {
+				d[key] = mutateValue(value)
+			}
+		}
+	case map[string]any:
+		for key, value := range d {
+			if key != "_id" {
+				d[key] = mutateValue(value)
+			}
+		}
+	case bson.D:
+		for i := range d {
+			if d[i].Key != "_id" {
+				d[i].Value = mutateValue(d[i].Value)
+			}
+		}
+	}
+}
+
+// mutateValue changes nested documents and arrays in place and replaces
+// anything else
+func mutateValue(value any) any {
+	switch v := value.(type) {
+	case bson.A:
+		for i := range v {
+			v[i] = "mutated"
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = "mutated"
+		}
+		return v
+	case bson.M, bson.D, map[string]any:
+		mutate(v)
+		return v
+	}
+	return "mutated"
+}
+
+var unsafeCollectionChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// collectionName derives a collection name from the test name
+func collectionName(t *testing.T) string {
+	return "conformance_" + unsafeCollectionChars.ReplaceAllString(t.Name(), "_")
+}
@@ -0,0 +1,32 @@
+//go:build integration
+
+package databasetest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/uug-ai/database/pkg/database"
+)
+
+// TestMongoConformance runs the conformance suite against a real MongoDB:
+// go test -tags integration with MONGODB_URI set
+func TestMongoConformance(t *testing.T) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+
+	opts := database.NewMongoOptions().
+		SetUri(mongodbUri).
+		SetTimeout(5000).
+		Build()
+
+	RunConformance(t, func() database.DatabaseInterface {
+		db, err := database.New(opts)
+		if err != nil {
+			t.Fatalf("failed to create database instance: %v", err)
+		}
+		return db.Client
+	})
+}
@@ -0,0 +1,21 @@
+package databasetest
+
+import (
+	"testing"
+
+	"github.com/uug-ai/database/pkg/database"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("Fake", func(t *testing.T) {
+		RunConformance(t, func() database.DatabaseInterface {
+			return database.NewFakeDatabase()
+		})
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		RunConformance(t, func() database.DatabaseInterface {
+			return database.NewMockDatabase().FailAfterClose(true)
+		})
+	})
+}
@@ -0,0 +1,261 @@
+package databasetest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/log"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"github.com/uug-ai/database/pkg/database"
+)
+
+// DefaultImage is the image StartMongo runs unless WithImage or the
+// MONGODB_TEST_IMAGE environment variable names another
+const DefaultImage = "mongo:7"
+
+// replicaSetName is the name of the replica set WithReplicaSet initiates
+const replicaSetName = "rs0"
+
+// ContainerOption configures StartMongo
+type ContainerOption func(*containerConfig)
+
+type containerConfig struct {
+	image        string
+	replicaSet   bool
+	shared       bool
+	startTimeout time.Duration
+}
+
+// WithImage runs image, such as "mongo:6.0", instead of DefaultImage
+func WithImage(image string) ContainerOption {
+	return func(c *containerConfig) {
+		c.image = image
+	}
+}
+
+// WithReplicaSet starts mongod as a single-node replica set, which
+// transactions and change streams need
+func WithReplicaSet() ContainerOption {
+	return func(c *containerConfig) {
+		c.replicaSet = true
+	}
+}
+
+// WithShared reuses one container for every StartMongo call of the test
+// binary with the same image and replica set setting, instead of starting
+// one per test. Tests sharing a container should use their own databases.
+// Stop shared containers with StopShared from TestMain.
+func WithShared() ContainerOption {
+	return func(c *containerConfig) {
+		c.shared = true
+	}
+}
+
+// WithStartTimeout bounds how long StartMongo waits for the server to answer
+// a ping, one minute by default, which covers pulling the image
+func WithStartTimeout(d time.Duration) ContainerOption {
+	return func(c *containerConfig) {
+		c.startTimeout = d
+	}
+}
+
+// container is a running MongoDB container
+type container struct {
+	mongo *mongodb.MongoDBContainer
+	uri   string
+}
+
+var (
+	sharedMu   sync.Mutex
+	containers = map[containerConfig]*container{}
+)
+
+// StartMongo starts MongoDB in a container with testcontainers-go and
+// returns a Database connected to it once it answers a ping, for
+// integration tests that need a real server. The returned function closes
+// the client and removes the container; it is registered with t.Cleanup as
+// well, so calling it is only needed to stop early. A shared container, see
+// WithShared, outlives the test and only the client is closed. The test is
+// skipped when Docker is not available.
+//
+//	func TestArchive(t *testing.T) {
+//		db, _ := databasetest.StartMongo(t, databasetest.WithReplicaSet())
+//		...
+//	}
+func StartMongo(t testing.TB, opts ...ContainerOption) (*database.Database, func()) {
+	t.Helper()
+	cfg := containerConfig{image: DefaultImage, startTimeout: time.Minute}
+	if image := os.Getenv("MONGODB_TEST_IMAGE"); image != "" {
+		cfg.image = image
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := dockerAvailable(); err != nil {
+		t.Skipf("databasetest: Docker is not available, skipping: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.startTimeout)
+	defer cancel()
+
+	var c *container
+	var err error
+	if cfg.shared {
+		c, err = sharedContainer(ctx, cfg)
+	} else {
+		c, err = startContainer(ctx, cfg, testcontainers.WithLogger(log.TestLogger(t)))
+	}
+	if err != nil {
+		t.Fatalf("databasetest: %v", err)
+	}
+
+	db, err := connect(ctx, c.uri)
+	if err != nil {
+		if !cfg.shared {
+			c.remove()
+		}
+		t.Fatalf("databasetest: %s did not answer a ping within %s: %v", cfg.image, cfg.startTimeout, err)
+	}
+
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			db.Client.Close(ctx)
+			if !cfg.shared {
+				c.remove()
+			}
+		})
+	}
+	t.Cleanup(cleanup)
+	return db, cleanup
+}
+
+// StopShared removes the containers started with WithShared. Call it from
+// TestMain after the tests ran, as shared containers outlive every test;
+// testcontainers' reaper removes them when the test binary exits otherwise.
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		databasetest.StopShared()
+//		os.Exit(code)
+//	}
+func StopShared() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	for cfg, c := range containers {
+		c.remove()
+		delete(containers, cfg)
+	}
+}
+
+// sharedContainer returns the shared container of cfg, starting it on first
+// use
+func sharedContainer(ctx context.Context, cfg containerConfig) (*container, error) {
+	key := cfg
+	key.startTimeout = 0
+
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if c, ok := containers[key]; ok {
+		return c, nil
+	}
+	c, err := startContainer(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	containers[key] = c
+	return c, nil
+}
+
+// startContainer runs the mongodb module of testcontainers-go, which waits
+// for mongod to listen and initiates the replica set when cfg asks for one
+func startContainer(ctx context.Context, cfg containerConfig, customize ...testcontainers.ContainerCustomizer) (*container, error) {
+	if cfg.replicaSet {
+		customize = append(customize, mongodb.WithReplicaSet(replicaSetName))
+	}
+	mongo, err := mongodb.Run(ctx, cfg.image, customize...)
+	if err != nil {
+		testcontainers.TerminateContainer(mongo)
+		return nil, fmt.Errorf("start %s: %w", cfg.image, err)
+	}
+	uri, err := mongo.ConnectionString(ctx)
+	if err == nil {
+		uri, err = directURI(uri)
+	}
+	if err != nil {
+		testcontainers.TerminateContainer(mongo)
+		return nil, fmt.Errorf("connection string of %s: %w", cfg.image, err)
+	}
+	return &container{mongo: mongo, uri: uri}, nil
+}
+
+// directURI returns uri connecting to its host directly. The replica set
+// member is registered under the container's address, which the host may
+// not reach, so the client must not discover the set from it.
+func directURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Del("replicaSet")
+	q.Set("directConnection", "true")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// connect creates a Database for uri, retrying until the server answers a
+// ping or ctx ends
+func connect(ctx context.Context, uri string) (*database.Database, error) {
+	opts := database.NewMongoOptions().
+		SetUri(uri).
+		SetTimeout(2000).
+		SetVerifyConnection(true).
+		Build()
+	for {
+		db, err := database.NewWithContext(ctx, opts)
+		if err == nil {
+			return db, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// remove stops and deletes the container
+func (c *container) remove() {
+	testcontainers.TerminateContainer(c.mongo)
+}
+
+// dockerAvailable reports why testcontainers cannot run containers, if it
+// cannot
+func dockerAvailable() (err error) {
+	// The Docker client panics on some malformed configurations
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	provider, err := testcontainers.ProviderDefault.GetProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return provider.Health(ctx)
+}
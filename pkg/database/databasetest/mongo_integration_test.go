@@ -0,0 +1,66 @@
+//go:build integration
+
+package databasetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uug-ai/database/pkg/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestStartMongo starts real containers: go test -tags integration with
+// Docker running. It is skipped without Docker.
+func TestStartMongo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Standalone", func(t *testing.T) {
+		db, cleanup := StartMongo(t)
+		if _, err := db.Client.InsertOne(ctx, Database, "start", bson.M{"_id": "a"}); err != nil {
+			t.Fatal(err)
+		}
+		doc, err := db.Client.FindOne(ctx, Database, "start", bson.M{"_id": "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			ID string `bson:"_id"`
+		}
+		if raw, err := bson.Marshal(doc); err != nil || bson.Unmarshal(raw, &got) != nil || got.ID != "a" {
+			t.Errorf("expected the inserted document back, got %v", doc)
+		}
+		cleanup()
+		// The cleanup registered with t.Cleanup runs again without harm
+		cleanup()
+	})
+
+	t.Run("ReplicaSet", func(t *testing.T) {
+		db, _ := StartMongo(t, WithReplicaSet())
+		stream, err := database.Watch(ctx, db.Client, Database, "start", mongo.Pipeline{})
+		if err != nil {
+			t.Fatalf("expected change streams on the replica set, got %v", err)
+		}
+		defer stream.Close(ctx)
+		if _, err := db.Client.InsertOne(ctx, Database, "start", bson.M{"_id": "b"}); err != nil {
+			t.Fatal(err)
+		}
+		next, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		var event database.ChangeEvent
+		if !stream.Next(next) || stream.Decode(&event) != nil || event.DocumentKey["_id"] != "b" {
+			t.Errorf("expected the insert of b, got %+v, %v", event, stream.Err())
+		}
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		t.Cleanup(StopShared)
+		first, _ := StartMongo(t, WithShared())
+		second, _ := StartMongo(t, WithShared())
+		if first.Options.Uri != second.Options.Uri {
+			t.Errorf("expected one shared container, got %s and %s", first.Options.Uri, second.Options.Uri)
+		}
+	})
+}
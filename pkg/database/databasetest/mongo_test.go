@@ -0,0 +1,20 @@
+package databasetest
+
+import "testing"
+
+func TestDirectURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"mongodb://localhost:32768/", "mongodb://localhost:32768/?directConnection=true"},
+		{"mongodb://localhost:32768/?replicaSet=rs0", "mongodb://localhost:32768/?directConnection=true"},
+		{"mongodb://[::1]:32768/?replicaSet=rs0", "mongodb://[::1]:32768/?directConnection=true"},
+	}
+	for _, tt := range tests {
+		got, err := directURI(tt.uri)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: expected %s, got %s, %v", tt.uri, tt.want, got, err)
+		}
+	}
+}
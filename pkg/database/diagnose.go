@@ -0,0 +1,463 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Steps of a connection diagnosis, in the order they run
+const (
+	StepOptions = "options"
+	StepDNS     = "dns"
+	StepTCP     = "tcp"
+	StepTLS     = "tls"
+	StepAuth    = "auth"
+	StepHello   = "hello"
+)
+
+// defaultDiagnosisBudget bounds a diagnosis without a timeout or deadline
+const defaultDiagnosisBudget = 10 * time.Second
+
+// diagnosisWeights splits the budget between the steps; the per-host steps
+// share their slice between the hosts
+var diagnosisWeights = map[string]int{StepDNS: 1, StepTCP: 2, StepTLS: 2, StepAuth: 3, StepHello: 2}
+
+// DiagnosisStep is the outcome of one probe
+type DiagnosisStep struct {
+	Name string
+	// Target is the host probed, empty for steps on the whole deployment
+	Target   string
+	OK       bool
+	Duration time.Duration
+	// Detail describes what the probe found, such as the addresses a host
+	// resolves to or the server certificate
+	Detail     string
+	Err        error
+	Suggestion string
+}
+
+// Diagnosis is the report of DiagnoseConnection
+type Diagnosis struct {
+	Steps []DiagnosisStep
+}
+
+// OK reports whether every step succeeded
+func (d *Diagnosis) OK() bool {
+	return d.Failed() == nil
+}
+
+// Failed returns the step that stopped the diagnosis or, when it went on
+// with other hosts, the first failed step; nil when every step succeeded
+func (d *Diagnosis) Failed() *DiagnosisStep {
+	if last := len(d.Steps) - 1; last >= 0 && !d.Steps[last].OK {
+		return &d.Steps[last]
+	}
+	for i := range d.Steps {
+		if !d.Steps[i].OK {
+			return &d.Steps[i]
+		}
+	}
+	return nil
+}
+
+// String renders the report, one line per step with the suggestion of each
+// failed step
+func (d *Diagnosis) String() string {
+	var b strings.Builder
+	for _, step := range d.Steps {
+		status := "ok"
+		if !step.OK {
+			status = "FAILED"
+		}
+		fmt.Fprintf(&b, "%-7s %-6s", step.Name, status)
+		if step.Target != "" {
+			fmt.Fprintf(&b, " %s", step.Target)
+		}
+		fmt.Fprintf(&b, " (%s)", step.Duration.Round(time.Millisecond))
+		if step.Detail != "" {
+			fmt.Fprintf(&b, ": %s", step.Detail)
+		}
+		if step.Err != nil {
+			fmt.Fprintf(&b, ": %v", step.Err)
+		}
+		b.WriteByte('\n')
+		if step.Suggestion != "" {
+			fmt.Fprintf(&b, "        -> %s\n", step.Suggestion)
+		}
+	}
+	return b.String()
+}
+
+// helloResult holds the fields of the hello response the diagnosis reports
+type helloResult struct {
+	SetName           string `bson:"setName"`
+	IsWritablePrimary bool   `bson:"isWritablePrimary"`
+	MaxWireVersion    int32  `bson:"maxWireVersion"`
+	Msg               string `bson:"msg"`
+}
+
+// diagnosisProbes are the network operations of a diagnosis, replaced in tests
+type diagnosisProbes struct {
+	lookup func(ctx context.Context, host string) ([]string, error)
+	dial   func(ctx context.Context, address string) error
+	// handshake returns the server certificate, also when verifying it fails
+	handshake    func(ctx context.Context, address string, cfg *tls.Config) (*x509.Certificate, error)
+	authenticate func(ctx context.Context, opts *moptions.ClientOptions) error
+	hello        func(ctx context.Context, opts *moptions.ClientOptions) (*helloResult, error)
+}
+
+// DiagnoseConnection finds out why a connection fails by trying each step on
+// its own: resolving each host, connecting to it over TCP, the TLS handshake
+// when TLS is enabled, authenticating and running hello. The report says
+// which step failed and suggests a fix. Each probe gets a slice of the
+// options' Timeout, or of ctx's deadline when it is sooner. The error is only
+// set when opts is nil; failed probes are part of the report.
+//
+//	diagnosis, err := database.DiagnoseConnection(ctx, opts)
+//	if err == nil && !diagnosis.OK() {
+//		fmt.Print(diagnosis)
+//	}
+func DiagnoseConnection(ctx context.Context, opts *MongoOptions) (*Diagnosis, error) {
+	return diagnoseConnection(ctx, opts, defaultProbes())
+}
+
+func diagnoseConnection(ctx context.Context, opts *MongoOptions, probes diagnosisProbes) (*Diagnosis, error) {
+	if opts == nil {
+		return nil, errors.New("diagnose connection: options are required")
+	}
+	budget := opts.connectTimeout()
+	if deadline, ok := ctx.Deadline(); ok && (budget == 0 || time.Until(deadline) < budget) {
+		budget = time.Until(deadline)
+	}
+	if budget <= 0 {
+		budget = defaultDiagnosisBudget
+	}
+	r := &diagnosisRun{ctx: ctx, budget: budget, diagnosis: &Diagnosis{}}
+
+	opts, err := resolveCredentials(ctx, opts)
+	if err != nil {
+		r.add(DiagnosisStep{Name: StepAuth, Err: err, Suggestion: "the credential provider failed: check its configuration and that its secrets are in place"})
+		return r.diagnosis, nil
+	}
+	clientOpts, err := buildClientOptions(opts)
+	if err == nil {
+		if tunnel, ok := clientOpts.Dialer.(*sshTunnel); ok {
+			defer tunnel.Close()
+		}
+		err = clientOpts.Validate()
+	}
+	if err != nil {
+		name, suggestion := StepOptions, "fix the connection options"
+		if isSRV(opts) {
+			name, suggestion = StepDNS, "the SRV record of the cluster could not be resolved: check the cluster host name and the DNS servers of this machine"
+		}
+		r.add(DiagnosisStep{Name: name, Err: err, Suggestion: suggestion})
+		return r.diagnosis, nil
+	}
+
+	hosts := clientOpts.Hosts
+	reachable := r.probeHosts(hosts, clientOpts.TLSConfig, probes)
+	if reachable == "" {
+		return r.diagnosis, nil
+	}
+
+	// Talk to one reachable host directly, so a wrong replica set name shows
+	// up in hello rather than as a server selection timeout
+	direct := *clientOpts
+	direct.Hosts = []string{reachable}
+	direct.ReplicaSet = nil
+	direct.SetDirect(true)
+
+	start := time.Now()
+	authCtx, cancel := r.slice(StepAuth, 1)
+	err = probes.authenticate(authCtx, &direct)
+	cancel()
+	step := DiagnosisStep{Name: StepAuth, Target: reachable, OK: err == nil, Duration: time.Since(start), Err: err}
+	if err != nil {
+		step.Suggestion = authSuggestion(err, opts)
+		r.add(step)
+		return r.diagnosis, nil
+	}
+	step.Detail = "authenticated"
+	if clientOpts.Auth == nil {
+		step.Detail = "connected without credentials"
+	}
+	r.add(step)
+
+	start = time.Now()
+	helloCtx, cancel := r.slice(StepHello, 1)
+	hello, err := probes.hello(helloCtx, &direct)
+	cancel()
+	step = DiagnosisStep{Name: StepHello, Target: reachable, OK: err == nil, Duration: time.Since(start), Err: err}
+	switch {
+	case err != nil:
+		step.Suggestion = "the server accepted the connection but did not answer hello: check it is a MongoDB-compatible server"
+	case clientOpts.ReplicaSet != nil && *clientOpts.ReplicaSet != hello.SetName:
+		step.OK = false
+		step.Err = fmt.Errorf("server is in replica set %q, not %q", hello.SetName, *clientOpts.ReplicaSet)
+		step.Suggestion = fmt.Sprintf("set the replica set name to %q, or leave it empty", hello.SetName)
+		if hello.SetName == "" {
+			step.Suggestion = "the server is not part of a replica set: leave the replica set name empty"
+		}
+	default:
+		step.Detail = helloDetail(hello)
+	}
+	r.add(step)
+	return r.diagnosis, nil
+}
+
+// isSRV reports whether the options find their hosts by an SRV lookup
+func isSRV(opts *MongoOptions) bool {
+	if opts.Uri != "" {
+		return strings.HasPrefix(opts.Uri, "mongodb+srv://")
+	}
+	return slices.ContainsFunc(opts.seedList(), isAtlasHost)
+}
+
+// isSocket reports whether host is a Unix domain socket, which the driver
+// recognizes by its suffix
+func isSocket(host string) bool {
+	return strings.HasSuffix(host, ".sock")
+}
+
+// diagnosisRun holds the state of one diagnosis
+type diagnosisRun struct {
+	ctx       context.Context
+	budget    time.Duration
+	diagnosis *Diagnosis
+}
+
+func (r *diagnosisRun) add(step DiagnosisStep) {
+	r.diagnosis.Steps = append(r.diagnosis.Steps, step)
+}
+
+// slice returns a context for one probe of step, which shares the step's
+// part of the budget with n-1 other probes
+func (r *diagnosisRun) slice(step string, n int) (context.Context, context.CancelFunc) {
+	total := 0
+	for _, w := range diagnosisWeights {
+		total += w
+	}
+	d := r.budget * time.Duration(diagnosisWeights[step]) / time.Duration(total*n)
+	return context.WithTimeout(r.ctx, d)
+}
+
+// probeHosts resolves, connects to and, with TLS, shakes hands with each
+// host, and returns the first host that passed every probe
+func (r *diagnosisRun) probeHosts(hosts []string, tlsConfig *tls.Config, probes diagnosisProbes) string {
+	reachable := ""
+	for _, host := range hosts {
+		name, port, err := net.SplitHostPort(host)
+		if err != nil {
+			name, port = host, "27017"
+		}
+		address := net.JoinHostPort(name, port)
+
+		// A Unix domain socket has no name to resolve
+		if isSocket(host) {
+			address = host
+		} else {
+			start := time.Now()
+			ctx, cancel := r.slice(StepDNS, len(hosts))
+			addrs, err := probes.lookup(ctx, name)
+			cancel()
+			step := DiagnosisStep{Name: StepDNS, Target: host, OK: err == nil, Duration: time.Since(start), Err: err}
+			if err != nil {
+				step.Suggestion = fmt.Sprintf("%s does not resolve: check the host name and the DNS servers of this machine", name)
+				r.add(step)
+				continue
+			}
+			step.Detail = strings.Join(addrs, ", ")
+			r.add(step)
+		}
+
+		start := time.Now()
+		ctx, cancel := r.slice(StepTCP, len(hosts))
+		err = probes.dial(ctx, address)
+		cancel()
+		step := DiagnosisStep{Name: StepTCP, Target: host, OK: err == nil, Duration: time.Since(start), Err: err}
+		if err != nil {
+			step.Suggestion = dialSuggestion(err, address)
+			r.add(step)
+			continue
+		}
+		r.add(step)
+
+		if tlsConfig != nil {
+			cfg := tlsConfig.Clone()
+			if cfg.ServerName == "" {
+				cfg.ServerName = name
+			}
+			start = time.Now()
+			ctx, cancel = r.slice(StepTLS, len(hosts))
+			cert, err := probes.handshake(ctx, address, cfg)
+			cancel()
+			step = DiagnosisStep{Name: StepTLS, Target: host, OK: err == nil, Duration: time.Since(start), Err: err}
+			if cert != nil {
+				step.Detail = fmt.Sprintf("certificate %s issued by %s, valid until %s",
+					cert.Subject, cert.Issuer, cert.NotAfter.UTC().Format(time.RFC3339))
+			}
+			if err != nil {
+				step.Suggestion = tlsSuggestion(err, cert)
+				r.add(step)
+				continue
+			}
+			r.add(step)
+		}
+
+		if reachable == "" {
+			reachable = host
+		}
+	}
+	return reachable
+}
+
+func dialSuggestion(err error, address string) string {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Sprintf("nothing listens on %s: check the port and that the server is running", address)
+	case errors.Is(err, context.DeadlineExceeded) || isNetTimeout(err):
+		return fmt.Sprintf("%s did not answer: check firewalls and security groups allow this machine", address)
+	}
+	return fmt.Sprintf("could not connect to %s: check the network route to the server", address)
+}
+
+func isNetTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func tlsSuggestion(err error, cert *x509.Certificate) string {
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknown):
+		return "the server certificate is signed by an unknown authority: set the CA file to the bundle that signed it"
+	case errors.As(err, &hostname):
+		return "the server certificate is for another host name: connect with a name the certificate lists"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired && cert != nil:
+		return fmt.Sprintf("the server certificate expired at %s: renew it", cert.NotAfter.UTC().Format(time.RFC3339))
+	case cert == nil:
+		return "the TLS handshake failed: check the server has TLS enabled, or disable TLS"
+	}
+	return "the server certificate is not trusted: check the CA file and the certificate"
+}
+
+func authSuggestion(err error, opts *MongoOptions) string {
+	if IsUnauthorized(err) {
+		source := opts.AuthSource
+		if source == "" {
+			source = "admin"
+		}
+		return fmt.Sprintf("the server rejected the credentials: check the username, password, auth source (%s) and auth mechanism", source)
+	}
+	if IsTimeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		return "the server did not complete the handshake in time: raise the timeout or check the server's load"
+	}
+	return "the server refused the session: check the credentials and the server logs"
+}
+
+func helloDetail(hello *helloResult) string {
+	role := "secondary"
+	switch {
+	case hello.IsWritablePrimary && hello.Msg == "isdbgrid":
+		role = "mongos"
+	case hello.IsWritablePrimary:
+		role = "primary"
+	}
+	detail := fmt.Sprintf("%s, wire version %d", role, hello.MaxWireVersion)
+	if hello.SetName != "" {
+		detail += ", replica set " + hello.SetName
+	}
+	return detail
+}
+
+// defaultProbes returns the probes that use the network
+func defaultProbes() diagnosisProbes {
+	return diagnosisProbes{
+		lookup: net.DefaultResolver.LookupHost,
+		dial: func(ctx context.Context, address string) error {
+			network := "tcp"
+			if isSocket(address) {
+				network = "unix"
+			}
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, network, address)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+		handshake:    tlsHandshake,
+		authenticate: probeAuthenticate,
+		hello:        probeHello,
+	}
+}
+
+// tlsHandshake shakes hands without verifying, so the certificate can be
+// reported, then verifies it as the driver would
+func tlsHandshake(ctx context.Context, address string, cfg *tls.Config) (*x509.Certificate, error) {
+	insecure := cfg.Clone()
+	insecure.InsecureSkipVerify = true
+	d := tls.Dialer{Config: insecure}
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("server sent no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		DNSName:       cfg.ServerName,
+		Roots:         cfg.RootCAs,
+		Intermediates: intermediates,
+	})
+	if cfg.InsecureSkipVerify {
+		err = nil
+	}
+	return certs[0], err
+}
+
+func probeAuthenticate(ctx context.Context, opts *moptions.ClientOptions) error {
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	return mapError(client.Ping(ctx, nil))
+}
+
+func probeHello(ctx context.Context, opts *moptions.ClientOptions) (*helloResult, error) {
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Disconnect(context.Background())
+
+	var hello helloResult
+	err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &hello, nil
+}
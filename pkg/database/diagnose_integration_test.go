@@ -0,0 +1,36 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestDiagnoseConnectionLive runs the real probes against a reachable
+// deployment and an unused port: go test -tags integration with MONGODB_URI
+// set
+func TestDiagnoseConnectionLive(t *testing.T) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+	ctx := context.Background()
+
+	diagnosis, err := DiagnoseConnection(ctx, NewMongoOptions().SetUri(mongodbUri).SetTimeout(10000).Build())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !diagnosis.OK() {
+		t.Errorf("expected the deployment to be healthy:\n%s", diagnosis)
+	}
+
+	diagnosis, err = DiagnoseConnection(ctx, NewMongoOptions().SetUri("mongodb://127.0.0.1:1").SetTimeout(2000).Build())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if failed := diagnosis.Failed(); failed == nil || failed.Name != StepTCP {
+		t.Errorf("expected the TCP step to fail:\n%s", diagnosis)
+	}
+}
@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// healthyProbes returns probes for a reachable replica set rs0
+func healthyProbes() diagnosisProbes {
+	return diagnosisProbes{
+		lookup: func(ctx context.Context, host string) ([]string, error) {
+			return []string{"10.0.0.1"}, nil
+		},
+		dial: func(ctx context.Context, address string) error { return nil },
+		handshake: func(ctx context.Context, address string, cfg *tls.Config) (*x509.Certificate, error) {
+			return &x509.Certificate{Subject: pkix.Name{CommonName: cfg.ServerName}, NotAfter: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}, nil
+		},
+		authenticate: func(ctx context.Context, opts *moptions.ClientOptions) error { return nil },
+		hello: func(ctx context.Context, opts *moptions.ClientOptions) (*helloResult, error) {
+			return &helloResult{SetName: "rs0", IsWritablePrimary: true, MaxWireVersion: 21}, nil
+		},
+	}
+}
+
+func TestDiagnoseConnection(t *testing.T) {
+	ctx := context.Background()
+	components := func() *MongoOptionsBuilder {
+		return NewMongoOptions().
+			SetHosts([]string{"db1:27017", "db2:27017"}).
+			SetUsername("user").
+			SetPassword("pass").
+			SetAuthSource("admin").
+			SetReplicaSet("rs0").
+			SetTimeout(1000)
+	}
+
+	tests := []struct {
+		name       string
+		opts       *MongoOptions
+		probes     func(p *diagnosisProbes)
+		ok         bool
+		failed     string
+		target     string
+		suggestion string
+		steps      []string
+	}{
+		{
+			name:  "Healthy",
+			opts:  components().Build(),
+			ok:    true,
+			steps: []string{"dns db1:27017", "tcp db1:27017", "dns db2:27017", "tcp db2:27017", "auth db1:27017", "hello db1:27017"},
+		},
+		{
+			name: "TLS",
+			opts: components().SetTLS(true).Build(),
+			ok:   true,
+			steps: []string{"dns db1:27017", "tcp db1:27017", "tls db1:27017", "dns db2:27017", "tcp db2:27017", "tls db2:27017",
+				"auth db1:27017", "hello db1:27017"},
+		},
+		{
+			name: "Socket",
+			opts: NewMongoOptions().SetSocketPath("/tmp/mongodb-27017.sock").SetUsername("user").SetPassword("pass").SetAuthSource("admin").SetTimeout(1000).Build(),
+			probes: func(p *diagnosisProbes) {
+				p.lookup = func(ctx context.Context, host string) ([]string, error) {
+					return nil, errors.New("a socket is not resolved")
+				}
+				p.hello = func(ctx context.Context, opts *moptions.ClientOptions) (*helloResult, error) {
+					return &helloResult{IsWritablePrimary: true}, nil
+				}
+			},
+			ok:    true,
+			steps: []string{"tcp /tmp/mongodb-27017.sock", "auth /tmp/mongodb-27017.sock", "hello /tmp/mongodb-27017.sock"},
+		},
+		{
+			name: "OneHostUnresolved",
+			opts: components().Build(),
+			probes: func(p *diagnosisProbes) {
+				p.lookup = func(ctx context.Context, host string) ([]string, error) {
+					if host == "db1" {
+						return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+					}
+					return []string{"10.0.0.2"}, nil
+				}
+			},
+			failed:     StepDNS,
+			target:     "db1:27017",
+			suggestion: "db1 does not resolve",
+			steps:      []string{"dns db1:27017", "dns db2:27017", "tcp db2:27017", "auth db2:27017", "hello db2:27017"},
+		},
+		{
+			name: "Refused",
+			opts: components().Build(),
+			probes: func(p *diagnosisProbes) {
+				p.dial = func(ctx context.Context, address string) error {
+					return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+				}
+			},
+			failed:     StepTCP,
+			target:     "db2:27017",
+			suggestion: "nothing listens on db2:27017",
+			steps:      []string{"dns db1:27017", "tcp db1:27017", "dns db2:27017", "tcp db2:27017"},
+		},
+		{
+			name: "Firewalled",
+			opts: components().SetHost("db1").Build(),
+			probes: func(p *diagnosisProbes) {
+				p.dial = func(ctx context.Context, address string) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+			},
+			failed:     StepTCP,
+			suggestion: "check firewalls",
+		},
+		{
+			name: "UnknownAuthority",
+			opts: components().SetHost("db1").SetTLS(true).Build(),
+			probes: func(p *diagnosisProbes) {
+				healthy := p.handshake
+				p.handshake = func(ctx context.Context, address string, cfg *tls.Config) (*x509.Certificate, error) {
+					cert, _ := healthy(ctx, address, cfg)
+					return cert, x509.UnknownAuthorityError{Cert: cert}
+				}
+			},
+			failed:     StepTLS,
+			suggestion: "set the CA file",
+		},
+		{
+			name: "BadCredentials",
+			opts: components().Build(),
+			probes: func(p *diagnosisProbes) {
+				p.authenticate = func(ctx context.Context, opts *moptions.ClientOptions) error {
+					return mapError(mongo.CommandError{Code: 18, Name: "AuthenticationFailed", Message: "Authentication failed."})
+				}
+			},
+			failed:     StepAuth,
+			suggestion: "auth source (admin)",
+		},
+		{
+			name:       "WrongReplicaSet",
+			opts:       components().SetReplicaSet("rs1").Build(),
+			failed:     StepHello,
+			suggestion: `set the replica set name to "rs0"`,
+		},
+		{
+			name: "Standalone",
+			opts: components().Build(),
+			probes: func(p *diagnosisProbes) {
+				p.hello = func(ctx context.Context, opts *moptions.ClientOptions) (*helloResult, error) {
+					return &helloResult{IsWritablePrimary: true}, nil
+				}
+			},
+			failed:     StepHello,
+			suggestion: "leave the replica set name empty",
+		},
+		{
+			name:       "InvalidOptions",
+			opts:       components().SetReadConcern("eventual").Build(),
+			failed:     StepOptions,
+			suggestion: "fix the connection options",
+			steps:      []string{"options "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := healthyProbes()
+			if tt.probes != nil {
+				tt.probes(&probes)
+			}
+			diagnosis, err := diagnoseConnection(ctx, tt.opts, probes)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diagnosis.OK() != tt.ok {
+				t.Fatalf("expected OK to be %v:\n%s", tt.ok, diagnosis)
+			}
+			if tt.steps != nil {
+				var got []string
+				for _, step := range diagnosis.Steps {
+					got = append(got, step.Name+" "+step.Target)
+				}
+				if strings.Join(got, "|") != strings.Join(tt.steps, "|") {
+					t.Errorf("expected steps %v, got %v", tt.steps, got)
+				}
+			}
+			if tt.ok {
+				return
+			}
+			failed := diagnosis.Failed()
+			if failed.Name != tt.failed || (tt.target != "" && failed.Target != tt.target) {
+				t.Errorf("expected %s %s to fail, got %s %s", tt.failed, tt.target, failed.Name, failed.Target)
+			}
+			if !strings.Contains(failed.Suggestion, tt.suggestion) {
+				t.Errorf("expected a suggestion containing %q, got %q", tt.suggestion, failed.Suggestion)
+			}
+			if !strings.Contains(diagnosis.String(), "-> "+failed.Suggestion) {
+				t.Errorf("expected the report to show the suggestion:\n%s", diagnosis)
+			}
+		})
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		diagnosis, _ := diagnoseConnection(ctx, components().SetHost("db1").SetTLS(true).Build(), healthyProbes())
+		report := diagnosis.String()
+		for _, want := range []string{"dns     ok     db1 (0s): 10.0.0.1", "certificate CN=db1", "valid until 2030-01-01T00:00:00Z", "primary, wire version 21, replica set rs0"} {
+			if !strings.Contains(report, want) {
+				t.Errorf("expected the report to contain %q:\n%s", want, report)
+			}
+		}
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		probes := healthyProbes()
+		slices := map[string]time.Duration{}
+		record := func(name string, ctx context.Context) {
+			deadline, _ := ctx.Deadline()
+			slices[name] = time.Until(deadline).Round(10 * time.Millisecond)
+		}
+		probes.lookup = func(ctx context.Context, host string) ([]string, error) {
+			record(StepDNS, ctx)
+			return nil, nil
+		}
+		probes.dial = func(ctx context.Context, address string) error {
+			record(StepTCP, ctx)
+			return nil
+		}
+		probes.authenticate = func(ctx context.Context, opts *moptions.ClientOptions) error {
+			record(StepAuth, ctx)
+			return nil
+		}
+
+		// 1000ms over weights summing to 10, the per-host steps shared by two hosts
+		if _, err := diagnoseConnection(ctx, components().Build(), probes); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := map[string]time.Duration{StepDNS: 50 * time.Millisecond, StepTCP: 100 * time.Millisecond, StepAuth: 300 * time.Millisecond}
+		if fmt.Sprint(slices) != fmt.Sprint(want) {
+			t.Errorf("expected slices %v, got %v", want, slices)
+		}
+	})
+
+	t.Run("NilOptions", func(t *testing.T) {
+		if _, err := diagnoseConnection(ctx, nil, healthyProbes()); err == nil {
+			t.Error("expected an error without options")
+		}
+	})
+
+	t.Run("DirectToReachableHost", func(t *testing.T) {
+		probes := healthyProbes()
+		var got *moptions.ClientOptions
+		probes.authenticate = func(ctx context.Context, opts *moptions.ClientOptions) error {
+			got = opts
+			return errors.New("stop")
+		}
+		diagnoseConnection(ctx, components().Build(), probes)
+		if got == nil || len(got.Hosts) != 1 || got.Direct == nil || !*got.Direct || got.ReplicaSet != nil {
+			t.Errorf("expected a direct connection to one host without a replica set, got %+v", got)
+		}
+	})
+}
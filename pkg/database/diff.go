@@ -0,0 +1,323 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultDiffExamples is how many documents DiffCollections keeps per kind of
+// difference when no MaxExamples is given
+const defaultDiffExamples = 10
+
+// defaultDiffProgressEvery is how many documents DiffCollections reads
+// between Progress calls when no ProgressEvery is given
+const defaultDiffProgressEvery = 1000
+
+// DiffConfig configures DiffCollections
+type DiffConfig struct {
+	// IgnoreFields are dotted paths left out of the comparison, such as
+	// updated_at, along with everything under them
+	IgnoreFields []string
+	// Tolerance is the largest difference between two numbers that still
+	// counts as equal, for values that went through a float conversion
+	Tolerance float64
+	// MaxExamples caps the documents the report keeps of each kind of
+	// difference, 10 when zero; a negative value keeps none
+	MaxExamples int
+	// BatchSize sets the batch size of both cursors, the driver's default
+	// when zero
+	BatchSize int32
+	// Progress is called with the counts so far every ProgressEvery
+	// documents read from either side, 1000 by default, and once at the end
+	Progress      func(DiffProgress)
+	ProgressEvery int
+}
+
+// DiffProgress are the running counts of DiffCollections
+type DiffProgress struct {
+	SourceRead      int64 `json:"source_read"`
+	TargetRead      int64 `json:"target_read"`
+	Matching        int64 `json:"matching"`
+	MissingInTarget int64 `json:"missing_in_target"`
+	MissingInSource int64 `json:"missing_in_source"`
+	Different       int64 `json:"different"`
+}
+
+// DiffReport is the result of DiffCollections. The examples are the first
+// documents of each kind in _id order, up to DiffConfig.MaxExamples.
+type DiffReport struct {
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	DiffProgress
+	MissingInTargetExamples []map[string]any `json:"missing_in_target_examples"`
+	MissingInSourceExamples []map[string]any `json:"missing_in_source_examples"`
+	DifferentExamples       []DocumentDiff   `json:"different_examples"`
+}
+
+// Equal reports whether the collections held the same documents
+func (r *DiffReport) Equal() bool {
+	return r.MissingInTarget == 0 && r.MissingInSource == 0 && r.Different == 0
+}
+
+// DocumentDiff is a document whose fields differ between source and target
+type DocumentDiff struct {
+	ID any `json:"id"`
+	// Fields are the dotted paths that differ, with array indexes as path
+	// elements
+	Fields []string       `json:"fields"`
+	Source map[string]any `json:"source"`
+	Target map[string]any `json:"target"`
+}
+
+// DiffCollections compares the documents of db.collection matching filter
+// in source and target, such as two clusters before and after a migration.
+// It streams both sides sorted by _id and merges them like a join, so memory
+// holds one document per side plus the capped examples however large the
+// collection. Documents are compared field by field regardless of field
+// order, numbers of different types are equal when their values are, and
+// cfg can ignore fields and tolerate small numeric differences.
+//
+//	report, err := database.DiffCollections(ctx, oldCluster, newCluster, "vault", "events", bson.M{}, database.DiffConfig{
+//		IgnoreFields: []string{"updated_at"},
+//		Progress: func(p database.DiffProgress) {
+//			log.Printf("read %d source documents, %d different", p.SourceRead, p.Different)
+//		},
+//	})
+//	if err == nil && !report.Equal() {
+//		log.Printf("%d missing in target, %d different", report.MissingInTarget, report.Different)
+//	}
+func DiffCollections(ctx context.Context, source *Database, target *Database, db string, collection string, filter any, cfg DiffConfig) (*DiffReport, error) {
+	if cfg.Tolerance < 0 {
+		return nil, fmt.Errorf("diff %s.%s: tolerance must not be negative, got %v", db, collection, cfg.Tolerance)
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+	maxExamples := cfg.MaxExamples
+	if maxExamples == 0 {
+		maxExamples = defaultDiffExamples
+	}
+	every := int64(cfg.ProgressEvery)
+	if every <= 0 {
+		every = defaultDiffProgressEvery
+	}
+
+	opts := moptions.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if cfg.BatchSize > 0 {
+		opts.SetBatchSize(cfg.BatchSize)
+	}
+	src, err := openDiffCursor(ctx, source, "source", db, collection, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer src.cursor.Close(ctx)
+	dst, err := openDiffCursor(ctx, target, "target", db, collection, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer dst.cursor.Close(ctx)
+
+	report := &DiffReport{
+		Database:                db,
+		Collection:              collection,
+		MissingInTargetExamples: []map[string]any{},
+		MissingInSourceExamples: []map[string]any{},
+		DifferentExamples:       []DocumentDiff{},
+	}
+	keep := func(n int) bool { return n < maxExamples }
+	progress := func() {
+		if cfg.Progress != nil {
+			report.SourceRead, report.TargetRead = src.read, dst.read
+			cfg.Progress(report.DiffProgress)
+		}
+	}
+
+	if err := src.advance(ctx); err != nil {
+		return nil, err
+	}
+	if err := dst.advance(ctx); err != nil {
+		return nil, err
+	}
+	for src.doc != nil || dst.doc != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("diff %s.%s: stopped after %d source and %d target documents: %w", db, collection, src.read, dst.read, err)
+		}
+		before := src.read + dst.read
+
+		var c int
+		switch {
+		case dst.doc == nil:
+			c = -1
+		case src.doc == nil:
+			c = 1
+		default:
+			c = compareValues(src.doc["_id"], dst.doc["_id"])
+		}
+		switch {
+		case c < 0:
+			report.MissingInTarget++
+			if keep(len(report.MissingInTargetExamples)) {
+				report.MissingInTargetExamples = append(report.MissingInTargetExamples, src.doc)
+			}
+			err = src.advance(ctx)
+		case c > 0:
+			report.MissingInSource++
+			if keep(len(report.MissingInSourceExamples)) {
+				report.MissingInSourceExamples = append(report.MissingInSourceExamples, dst.doc)
+			}
+			err = dst.advance(ctx)
+		default:
+			fields := diffFields(src.doc, dst.doc, "", cfg)
+			if len(fields) == 0 {
+				report.Matching++
+			} else {
+				report.Different++
+				if keep(len(report.DifferentExamples)) {
+					report.DifferentExamples = append(report.DifferentExamples, DocumentDiff{
+						ID:     src.doc["_id"],
+						Fields: fields,
+						Source: src.doc,
+						Target: dst.doc,
+					})
+				}
+			}
+			if err = src.advance(ctx); err == nil {
+				err = dst.advance(ctx)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (src.read+dst.read)/every != before/every {
+			progress()
+		}
+	}
+	report.SourceRead, report.TargetRead = src.read, dst.read
+	progress()
+	return report, nil
+}
+
+// diffCursor is one side of DiffCollections, holding its current document
+type diffCursor struct {
+	side   string
+	cursor Cursor
+	doc    map[string]any
+	read   int64
+}
+
+func openDiffCursor(ctx context.Context, d *Database, side string, db string, collection string, filter any, opts *moptions.FindOptions) (*diffCursor, error) {
+	cursor, err := d.Client.FindCursor(ctx, db, collection, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s.%s: %s: %w", db, collection, side, err)
+	}
+	return &diffCursor{side: side, cursor: cursor}, nil
+}
+
+// advance moves to the next document, leaving doc nil at the end, and checks
+// that the _id values ascend, as the merge relies on
+func (c *diffCursor) advance(ctx context.Context) error {
+	previous := c.doc
+	c.doc = nil
+	if !c.cursor.Next(ctx) {
+		if err := c.cursor.Err(); err != nil {
+			return fmt.Errorf("diff: %s: after %d documents: %w", c.side, c.read, err)
+		}
+		return nil
+	}
+	var doc any
+	if docs, ok := c.cursor.(documentCursor); ok {
+		doc = docs.document()
+	} else {
+		var raw bson.D
+		if err := c.cursor.Decode(&raw); err != nil {
+			return fmt.Errorf("diff: %s: decode document %d: %w", c.side, c.read, err)
+		}
+		doc = raw
+	}
+	fields, _ := normalizeDocument(doc).(map[string]any)
+	id, ok := fields["_id"]
+	if !ok {
+		return fmt.Errorf("diff: %s: document %d has no _id", c.side, c.read)
+	}
+	if previous != nil && compareValues(previous["_id"], id) >= 0 {
+		return fmt.Errorf("diff: %s: documents are not in ascending _id order at %v", c.side, id)
+	}
+	c.doc = fields
+	c.read++
+	return nil
+}
+
+// diffFields returns the sorted paths under prefix at which a and b differ
+func diffFields(a map[string]any, b map[string]any, prefix string, cfg DiffConfig) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var fields []string
+	for _, k := range keys {
+		path := prefix + k
+		if diffIgnored(path, cfg.IgnoreFields) {
+			continue
+		}
+		av, aok := a[k]
+		bv, bok := b[k]
+		if aok != bok {
+			fields = append(fields, path)
+			continue
+		}
+		fields = append(fields, diffValues(av, bv, path, cfg)...)
+	}
+	return fields
+}
+
+// diffValues returns the paths at which a and b, the values at path, differ
+func diffValues(a any, b any, path string, cfg DiffConfig) []string {
+	switch at := a.(type) {
+	case map[string]any:
+		if bt, ok := b.(map[string]any); ok {
+			return diffFields(at, bt, path+".", cfg)
+		}
+	case []any:
+		bt, ok := b.([]any)
+		if !ok || len(at) != len(bt) {
+			break
+		}
+		var fields []string
+		for i := range at {
+			fields = append(fields, diffValues(at[i], bt[i], path+"."+strconv.Itoa(i), cfg)...)
+		}
+		return fields
+	}
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok && math.Abs(af-bf) <= cfg.Tolerance {
+			return nil
+		}
+		return []string{path}
+	}
+	if valuesEqual(a, b) {
+		return nil
+	}
+	return []string{path}
+}
+
+// diffIgnored reports whether path is one of ignored or under one of them
+func diffIgnored(path string, ignored []string) bool {
+	return slices.ContainsFunc(ignored, func(field string) bool {
+		return path == field || strings.HasPrefix(path, field+".")
+	})
+}
@@ -0,0 +1,74 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestDiffCollectionsLive compares a collection across two deployments, such
+// as the clusters on either side of a migration:
+//
+//	MONGODB_URI=mongodb://source MONGODB_TARGET_URI=mongodb://target \
+//		go test -tags integration ./pkg/database -run DiffCollectionsLive
+//
+// The target connects with the suite's other settings.
+func TestDiffCollectionsLive(t *testing.T) {
+	targetURI := os.Getenv("MONGODB_TARGET_URI")
+	if targetURI == "" {
+		t.Skip("MONGODB_TARGET_URI not set, skipping integration test")
+	}
+	ctx := context.Background()
+	source := suiteClient(t)
+	opts := suiteOptions(t)
+	opts.Uri = targetURI
+	target, err := New(opts)
+	if err != nil {
+		t.Fatalf("failed to connect to the target: %v", err)
+	}
+	dbName := suiteDatabase()
+	coll := suiteCollection(t, source)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		target.Current().(*MongoClient).Client.Database(dbName).Collection(coll).Drop(ctx)
+		target.Client.Close(ctx)
+	})
+
+	seed := func(d *Database, docs ...any) {
+		t.Helper()
+		if _, err := d.Client.InsertMany(ctx, dbName, coll, docs); err != nil {
+			t.Fatalf("failed to seed: %v", err)
+		}
+	}
+	seed(source,
+		bson.M{"_id": "a", "score": 1, "updated_at": time.Now()},
+		bson.M{"_id": "b", "score": 2},
+		bson.M{"_id": "c", "score": 3},
+	)
+	seed(target,
+		bson.M{"_id": "a", "score": int64(1), "updated_at": time.Now().Add(time.Minute)},
+		bson.M{"_id": "b", "score": 20},
+		bson.M{"_id": "d", "score": 4},
+	)
+
+	report, err := DiffCollections(ctx, source, target, dbName, coll, nil, DiffConfig{
+		IgnoreFields: []string{"updated_at"},
+		BatchSize:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DiffProgress{SourceRead: 3, TargetRead: 3, Matching: 1, MissingInTarget: 1, MissingInSource: 1, Different: 1}
+	if report.DiffProgress != want {
+		t.Errorf("expected %+v, got %+v", want, report.DiffProgress)
+	}
+	if len(report.DifferentExamples) != 1 || report.DifferentExamples[0].ID != "b" {
+		t.Errorf("expected document b to differ, got %+v", report.DifferentExamples)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDiffCollections(t *testing.T) {
+	ctx := context.Background()
+	updated := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	setup := func() (*Database, *Database) {
+		source, target := NewFakeDatabase(), NewFakeDatabase()
+		source.Seed("vault", "events",
+			bson.M{"_id": 1, "type": "motion", "score": 0.5, "updated_at": updated},
+			bson.M{"_id": 2, "type": "sound", "tags": bson.A{"a", "b"}},
+			bson.M{"_id": 3, "type": "motion", "camera": bson.M{"name": "front", "zone": 1}},
+			bson.M{"_id": 4, "type": "motion"},
+			bson.M{"_id": 6, "type": "motion", "count": 7},
+		)
+		// The target holds the documents in another order, as a migration
+		// might write them, with controlled differences
+		target.Seed("vault", "events",
+			bson.M{"_id": 6, "count": int64(7), "type": "motion"},
+			bson.M{"_id": 5, "type": "sound"},
+			bson.M{"_id": 3, "type": "motion", "camera": bson.M{"name": "front", "zone": 2}},
+			bson.M{"_id": 2, "type": "sound", "tags": bson.A{"a", "c"}},
+			bson.M{"_id": 1, "type": "motion", "score": 0.5000001, "updated_at": updated.Add(time.Hour)},
+		)
+		return &Database{Client: source}, &Database{Client: target}
+	}
+
+	t.Run("Differences", func(t *testing.T) {
+		source, target := setup()
+		report, err := DiffCollections(ctx, source, target, "vault", "events", nil, DiffConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DiffProgress{SourceRead: 5, TargetRead: 5, Matching: 1, MissingInTarget: 1, MissingInSource: 1, Different: 3}
+		if report.DiffProgress != want {
+			t.Errorf("expected %+v, got %+v", want, report.DiffProgress)
+		}
+		if report.Equal() {
+			t.Error("expected the report not to be equal")
+		}
+		var fields [][]string
+		for _, d := range report.DifferentExamples {
+			fields = append(fields, d.Fields)
+		}
+		wantFields := [][]string{{"score", "updated_at"}, {"tags.1"}, {"camera.zone"}}
+		if !reflect.DeepEqual(fields, wantFields) {
+			t.Errorf("expected fields %v, got %v", wantFields, fields)
+		}
+		if id := report.MissingInTargetExamples[0]["_id"]; id != int32(4) {
+			t.Errorf("expected document 4 to be missing in the target, got %v", id)
+		}
+		if id := report.MissingInSourceExamples[0]["_id"]; id != int32(5) {
+			t.Errorf("expected document 5 to be missing in the source, got %v", id)
+		}
+		if _, err := json.Marshal(report); err != nil {
+			t.Errorf("expected the report to marshal to JSON, got %v", err)
+		}
+	})
+
+	t.Run("IgnoreAndTolerance", func(t *testing.T) {
+		source, target := setup()
+		report, err := DiffCollections(ctx, source, target, "vault", "events", bson.M{"_id": bson.M{"$in": bson.A{1, 3}}}, DiffConfig{
+			IgnoreFields: []string{"updated_at", "camera"},
+			Tolerance:    0.001,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Equal() || report.Matching != 2 {
+			t.Errorf("expected ignored fields and small numeric differences to match, got %+v", report)
+		}
+	})
+
+	t.Run("MaxExamples", func(t *testing.T) {
+		source, target := setup()
+		report, err := DiffCollections(ctx, source, target, "vault", "events", nil, DiffConfig{MaxExamples: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.DifferentExamples) != 1 || report.Different != 3 {
+			t.Errorf("expected one example of 3 differences, got %d of %d", len(report.DifferentExamples), report.Different)
+		}
+		if report, _ = DiffCollections(ctx, source, target, "vault", "events", nil, DiffConfig{MaxExamples: -1}); len(report.DifferentExamples) != 0 {
+			t.Errorf("expected no examples, got %v", report.DifferentExamples)
+		}
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		source, target := setup()
+		var calls []DiffProgress
+		_, err := DiffCollections(ctx, source, target, "vault", "events", nil, DiffConfig{
+			ProgressEvery: 4,
+			Progress:      func(p DiffProgress) { calls = append(calls, p) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(calls) != 3 {
+			t.Fatalf("expected progress at 4 and 8 documents read and at the end, got %+v", calls)
+		}
+		if last := calls[len(calls)-1]; last.SourceRead != 5 || last.TargetRead != 5 || last.Different != 3 {
+			t.Errorf("expected the final counts last, got %+v", last)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		report, err := DiffCollections(ctx, &Database{Client: NewFakeDatabase()}, &Database{Client: NewFakeDatabase()}, "vault", "events", nil, DiffConfig{})
+		if err != nil || !report.Equal() || report.SourceRead != 0 {
+			t.Errorf("expected empty collections to be equal, got %+v, %v", report, err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		source, _ := setup()
+		mock := NewMockDatabase()
+		mock.QueueUnauthorized()
+		if _, err := DiffCollections(ctx, source, &Database{Client: mock}, "vault", "events", nil, DiffConfig{}); !errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "target") {
+			t.Errorf("expected the target's error, got %v", err)
+		}
+
+		unsorted := NewMockDatabase()
+		unsorted.ExpectFindCursor([]any{bson.M{"_id": 2}, bson.M{"_id": 1}}, nil)
+		if _, err := DiffCollections(ctx, source, &Database{Client: unsorted}, "vault", "events", nil, DiffConfig{}); err == nil || !strings.Contains(err.Error(), "ascending _id order") {
+			t.Errorf("expected unsorted documents to be rejected, got %v", err)
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := DiffCollections(cancelled, source, source, "vault", "events", nil, DiffConfig{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancellation, got %v", err)
+		}
+		if _, err := DiffCollections(ctx, source, source, "vault", "events", nil, DiffConfig{Tolerance: -1}); err == nil {
+			t.Error("expected a negative tolerance to be rejected")
+		}
+	})
+}
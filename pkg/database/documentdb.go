@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// NewDocumentDBOptions creates a builder with the settings Amazon DocumentDB
+// needs: TLS trusting caFile, the absolute path of the Amazon RDS CA bundle
+// from https://truststore.pki.rds.amazonaws.com/global/global-bundle.pem,
+// retryable writes off, which DocumentDB does not support, SCRAM-SHA-1
+// against the admin database, the rs0 replica set every cluster reports,
+// and reads from replicas when available. Every setting can still be
+// overridden; ValidateForDocumentDB checks that the bundle can be loaded.
+//
+//	opts := database.NewDocumentDBOptions("docdb.cluster-abc.eu-west-1.docdb.amazonaws.com:27017", user, password, "/etc/app/rds-global-bundle.pem").
+//		SetTimeoutDuration(10 * time.Second).
+//		Build()
+func NewDocumentDBOptions(host string, username string, password string, caFile string) *MongoOptionsBuilder {
+	return NewMongoOptions().
+		SetHost(host).
+		SetUsername(username).
+		SetPassword(password).
+		SetAuthSource("admin").
+		SetAuthMechanism("SCRAM-SHA-1").
+		SetReplicaSet("rs0").
+		SetRetryWrites(false).
+		SetTLS(true).
+		SetCAFile(caFile).
+		SetReadPreference("secondaryPreferred")
+}
+
+// ValidateForDocumentDB reports the options Amazon DocumentDB does not
+// support, which otherwise only fail once commands reach the cluster, and a
+// CA bundle that is missing, relative or cannot be loaded
+func (o *MongoOptions) ValidateForDocumentDB() error {
+	var errs []error
+	if o.RetryWrites || strings.Contains(strings.ToLower(o.Uri), "retrywrites=true") {
+		errs = append(errs, errors.New("retryable writes are not supported, use SetRetryWrites(false)"))
+	}
+	if o.ReadConcern != "" && !slices.Contains([]string{"local", "majority"}, o.ReadConcern) {
+		errs = append(errs, fmt.Errorf("read concern %q is not supported, use local or majority", o.ReadConcern))
+	}
+	if slices.Contains([]string{"MONGODB-X509", "GSSAPI", "PLAIN"}, strings.ToUpper(o.AuthMechanism)) {
+		errs = append(errs, fmt.Errorf("auth mechanism %s is not supported", o.AuthMechanism))
+	}
+	if strings.HasPrefix(o.Uri, "mongodb+srv://") || slices.ContainsFunc(o.seedList(), isAtlasHost) {
+		errs = append(errs, errors.New("SRV connection strings are not supported"))
+	}
+	if o.AutoEncryption != nil {
+		errs = append(errs, errors.New("client-side field level encryption is not supported"))
+	}
+	switch {
+	case o.CAFile == "" && o.TLS:
+		errs = append(errs, errors.New("TLS needs the Amazon RDS CA bundle, use SetCAFile"))
+	case o.CAFile != "" && !filepath.IsAbs(o.CAFile):
+		errs = append(errs, fmt.Errorf("CA file %s must be an absolute path", o.CAFile))
+	case o.CAFile != "":
+		if _, err := o.tlsConfig(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("documentdb: %w", errors.Join(errs...))
+	}
+	return nil
+}
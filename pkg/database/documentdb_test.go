@@ -0,0 +1,160 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// writeTestCA writes a self-signed CA certificate as PEM and returns its path
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create a certificate: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatalf("failed to write the certificate: %v", err)
+	}
+	return path
+}
+
+func TestNewDocumentDBOptions(t *testing.T) {
+	t.Run("Preset", func(t *testing.T) {
+		ca := writeTestCA(t)
+		opts := NewDocumentDBOptions("docdb.cluster-abc.docdb.amazonaws.com:27017", "user", "p@ss", ca).SetTimeout(1000).Build()
+
+		if opts.Host != "docdb.cluster-abc.docdb.amazonaws.com:27017" || opts.Username != "user" || opts.Password != "p@ss" {
+			t.Errorf("expected the host and credentials, got %+v", opts)
+		}
+		if opts.RetryWrites {
+			t.Error("expected retry writes to be off")
+		}
+		if !opts.TLS || opts.CAFile != ca {
+			t.Errorf("expected TLS with the CA bundle, got %v, %q", opts.TLS, opts.CAFile)
+		}
+		if opts.AuthSource != "admin" || opts.AuthMechanism != "SCRAM-SHA-1" || opts.ReplicaSet != "rs0" {
+			t.Errorf("expected admin, SCRAM-SHA-1 and rs0, got %q, %q, %q", opts.AuthSource, opts.AuthMechanism, opts.ReplicaSet)
+		}
+		if opts.ReadPreference != "secondaryPreferred" {
+			t.Errorf("expected secondaryPreferred reads, got %q", opts.ReadPreference)
+		}
+		if err := opts.ValidateForDocumentDB(); err != nil {
+			t.Errorf("expected the preset to be valid, got %v", err)
+		}
+	})
+
+	t.Run("ClientOptions", func(t *testing.T) {
+		ca := writeTestCA(t)
+		opts := NewDocumentDBOptions("docdb.example.com:27017", "user", "pass", ca).SetTimeout(1000).Build()
+
+		clientOpts, err := buildClientOptions(opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if clientOpts.TLSConfig == nil || clientOpts.TLSConfig.RootCAs == nil {
+			t.Error("expected TLS with the CA file")
+		}
+		if clientOpts.RetryWrites == nil || *clientOpts.RetryWrites {
+			t.Error("expected retry writes to be off")
+		}
+		if clientOpts.ReadPreference == nil || clientOpts.ReadPreference.Mode() != readpref.SecondaryPreferredMode {
+			t.Errorf("expected secondaryPreferred reads, got %v", clientOpts.ReadPreference)
+		}
+		if clientOpts.ReplicaSet == nil || *clientOpts.ReplicaSet != "rs0" {
+			t.Errorf("expected replica set rs0, got %v", clientOpts.ReplicaSet)
+		}
+		if clientOpts.Auth == nil || clientOpts.Auth.AuthMechanism != "SCRAM-SHA-1" {
+			t.Errorf("expected SCRAM-SHA-1, got %+v", clientOpts.Auth)
+		}
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		opts := NewDocumentDBOptions("docdb.example.com", "user", "pass", "/etc/ssl/rds.pem").
+			SetCAFile("/etc/ssl/other.pem").
+			SetReadPreference("primary").
+			SetAuthMechanism("SCRAM-SHA-256").
+			Build()
+		if opts.CAFile != "/etc/ssl/other.pem" || opts.ReadPreference != "primary" || opts.AuthMechanism != "SCRAM-SHA-256" {
+			t.Errorf("expected the overrides, got %+v", opts)
+		}
+	})
+
+	t.Run("MissingCAFile", func(t *testing.T) {
+		opts := NewDocumentDBOptions("docdb.example.com", "user", "pass", filepath.Join(t.TempDir(), "missing.pem")).Build()
+		if _, err := buildClientOptions(opts); err == nil || !strings.Contains(err.Error(), "CA file") {
+			t.Errorf("expected a CA file error, got %v", err)
+		}
+		if err := opts.ValidateForDocumentDB(); err == nil || !strings.Contains(err.Error(), "CA file") {
+			t.Errorf("expected validation to load the CA file, got %v", err)
+		}
+	})
+}
+
+func TestValidateForDocumentDB(t *testing.T) {
+	ca := writeTestCA(t)
+	notPEM := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	preset := func() *MongoOptionsBuilder {
+		return NewDocumentDBOptions("docdb.example.com", "user", "pass", ca)
+	}
+	tests := []struct {
+		name    string
+		opts    *MongoOptions
+		wantErr string
+	}{
+		{"Preset", preset().Build(), ""},
+		{"MajorityReadConcern", preset().SetReadConcern("majority").Build(), ""},
+		{"RetryWrites", preset().SetRetryWrites(true).Build(), "retryable writes"},
+		{"RetryWritesInURI", NewMongoOptions().SetUri("mongodb://docdb.example.com/?retryWrites=true").Build(), "retryable writes"},
+		{"LinearizableReadConcern", preset().SetReadConcern("linearizable").Build(), "read concern"},
+		{"SnapshotReadConcern", preset().SetReadConcern("snapshot").Build(), "read concern"},
+		{"X509", preset().SetAuthMechanism("MONGODB-X509").Build(), "auth mechanism"},
+		{"SRV", NewMongoOptions().SetUri("mongodb+srv://cluster0.mongodb.net").Build(), "SRV"},
+		{"AutoEncryption", preset().SetAutoEncryption(map[string]map[string]any{"local": {}}, "db.keys", nil, false).Build(), "encryption"},
+		{"RelativeCAFile", preset().SetCAFile("global-bundle.pem").Build(), "absolute path"},
+		{"NoCAFile", preset().SetCAFile("").Build(), "CA bundle"},
+		{"MissingCAFile", preset().SetCAFile(filepath.Join(t.TempDir(), "missing.pem")).Build(), "CA file"},
+		{"CAFileNotPEM", preset().SetCAFile(notPEM).Build(), "no PEM certificates"},
+		{"NoTLS", preset().SetTLS(false).SetCAFile("").Build(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.ValidateForDocumentDB()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ErrSchemaDrift is returned by DriftReport.Check and CheckDrift when the
+// sampled documents drift from the model past the threshold
+var ErrSchemaDrift = errors.New("schema drift")
+
+// DriftReport compares the documents sampled from a collection with the bson
+// tags and Go types of a struct. Paths are dotted for fields of nested
+// structs; arrays and the contents of map, interface and bson.M fields are
+// not compared. Percentages are of the sampled documents.
+type DriftReport struct {
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	Model      string `json:"model"`
+	Sampled    int    `json:"sampled"`
+	// UnknownFields are in the documents but not in the struct, so decoding
+	// drops them
+	UnknownFields []DriftField `json:"unknown_fields"`
+	// UnobservedFields are in the struct but in none of the documents
+	UnobservedFields []DriftUnobserved `json:"unobserved_fields"`
+	// TypeMismatches are values of a type the struct field does not declare
+	TypeMismatches []DriftMismatch `json:"type_mismatches"`
+}
+
+// DriftField is a field the struct lacks, with the BSON types seen for it
+type DriftField struct {
+	Field   string   `json:"field"`
+	Types   []string `json:"types"`
+	Percent float64  `json:"percent"`
+}
+
+// DriftUnobserved is a struct field no sampled document has. Optional fields,
+// pointers and omitempty fields, are commonly absent.
+type DriftUnobserved struct {
+	Field    string `json:"field"`
+	Optional bool   `json:"optional"`
+}
+
+// DriftMismatch is a BSON type seen for a field whose Go type expects others
+type DriftMismatch struct {
+	Field    string   `json:"field"`
+	Expected []string `json:"expected"`
+	Observed string   `json:"observed"`
+	Percent  float64  `json:"percent"`
+}
+
+// Drifted reports whether the report has any finding
+func (r *DriftReport) Drifted() bool {
+	return len(r.UnknownFields) > 0 || len(r.UnobservedFields) > 0 || len(r.TypeMismatches) > 0
+}
+
+// Check returns an error matching ErrSchemaDrift listing the unknown fields
+// and type mismatches seen in at least threshold percent of the sampled
+// documents, and the required struct fields none of them has. Optional
+// unobserved fields are ignored, as is everything when nothing was sampled.
+//
+//	if err := report.Check(1); err != nil {
+//		log.Fatal(err) // fail the canary job
+//	}
+func (r *DriftReport) Check(threshold float64) error {
+	if r.Sampled == 0 {
+		return nil
+	}
+	var problems []string
+	for _, f := range r.UnknownFields {
+		if f.Percent >= threshold {
+			problems = append(problems, fmt.Sprintf("%s is not in the model (%.1f%%)", f.Field, f.Percent))
+		}
+	}
+	for _, f := range r.UnobservedFields {
+		if !f.Optional {
+			problems = append(problems, fmt.Sprintf("%s is in no document", f.Field))
+		}
+	}
+	for _, m := range r.TypeMismatches {
+		if m.Percent >= threshold {
+			problems = append(problems, fmt.Sprintf("%s is %s, want %s (%.1f%%)", m.Field, m.Observed, strings.Join(m.Expected, " or "), m.Percent))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w in %s.%s against %s: %s", ErrSchemaDrift, r.Database, r.Collection, r.Model, strings.Join(problems, "; "))
+}
+
+// DetectDrift samples up to sample documents of db.collection with $sample,
+// so it does not scan the collection, and compares their fields and BSON
+// types with the struct model, a value or pointer of the struct type the
+// collection decodes into. Numbers of any type match a float field, as
+// the driver decodes them.
+//
+//	report, err := db.DetectDrift(ctx, "vault", "cameras", 500, Camera{})
+//	data, _ := json.MarshalIndent(report, "", "  ") // CI artifact
+func (d *Database) DetectDrift(ctx context.Context, db string, collection string, sample int, model any) (*DriftReport, error) {
+	if sample < 1 {
+		return nil, fmt.Errorf("detect drift: sample must be at least 1, got %d", sample)
+	}
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("detect drift: model must be a struct, got %T", model)
+	}
+	fields := map[string]driftExpect{}
+	if err := driftFields(t, "", fields, map[reflect.Type]bool{}); err != nil {
+		return nil, fmt.Errorf("detect drift: %v: %w", t, err)
+	}
+
+	result, err := d.Client.Aggregate(ctx, db, collection, bson.A{bson.M{"$sample": bson.M{"size": sample}}})
+	if err != nil {
+		return nil, fmt.Errorf("detect drift: sample %s.%s: %w", db, collection, err)
+	}
+	docs, _ := result.([]any)
+	observed := map[string]map[string]int{}
+	for i, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("detect drift: document %d: %w", i, err)
+		}
+		if err := observeFields(bson.Raw(raw), "", fields, observed); err != nil {
+			return nil, fmt.Errorf("detect drift: document %d: %w", i, err)
+		}
+	}
+	return driftReport(db, collection, t, len(docs), fields, observed), nil
+}
+
+// CheckDrift runs DetectDrift and Check with threshold, returning the report
+// along with the error
+func (d *Database) CheckDrift(ctx context.Context, db string, collection string, sample int, model any, threshold float64) (*DriftReport, error) {
+	report, err := d.DetectDrift(ctx, db, collection, sample, model)
+	if err != nil {
+		return nil, err
+	}
+	return report, report.Check(threshold)
+}
+
+// driftExpect is what the model declares for a path: its BSON types, none
+// for any, whether it may be absent and whether its contents are free-form
+type driftExpect struct {
+	types    []string
+	optional bool
+	open     bool
+}
+
+// driftFields adds the paths of the fields of struct type t under prefix
+func driftFields(t reflect.Type, prefix string, fields map[string]driftExpect, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return fmt.Errorf("type %v is recursive", t)
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("bson")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		_, flags, _ := strings.Cut(tag, ",")
+		if strings.Contains(flags, "inline") && f.Type.Kind() == reflect.Struct {
+			if err := driftFields(f.Type, prefix, fields, seen); err != nil {
+				return err
+			}
+			continue
+		}
+		path := prefix + bsonKey(f)
+		schema, err := typeSchema(f.Type, map[reflect.Type]bool{})
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		expect := driftExpect{
+			optional: f.Type.Kind() == reflect.Pointer || strings.Contains(flags, "omitempty"),
+		}
+		switch types := schema["bsonType"].(type) {
+		case string:
+			expect.types = []string{types}
+		case []any:
+			for _, name := range types {
+				expect.types = append(expect.types, name.(string))
+			}
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case schema["properties"] != nil && ft.Kind() == reflect.Struct:
+			if err := driftFields(ft, path+".", fields, seen); err != nil {
+				return err
+			}
+		case slices.Contains(expect.types, "object"), len(expect.types) == 0:
+			expect.open = true
+		}
+		fields[path] = expect
+	}
+	return nil
+}
+
+// observeFields counts the BSON type of every field of doc under prefix into
+// observed, descending into documents the model describes field by field
+func observeFields(doc bson.Raw, prefix string, fields map[string]driftExpect, observed map[string]map[string]int) error {
+	elems, err := doc.Elements()
+	if err != nil {
+		return err
+	}
+	for _, elem := range elems {
+		path := prefix + elem.Key()
+		value := elem.Value()
+		if observed[path] == nil {
+			observed[path] = map[string]int{}
+		}
+		observed[path][bsonTypeName(value.Type)]++
+
+		expect, known := fields[path]
+		if known && !expect.open && value.Type == bsontype.EmbeddedDocument {
+			if err := observeFields(value.Document(), path+".", fields, observed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// driftReport compares what was observed in sampled documents with fields
+func driftReport(db string, collection string, t reflect.Type, sampled int, fields map[string]driftExpect, observed map[string]map[string]int) *DriftReport {
+	report := &DriftReport{
+		Database:         db,
+		Collection:       collection,
+		Model:            t.String(),
+		Sampled:          sampled,
+		UnknownFields:    []DriftField{},
+		UnobservedFields: []DriftUnobserved{},
+		TypeMismatches:   []DriftMismatch{},
+	}
+	percent := func(n int) float64 {
+		return float64(n) * 100 / float64(sampled)
+	}
+
+	for path, types := range observed {
+		expect, known := fields[path]
+		if !known {
+			n := 0
+			names := make([]string, 0, len(types))
+			for name, count := range types {
+				n += count
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			report.UnknownFields = append(report.UnknownFields, DriftField{Field: path, Types: names, Percent: percent(n)})
+			continue
+		}
+		for name, count := range types {
+			if !driftCompatible(expect.types, name) {
+				report.TypeMismatches = append(report.TypeMismatches, DriftMismatch{
+					Field:    path,
+					Expected: expect.types,
+					Observed: name,
+					Percent:  percent(count),
+				})
+			}
+		}
+	}
+	for path, expect := range fields {
+		if _, ok := observed[path]; ok {
+			continue
+		}
+		// The fields of a nested struct are not reported when no document
+		// has the struct itself
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			if _, ok := observed[path[:i]]; !ok {
+				continue
+			}
+		}
+		report.UnobservedFields = append(report.UnobservedFields, DriftUnobserved{Field: path, Optional: expect.optional})
+	}
+
+	sort.Slice(report.UnknownFields, func(i, j int) bool {
+		return report.UnknownFields[i].Field < report.UnknownFields[j].Field
+	})
+	sort.Slice(report.UnobservedFields, func(i, j int) bool {
+		return report.UnobservedFields[i].Field < report.UnobservedFields[j].Field
+	})
+	sort.Slice(report.TypeMismatches, func(i, j int) bool {
+		a, b := report.TypeMismatches[i], report.TypeMismatches[j]
+		return a.Field < b.Field || a.Field == b.Field && a.Observed < b.Observed
+	})
+	return report
+}
+
+// driftCompatible reports whether a value of BSON type observed decodes into
+// a field declaring expected. Null decodes into any field as its zero value.
+func driftCompatible(expected []string, observed string) bool {
+	if len(expected) == 0 || observed == "null" || slices.Contains(expected, observed) {
+		return true
+	}
+	return slices.Contains(expected, "double") && (observed == "int" || observed == "long")
+}
+
+// bsonTypeNames are the $jsonSchema aliases of the BSON types
+var bsonTypeNames = map[bsontype.Type]string{
+	bsontype.Double:           "double",
+	bsontype.String:           "string",
+	bsontype.EmbeddedDocument: "object",
+	bsontype.Array:            "array",
+	bsontype.Binary:           "binData",
+	bsontype.Undefined:        "undefined",
+	bsontype.ObjectID:         "objectId",
+	bsontype.Boolean:          "bool",
+	bsontype.DateTime:         "date",
+	bsontype.Null:             "null",
+	bsontype.Regex:            "regex",
+	bsontype.DBPointer:        "dbPointer",
+	bsontype.JavaScript:       "javascript",
+	bsontype.Symbol:           "symbol",
+	bsontype.CodeWithScope:    "javascriptWithScope",
+	bsontype.Int32:            "int",
+	bsontype.Timestamp:        "timestamp",
+	bsontype.Int64:            "long",
+	bsontype.Decimal128:       "decimal",
+	bsontype.MinKey:           "minKey",
+	bsontype.MaxKey:           "maxKey",
+}
+
+func bsonTypeName(t bsontype.Type) string {
+	if name, ok := bsonTypeNames[t]; ok {
+		return name
+	}
+	return t.String()
+}
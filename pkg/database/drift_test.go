@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type driftLocation struct {
+	Site string `bson:"site"`
+	Room string `bson:"room"`
+}
+
+type driftCamera struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	Port      int                `bson:"port"`
+	Zoom      float64            `bson:"zoom"`
+	Location  driftLocation      `bson:"location"`
+	Labels    map[string]string  `bson:"labels"`
+	DeletedAt *time.Time         `bson:"deleted_at"`
+	Firmware  string             `bson:"firmware"`
+}
+
+func TestDetectDrift(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	for i := 0; i < 10; i++ {
+		doc := bson.M{
+			"_id":      primitive.NewObjectID(),
+			"name":     "cam",
+			"port":     8080,
+			"zoom":     2,
+			"location": bson.M{"site": "hq", "room": "lobby"},
+			"labels":   bson.M{"anything": "goes"},
+			"model":    "x100",
+		}
+		if i < 3 {
+			doc["port"] = "8080"
+			doc["location"] = bson.M{"site": "hq", "floor": 2}
+		}
+		fake.Seed("vault", "cameras", doc)
+	}
+	db := &Database{Client: fake}
+
+	report, err := db.DetectDrift(ctx, "vault", "cameras", 100, &driftCamera{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DriftReport{
+		Database:   "vault",
+		Collection: "cameras",
+		Model:      "database.driftCamera",
+		Sampled:    10,
+		UnknownFields: []DriftField{
+			{Field: "location.floor", Types: []string{"int"}, Percent: 30},
+			{Field: "model", Types: []string{"string"}, Percent: 100},
+		},
+		UnobservedFields: []DriftUnobserved{
+			{Field: "deleted_at", Optional: true},
+			{Field: "firmware", Optional: false},
+		},
+		TypeMismatches: []DriftMismatch{
+			{Field: "port", Expected: []string{"int", "long"}, Observed: "string", Percent: 30},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("expected\n%+v\ngot\n%+v", want, report)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{`"unknown_fields"`, `"unobserved_fields"`, `"type_mismatches"`, `"percent":30`} {
+			if !strings.Contains(string(data), key) {
+				t.Errorf("expected %s in %s", key, data)
+			}
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			report    *DriftReport
+			threshold float64
+			want      []string
+		}{
+			{"All", report, 0, []string{"location.floor", "model", "firmware is in no document", "port is string"}},
+			{"AboveThreshold", report, 50, []string{"model", "firmware"}},
+			{"Clean", &DriftReport{Sampled: 5}, 0, nil},
+			{"OnlyOptionalMissing", &DriftReport{Sampled: 5, UnobservedFields: []DriftUnobserved{{Field: "deleted_at", Optional: true}}}, 0, nil},
+			{"NothingSampled", &DriftReport{UnobservedFields: []DriftUnobserved{{Field: "name"}}}, 0, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := tt.report.Check(tt.threshold)
+				if tt.want == nil {
+					if err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrSchemaDrift) {
+					t.Fatalf("expected ErrSchemaDrift, got %v", err)
+				}
+				for _, part := range tt.want {
+					if !strings.Contains(err.Error(), part) {
+						t.Errorf("expected %q in %v", part, err)
+					}
+				}
+				if tt.threshold == 50 && strings.Contains(err.Error(), "port") {
+					t.Errorf("expected findings under the threshold to be left out, got %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.ExpectAggregate([]any{}, nil)
+		report, err := (&Database{Client: mock}).DetectDrift(ctx, "vault", "cameras", 50, driftCamera{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Sampled != 0 || len(report.UnobservedFields) != 8 {
+			t.Errorf("expected every top-level field to be unobserved, got %+v", report)
+		}
+		pipeline := normalizeDocument(mock.AggregateCalls[0].Pipeline)
+		if want := []any{map[string]any{"$sample": map[string]any{"size": 50}}}; !reflect.DeepEqual(pipeline, want) {
+			t.Errorf("expected a single $sample stage, got %v", pipeline)
+		}
+	})
+
+	t.Run("CheckDrift", func(t *testing.T) {
+		if _, err := db.CheckDrift(ctx, "vault", "cameras", 5, driftCamera{}, 0); !errors.Is(err, ErrSchemaDrift) {
+			t.Errorf("expected ErrSchemaDrift, got %v", err)
+		}
+	})
+
+	t.Run("InvalidModel", func(t *testing.T) {
+		if _, err := db.DetectDrift(ctx, "vault", "cameras", 5, map[string]any{}); err == nil {
+			t.Error("expected a model that is not a struct to be rejected")
+		}
+		if _, err := db.DetectDrift(ctx, "vault", "cameras", 0, driftCamera{}); err == nil {
+			t.Error("expected a sample size of 0 to be rejected")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// FindEach calls fn with every document matching filter, one at a time, so a
+// scan over millions of documents holds one batch in memory rather than all
+// of them. Set the batch size with moptions.Find().SetBatchSize among opts.
+// It stops at the first error of fn and returns it wrapped with the number of
+// documents processed, and stops when ctx ends. The fake and the mock's
+// FindCursor responses drive fn the same way, so job logic can be tested
+// without a server.
+//
+//	err := db.FindEach(ctx, "vault", "events", bson.M{"archived": false}, func(doc map[string]any) error {
+//		return archive(ctx, doc)
+//	}, moptions.Find().SetBatchSize(500))
+func (d *Database) FindEach(ctx context.Context, db string, collection string, filter any, fn func(doc map[string]any) error, opts ...any) error {
+	return FindEachAs(ctx, d, db, collection, filter, fn, opts...)
+}
+
+// FindEachAs is FindEach decoding every document into T with the BSON options
+// of d.Options, like FindAs
+func FindEachAs[T any](ctx context.Context, d *Database, db string, collection string, filter any, fn func(doc T) error, opts ...any) error {
+	cursor, err := d.Client.FindCursor(ctx, db, collection, filter, opts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	c := d.codec()
+	docs, _ := cursor.(documentCursor)
+	processed := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("find each: stopped after %d documents: %w", processed, err)
+		}
+		if !cursor.Next(ctx) {
+			break
+		}
+		var doc T
+		if docs != nil {
+			err = c.decode(docs.document(), &doc)
+		} else {
+			err = cursor.Decode(&doc)
+		}
+		if err != nil {
+			return fmt.Errorf("find each: decode document %d into %T: %w", processed, doc, err)
+		}
+		if err := fn(doc); err != nil {
+			return fmt.Errorf("find each: stopped after %d documents: %w", processed, err)
+		}
+		processed++
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("find each: stopped after %d documents: %w", processed, err)
+	}
+	return nil
+}
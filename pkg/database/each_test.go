@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestFindEach(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	for i := range 5 {
+		if err := fake.Seed("vault", "events", bson.M{"camera": fmt.Sprintf("camera-%d", i), "order": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &Database{Client: fake}
+	sorted := moptions.Find().SetSort(bson.M{"order": 1}).SetBatchSize(2)
+
+	t.Run("Fake", func(t *testing.T) {
+		var cameras []string
+		err := db.FindEach(ctx, "vault", "events", bson.M{"order": bson.M{"$gte": 1}}, func(doc map[string]any) error {
+			cameras = append(cameras, doc["camera"].(string))
+			return nil
+		}, sorted)
+		if err != nil || strings.Join(cameras, ",") != "camera-1,camera-2,camera-3,camera-4" {
+			t.Errorf("expected cameras 1 to 4, got %v, %v", cameras, err)
+		}
+	})
+
+	t.Run("As", func(t *testing.T) {
+		type event struct {
+			Camera string `bson:"camera"`
+			Order  int    `bson:"order"`
+		}
+		total := 0
+		err := FindEachAs(ctx, db, "vault", "events", bson.M{}, func(e event) error {
+			total += e.Order
+			return nil
+		}, sorted)
+		if err != nil || total != 10 {
+			t.Errorf("expected the orders to sum to 10, got %d, %v", total, err)
+		}
+	})
+
+	t.Run("FnError", func(t *testing.T) {
+		failed := errors.New("archive unavailable")
+		calls := 0
+		err := db.FindEach(ctx, "vault", "events", bson.M{}, func(doc map[string]any) error {
+			calls++
+			if calls == 3 {
+				return failed
+			}
+			return nil
+		}, sorted)
+		if !errors.Is(err, failed) || !strings.Contains(err.Error(), "after 2 documents") || calls != 3 {
+			t.Errorf("expected the error of the third call after 2 documents, got %v after %d calls", err, calls)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		calls := 0
+		err := db.FindEach(ctx, "vault", "events", bson.M{}, func(doc map[string]any) error {
+			calls++
+			if calls == 2 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) || calls != 2 || !strings.Contains(err.Error(), "after 2 documents") {
+			t.Errorf("expected context.Canceled after 2 documents, got %v after %d calls", err, calls)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		mock    *MockDatabase
+		calls   int
+		wantErr string
+	}{
+		{"CursorError", NewMockDatabase().QueueFindCursor([]any{bson.M{"n": 1}, bson.M{"n": 2}, bson.M{"n": 3}}, 2, errors.New("cursor killed")), 2, "stopped after 2 documents: cursor killed"},
+		{"FindCursorError", NewMockDatabase().ExpectFindCursor(nil, errors.New("connection refused")), 0, "connection refused"},
+		{"DecodeError", NewMockDatabase().QueueFindCursor([]any{bson.M{"n": 1}, "not a document"}, -1, nil), 1, "decode document 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := (&Database{Client: tt.mock}).FindEach(ctx, "vault", "events", bson.M{}, func(doc map[string]any) error {
+				calls++
+				return nil
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || calls != tt.calls {
+				t.Errorf("expected an error containing %q after %d calls, got %v after %d", tt.wantErr, tt.calls, err, calls)
+			}
+			if len(tt.mock.FindCursorCalls) == 1 && tt.mock.FindCursorCalls[0].Cursor != nil && !tt.mock.FindCursorCalls[0].Cursor.Closed() {
+				t.Error("expected the cursor to be closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KMSProviders are the key management services the driver supports. A
+// provider may also be named, such as "local:backup".
+var KMSProviders = []string{"aws", "azure", "gcp", "kmip", "local"}
+
+// AutoEncryptionOptions configures client-side field level encryption.
+// Connecting with them needs a driver built with the cse build tag and
+// mongocryptd or the crypt_shared library available.
+type AutoEncryptionOptions struct {
+	// KmsProviders holds the credentials of each KMS provider by name, such
+	// as {"local": {"key": <96 bytes>}}
+	KmsProviders map[string]map[string]any
+	// KeyVaultNamespace is the "db.collection" holding the data keys
+	KeyVaultNamespace string
+	// SchemaMap maps "db.collection" namespaces to $jsonSchema documents
+	// naming the fields to encrypt
+	SchemaMap map[string]any
+	// BypassAutoEncryption only decrypts automatically; values are
+	// encrypted explicitly
+	BypassAutoEncryption bool
+}
+
+// SetAutoEncryption enables client-side field level encryption on both
+// connection paths
+//
+//	opts := database.NewMongoOptions().
+//		SetUri(uri).
+//		SetAutoEncryption(map[string]map[string]any{"local": {"key": masterKey}},
+//			"encryption.__keyVault", schemaMap, false).
+//		Build()
+func (b *MongoOptionsBuilder) SetAutoEncryption(kmsProviders map[string]map[string]any, keyVaultNamespace string, schemaMap map[string]any, bypassAutoEncryption bool) *MongoOptionsBuilder {
+	b.options.AutoEncryption = &AutoEncryptionOptions{
+		KmsProviders:         kmsProviders,
+		KeyVaultNamespace:    keyVaultNamespace,
+		SchemaMap:            schemaMap,
+		BypassAutoEncryption: bypassAutoEncryption,
+	}
+	return b
+}
+
+// Validate reports a missing or malformed key vault namespace and unknown
+// KMS providers, which the driver only reports when connecting
+func (o *AutoEncryptionOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.KeyVaultNamespace == "" {
+		return errors.New("auto encryption: key vault namespace is required")
+	}
+	if db, coll, ok := strings.Cut(o.KeyVaultNamespace, "."); !ok || db == "" || coll == "" {
+		return fmt.Errorf("auto encryption: key vault namespace %q must be \"db.collection\"", o.KeyVaultNamespace)
+	}
+	if len(o.KmsProviders) == 0 {
+		return errors.New("auto encryption: at least one KMS provider is required")
+	}
+	for name := range o.KmsProviders {
+		provider, _, _ := strings.Cut(name, ":")
+		if !slices.Contains(KMSProviders, provider) {
+			return fmt.Errorf("auto encryption: unknown KMS provider %q, expected one of %v", name, KMSProviders)
+		}
+	}
+	return nil
+}
+
+// clientOptions applies the options to a driver client configuration
+func (o *AutoEncryptionOptions) clientOptions(opts *moptions.ClientOptions) *moptions.ClientOptions {
+	if o == nil {
+		return opts
+	}
+	return opts.SetAutoEncryptionOptions(moptions.AutoEncryption().
+		SetKmsProviders(o.KmsProviders).
+		SetKeyVaultNamespace(o.KeyVaultNamespace).
+		SetSchemaMap(o.SchemaMap).
+		SetBypassAutoEncryption(o.BypassAutoEncryption))
+}
+
+// CreateDataKey creates a data key with kmsProvider in the key vault of the
+// client's auto encryption options and returns its ID, for use in the
+// schema map's encrypt.keyId
+func (m *MongoClient) CreateDataKey(ctx context.Context, kmsProvider string, opts *moptions.DataKeyOptions) (primitive.Binary, error) {
+	encryption := m.Options.AutoEncryption
+	if encryption == nil {
+		return primitive.Binary{}, errors.New("create data key: auto encryption is not configured")
+	}
+	if err := encryption.Validate(); err != nil {
+		return primitive.Binary{}, err
+	}
+	if _, ok := encryption.KmsProviders[kmsProvider]; !ok {
+		return primitive.Binary{}, fmt.Errorf("create data key: KMS provider %q is not configured", kmsProvider)
+	}
+
+	ce, err := mongo.NewClientEncryption(m.Client, moptions.ClientEncryption().
+		SetKeyVaultNamespace(encryption.KeyVaultNamespace).
+		SetKmsProviders(encryption.KmsProviders))
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+	defer ce.Close(ctx)
+
+	var dataKeyOpts []*moptions.DataKeyOptions
+	if opts != nil {
+		dataKeyOpts = append(dataKeyOpts, opts)
+	}
+	id, err := ce.CreateDataKey(ctx, kmsProvider, dataKeyOpts...)
+	return id, mapError(err)
+}
@@ -0,0 +1,77 @@
+//go:build integration && cse
+
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestMongoAutoEncryption encrypts a field with a local master key against a
+// real MongoDB: go test -tags "integration cse" with MONGODB_URI set and
+// mongocryptd or crypt_shared available
+func TestMongoAutoEncryption(t *testing.T) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+	ctx := context.Background()
+
+	masterKey := make([]byte, 96)
+	if _, err := rand.Read(masterKey); err != nil {
+		t.Fatalf("failed to generate a master key: %v", err)
+	}
+	kms := map[string]map[string]any{"local": {"key": masterKey}}
+	const keyVault = "database_encryption.__keyVault"
+
+	// A bypassing client creates the data key the schema map refers to
+	keys, err := New(NewMongoOptions().SetUri(mongodbUri).SetTimeout(5000).
+		SetAutoEncryption(kms, keyVault, nil, true).Build())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer keys.Client.Close(ctx)
+	keyID, err := keys.Current().(*MongoClient).CreateDataKey(ctx, "local", nil)
+	if err != nil {
+		t.Fatalf("failed to create a data key: %v", err)
+	}
+
+	schema := map[string]any{"database_encryption.people": bson.M{
+		"bsonType": "object",
+		"properties": bson.M{"email": bson.M{"encrypt": bson.M{
+			"keyId":     bson.A{keyID},
+			"bsonType":  "string",
+			"algorithm": "AEAD_AES_256_CBC_HMAC_SHA_512-Deterministic",
+		}}},
+	}}
+	encrypted, err := New(NewMongoOptions().SetUri(mongodbUri).SetTimeout(5000).
+		SetAutoEncryption(kms, keyVault, schema, false).Build())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer encrypted.Client.Close(ctx)
+	encrypted.Client.DeleteMany(ctx, "database_encryption", "people", bson.M{})
+
+	if _, err := encrypted.Client.InsertOne(ctx, "database_encryption", "people", bson.M{"_id": 1, "email": "jo@example.com"}); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	doc, err := encrypted.Client.FindOne(ctx, "database_encryption", "people", bson.M{"email": "jo@example.com"})
+	if err != nil || doc.(bson.M)["email"] != "jo@example.com" {
+		t.Errorf("expected the field decrypted, got %v, %v", doc, err)
+	}
+
+	plain, err := New(NewMongoOptions().SetUri(mongodbUri).SetTimeout(5000).Build())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer plain.Client.Close(ctx)
+	raw, err := plain.Client.FindOne(ctx, "database_encryption", "people", bson.M{"_id": 1})
+	if bin, ok := raw.(bson.M)["email"].(primitive.Binary); err != nil || !ok || bin.Subtype != 6 {
+		t.Errorf("expected the stored field encrypted, got %v, %v", raw, err)
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAutoEncryptionValidate(t *testing.T) {
+	local := map[string]map[string]any{"local": {"key": make([]byte, 96)}}
+
+	tests := []struct {
+		name    string
+		opts    *AutoEncryptionOptions
+		wantErr string
+	}{
+		{"Nil", nil, ""},
+		{"Valid", &AutoEncryptionOptions{KmsProviders: local, KeyVaultNamespace: "encryption.__keyVault"}, ""},
+		{"NamedProvider", &AutoEncryptionOptions{KmsProviders: map[string]map[string]any{"aws:eu": {}}, KeyVaultNamespace: "encryption.__keyVault"}, ""},
+		{"MissingNamespace", &AutoEncryptionOptions{KmsProviders: local}, "key vault namespace is required"},
+		{"MalformedNamespace", &AutoEncryptionOptions{KmsProviders: local, KeyVaultNamespace: "keyVault"}, "must be \"db.collection\""},
+		{"NoProviders", &AutoEncryptionOptions{KeyVaultNamespace: "encryption.__keyVault"}, "at least one KMS provider"},
+		{"UnknownProvider", &AutoEncryptionOptions{KmsProviders: map[string]map[string]any{"vault": {}}, KeyVaultNamespace: "encryption.__keyVault"}, "unknown KMS provider \"vault\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSetAutoEncryption(t *testing.T) {
+	kms := map[string]map[string]any{"local": {"key": make([]byte, 96)}}
+	schema := map[string]any{"vault.people": map[string]any{"bsonType": "object"}}
+
+	builders := map[string]*MongoOptionsBuilder{
+		"URI":        NewMongoOptions().SetUri("mongodb://localhost:27017"),
+		"Components": NewMongoOptions().SetHost("localhost:27017").SetUsername("u").SetPassword("p").SetAuthSource("admin"),
+	}
+
+	for name := range builders {
+		t.Run(name, func(t *testing.T) {
+			opts := builders[name].SetTimeout(1000).SetAutoEncryption(kms, "encryption.__keyVault", schema, true).Build()
+
+			clientOpts, err := buildClientOptions(opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := clientOpts.AutoEncryptionOptions
+			if got == nil {
+				t.Fatal("expected auto encryption options on the client")
+			}
+			if got.KeyVaultNamespace != "encryption.__keyVault" || got.KmsProviders["local"] == nil ||
+				got.SchemaMap["vault.people"] == nil || got.BypassAutoEncryption == nil || !*got.BypassAutoEncryption {
+				t.Errorf("expected the configured options, got %+v", got)
+			}
+		})
+
+		t.Run(name+"Without", func(t *testing.T) {
+			opts := builders[name].Build()
+			opts.AutoEncryption = nil
+			clientOpts, err := buildClientOptions(opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := clientOpts.AutoEncryptionOptions; got != nil {
+				t.Errorf("expected no auto encryption, got %+v", got)
+			}
+		})
+	}
+
+	t.Run("NewRejectsBeforeConnecting", func(t *testing.T) {
+		opts := NewMongoOptions().SetUri("mongodb://localhost:27017").SetTimeout(1000).
+			SetAutoEncryption(map[string]map[string]any{"hsm": {}}, "encryption.__keyVault", nil, false).Build()
+		if _, err := New(opts); err == nil || !strings.Contains(err.Error(), "unknown KMS provider") {
+			t.Errorf("expected an unknown provider error, got %v", err)
+		}
+		if _, err := NewMongoClient(opts); err == nil {
+			t.Error("expected NewMongoClient to validate too")
+		}
+	})
+
+	t.Run("CreateDataKeyNotConfigured", func(t *testing.T) {
+		client := &MongoClient{Options: NewMongoOptions().Build()}
+		if _, err := client.CreateDataKey(context.Background(), "local", nil); err == nil {
+			t.Error("expected an error without auto encryption")
+		}
+		client.Options.AutoEncryption = &AutoEncryptionOptions{KmsProviders: kms, KeyVaultNamespace: "encryption.__keyVault"}
+		if _, err := client.CreateDataKey(context.Background(), "aws", nil); err == nil {
+			t.Error("expected an error for an unconfigured provider")
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrDuplicateKey is returned when a write violates a unique index. The real
+// client wraps the driver error with it and the fake returns it directly, so
+// errors.Is(err, ErrDuplicateKey) works against both.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// ErrNotFound is returned by KVStore.Get when the key is missing or expired
+var ErrNotFound = errors.New("not found")
+
+// ErrClientClosed is returned by operations on a client after Close. The real
+// client wraps mongo.ErrClientDisconnected with it, and the mock returns it
+// when FailAfterClose is enabled.
+var ErrClientClosed = errors.New("client is closed")
+
+// ErrUnauthorized is returned when the server rejects a command because the
+// user lacks the privileges or failed to authenticate. The real client wraps
+// the driver error with it.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrThrottled is returned when Azure Cosmos DB rejects an operation because
+// the request rate exceeds the provisioned throughput. The real client wraps
+// the driver error with it; RetryAfter returns the delay the server asks for.
+var ErrThrottled = errors.New("request rate too large")
+
+// ErrViewNotSupported is returned by writes and index creation on a view,
+// which is read-only. The real client wraps the server error with it and the
+// fake returns it directly.
+var ErrViewNotSupported = errors.New("operation not supported on a view")
+
+// Server error codes for rejected credentials and missing privileges, the
+// code Cosmos DB throttles with, the code of writes to a view, and the write
+// error codes the fake reports
+const (
+	codeBadValue                  = 2
+	codeUnauthorized              = 13
+	codeAuthenticationFailed      = 18
+	codeCommandNotSupportedOnView = 166
+	codeDuplicateKey              = 11000
+	codeRequestRateTooLarge       = 16500
+)
+
+// mapError wraps driver errors with the package errors they correspond to,
+// keeping the original error in the chain
+func mapError(err error) error {
+	var me mongo.MarshalError
+	switch {
+	case err == nil:
+		return nil
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %w", ErrDuplicateKey, err)
+	case errors.Is(err, mongo.ErrClientDisconnected):
+		return fmt.Errorf("%w: %w", ErrClientClosed, err)
+	case isServerErrorCode(err, codeUnauthorized, codeAuthenticationFailed):
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case isServerErrorCode(err, codeRequestRateTooLarge):
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	case isServerErrorCode(err, codeCommandNotSupportedOnView):
+		return fmt.Errorf("%w: %w", ErrViewNotSupported, err)
+	case errors.As(err, &me):
+		return marshalError{me}
+	}
+	return err
+}
+
+// marshalError keeps the error of a MarshalBSON method, such as the one of an
+// invalid ByID filter, in the chain of the driver's MarshalError
+type marshalError struct {
+	mongo.MarshalError
+}
+
+func (e marshalError) Unwrap() []error {
+	return []error{e.MarshalError, e.Err}
+}
+
+func isServerErrorCode(err error, codes ...int) bool {
+	var se mongo.ServerError
+	if !errors.As(err, &se) {
+		return false
+	}
+	for _, code := range codes {
+		if se.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDuplicateKey reports whether err is a unique index violation, from the
+// real client, the fake or the mock
+func IsDuplicateKey(err error) bool {
+	return errors.Is(err, ErrDuplicateKey) || mongo.IsDuplicateKeyError(err)
+}
+
+// IsTransient reports whether err is a network error, a throttled request
+// or carries a label saying the operation can be retried
+func IsTransient(err error) bool {
+	if mongo.IsNetworkError(err) || IsThrottled(err) {
+		return true
+	}
+	var le mongo.LabeledError
+	return errors.As(err, &le) &&
+		(le.HasErrorLabel("TransientTransactionError") || le.HasErrorLabel("RetryableWriteError"))
+}
+
+// IsTimeout reports whether err was caused by a client or server timeout
+func IsTimeout(err error) bool {
+	return mongo.IsTimeout(err)
+}
+
+// IsUnauthorized reports whether err is an authentication or authorization
+// failure
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized) || isServerErrorCode(err, codeUnauthorized, codeAuthenticationFailed)
+}
+
+// IsThrottled reports whether err is a Cosmos DB request rate error
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrThrottled) || isServerErrorCode(err, codeRequestRateTooLarge)
+}
+
+var retryAfterPattern = regexp.MustCompile(`RetryAfterMs=(\d+)`)
+
+// RetryAfter returns how long the server asked to wait before retrying a
+// throttled operation, from the RetryAfterMs Cosmos DB puts in its message
+func RetryAfter(err error) (time.Duration, bool) {
+	if !IsThrottled(err) {
+		return 0, false
+	}
+	m := retryAfterPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, false
+	}
+	ms, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMapError(t *testing.T) {
+	dup := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+
+	err := mapError(dup)
+	var we mongo.WriteException
+	if !errors.Is(err, ErrDuplicateKey) || !errors.As(err, &we) {
+		t.Errorf("expected ErrDuplicateKey wrapping the driver error, got %v", err)
+	}
+
+	closed := mapError(mongo.ErrClientDisconnected)
+	if !errors.Is(closed, ErrClientClosed) || !errors.Is(closed, mongo.ErrClientDisconnected) {
+		t.Errorf("expected ErrClientClosed wrapping the driver error, got %v", closed)
+	}
+
+	if mapError(mongo.ErrNoDocuments) != mongo.ErrNoDocuments {
+		t.Error("expected ErrNoDocuments to pass through unchanged")
+	}
+
+	marshal := mapError(mongo.MarshalError{Value: ByID("zz"), Err: ByID("zz").Err()})
+	var me mongo.MarshalError
+	if !errors.Is(marshal, ErrInvalidID) || !errors.As(marshal, &me) {
+		t.Errorf("expected the MarshalBSON error kept in the chain, got %v", marshal)
+	}
+
+	view := mapError(mongo.CommandError{Code: 166, Message: "Namespace vault.camera_status is a view, not a collection"})
+	var ce mongo.CommandError
+	if !errors.Is(view, ErrViewNotSupported) || !errors.As(view, &ce) {
+		t.Errorf("expected ErrViewNotSupported wrapping the driver error, got %v", view)
+	}
+
+	other := errors.New("boom")
+	if mapError(other) != other {
+		t.Error("expected other errors to pass through")
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name                                        string
+		err                                         error
+		duplicate, transient, timeout, unauthorized bool
+	}{
+		{name: "DuplicateKey", err: mapError(mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}), duplicate: true},
+		{name: "FakeDuplicateKey", err: ErrDuplicateKey, duplicate: true},
+		{name: "Network", err: mongo.CommandError{Labels: []string{"NetworkError"}}, transient: true},
+		{name: "RetryableWrite", err: mongo.CommandError{Code: 91, Labels: []string{"RetryableWriteError"}}, transient: true},
+		{name: "Deadline", err: context.DeadlineExceeded, timeout: true},
+		{name: "MaxTimeMS", err: mongo.CommandError{Code: 50}, timeout: true},
+		{name: "Unauthorized", err: mapError(mongo.CommandError{Code: 13}), unauthorized: true},
+		{name: "AuthenticationFailed", err: mapError(mongo.CommandError{Code: 18}), unauthorized: true},
+		{name: "Other", err: errors.New("boom")},
+		{name: "Nil", err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if IsDuplicateKey(tt.err) != tt.duplicate {
+				t.Errorf("IsDuplicateKey(%v) = %v", tt.err, !tt.duplicate)
+			}
+			if IsTransient(tt.err) != tt.transient {
+				t.Errorf("IsTransient(%v) = %v", tt.err, !tt.transient)
+			}
+			if IsTimeout(tt.err) != tt.timeout {
+				t.Errorf("IsTimeout(%v) = %v", tt.err, !tt.timeout)
+			}
+			if IsUnauthorized(tt.err) != tt.unauthorized {
+				t.Errorf("IsUnauthorized(%v) = %v", tt.err, !tt.unauthorized)
+			}
+		})
+	}
+
+	if unauthorized := mapError(mongo.CommandError{Code: 13}); !errors.Is(unauthorized, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized wrapping the driver error, got %v", unauthorized)
+	}
+}
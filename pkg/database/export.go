@@ -0,0 +1,136 @@
+package database
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportOptions configures Export
+type ExportOptions struct {
+	// Projection limits the exported fields, as for Find
+	Projection any
+	// Sort orders the exported documents, as for Find
+	Sort any
+	// Limit exports at most this many documents; zero exports all of them
+	Limit int64
+	// Gzip compresses the output stream
+	Gzip bool
+	// Canonical writes canonical Extended JSON, which keeps the exact type
+	// of every number, instead of the more readable relaxed form. Both keep
+	// ObjectIDs, dates and binary values.
+	Canonical bool
+	// Mask strips, hashes, redacts or truncates fields of every document
+	// before it is written
+	Mask *MaskSpec
+}
+
+// ExportReport describes an export
+type ExportReport struct {
+	// Documents is the number of documents written
+	Documents int64
+	// Bytes is the number of bytes written to the writer, after compression
+	Bytes    int64
+	Duration time.Duration
+	// Partial is true when the export stopped early, on an error or because
+	// the context ended; the output holds the documents counted, each on a
+	// complete line
+	Partial bool
+}
+
+// Export writes the documents matching filter to w as Extended JSON Lines,
+// one document per line, reading them through a cursor so memory stays
+// bounded however many documents match. It stops when ctx ends, returning
+// the context's error together with a report marked Partial; a gzip stream
+// is still closed properly then. Import, or mongoimport, reads the output
+// back.
+//
+//	f, _ := os.Create("customer-events.jsonl.gz")
+//	defer f.Close()
+//	report, err := db.Export(ctx, "vault", "events", bson.M{"customer": id}, f, database.ExportOptions{Gzip: true})
+func (d *Database) Export(ctx context.Context, db string, collection string, filter any, w io.Writer, opts ExportOptions) (*ExportReport, error) {
+	start := time.Now()
+	masker, err := newMasker(opts.Mask)
+	if err != nil {
+		return nil, fmt.Errorf("export %s.%s: %w", db, collection, err)
+	}
+	report := &ExportReport{}
+	counted := &countingWriter{w: w}
+	var out io.Writer = counted
+	var zw *gzip.Writer
+	if opts.Gzip {
+		zw = gzip.NewWriter(counted)
+		out = zw
+	}
+	buf := bufio.NewWriter(out)
+
+	find := moptions.Find()
+	if opts.Projection != nil {
+		find.SetProjection(opts.Projection)
+	}
+	if opts.Sort != nil {
+		find.SetSort(opts.Sort)
+	}
+	if opts.Limit > 0 {
+		find.SetLimit(opts.Limit)
+	}
+	err = FindEachAs(ctx, d, db, collection, orEmpty(filter), func(doc bson.D) error {
+		if masker != nil {
+			var err error
+			if doc, err = masker.apply(doc); err != nil {
+				return err
+			}
+		}
+		line, err := bson.MarshalExtJSON(doc, opts.Canonical, false)
+		if err != nil {
+			return err
+		}
+		if _, err := buf.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		report.Documents++
+		return nil
+	}, find)
+
+	// Flush what was written even after an error, so the output ends with
+	// the last complete line
+	if ferr := buf.Flush(); err == nil {
+		err = ferr
+	}
+	if zw != nil {
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	report.Bytes = counted.n
+	report.Duration = time.Since(start)
+	if err != nil {
+		report.Partial = true
+		return report, fmt.Errorf("export %s.%s: %w", db, collection, err)
+	}
+	if masker != nil && opts.Mask.Strict {
+		if paths := masker.unmatched(); len(paths) > 0 {
+			return report, fmt.Errorf("export %s.%s: %w: %s", db, collection, ErrMaskUnmatched, strings.Join(paths, ", "))
+		}
+	}
+	return report, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
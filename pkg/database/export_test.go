@@ -0,0 +1,158 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	ids := make([]primitive.ObjectID, 5)
+	fake := NewFakeDatabase()
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+		err := fake.Seed("vault", "events", bson.M{
+			"_id":       ids[i],
+			"customer":  "acme",
+			"order":     i,
+			"timestamp": start.Add(time.Duration(i) * time.Minute),
+			"camera":    bson.M{"id": primitive.NewObjectID(), "name": "gate"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.Seed("vault", "events", bson.M{"customer": "other"})
+	db := &Database{Client: fake}
+
+	// lines parses the Extended JSON lines of an export
+	lines := func(t *testing.T, data []byte) []bson.M {
+		t.Helper()
+		var docs []bson.M
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var doc bson.M
+			if err := bson.UnmarshalExtJSON(scanner.Bytes(), false, &doc); err != nil {
+				t.Fatalf("line %d is not Extended JSON: %v", len(docs)+1, err)
+			}
+			docs = append(docs, doc)
+		}
+		return docs
+	}
+
+	t.Run("Types", func(t *testing.T) {
+		var out bytes.Buffer
+		report, err := db.Export(ctx, "vault", "events", bson.M{"customer": "acme"}, &out, ExportOptions{Sort: bson.M{"order": 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := lines(t, out.Bytes())
+		if report.Documents != 5 || len(docs) != 5 || report.Bytes != int64(out.Len()) || report.Partial {
+			t.Fatalf("expected 5 documents in %d bytes, got %+v and %d lines", out.Len(), report, len(docs))
+		}
+		for i, doc := range docs {
+			if doc["_id"] != ids[i] {
+				t.Errorf("expected the ObjectID %v to survive, got %#v", ids[i], doc["_id"])
+			}
+			if ts, ok := doc["timestamp"].(primitive.DateTime); !ok || !ts.Time().Equal(start.Add(time.Duration(i)*time.Minute)) {
+				t.Errorf("expected the date to survive, got %#v", doc["timestamp"])
+			}
+			if _, ok := doc["camera"].(bson.M)["id"].(primitive.ObjectID); !ok {
+				t.Errorf("expected the nested ObjectID to survive, got %#v", doc["camera"])
+			}
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		var out bytes.Buffer
+		report, err := db.Export(ctx, "vault", "events", bson.M{"customer": "acme"}, &out, ExportOptions{
+			Projection: bson.M{"order": 1},
+			Sort:       bson.M{"order": -1},
+			Limit:      2,
+			Canonical:  true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := lines(t, out.Bytes())
+		if report.Documents != 2 || len(docs) != 2 || docs[0]["order"] != int32(4) || docs[0]["timestamp"] != nil {
+			t.Errorf("expected orders 4 and 3 with only _id and order, got %v", docs)
+		}
+		if !strings.Contains(out.String(), `"$numberInt"`) {
+			t.Errorf("expected canonical Extended JSON, got %s", out.String())
+		}
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		var out bytes.Buffer
+		report, err := db.Export(ctx, "vault", "events", nil, &out, ExportOptions{Gzip: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Bytes != int64(out.Len()) {
+			t.Errorf("expected the compressed size %d, got %d", out.Len(), report.Bytes)
+		}
+		zr, err := gzip.NewReader(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var plain bytes.Buffer
+		if _, err := plain.ReadFrom(zr); err != nil {
+			t.Fatal(err)
+		}
+		if docs := lines(t, plain.Bytes()); len(docs) != 6 || report.Documents != 6 {
+			t.Errorf("expected all 6 documents, got %d lines and %+v", len(docs), report)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		mock := NewMockDatabase()
+		mock.FindCursorFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+			return &cancelCursor{Cursor: &streamCursor{n: 100000}, after: 10, cancel: cancel}, nil
+		}
+		var out bytes.Buffer
+		report, err := (&Database{Client: mock}).Export(ctx, "vault", "events", nil, &out, ExportOptions{Gzip: true})
+		if !errors.Is(err, context.Canceled) || !report.Partial || report.Documents != 10 {
+			t.Fatalf("expected a partial export of 10 documents, got %+v, %v", report, err)
+		}
+		zr, err := gzip.NewReader(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var plain bytes.Buffer
+		if _, err := plain.ReadFrom(zr); err != nil {
+			t.Fatalf("expected a complete gzip stream, got %v", err)
+		}
+		if docs := lines(t, plain.Bytes()); len(docs) != 10 {
+			t.Errorf("expected 10 complete lines, got %d", len(docs))
+		}
+	})
+}
+
+// cancelCursor cancels a context once it has read after documents
+type cancelCursor struct {
+	Cursor
+	after  int
+	read   int
+	cancel context.CancelFunc
+}
+
+func (c *cancelCursor) Next(ctx context.Context) bool {
+	ok := c.Cursor.Next(ctx)
+	if c.read++; c.read == c.after {
+		c.cancel()
+	}
+	return ok
+}
@@ -0,0 +1,336 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FakeDatabase is an in-memory implementation of DatabaseInterface for tests
+// that need real filter semantics rather than scripted responses. Documents
+// are stored after a BSON round trip, so dates come back as primitive.DateTime
+// and documents as bson.M, the same shapes the driver decodes into any.
+type FakeDatabase struct {
+	mu          sync.RWMutex
+	collections map[fakeNamespace][]map[string]any
+	indexes     map[fakeNamespace][]uniqueIndex
+	ttls        map[fakeNamespace][]ttlIndex
+	schemas     map[fakeNamespace]SchemaValidation
+	views       map[fakeNamespace]fakeView
+	clock       Clock
+	closed      atomic.Bool
+
+	// tx serializes transactions, see WithTransaction
+	tx sync.Mutex
+	// random picks the documents of $sample, see SetSeed; randomMu guards it
+	// since reads share f.mu
+	randomMu sync.Mutex
+	random   *rand.Rand
+}
+
+var (
+	_ DatabaseInterface = (*FakeDatabase)(nil)
+	_ EstimatedCounter  = (*FakeDatabase)(nil)
+	_ Indexer           = (*FakeDatabase)(nil)
+	_ SchemaManager     = (*FakeDatabase)(nil)
+	_ Transactor        = (*FakeDatabase)(nil)
+	_ ViewManager       = (*FakeDatabase)(nil)
+)
+
+type fakeNamespace struct {
+	db         string
+	collection string
+}
+
+// NewFakeDatabase creates an empty in-memory database
+func NewFakeDatabase() *FakeDatabase {
+	return &FakeDatabase{
+		collections: make(map[fakeNamespace][]map[string]any),
+		indexes:     make(map[fakeNamespace][]uniqueIndex),
+		ttls:        make(map[fakeNamespace][]ttlIndex),
+		schemas:     make(map[fakeNamespace]SchemaValidation),
+		views:       make(map[fakeNamespace]fakeView),
+		clock:       systemClock{},
+		random:      rand.New(rand.NewSource(defaultFakeSeed)),
+	}
+}
+
+// Seed stores documents in db.collection, generating an ObjectID _id for
+// documents that have none
+func (f *FakeDatabase) Seed(db string, collection string, docs ...any) error {
+	stored := make([]map[string]any, 0, len(docs))
+	for i, doc := range docs {
+		m, err := fakeDocument(doc)
+		if err != nil {
+			return fmt.Errorf("fake: document %d: %w", i, err)
+		}
+		stored = append(stored, m)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := fakeNamespace{db, collection}
+	for i, doc := range stored {
+		if err := f.checkUnique(ns, doc, -1); err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		f.collections[ns] = append(f.collections[ns], doc)
+	}
+	return nil
+}
+
+// Documents returns a copy of every document stored in db.collection in
+// insertion order
+func (f *FakeDatabase) Documents(db string, collection string) []bson.M {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	stored := f.collections[fakeNamespace{db, collection}]
+	out := make([]bson.M, len(stored))
+	for i, doc := range stored {
+		out[i] = toBSON(doc).(bson.M)
+	}
+	return out
+}
+
+// Reset removes every stored document; indexes are kept
+func (f *FakeDatabase) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.collections = make(map[fakeNamespace][]map[string]any)
+}
+
+// Ping always succeeds unless the context is done or the fake is closed
+func (f *FakeDatabase) Ping(ctx context.Context) error {
+	return f.ready(ctx)
+}
+
+// Close makes every later operation fail with ErrClientClosed, as the real
+// client does. Closing twice is not an error, and the stored documents stay
+// available through Documents and DumpFixtures.
+func (f *FakeDatabase) Close(ctx context.Context) error {
+	f.closed.Store(true)
+	return nil
+}
+
+// ready returns the error an operation fails with before touching the store
+func (f *FakeDatabase) ready(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if f.closed.Load() {
+		return ErrClientClosed
+	}
+	_, err := callOptions(ctx)
+	return err
+}
+
+// Find returns every document in db.collection matching the filter as a []any
+// of bson.M, honouring the sort, skip, limit and projection of FindOptions
+func (f *FakeDatabase) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	matches, err := f.find(db, collection, filter, findSpecOf(opts))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]any, len(matches))
+	for i, doc := range matches {
+		results[i] = toBSON(doc)
+	}
+	return results, nil
+}
+
+// FindOne returns the first document matching the filter or mongo.ErrNoDocuments
+func (f *FakeDatabase) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	spec := findSpecOf(opts)
+	spec.limit = 1
+	matches, err := f.find(db, collection, filter, spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return toBSON(matches[0]), nil
+}
+
+// FindCursor returns a cursor over the documents matching the filter. The
+// documents are not copied as for Find: stored documents are replaced rather
+// than modified, and the cursor only reads them to decode.
+func (f *FakeDatabase) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	matches, err := f.find(db, collection, filter, findSpecOf(opts))
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]any, len(matches))
+	for i, doc := range matches {
+		docs[i] = doc
+	}
+	return newSliceCursor(docs, nil), nil
+}
+
+// Count returns the number of documents matching the filter, honouring the
+// Skip and Limit of CountOptions
+func (f *FakeDatabase) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	if err := f.ready(ctx); err != nil {
+		return 0, err
+	}
+
+	var spec findSpec
+	for _, o := range optionsOf[moptions.CountOptions](opts) {
+		if o.Skip != nil {
+			spec.skip = *o.Skip
+		}
+		if o.Limit != nil {
+			spec.limit = *o.Limit
+		}
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	matches, err := f.find(db, collection, filter, spec)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(matches)), nil
+}
+
+// Distinct returns the distinct values of field across the documents matching
+// the filter, in the order first seen. As in MongoDB, the elements of an array
+// value count individually and documents missing the field are skipped.
+func (f *FakeDatabase) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	matches, err := f.find(db, collection, filter, findSpec{})
+	if err != nil {
+		return nil, err
+	}
+	values := []any{}
+	for _, doc := range matches {
+		found, _ := resolvePath(doc, field)
+		for _, value := range found {
+			elems := []any{value}
+			if arr, ok := value.([]any); ok {
+				elems = arr
+			}
+			for _, elem := range elems {
+				if !slices.ContainsFunc(values, func(v any) bool { return valuesEqual(v, elem) }) {
+					values = append(values, elem)
+				}
+			}
+		}
+	}
+	for i, value := range values {
+		values[i] = toBSON(value)
+	}
+	return values, nil
+}
+
+// find returns the documents matching filter shaped by spec; the caller holds f.mu
+func (f *FakeDatabase) find(db string, collection string, filter any, spec findSpec) ([]map[string]any, error) {
+	ns := fakeNamespace{db, collection}
+	if _, ok := f.views[ns]; ok {
+		return f.findView(ns, filter, spec)
+	}
+	indexes, err := f.matchIndexes(ns, filter)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]map[string]any, len(indexes))
+	for i, idx := range indexes {
+		matches[i] = f.collections[ns][idx]
+	}
+	if matches, err = scoreText(matches, filter); err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	shaped, err := spec.apply(matches)
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	return shaped, nil
+}
+
+// namespaces returns the stored namespaces in sorted order; the caller holds f.mu
+func (f *FakeDatabase) namespaces() []fakeNamespace {
+	out := make([]fakeNamespace, 0, len(f.collections))
+	for ns := range f.collections {
+		out = append(out, ns)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].db != out[j].db {
+			return out[i].db < out[j].db
+		}
+		return out[i].collection < out[j].collection
+	})
+	return out
+}
+
+// fakeDocument converts doc into its stored form via a BSON round trip and
+// assigns an _id when missing
+func fakeDocument(doc any) (map[string]any, error) {
+	stored, err := storedDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := stored["_id"]; !ok {
+		stored["_id"] = primitive.NewObjectID()
+	}
+	return stored, nil
+}
+
+// toBSON deep-copies a stored value into bson.M and bson.A so callers can
+// modify results without touching the store
+func toBSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(bson.M, len(t))
+		for k, val := range t {
+			out[k] = toBSON(val)
+		}
+		return out
+	case []any:
+		out := make(bson.A, len(t))
+		for i, val := range t {
+			out[i] = toBSON(val)
+		}
+		return out
+	}
+	return v
+}
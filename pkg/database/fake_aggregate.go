@@ -0,0 +1,574 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultFakeSeed seeds the random source of a new fake, so $sample picks the
+// same documents on every run
+const defaultFakeSeed = 1
+
+// SetSeed reseeds the random source $sample picks documents with. A new fake
+// is seeded with the same value every time, so sampling is repeatable.
+func (f *FakeDatabase) SetSeed(seed int64) {
+	f.randomMu.Lock()
+	defer f.randomMu.Unlock()
+
+	f.random = rand.New(rand.NewSource(seed))
+}
+
+// shuffle returns a copy of docs in random order
+func (f *FakeDatabase) shuffle(docs []map[string]any) []map[string]any {
+	f.randomMu.Lock()
+	defer f.randomMu.Unlock()
+
+	out := slices.Clone(docs)
+	f.random.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// aggregateStage transforms the documents flowing through a pipeline. Stages
+// return new slices and never modify the stored documents in place.
+type aggregateStage func(docs []map[string]any, arg any) ([]map[string]any, error)
+
+// aggregateStages lists the pipeline stages the fake supports
+var aggregateStages = map[string]aggregateStage{
+	"$match":   aggregateMatch,
+	"$project": aggregateProject,
+	"$sort":    aggregateSort,
+	"$skip":    aggregateSkip,
+	"$limit":   aggregateLimit,
+	"$count":   aggregateCount,
+	"$group":   aggregateGroup,
+	"$unwind":  aggregateUnwind,
+}
+
+// Aggregate runs pipeline over db.collection. It supports $match, $project
+// (field selection and "$field" references), $sort, $skip, $limit, $count,
+// $group (with $sum, $avg, $min, $max and $first), $unwind, equality
+// $lookup with localField and foreignField, and $sample, which picks
+// documents with the fake's seeded random source, see SetSeed; any other
+// stage returns an error naming it.
+func (f *FakeDatabase) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	docs, err := f.source(fakeNamespace{db, collection})
+	if err != nil {
+		return nil, err
+	}
+	docs, err = runPipeline(docs, pipeline, pipelineEnv{
+		from: func(from string) ([]map[string]any, error) {
+			return f.source(fakeNamespace{db, from})
+		},
+		shuffle: f.shuffle,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	out := make([]any, len(docs))
+	for i, doc := range docs {
+		out[i] = toBSON(doc)
+	}
+	return out, nil
+}
+
+// pipelineEnv provides the stages that need more than their input documents
+type pipelineEnv struct {
+	// from returns the documents of another collection of the same database
+	// for $lookup
+	from func(collection string) ([]map[string]any, error)
+	// shuffle returns docs in random order for $sample
+	shuffle func(docs []map[string]any) []map[string]any
+}
+
+// runPipeline passes docs through each stage of pipeline in order
+func runPipeline(docs []map[string]any, pipeline any, env pipelineEnv) ([]map[string]any, error) {
+	stages, err := pipelineStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	for i, stage := range stages {
+		name, arg, err := stageOf(stage)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %d: %w", i, err)
+		}
+		var run aggregateStage
+		switch name {
+		case "$lookup":
+			run = func(docs []map[string]any, arg any) ([]map[string]any, error) {
+				return aggregateLookup(docs, arg, env.from)
+			}
+		case "$sample":
+			run = func(docs []map[string]any, arg any) ([]map[string]any, error) {
+				return aggregateSample(docs, arg, env.shuffle)
+			}
+		default:
+			var ok bool
+			if run, ok = aggregateStages[name]; !ok {
+				return nil, fmt.Errorf("stage %s not supported by fake", name)
+			}
+		}
+		if docs, err = run(docs, arg); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return docs, nil
+}
+
+// pipelineStages accepts mongo.Pipeline, bson.A or any other slice of stages
+func pipelineStages(pipeline any) ([]any, error) {
+	rv := reflect.ValueOf(pipeline)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("pipeline must be an array of stages, got %T", pipeline)
+	}
+	stages := make([]any, rv.Len())
+	for i := range stages {
+		stages[i] = rv.Index(i).Interface()
+	}
+	return stages, nil
+}
+
+// stageOf splits a single-key stage document into its name and argument.
+// bson.D arguments are passed on as-is so $sort keeps its key order.
+func stageOf(stage any) (string, any, error) {
+	if d, ok := stage.(bson.D); ok {
+		if len(d) != 1 {
+			return "", nil, fmt.Errorf("stage must have exactly one field, got %d", len(d))
+		}
+		return d[0].Key, d[0].Value, nil
+	}
+	m, ok := normalizeDocument(stage).(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", nil, fmt.Errorf("stage must be a document with exactly one field, got %v", stage)
+	}
+	for name, arg := range m {
+		return name, arg, nil
+	}
+	return "", nil, nil
+}
+
+// aggregateMatch keeps the documents matching a query filter
+func aggregateMatch(docs []map[string]any, arg any) ([]map[string]any, error) {
+	var out []map[string]any
+	for _, doc := range docs {
+		ok, err := matchesFilter(doc, arg)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
+
+// aggregateProject selects fields like a find projection. A "$field" value
+// sets the output field to another field's value, which covers renames.
+func aggregateProject(docs []map[string]any, arg any) ([]map[string]any, error) {
+	spec, ok := normalizeDocument(arg).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("needs a document, got %T", arg)
+	}
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("needs at least one field")
+	}
+
+	references := map[string]any{}
+	selection := map[string]any{}
+	for field, v := range spec {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, "$") {
+			references[field] = s[1:]
+			continue
+		}
+		if _, ok := projectionFlag(v); !ok {
+			return nil, fmt.Errorf("unsupported expression for %s: %v", field, v)
+		}
+		selection[field] = v
+	}
+
+	out := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		if len(references) == 0 {
+			projected, err := projectDocument(doc, selection)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = projected
+			continue
+		}
+
+		// References make this an inclusion projection
+		projected := map[string]any{}
+		for _, field := range sortedKeys(selection) {
+			on, _ := projectionFlag(selection[field])
+			if field == "_id" {
+				continue
+			}
+			if !on {
+				return nil, fmt.Errorf("cannot exclude %s while computing fields", field)
+			}
+			if v, ok := getPath(doc, field); ok {
+				if err := setPath(projected, field, copyValue(v)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if on, ok := projectionFlag(selection["_id"]); !ok || on {
+			if id, ok := doc["_id"]; ok {
+				projected["_id"] = id
+			}
+		}
+		for _, field := range sortedKeys(references) {
+			if v, ok := getPath(doc, references[field].(string)); ok {
+				if err := setPath(projected, field, copyValue(v)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		out[i] = projected
+	}
+	return out, nil
+}
+
+// aggregateSort orders documents the same way a find sort does
+func aggregateSort(docs []map[string]any, arg any) ([]map[string]any, error) {
+	return sortDocuments(docs, arg)
+}
+
+// aggregateSkip drops the first n documents
+func aggregateSkip(docs []map[string]any, arg any) ([]map[string]any, error) {
+	n, err := stageCount(arg)
+	if err != nil {
+		return nil, err
+	}
+	if n >= len(docs) {
+		return nil, nil
+	}
+	return docs[n:], nil
+}
+
+// aggregateLimit keeps the first n documents
+func aggregateLimit(docs []map[string]any, arg any) ([]map[string]any, error) {
+	n, err := stageCount(arg)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	if n < len(docs) {
+		return docs[:n], nil
+	}
+	return docs, nil
+}
+
+func stageCount(arg any) (int, error) {
+	n, ok := toFloat(arg)
+	if !ok || n < 0 || n != float64(int(n)) {
+		return 0, fmt.Errorf("needs a non-negative integer, got %v", arg)
+	}
+	return int(n), nil
+}
+
+// aggregateCount replaces the documents with one holding their count. Like
+// MongoDB, it outputs nothing when there are no documents.
+func aggregateCount(docs []map[string]any, arg any) ([]map[string]any, error) {
+	field, ok := arg.(string)
+	if !ok || field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+		return nil, fmt.Errorf("needs a field name, got %v", arg)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	return []map[string]any{{field: int32(len(docs))}}, nil
+}
+
+// aggregateGroup groups documents by the _id expression and computes each
+// accumulator per group. Groups are output in the order they are first seen.
+func aggregateGroup(docs []map[string]any, arg any) ([]map[string]any, error) {
+	spec, ok := normalizeDocument(arg).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("needs a document, got %T", arg)
+	}
+	idExpr, ok := spec["_id"]
+	if !ok {
+		return nil, fmt.Errorf("needs an _id expression")
+	}
+	fields := make([]string, 0, len(spec))
+	for _, field := range sortedKeys(spec) {
+		if field == "_id" {
+			continue
+		}
+		acc, ok := spec[field].(map[string]any)
+		if !ok || len(acc) != 1 {
+			return nil, fmt.Errorf("%s must be a single accumulator", field)
+		}
+		for op := range acc {
+			switch op {
+			case "$sum", "$avg", "$min", "$max", "$first":
+			default:
+				return nil, fmt.Errorf("accumulator %s not supported by fake", op)
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	var keys []any
+	members := [][]map[string]any{}
+	for _, doc := range docs {
+		key, err := evalExpression(doc, idExpr)
+		if err != nil {
+			return nil, err
+		}
+		idx := -1
+		for i, k := range keys {
+			if valuesEqual(k, key) && typeOrder(k) == typeOrder(key) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			keys = append(keys, key)
+			members = append(members, nil)
+			idx = len(keys) - 1
+		}
+		members[idx] = append(members[idx], doc)
+	}
+
+	out := make([]map[string]any, len(keys))
+	for i, key := range keys {
+		group := map[string]any{"_id": copyValue(key)}
+		for _, field := range fields {
+			for op, expr := range spec[field].(map[string]any) {
+				v, err := accumulate(op, expr, members[i])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", field, err)
+				}
+				group[field] = v
+			}
+		}
+		out[i] = group
+	}
+	return out, nil
+}
+
+// accumulate computes one $group accumulator over the documents of a group.
+// $sum and $avg ignore non-numeric values, $min and $max ignore null and
+// missing values, as in MongoDB.
+func accumulate(op string, expr any, docs []map[string]any) (any, error) {
+	values := make([]any, 0, len(docs))
+	for _, doc := range docs {
+		v, err := evalExpression(doc, expr)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	switch op {
+	case "$sum", "$avg":
+		var sum any = int32(0)
+		n := 0
+		for _, v := range values {
+			if _, ok := toFloat(v); !ok {
+				continue
+			}
+			sum, _ = addNumbers(sum, v)
+			n++
+		}
+		if op == "$sum" {
+			return sum, nil
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		total, _ := toFloat(sum)
+		return total / float64(n), nil
+	case "$min", "$max":
+		var best any
+		for _, v := range values {
+			if v == nil {
+				continue
+			}
+			c := compareValues(v, best)
+			if best == nil || (op == "$min" && c < 0) || (op == "$max" && c > 0) {
+				best = v
+			}
+		}
+		return copyValue(best), nil
+	case "$first":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return copyValue(values[0]), nil
+	}
+	return nil, fmt.Errorf("accumulator %s not supported by fake", op)
+}
+
+// evalExpression evaluates the expressions $group understands: "$field"
+// references, documents of expressions and literal values
+func evalExpression(doc map[string]any, expr any) (any, error) {
+	switch t := expr.(type) {
+	case string:
+		if strings.HasPrefix(t, "$") {
+			v, _ := getPath(doc, t[1:])
+			return v, nil
+		}
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, sub := range t {
+			if strings.HasPrefix(k, "$") {
+				return nil, fmt.Errorf("expression operator %s not supported by fake", k)
+			}
+			v, err := evalExpression(doc, sub)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+	return expr, nil
+}
+
+// aggregateUnwind outputs one document per element of an array field. It
+// accepts a "$field" path or a document with path,
+// preserveNullAndEmptyArrays and includeArrayIndex.
+func aggregateUnwind(docs []map[string]any, arg any) ([]map[string]any, error) {
+	var path, indexField string
+	preserve := false
+	switch t := normalizeDocument(arg).(type) {
+	case string:
+		path = t
+	case map[string]any:
+		path, _ = t["path"].(string)
+		preserve, _ = t["preserveNullAndEmptyArrays"].(bool)
+		indexField, _ = t["includeArrayIndex"].(string)
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must be a \"$field\" reference, got %v", arg)
+	}
+	path = path[1:]
+
+	var out []map[string]any
+	for _, doc := range docs {
+		v, found := getPath(doc, path)
+		arr, isArray := v.([]any)
+		if !isArray {
+			// A non-array value is treated as a single element array
+			if (found && v != nil) || preserve {
+				unwound := copyDocument(doc)
+				if indexField != "" {
+					unwound[indexField] = nil
+				}
+				out = append(out, unwound)
+			}
+			continue
+		}
+		if len(arr) == 0 {
+			if preserve {
+				unwound := copyDocument(doc)
+				unsetPath(unwound, path)
+				if indexField != "" {
+					unwound[indexField] = nil
+				}
+				out = append(out, unwound)
+			}
+			continue
+		}
+		for i, elem := range arr {
+			unwound := copyDocument(doc)
+			if err := setPath(unwound, path, copyValue(elem)); err != nil {
+				return nil, err
+			}
+			if indexField != "" {
+				unwound[indexField] = int64(i)
+			}
+			out = append(out, unwound)
+		}
+	}
+	return out, nil
+}
+
+// aggregateLookup adds to each document the array of documents of another
+// collection whose foreignField equals its localField, with MongoDB's
+// equality semantics: arrays match any of their elements and a missing
+// field matches null. The let and pipeline forms are not supported.
+func aggregateLookup(docs []map[string]any, arg any, from func(collection string) ([]map[string]any, error)) ([]map[string]any, error) {
+	spec, ok := normalizeDocument(arg).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a document, got %v", arg)
+	}
+	if _, ok := spec["pipeline"]; ok {
+		return nil, fmt.Errorf("pipeline form not supported by fake")
+	}
+	fields := make(map[string]string, 4)
+	for _, key := range []string{"from", "localField", "foreignField", "as"} {
+		value, _ := spec[key].(string)
+		if value == "" {
+			return nil, fmt.Errorf("%s must be a non-empty string", key)
+		}
+		fields[key] = value
+	}
+	if from == nil {
+		return nil, fmt.Errorf("no collections to join")
+	}
+	foreign, err := from(fields["from"])
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		locals, found := resolvePath(doc, fields["localField"])
+		if !found {
+			locals = []any{nil}
+		}
+		locals = expandArrays(locals)
+		joined := []any{}
+		for _, candidate := range foreign {
+			values, found := resolvePath(candidate, fields["foreignField"])
+			for _, local := range locals {
+				if matchEquals(values, found, local) {
+					joined = append(joined, copyDocument(candidate))
+					break
+				}
+			}
+		}
+		out[i] = copyDocument(doc)
+		if err := setPath(out[i], fields["as"], joined); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// aggregateSample outputs size documents picked at random without
+// replacement, or every document in random order when there are fewer
+func aggregateSample(docs []map[string]any, arg any, shuffle func([]map[string]any) []map[string]any) ([]map[string]any, error) {
+	spec, ok := normalizeDocument(arg).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("argument must be a document, got %v", arg)
+	}
+	size, err := stageCount(spec["size"])
+	if err != nil {
+		return nil, fmt.Errorf("size %w", err)
+	}
+	if shuffle == nil {
+		return nil, fmt.Errorf("no random source")
+	}
+	docs = shuffle(docs)
+	return docs[:min(size, len(docs))], nil
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// orderDocs returns the stored form of the documents used by the stage tests
+func orderDocs() []map[string]any {
+	docs := []any{
+		bson.M{"_id": 1, "customer": "alice", "total": 10, "items": bson.A{"pen", "ink"}},
+		bson.M{"_id": 2, "customer": "bob", "total": 5.5, "items": bson.A{}},
+		bson.M{"_id": 3, "customer": "alice", "total": 20, "items": bson.A{"pad"}},
+		bson.M{"_id": 4, "customer": "carol", "status": "open"},
+	}
+	out := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		out[i], _ = storedDocument(doc)
+	}
+	return out
+}
+
+func TestAggregateStages(t *testing.T) {
+	tests := []struct {
+		name    string
+		stage   aggregateStage
+		arg     any
+		want    []map[string]any
+		wantErr string
+	}{
+		{"Match", aggregateMatch, bson.M{"customer": "alice"}, []map[string]any{orderDocs()[0], orderDocs()[2]}, ""},
+		{"ProjectSelection", aggregateProject, bson.M{"customer": 1, "_id": 0},
+			[]map[string]any{{"customer": "alice"}, {"customer": "bob"}, {"customer": "alice"}, {"customer": "carol"}}, ""},
+		{"ProjectRename", aggregateProject, bson.M{"buyer": "$customer", "total": 1},
+			[]map[string]any{{"_id": 1, "buyer": "alice", "total": 10}, {"_id": 2, "buyer": "bob", "total": 5.5}, {"_id": 3, "buyer": "alice", "total": 20}, {"_id": 4, "buyer": "carol"}}, ""},
+		{"ProjectExpression", aggregateProject, bson.M{"n": bson.M{"$size": "$items"}}, nil, "unsupported expression"},
+		{"Sort", aggregateSort, bson.D{{Key: "customer", Value: 1}, {Key: "total", Value: -1}},
+			[]map[string]any{orderDocs()[2], orderDocs()[0], orderDocs()[1], orderDocs()[3]}, ""},
+		{"Skip", aggregateSkip, 3, []map[string]any{orderDocs()[3]}, ""},
+		{"Limit", aggregateLimit, int64(1), []map[string]any{orderDocs()[0]}, ""},
+		{"LimitZero", aggregateLimit, 0, nil, "positive"},
+		{"Count", aggregateCount, "orders", []map[string]any{{"orders": int32(4)}}, ""},
+		{"CountInvalidField", aggregateCount, "$orders", nil, "field name"},
+		{"Group", aggregateGroup, bson.M{
+			"_id":   "$customer",
+			"spent": bson.M{"$sum": "$total"},
+			"n":     bson.M{"$sum": 1},
+			"avg":   bson.M{"$avg": "$total"},
+			"low":   bson.M{"$min": "$total"},
+			"high":  bson.M{"$max": "$total"},
+			"first": bson.M{"$first": "$_id"},
+		}, []map[string]any{
+			{"_id": "alice", "spent": int32(30), "n": int32(2), "avg": 15.0, "low": 10, "high": 20, "first": 1},
+			{"_id": "bob", "spent": 5.5, "n": int32(1), "avg": 5.5, "low": 5.5, "high": 5.5, "first": 2},
+			{"_id": "carol", "spent": int32(0), "n": int32(1), "avg": nil, "low": nil, "high": nil, "first": 4},
+		}, ""},
+		{"GroupAll", aggregateGroup, bson.M{"_id": nil, "n": bson.M{"$sum": 1}}, []map[string]any{{"_id": nil, "n": int32(4)}}, ""},
+		{"GroupCompoundKey", aggregateGroup, bson.M{"_id": bson.M{"c": "$customer", "s": "$status"}, "n": bson.M{"$sum": 1}},
+			[]map[string]any{
+				{"_id": map[string]any{"c": "alice", "s": nil}, "n": int32(2)},
+				{"_id": map[string]any{"c": "bob", "s": nil}, "n": int32(1)},
+				{"_id": map[string]any{"c": "carol", "s": "open"}, "n": int32(1)},
+			}, ""},
+		{"GroupUnknownAccumulator", aggregateGroup, bson.M{"_id": nil, "all": bson.M{"$push": "$_id"}}, nil, "accumulator $push not supported"},
+		{"Unwind", aggregateUnwind, "$items", []map[string]any{
+			{"_id": 1, "customer": "alice", "total": 10, "items": "pen"},
+			{"_id": 1, "customer": "alice", "total": 10, "items": "ink"},
+			{"_id": 3, "customer": "alice", "total": 20, "items": "pad"},
+		}, ""},
+		{"UnwindPreserve", aggregateUnwind, bson.M{"path": "$items", "preserveNullAndEmptyArrays": true, "includeArrayIndex": "i"}, []map[string]any{
+			{"_id": 1, "customer": "alice", "total": 10, "items": "pen", "i": int64(0)},
+			{"_id": 1, "customer": "alice", "total": 10, "items": "ink", "i": int64(1)},
+			{"_id": 2, "customer": "bob", "total": 5.5, "i": nil},
+			{"_id": 3, "customer": "alice", "total": 20, "items": "pad", "i": int64(0)},
+			{"_id": 4, "customer": "carol", "status": "open", "i": nil},
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := orderDocs()
+			got, err := tt.stage(docs, tt.arg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d documents, got %d: %v", len(tt.want), len(got), got)
+			}
+			for i := range got {
+				if !valuesEqual(got[i], normalizeDocument(tt.want[i])) {
+					t.Errorf("document %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+			if !valuesEqual(docs, orderDocs()) {
+				t.Error("expected the input documents to be left unchanged")
+			}
+		})
+	}
+}
+
+func TestFakeDatabaseAggregate(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	for _, doc := range orderDocs() {
+		fake.Seed("shop", "orders", doc)
+	}
+
+	t.Run("Pipeline", func(t *testing.T) {
+		result, err := fake.Aggregate(ctx, "shop", "orders", mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"total": bson.M{"$gt": 0}}}},
+			{{Key: "$unwind", Value: "$items"}},
+			{{Key: "$group", Value: bson.M{"_id": "$customer", "items": bson.M{"$sum": 1}}}},
+			{{Key: "$sort", Value: bson.M{"items": -1}}},
+			{{Key: "$limit", Value: 1}},
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		docs := result.([]any)
+		if len(docs) != 1 || docs[0].(bson.M)["_id"] != "alice" || docs[0].(bson.M)["items"] != int32(3) {
+			t.Errorf("expected alice with 3 items, got %v", docs)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		result, _ := fake.Aggregate(ctx, "shop", "orders", bson.A{bson.M{"$match": bson.M{"customer": "alice"}}, bson.M{"$count": "n"}})
+		if docs := result.([]any); len(docs) != 1 || docs[0].(bson.M)["n"] != int32(2) {
+			t.Errorf("expected a count of 2, got %v", docs)
+		}
+	})
+
+	t.Run("UnsupportedStage", func(t *testing.T) {
+		_, err := fake.Aggregate(ctx, "shop", "orders", bson.A{bson.M{"$graphLookup": bson.M{"from": "customers"}}})
+		if err == nil || !strings.Contains(err.Error(), "stage $graphLookup not supported by fake") {
+			t.Errorf("expected an unsupported stage error, got %v", err)
+		}
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		ids := func(seed int64, size int) []any {
+			fake.SetSeed(seed)
+			result, err := fake.Aggregate(ctx, "shop", "orders", bson.A{bson.M{"$sample": bson.M{"size": size}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out []any
+			for _, doc := range result.([]any) {
+				out = append(out, doc.(bson.M)["_id"])
+			}
+			return out
+		}
+		first := ids(7, 2)
+		if len(first) != 2 || first[0] == first[1] {
+			t.Fatalf("expected 2 distinct documents, got %v", first)
+		}
+		if again := ids(7, 2); !reflect.DeepEqual(first, again) {
+			t.Errorf("expected the same seed to pick the same documents, got %v and %v", first, again)
+		}
+		if all := ids(7, 10); len(all) != 4 {
+			t.Errorf("expected every document when size exceeds the collection, got %v", all)
+		}
+		if _, err := fake.Aggregate(ctx, "shop", "orders", bson.A{bson.M{"$sample": bson.M{"size": -1}}}); err == nil {
+			t.Error("expected a negative size to be rejected")
+		}
+	})
+
+	t.Run("StoreUnchanged", func(t *testing.T) {
+		fake.Aggregate(ctx, "shop", "orders", bson.A{bson.M{"$unwind": "$items"}, bson.M{"$project": bson.M{"x": "$items"}}})
+		docs := fake.Documents("shop", "orders")
+		if _, ok := docs[0]["items"].(bson.A); len(docs) != 4 || !ok {
+			t.Errorf("expected the stored documents to be unchanged, got %v", docs)
+		}
+	})
+}
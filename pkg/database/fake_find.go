@@ -0,0 +1,293 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// findSpec holds the parts of the find options the fake honours. Results are
+// sorted first, then skipped and limited, then projected, as on the server.
+type findSpec struct {
+	sort       any
+	skip       int64
+	limit      int64
+	projection any
+}
+
+func findSpecOf(opts []any) findSpec {
+	var spec findSpec
+	for _, o := range optionsOf[moptions.FindOptions](opts) {
+		if o.Sort != nil {
+			spec.sort = o.Sort
+		}
+		if o.Skip != nil {
+			spec.skip = *o.Skip
+		}
+		if o.Limit != nil {
+			spec.limit = *o.Limit
+		}
+		if o.Projection != nil {
+			spec.projection = o.Projection
+		}
+	}
+	for _, o := range optionsOf[moptions.FindOneOptions](opts) {
+		if o.Sort != nil {
+			spec.sort = o.Sort
+		}
+		if o.Skip != nil {
+			spec.skip = *o.Skip
+		}
+		if o.Projection != nil {
+			spec.projection = o.Projection
+		}
+	}
+	return spec
+}
+
+// apply sorts, pages and projects docs into new documents
+func (s findSpec) apply(docs []map[string]any) ([]map[string]any, error) {
+	sorted, err := sortDocuments(docs, s.sort)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.skip > 0 {
+		if s.skip >= int64(len(sorted)) {
+			sorted = nil
+		} else {
+			sorted = sorted[s.skip:]
+		}
+	}
+	limit := s.limit
+	if limit < 0 {
+		// A negative limit asks for a single batch of that size
+		limit = -limit
+	}
+	if limit > 0 && limit < int64(len(sorted)) {
+		sorted = sorted[:limit]
+	}
+
+	out := make([]map[string]any, len(sorted))
+	for i, doc := range sorted {
+		projected, err := projectDocument(doc, s.projection)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := projected[fakeTextScore]; ok {
+			delete(projected, fakeTextScore)
+		}
+		out[i] = projected
+	}
+	return out, nil
+}
+
+type sortKey struct {
+	path      string
+	direction int
+}
+
+// sortDocuments returns docs stably ordered by spec, a bson.D or a single-key
+// map of field to 1 or -1. Values of different types are ordered as MongoDB
+// orders them: null and missing fields first, then numbers, strings,
+// documents, arrays, binary data, ObjectIDs, booleans, dates, timestamps and
+// regular expressions. Array fields sort by their smallest element ascending
+// and their largest element descending.
+func sortDocuments(docs []map[string]any, spec any) ([]map[string]any, error) {
+	order, err := sortOrder(docs, spec)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]any, len(order))
+	for i, idx := range order {
+		out[i] = docs[idx]
+	}
+	return out, nil
+}
+
+// sortOrder returns the positions of docs in sorted order
+func sortOrder(docs []map[string]any, spec any) ([]int, error) {
+	keys, err := sortKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		for _, key := range keys {
+			a := sortValue(docs[order[i]], key)
+			b := sortValue(docs[order[j]], key)
+			if c := compareValues(a, b) * key.direction; c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return order, nil
+}
+
+func sortKeys(spec any) ([]sortKey, error) {
+	var d bson.D
+	switch t := spec.(type) {
+	case nil:
+		return nil, nil
+	case bson.D:
+		d = t
+	default:
+		m, ok := normalizeDocument(spec).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("sort must be a document, got %T", spec)
+		}
+		if len(m) > 1 {
+			return nil, fmt.Errorf("sort on several fields must be a bson.D to keep the key order")
+		}
+		for k, v := range m {
+			d = append(d, bson.E{Key: k, Value: v})
+		}
+	}
+
+	keys := make([]sortKey, 0, len(d))
+	for _, e := range d {
+		if isTextScoreMeta(e.Value) {
+			// Highest text score first, as on the server
+			keys = append(keys, sortKey{path: fakeTextScore, direction: -1})
+			continue
+		}
+		n, ok := toFloat(e.Value)
+		if !ok || (n != 1 && n != -1) {
+			return nil, fmt.Errorf("sort direction for %s must be 1 or -1, got %v", e.Key, e.Value)
+		}
+		keys = append(keys, sortKey{path: e.Key, direction: int(n)})
+	}
+	return keys, nil
+}
+
+func sortValue(doc map[string]any, key sortKey) any {
+	values, found := resolvePath(doc, key.path)
+	if !found {
+		return nil
+	}
+	var best any
+	first := true
+	for _, v := range values {
+		candidates := []any{v}
+		if arr, ok := v.([]any); ok && len(arr) > 0 {
+			candidates = arr
+		}
+		for _, c := range candidates {
+			if first || compareValues(c, best)*key.direction < 0 {
+				best, first = c, false
+			}
+		}
+	}
+	return best
+}
+
+// projectDocument applies an inclusion or exclusion projection. _id is kept
+// unless excluded explicitly, and it is the only field that may be excluded
+// in an inclusion projection.
+func projectDocument(doc map[string]any, projection any) (map[string]any, error) {
+	if projection == nil {
+		return doc, nil
+	}
+	fields, ok := normalizeDocument(projection).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("projection must be a document, got %T", projection)
+	}
+	if len(fields) == 0 {
+		return doc, nil
+	}
+
+	include := map[string]bool{}
+	var scores []string
+	keepID, onlyID := true, false
+	inclusion, exclusion := false, false
+	for path, v := range fields {
+		if isTextScoreMeta(v) {
+			// Neither includes nor excludes other fields
+			scores = append(scores, path)
+			continue
+		}
+		on, ok := projectionFlag(v)
+		if !ok {
+			return nil, fmt.Errorf("unsupported projection for %s: %v", path, v)
+		}
+		if path == "_id" {
+			keepID = on
+			onlyID = on
+			continue
+		}
+		include[path] = on
+		if on {
+			inclusion = true
+		} else {
+			exclusion = true
+		}
+	}
+	if inclusion && exclusion {
+		return nil, fmt.Errorf("projection cannot mix inclusion and exclusion")
+	}
+
+	if onlyID && !exclusion {
+		// {_id: 1} on its own is an inclusion projection of just _id
+		inclusion = true
+	}
+
+	if !inclusion {
+		out := copyDocument(doc)
+		for path := range include {
+			unsetPath(out, path)
+		}
+		if !keepID {
+			delete(out, "_id")
+		}
+		return out, projectScores(doc, out, scores)
+	}
+
+	out := map[string]any{}
+	if id, ok := doc["_id"]; ok && keepID {
+		out["_id"] = id
+	}
+	paths := make([]string, 0, len(include))
+	for path := range include {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if v, ok := getPath(doc, path); ok {
+			if err := setPath(out, path, copyValue(v)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, projectScores(doc, out, scores)
+}
+
+// projectScores sets the text score of doc on the {$meta: "textScore"}
+// paths of out
+func projectScores(doc map[string]any, out map[string]any, paths []string) error {
+	score, ok := doc[fakeTextScore]
+	if !ok {
+		return nil
+	}
+	for _, path := range paths {
+		if err := setPath(out, path, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func projectionFlag(v any) (bool, bool) {
+	if b, ok := v.(bool); ok {
+		return b, true
+	}
+	if n, ok := toFloat(v); ok {
+		return n != 0, true
+	}
+	return false, false
+}
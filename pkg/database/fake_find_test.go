@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// resultIDs returns the _id of each document in a Find result
+func resultIDs(t *testing.T, result any) []any {
+	t.Helper()
+	docs, ok := result.([]any)
+	if !ok {
+		t.Fatalf("expected []any result, got %T", result)
+	}
+	ids := make([]any, len(docs))
+	for i, doc := range docs {
+		ids[i] = normalizeDocument(doc).(map[string]any)["_id"]
+	}
+	return ids
+}
+
+func TestFakeDatabaseFindOptions(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	fake.Seed("testdb", "items",
+		bson.M{"_id": 1, "rank": 2, "name": "b"},
+		bson.M{"_id": 2, "rank": "x", "name": "a"},
+		bson.M{"_id": 3, "name": "c"},
+		bson.M{"_id": 4, "rank": 2, "name": "a"},
+		bson.M{"_id": 5, "rank": primitive.NewObjectID(), "name": "d"},
+		bson.M{"_id": 6, "rank": bson.A{5, 0}, "name": "e"},
+	)
+
+	tests := []struct {
+		name string
+		opts *moptions.FindOptions
+		want []any
+	}{
+		{"InsertionOrderWithoutSort", moptions.Find(), []any{int32(1), int32(2), int32(3), int32(4), int32(5), int32(6)}},
+		{"TypeOrderAscending", moptions.Find().SetSort(bson.M{"rank": 1}), []any{int32(3), int32(6), int32(1), int32(4), int32(2), int32(5)}},
+		{"TypeOrderDescending", moptions.Find().SetSort(bson.M{"rank": -1}), []any{int32(5), int32(2), int32(6), int32(1), int32(4), int32(3)}},
+		{"MultiKey", moptions.Find().SetSort(bson.D{{Key: "rank", Value: 1}, {Key: "name", Value: -1}}), []any{int32(3), int32(6), int32(1), int32(4), int32(2), int32(5)}},
+		{"SkipAndLimit", moptions.Find().SetSort(bson.M{"name": 1}).SetSkip(1).SetLimit(2), []any{int32(4), int32(1)}},
+		{"SkipPastEnd", moptions.Find().SetSkip(10), []any{}},
+		{"NegativeLimit", moptions.Find().SetLimit(-2), []any{int32(1), int32(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fake.Find(ctx, "testdb", "items", bson.M{}, tt.opts)
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if got := resultIDs(t, result); !valuesEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("StableSort", func(t *testing.T) {
+		result, _ := fake.Find(ctx, "testdb", "items", bson.M{"rank": 2}, moptions.Find().SetSort(bson.M{"rank": 1}))
+		if got := resultIDs(t, result); !valuesEqual(got, []any{1, 4}) {
+			t.Errorf("expected equal keys to keep insertion order, got %v", got)
+		}
+	})
+
+	t.Run("UnorderedMultiKeySortIsAnError", func(t *testing.T) {
+		_, err := fake.Find(ctx, "testdb", "items", bson.M{}, moptions.Find().SetSort(bson.M{"rank": 1, "name": 1}))
+		if err == nil || !strings.Contains(err.Error(), "bson.D") {
+			t.Errorf("expected error asking for bson.D, got %v", err)
+		}
+	})
+
+	t.Run("FindOneHonoursSort", func(t *testing.T) {
+		doc, err := fake.FindOne(ctx, "testdb", "items", bson.M{}, moptions.FindOne().SetSort(bson.M{"name": -1}))
+		if err != nil || doc.(bson.M)["_id"] != int32(6) {
+			t.Errorf("expected the last name first, got %v, %v", doc, err)
+		}
+	})
+}
+
+func TestProjectDocument(t *testing.T) {
+	doc := normalizeDocument(bson.M{
+		"_id":     1,
+		"name":    "alice",
+		"age":     31,
+		"profile": bson.M{"city": "Ghent", "zip": "9000"},
+	}).(map[string]any)
+
+	tests := []struct {
+		name       string
+		projection any
+		want       map[string]any
+		wantErr    string
+	}{
+		{"InclusionKeepsID", bson.M{"name": 1}, map[string]any{"_id": 1, "name": "alice"}, ""},
+		{"InclusionWithoutID", bson.M{"name": 1, "_id": 0}, map[string]any{"name": "alice"}, ""},
+		{"InclusionNested", bson.M{"profile.city": true}, map[string]any{"_id": 1, "profile": map[string]any{"city": "Ghent"}}, ""},
+		{"Exclusion", bson.M{"age": 0, "profile": 0}, map[string]any{"_id": 1, "name": "alice"}, ""},
+		{"ExcludeOnlyID", bson.M{"_id": 0}, map[string]any{"name": "alice", "age": 31, "profile": map[string]any{"city": "Ghent", "zip": "9000"}}, ""},
+		{"ExclusionNested", bson.M{"profile.zip": 0}, map[string]any{"_id": 1, "name": "alice", "age": 31, "profile": map[string]any{"city": "Ghent"}}, ""},
+		{"Mixed", bson.M{"name": 1, "age": 0}, nil, "cannot mix"},
+		{"Operator", bson.M{"tags": bson.M{"$slice": 1}}, nil, "unsupported projection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := projectDocument(doc, tt.projection)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !valuesEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
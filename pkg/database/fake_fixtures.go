@@ -0,0 +1,215 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFixtures loads every .json, .yaml and .yml file in dir. File names map
+// to namespaces, so testdb.users.json is loaded into collection users of
+// database testdb.
+func (f *FakeDatabase) LoadFixtures(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("fake: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isFixtureFile(entry.Name()) {
+			continue
+		}
+		db, collection, err := fixtureNamespace(entry.Name())
+		if err != nil {
+			return fmt.Errorf("fake: %w", err)
+		}
+		if err := f.LoadFixtureFile(db, collection, filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFixtureFile loads a JSON or YAML array of documents into db.collection.
+// Documents may use extended JSON such as {"$oid": "..."} and {"$date": "..."}.
+func (f *FakeDatabase) LoadFixtureFile(db string, collection string, path string) error {
+	docs, err := readFixtureFile(path)
+	if err != nil {
+		return fmt.Errorf("fake: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := fakeNamespace{db, collection}
+	f.collections[ns] = append(f.collections[ns], docs...)
+	return nil
+}
+
+// DumpFixtures writes every non-empty collection to dir as <db>.<collection>.json
+// in relaxed extended JSON, with keys sorted so the output is stable for golden files
+func (f *FakeDatabase) DumpFixtures(dir string) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("fake: %w", err)
+	}
+	for _, ns := range f.namespaces() {
+		docs := f.collections[ns]
+		if len(docs) == 0 {
+			continue
+		}
+		data, err := marshalFixtures(docs)
+		if err != nil {
+			return fmt.Errorf("fake: dump %s.%s: %w", ns.db, ns.collection, err)
+		}
+		path := filepath.Join(dir, ns.db+"."+ns.collection+".json")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("fake: %w", err)
+		}
+	}
+	return nil
+}
+
+// readFixtureFile parses a fixture file into stored documents, reporting the
+// file and document index of anything malformed
+func readFixtureFile(path string) ([]map[string]any, error) {
+	raws, err := readFixtureDocuments(path)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]map[string]any, 0, len(raws))
+	for i, raw := range raws {
+		doc, err := fakeDocument(raw)
+		if err != nil {
+			return nil, fmt.Errorf("fixture %s: document %d: %w", path, i, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// readFixtureDocuments parses the documents of a fixture file, keeping their
+// field order
+func readFixtureDocuments(path string) ([]bson.D, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raws []json.RawMessage
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		raws, err = yamlDocuments(data)
+	default:
+		err = json.Unmarshal(data, &raws)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", path, err)
+	}
+
+	docs := make([]bson.D, 0, len(raws))
+	for i, raw := range raws {
+		var d bson.D
+		if err := bson.UnmarshalExtJSON(raw, false, &d); err != nil {
+			return nil, fmt.Errorf("fixture %s: document %d: %w", path, i, err)
+		}
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// yamlDocuments converts a YAML array into JSON documents so extended JSON
+// keys are interpreted the same way for both formats
+func yamlDocuments(data []byte) ([]json.RawMessage, error) {
+	var items []any
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	raws := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		raws[i] = raw
+	}
+	return raws, nil
+}
+
+func marshalFixtures(docs []map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		raw, err := bson.MarshalExtJSON(orderedDocument(doc), false, false)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(raw)
+	}
+	buf.WriteByte(']')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// orderedDocument converts a stored document into a bson.D with _id first
+// and the remaining keys sorted
+func orderedDocument(doc map[string]any) bson.D {
+	keys := sortedKeys(doc)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i] == "_id" && keys[j] != "_id"
+	})
+	out := make(bson.D, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, bson.E{Key: k, Value: orderedValue(doc[k])})
+	}
+	return out
+}
+
+func orderedValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		return orderedDocument(t)
+	case []any:
+		out := make(bson.A, len(t))
+		for i, val := range t {
+			out[i] = orderedValue(val)
+		}
+		return out
+	}
+	return v
+}
+
+func isFixtureFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// fixtureNamespace splits a file name such as testdb.users.json into its
+// database and collection; collection names may themselves contain dots
+func fixtureNamespace(name string) (string, string, error) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	db, collection, ok := strings.Cut(base, ".")
+	if !ok || db == "" || collection == "" {
+		return "", "", fmt.Errorf("fixture %s: file name must be <db>.<collection>%s", name, filepath.Ext(name))
+	}
+	return db, collection, nil
+}
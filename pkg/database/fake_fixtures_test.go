@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func writeFixture(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path
+}
+
+func TestFakeDatabaseFixtures(t *testing.T) {
+	t.Run("LoadFixturesMapsFileNamesToNamespaces", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFixture(t, dir, "testdb.users.json", `[
+			{"_id": {"$oid": "65f1a2b3c4d5e6f708192a3b"}, "name": "alice", "joined": {"$date": "2024-03-01T10:00:00Z"}},
+			{"name": "bob"}
+		]`)
+		writeFixture(t, dir, "testdb.audit.log.yaml", "- action: login\n  count: 2\n- action: logout\n")
+		writeFixture(t, dir, "README.md", "ignored")
+
+		fake := NewFakeDatabase()
+		if err := fake.LoadFixtures(dir); err != nil {
+			t.Fatalf("failed to load fixtures: %v", err)
+		}
+
+		users := fake.Documents("testdb", "users")
+		if len(users) != 2 {
+			t.Fatalf("expected 2 users, got %d", len(users))
+		}
+		wantID, _ := primitive.ObjectIDFromHex("65f1a2b3c4d5e6f708192a3b")
+		if users[0]["_id"] != wantID {
+			t.Errorf("expected extended JSON ObjectID, got %#v", users[0]["_id"])
+		}
+		if _, ok := users[0]["joined"].(primitive.DateTime); !ok {
+			t.Errorf("expected extended JSON date, got %T", users[0]["joined"])
+		}
+		if _, ok := users[1]["_id"].(primitive.ObjectID); !ok {
+			t.Error("expected generated _id for document without one")
+		}
+
+		result, err := fake.Find(context.Background(), "testdb", "audit.log", bson.M{"count": 2})
+		if err != nil || len(result.([]any)) != 1 {
+			t.Errorf("expected YAML document to be queryable, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("MalformedDocumentReportsFileAndIndex", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeFixture(t, dir, "testdb.users.json", `[{"name": "ok"}, {"_id": {"$oid": "nothex"}}]`)
+
+		err := NewFakeDatabase().LoadFixtureFile("testdb", "users", path)
+		if err == nil {
+			t.Fatal("expected error for malformed document")
+		}
+		if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "document 1") {
+			t.Errorf("expected file and index in error, got %v", err)
+		}
+	})
+
+	t.Run("FileNameWithoutNamespace", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFixture(t, dir, "users.json", `[]`)
+
+		if err := NewFakeDatabase().LoadFixtures(dir); err == nil || !strings.Contains(err.Error(), "<db>.<collection>.json") {
+			t.Errorf("expected namespace error, got %v", err)
+		}
+	})
+
+	t.Run("DumpFixturesRoundTrips", func(t *testing.T) {
+		fake := seededFake(t)
+		dir := t.TempDir()
+		if err := fake.DumpFixtures(dir); err != nil {
+			t.Fatalf("failed to dump fixtures: %v", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "testdb.users.json"))
+		if err != nil {
+			t.Fatalf("expected dumped file: %v", err)
+		}
+		if !strings.HasPrefix(string(data), "[\n  {\n    \"_id\": 1,\n    \"address\"") {
+			t.Errorf("expected _id first and sorted keys, got:\n%s", data)
+		}
+
+		reloaded := NewFakeDatabase()
+		if err := reloaded.LoadFixtures(dir); err != nil {
+			t.Fatalf("failed to reload dump: %v", err)
+		}
+		if got, want := reloaded.Documents("testdb", "users"), fake.Documents("testdb", "users"); !valuesEqual(normalizeDocument(got), normalizeDocument(want)) {
+			t.Errorf("expected reloaded documents to equal original\ngot:  %v\nwant: %v", got, want)
+		}
+	})
+}
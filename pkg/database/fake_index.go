@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// uniqueIndex is a unique index on one or more fields. A sparse index skips
+// documents that have none of its fields; otherwise missing fields index as
+// null, so two documents without the field collide, as in MongoDB.
+type uniqueIndex struct {
+	fields []string
+	sparse bool
+}
+
+// name follows MongoDB's default index naming, e.g. serial_1_site_1
+func (ix uniqueIndex) name() string {
+	parts := make([]string, len(ix.fields))
+	for i, field := range ix.fields {
+		parts[i] = field + "_1"
+	}
+	return strings.Join(parts, "_")
+}
+
+// keys returns every key doc contributes to the index. Array values index
+// each element, so a compound key expands to every combination.
+func (ix uniqueIndex) keys(doc map[string]any) [][]any {
+	keys := [][]any{{}}
+	present := false
+	for _, field := range ix.fields {
+		values, found := resolvePath(doc, field)
+		if found {
+			present = true
+		} else {
+			values = []any{nil}
+		}
+		var elems []any
+		for _, v := range values {
+			if arr, ok := v.([]any); ok && len(arr) > 0 {
+				elems = append(elems, arr...)
+				continue
+			}
+			elems = append(elems, v)
+		}
+		next := make([][]any, 0, len(keys)*len(elems))
+		for _, key := range keys {
+			for _, elem := range elems {
+				next = append(next, append(append([]any{}, key...), elem))
+			}
+		}
+		keys = next
+	}
+	if ix.sparse && !present {
+		return nil
+	}
+	return keys
+}
+
+func (ix uniqueIndex) describe(key []any) string {
+	parts := make([]string, len(ix.fields))
+	for i, field := range ix.fields {
+		parts[i] = field + ": " + describeValue(toBSON(key[i]))
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// EnsureUniqueIndex makes later inserts, updates and upserts that would
+// store two documents with the same values for fields fail with
+// ErrDuplicateKey. Documents missing a field index it as null. It fails the
+// same way if the stored documents already violate the index.
+func (f *FakeDatabase) EnsureUniqueIndex(db string, collection string, fields ...string) error {
+	return f.ensureUniqueIndex(fakeNamespace{db, collection}, uniqueIndex{fields: fields})
+}
+
+// EnsureSparseUniqueIndex is EnsureUniqueIndex for a sparse index: documents
+// that have none of the fields are not indexed and never collide
+func (f *FakeDatabase) EnsureSparseUniqueIndex(db string, collection string, fields ...string) error {
+	return f.ensureUniqueIndex(fakeNamespace{db, collection}, uniqueIndex{fields: fields, sparse: true})
+}
+
+// DropIndex removes the unique index on fields, restoring permissive writes
+func (f *FakeDatabase) DropIndex(db string, collection string, fields ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := fakeNamespace{db, collection}
+	name := uniqueIndex{fields: fields}.name()
+	for i, ix := range f.indexes[ns] {
+		if ix.name() == name {
+			f.indexes[ns] = append(f.indexes[ns][:i], f.indexes[ns][i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("fake: index %s not found on %s.%s", name, db, collection)
+}
+
+// EnsureIndexes implements Indexer. Unique specs become unique indexes and
+// specs with ExpireAfter TTL indexes. Other indexes, such as 2dsphere, only
+// make queries faster on a server and are accepted without effect.
+func (f *FakeDatabase) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	if err := f.ready(ctx); err != nil {
+		return err
+	}
+	f.mu.RLock()
+	err := f.writable(fakeNamespace{db, collection})
+	f.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("cannot create indexes on view %s.%s: %w", db, collection, ErrViewNotSupported)
+	}
+	for _, spec := range specs {
+		if spec.ExpireAfter > 0 {
+			if err := f.EnsureTTLIndex(db, collection, spec.Keys[0].Key, spec.ExpireAfter); err != nil {
+				return err
+			}
+		}
+		if spec.Unique {
+			index := uniqueIndex{fields: spec.fields(), sparse: spec.Sparse}
+			if err := f.ensureUniqueIndex(fakeNamespace{db, collection}, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FakeDatabase) ensureUniqueIndex(ns fakeNamespace, index uniqueIndex) error {
+	if len(index.fields) == 0 {
+		return fmt.Errorf("fake: unique index needs at least one field")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var seen [][]any
+	for _, doc := range f.collections[ns] {
+		keys := index.keys(doc)
+		for _, key := range keys {
+			for _, other := range seen {
+				if valuesEqual(key, other) {
+					return f.duplicateKey(ns, index, key)
+				}
+			}
+		}
+		seen = append(seen, keys...)
+	}
+
+	for i, ix := range f.indexes[ns] {
+		if ix.name() == index.name() {
+			f.indexes[ns][i] = index
+			return nil
+		}
+	}
+	f.indexes[ns] = append(f.indexes[ns], index)
+	return nil
+}
+
+// checkUnique returns ErrDuplicateKey if storing doc at position pos would
+// violate a unique index; pos is -1 for a new document. The caller holds f.mu.
+func (f *FakeDatabase) checkUnique(ns fakeNamespace, doc map[string]any, pos int) error {
+	for _, index := range f.indexes[ns] {
+		keys := index.keys(doc)
+		if len(keys) == 0 {
+			continue
+		}
+		for i, other := range f.collections[ns] {
+			if i == pos {
+				continue
+			}
+			for _, key := range keys {
+				for _, otherKey := range index.keys(other) {
+					if valuesEqual(key, otherKey) {
+						return f.duplicateKey(ns, index, key)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FakeDatabase) duplicateKey(ns fakeNamespace, index uniqueIndex, key []any) error {
+	return fmt.Errorf("fake: %w: collection %s.%s index %s dup key: %s",
+		ErrDuplicateKey, ns.db, ns.collection, index.name(), index.describe(key))
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestFakeDatabaseUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("InsertDuplicate", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if err := fake.EnsureUniqueIndex("testdb", "devices", "serial"); err != nil {
+			t.Fatalf("failed to create index: %v", err)
+		}
+		fake.InsertOne(ctx, "testdb", "devices", bson.M{"serial": "A1"})
+
+		_, err := fake.InsertOne(ctx, "testdb", "devices", bson.M{"serial": "A1"})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("expected ErrDuplicateKey, got %v", err)
+		}
+		if !strings.Contains(err.Error(), `serial_1 dup key: { serial: "A1" }`) {
+			t.Errorf("expected the key values in the message, got %v", err)
+		}
+		if n := len(fake.Documents("testdb", "devices")); n != 1 {
+			t.Errorf("expected the duplicate not to be stored, got %d documents", n)
+		}
+	})
+
+	t.Run("CompoundKey", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.EnsureUniqueIndex("testdb", "devices", "site", "serial")
+		fake.InsertOne(ctx, "testdb", "devices", bson.M{"site": "gent", "serial": "A1"})
+
+		if _, err := fake.InsertOne(ctx, "testdb", "devices", bson.M{"site": "brugge", "serial": "A1"}); err != nil {
+			t.Errorf("expected a different site to be allowed, got %v", err)
+		}
+		_, err := fake.InsertOne(ctx, "testdb", "devices", bson.M{"site": "gent", "serial": "A1"})
+		if !errors.Is(err, ErrDuplicateKey) || !strings.Contains(err.Error(), `site: "gent", serial: "A1"`) {
+			t.Errorf("expected ErrDuplicateKey naming both fields, got %v", err)
+		}
+	})
+
+	t.Run("MissingFields", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.EnsureUniqueIndex("testdb", "devices", "serial")
+		fake.InsertOne(ctx, "testdb", "devices", bson.M{"name": "a"})
+
+		_, err := fake.InsertOne(ctx, "testdb", "devices", bson.M{"name": "b"})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected missing fields to collide as null, got %v", err)
+		}
+
+		sparse := NewFakeDatabase()
+		sparse.EnsureSparseUniqueIndex("testdb", "devices", "serial")
+		sparse.InsertOne(ctx, "testdb", "devices", bson.M{"name": "a"})
+		if _, err := sparse.InsertOne(ctx, "testdb", "devices", bson.M{"name": "b"}); err != nil {
+			t.Errorf("expected a sparse index to skip documents without the field, got %v", err)
+		}
+	})
+
+	t.Run("ArrayValues", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.EnsureUniqueIndex("testdb", "users", "emails")
+		fake.InsertOne(ctx, "testdb", "users", bson.M{"emails": bson.A{"a@x", "b@x"}})
+
+		_, err := fake.InsertOne(ctx, "testdb", "users", bson.M{"emails": bson.A{"c@x", "b@x"}})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected a shared array element to collide, got %v", err)
+		}
+	})
+
+	t.Run("UpdatesAndUpserts", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.EnsureUniqueIndex("testdb", "devices", "serial")
+		fake.InsertMany(ctx, "testdb", "devices", []any{bson.M{"_id": 1, "serial": "A1"}, bson.M{"_id": 2, "serial": "B2"}})
+
+		if _, err := fake.UpdateOne(ctx, "testdb", "devices", bson.M{"_id": 1}, bson.M{"$set": bson.M{"serial": "A1", "seen": true}}); err != nil {
+			t.Errorf("expected updating a document to keep its own key, got %v", err)
+		}
+		if _, err := fake.UpdateOne(ctx, "testdb", "devices", bson.M{"_id": 2}, bson.M{"$set": bson.M{"serial": "A1"}}); !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected UpdateOne to fail, got %v", err)
+		}
+		if _, err := fake.ReplaceOne(ctx, "testdb", "devices", bson.M{"_id": 2}, bson.M{"serial": "A1"}); !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected ReplaceOne to fail, got %v", err)
+		}
+		_, err := fake.UpdateOne(ctx, "testdb", "devices", bson.M{"_id": 3}, bson.M{"$set": bson.M{"serial": "B2"}}, moptions.Update().SetUpsert(true))
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected the upsert to fail, got %v", err)
+		}
+		_, err = fake.BulkWrite(ctx, "testdb", "devices", []any{mongo.NewInsertOneModel().SetDocument(bson.M{"serial": "B2"})})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected BulkWrite to fail, got %v", err)
+		}
+		if doc, _ := fake.FindOne(ctx, "testdb", "devices", bson.M{"_id": 2}); doc.(bson.M)["serial"] != "B2" {
+			t.Errorf("expected the document to be unchanged, got %v", doc)
+		}
+	})
+
+	t.Run("ExistingDuplicates", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.Seed("testdb", "devices", bson.M{"serial": "A1"}, bson.M{"serial": "A1"})
+
+		if err := fake.EnsureUniqueIndex("testdb", "devices", "serial"); !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected building the index to fail, got %v", err)
+		}
+	})
+
+	t.Run("DropIndex", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.EnsureUniqueIndex("testdb", "devices", "serial")
+		fake.InsertOne(ctx, "testdb", "devices", bson.M{"serial": "A1"})
+
+		if err := fake.DropIndex("testdb", "devices", "serial"); err != nil {
+			t.Fatalf("failed to drop index: %v", err)
+		}
+		if _, err := fake.InsertOne(ctx, "testdb", "devices", bson.M{"serial": "A1"}); err != nil {
+			t.Errorf("expected duplicates to be allowed after DropIndex, got %v", err)
+		}
+		if err := fake.DropIndex("testdb", "devices", "serial"); err == nil {
+			t.Error("expected an error dropping a missing index")
+		}
+	})
+}
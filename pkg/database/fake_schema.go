@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// ApplySchema implements SchemaManager. The fake stores the validator so
+// GetSchema reads it back, but does not validate documents against it.
+func (f *FakeDatabase) ApplySchema(ctx context.Context, db string, collection string, schema map[string]any, level string, action string) error {
+	if err := f.ready(ctx); err != nil {
+		return err
+	}
+	if level == "" {
+		level = ValidationStrict
+	}
+	if action == "" {
+		action = ValidationError
+	}
+	copied, _ := normalizeDocument(schema).(map[string]any)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ns := fakeNamespace{db, collection}
+	if _, ok := f.collections[ns]; !ok {
+		f.collections[ns] = nil
+	}
+	f.schemas[ns] = SchemaValidation{Schema: copied, Level: level, Action: action}
+	return nil
+}
+
+// GetSchema implements SchemaManager
+func (f *FakeDatabase) GetSchema(ctx context.Context, db string, collection string) (*SchemaValidation, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	ns := fakeNamespace{db, collection}
+	if _, ok := f.collections[ns]; !ok {
+		return nil, fmt.Errorf("%w: %s.%s", ErrCollectionNotFound, db, collection)
+	}
+	validation, ok := f.schemas[ns]
+	if !ok {
+		return nil, nil
+	}
+	validation.Schema, _ = normalizeDocument(validation.Schema).(map[string]any)
+	return &validation, nil
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func seededFake(t *testing.T) *FakeDatabase {
+	t.Helper()
+	fake := NewFakeDatabase()
+	err := fake.Seed("testdb", "users",
+		bson.M{"_id": 1, "name": "alice", "age": 31, "tags": bson.A{"admin", "ops"}, "address": bson.M{"city": "Ghent"}},
+		bson.M{"_id": 2, "name": "bob", "age": 25, "tags": bson.A{"ops"}, "joined": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		bson.M{"_id": 3, "name": "carol", "age": 42, "scores": bson.A{bson.M{"kind": "math", "value": 90}, bson.M{"kind": "art", "value": 60}}},
+	)
+	if err != nil {
+		t.Fatalf("failed to seed fake: %v", err)
+	}
+	return fake
+}
+
+func TestFakeDatabaseFind(t *testing.T) {
+	fake := seededFake(t)
+
+	tests := []struct {
+		name   string
+		filter any
+		want   []int32
+	}{
+		{"NilFilter", nil, []int32{1, 2, 3}},
+		{"Equality", bson.M{"name": "bob"}, []int32{2}},
+		{"BsonD", bson.D{{Key: "name", Value: "carol"}}, []int32{3}},
+		{"ArrayContains", bson.M{"tags": "ops"}, []int32{1, 2}},
+		{"DottedPath", bson.M{"address.city": "Ghent"}, []int32{1}},
+		{"DottedPathThroughArray", bson.M{"scores.kind": "art"}, []int32{3}},
+		{"Gte", bson.M{"age": bson.M{"$gte": 31}}, []int32{1, 3}},
+		{"Range", bson.M{"age": bson.M{"$gt": 20, "$lt": 40}}, []int32{1, 2}},
+		{"CrossTypeComparisonNeverMatches", bson.M{"age": bson.M{"$gt": "a"}}, nil},
+		{"Ne", bson.M{"name": bson.M{"$ne": "alice"}}, []int32{2, 3}},
+		{"In", bson.M{"name": bson.M{"$in": bson.A{"alice", "carol"}}}, []int32{1, 3}},
+		{"Nin", bson.M{"name": bson.M{"$nin": bson.A{"alice", "carol"}}}, []int32{2}},
+		{"ExistsTrue", bson.M{"joined": bson.M{"$exists": true}}, []int32{2}},
+		{"ExistsFalse", bson.M{"joined": bson.M{"$exists": false}}, []int32{1, 3}},
+		{"NullMatchesMissing", bson.M{"joined": nil}, []int32{1, 3}},
+		{"Regex", bson.M{"name": bson.M{"$regex": "^A", "$options": "i"}}, []int32{1}},
+		{"RegexValue", bson.M{"name": primitive.Regex{Pattern: "o"}}, []int32{2, 3}},
+		{"Not", bson.M{"age": bson.M{"$not": bson.M{"$gt": 30}}}, []int32{2}},
+		{"Size", bson.M{"tags": bson.M{"$size": 2}}, []int32{1}},
+		{"All", bson.M{"tags": bson.M{"$all": bson.A{"ops", "admin"}}}, []int32{1}},
+		{"ElemMatch", bson.M{"scores": bson.M{"$elemMatch": bson.M{"kind": "math", "value": bson.M{"$gte": 80}}}}, []int32{3}},
+		{"And", bson.M{"$and": bson.A{bson.M{"age": bson.M{"$gt": 20}}, bson.M{"tags": "admin"}}}, []int32{1}},
+		{"Or", bson.M{"$or": bson.A{bson.M{"name": "bob"}, bson.M{"age": 42}}}, []int32{2, 3}},
+		{"Nor", bson.M{"$nor": bson.A{bson.M{"name": "bob"}, bson.M{"age": 42}}}, []int32{1}},
+		{"DateComparesWithTime", bson.M{"joined": bson.M{"$gte": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}, []int32{2}},
+		{"Struct", struct {
+			Name string `bson:"name"`
+		}{"alice"}, []int32{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fake.Find(context.Background(), "testdb", "users", tt.filter)
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			docs := result.([]any)
+			if len(docs) != len(tt.want) {
+				t.Fatalf("expected %d documents, got %d: %v", len(tt.want), len(docs), docs)
+			}
+			for i, doc := range docs {
+				if id := doc.(bson.M)["_id"]; id != tt.want[i] {
+					t.Errorf("document %d: expected _id %v, got %v", i, tt.want[i], id)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeDatabase(t *testing.T) {
+	t.Run("FindOneReturnsErrNoDocuments", func(t *testing.T) {
+		fake := seededFake(t)
+
+		_, err := fake.FindOne(context.Background(), "testdb", "users", bson.M{"name": "nobody"})
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected mongo.ErrNoDocuments, got %v", err)
+		}
+
+		result, err := fake.FindOne(context.Background(), "testdb", "users", bson.M{"age": bson.M{"$lt": 30}})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if result.(bson.M)["name"] != "bob" {
+			t.Errorf("expected bob, got %v", result)
+		}
+	})
+
+	t.Run("UnknownOperatorIsAnError", func(t *testing.T) {
+		fake := seededFake(t)
+
+		_, err := fake.Find(context.Background(), "testdb", "users", bson.M{"age": bson.M{"$near": 1}})
+		if err == nil || err.Error() != "fake: unsupported query operator $near" {
+			t.Errorf("expected unsupported operator error, got %v", err)
+		}
+	})
+
+	t.Run("ResultsAreCopies", func(t *testing.T) {
+		fake := seededFake(t)
+
+		result, _ := fake.FindOne(context.Background(), "testdb", "users", bson.M{"_id": 1})
+		result.(bson.M)["address"].(bson.M)["city"] = "Antwerp"
+
+		if city := fake.Documents("testdb", "users")[0]["address"].(bson.M)["city"]; city != "Ghent" {
+			t.Errorf("expected stored document to be unchanged, got %v", city)
+		}
+	})
+
+	t.Run("SeedGeneratesIDs", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if err := fake.Seed("testdb", "users", bson.M{"name": "dave"}); err != nil {
+			t.Fatalf("failed to seed: %v", err)
+		}
+		if _, ok := fake.Documents("testdb", "users")[0]["_id"].(primitive.ObjectID); !ok {
+			t.Error("expected generated ObjectID _id")
+		}
+	})
+
+	t.Run("EmptyCollection", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		result, err := fake.Find(context.Background(), "testdb", "users", bson.M{})
+		if err != nil || len(result.([]any)) != 0 {
+			t.Errorf("expected empty result, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("ClosedFailsLaterOperations", func(t *testing.T) {
+		fake := seededFake(t)
+		if err := fake.Close(context.Background()); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+
+		if _, err := fake.Find(context.Background(), "testdb", "users", nil); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("expected ErrClientClosed, got %v", err)
+		}
+		if len(fake.Documents("testdb", "users")) != 3 {
+			t.Error("expected stored documents to stay available")
+		}
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		fake := seededFake(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := fake.Find(ctx, "testdb", "users", nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestFakeDatabaseDistinct(t *testing.T) {
+	ctx := context.Background()
+	fake := seededFake(t)
+
+	tags, err := fake.Distinct(ctx, "testdb", "users", "tags", bson.M{})
+	if err != nil || len(tags) != 2 || tags[0] != "admin" || tags[1] != "ops" {
+		t.Errorf("expected array elements to count individually, got %v, %v", tags, err)
+	}
+	kinds, _ := fake.Distinct(ctx, "testdb", "users", "scores.kind", bson.M{})
+	if len(kinds) != 2 {
+		t.Errorf("expected values from arrays of documents, got %v", kinds)
+	}
+	names, _ := fake.Distinct(ctx, "testdb", "users", "name", bson.M{"age": bson.M{"$gt": 30}})
+	if len(names) != 2 {
+		t.Errorf("expected the filter to apply, got %v", names)
+	}
+	if missing, err := fake.Distinct(ctx, "testdb", "users", "nope", bson.M{}); err != nil || missing == nil || len(missing) != 0 {
+		t.Errorf("expected an empty slice for a missing field, got %#v, %v", missing, err)
+	}
+}
+
+func TestFakeDatabaseCount(t *testing.T) {
+	ctx := context.Background()
+	fake := seededFake(t)
+
+	if n, err := fake.Count(ctx, "testdb", "users", bson.M{"tags": "ops"}); err != nil || n != 2 {
+		t.Errorf("expected 2, got %d, %v", n, err)
+	}
+	if n, _ := fake.Count(ctx, "testdb", "users", bson.M{}, moptions.Count().SetSkip(1).SetLimit(1)); n != 1 {
+		t.Errorf("expected skip and limit to apply, got %d", n)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fakeTextScore holds the approximate text score on the documents a $text
+// query matched while the fake sorts and projects them. Stored field names
+// cannot start with $, so it never collides with a real field.
+const fakeTextScore = "$textScore"
+
+// textQuery is the fake's approximation of a $text search: a document
+// matches when one of the terms or phrases occurs as a substring of one of
+// its string values and none of the negated terms do. Stemming, stop words,
+// languages and index weights are not modelled.
+type textQuery struct {
+	terms         []string
+	negated       []string
+	caseSensitive bool
+}
+
+func parseTextQuery(cond any) (textQuery, error) {
+	args, ok := cond.(map[string]any)
+	if !ok {
+		return textQuery{}, fmt.Errorf("$text needs a document")
+	}
+	search, ok := args["$search"].(string)
+	if !ok {
+		return textQuery{}, fmt.Errorf("$text needs a $search string")
+	}
+	q := textQuery{}
+	if cs, ok := args["$caseSensitive"].(bool); ok {
+		q.caseSensitive = cs
+	}
+
+	// Quoted phrases count as one term
+	for i, part := range strings.Split(search, `"`) {
+		if i%2 == 1 {
+			if part = strings.TrimSpace(part); part != "" {
+				q.terms = append(q.terms, q.fold(part))
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if strings.HasPrefix(word, "-") && len(word) > 1 {
+				q.negated = append(q.negated, q.fold(word[1:]))
+			} else {
+				q.terms = append(q.terms, q.fold(word))
+			}
+		}
+	}
+	return q, nil
+}
+
+func (q textQuery) fold(s string) string {
+	if q.caseSensitive {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// score returns how often the terms occur in the string values of doc, or
+// 0 when the document does not match
+func (q textQuery) score(doc map[string]any) float64 {
+	var texts []string
+	collectStrings(doc, &texts)
+
+	total := 0
+	for _, text := range texts {
+		text = q.fold(text)
+		for _, term := range q.negated {
+			if strings.Contains(text, term) {
+				return 0
+			}
+		}
+		for _, term := range q.terms {
+			total += strings.Count(text, term)
+		}
+	}
+	return float64(total)
+}
+
+func collectStrings(v any, out *[]string) {
+	switch t := v.(type) {
+	case string:
+		*out = append(*out, t)
+	case map[string]any:
+		for key, value := range t {
+			if key != fakeTextScore {
+				collectStrings(value, out)
+			}
+		}
+	case []any:
+		for _, value := range t {
+			collectStrings(value, out)
+		}
+	}
+}
+
+// isTextScoreMeta reports whether v is {$meta: "textScore"}
+func isTextScoreMeta(v any) bool {
+	m, ok := normalizeDocument(v).(map[string]any)
+	return ok && len(m) == 1 && m["$meta"] == "textScore"
+}
+
+// scoreText returns copies of docs carrying their text score when filter has
+// a top-level $text clause, and docs unchanged otherwise
+func scoreText(docs []map[string]any, filter any) ([]map[string]any, error) {
+	query, _ := normalizeDocument(filter).(map[string]any)
+	cond, ok := query["$text"]
+	if !ok {
+		return docs, nil
+	}
+	q, err := parseTextQuery(cond)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		scored := make(map[string]any, len(doc)+1)
+		for k, v := range doc {
+			scored[k] = v
+		}
+		scored[fakeTextScore] = q.score(doc)
+		out[i] = scored
+	}
+	return out, nil
+}
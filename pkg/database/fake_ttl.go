@@ -0,0 +1,118 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ttlIndex expires documents ttl after the date stored in field
+type ttlIndex struct {
+	field string
+	ttl   time.Duration
+}
+
+// SetClock replaces the clock TTL expiry is measured against; nil restores
+// the system clock
+func (f *FakeDatabase) SetClock(clock Clock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if clock == nil {
+		clock = systemClock{}
+	}
+	f.clock = clock
+}
+
+// EnsureTTLIndex expires documents of db.collection once the date in field
+// plus ttl is before the clock's now. Expired documents are hidden from reads
+// and filtered writes straight away and removed from the store by
+// RunTTLSweep. Documents without a date in field never expire; for an array
+// of dates the earliest one counts, as in MongoDB.
+func (f *FakeDatabase) EnsureTTLIndex(db string, collection string, field string, ttl time.Duration) error {
+	if field == "" {
+		return fmt.Errorf("fake: TTL index needs a field")
+	}
+	if ttl < 0 {
+		return fmt.Errorf("fake: TTL must not be negative, got %s", ttl)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := fakeNamespace{db, collection}
+	for i, ix := range f.ttls[ns] {
+		if ix.field == field {
+			f.ttls[ns][i].ttl = ttl
+			return nil
+		}
+	}
+	f.ttls[ns] = append(f.ttls[ns], ttlIndex{field: field, ttl: ttl})
+	return nil
+}
+
+// RunTTLSweep removes every expired document, like one pass of MongoDB's TTL
+// monitor, and returns how many were removed
+func (f *FakeDatabase) RunTTLSweep() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	removed := 0
+	for ns := range f.ttls {
+		docs := f.collections[ns]
+		kept := make([]map[string]any, 0, len(docs))
+		for _, doc := range docs {
+			if f.expired(ns, doc) {
+				removed++
+				continue
+			}
+			kept = append(kept, doc)
+		}
+		f.collections[ns] = kept
+	}
+	return removed
+}
+
+// expired reports whether a TTL index of ns has expired doc; the caller holds f.mu
+func (f *FakeDatabase) expired(ns fakeNamespace, doc map[string]any) bool {
+	indexes := f.ttls[ns]
+	if len(indexes) == 0 {
+		return false
+	}
+	now := f.clock.Now()
+	for _, ix := range indexes {
+		v, ok := getPath(doc, ix.field)
+		if !ok {
+			continue
+		}
+		var earliest time.Time
+		found := false
+		values := []any{v}
+		if arr, ok := v.([]any); ok {
+			values = arr
+		}
+		for _, value := range values {
+			if t, ok := asTime(value); ok && (!found || t.Before(earliest)) {
+				earliest, found = t, true
+			}
+		}
+		if found && earliest.Add(ix.ttl).Before(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// live returns the documents of ns that have not expired; the caller holds f.mu
+func (f *FakeDatabase) live(ns fakeNamespace) []map[string]any {
+	docs := f.collections[ns]
+	if len(f.ttls[ns]) == 0 {
+		return docs
+	}
+	out := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		if !f.expired(ns, doc) {
+			out = append(out, doc)
+		}
+	}
+	return out
+}
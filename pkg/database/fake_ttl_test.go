@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestFakeDatabaseTTL(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	setup := func(t *testing.T) (*FakeDatabase, *TestClock) {
+		t.Helper()
+		clock := NewTestClock(start)
+		fake := NewFakeDatabase()
+		fake.SetClock(clock)
+		if err := fake.EnsureTTLIndex("app", "sessions", "lastSeen", time.Hour); err != nil {
+			t.Fatalf("failed to create TTL index: %v", err)
+		}
+		fake.Seed("app", "sessions",
+			bson.M{"_id": "old", "lastSeen": start.Add(-2 * time.Hour)},
+			bson.M{"_id": "fresh", "lastSeen": start.Add(-30 * time.Minute)},
+			bson.M{"_id": "pinned"},
+			bson.M{"_id": "text", "lastSeen": "yesterday"},
+			bson.M{"_id": "many", "lastSeen": bson.A{start, start.Add(-3 * time.Hour)}},
+		)
+		return fake, clock
+	}
+
+	visible := func(t *testing.T, fake *FakeDatabase) []any {
+		t.Helper()
+		result, err := fake.Find(ctx, "app", "sessions", bson.M{})
+		if err != nil {
+			t.Fatalf("find failed: %v", err)
+		}
+		return resultIDs(t, result)
+	}
+
+	t.Run("ReadsHideExpired", func(t *testing.T) {
+		fake, _ := setup(t)
+
+		if got := visible(t, fake); !valuesEqual(got, []any{"fresh", "pinned", "text"}) {
+			t.Errorf("expected only unexpired sessions, got %v", got)
+		}
+		if _, err := fake.FindOne(ctx, "app", "sessions", bson.M{"_id": "old"}); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected an expired document not to be found, got %v", err)
+		}
+		if n, _ := fake.DeleteMany(ctx, "app", "sessions", bson.M{}); n != 3 {
+			t.Errorf("expected filtered writes to skip expired documents, deleted %d", n)
+		}
+	})
+
+	t.Run("ClockAdvances", func(t *testing.T) {
+		fake, clock := setup(t)
+
+		clock.Advance(31 * time.Minute)
+		if got := visible(t, fake); !valuesEqual(got, []any{"pinned", "text"}) {
+			t.Errorf("expected fresh to expire after the clock moved, got %v", got)
+		}
+	})
+
+	t.Run("SweepRemovesExpired", func(t *testing.T) {
+		fake, _ := setup(t)
+
+		if n := len(fake.Documents("app", "sessions")); n != 5 {
+			t.Fatalf("expected expired documents to stay stored until a sweep, got %d", n)
+		}
+		if removed := fake.RunTTLSweep(); removed != 2 {
+			t.Errorf("expected 2 documents removed, got %d", removed)
+		}
+		if n := len(fake.Documents("app", "sessions")); n != 3 {
+			t.Errorf("expected 3 documents after the sweep, got %d", n)
+		}
+	})
+
+	t.Run("TestClock", func(t *testing.T) {
+		clock := NewTestClock(start)
+		clock.Advance(time.Minute)
+		if !clock.Now().Equal(start.Add(time.Minute)) {
+			t.Errorf("expected Advance to move the clock, got %v", clock.Now())
+		}
+		clock.Set(start)
+		if !clock.Now().Equal(start) {
+			t.Errorf("expected Set to move the clock, got %v", clock.Now())
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// WithTransaction implements Transactor. Transactions run one at a time and
+// fn's writes are applied as it makes them; when fn fails, every collection
+// is restored to its state when the transaction began. There is no
+// isolation: reads outside the transaction see its writes, and writes made
+// meanwhile outside it are rolled back with it.
+func (f *FakeDatabase) WithTransaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	if err := f.ready(ctx); err != nil {
+		return err
+	}
+	if ctx.Value(transactionKey{}) == f {
+		return errors.New("fake: nested transaction")
+	}
+	f.tx.Lock()
+	defer f.tx.Unlock()
+
+	f.mu.RLock()
+	snapshot := make(map[fakeNamespace][]map[string]any, len(f.collections))
+	for ns, docs := range f.collections {
+		// Deletes shift the stored slices in place; updates replace documents
+		snapshot[ns] = slices.Clone(docs)
+	}
+	f.mu.RUnlock()
+
+	if err := fn(context.WithValue(ctx, transactionKey{}, f)); err != nil {
+		f.mu.Lock()
+		f.collections = snapshot
+		f.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// maxViewDepth bounds the chain of views over views the fake resolves, as a
+// guard against cycles
+const maxViewDepth = 20
+
+// fakeView is a view stored as its source and pipeline
+type fakeView struct {
+	viewOn   string
+	pipeline []any
+}
+
+// CreateView implements ViewManager. The fake stores the pipeline and runs
+// it over the source whenever the view is read, so it supports the stages
+// Aggregate does.
+func (f *FakeDatabase) CreateView(ctx context.Context, db string, name string, viewOn string, pipeline any) error {
+	if err := f.ready(ctx); err != nil {
+		return err
+	}
+	var stages []any
+	if pipeline != nil {
+		var err error
+		if stages, err = pipelineStages(pipeline); err != nil {
+			return fmt.Errorf("fake: %w", err)
+		}
+		for i, stage := range stages {
+			stages[i] = normalizeDocument(stage)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ns := fakeNamespace{db, name}
+	if _, ok := f.views[ns]; ok {
+		return fmt.Errorf("fake: view %s.%s already exists", db, name)
+	}
+	if _, ok := f.collections[ns]; ok {
+		return fmt.Errorf("fake: collection %s.%s already exists", db, name)
+	}
+	f.views[ns] = fakeView{viewOn: viewOn, pipeline: stages}
+	return nil
+}
+
+// ListViews implements ViewManager
+func (f *FakeDatabase) ListViews(ctx context.Context, db string) ([]ViewInfo, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	views := []ViewInfo{}
+	for ns, view := range f.views {
+		if ns.db != db {
+			continue
+		}
+		pipeline := make([]any, len(view.pipeline))
+		for i, stage := range view.pipeline {
+			pipeline[i] = normalizeDocument(stage)
+		}
+		views = append(views, ViewInfo{Name: ns.collection, ViewOn: view.viewOn, Pipeline: pipeline})
+	}
+	slices.SortFunc(views, func(a, b ViewInfo) int { return cmp.Compare(a.Name, b.Name) })
+	return views, nil
+}
+
+// DropView implements ViewManager
+func (f *FakeDatabase) DropView(ctx context.Context, db string, name string) error {
+	if err := f.ready(ctx); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ns := fakeNamespace{db, name}
+	if _, ok := f.views[ns]; !ok {
+		if _, ok := f.collections[ns]; ok {
+			return fmt.Errorf("drop view %s.%s: %w", db, name, ErrNotView)
+		}
+		return nil
+	}
+	delete(f.views, ns)
+	return nil
+}
+
+// source returns the documents reads of ns start from: the unexpired
+// documents of a collection, or the output of a view's pipeline; the caller
+// holds f.mu
+func (f *FakeDatabase) source(ns fakeNamespace) ([]map[string]any, error) {
+	return f.viewSource(ns, 0)
+}
+
+func (f *FakeDatabase) viewSource(ns fakeNamespace, depth int) ([]map[string]any, error) {
+	view, ok := f.views[ns]
+	if !ok {
+		return f.live(ns), nil
+	}
+	if depth == maxViewDepth {
+		return nil, fmt.Errorf("fake: view %s.%s nests more than %d views", ns.db, ns.collection, maxViewDepth)
+	}
+	docs, err := f.viewSource(fakeNamespace{ns.db, view.viewOn}, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	docs, err = runPipeline(docs, view.pipeline, pipelineEnv{
+		from: func(from string) ([]map[string]any, error) {
+			return f.viewSource(fakeNamespace{ns.db, from}, depth+1)
+		},
+		shuffle: f.shuffle,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fake: view %s.%s: %w", ns.db, ns.collection, err)
+	}
+	return docs, nil
+}
+
+// findView returns the documents of the view ns matching filter shaped by
+// spec; the caller holds f.mu
+func (f *FakeDatabase) findView(ns fakeNamespace, filter any, spec findSpec) ([]map[string]any, error) {
+	if err := filterError(filter); err != nil {
+		return nil, err
+	}
+	docs, err := f.source(ns)
+	if err != nil {
+		return nil, err
+	}
+	var matches []map[string]any
+	for _, doc := range docs {
+		ok, err := matchesFilter(doc, filter)
+		if err != nil {
+			return nil, fmt.Errorf("fake: %w", err)
+		}
+		if ok {
+			matches = append(matches, doc)
+		}
+	}
+	shaped, err := spec.apply(matches)
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	return shaped, nil
+}
+
+// writable fails with ErrViewNotSupported when ns is a view; the caller
+// holds f.mu
+func (f *FakeDatabase) writable(ns fakeNamespace) error {
+	if _, ok := f.views[ns]; ok {
+		return fmt.Errorf("%w: %s.%s is a view, not a collection", ErrViewNotSupported, ns.db, ns.collection)
+	}
+	return nil
+}
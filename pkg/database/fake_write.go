@@ -0,0 +1,515 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertOne stores document, generating an ObjectID _id when it has none
+func (f *FakeDatabase) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.insert(fakeNamespace{db, collection}, document)
+}
+
+// InsertMany stores documents in order and returns their _ids. With the
+// Ordered option set to false it tries every document and reports the ones
+// that failed in a mongo.BulkWriteException, as the server does.
+func (f *FakeDatabase) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ordered := insertOrdered(opts)
+	ids := make([]any, 0, len(documents))
+	var failed []mongo.BulkWriteError
+	for i, doc := range documents {
+		id, err := f.insert(fakeNamespace{db, collection}, doc)
+		if err != nil {
+			if ordered {
+				return ids, fmt.Errorf("fake: document %d: %w", i, err)
+			}
+			failed = append(failed, fakeWriteError(i, err, nil))
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, fakeBulkError(failed)
+}
+
+// UpdateOne applies update to the first matching document, or inserts one
+// when nothing matches and the Upsert option is set
+func (f *FakeDatabase) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.update(fakeNamespace{db, collection}, filter, update, false, updateUpsert(opts))
+}
+
+// UpdateMany applies update to every matching document
+func (f *FakeDatabase) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.update(fakeNamespace{db, collection}, filter, update, true, updateUpsert(opts))
+}
+
+// ReplaceOne replaces the first matching document, keeping its _id
+func (f *FakeDatabase) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	upsert := false
+	for _, o := range optionsOf[moptions.ReplaceOptions](opts) {
+		if o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.replace(fakeNamespace{db, collection}, filter, replacement, upsert)
+}
+
+// DeleteOne removes the first matching document
+func (f *FakeDatabase) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	if err := f.ready(ctx); err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delete(fakeNamespace{db, collection}, filter, false)
+}
+
+// DeleteMany removes every matching document and returns how many were removed
+func (f *FakeDatabase) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	if err := f.ready(ctx); err != nil {
+		return 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.delete(fakeNamespace{db, collection}, filter, true)
+}
+
+// FindOneAndUpdate atomically updates the first matching document and returns
+// it as it was before the update, or after it with options.After
+func (f *FakeDatabase) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	upsert, returnNew := false, false
+	var spec findSpec
+	for _, o := range optionsOf[moptions.FindOneAndUpdateOptions](opts) {
+		if o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+		if o.ReturnDocument != nil {
+			returnNew = *o.ReturnDocument == moptions.After
+		}
+		if o.Sort != nil {
+			spec.sort = o.Sort
+		}
+		if o.Projection != nil {
+			spec.projection = o.Projection
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := fakeNamespace{db, collection}
+	indexes, err := f.matchIndexes(ns, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		if !upsert {
+			return nil, mongo.ErrNoDocuments
+		}
+		if _, err := f.upsert(ns, filter, update); err != nil {
+			return nil, err
+		}
+		if !returnNew {
+			return nil, mongo.ErrNoDocuments
+		}
+		docs := f.collections[ns]
+		return f.projected(docs[len(docs)-1], spec.projection)
+	}
+
+	// With a sort the first document in sort order is updated
+	i := indexes[0]
+	if spec.sort != nil {
+		candidates := make([]map[string]any, len(indexes))
+		for n, idx := range indexes {
+			candidates[n] = f.collections[ns][idx]
+		}
+		order, err := sortOrder(candidates, spec.sort)
+		if err != nil {
+			return nil, fmt.Errorf("fake: %w", err)
+		}
+		i = indexes[order[0]]
+	}
+
+	before := f.collections[ns][i]
+	after := copyDocument(before)
+	if err := applyUpdate(after, update, false); err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	if err := f.checkUnique(ns, after, i); err != nil {
+		return nil, err
+	}
+	f.collections[ns][i] = after
+	if returnNew {
+		return f.projected(after, spec.projection)
+	}
+	return f.projected(before, spec.projection)
+}
+
+// projected returns a projected copy of doc as bson.M
+func (f *FakeDatabase) projected(doc map[string]any, projection any) (any, error) {
+	out, err := projectDocument(doc, projection)
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	return toBSON(out), nil
+}
+
+// BulkWrite applies mongo.WriteModel operations in order, stopping at the
+// first error unless the Ordered option is set to false, which applies every
+// model and reports the failures in a mongo.BulkWriteException
+func (f *FakeDatabase) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	if err := f.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ns := fakeNamespace{db, collection}
+	ordered := bulkOrdered(opts)
+	result := &BulkWriteResult{UpsertedIDs: map[int64]any{}}
+	var failed []mongo.BulkWriteError
+	for i, model := range models {
+		var res *UpdateResult
+		var err error
+		switch m := model.(type) {
+		case *mongo.InsertOneModel:
+			_, err = f.insert(ns, m.Document)
+			if err == nil {
+				result.InsertedCount++
+			}
+		case *mongo.UpdateOneModel:
+			res, err = f.update(ns, m.Filter, m.Update, false, m.Upsert != nil && *m.Upsert)
+		case *mongo.UpdateManyModel:
+			res, err = f.update(ns, m.Filter, m.Update, true, m.Upsert != nil && *m.Upsert)
+		case *mongo.ReplaceOneModel:
+			res, err = f.replace(ns, m.Filter, m.Replacement, m.Upsert != nil && *m.Upsert)
+		case *mongo.DeleteOneModel:
+			var n int64
+			n, err = f.delete(ns, m.Filter, false)
+			result.DeletedCount += n
+		case *mongo.DeleteManyModel:
+			var n int64
+			n, err = f.delete(ns, m.Filter, true)
+			result.DeletedCount += n
+		default:
+			return nil, fmt.Errorf("invalid write model at index %d: %T", i, model)
+		}
+		if err != nil {
+			if ordered {
+				return nil, fmt.Errorf("fake: write model %d: %w", i, err)
+			}
+			failed = append(failed, fakeWriteError(i, err, model))
+			continue
+		}
+		if res != nil {
+			result.MatchedCount += res.MatchedCount
+			result.ModifiedCount += res.ModifiedCount
+			result.UpsertedCount += res.UpsertedCount
+			if res.UpsertedID != nil {
+				result.UpsertedIDs[int64(i)] = res.UpsertedID
+			}
+		}
+	}
+	if err := fakeBulkError(failed); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// insert stores doc and returns its _id; the caller holds f.mu
+func (f *FakeDatabase) insert(ns fakeNamespace, doc any) (any, error) {
+	if err := f.writable(ns); err != nil {
+		return nil, err
+	}
+	stored, err := fakeDocument(doc)
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	if err := f.checkUnique(ns, stored, -1); err != nil {
+		return nil, err
+	}
+	f.collections[ns] = append(f.collections[ns], stored)
+	return stored["_id"], nil
+}
+
+// update applies update to the first or every matching document; the caller holds f.mu
+func (f *FakeDatabase) update(ns fakeNamespace, filter any, update any, many bool, upsert bool) (*UpdateResult, error) {
+	// Validate the update even when nothing matches, as the server does
+	if err := applyUpdate(map[string]any{}, update, true); err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	indexes, err := f.matchIndexes(ns, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 && upsert {
+		return f.upsert(ns, filter, update)
+	}
+	if !many && len(indexes) > 1 {
+		indexes = indexes[:1]
+	}
+
+	result := &UpdateResult{MatchedCount: int64(len(indexes))}
+	for _, i := range indexes {
+		updated := copyDocument(f.collections[ns][i])
+		if err := applyUpdate(updated, update, false); err != nil {
+			return nil, fmt.Errorf("fake: %w", err)
+		}
+		if err := f.checkUnique(ns, updated, i); err != nil {
+			return nil, err
+		}
+		if !valuesEqual(updated, f.collections[ns][i]) {
+			result.ModifiedCount++
+		}
+		f.collections[ns][i] = updated
+	}
+	return result, nil
+}
+
+// upsert inserts the document described by the filter's equality conditions
+// with update applied; the caller holds f.mu
+func (f *FakeDatabase) upsert(ns fakeNamespace, filter any, update any) (*UpdateResult, error) {
+	doc := upsertSeed(filter)
+	if err := applyUpdate(doc, update, true); err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	id, err := f.insert(ns, doc)
+	if err != nil {
+		return nil, err
+	}
+	return &UpdateResult{UpsertedCount: 1, UpsertedID: id}, nil
+}
+
+// replace replaces the first matching document; the caller holds f.mu
+func (f *FakeDatabase) replace(ns fakeNamespace, filter any, replacement any, upsert bool) (*UpdateResult, error) {
+	indexes, err := f.matchIndexes(ns, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		if !upsert {
+			return &UpdateResult{}, nil
+		}
+		doc, err := applyReplacement(upsertSeed(filter), replacement)
+		if err != nil {
+			return nil, fmt.Errorf("fake: %w", err)
+		}
+		id, err := f.insert(ns, doc)
+		if err != nil {
+			return nil, err
+		}
+		return &UpdateResult{UpsertedCount: 1, UpsertedID: id}, nil
+	}
+
+	i := indexes[0]
+	replaced, err := applyReplacement(f.collections[ns][i], replacement)
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	if err := f.checkUnique(ns, replaced, i); err != nil {
+		return nil, err
+	}
+	result := &UpdateResult{MatchedCount: 1}
+	if !valuesEqual(replaced, f.collections[ns][i]) {
+		result.ModifiedCount = 1
+	}
+	f.collections[ns][i] = replaced
+	return result, nil
+}
+
+// delete removes the first or every matching document; the caller holds f.mu
+func (f *FakeDatabase) delete(ns fakeNamespace, filter any, many bool) (int64, error) {
+	indexes, err := f.matchIndexes(ns, filter)
+	if err != nil {
+		return 0, err
+	}
+	if !many && len(indexes) > 1 {
+		indexes = indexes[:1]
+	}
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+
+	remove := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		remove[i] = true
+	}
+	docs := f.collections[ns]
+	kept := make([]map[string]any, 0, len(docs)-len(indexes))
+	for i, doc := range docs {
+		if !remove[i] {
+			kept = append(kept, doc)
+		}
+	}
+	f.collections[ns] = kept
+	return int64(len(indexes)), nil
+}
+
+// matchIndexes returns the positions of the unexpired documents matching
+// filter, failing for a view, which find reads with findView; the caller
+// holds f.mu
+func (f *FakeDatabase) matchIndexes(ns fakeNamespace, filter any) ([]int, error) {
+	if err := filterError(filter); err != nil {
+		return nil, err
+	}
+	if err := f.writable(ns); err != nil {
+		return nil, err
+	}
+	var indexes []int
+	ids, byID := idInFilter(filter)
+	for i, doc := range f.collections[ns] {
+		if f.expired(ns, doc) {
+			continue
+		}
+		if byID {
+			if ids[idKey(doc["_id"])] {
+				indexes = append(indexes, i)
+			}
+			continue
+		}
+		ok, err := matchesFilter(doc, filter)
+		if err != nil {
+			return nil, fmt.Errorf("fake: %w", err)
+		}
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// idInFilter returns the keys of the IDs of a filter that only has an _id $in
+// list, like the queries of FindByIDs, which the fake answers from the keys
+// instead of comparing every document with every ID
+func idInFilter(filter any) (map[string]bool, bool) {
+	query, ok := normalizeDocument(filter).(map[string]any)
+	if !ok || len(query) != 1 {
+		return nil, false
+	}
+	ops, ok := query["_id"].(map[string]any)
+	if !ok || len(ops) != 1 {
+		return nil, false
+	}
+	list, ok := ops["$in"].([]any)
+	if !ok {
+		return nil, false
+	}
+	keys := make(map[string]bool, len(list))
+	for _, id := range list {
+		switch id.(type) {
+		case nil, map[string]any, []any, primitive.Regex:
+			// Matched by the general path: missing fields, documents and
+			// patterns
+			return nil, false
+		}
+		keys[idKey(id)] = true
+	}
+	return keys, true
+}
+
+// updateUpsert reports whether the UpdateOptions in opts request an upsert
+func updateUpsert(opts []any) bool {
+	upsert := false
+	for _, o := range optionsOf[moptions.UpdateOptions](opts) {
+		if o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+	}
+	return upsert
+}
+
+// insertOrdered reports whether the InsertManyOptions in opts keep the
+// default ordered insert
+func insertOrdered(opts []any) bool {
+	ordered := true
+	for _, o := range optionsOf[moptions.InsertManyOptions](opts) {
+		if o.Ordered != nil {
+			ordered = *o.Ordered
+		}
+	}
+	return ordered
+}
+
+// bulkOrdered reports whether the BulkWriteOptions in opts keep the default
+// ordered bulk write
+func bulkOrdered(opts []any) bool {
+	ordered := true
+	for _, o := range optionsOf[moptions.BulkWriteOptions](opts) {
+		if o.Ordered != nil {
+			ordered = *o.Ordered
+		}
+	}
+	return ordered
+}
+
+// fakeWriteError describes the failed write at index the way the server
+// does, with the duplicate key code for unique index violations
+func fakeWriteError(index int, err error, model any) mongo.BulkWriteError {
+	code := codeBadValue
+	if errors.Is(err, ErrDuplicateKey) {
+		code = codeDuplicateKey
+	}
+	request, _ := model.(mongo.WriteModel)
+	return mongo.BulkWriteError{
+		WriteError: mongo.WriteError{Index: index, Code: code, Message: err.Error()},
+		Request:    request,
+	}
+}
+
+// fakeBulkError returns the error of an unordered write that failed for the
+// writes in failed, mapped as MongoClient maps the server's
+func fakeBulkError(failed []mongo.BulkWriteError) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return mapError(mongo.BulkWriteException{WriteErrors: failed})
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestFakeDatabaseWrites(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("CreateUpdateList", func(t *testing.T) {
+		fake := NewFakeDatabase()
+
+		id, err := fake.InsertOne(ctx, "testdb", "orders", bson.M{"customer": "alice", "status": "new", "total": 10})
+		if err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+		if _, ok := id.(primitive.ObjectID); !ok {
+			t.Fatalf("expected generated ObjectID, got %T", id)
+		}
+		fake.InsertOne(ctx, "testdb", "orders", bson.M{"_id": "o-2", "customer": "bob", "status": "new", "total": 5})
+
+		res, err := fake.UpdateOne(ctx, "testdb", "orders", bson.M{"_id": id}, bson.M{
+			"$set":  bson.M{"status": "paid"},
+			"$inc":  bson.M{"total": 5},
+			"$push": bson.M{"history": "paid"},
+		})
+		if err != nil || res.MatchedCount != 1 || res.ModifiedCount != 1 {
+			t.Fatalf("expected one modified document, got %+v, %v", res, err)
+		}
+
+		result, _ := fake.Find(ctx, "testdb", "orders", bson.M{"status": "paid", "total": 15, "history": "paid"})
+		if docs := result.([]any); len(docs) != 1 || docs[0].(bson.M)["customer"] != "alice" {
+			t.Errorf("expected the updated order to be listed, got %v", docs)
+		}
+	})
+
+	t.Run("UpdateOneOnlyTouchesFirstMatch", func(t *testing.T) {
+		fake := seededFake(t)
+
+		res, err := fake.UpdateOne(ctx, "testdb", "users", bson.M{"tags": "ops"}, bson.M{"$pull": bson.M{"tags": "ops"}})
+		if err != nil || res.MatchedCount != 1 {
+			t.Fatalf("expected one match, got %+v, %v", res, err)
+		}
+		result, _ := fake.Find(ctx, "testdb", "users", bson.M{"tags": "ops"})
+		if docs := result.([]any); len(docs) != 1 || docs[0].(bson.M)["name"] != "bob" {
+			t.Errorf("expected only bob to keep the ops tag, got %v", docs)
+		}
+	})
+
+	t.Run("UpdateManyCountsUnmodified", func(t *testing.T) {
+		fake := seededFake(t)
+
+		res, err := fake.UpdateMany(ctx, "testdb", "users", bson.M{}, bson.M{"$set": bson.M{"name": "bob"}})
+		if err != nil || res.MatchedCount != 3 || res.ModifiedCount != 2 {
+			t.Errorf("expected 3 matched and 2 modified, got %+v, %v", res, err)
+		}
+	})
+
+	t.Run("UnknownUpdateOperator", func(t *testing.T) {
+		fake := seededFake(t)
+
+		_, err := fake.UpdateOne(ctx, "testdb", "users", bson.M{"name": "nobody"}, bson.M{"$currentDate": bson.M{"seen": true}})
+		if err == nil || !strings.Contains(err.Error(), "$currentDate") {
+			t.Errorf("expected error naming $currentDate, got %v", err)
+		}
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		opts := moptions.Update().SetUpsert(true)
+
+		res, err := fake.UpdateOne(ctx, "testdb", "counters", bson.M{"name": "visits"},
+			bson.M{"$inc": bson.M{"n": 1}, "$setOnInsert": bson.M{"created": true}}, opts)
+		if err != nil || res.UpsertedCount != 1 || res.UpsertedID == nil {
+			t.Fatalf("expected an upsert, got %+v, %v", res, err)
+		}
+		fake.UpdateOne(ctx, "testdb", "counters", bson.M{"name": "visits"}, bson.M{"$inc": bson.M{"n": 1}}, opts)
+
+		docs := fake.Documents("testdb", "counters")
+		if len(docs) != 1 || docs[0]["n"] != int32(2) || docs[0]["created"] != true {
+			t.Errorf("expected one counter at 2, got %v", docs)
+		}
+	})
+
+	t.Run("ReplaceOneKeepsID", func(t *testing.T) {
+		fake := seededFake(t)
+
+		res, err := fake.ReplaceOne(ctx, "testdb", "users", bson.M{"_id": 2}, bson.M{"name": "robert"})
+		if err != nil || res.ModifiedCount != 1 {
+			t.Fatalf("expected a replacement, got %+v, %v", res, err)
+		}
+		doc, _ := fake.FindOne(ctx, "testdb", "users", bson.M{"_id": 2})
+		if m := doc.(bson.M); m["name"] != "robert" || m["age"] != nil {
+			t.Errorf("expected replaced document, got %v", m)
+		}
+	})
+
+	t.Run("DeleteManyReturnsCount", func(t *testing.T) {
+		fake := seededFake(t)
+
+		n, err := fake.DeleteMany(ctx, "testdb", "users", bson.M{"age": bson.M{"$lt": 40}})
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 deleted, got %d, %v", n, err)
+		}
+		n, _ = fake.DeleteOne(ctx, "testdb", "users", bson.M{})
+		if n != 1 || len(fake.Documents("testdb", "users")) != 0 {
+			t.Errorf("expected the collection to be empty, got %d deleted", n)
+		}
+	})
+
+	t.Run("FindOneAndUpdate", func(t *testing.T) {
+		fake := seededFake(t)
+
+		before, err := fake.FindOneAndUpdate(ctx, "testdb", "users", bson.M{"name": "bob"}, bson.M{"$inc": bson.M{"age": 1}})
+		if err != nil || before.(bson.M)["age"] != int32(25) {
+			t.Fatalf("expected the original document by default, got %v, %v", before, err)
+		}
+
+		after, err := fake.FindOneAndUpdate(ctx, "testdb", "users", bson.M{"name": "bob"}, bson.M{"$inc": bson.M{"age": 1}},
+			moptions.FindOneAndUpdate().SetReturnDocument(moptions.After))
+		if err != nil || after.(bson.M)["age"] != int32(27) {
+			t.Errorf("expected the updated document with ReturnDocument After, got %v, %v", after, err)
+		}
+
+		_, err = fake.FindOneAndUpdate(ctx, "testdb", "users", bson.M{"name": "nobody"}, bson.M{"$set": bson.M{"x": 1}})
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected mongo.ErrNoDocuments, got %v", err)
+		}
+	})
+
+	t.Run("BulkWrite", func(t *testing.T) {
+		fake := seededFake(t)
+
+		res, err := fake.BulkWrite(ctx, "testdb", "users", []any{
+			mongo.NewInsertOneModel().SetDocument(bson.M{"name": "dave"}),
+			mongo.NewUpdateManyModel().SetFilter(bson.M{"age": bson.M{"$gt": 30}}).SetUpdate(bson.M{"$set": bson.M{"senior": true}}),
+			mongo.NewUpdateOneModel().SetFilter(bson.M{"name": "erin"}).SetUpdate(bson.M{"$set": bson.M{"age": 20}}).SetUpsert(true),
+			mongo.NewDeleteOneModel().SetFilter(bson.M{"name": "bob"}),
+		})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if res.InsertedCount != 1 || res.MatchedCount != 2 || res.ModifiedCount != 2 || res.UpsertedCount != 1 || res.DeletedCount != 1 {
+			t.Errorf("unexpected bulk result %+v", res)
+		}
+		if _, ok := res.UpsertedIDs[2]; !ok {
+			t.Errorf("expected upserted id at index 2, got %v", res.UpsertedIDs)
+		}
+		if n := len(fake.Documents("testdb", "users")); n != 4 {
+			t.Errorf("expected 4 users, got %d", n)
+		}
+	})
+
+	t.Run("Unordered", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.EnsureUniqueIndex("testdb", "users", "name")
+		fake.Seed("testdb", "users", bson.M{"name": "alice"})
+
+		ids, err := fake.InsertMany(ctx, "testdb", "users", []any{
+			bson.M{"name": "alice"}, bson.M{"name": "bob"}, bson.M{"name": "bob"}, bson.M{"name": "carol"},
+		}, moptions.InsertMany().SetOrdered(false))
+		var bwe mongo.BulkWriteException
+		if !errors.Is(err, ErrDuplicateKey) || !errors.As(err, &bwe) || len(ids) != 2 {
+			t.Fatalf("expected a bulk write exception after inserting bob and carol, got %v and %v", ids, err)
+		}
+		if len(bwe.WriteErrors) != 2 || bwe.WriteErrors[0].Index != 0 || bwe.WriteErrors[1].Index != 2 || !mongo.IsDuplicateKeyError(err) {
+			t.Errorf("expected duplicate key errors at 0 and 2, got %+v", bwe.WriteErrors)
+		}
+
+		_, err = fake.BulkWrite(ctx, "testdb", "users", []any{
+			mongo.NewInsertOneModel().SetDocument(bson.M{"name": "carol"}),
+			mongo.NewInsertOneModel().SetDocument(bson.M{"name": "dave"}),
+		}, moptions.BulkWrite().SetOrdered(false))
+		if !errors.As(err, &bwe) || len(bwe.WriteErrors) != 1 || bwe.WriteErrors[0].Index != 0 {
+			t.Errorf("expected a duplicate key error at 0, got %v", err)
+		}
+		if n := len(fake.Documents("testdb", "users")); n != 4 {
+			t.Errorf("expected 4 users, got %d", n)
+		}
+	})
+}
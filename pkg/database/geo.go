@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// NearSphere returns a filter matching documents whose GeoJSON point in field
+// lies within maxMeters of (lng, lat), nearest first. A maxMeters of 0 puts
+// no limit on the distance. Coordinates are longitude first, as in GeoJSON;
+// a latitude outside [-90, 90] is reported as likely swapped. The query
+// needs a 2dsphere index on field, see GeoIndex.
+func NearSphere(field string, lng float64, lat float64, maxMeters float64) (bson.M, error) {
+	if err := checkPosition(lng, lat); err != nil {
+		return nil, err
+	}
+	if maxMeters < 0 {
+		return nil, fmt.Errorf("geo: maximum distance must not be negative, got %v", maxMeters)
+	}
+	near := bson.M{"$geometry": bson.M{"type": "Point", "coordinates": bson.A{lng, lat}}}
+	if maxMeters > 0 {
+		near["$maxDistance"] = maxMeters
+	}
+	return bson.M{field: bson.M{"$nearSphere": near}}, nil
+}
+
+// WithinPolygon returns a filter matching documents whose GeoJSON geometry in
+// field lies within the polygon with the given [lng, lat] vertices. The ring
+// is closed automatically when the last vertex differs from the first.
+func WithinPolygon(field string, coords [][2]float64) (bson.M, error) {
+	ring := make(bson.A, 0, len(coords)+1)
+	for i, c := range coords {
+		if err := checkPosition(c[0], c[1]); err != nil {
+			return nil, fmt.Errorf("vertex %d: %w", i, err)
+		}
+		ring = append(ring, bson.A{c[0], c[1]})
+	}
+	if len(coords) > 0 && coords[0] != coords[len(coords)-1] {
+		ring = append(ring, bson.A{coords[0][0], coords[0][1]})
+	}
+	if len(ring) < 4 {
+		return nil, fmt.Errorf("geo: polygon needs at least 3 distinct vertices, got %d", len(coords))
+	}
+	return withinGeometry(field, ring), nil
+}
+
+// WithinBox returns a filter matching documents whose GeoJSON geometry in
+// field lies within the box between the [lng, lat] corners bottomLeft and
+// topRight. The box is sent as a GeoJSON polygon, so it also works against
+// GeoJSON points, which the legacy $box operator does not match.
+func WithinBox(field string, bottomLeft [2]float64, topRight [2]float64) (bson.M, error) {
+	for _, c := range [][2]float64{bottomLeft, topRight} {
+		if err := checkPosition(c[0], c[1]); err != nil {
+			return nil, err
+		}
+	}
+	if bottomLeft[0] >= topRight[0] || bottomLeft[1] >= topRight[1] {
+		return nil, fmt.Errorf("geo: bottom-left corner %v must be below and left of top-right corner %v", bottomLeft, topRight)
+	}
+	return withinGeometry(field, bson.A{
+		bson.A{bottomLeft[0], bottomLeft[1]},
+		bson.A{topRight[0], bottomLeft[1]},
+		bson.A{topRight[0], topRight[1]},
+		bson.A{bottomLeft[0], topRight[1]},
+		bson.A{bottomLeft[0], bottomLeft[1]},
+	}), nil
+}
+
+func withinGeometry(field string, ring bson.A) bson.M {
+	return bson.M{field: bson.M{"$geoWithin": bson.M{
+		"$geometry": bson.M{"type": "Polygon", "coordinates": bson.A{ring}},
+	}}}
+}
+
+// checkPosition validates a GeoJSON position; an out of range latitude is the
+// usual sign of coordinates given as (lat, lng)
+func checkPosition(lng float64, lat float64) error {
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("geo: latitude %v out of range [-90, 90]; positions are (longitude, latitude), are they swapped?", lat)
+	}
+	if lng < -180 || lng > 180 {
+		return fmt.Errorf("geo: longitude %v out of range [-180, 180]", lng)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestMongoNearSphere runs a near query against a real MongoDB:
+// go test -tags integration with MONGODB_URI set
+func TestMongoNearSphere(t *testing.T) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+
+	ctx := context.Background()
+	db, err := New(NewMongoOptions().SetUri(mongodbUri).SetTimeout(5000).Build())
+	if err != nil {
+		t.Fatalf("failed to create database instance: %v", err)
+	}
+	const database, collection = "database_geo", "cameras"
+	db.Client.DeleteMany(ctx, database, collection, bson.M{})
+	t.Cleanup(func() { db.Client.DeleteMany(ctx, database, collection, bson.M{}) })
+
+	if err := EnsureIndexes(ctx, db.Client, database, collection, GeoIndex("location")); err != nil {
+		t.Fatalf("failed to create the 2dsphere index: %v", err)
+	}
+	point := func(lng, lat float64) bson.M {
+		return bson.M{"type": "Point", "coordinates": bson.A{lng, lat}}
+	}
+	_, err = db.Client.InsertMany(ctx, database, collection, []any{
+		bson.M{"_id": "far", "location": point(3.80, 51.05)},      // about 5.6 km east
+		bson.M{"_id": "near", "location": point(3.7205, 51.0505)}, // about 70 m away
+		bson.M{"_id": "mid", "location": point(3.7250, 51.0520)},  // about 450 m away
+	})
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	filter, err := NearSphere("location", 3.72, 51.05, 1000)
+	if err != nil {
+		t.Fatalf("failed to build the filter: %v", err)
+	}
+	result, err := db.Client.Find(ctx, database, collection, filter)
+	if err != nil {
+		t.Fatalf("near query failed: %v", err)
+	}
+	docs := result.([]any)
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 cameras within 1 km, got %v", docs)
+	}
+	first := normalizeDocument(docs[0]).(map[string]any)["_id"]
+	if first != "near" {
+		t.Errorf("expected the nearest camera first, got %v", first)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGeoFilters(t *testing.T) {
+	square := bson.A{bson.A{3.0, 51.0}, bson.A{4.0, 51.0}, bson.A{4.0, 52.0}, bson.A{3.0, 52.0}, bson.A{3.0, 51.0}}
+	within := func(ring bson.A) bson.M {
+		return bson.M{"loc": bson.M{"$geoWithin": bson.M{"$geometry": bson.M{"type": "Polygon", "coordinates": bson.A{ring}}}}}
+	}
+
+	tests := []struct {
+		name    string
+		build   func() (bson.M, error)
+		want    bson.M
+		wantErr string
+	}{
+		{
+			name:  "NearSphere",
+			build: func() (bson.M, error) { return NearSphere("loc", 3.72, 51.05, 500) },
+			want: bson.M{"loc": bson.M{"$nearSphere": bson.M{
+				"$geometry":    bson.M{"type": "Point", "coordinates": bson.A{3.72, 51.05}},
+				"$maxDistance": 500.0,
+			}}},
+		},
+		{
+			name:  "NearSphereWithoutLimit",
+			build: func() (bson.M, error) { return NearSphere("loc", 3.72, 51.05, 0) },
+			want:  bson.M{"loc": bson.M{"$nearSphere": bson.M{"$geometry": bson.M{"type": "Point", "coordinates": bson.A{3.72, 51.05}}}}},
+		},
+		{name: "SwappedCoordinates", build: func() (bson.M, error) { return NearSphere("loc", 51.05, 103.72, 500) }, wantErr: "swapped"},
+		{name: "LongitudeOutOfRange", build: func() (bson.M, error) { return NearSphere("loc", 181, 0, 500) }, wantErr: "longitude"},
+		{name: "NegativeDistance", build: func() (bson.M, error) { return NearSphere("loc", 0, 0, -1) }, wantErr: "negative"},
+		{
+			name: "PolygonIsClosed",
+			build: func() (bson.M, error) {
+				return WithinPolygon("loc", [][2]float64{{3, 51}, {4, 51}, {4, 52}, {3, 52}})
+			},
+			want: within(square),
+		},
+		{
+			name: "ClosedPolygonUnchanged",
+			build: func() (bson.M, error) {
+				return WithinPolygon("loc", [][2]float64{{3, 51}, {4, 51}, {4, 52}, {3, 52}, {3, 51}})
+			},
+			want: within(square),
+		},
+		{name: "PolygonTooSmall", build: func() (bson.M, error) { return WithinPolygon("loc", [][2]float64{{3, 51}, {4, 51}}) }, wantErr: "at least 3"},
+		{name: "PolygonVertexSwapped", build: func() (bson.M, error) { return WithinPolygon("loc", [][2]float64{{3, 51}, {4, 51}, {52, 104}}) }, wantErr: "vertex 2"},
+		{
+			name:  "Box",
+			build: func() (bson.M, error) { return WithinBox("loc", [2]float64{3, 51}, [2]float64{4, 52}) },
+			want:  within(square),
+		},
+		{name: "BoxCornersReversed", build: func() (bson.M, error) { return WithinBox("loc", [2]float64{4, 52}, [2]float64{3, 51}) }, wantErr: "bottom-left"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !valuesEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
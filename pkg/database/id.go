@@ -0,0 +1,152 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidID is returned for an ID that is not 24 hex characters, by
+// ParseID and by operations given a ByID filter built from one
+var ErrInvalidID = errors.New("invalid id")
+
+// ID is a document ID stored as a BSON ObjectID and written to JSON as its
+// hex string, so it can go straight from a URL into a filter and back out in
+// a response
+type ID primitive.ObjectID
+
+// NewID returns a new unique ID
+func NewID() ID {
+	return ID(primitive.NewObjectID())
+}
+
+// ParseID parses the 24 character hex form of an ID
+func ParseID(s string) (ID, error) {
+	oid, err := primitive.ObjectIDFromHex(s)
+	if err != nil {
+		return ID{}, fmt.Errorf("%w %q: %w", ErrInvalidID, s, err)
+	}
+	return ID(oid), nil
+}
+
+// Hex returns the 24 character hex form
+func (id ID) Hex() string {
+	return primitive.ObjectID(id).Hex()
+}
+
+// String returns the hex form
+func (id ID) String() string {
+	return id.Hex()
+}
+
+// ObjectID returns the ID as the driver's ObjectID
+func (id ID) ObjectID() primitive.ObjectID {
+	return primitive.ObjectID(id)
+}
+
+// IsZero reports whether the ID is unset
+func (id ID) IsZero() bool {
+	return primitive.ObjectID(id).IsZero()
+}
+
+// MarshalBSONValue encodes the ID as an ObjectID
+func (id ID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(primitive.ObjectID(id))
+}
+
+// UnmarshalBSONValue decodes an ObjectID, or its hex form stored as a string
+func (id *ID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bson.TypeObjectID:
+		*id = ID(raw.ObjectID())
+		return nil
+	case bson.TypeString:
+		parsed, err := ParseID(raw.StringValue())
+		if err != nil {
+			return err
+		}
+		*id = parsed
+		return nil
+	}
+	return fmt.Errorf("cannot decode %s into an ID", t)
+}
+
+// MarshalJSON encodes the ID as its hex string
+func (id ID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.Hex())
+}
+
+// UnmarshalJSON decodes a hex string; null and "" leave the ID zero
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+	if s == nil || *s == "" {
+		*id = ID{}
+		return nil
+	}
+	parsed, err := ParseID(*s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// IDFilter is the _id filter built by ByID. A filter built from an invalid
+// ID carries the error, which every operation given the filter returns.
+type IDFilter struct {
+	id  ID
+	err error
+}
+
+// ByID returns a filter matching the document with the given _id, given as
+// an ID, an ObjectID or a hex string. Operations given the filter of an
+// invalid hex string, or of another type, fail with ErrInvalidID instead of
+// matching nothing.
+//
+//	doc, err := db.Client.FindOne(ctx, "vault", "cameras", database.ByID(r.PathValue("id")))
+func ByID(idOrHex any) IDFilter {
+	switch v := idOrHex.(type) {
+	case ID:
+		return IDFilter{id: v}
+	case primitive.ObjectID:
+		return IDFilter{id: ID(v)}
+	case string:
+		id, err := ParseID(v)
+		return IDFilter{id: id, err: err}
+	}
+	return IDFilter{err: fmt.Errorf("%w: cannot filter by %T", ErrInvalidID, idOrHex)}
+}
+
+// ID returns the ID the filter matches
+func (f IDFilter) ID() ID {
+	return f.id
+}
+
+// Err returns the error of an invalid ID, or nil
+func (f IDFilter) Err() error {
+	return f.err
+}
+
+// MarshalBSON encodes {_id: ObjectID}, or fails for an invalid ID
+func (f IDFilter) MarshalBSON() ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return bson.Marshal(bson.D{{Key: "_id", Value: primitive.ObjectID(f.id)}})
+}
+
+// filterError returns the error of an invalid ByID filter
+func filterError(filter any) error {
+	if f, ok := filter.(IDFilter); ok {
+		return f.err
+	}
+	return nil
+}
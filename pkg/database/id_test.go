@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type camera struct {
+	ID   ID     `bson:"_id" json:"id"`
+	Name string `bson:"name" json:"name"`
+}
+
+func TestParseID(t *testing.T) {
+	const hex = "65a1f0c2e4b0a1b2c3d4e5f6"
+	id, err := ParseID(hex)
+	if err != nil || id.Hex() != hex || id.String() != hex || id.ObjectID().Hex() != hex {
+		t.Errorf("expected %s, got %s, %v", hex, id, err)
+	}
+
+	for _, invalid := range []string{"", "65a1f0c2", "65a1f0c2e4b0a1b2c3d4e5zz"} {
+		if _, err := ParseID(invalid); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID for %q, got %v", invalid, err)
+		}
+	}
+
+	if a, b := NewID(), NewID(); a == b || a.IsZero() {
+		t.Errorf("expected distinct non-zero IDs, got %s and %s", a, b)
+	}
+}
+
+func TestIDEncoding(t *testing.T) {
+	want := camera{ID: NewID(), Name: "front"}
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(want)
+		if err != nil || string(data) != `{"id":"`+want.ID.Hex()+`","name":"front"}` {
+			t.Fatalf("expected the ID as a hex string, got %s, %v", data, err)
+		}
+		var got camera
+		if err := json.Unmarshal(data, &got); err != nil || got != want {
+			t.Errorf("expected %+v, got %+v, %v", want, got, err)
+		}
+		if err := json.Unmarshal([]byte(`{"id":"nope"}`), &got); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID, got %v", err)
+		}
+		if err := json.Unmarshal([]byte(`{"id":null}`), &got); err != nil || !got.ID.IsZero() {
+			t.Errorf("expected null to leave the ID zero, got %v, %v", got.ID, err)
+		}
+	})
+
+	t.Run("BSON", func(t *testing.T) {
+		data, err := bson.Marshal(want)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		if _, ok := bson.Raw(data).Lookup("_id").ObjectIDOK(); !ok {
+			t.Error("expected the ID stored as an ObjectID")
+		}
+		var got camera
+		if err := bson.Unmarshal(data, &got); err != nil || got != want {
+			t.Errorf("expected %+v, got %+v, %v", want, got, err)
+		}
+		if err := decodeDocument(bson.M{"_id": want.ID.Hex()}, &got); err != nil || got.ID != want.ID {
+			t.Errorf("expected a hex string to decode, got %v, %v", got.ID, err)
+		}
+		if err := decodeDocument(bson.M{"_id": 42}, &got); err == nil {
+			t.Error("expected an error decoding a number into an ID")
+		}
+	})
+}
+
+func TestByID(t *testing.T) {
+	ctx := context.Background()
+	id := NewID()
+
+	tests := []struct {
+		name    string
+		value   any
+		wantErr bool
+	}{
+		{"ID", id, false},
+		{"ObjectID", id.ObjectID(), false},
+		{"Hex", id.Hex(), false},
+		{"InvalidHex", "not-an-id", true},
+		{"OtherType", 42, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeDatabase()
+			if err := fake.Seed("vault", "cameras", camera{ID: id, Name: "front"}, camera{ID: NewID(), Name: "back"}); err != nil {
+				t.Fatalf("failed to seed: %v", err)
+			}
+
+			doc, err := fake.FindOne(ctx, "vault", "cameras", ByID(tt.value))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidID) {
+					t.Errorf("expected ErrInvalidID, got %v", err)
+				}
+				return
+			}
+			if err != nil || doc.(bson.M)["name"] != "front" {
+				t.Errorf("expected the front camera, got %v, %v", doc, err)
+			}
+		})
+	}
+
+	t.Run("InvalidOnEmptyCollection", func(t *testing.T) {
+		if _, err := NewFakeDatabase().DeleteMany(ctx, "vault", "cameras", ByID("zz")); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("FindOneAs", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.Seed("vault", "cameras", camera{ID: id, Name: "front"})
+		db, err := New(NewMongoOptions().SetUri("mongodb://localhost").SetTimeout(5000).Build(), fake)
+		if err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		got, err := FindOneAs[camera](ctx, db, "vault", "cameras", ByID(id.Hex()))
+		if err != nil || got.ID != id {
+			t.Errorf("expected _id decoded into the ID, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("MockAssertsByHex", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFindOne("vault", "cameras").WithFilter(FilterContains(map[string]any{"_id": id.Hex()})).Return(bson.M{"name": "front"}, nil)
+
+		doc, err := mock.FindOne(ctx, "vault", "cameras", ByID(id))
+		if err != nil || doc.(bson.M)["name"] != "front" {
+			t.Fatalf("expected the expectation to match by hex, got %v, %v", doc, err)
+		}
+		call, _ := mock.LastFindOneCall()
+		if !FilterEquals(bson.M{"_id": id.Hex()})(call.Filter) || !FilterEquals(bson.M{"_id": id.ObjectID()})(call.Filter) {
+			t.Errorf("expected the recorded filter to equal the hex and ObjectID forms, got %v", call.Filter)
+		}
+	})
+
+	t.Run("MockRejectsInvalid", func(t *testing.T) {
+		mock := NewMockDatabase()
+		if _, err := mock.UpdateOne(ctx, "vault", "cameras", ByID("zz"), U().Set("name", "x")); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID, got %v", err)
+		}
+		if history := mock.History(); len(history) != 1 || history[0].Source != SourceInvalid {
+			t.Errorf("expected one call with source %q, got %+v", SourceInvalid, history)
+		}
+	})
+
+	t.Run("ObjectIDFilterStillMatches", func(t *testing.T) {
+		if !FilterContains(map[string]any{"_id": primitive.ObjectID(id)})(bson.M{"_id": id.Hex()}) {
+			t.Error("expected ObjectID and hex forms to match both ways")
+		}
+	})
+}
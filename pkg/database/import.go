@@ -0,0 +1,269 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultImportBatchSize is the number of documents Import writes at once
+// when ImportOptions.BatchSize is not set
+const defaultImportBatchSize = 1000
+
+// maxImportFailures bounds the failures an ImportReport lists; Failed keeps
+// counting past it
+const maxImportFailures = 1000
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	// BatchSize is the number of documents written at once, 1000 by default
+	BatchSize int
+	// KeyFields upserts every document instead of inserting it, replacing
+	// the stored document with the same values of these fields, such as
+	// []string{"_id"}. Each document must hold every key field.
+	KeyFields []string
+	// ContinueOnError records documents that fail to parse, validate or
+	// write in the report and goes on with the next line, instead of
+	// stopping at the first failure
+	ContinueOnError bool
+	// DryRun parses and validates every line without writing anything
+	DryRun bool
+	// Validate is called with every parsed document; an error fails the
+	// document's line
+	Validate func(doc bson.D) error
+}
+
+// ImportFailure is a line Import could not import
+type ImportFailure struct {
+	// Line is the 1-based line number in the decompressed input
+	Line int64
+	Err  error
+}
+
+// ImportReport describes an import
+type ImportReport struct {
+	// Lines is the number of lines read, blank ones included
+	Lines int64
+	// Documents is the number of documents parsed and validated
+	Documents int64
+	// Written is the number of documents inserted or upserted
+	Written int64
+	// Failed is the number of lines that failed; Failures lists the first
+	// thousand of them
+	Failed   int64
+	Failures []ImportFailure
+	Duration time.Duration
+	DryRun   bool
+}
+
+// Import reads Extended JSON Lines from r, as written by Export or
+// mongoexport, and inserts the documents into collection in batches, or
+// upserts them by ImportOptions.KeyFields. Gzip input is detected and
+// decompressed. Blank lines are skipped.
+//
+// Without ContinueOnError, Import stops at the first failing line and returns
+// an error naming it; the documents of earlier lines are written, and when the
+// failure is a write error the rest of its batch is too, as batches are
+// written unordered. With ContinueOnError the failures are only recorded in
+// the report and the error is nil unless reading r or a whole batch fails.
+//
+//	f, _ := os.Open("customer-events.jsonl.gz")
+//	defer f.Close()
+//	report, err := db.Import(ctx, "vault", "events", f, database.ImportOptions{KeyFields: []string{"_id"}})
+func (d *Database) Import(ctx context.Context, db string, collection string, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	start := time.Now()
+	report := &ImportReport{DryRun: opts.DryRun}
+	err := d.importLines(ctx, db, collection, r, opts, report)
+	report.Duration = time.Since(start)
+	if err != nil {
+		return report, fmt.Errorf("import %s.%s: %w", db, collection, err)
+	}
+	return report, nil
+}
+
+// importLine is a parsed document waiting in a batch
+type importLine struct {
+	line int64
+	doc  bson.D
+}
+
+func (d *Database) importLines(ctx context.Context, db string, collection string, r io.Reader, opts ImportOptions, report *ImportReport) error {
+	in, err := decompressed(r)
+	if err != nil {
+		return err
+	}
+	size := opts.BatchSize
+	if size <= 0 {
+		size = defaultImportBatchSize
+	}
+
+	// fail records a failed line and, unless the import continues past
+	// failures, returns the error that stops it
+	fail := func(line int64, err error) error {
+		report.Failed++
+		if len(report.Failures) < maxImportFailures {
+			report.Failures = append(report.Failures, ImportFailure{Line: line, Err: err})
+		}
+		if opts.ContinueOnError {
+			return nil
+		}
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+
+	batch := make([]importLine, 0, size)
+	flush := func() error {
+		if len(batch) == 0 || opts.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+		failed, err := d.importBatch(ctx, db, collection, batch, opts.KeyFields)
+		if err != nil {
+			return fmt.Errorf("lines %d to %d: %w", batch[0].line, batch[len(batch)-1].line, err)
+		}
+		report.Written += int64(len(batch) - len(failed))
+		var stop error
+		for _, we := range failed {
+			var err error = we
+			if we.Code == codeDuplicateKey {
+				err = fmt.Errorf("%w: %w", ErrDuplicateKey, we)
+			}
+			if err := fail(batch[we.Index].line, err); err != nil && stop == nil {
+				stop = err
+			}
+		}
+		batch = batch[:0]
+		return stop
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		raw, rerr := in.ReadBytes('\n')
+		if rerr != nil && rerr != io.EOF {
+			return rerr
+		}
+		if len(raw) > 0 {
+			report.Lines++
+			if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+				doc, err := parseImportLine(raw, opts)
+				if err != nil {
+					if err := fail(report.Lines, err); err != nil {
+						if ferr := flush(); ferr != nil {
+							return ferr
+						}
+						return err
+					}
+				} else {
+					report.Documents++
+					batch = append(batch, importLine{line: report.Lines, doc: doc})
+					if len(batch) == size {
+						if err := flush(); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		if rerr == io.EOF {
+			return flush()
+		}
+	}
+}
+
+// parseImportLine parses one Extended JSON document and validates it
+func parseImportLine(raw []byte, opts ImportOptions) (bson.D, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON(raw, false, &doc); err != nil {
+		return nil, err
+	}
+	if _, err := importKeyFilter(doc, opts.KeyFields); err != nil {
+		return nil, err
+	}
+	if opts.Validate != nil {
+		if err := opts.Validate(doc); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// importBatch writes batch unordered and returns the writes that failed; an
+// error means the batch as a whole failed
+func (d *Database) importBatch(ctx context.Context, db string, collection string, batch []importLine, keys []string) ([]mongo.WriteError, error) {
+	var err error
+	if len(keys) == 0 {
+		docs := make([]any, len(batch))
+		for i, l := range batch {
+			docs[i] = l.doc
+		}
+		_, err = d.Client.InsertMany(ctx, db, collection, docs, moptions.InsertMany().SetOrdered(false))
+	} else {
+		models := make([]any, len(batch))
+		for i, l := range batch {
+			filter, _ := importKeyFilter(l.doc, keys)
+			models[i] = mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(l.doc).SetUpsert(true)
+		}
+		_, err = d.Client.BulkWrite(ctx, db, collection, models, moptions.BulkWrite().SetOrdered(false))
+	}
+	if err == nil {
+		return nil, nil
+	}
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || len(bwe.WriteErrors) == 0 || bwe.WriteConcernError != nil {
+		return nil, err
+	}
+	failed := make([]mongo.WriteError, len(bwe.WriteErrors))
+	for i, we := range bwe.WriteErrors {
+		failed[i] = we.WriteError
+	}
+	return failed, nil
+}
+
+// importKeyFilter returns the filter matching the values of keys in doc,
+// which may be dotted paths into embedded documents
+func importKeyFilter(doc bson.D, keys []string) (bson.D, error) {
+	filter := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		var value any = doc
+		for _, part := range strings.Split(key, ".") {
+			embedded, ok := value.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("missing key field %q", key)
+			}
+			if value, ok = fieldValue(embedded, part); !ok {
+				return nil, fmt.Errorf("missing key field %q", key)
+			}
+		}
+		filter = append(filter, bson.E{Key: key, Value: value})
+	}
+	return filter, nil
+}
+
+// decompressed returns r as a buffered reader, decompressing it when it
+// starts with the gzip magic number
+func decompressed(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return bufio.NewReader(zr), nil
+	}
+	return br, nil
+}
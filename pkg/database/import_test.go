@@ -0,0 +1,161 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	fake := NewFakeDatabase()
+	for i := 0; i < 25; i++ {
+		err := fake.Seed("vault", "events", bson.M{
+			"_id":       primitive.NewObjectID(),
+			"order":     int32(i),
+			"size":      int64(i) << 40,
+			"score":     float64(i) / 4,
+			"timestamp": primitive.NewDateTimeFromTime(start.Add(time.Duration(i) * time.Minute)),
+			"camera":    bson.M{"id": primitive.NewObjectID(), "tags": bson.A{"gate", int32(i)}},
+			"thumb":     primitive.Binary{Subtype: 0, Data: []byte{byte(i), 1, 2}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &Database{Client: fake}
+
+	export := func(t *testing.T, opts ExportOptions) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		if _, err := db.Export(ctx, "vault", "events", nil, &out, opts); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts ExportOptions
+		}{
+			{"Canonical", ExportOptions{Canonical: true}},
+			{"Gzip", ExportOptions{Canonical: true, Gzip: true}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				collection := "copy" + tt.name
+				report, err := db.Import(ctx, "vault", collection, bytes.NewReader(export(t, tt.opts)), ImportOptions{BatchSize: 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if report.Lines != 25 || report.Documents != 25 || report.Written != 25 || report.Failed != 0 {
+					t.Fatalf("expected 25 documents written, got %+v", report)
+				}
+				want, got := fake.Documents("vault", "events"), fake.Documents("vault", collection)
+				if len(got) != len(want) {
+					t.Fatalf("expected %d documents, got %d", len(want), len(got))
+				}
+				for i := range want {
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Errorf("document %d differs:\nwant %#v\ngot  %#v", i, want[i], got[i])
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		data := export(t, ExportOptions{Canonical: true})
+		target := NewFakeDatabase()
+		first := fake.Documents("vault", "events")[0]
+		target.Seed("vault", "events", bson.M{"_id": first["_id"], "order": int32(-1)})
+		report, err := (&Database{Client: target}).Import(ctx, "vault", "events", bytes.NewReader(data), ImportOptions{KeyFields: []string{"_id"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := target.Documents("vault", "events")
+		if report.Written != 25 || len(docs) != 25 || !reflect.DeepEqual(docs[0], first) {
+			t.Errorf("expected 25 documents with the first replaced, got %+v and %v", report, docs[0])
+		}
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		input := "{\"_id\": 1, \"camera\": {\"id\": 7}}\n{\"_id\": 2, \"camera\": {}}\n"
+		target := NewFakeDatabase()
+		report, err := (&Database{Client: target}).Import(ctx, "vault", "events", strings.NewReader(input), ImportOptions{KeyFields: []string{"camera.id"}, ContinueOnError: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Written != 1 || report.Failed != 1 || report.Failures[0].Line != 2 || !strings.Contains(report.Failures[0].Err.Error(), `"camera.id"`) {
+			t.Errorf("expected line 2 to miss its key, got %+v", report)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		input := strings.Join([]string{
+			`{"_id": 1}`,
+			`{"_id": 2`,
+			``,
+			`{"_id": 1}`,
+			`{"_id": 3, "order": -1}`,
+			`{"_id": 4}`,
+		}, "\n")
+		validate := func(doc bson.D) error {
+			if order, ok := fieldValue(doc, "order"); ok && order.(int32) < 0 {
+				return errors.New("negative order")
+			}
+			return nil
+		}
+
+		tests := []struct {
+			name     string
+			opts     ImportOptions
+			err      bool
+			written  int64
+			failures []int64
+		}{
+			{"Continue", ImportOptions{BatchSize: 2, ContinueOnError: true, Validate: validate}, false, 2, []int64{2, 4, 5}},
+			{"Stop", ImportOptions{BatchSize: 2, Validate: validate}, true, 1, []int64{2}},
+			{"DryRun", ImportOptions{DryRun: true, ContinueOnError: true, Validate: validate}, false, 0, []int64{2, 5}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				target := NewFakeDatabase()
+				target.EnsureUniqueIndex("vault", "events", "_id")
+				report, err := (&Database{Client: target}).Import(ctx, "vault", "events", strings.NewReader(input), tt.opts)
+				if (err != nil) != tt.err {
+					t.Fatalf("expected an error %v, got %v", tt.err, err)
+				}
+				if err != nil && !strings.Contains(err.Error(), "line 2") {
+					t.Errorf("expected the error to name line 2, got %v", err)
+				}
+				var lines []int64
+				for _, f := range report.Failures {
+					lines = append(lines, f.Line)
+				}
+				if !reflect.DeepEqual(lines, tt.failures) || report.Failed != int64(len(tt.failures)) {
+					t.Errorf("expected failures on lines %v, got %v", tt.failures, lines)
+				}
+				if report.Written != tt.written || int64(len(target.Documents("vault", "events"))) != tt.written {
+					t.Errorf("expected %d documents written, got %+v and %d stored", tt.written, report, len(target.Documents("vault", "events")))
+				}
+			})
+		}
+
+		target := NewFakeDatabase()
+		target.EnsureUniqueIndex("vault", "events", "_id")
+		report, _ := (&Database{Client: target}).Import(ctx, "vault", "events", strings.NewReader(input), ImportOptions{ContinueOnError: true})
+		if f := report.Failures[len(report.Failures)-1]; f.Line != 4 || !errors.Is(f.Err, ErrDuplicateKey) {
+			t.Errorf("expected line 4 to fail with a duplicate key, got %+v", f)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexSpec describes an index for EnsureIndexes
+type IndexSpec struct {
+	// Keys lists the indexed fields in order with their kind: 1 or -1 for
+	// ascending or descending, or an index type such as "2dsphere"
+	Keys bson.D
+	// Name overrides MongoDB's default name, e.g. serial_1_site_1
+	Name string
+	// Unique rejects writes that would store two documents with the same key
+	Unique bool
+	// Sparse skips documents that have none of the indexed fields
+	Sparse bool
+	// ExpireAfter makes a TTL index on a single date field
+	ExpireAfter time.Duration
+	// Weights sets the relative importance of the fields of a text index
+	Weights map[string]int
+	// DefaultLanguage sets the stemming language of a text index
+	DefaultLanguage string
+}
+
+// Indexer is implemented by clients that can create indexes
+type Indexer interface {
+	EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error
+}
+
+// EnsureIndexes creates the indexes described by specs on db.collection if
+// they do not exist yet. The client, or a client it wraps, must implement
+// Indexer, as MongoClient and FakeDatabase do.
+func EnsureIndexes(ctx context.Context, client DatabaseInterface, db string, collection string, specs ...IndexSpec) error {
+	indexer, ok := implementation[Indexer](client)
+	if !ok {
+		return fmt.Errorf("client %T cannot create indexes", client)
+	}
+	for i, spec := range specs {
+		if err := spec.validate(); err != nil {
+			return fmt.Errorf("index %d: %w", i, err)
+		}
+	}
+	return indexer.EnsureIndexes(ctx, db, collection, specs...)
+}
+
+// GeoIndex returns the spec of a 2dsphere index on field, which holds
+// GeoJSON points such as the ones NearSphere queries
+func GeoIndex(field string) IndexSpec {
+	return IndexSpec{Keys: bson.D{{Key: field, Value: "2dsphere"}}}
+}
+
+func (s IndexSpec) validate() error {
+	if len(s.Keys) == 0 {
+		return errors.New("index needs at least one key")
+	}
+	if s.ExpireAfter < 0 {
+		return fmt.Errorf("TTL must not be negative, got %s", s.ExpireAfter)
+	}
+	if s.ExpireAfter > 0 && len(s.Keys) != 1 {
+		return errors.New("TTL index must have exactly one key")
+	}
+	for field, weight := range s.Weights {
+		if weight < 1 {
+			return fmt.Errorf("text weight of %s must be at least 1, got %d", field, weight)
+		}
+	}
+	return nil
+}
+
+// fields returns the indexed field names in order
+func (s IndexSpec) fields() []string {
+	out := make([]string, len(s.Keys))
+	for i, key := range s.Keys {
+		out[i] = key.Key
+	}
+	return out
+}
+
+// EnsureIndexes creates the indexes on the server; creating an index that
+// already exists with the same options is not an error
+func (m *MongoClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	coll := m.Client.Database(db).Collection(collection)
+
+	models := make([]mongo.IndexModel, len(specs))
+	for i, spec := range specs {
+		opts := moptions.Index()
+		if spec.Name != "" {
+			opts.SetName(spec.Name)
+		}
+		if spec.Unique {
+			opts.SetUnique(true)
+		}
+		if spec.Sparse {
+			opts.SetSparse(true)
+		}
+		if spec.ExpireAfter > 0 {
+			opts.SetExpireAfterSeconds(int32(spec.ExpireAfter / time.Second))
+		}
+		if len(spec.Weights) > 0 {
+			weights := bson.M{}
+			for field, weight := range spec.Weights {
+				weights[field] = weight
+			}
+			opts.SetWeights(weights)
+		}
+		if spec.DefaultLanguage != "" {
+			opts.SetDefaultLanguage(spec.DefaultLanguage)
+		}
+		models[i] = mongo.IndexModel{Keys: spec.Keys, Options: opts}
+	}
+
+	_, err := coll.Indexes().CreateMany(ctx, models)
+	if err = mapError(err); errors.Is(err, ErrViewNotSupported) {
+		return fmt.Errorf("cannot create indexes on view %s.%s: %w", db, collection, err)
+	}
+	return err
+}
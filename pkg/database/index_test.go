@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestEnsureIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("FakeAppliesUniqueAndTTL", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		clock := NewTestClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		fake.SetClock(clock)
+
+		err := EnsureIndexes(ctx, fake, "app", "sessions",
+			IndexSpec{Keys: bson.D{{Key: "token", Value: 1}}, Unique: true},
+			IndexSpec{Keys: bson.D{{Key: "lastSeen", Value: 1}}, ExpireAfter: time.Hour},
+			GeoIndex("location"),
+		)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+
+		fake.InsertOne(ctx, "app", "sessions", bson.M{"token": "a", "lastSeen": clock.Now()})
+		if _, err := fake.InsertOne(ctx, "app", "sessions", bson.M{"token": "a"}); !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+		clock.Advance(2 * time.Hour)
+		if n, _ := fake.Count(ctx, "app", "sessions", bson.M{}); n != 0 {
+			t.Errorf("expected the session to expire, got %d", n)
+		}
+	})
+
+	t.Run("InvalidSpecs", func(t *testing.T) {
+		for name, spec := range map[string]IndexSpec{
+			"NoKeys":      {},
+			"CompoundTTL": {Keys: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}, ExpireAfter: time.Hour},
+			"NegativeTTL": {Keys: bson.D{{Key: "a", Value: 1}}, ExpireAfter: -time.Second},
+		} {
+			if err := EnsureIndexes(ctx, NewFakeDatabase(), "app", "sessions", spec); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+
+	t.Run("ClientWithoutIndexes", func(t *testing.T) {
+		if err := EnsureIndexes(ctx, NewMockDatabase(), "app", "sessions", GeoIndex("location")); err == nil {
+			t.Error("expected an error for a client that cannot create indexes")
+		}
+	})
+}
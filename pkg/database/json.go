@@ -0,0 +1,284 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnsafeFilter is returned by JSONToFilter when a filter uses an
+// operator or field that is not allowed
+var ErrUnsafeFilter = errors.New("unsafe filter")
+
+// SafeFilterOperators are the query operators JSONToFilter accepts by
+// default. Operators that run code or are costly to evaluate, such as $where,
+// $expr, $function and $text, are left out.
+var SafeFilterOperators = []string{
+	"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin",
+	"$and", "$or", "$nor", "$not",
+	"$exists", "$type",
+	"$all", "$elemMatch", "$size",
+	"$regex", "$options", "$mod",
+}
+
+// maxFilterDepth bounds the nesting of filters from JSON
+const maxFilterDepth = 20
+
+// FilterOption configures JSONToFilter
+type FilterOption = Option[filterConfig]
+
+type filterConfig struct {
+	operators []string
+	fields    []string
+}
+
+// AllowOperators adds operators to SafeFilterOperators for one call
+func AllowOperators(operators ...string) FilterOption {
+	return func(c *filterConfig) {
+		c.operators = append(c.operators, operators...)
+	}
+}
+
+// AllowFields only accepts filters on the given fields or their subfields,
+// so clients cannot query fields such as password hashes. The fields an
+// $elemMatch queries are checked under the array's path, so
+// {"items": {"$elemMatch": {"sku": "a"}}} needs items or items.sku.
+func AllowFields(fields ...string) FilterOption {
+	return func(c *filterConfig) {
+		c.fields = append(c.fields, fields...)
+	}
+}
+
+// JSONToFilter parses a filter from a client's JSON. Values may use MongoDB
+// Extended JSON, such as {"$oid": "..."}, {"$date": "..."} and
+// {"$numberDecimal": "..."}. Any other key starting with $ must be one of
+// SafeFilterOperators or allowed with AllowOperators, which keeps operator
+// injection such as $where out of the query; such filters, and filters on
+// fields outside AllowFields, return an error matching ErrUnsafeFilter.
+//
+//	filter, err := database.JSONToFilter(body, database.AllowFields("name", "site", "created_at"))
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusBadRequest)
+//		return
+//	}
+func JSONToFilter(data []byte, opts ...FilterOption) (any, error) {
+	cfg := filterConfig{operators: slices.Clone(SafeFilterOperators)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var filter bson.D
+	if err := bson.UnmarshalExtJSON(data, false, &filter); err != nil {
+		return nil, fmt.Errorf("parse filter: %w", err)
+	}
+	if err := cfg.checkDocument(filter, true, "", 0); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// checkDocument checks the keys of doc; top is true where keys are field
+// names or logical operators rather than operators on a field. path is the
+// prefix of the field names where top is true, such as "items." in an
+// $elemMatch, and the field the operators apply to otherwise.
+func (c filterConfig) checkDocument(doc bson.D, top bool, path string, depth int) error {
+	if depth > maxFilterDepth {
+		return fmt.Errorf("%w: nested deeper than %d", ErrUnsafeFilter, maxFilterDepth)
+	}
+	for _, e := range doc {
+		if !strings.HasPrefix(e.Key, "$") {
+			if !top {
+				// An embedded document matched by equality
+				continue
+			}
+			field := path + e.Key
+			if !c.fieldAllowed(field) && !elemMatchesFields(e.Value) {
+				return fmt.Errorf("%w: field %q is not allowed", ErrUnsafeFilter, field)
+			}
+			if err := c.checkValue(e.Value, field, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if !slices.Contains(c.operators, e.Key) {
+			return fmt.Errorf("%w: operator %s is not allowed", ErrUnsafeFilter, e.Key)
+		}
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			prefix := path
+			if !top {
+				prefix = path + "."
+			}
+			clauses, ok := e.Value.(bson.A)
+			if !ok {
+				return fmt.Errorf("%s needs an array, got %T", e.Key, e.Value)
+			}
+			for _, clause := range clauses {
+				d, ok := clause.(bson.D)
+				if !ok {
+					return fmt.Errorf("%s needs documents, got %T", e.Key, clause)
+				}
+				if err := c.checkDocument(d, true, prefix, depth+1); err != nil {
+					return err
+				}
+			}
+		case "$elemMatch":
+			if d, ok := e.Value.(bson.D); ok {
+				// Either a query on the elements' fields, checked under the
+				// array's path, or operators on the elements
+				fields := len(d) == 0 || matchesFields(d)
+				elements := path
+				if fields {
+					elements = path + "."
+				}
+				if err := c.checkDocument(d, fields, elements, depth+1); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := c.checkValue(e.Value, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkValue checks the operators in the value field is matched against
+func (c filterConfig) checkValue(value any, field string, depth int) error {
+	switch v := value.(type) {
+	case bson.D:
+		return c.checkDocument(v, false, field, depth)
+	case bson.A:
+		for _, item := range v {
+			if err := c.checkValue(item, field, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchesFields reports whether the $elemMatch query d is on the fields of
+// the elements rather than the elements themselves
+func matchesFields(d bson.D) bool {
+	return !strings.HasPrefix(d[0].Key, "$") || slices.Contains([]string{"$and", "$or", "$nor"}, d[0].Key)
+}
+
+// elemMatchesFields reports whether value only holds $elemMatch queries on
+// the fields of the elements, which are checked against AllowFields under
+// the array's path, so the array itself need not be allowed
+func elemMatchesFields(value any) bool {
+	doc, ok := value.(bson.D)
+	if !ok || len(doc) == 0 {
+		return false
+	}
+	for _, e := range doc {
+		d, ok := e.Value.(bson.D)
+		if e.Key != "$elemMatch" || !ok || len(d) == 0 || !matchesFields(d) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c filterConfig) fieldAllowed(field string) bool {
+	if len(c.fields) == 0 {
+		return true
+	}
+	for _, allowed := range c.fields {
+		if field == allowed || strings.HasPrefix(field, allowed+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// DocumentToJSON renders a document, as returned by Find or any struct or
+// map the driver can encode, as plain JSON for API responses. Fields keep
+// their order. ObjectIDs become hex strings, dates RFC 3339 strings in UTC,
+// Decimal128 values decimal strings so no precision is lost, UUIDs their
+// canonical string form and other binary data base64.
+func DocumentToJSON(doc any) ([]byte, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("document to json: %w", err)
+	}
+	var d bson.D
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("document to json: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, d); err != nil {
+		return nil, fmt.Errorf("document to json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes value as JSON, converting BSON types
+func writeJSON(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case bson.D:
+		buf.WriteByte('{')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(e.Key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, e.Value); err != nil {
+				return fmt.Errorf("%s: %w", e.Key, err)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case bson.A:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case primitive.ObjectID:
+		value = v.Hex()
+	case primitive.DateTime:
+		value = v.Time().UTC().Format(time.RFC3339Nano)
+	case primitive.Timestamp:
+		value = time.Unix(int64(v.T), 0).UTC().Format(time.RFC3339)
+	case primitive.Decimal128:
+		value = v.String()
+	case primitive.Binary:
+		if v.Subtype == bson.TypeBinaryUUID && len(v.Data) == 16 {
+			value = UUID(v.Data).String()
+		} else {
+			value = base64.StdEncoding.EncodeToString(v.Data)
+		}
+	case primitive.Regex:
+		value = "/" + v.Pattern + "/" + v.Options
+	case primitive.JavaScript:
+		value = string(v)
+	case primitive.Symbol:
+		value = string(v)
+	case primitive.Null, primitive.Undefined, primitive.MinKey, primitive.MaxKey:
+		value = nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJSONToFilter(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("65f1c2a4e13b4a1d2c3b4a5f")
+	when := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	price, _ := primitive.ParseDecimal128("19.99")
+	uuid, _ := ParseUUID("0f8fad5b-d9cb-469f-a165-70867728950e")
+
+	tests := []struct {
+		name  string
+		input string
+		want  bson.D
+	}{
+		{"ObjectID", `{"_id": {"$oid": "65f1c2a4e13b4a1d2c3b4a5f"}}`, bson.D{{Key: "_id", Value: id}}},
+		{"Date", `{"at": {"$gte": {"$date": "2024-03-01T09:30:00Z"}}}`, bson.D{{Key: "at", Value: bson.D{{Key: "$gte", Value: primitive.NewDateTimeFromTime(when)}}}}},
+		{"Decimal", `{"price": {"$numberDecimal": "19.99"}}`, bson.D{{Key: "price", Value: price}}},
+		{"Long", `{"n": {"$numberLong": "9007199254740993"}}`, bson.D{{Key: "n", Value: int64(9007199254740993)}}},
+		{"UUID", `{"key": {"$binary": {"base64": "D4+tW9nLRp+hZXCGdyiVDg==", "subType": "04"}}}`, bson.D{{Key: "key", Value: primitive.Binary{Subtype: 4, Data: uuid[:]}}}},
+		{
+			"Logical",
+			`{"$or": [{"name": {"$regex": "^front", "$options": "i"}}, {"tags": {"$elemMatch": {"$eq": "door"}}}]}`,
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^front"}, {Key: "$options", Value: "i"}}}},
+				bson.D{{Key: "tags", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "$eq", Value: "door"}}}}}},
+			}}},
+		},
+		{"EmbeddedEquality", `{"address": {"city": "Ghent"}}`, bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "Ghent"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONToFilter([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !valuesEqual(normalizeDocument(got), normalizeDocument(tt.want)) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJSONToFilterRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []FilterOption
+	}{
+		{"Where", `{"$where": "sleep(1000)"}`, nil},
+		{"WhereInsideOr", `{"$or": [{"name": "x"}, {"$where": "true"}]}`, nil},
+		{"Expr", `{"$expr": {"$gt": ["$a", "$b"]}}`, nil},
+		{"FunctionInField", `{"name": {"$function": {}}}`, nil},
+		{"NotWithUnknown", `{"name": {"$not": {"$where": "x"}}}`, nil},
+		{"ElemMatchWhere", `{"tags": {"$elemMatch": {"$where": "x"}}}`, nil},
+		{"FieldNotAllowed", `{"password": {"$ne": null}}`, []FilterOption{AllowFields("name")}},
+		{"FieldInOrNotAllowed", `{"$or": [{"name": "x"}, {"password": "y"}]}`, []FilterOption{AllowFields("name")}},
+		{"ElemMatchFieldNotAllowed", `{"tags": {"$elemMatch": {"secret": 1}}}`, []FilterOption{AllowFields("tags.label", "secret")}},
+		{"ElemMatchOnElementsNotAllowed", `{"items": {"$elemMatch": {"$gt": 1}}}`, []FilterOption{AllowFields("items.sku")}},
+		{"ElemMatchOrNotAllowed", `{"items": {"$elemMatch": {"$or": [{"sku": "a"}, {"cost": 1}]}}}`, []FilterOption{AllowFields("items.sku")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := JSONToFilter([]byte(tt.input), tt.opts...); !errors.Is(err, ErrUnsafeFilter) {
+				t.Errorf("expected ErrUnsafeFilter, got %v", err)
+			}
+		})
+	}
+
+	t.Run("AllowOperators", func(t *testing.T) {
+		if _, err := JSONToFilter([]byte(`{"$text": {"$search": "door"}}`), AllowOperators("$text", "$search")); err != nil {
+			t.Errorf("expected an allowed operator accepted, got %v", err)
+		}
+	})
+
+	t.Run("AllowFieldsSubfield", func(t *testing.T) {
+		if _, err := JSONToFilter([]byte(`{"address.city": "Ghent"}`), AllowFields("address")); err != nil {
+			t.Errorf("expected a subfield accepted, got %v", err)
+		}
+	})
+
+	t.Run("AllowFieldsElemMatch", func(t *testing.T) {
+		inputs := []string{
+			`{"items": {"$elemMatch": {"sku": "a", "qty": {"$gt": 1}}}}`,
+			`{"items": {"$elemMatch": {"$or": [{"sku": "a"}, {"qty": 2}]}}}`,
+		}
+		for _, input := range inputs {
+			if _, err := JSONToFilter([]byte(input), AllowFields("items.sku", "items.qty")); err != nil {
+				t.Errorf("%s: expected the element fields checked under items, got %v", input, err)
+			}
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, input := range []string{`[{"a": 1}]`, `{"a":`, `{"$and": {"a": 1}}`} {
+			if _, err := JSONToFilter([]byte(input)); err == nil {
+				t.Errorf("%s: expected an error", input)
+			}
+		}
+	})
+
+	t.Run("TooDeep", func(t *testing.T) {
+		input := `{"a": 1}`
+		for i := 0; i <= maxFilterDepth; i++ {
+			input = `{"$and": [` + input + `]}`
+		}
+		if _, err := JSONToFilter([]byte(input)); !errors.Is(err, ErrUnsafeFilter) {
+			t.Errorf("expected ErrUnsafeFilter, got %v", err)
+		}
+	})
+}
+
+func TestDocumentToJSON(t *testing.T) {
+	id, _ := primitive.ObjectIDFromHex("65f1c2a4e13b4a1d2c3b4a5f")
+	when := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	price, _ := primitive.ParseDecimal128("19.99")
+	uuid, _ := ParseUUID("0f8fad5b-d9cb-469f-a165-70867728950e")
+
+	tests := []struct {
+		name string
+		doc  any
+		want string
+	}{
+		{"ObjectID", bson.D{{Key: "_id", Value: id}}, `{"_id":"65f1c2a4e13b4a1d2c3b4a5f"}`},
+		{"ID", bson.D{{Key: "_id", Value: ID(id)}}, `{"_id":"65f1c2a4e13b4a1d2c3b4a5f"}`},
+		{"Time", bson.D{{Key: "at", Value: when}}, `{"at":"2024-03-01T09:30:00Z"}`},
+		{"DateTimeMillis", bson.D{{Key: "at", Value: primitive.NewDateTimeFromTime(when.Add(250 * time.Millisecond))}}, `{"at":"2024-03-01T09:30:00.25Z"}`},
+		{"Decimal", bson.D{{Key: "price", Value: price}}, `{"price":"19.99"}`},
+		{"Long", bson.D{{Key: "n", Value: int64(9007199254740993)}}, `{"n":9007199254740993}`},
+		{"UUID", bson.D{{Key: "key", Value: uuid}}, `{"key":"0f8fad5b-d9cb-469f-a165-70867728950e"}`},
+		{"Binary", bson.D{{Key: "raw", Value: primitive.Binary{Data: []byte("hi")}}}, `{"raw":"aGk="}`},
+		{"Regex", bson.D{{Key: "re", Value: primitive.Regex{Pattern: "^a", Options: "i"}}}, `{"re":"/^a/i"}`},
+		{"Null", bson.D{{Key: "gone", Value: nil}}, `{"gone":null}`},
+		{
+			"NestedKeepsOrder",
+			bson.D{{Key: "z", Value: bson.A{id, bson.D{{Key: "b", Value: 1}, {Key: "a", Value: true}}}}, {Key: "a", Value: "x"}},
+			`{"z":["65f1c2a4e13b4a1d2c3b4a5f",{"b":1,"a":true}],"a":"x"}`,
+		},
+		{
+			"Struct",
+			struct {
+				ID   primitive.ObjectID `bson:"_id"`
+				Name string             `bson:"name"`
+			}{id, "front"},
+			`{"_id":"65f1c2a4e13b4a1d2c3b4a5f","name":"front"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DocumentToJSON(tt.doc)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("expected %s, got %s, %v", tt.want, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// kvRetention is how long after its expiry a key is removed by the TTL
+// index of a KVStore; reads ignore expired keys before that
+const kvRetention = time.Second
+
+// KVOptions configures NewKVStore
+type KVOptions struct {
+	// Clock supplies the time expiry is measured against, the system clock
+	// by default. The fake's TTL index must use the same clock, see
+	// FakeDatabase.SetClock.
+	Clock Clock
+}
+
+// KVStore keeps values of type T by string key in one collection, one
+// document per key with the key as _id. It is safe for concurrent use.
+type KVStore[T any] struct {
+	client     DatabaseInterface
+	db         string
+	collection string
+	clock      Clock
+	codec      *codec
+
+	indexMu sync.Mutex
+	indexed bool
+
+	flightMu sync.Mutex
+	flights  map[string]*kvFlight[T]
+}
+
+// kvEntry is a document of a KVStore
+type kvEntry[T any] struct {
+	Key   string `bson:"_id,omitempty"`
+	Value T      `bson:"value"`
+}
+
+// kvFlight is a GetOrSet computing the value of a key
+type kvFlight[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// NewKVStore returns a KVStore over dbName.collection of db. Values are
+// stored as BSON with the codec of db's BSON options, so any struct works;
+// a KVStore[[]byte] stores opaque payloads as BSON binary, as they are.
+//
+//	sessions := database.NewKVStore[Session](db, "vault", "sessions", database.KVOptions{})
+//	err := sessions.Set(ctx, token, session, 30*time.Minute)
+//	session, err := sessions.Get(ctx, token)
+//	if errors.Is(err, database.ErrNotFound) {
+//		return errUnauthenticated
+//	}
+func NewKVStore[T any](db *Database, dbName string, collection string, opts KVOptions) *KVStore[T] {
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	return &KVStore[T]{
+		client:     db.Client,
+		db:         dbName,
+		collection: collection,
+		clock:      opts.Clock,
+		codec:      db.codec(),
+		flights:    map[string]*kvFlight[T]{},
+	}
+}
+
+// Get returns the value of key, or ErrNotFound when the key is missing or
+// expired
+func (s *KVStore[T]) Get(ctx context.Context, key string) (T, error) {
+	var entry kvEntry[T]
+	doc, err := s.client.FindOne(ctx, s.db, s.collection, s.live(bson.M{"_id": key}), moptions.FindOne().SetProjection(bson.M{"value": 1}))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return entry.Value, fmt.Errorf("kv %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return entry.Value, fmt.Errorf("kv %s: %w", key, err)
+	}
+	if err := s.codec.decode(doc, &entry); err != nil {
+		return entry.Value, fmt.Errorf("kv %s: decode value into %T: %w", key, entry.Value, err)
+	}
+	return entry.Value, nil
+}
+
+// Set stores value under key, replacing its value and expiry. The key
+// expires ttl from now; a ttl of zero or less keeps it until deleted. The
+// first Set creates the TTL index that removes expired keys when the
+// client can create indexes.
+func (s *KVStore[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := s.ensureIndex(ctx); err != nil {
+		return fmt.Errorf("kv %s: %w", key, err)
+	}
+	raw, err := s.codec.marshal(kvEntry[T]{Value: value})
+	if err != nil {
+		return fmt.Errorf("kv %s: encode %T: %w", key, value, err)
+	}
+	set := bson.M{"value": bson.Raw(raw).Lookup("value")}
+	update := bson.M{"$set": set}
+	if ttl > 0 {
+		set["expires_at"] = s.clock.Now().Add(ttl)
+	} else {
+		update["$unset"] = bson.M{"expires_at": ""}
+	}
+	if _, err := s.client.UpdateOne(ctx, s.db, s.collection, bson.M{"_id": key}, update, moptions.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("kv %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes key; deleting a missing key is not an error
+func (s *KVStore[T]) Delete(ctx context.Context, key string) error {
+	if _, err := s.client.DeleteOne(ctx, s.db, s.collection, bson.M{"_id": key}); err != nil {
+		return fmt.Errorf("kv %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetOrSet returns the value of key, computing it with fn and storing it
+// for ttl when the key is missing or expired. Concurrent calls of this
+// store for the same key share one call of fn and its result; calls from
+// other processes are not coordinated. A failing fn stores nothing.
+//
+//	report, err := reports.GetOrSet(ctx, day, time.Hour, func(ctx context.Context) (Report, error) {
+//		return buildReport(ctx, day)
+//	})
+func (s *KVStore[T]) GetOrSet(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) (T, error)) (T, error) {
+	value, err := s.Get(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		return value, err
+	}
+
+	s.flightMu.Lock()
+	if flight, ok := s.flights[key]; ok {
+		s.flightMu.Unlock()
+		select {
+		case <-flight.done:
+			return flight.value, flight.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+	flight := &kvFlight[T]{done: make(chan struct{})}
+	s.flights[key] = flight
+	s.flightMu.Unlock()
+
+	defer func() {
+		s.flightMu.Lock()
+		delete(s.flights, key)
+		s.flightMu.Unlock()
+		close(flight.done)
+	}()
+	// Another call may have stored the value since the first Get
+	flight.value, flight.err = s.Get(ctx, key)
+	if !errors.Is(flight.err, ErrNotFound) {
+		return flight.value, flight.err
+	}
+	flight.value, flight.err = fn(ctx)
+	if flight.err == nil {
+		flight.err = s.Set(ctx, key, flight.value, ttl)
+	}
+	return flight.value, flight.err
+}
+
+// Keys returns the unexpired keys starting with prefix, all of them for
+// an empty prefix, in ascending order. The anchored prefix match uses the
+// _id index.
+func (s *KVStore[T]) Keys(ctx context.Context, prefix string) ([]string, error) {
+	filter := s.live(bson.M{"_id": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}})
+	docs, err := s.client.Find(ctx, s.db, s.collection, filter,
+		moptions.Find().SetProjection(bson.M{"_id": 1}).SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("kv keys %q: %w", prefix, err)
+	}
+	list, _ := docs.([]any)
+	keys := make([]string, 0, len(list))
+	for i, doc := range list {
+		var entry struct {
+			Key string `bson:"_id"`
+		}
+		if err := s.codec.decode(doc, &entry); err != nil {
+			return nil, fmt.Errorf("kv keys %q: decode document %d: %w", prefix, i, err)
+		}
+		keys = append(keys, entry.Key)
+	}
+	return keys, nil
+}
+
+// live narrows filter to the keys that have not expired
+func (s *KVStore[T]) live(filter bson.M) bson.M {
+	filter["$or"] = bson.A{
+		bson.M{"expires_at": bson.M{"$exists": false}},
+		bson.M{"expires_at": bson.M{"$gt": s.clock.Now()}},
+	}
+	return filter
+}
+
+// ensureIndex creates the TTL index on expires_at once, retrying on the
+// next Set after a failure
+func (s *KVStore[T]) ensureIndex(ctx context.Context) error {
+	if _, ok := s.client.(Indexer); !ok {
+		return nil
+	}
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if s.indexed {
+		return nil
+	}
+	if err := EnsureIndexes(ctx, s.client, s.db, s.collection,
+		IndexSpec{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfter: kvRetention},
+	); err != nil {
+		return err
+	}
+	s.indexed = true
+	return nil
+}
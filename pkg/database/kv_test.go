@@ -0,0 +1,190 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type kvSession struct {
+	User  string   `bson:"user"`
+	Roles []string `bson:"roles"`
+}
+
+func TestKVStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("SetGetDelete", func(t *testing.T) {
+		store := NewKVStore[kvSession](&Database{Client: NewFakeDatabase()}, "vault", "sessions", KVOptions{})
+		if _, err := store.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound for a missing key, got %v", err)
+		}
+		want := kvSession{User: "ada", Roles: []string{"admin"}}
+		if err := store.Set(ctx, "a", want, 0); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Get(ctx, "a"); !reflect.DeepEqual(got, want) || err != nil {
+			t.Errorf("expected %+v, got %+v, %v", want, got, err)
+		}
+		want.User = "grace"
+		store.Set(ctx, "a", want, 0)
+		if got, _ := store.Get(ctx, "a"); got.User != "grace" {
+			t.Errorf("expected Set to replace the value, got %+v", got)
+		}
+		for i := 0; i < 2; i++ {
+			if err := store.Delete(ctx, "a"); err != nil {
+				t.Errorf("delete %d: %v", i, err)
+			}
+		}
+		if _, err := store.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound after Delete, got %v", err)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		clock := NewTestClock(start)
+		fake := NewFakeDatabase()
+		fake.SetClock(clock)
+		store := NewKVStore[string](&Database{Client: fake}, "vault", "cache", KVOptions{Clock: clock})
+		store.Set(ctx, "short", "x", time.Minute)
+		store.Set(ctx, "long", "y", time.Hour)
+		store.Set(ctx, "forever", "z", 0)
+
+		clock.Advance(time.Minute)
+		if _, err := store.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the key to expire with its TTL, got %v", err)
+		}
+		if keys, _ := store.Keys(ctx, ""); !reflect.DeepEqual(keys, []string{"forever", "long"}) {
+			t.Errorf("expected Keys to skip expired keys, got %v", keys)
+		}
+
+		store.Set(ctx, "long", "y", 0)
+		clock.Advance(2 * time.Hour)
+		if got, err := store.Get(ctx, "long"); got != "y" || err != nil {
+			t.Errorf("expected Set without TTL to clear the expiry, got %q, %v", got, err)
+		}
+		if n := fake.RunTTLSweep(); n != 1 {
+			t.Errorf("expected the TTL index to remove 1 expired key, removed %d", n)
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		store := NewKVStore[int](&Database{Client: NewFakeDatabase()}, "vault", "cache", KVOptions{})
+		for i, key := range []string{"user:2", "user:1", "users", "org:1", "user.1"} {
+			store.Set(ctx, key, i, 0)
+		}
+		tests := map[string][]string{
+			"":      {"org:1", "user.1", "user:1", "user:2", "users"},
+			"user:": {"user:1", "user:2"},
+			"user.": {"user.1"},
+			"none":  {},
+		}
+		for prefix, want := range tests {
+			t.Run(prefix, func(t *testing.T) {
+				keys, err := store.Keys(ctx, prefix)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(keys, want) {
+					t.Errorf("expected %v, got %v", want, keys)
+				}
+			})
+		}
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		store := NewKVStore[[]byte](&Database{Client: fake}, "vault", "blobs", KVOptions{})
+		payload := []byte{0x00, 0xff, '{', 0x10}
+		if err := store.Set(ctx, "blob", payload, 0); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Get(ctx, "blob"); !bytes.Equal(got, payload) || err != nil {
+			t.Errorf("expected %x, got %x, %v", payload, got, err)
+		}
+	})
+
+	t.Run("GetOrSet", func(t *testing.T) {
+		store := NewKVStore[string](&Database{Client: NewFakeDatabase()}, "vault", "cache", KVOptions{})
+		var calls atomic.Int32
+		release := make(chan struct{})
+		fn := func(ctx context.Context) (string, error) {
+			calls.Add(1)
+			<-release
+			return "report", nil
+		}
+
+		var wg sync.WaitGroup
+		results := make([]string, 10)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := store.GetOrSet(ctx, "daily", time.Hour, fn)
+				if err != nil {
+					t.Error(err)
+				}
+				results[i] = v
+			}()
+		}
+		eventually(t, "a call to start", func() bool { return calls.Load() == 1 })
+		close(release)
+		wg.Wait()
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected concurrent calls to share one computation, got %d", n)
+		}
+		for i, v := range results {
+			if v != "report" {
+				t.Errorf("call %d: expected the shared value, got %q", i, v)
+			}
+		}
+		if _, err := store.GetOrSet(ctx, "daily", time.Hour, fn); err != nil || calls.Load() != 1 {
+			t.Errorf("expected the stored value to be returned without calling fn, got %v after %d calls", err, calls.Load())
+		}
+
+		failure := errors.New("boom")
+		if _, err := store.GetOrSet(ctx, "broken", time.Hour, func(context.Context) (string, error) {
+			return "", failure
+		}); !errors.Is(err, failure) {
+			t.Errorf("expected fn's error, got %v", err)
+		}
+		if _, err := store.Get(ctx, "broken"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected a failing fn to store nothing, got %v", err)
+		}
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		store := NewKVStore[kvSession](&Database{Client: mock}, "vault", "sessions", KVOptions{Clock: NewTestClock(start)})
+		if err := store.Set(ctx, "a", kvSession{User: "ada"}, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if len(mock.UpdateOneCalls) != 1 {
+			t.Fatalf("expected one UpdateOne, got %d", len(mock.UpdateOneCalls))
+		}
+		update := mock.UpdateOneCalls[0].Update.(bson.M)["$set"].(bson.M)
+		if got := update["expires_at"]; got != start.Add(time.Minute) {
+			t.Errorf("expected the expiry to follow the clock, got %v", got)
+		}
+		if _, err := store.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound from the mock's default FindOne, got %v", err)
+		}
+
+		failure := errors.New("unavailable")
+		mock.FindOneFunc = func(context.Context, string, string, any, ...any) (any, error) {
+			return nil, failure
+		}
+		if _, err := store.Get(ctx, "a"); !errors.Is(err, failure) || errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the client error, got %v", err)
+		}
+	})
+}
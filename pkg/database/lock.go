@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LockCollection is the collection AcquireLock keeps locks in
+const LockCollection = "_locks"
+
+// defaultLockSkew is the clock skew between processes AcquireLock tolerates
+// by default
+const defaultLockSkew = time.Second
+
+// lockRetention is how long after its expiry an abandoned lock is removed by
+// the TTL index of LockCollection
+const lockRetention = time.Hour
+
+// ErrLockHeld is returned by AcquireLock and RunWithLock when another owner
+// holds an unexpired lock of that name
+var ErrLockHeld = errors.New("lock held")
+
+// ErrLockLost is returned by Refresh when the lock expired and was taken by
+// another owner, or was removed, and by RunWithLock when fn lost its lock
+var ErrLockLost = errors.New("lock lost")
+
+// LockOption configures AcquireLock and RunWithLock
+type LockOption func(*lockConfig)
+
+type lockConfig struct {
+	clock Clock
+	skew  time.Duration
+}
+
+// LockClock sets the clock leases are measured against, the system clock by
+// default
+func LockClock(clock Clock) LockOption {
+	return func(c *lockConfig) {
+		c.clock = clock
+	}
+}
+
+// LockSkew sets how far the clocks of the processes sharing a lock may
+// drift apart, one second by default: a lock is only taken over once it
+// expired by this much, so a process whose clock runs ahead cannot take a
+// lock its holder still considers valid
+func LockSkew(skew time.Duration) LockOption {
+	return func(c *lockConfig) {
+		c.skew = skew
+	}
+}
+
+// Lock is a lease on a named lock, as returned by AcquireLock. The lease
+// lasts for the lock's TTL from the last Refresh.
+type Lock struct {
+	client DatabaseInterface
+	db     string
+	name   string
+	owner  string
+	ttl    time.Duration
+	token  primitive.ObjectID
+	cfg    lockConfig
+
+	mu      sync.Mutex
+	expires time.Time
+	done    chan struct{}
+	once    sync.Once
+}
+
+// AcquireLock takes the lock name in the LockCollection of db for owner,
+// for ttl, if no other owner holds it or its holder's lease expired. A
+// lock whose holder died without releasing it is taken over once its lease
+// ran out, and its document is removed an hour later by a TTL index.
+// AcquireLock returns ErrLockHeld when the lock is held, including by an
+// earlier lease of the same owner.
+//
+// Locks rely on a unique index on the lock name, which AcquireLock creates
+// once per client and database; it fails when the client, or a client it
+// wraps, cannot create indexes.
+//
+//	lock, err := db.AcquireLock(ctx, "vault", "nightly-report", time.Minute, hostname)
+//	if errors.Is(err, database.ErrLockHeld) {
+//		return nil // another replica runs it
+//	}
+//	defer lock.Release(ctx)
+func (d *Database) AcquireLock(ctx context.Context, db string, name string, ttl time.Duration, owner string, opts ...LockOption) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("lock %s: TTL must be positive, got %s", name, ttl)
+	}
+	cfg := lockConfig{clock: systemClock{}, skew: defaultLockSkew}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := ensureLockIndexes(ctx, d.Client, db); err != nil {
+		return nil, fmt.Errorf("lock %s: %w", name, err)
+	}
+
+	l := &Lock{client: d.Client, db: db, name: name, owner: owner, ttl: ttl, token: primitive.NewObjectID(), cfg: cfg, done: make(chan struct{})}
+	now := cfg.clock.Now()
+	expires := now.Add(ttl)
+	_, err := d.Client.UpdateOne(ctx, db, LockCollection,
+		bson.M{"name": name, "expires_at": bson.M{"$lte": now.Add(-cfg.skew)}},
+		bson.M{"$set": bson.M{"owner": owner, "token": l.token, "acquired_at": now, "expires_at": expires}},
+		moptions.Update().SetUpsert(true))
+	if errors.Is(err, ErrDuplicateKey) {
+		return nil, fmt.Errorf("lock %s: %w", name, ErrLockHeld)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", name, err)
+	}
+	l.expires = expires
+	return l, nil
+}
+
+// lockIndexKey identifies the client and database whose lock indexes exist
+type lockIndexKey struct {
+	indexer Indexer
+	db      string
+}
+
+// lockIndexes holds the lockIndexKey of every database whose lock indexes
+// were created
+var lockIndexes sync.Map
+
+// ensureLockIndexes creates the unique index on the lock name, without which
+// two racing upserts could both take a lock, and the TTL index removing
+// abandoned locks, once per indexer and database
+func ensureLockIndexes(ctx context.Context, client DatabaseInterface, db string) error {
+	indexer, ok := implementation[Indexer](client)
+	if !ok {
+		return fmt.Errorf("client %T cannot create the unique index locks rely on", client)
+	}
+	key := lockIndexKey{indexer: indexer, db: db}
+	cache := reflect.TypeOf(indexer).Comparable()
+	if cache {
+		if _, done := lockIndexes.Load(key); done {
+			return nil
+		}
+	}
+	if err := EnsureIndexes(ctx, client, db, LockCollection,
+		IndexSpec{Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+		IndexSpec{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfter: lockRetention},
+	); err != nil {
+		return err
+	}
+	if cache {
+		lockIndexes.Store(key, struct{}{})
+	}
+	return nil
+}
+
+// Name returns the name of the lock
+func (l *Lock) Name() string {
+	return l.name
+}
+
+// Owner returns the owner the lock was acquired for
+func (l *Lock) Owner() string {
+	return l.owner
+}
+
+// ExpiresAt returns when the lease runs out unless refreshed
+func (l *Lock) ExpiresAt() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.expires
+}
+
+// Refresh extends the lease by the lock's TTL from now. It fails with
+// ErrLockLost when another owner took the lock over or it was removed.
+// Any failure, a lost connection included, closes Done, as the holder
+// cannot tell whether its lease is still valid.
+func (l *Lock) Refresh(ctx context.Context) error {
+	select {
+	case <-l.done:
+		return fmt.Errorf("lock %s: %w", l.name, ErrLockLost)
+	default:
+	}
+	expires := l.cfg.clock.Now().Add(l.ttl)
+	res, err := l.client.UpdateOne(ctx, l.db, LockCollection,
+		bson.M{"name": l.name, "token": l.token},
+		bson.M{"$set": bson.M{"expires_at": expires}})
+	if err == nil && res.MatchedCount == 0 {
+		err = ErrLockLost
+	}
+	if err != nil {
+		l.close()
+		return fmt.Errorf("lock %s: %w", l.name, err)
+	}
+	l.mu.Lock()
+	l.expires = expires
+	l.mu.Unlock()
+	return nil
+}
+
+// Release gives the lock up so another owner can take it straight away, and
+// closes Done. Releasing a lock that was lost or already released does
+// nothing.
+func (l *Lock) Release(ctx context.Context) error {
+	l.close()
+	if _, err := l.client.DeleteOne(ctx, l.db, LockCollection, bson.M{"name": l.name, "token": l.token}); err != nil {
+		return fmt.Errorf("lock %s: %w", l.name, err)
+	}
+	return nil
+}
+
+// Done is closed when a Refresh failed or the lock was released; the holder
+// should stop the work the lock guards
+func (l *Lock) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *Lock) close() {
+	l.once.Do(func() { close(l.done) })
+}
+
+// RunWithLock runs fn while holding the lock name, refreshing the lease every
+// third of ttl, and releases the lock when fn returns. fn's context is
+// canceled when the lock is lost, and RunWithLock then returns an error
+// matching ErrLockLost along with fn's. It returns ErrLockHeld without
+// running fn when another owner holds the lock. The owner is the host name
+// and process ID.
+//
+//	err := db.RunWithLock(ctx, "vault", "nightly-report", time.Minute, func(ctx context.Context) error {
+//		return buildReport(ctx)
+//	})
+//	if errors.Is(err, database.ErrLockHeld) {
+//		return nil // another replica runs it
+//	}
+func (d *Database) RunWithLock(ctx context.Context, db string, name string, ttl time.Duration, fn func(ctx context.Context) error, opts ...LockOption) error {
+	host, _ := os.Hostname()
+	lock, err := d.AcquireLock(ctx, db, name, ttl, fmt.Sprintf("%s:%d", host, os.Getpid()), opts...)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(context.WithoutCancel(ctx))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	heartbeat := make(chan struct{})
+	lost := false
+	go func() {
+		defer close(heartbeat)
+		ticker := time.NewTicker(max(ttl/3, time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				if err := lock.Refresh(runCtx); err != nil && runCtx.Err() == nil {
+					lost = true
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(runCtx)
+	cancel()
+	<-heartbeat
+	if lost {
+		return errors.Join(fmt.Errorf("lock %s: %w", name, ErrLockLost), err)
+	}
+	return err
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// lockFake is a fake whose TTL expiry follows clock
+func lockFake(clock Clock) *FakeDatabase {
+	fake := NewFakeDatabase()
+	fake.SetClock(clock)
+	return fake
+}
+
+// passClient passes every call on to the client it wraps, as middleware
+// that implements none of the optional interfaces does
+type passClient struct {
+	DatabaseInterface
+}
+
+func (c *passClient) Unwrap() DatabaseInterface {
+	return c.DatabaseInterface
+}
+
+// indexCountClient counts the EnsureIndexes calls reaching the client it
+// wraps
+type indexCountClient struct {
+	passClient
+	calls int
+}
+
+func (c *indexCountClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	c.calls++
+	return EnsureIndexes(ctx, c.DatabaseInterface, db, collection, specs...)
+}
+
+func TestAcquireLock(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Held", func(t *testing.T) {
+		d := &Database{Client: NewFakeDatabase()}
+		a, err := d.AcquireLock(ctx, "vault", "report", time.Minute, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Name() != "report" || a.Owner() != "a" || a.ExpiresAt().IsZero() {
+			t.Errorf("expected the lock's name, owner and expiry, got %q, %q, %v", a.Name(), a.Owner(), a.ExpiresAt())
+		}
+		for _, owner := range []string{"b", "a"} {
+			if _, err := d.AcquireLock(ctx, "vault", "report", time.Minute, owner); !errors.Is(err, ErrLockHeld) {
+				t.Errorf("expected %s to find the lock held, got %v", owner, err)
+			}
+		}
+		if _, err := d.AcquireLock(ctx, "vault", "other", time.Minute, "b"); err != nil {
+			t.Errorf("expected another lock to be free, got %v", err)
+		}
+		if err := a.Release(ctx); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-a.Done():
+		default:
+			t.Error("expected Release to close Done")
+		}
+		if _, err := d.AcquireLock(ctx, "vault", "report", time.Minute, "b"); err != nil {
+			t.Errorf("expected the released lock to be taken, got %v", err)
+		}
+		if err := a.Release(ctx); err != nil {
+			t.Errorf("expected a second Release to do nothing, got %v", err)
+		}
+	})
+
+	t.Run("HolderDies", func(t *testing.T) {
+		clock := NewTestClock(start)
+		fake := lockFake(clock)
+		d := &Database{Client: fake}
+		a, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "a", LockClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a stops heartbeating without releasing the lock
+		clock.Advance(10 * time.Second)
+		if _, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "b", LockClock(clock)); !errors.Is(err, ErrLockHeld) {
+			t.Fatalf("expected the lock to be held within the skew tolerance, got %v", err)
+		}
+		clock.Advance(defaultLockSkew)
+		b, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "b", LockClock(clock))
+		if err != nil {
+			t.Fatalf("expected the expired lock to be taken over, got %v", err)
+		}
+		if err := a.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+			t.Errorf("expected the former holder to have lost the lock, got %v", err)
+		}
+		select {
+		case <-a.Done():
+		default:
+			t.Error("expected the failed Refresh to close Done")
+		}
+		// b dies too; the TTL index removes its lock an hour after expiry
+		clock.Advance(10*time.Second + lockRetention + time.Second)
+		fake.RunTTLSweep()
+		if docs := fake.Documents("vault", LockCollection); len(docs) != 0 {
+			t.Errorf("expected the abandoned lock to be removed, got %v", docs)
+		}
+		if err := b.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+			t.Errorf("expected the removed lock to be lost, got %v", err)
+		}
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		clock := NewTestClock(start)
+		d := &Database{Client: lockFake(clock)}
+		a, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "a", LockClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(8 * time.Second)
+		if err := a.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !a.ExpiresAt().Equal(start.Add(18 * time.Second)) {
+			t.Errorf("expected the lease to be extended, got %v", a.ExpiresAt())
+		}
+		clock.Advance(8 * time.Second)
+		if _, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "b", LockClock(clock)); !errors.Is(err, ErrLockHeld) {
+			t.Errorf("expected the refreshed lock to be held, got %v", err)
+		}
+	})
+
+	t.Run("RefreshFails", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		a, err := (&Database{Client: fake}).AcquireLock(ctx, "vault", "report", time.Minute, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fake.Close(ctx)
+		if err := a.Refresh(ctx); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("expected the refresh to fail, got %v", err)
+		}
+		select {
+		case <-a.Done():
+		default:
+			t.Error("expected a failed refresh to close Done")
+		}
+	})
+
+	// b's clock runs 3s ahead of a's; at 8s into a's 10s lease, b reads 11s
+	t.Run("ClockSkew", func(t *testing.T) {
+		tests := []struct {
+			name string
+			skew time.Duration
+			want error
+		}{
+			{"Tolerated", 5 * time.Second, ErrLockHeld},
+			{"Exceeded", time.Second, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				clockA, clockB := NewTestClock(start), NewTestClock(start.Add(3*time.Second))
+				d := &Database{Client: lockFake(clockA)}
+				if _, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "a", LockClock(clockA), LockSkew(tt.skew)); err != nil {
+					t.Fatal(err)
+				}
+				clockA.Advance(8 * time.Second)
+				clockB.Advance(8 * time.Second)
+				if _, err := d.AcquireLock(ctx, "vault", "report", 10*time.Second, "b", LockClock(clockB), LockSkew(tt.skew)); !errors.Is(err, tt.want) {
+					t.Errorf("expected %v, got %v", tt.want, err)
+				}
+			})
+		}
+	})
+
+	t.Run("WrappedClient", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		d := &Database{Client: Wrap(fake, func(next DatabaseInterface) DatabaseInterface {
+			return &passClient{DatabaseInterface: next}
+		})}
+		if _, err := d.AcquireLock(ctx, "vault", "report", time.Minute, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.AcquireLock(ctx, "vault", "report", time.Minute, "b"); !errors.Is(err, ErrLockHeld) {
+			t.Errorf("expected the unique index to reject a second holder, got %v", err)
+		}
+		if n, _ := fake.Count(ctx, "vault", LockCollection, bson.M{"name": "report"}); n != 1 {
+			t.Errorf("expected a single lock document, got %d", n)
+		}
+	})
+
+	t.Run("IndexesOnce", func(t *testing.T) {
+		counter := &indexCountClient{passClient: passClient{DatabaseInterface: NewFakeDatabase()}}
+		d := &Database{Client: counter}
+		for _, name := range []string{"report", "backup", "report"} {
+			d.AcquireLock(ctx, "vault", name, time.Minute, "a")
+		}
+		d.AcquireLock(ctx, "archive", "report", time.Minute, "a")
+		if counter.calls != 2 {
+			t.Errorf("expected the indexes ensured once per database, got %d calls", counter.calls)
+		}
+	})
+
+	t.Run("NoIndexer", func(t *testing.T) {
+		mock := NewMockDatabase()
+		if _, err := (&Database{Client: mock}).AcquireLock(ctx, "vault", "report", time.Minute, "a"); err == nil || !strings.Contains(err.Error(), "cannot create") {
+			t.Errorf("expected a client without indexes to be refused, got %v", err)
+		}
+		if n := len(mock.History()); n != 0 {
+			t.Errorf("expected nothing sent to the client, got %d calls", n)
+		}
+	})
+
+	t.Run("InvalidTTL", func(t *testing.T) {
+		if _, err := (&Database{Client: NewFakeDatabase()}).AcquireLock(ctx, "vault", "report", 0, "a"); err == nil {
+			t.Error("expected a zero TTL to be rejected")
+		}
+	})
+}
+
+func TestRunWithLock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		d := &Database{Client: fake}
+		err := d.RunWithLock(ctx, "vault", "report", 30*time.Millisecond, func(ctx context.Context) error {
+			// The lease is refreshed past its TTL while fn runs
+			time.Sleep(100 * time.Millisecond)
+			if _, err := d.AcquireLock(ctx, "vault", "report", time.Minute, "b", LockSkew(0)); !errors.Is(err, ErrLockHeld) {
+				t.Errorf("expected the lock to be held while fn runs, got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if docs := fake.Documents("vault", LockCollection); len(docs) != 0 {
+			t.Errorf("expected the lock to be released, got %v", docs)
+		}
+	})
+
+	t.Run("Held", func(t *testing.T) {
+		d := &Database{Client: NewFakeDatabase()}
+		if _, err := d.AcquireLock(ctx, "vault", "report", time.Minute, "b"); err != nil {
+			t.Fatal(err)
+		}
+		ran := false
+		err := d.RunWithLock(ctx, "vault", "report", time.Minute, func(context.Context) error {
+			ran = true
+			return nil
+		})
+		if !errors.Is(err, ErrLockHeld) || ran {
+			t.Errorf("expected fn not to run, got %v and ran=%v", err, ran)
+		}
+	})
+
+	t.Run("Lost", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		failure := errors.New("interrupted")
+		err := (&Database{Client: fake}).RunWithLock(ctx, "vault", "report", 30*time.Millisecond, func(ctx context.Context) error {
+			fake.DeleteMany(ctx, "vault", LockCollection, map[string]any{})
+			select {
+			case <-ctx.Done():
+				return failure
+			case <-time.After(5 * time.Second):
+				return errors.New("expected the context to end with the lock")
+			}
+		})
+		if !errors.Is(err, ErrLockLost) || !errors.Is(err, failure) {
+			t.Errorf("expected the lost lock and fn's error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrInvalidJoin is returned by FindWithLookup for a Join it cannot turn
+// into a $lookup stage
+var ErrInvalidJoin = errors.New("invalid join")
+
+// Join describes one $lookup of FindWithLookup: the documents of From, a
+// collection of the same database, whose ForeignField equals the
+// LocalField of a matched document are added to it under As
+type Join struct {
+	From         string
+	LocalField   string
+	ForeignField string
+	// As is the field the joined documents are stored under. It defaults to
+	// From, except for a join of the collection with itself.
+	As string
+	// Unwind replaces the array under As with its single element, or leaves
+	// As unset when nothing joined, so a to-one join decodes into a nested
+	// struct. A document joining several outputs once per joined document.
+	Unwind bool
+}
+
+// FindWithLookup returns the documents of db.collection matching filter with
+// the documents of each join added, running one aggregation of a $match
+// followed by a $lookup, and an $unwind for joins with Unwind, per join in
+// order. Joined documents come back as []any of map[string]any, or as a
+// map[string]any with Unwind. opts are passed on to Aggregate. An invalid
+// join fails with an error matching ErrInvalidJoin before anything is sent.
+//
+//	events, err := db.FindWithLookup(ctx, "vault", "events", bson.M{"type": "motion"}, []database.Join{
+//		{From: "cameras", LocalField: "camera_id", ForeignField: "_id", As: "camera", Unwind: true},
+//	})
+func (d *Database) FindWithLookup(ctx context.Context, db string, collection string, filter any, joins []Join, opts ...any) ([]map[string]any, error) {
+	docs, err := findWithLookup(ctx, d, db, collection, filter, joins, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		out[i], _ = normalizeDocument(doc).(map[string]any)
+	}
+	return out, nil
+}
+
+// FindWithLookupAs is FindWithLookup decoding every document into T with the
+// BSON options of d.Options, like FindAs. The field of T for a join's As is
+// a slice, or a struct or pointer with Unwind.
+//
+//	type EventWithCamera struct {
+//		ID     primitive.ObjectID `bson:"_id"`
+//		Type   string             `bson:"type"`
+//		Camera *Camera            `bson:"camera"`
+//	}
+//	events, err := database.FindWithLookupAs[EventWithCamera](ctx, db, "vault", "events", filter, joins)
+func FindWithLookupAs[T any](ctx context.Context, d *Database, db string, collection string, filter any, joins []Join, opts ...any) ([]T, error) {
+	docs, err := findWithLookup(ctx, d, db, collection, filter, joins, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := d.codec()
+	out := make([]T, len(docs))
+	for i, doc := range docs {
+		if err := c.decode(doc, &out[i]); err != nil {
+			return nil, fmt.Errorf("find with lookup: decode document %d into %T: %w", i, out[i], err)
+		}
+	}
+	return out, nil
+}
+
+func findWithLookup(ctx context.Context, d *Database, db string, collection string, filter any, joins []Join, opts []any) ([]any, error) {
+	pipeline, err := lookupPipeline(collection, filter, joins)
+	if err != nil {
+		return nil, fmt.Errorf("find with lookup on %s.%s: %w", db, collection, err)
+	}
+	result, err := d.Client.Aggregate(ctx, db, collection, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	docs, _ := result.([]any)
+	return docs, nil
+}
+
+// lookupPipeline builds the $match, $lookup and $unwind stages of
+// FindWithLookup, validating the joins
+func lookupPipeline(collection string, filter any, joins []Join) (bson.A, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	pipeline := bson.A{bson.M{"$match": filter}}
+	seen := make(map[string]bool, len(joins))
+	for i, join := range joins {
+		switch {
+		case join.From == "":
+			return nil, fmt.Errorf("%w: join %d: From is required", ErrInvalidJoin, i)
+		case join.LocalField == "":
+			return nil, fmt.Errorf("%w: join %d: LocalField is required", ErrInvalidJoin, i)
+		case join.ForeignField == "":
+			return nil, fmt.Errorf("%w: join %d: ForeignField is required", ErrInvalidJoin, i)
+		case join.As == "" && join.From == collection:
+			return nil, fmt.Errorf("%w: join %d: As is required to join %s with itself", ErrInvalidJoin, i, collection)
+		}
+		as := join.As
+		if as == "" {
+			as = join.From
+		}
+		if seen[as] {
+			return nil, fmt.Errorf("%w: join %d: another join already stores its documents under %q", ErrInvalidJoin, i, as)
+		}
+		seen[as] = true
+
+		pipeline = append(pipeline, bson.M{"$lookup": bson.M{
+			"from":         join.From,
+			"localField":   join.LocalField,
+			"foreignField": join.ForeignField,
+			"as":           as,
+		}})
+		if join.Unwind {
+			pipeline = append(pipeline, bson.M{"$unwind": bson.M{
+				"path":                       "$" + as,
+				"preserveNullAndEmptyArrays": true,
+			}})
+		}
+	}
+	return pipeline, nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLookupPipeline(t *testing.T) {
+	camera := Join{From: "cameras", LocalField: "camera_id", ForeignField: "_id"}
+	tests := []struct {
+		name    string
+		joins   []Join
+		wantErr string
+	}{
+		{"DefaultAs", []Join{camera}, ""},
+		{"MissingFrom", []Join{{LocalField: "camera_id", ForeignField: "_id", As: "camera"}}, "From is required"},
+		{"MissingLocalField", []Join{{From: "cameras", ForeignField: "_id", As: "camera"}}, "LocalField is required"},
+		{"MissingForeignField", []Join{{From: "cameras", LocalField: "camera_id", As: "camera"}}, "ForeignField is required"},
+		{"SelfJoinWithoutAs", []Join{{From: "events", LocalField: "parent_id", ForeignField: "_id"}}, "As is required"},
+		{"SelfJoinWithAs", []Join{{From: "events", LocalField: "parent_id", ForeignField: "_id", As: "parent"}}, ""},
+		{"DuplicateAs", []Join{camera, {From: "sites", LocalField: "site_id", ForeignField: "_id", As: "cameras"}}, "already stores"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := lookupPipeline("events", nil, tt.joins)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidJoin) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an ErrInvalidJoin containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Stages", func(t *testing.T) {
+		camera.As, camera.Unwind = "camera", true
+		pipeline, err := lookupPipeline("events", bson.M{"type": "motion"}, []Join{camera})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := bson.A{
+			bson.M{"$match": bson.M{"type": "motion"}},
+			bson.M{"$lookup": bson.M{"from": "cameras", "localField": "camera_id", "foreignField": "_id", "as": "camera"}},
+			bson.M{"$unwind": bson.M{"path": "$camera", "preserveNullAndEmptyArrays": true}},
+		}
+		if !reflect.DeepEqual(pipeline, want) {
+			t.Errorf("expected %v, got %v", want, pipeline)
+		}
+	})
+}
+
+func TestFindWithLookup(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	fake.Seed("vault", "cameras",
+		bson.M{"_id": "cam-1", "name": "front"},
+		bson.M{"_id": "cam-2", "name": "back"},
+	)
+	fake.Seed("vault", "tags",
+		bson.M{"_id": 1, "camera_id": "cam-1", "label": "outdoor"},
+		bson.M{"_id": 2, "camera_id": "cam-1", "label": "entrance"},
+	)
+	fake.Seed("vault", "events",
+		bson.M{"_id": 1, "type": "motion", "camera_id": "cam-1"},
+		bson.M{"_id": 2, "type": "motion", "camera_id": "cam-9"},
+		bson.M{"_id": 3, "type": "sound", "camera_id": "cam-2"},
+		bson.M{"_id": 4, "type": "motion", "camera_id": []any{"cam-1", "cam-2"}},
+	)
+	db := &Database{Client: fake}
+
+	t.Run("Maps", func(t *testing.T) {
+		docs, err := db.FindWithLookup(ctx, "vault", "events", bson.M{"_id": bson.M{"$lt": 3}}, []Join{
+			{From: "cameras", LocalField: "camera_id", ForeignField: "_id"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []map[string]any{
+			{"_id": int32(1), "type": "motion", "camera_id": "cam-1", "cameras": []any{
+				map[string]any{"_id": "cam-1", "name": "front"},
+			}},
+			{"_id": int32(2), "type": "motion", "camera_id": "cam-9", "cameras": []any{}},
+		}
+		if !reflect.DeepEqual(docs, want) {
+			t.Errorf("expected %v, got %v", want, docs)
+		}
+	})
+
+	t.Run("ArrayLocalField", func(t *testing.T) {
+		docs, err := db.FindWithLookup(ctx, "vault", "events", bson.M{"_id": 4}, []Join{
+			{From: "cameras", LocalField: "camera_id", ForeignField: "_id"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := docs[0]["cameras"].([]any); len(got) != 2 {
+			t.Errorf("expected an array local field to join every element, got %v", got)
+		}
+	})
+
+	t.Run("Typed", func(t *testing.T) {
+		type camera struct {
+			ID   string `bson:"_id"`
+			Name string `bson:"name"`
+		}
+		type tag struct {
+			Label string `bson:"label"`
+		}
+		type event struct {
+			ID     int     `bson:"_id"`
+			Type   string  `bson:"type"`
+			Camera *camera `bson:"camera"`
+			Tags   []tag   `bson:"tags"`
+		}
+		events, err := FindWithLookupAs[event](ctx, db, "vault", "events", bson.M{"type": "motion", "_id": bson.M{"$ne": 4}}, []Join{
+			{From: "cameras", LocalField: "camera_id", ForeignField: "_id", As: "camera", Unwind: true},
+			{From: "tags", LocalField: "camera._id", ForeignField: "camera_id", As: "tags"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("expected 2 events, got %v", events)
+		}
+		if got := events[0]; got.Camera == nil || got.Camera.Name != "front" || len(got.Tags) != 2 || got.Tags[0].Label != "outdoor" {
+			t.Errorf("expected the camera and its tags to be joined, got %+v", got)
+		}
+		if got := events[1]; got.Camera != nil || len(got.Tags) != 0 {
+			t.Errorf("expected an event without a camera to keep a nil camera, got %+v", got)
+		}
+	})
+
+	t.Run("SelfJoin", func(t *testing.T) {
+		fake.Seed("vault", "events", bson.M{"_id": 5, "type": "clip", "parent_id": 1})
+		docs, err := db.FindWithLookup(ctx, "vault", "events", bson.M{"_id": 5}, []Join{
+			{From: "events", LocalField: "parent_id", ForeignField: "_id", As: "parent", Unwind: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent, _ := docs[0]["parent"].(map[string]any); parent["type"] != "motion" {
+			t.Errorf("expected the parent event to be joined, got %v", docs[0])
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		mock := NewMockDatabase()
+		_, err := (&Database{Client: mock}).FindWithLookup(ctx, "vault", "events", nil, []Join{{From: "events", LocalField: "parent_id", ForeignField: "_id"}})
+		if !errors.Is(err, ErrInvalidJoin) {
+			t.Errorf("expected ErrInvalidJoin, got %v", err)
+		}
+		if len(mock.AggregateCalls) != 0 {
+			t.Errorf("expected nothing to be sent, got %d aggregations", len(mock.AggregateCalls))
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaskAction is what a FieldMask does to the values at its path
+type MaskAction int
+
+const (
+	// MaskDrop removes the field, or the array element
+	MaskDrop MaskAction = iota + 1
+	// MaskHash replaces the value with the hex HMAC-SHA256 of its BSON
+	// encoding, keyed by MaskSpec.Salt, so equal values stay equal
+	MaskHash
+	// MaskRedact replaces the value with a placeholder of its type: the
+	// string "REDACTED", zero for numbers, false, the Unix epoch, a zero
+	// ObjectID, or an empty document, array or binary
+	MaskRedact
+	// MaskTruncate keeps the first Length characters of a string, elements
+	// of an array or bytes of a binary value
+	MaskTruncate
+)
+
+// Redacted is the placeholder MaskRedact writes over strings
+const Redacted = "REDACTED"
+
+// ErrMaskUnmatched is returned by an export with a strict MaskSpec when
+// paths matched no value in any document; the error lists them
+var ErrMaskUnmatched = errors.New("mask paths matched no value")
+
+// FieldMask masks the values at one path
+type FieldMask struct {
+	// Path is a dotted path such as "customer.email". A path through an
+	// array applies to every element, as in "contacts.phone", unless the
+	// segment is an index, as in "contacts.0.phone".
+	Path   string
+	Action MaskAction
+	// Length is the length MaskTruncate keeps
+	Length int
+}
+
+// MaskSpec strips or hashes personal data from documents, as ExportOptions
+// applies to every exported document
+type MaskSpec struct {
+	Fields []FieldMask
+	// Salt keys MaskHash. Exports sharing a salt hash equal values alike, so
+	// joins between exported collections still line up; without one, every
+	// export draws a random salt.
+	Salt []byte
+	// Strict fails the export when a path matched no value in any document,
+	// which catches typos
+	Strict bool
+}
+
+// masker applies a MaskSpec to the documents of one export
+type masker struct {
+	fields  []FieldMask
+	parts   [][]string
+	hash    hash.Hash
+	matched []bool
+}
+
+// newMasker validates spec and returns its masker, with a random salt when
+// spec has none; a nil spec masks nothing
+func newMasker(spec *MaskSpec) (*masker, error) {
+	if spec == nil || len(spec.Fields) == 0 {
+		return nil, nil
+	}
+	m := &masker{fields: spec.Fields, matched: make([]bool, len(spec.Fields))}
+	for _, f := range spec.Fields {
+		if f.Path == "" || strings.Contains(f.Path, "..") || strings.HasPrefix(f.Path, ".") || strings.HasSuffix(f.Path, ".") {
+			return nil, fmt.Errorf("mask: invalid path %q", f.Path)
+		}
+		if f.Action < MaskDrop || f.Action > MaskTruncate {
+			return nil, fmt.Errorf("mask: invalid action %d for %q", f.Action, f.Path)
+		}
+		if f.Action == MaskTruncate && f.Length < 0 {
+			return nil, fmt.Errorf("mask: negative length for %q", f.Path)
+		}
+		m.parts = append(m.parts, strings.Split(f.Path, "."))
+	}
+	salt := spec.Salt
+	if len(salt) == 0 {
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("mask: %w", err)
+		}
+	}
+	m.hash = hmac.New(sha256.New, salt)
+	return m, nil
+}
+
+// apply masks doc in place and returns it
+func (m *masker) apply(doc bson.D) (bson.D, error) {
+	for i, f := range m.fields {
+		out, _, err := m.mask(doc, m.parts[i], i)
+		if err != nil {
+			return nil, fmt.Errorf("mask %q: %w", f.Path, err)
+		}
+		doc = out.(bson.D)
+	}
+	return doc, nil
+}
+
+// unmatched returns the paths that matched no value so far
+func (m *masker) unmatched() []string {
+	var paths []string
+	for i, ok := range m.matched {
+		if !ok {
+			paths = append(paths, m.fields[i].Path)
+		}
+	}
+	return paths
+}
+
+// mask applies field i to the values at parts below v, returning the masked
+// v, or drop when v itself is removed
+func (m *masker) mask(v any, parts []string, i int) (out any, drop bool, err error) {
+	if len(parts) == 0 {
+		m.matched[i] = true
+		return m.leaf(v, m.fields[i])
+	}
+	switch t := v.(type) {
+	case bson.D:
+		for j := 0; j < len(t); j++ {
+			if t[j].Key != parts[0] {
+				continue
+			}
+			value, drop, err := m.mask(t[j].Value, parts[1:], i)
+			if err != nil {
+				return nil, false, err
+			}
+			if drop {
+				t = append(t[:j], t[j+1:]...)
+				j--
+				continue
+			}
+			t[j].Value = value
+		}
+		return t, false, nil
+	case bson.M:
+		if value, ok := t[parts[0]]; ok {
+			value, drop, err := m.mask(value, parts[1:], i)
+			if err != nil {
+				return nil, false, err
+			}
+			if drop {
+				delete(t, parts[0])
+			} else {
+				t[parts[0]] = value
+			}
+		}
+		return t, false, nil
+	case bson.A:
+		elems, err := m.maskArray(t, parts, i)
+		return bson.A(elems), false, err
+	case []any:
+		elems, err := m.maskArray(t, parts, i)
+		return elems, false, err
+	}
+	return v, false, nil
+}
+
+// maskArray applies field i to the element an index segment names, or to
+// every element
+func (m *masker) maskArray(elems []any, parts []string, i int) ([]any, error) {
+	if index, err := strconv.Atoi(parts[0]); err == nil {
+		if index < 0 || index >= len(elems) {
+			return elems, nil
+		}
+		value, drop, err := m.mask(elems[index], parts[1:], i)
+		if err != nil {
+			return nil, err
+		}
+		if drop {
+			return append(elems[:index], elems[index+1:]...), nil
+		}
+		elems[index] = value
+		return elems, nil
+	}
+	for j, elem := range elems {
+		value, _, err := m.mask(elem, parts, i)
+		if err != nil {
+			return nil, err
+		}
+		elems[j] = value
+	}
+	return elems, nil
+}
+
+// leaf applies f to the value at its path
+func (m *masker) leaf(v any, f FieldMask) (any, bool, error) {
+	switch f.Action {
+	case MaskDrop:
+		return nil, true, nil
+	case MaskHash:
+		h, err := m.hashValue(v)
+		return h, false, err
+	case MaskRedact:
+		return redactValue(v), false, nil
+	}
+	return truncateValue(v, f.Length), false, nil
+}
+
+// hashValue returns the hex HMAC of v's BSON type and encoding, so values
+// of different types never collide
+func (m *masker) hashValue(v any) (string, error) {
+	t, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return "", err
+	}
+	m.hash.Reset()
+	m.hash.Write([]byte{byte(t)})
+	m.hash.Write(data)
+	return hex.EncodeToString(m.hash.Sum(nil)), nil
+}
+
+// redactValue returns the placeholder of v's type
+func redactValue(v any) any {
+	switch v.(type) {
+	case string:
+		return Redacted
+	case int32:
+		return int32(0)
+	case int64:
+		return int64(0)
+	case int:
+		return 0
+	case float64:
+		return float64(0)
+	case primitive.Decimal128:
+		return primitive.NewDecimal128(0, 0)
+	case bool:
+		return false
+	case primitive.DateTime:
+		return primitive.DateTime(0)
+	case time.Time:
+		return time.Unix(0, 0).UTC()
+	case primitive.ObjectID:
+		return primitive.NilObjectID
+	case bson.D:
+		return bson.D{}
+	case bson.M:
+		return bson.M{}
+	case bson.A:
+		return bson.A{}
+	case []any:
+		return []any{}
+	case primitive.Binary:
+		return primitive.Binary{Subtype: v.(primitive.Binary).Subtype, Data: []byte{}}
+	case nil:
+		return nil
+	}
+	return Redacted
+}
+
+// truncateValue shortens strings, arrays and binary values to n
+func truncateValue(v any, n int) any {
+	switch t := v.(type) {
+	case string:
+		if r := []rune(t); len(r) > n {
+			return string(r[:n])
+		}
+	case bson.A:
+		if len(t) > n {
+			return t[:n]
+		}
+	case []any:
+		if len(t) > n {
+			return t[:n]
+		}
+	case primitive.Binary:
+		if len(t.Data) > n {
+			return primitive.Binary{Subtype: t.Subtype, Data: t.Data[:n]}
+		}
+	}
+	return v
+}
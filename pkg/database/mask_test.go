@@ -0,0 +1,191 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMask(t *testing.T) {
+	id := primitive.NewObjectID()
+	document := func() bson.D {
+		return bson.D{
+			{Key: "_id", Value: id},
+			{Key: "name", Value: "Ada Lovelace"},
+			{Key: "age", Value: int32(36)},
+			{Key: "address", Value: bson.D{{Key: "street", Value: "12 St James's Square"}, {Key: "city", Value: "London"}}},
+			{Key: "contacts", Value: bson.A{
+				bson.D{{Key: "kind", Value: "email"}, {Key: "value", Value: "ada@example.com"}},
+				bson.D{{Key: "kind", Value: "phone"}, {Key: "value", Value: "+44 20 7946 0000"}},
+			}},
+			{Key: "tags", Value: bson.A{"vip", "early", "beta"}},
+		}
+	}
+	salt := []byte("salt")
+	hashOf := func(v any) string {
+		m, err := newMasker(&MaskSpec{Fields: []FieldMask{{Path: "x", Action: MaskHash}}, Salt: salt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := m.hashValue(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		fields []FieldMask
+		want   func(doc bson.D) bson.D
+	}{
+		{
+			name:   "DropNested",
+			fields: []FieldMask{{Path: "address.street", Action: MaskDrop}},
+			want: func(doc bson.D) bson.D {
+				doc[3].Value = bson.D{{Key: "city", Value: "London"}}
+				return doc
+			},
+		},
+		{
+			name:   "HashArrayOfDocuments",
+			fields: []FieldMask{{Path: "contacts.value", Action: MaskHash}},
+			want: func(doc bson.D) bson.D {
+				contacts := doc[4].Value.(bson.A)
+				contacts[0].(bson.D)[1].Value = hashOf("ada@example.com")
+				contacts[1].(bson.D)[1].Value = hashOf("+44 20 7946 0000")
+				return doc
+			},
+		},
+		{
+			name:   "ArrayIndex",
+			fields: []FieldMask{{Path: "contacts.1", Action: MaskDrop}, {Path: "tags.0", Action: MaskRedact}},
+			want: func(doc bson.D) bson.D {
+				doc[4].Value = doc[4].Value.(bson.A)[:1]
+				doc[5].Value = bson.A{Redacted, "early", "beta"}
+				return doc
+			},
+		},
+		{
+			name: "RedactTypes",
+			fields: []FieldMask{
+				{Path: "_id", Action: MaskRedact},
+				{Path: "age", Action: MaskRedact},
+				{Path: "address", Action: MaskRedact},
+			},
+			want: func(doc bson.D) bson.D {
+				doc[0].Value = primitive.NilObjectID
+				doc[2].Value = int32(0)
+				doc[3].Value = bson.D{}
+				return doc
+			},
+		},
+		{
+			name:   "Truncate",
+			fields: []FieldMask{{Path: "name", Action: MaskTruncate, Length: 3}, {Path: "tags", Action: MaskTruncate, Length: 1}},
+			want: func(doc bson.D) bson.D {
+				doc[1].Value = "Ada"
+				doc[5].Value = bson.A{"vip"}
+				return doc
+			},
+		},
+		{
+			name:   "UnknownPath",
+			fields: []FieldMask{{Path: "address.zip", Action: MaskDrop}, {Path: "name.first", Action: MaskHash}},
+			want:   func(doc bson.D) bson.D { return doc },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMasker(&MaskSpec{Fields: tt.fields, Salt: salt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.apply(document())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.want(document()); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, f := range []FieldMask{{Path: "", Action: MaskDrop}, {Path: "a..b", Action: MaskDrop}, {Path: "a", Action: 0}, {Path: "a", Action: MaskTruncate, Length: -1}} {
+			if _, err := newMasker(&MaskSpec{Fields: []FieldMask{f}}); err == nil {
+				t.Errorf("expected %+v to be rejected", f)
+			}
+		}
+	})
+}
+
+func TestExportMask(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	customer := primitive.NewObjectID()
+	fake.Seed("crm", "customers", bson.M{"_id": customer, "email": "ada@example.com"})
+	fake.Seed("crm", "orders", bson.M{"customer": customer, "total": 10}, bson.M{"customer": customer, "total": 20})
+	db := &Database{Client: fake}
+
+	export := func(t *testing.T, collection string, mask *MaskSpec) ([]bson.M, error) {
+		t.Helper()
+		var out bytes.Buffer
+		_, err := db.Export(ctx, "crm", collection, nil, &out, ExportOptions{Mask: mask})
+		var docs []bson.M
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var doc bson.M
+			if err := bson.UnmarshalExtJSON([]byte(line), false, &doc); err != nil {
+				t.Fatal(err)
+			}
+			docs = append(docs, doc)
+		}
+		return docs, err
+	}
+
+	t.Run("Joins", func(t *testing.T) {
+		salt := []byte("incident-4711")
+		customers, err := export(t, "customers", &MaskSpec{Fields: []FieldMask{{Path: "_id", Action: MaskHash}, {Path: "email", Action: MaskDrop}}, Salt: salt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders, err := export(t, "orders", &MaskSpec{Fields: []FieldMask{{Path: "customer", Action: MaskHash}}, Salt: salt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashed, ok := customers[0]["_id"].(string)
+		if !ok || hashed == customer.Hex() || customers[0]["email"] != nil {
+			t.Fatalf("expected a hashed _id and no email, got %v", customers[0])
+		}
+		for _, order := range orders {
+			if order["customer"] != hashed {
+				t.Errorf("expected orders to join on %s, got %v", hashed, order["customer"])
+			}
+		}
+
+		other, _ := export(t, "orders", &MaskSpec{Fields: []FieldMask{{Path: "customer", Action: MaskHash}}})
+		if other[0]["customer"] == hashed {
+			t.Errorf("expected a random salt to hash differently")
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		fields := []FieldMask{{Path: "email", Action: MaskDrop}, {Path: "emial", Action: MaskDrop}}
+		if _, err := export(t, "customers", &MaskSpec{Fields: fields}); err != nil {
+			t.Errorf("expected unknown paths to be ignored, got %v", err)
+		}
+		docs, err := export(t, "customers", &MaskSpec{Fields: fields, Strict: true})
+		if !errors.Is(err, ErrMaskUnmatched) || !strings.Contains(err.Error(), "emial") || strings.Contains(err.Error(), "email,") {
+			t.Errorf("expected only emial to be reported, got %v", err)
+		}
+		if len(docs) != 1 || docs[0]["email"] != nil {
+			t.Errorf("expected the masked document, got %v", docs)
+		}
+	})
+}
@@ -0,0 +1,334 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FilterMatcher reports whether a filter satisfies an expectation.
+// Any func(filter any) bool can be used directly as a FilterMatcher.
+type FilterMatcher func(filter any) bool
+
+// FilterContains matches filters that contain every key of sub with an equal value.
+// Nested maps are compared by containment as well, so sub may describe only part
+// of a nested document. ObjectIDs compare as their hex strings, so sub can
+// give an ID as the hex string a test got from a URL.
+func FilterContains(sub map[string]any) FilterMatcher {
+	want := normalizeFilter(sub)
+	return func(filter any) bool {
+		return containsValue(normalizeFilter(filter), want)
+	}
+}
+
+// FilterEquals matches filters that are deeply equal to doc, comparing
+// ObjectIDs as their hex strings like FilterContains
+func FilterEquals(doc any) FilterMatcher {
+	want := normalizeFilter(doc)
+	return func(filter any) bool {
+		return valuesEqual(normalizeFilter(filter), want)
+	}
+}
+
+// FilterHasKeys matches filters that contain all of the given keys.
+// Dotted keys are resolved through nested documents when not present literally.
+func FilterHasKeys(keys ...string) FilterMatcher {
+	return func(filter any) bool {
+		doc, ok := normalizeDocument(filter).(map[string]any)
+		if !ok {
+			return len(keys) == 0
+		}
+		for _, key := range keys {
+			if _, ok := lookupPath(doc, key); !ok {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// PipelineHasStage matches aggregation pipelines with a stage called name,
+// e.g. "$match", whose spec satisfies every matcher; with no matchers any
+// such stage matches
+func PipelineHasStage(name string, matchers ...FilterMatcher) FilterMatcher {
+	return func(pipeline any) bool {
+		for _, stage := range pipelineStagesOf(pipeline) {
+			if stage.Name != name {
+				continue
+			}
+			matched := true
+			for _, matcher := range matchers {
+				if !matcher(stage.Spec) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AnyFilter matches every filter, including nil
+func AnyFilter() FilterMatcher {
+	return func(filter any) bool {
+		return true
+	}
+}
+
+// normalizeDocument converts the document flavours accepted by the driver
+// (bson.M, bson.D, map[string]any, structs) into map[string]any and []any so
+// they can be compared independently of how the caller spelled them
+func normalizeDocument(v any) any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case time.Time, primitive.Binary, primitive.Regex, primitive.Timestamp,
+		primitive.Decimal128, primitive.CodeWithScope, primitive.DBPointer:
+		// BSON value types are compared as-is rather than as documents
+		return t
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[k] = normalizeDocument(val)
+		}
+		return out
+	case bson.M:
+		return normalizeDocument(map[string]any(t))
+	case bson.D:
+		out := make(map[string]any, len(t))
+		for _, e := range t {
+			out[e.Key] = normalizeDocument(e.Value)
+		}
+		return out
+	case bson.E:
+		return map[string]any{t.Key: normalizeDocument(t.Value)}
+	case bson.A:
+		return normalizeDocument([]any(t))
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = normalizeDocument(val)
+		}
+		return out
+	}
+
+	if m, ok := v.(bson.Marshaler); ok {
+		// Documents with their own encoding, such as an UpdateBuilder,
+		// compare as the document they encode to
+		if data, err := m.MarshalBSON(); err == nil {
+			var out bson.M
+			if err := bson.Unmarshal(data, &out); err == nil {
+				return normalizeDocument(out)
+			}
+		}
+	}
+	if m, ok := v.(bson.ValueMarshaler); ok {
+		// Types with their own BSON encoding, such as UUID, compare as the
+		// value they encode to, as they would on the server
+		if typ, data, err := m.MarshalBSONValue(); err == nil {
+			var out any
+			if err := (bson.RawValue{Type: typ, Value: data}).Unmarshal(&out); err == nil {
+				return normalizeDocument(out)
+			}
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = normalizeDocument(iter.Value().Interface())
+		}
+		return out
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = normalizeDocument(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// ObjectIDs and other fixed-size binary values compare as-is
+			return v
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = normalizeDocument(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Elem().Kind() == reflect.Struct {
+			return normalizeStruct(v)
+		}
+		return normalizeDocument(rv.Elem().Interface())
+	case reflect.Struct:
+		return normalizeStruct(v)
+	}
+	return v
+}
+
+// normalizeFilter normalizes a filter for the matchers, which compare
+// ObjectIDs as hex strings
+func normalizeFilter(v any) any {
+	return hexObjectIDs(normalizeDocument(v))
+}
+
+func hexObjectIDs(v any) any {
+	switch t := v.(type) {
+	case primitive.ObjectID:
+		return t.Hex()
+	case map[string]any:
+		for k, val := range t {
+			t[k] = hexObjectIDs(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = hexObjectIDs(val)
+		}
+	}
+	return v
+}
+
+// normalizeStruct converts a struct into a map via a BSON round trip so bson
+// tags are honoured the same way the driver would honour them
+func normalizeStruct(v any) any {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return v
+	}
+	return normalizeDocument(m)
+}
+
+// containsValue reports whether got contains want: maps by key subset,
+// everything else by equality
+func containsValue(got, want any) bool {
+	wantMap, ok := want.(map[string]any)
+	if !ok {
+		return valuesEqual(got, want)
+	}
+	gotMap, ok := got.(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, wv := range wantMap {
+		gv, ok := gotMap[k]
+		if !ok || !containsValue(gv, wv) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesEqual compares two normalized values, treating numbers of different
+// Go types as equal when they hold the same value
+func valuesEqual(a, b any) bool {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			return af == bf
+		}
+		return false
+	}
+	switch at := a.(type) {
+	case map[string]any:
+		bt, ok := b.(map[string]any)
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for k, av := range at {
+			bv, ok := bt[k]
+			if !ok || !valuesEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bt, ok := b.([]any)
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !valuesEqual(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if at, ok := asTime(a); ok {
+		bt, ok := asTime(b)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat converts any Go numeric value to float64
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// lookupPath resolves key in doc, first literally and then as a dotted path
+// through nested documents
+func lookupPath(doc map[string]any, key string) (any, bool) {
+	if v, ok := doc[key]; ok {
+		return v, true
+	}
+	parts := strings.Split(key, ".")
+	var current any = doc
+	for _, part := range parts {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestFilterMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher FilterMatcher
+		filter  any
+		expect  bool
+	}{
+		{
+			name:    "ContainsSubset",
+			matcher: FilterContains(map[string]any{"status": "active"}),
+			filter:  map[string]any{"status": "active", "site_id": "s1"},
+			expect:  true,
+		},
+		{
+			name:    "ContainsBsonM",
+			matcher: FilterContains(map[string]any{"status": "active"}),
+			filter:  bson.M{"status": "active"},
+			expect:  true,
+		},
+		{
+			name:    "ContainsBsonD",
+			matcher: FilterContains(map[string]any{"status": "active"}),
+			filter:  bson.D{{Key: "status", Value: "active"}, {Key: "age", Value: 3}},
+			expect:  true,
+		},
+		{
+			name:    "ContainsNestedPartial",
+			matcher: FilterContains(map[string]any{"meta": map[string]any{"site": "a"}}),
+			filter:  bson.M{"meta": bson.M{"site": "a", "zone": "b"}},
+			expect:  true,
+		},
+		{
+			name:    "ContainsWrongValue",
+			matcher: FilterContains(map[string]any{"status": "active"}),
+			filter:  map[string]any{"status": "inactive"},
+			expect:  false,
+		},
+		{
+			name:    "ContainsNumericKinds",
+			matcher: FilterContains(map[string]any{"age": 3}),
+			filter:  bson.M{"age": int64(3)},
+			expect:  true,
+		},
+		{
+			name:    "EqualsExact",
+			matcher: FilterEquals(bson.M{"a": 1, "b": bson.M{"c": "d"}}),
+			filter:  map[string]any{"a": 1, "b": map[string]any{"c": "d"}},
+			expect:  true,
+		},
+		{
+			name:    "EqualsExtraKey",
+			matcher: FilterEquals(map[string]any{"a": 1}),
+			filter:  map[string]any{"a": 1, "b": 2},
+			expect:  false,
+		},
+		{
+			name:    "HasKeys",
+			matcher: FilterHasKeys("site_id", "meta.zone"),
+			filter:  bson.M{"site_id": "s1", "meta": bson.M{"zone": "z"}},
+			expect:  true,
+		},
+		{
+			name:    "HasKeysMissing",
+			matcher: FilterHasKeys("site_id"),
+			filter:  bson.M{"status": "active"},
+			expect:  false,
+		},
+		{
+			name: "RawFunc",
+			matcher: func(filter any) bool {
+				_, ok := filter.(string)
+				return ok
+			},
+			filter: "raw",
+			expect: true,
+		},
+		{
+			name:    "PipelineHasStage",
+			matcher: PipelineHasStage("$match", FilterHasKeys("tenant_id")),
+			filter:  mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "a", Value: 1}}}}, {{Key: "$match", Value: bson.M{"tenant_id": "t1"}}}},
+			expect:  true,
+		},
+		{
+			name:    "PipelineStageSpecMismatch",
+			matcher: PipelineHasStage("$match", FilterHasKeys("tenant_id")),
+			filter:  []bson.M{{"$match": bson.M{"site": "s1"}}},
+			expect:  false,
+		},
+		{
+			name:    "PipelineWithoutStage",
+			matcher: PipelineHasStage("$group"),
+			filter:  bson.A{bson.M{"$match": bson.M{}}},
+			expect:  false,
+		},
+		{
+			name:    "NotAPipeline",
+			matcher: PipelineHasStage("$match"),
+			filter:  bson.M{"$match": bson.M{}},
+			expect:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher(tt.filter); got != tt.expect {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
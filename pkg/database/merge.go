@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// mark records that the builder set field, so MergeOptions applies its zero
+// value too
+func (b *MongoOptionsBuilder) mark(field string) {
+	if b.options.explicit == nil {
+		b.options.explicit = make(map[string]bool)
+	}
+	b.options.explicit[field] = true
+}
+
+// isExplicit reports whether the builder set field, zero values included
+func (o *MongoOptions) isExplicit(field string) bool {
+	if field == "TimeoutDuration" {
+		return o.timeoutSet
+	}
+	return o.explicit[field]
+}
+
+// MergeOptions layers options, such as defaults from code, then a config
+// file, then environment variables: each field of the result comes from the
+// last of base and overrides that has it non-zero. Fields whose zero value is
+// a choice, such as SetRetryWrites(false), SetTLS(false) or
+// SetTimeoutDuration(0), also win when they were set through the builder.
+// Slices such as Hosts are replaced, not appended to. nil arguments are
+// skipped, and none of the arguments is modified.
+//
+//	opts := database.MergeOptions(defaults, fromFile, fromEnv)
+func MergeOptions(base *MongoOptions, overrides ...*MongoOptions) *MongoOptions {
+	merged := &MongoOptions{}
+	fields := reflect.TypeFor[MongoOptions]()
+	to := reflect.ValueOf(merged).Elem()
+	for _, o := range append([]*MongoOptions{base}, overrides...) {
+		if o == nil {
+			continue
+		}
+		from := reflect.ValueOf(o).Elem()
+		for i := range fields.NumField() {
+			field := fields.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			value := from.Field(i)
+			if value.IsZero() && !o.isExplicit(field.Name) {
+				continue
+			}
+			if value.Kind() == reflect.Slice {
+				value = reflect.AppendSlice(reflect.MakeSlice(value.Type(), 0, value.Len()), value)
+			}
+			to.Field(i).Set(value)
+			if field.Name == "Timeout" {
+				// A deprecated timeout overrides the duration of earlier
+				// options, unless these options set a duration too
+				merged.TimeoutDuration, merged.timeoutSet = 0, false
+			}
+			if o.isExplicit(field.Name) {
+				if field.Name == "TimeoutDuration" {
+					merged.timeoutSet = true
+				} else {
+					if merged.explicit == nil {
+						merged.explicit = make(map[string]bool)
+					}
+					merged.explicit[field.Name] = true
+				}
+			}
+		}
+		merged.buildErr = errors.Join(merged.buildErr, o.buildErr)
+	}
+	return merged
+}
+
+// FieldDiff is a field that differs between two MongoOptions, with the
+// values formatted for logs: the password is redacted, as are the passwords
+// of the URI and the proxy URL, and nested settings such as the SSH tunnel
+// and encryption only show whether they are set.
+type FieldDiff struct {
+	Field string
+	From  string
+	To    string
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Field, d.From, d.To)
+}
+
+// Diff returns the fields that differ from o in other, in field order, for
+// logging what an override changed:
+//
+//	for _, d := range defaults.Diff(opts) {
+//		log.Printf("mongo option %s", d)
+//	}
+func (o *MongoOptions) Diff(other *MongoOptions) []FieldDiff {
+	if o == nil {
+		o = &MongoOptions{}
+	}
+	if other == nil {
+		other = &MongoOptions{}
+	}
+	var diffs []FieldDiff
+	fields := reflect.TypeFor[MongoOptions]()
+	from, to := reflect.ValueOf(o).Elem(), reflect.ValueOf(other).Elem()
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		if !field.IsExported() || equalOption(from.Field(i), to.Field(i)) {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field: field.Name,
+			From:  formatOption(field.Name, from.Field(i)),
+			To:    formatOption(field.Name, to.Field(i)),
+		})
+	}
+	return diffs
+}
+
+// equalOption compares two values of a field; functions are equal when they
+// are the same function
+func equalOption(a reflect.Value, b reflect.Value) bool {
+	if a.Kind() == reflect.Func {
+		return a.Pointer() == b.Pointer()
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// formatOption formats the value of a field for FieldDiff, redacting secrets
+func formatOption(field string, v reflect.Value) string {
+	switch field {
+	case "Password":
+		if v.String() == "" {
+			return `""`
+		}
+		return "xxxxx"
+	case "Uri":
+		return fmt.Sprintf("%q", redactURI(v.String()))
+	case "ProxyURL":
+		if u, err := url.Parse(v.String()); err == nil {
+			return fmt.Sprintf("%q", u.Redacted())
+		}
+		return fmt.Sprintf("%q", redactURI(v.String()))
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Map:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		return "<set>"
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,39 @@
+package database
+
+// Middleware wraps a client to add behaviour to its operations, such as
+// WithTimestamps. Wrapping the mock or the fake runs the same middleware in
+// tests as against MongoDB.
+type Middleware func(next DatabaseInterface) DatabaseInterface
+
+// Wrap returns client wrapped in middleware; the first middleware is the
+// outermost, so it sees each call first
+func Wrap(client DatabaseInterface, middleware ...Middleware) DatabaseInterface {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		client = middleware[i](client)
+	}
+	return client
+}
+
+// unwrapper is implemented by middleware clients to expose the client they
+// wrap
+type unwrapper interface {
+	Unwrap() DatabaseInterface
+}
+
+// implementation returns the outermost client of the middleware chain
+// starting at client that implements T, so helpers for optional interfaces
+// such as SchemaManager reach through middleware
+func implementation[T any](client DatabaseInterface) (T, bool) {
+	for client != nil {
+		if impl, ok := client.(T); ok {
+			return impl, true
+		}
+		u, ok := client.(unwrapper)
+		if !ok {
+			break
+		}
+		client = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
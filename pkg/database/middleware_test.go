@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+// tagClient prefixes the collection of every Find with its tag
+type tagClient struct {
+	DatabaseInterface
+	tag string
+}
+
+func (c tagClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return c.DatabaseInterface.Find(ctx, db, c.tag+collection, filter, opts...)
+}
+
+func TestWrap(t *testing.T) {
+	tag := func(tag string) Middleware {
+		return func(next DatabaseInterface) DatabaseInterface {
+			return tagClient{DatabaseInterface: next, tag: tag}
+		}
+	}
+
+	mock := NewMockDatabase()
+	client := Wrap(mock, tag("a."), tag("b."))
+	client.Find(context.Background(), "vault", "cameras", nil)
+
+	// The first middleware sees the call first, so its tag ends up innermost
+	if call, _ := mock.LastFindCall(); call.Collection != "b.a.cameras" {
+		t.Errorf("expected b.a.cameras, got %s", call.Collection)
+	}
+	if Wrap(mock) != DatabaseInterface(mock) {
+		t.Error("expected no middleware to return the client itself")
+	}
+}
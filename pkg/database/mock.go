@@ -2,170 +2,653 @@ package database
 
 import (
 	"context"
-	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// MockDatabase is a mock implementation of DatabaseInterface for testing
+// MockDatabase is a mock implementation of DatabaseInterface for testing.
+// It is safe for concurrent use; the exported call and queue slices should
+// only be read once the code under test has finished.
 type MockDatabase struct {
+	mu sync.Mutex
+
 	// PingFunc allows customizing Ping behavior
 	PingFunc func(ctx context.Context) error
 
+	// CloseFunc allows customizing Close behavior
+	CloseFunc func(ctx context.Context) error
+
 	// FindFunc allows customizing Find behavior
 	FindFunc func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error)
 
 	// FindOneFunc allows customizing FindOne behavior
 	FindOneFunc func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error)
 
+	// FindCursorFunc allows customizing FindCursor behavior
+	FindCursorFunc func(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error)
+
+	// AggregateFunc allows customizing Aggregate behavior
+	AggregateFunc func(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error)
+
+	// CountFunc allows customizing Count behavior
+	CountFunc func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error)
+
+	// DistinctFunc allows customizing Distinct behavior
+	DistinctFunc func(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error)
+
+	// InsertOneFunc allows customizing InsertOne behavior
+	InsertOneFunc func(ctx context.Context, db string, collection string, document any, opts ...any) (any, error)
+
+	// InsertManyFunc allows customizing InsertMany behavior
+	InsertManyFunc func(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error)
+
+	// UpdateOneFunc allows customizing UpdateOne behavior
+	UpdateOneFunc func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error)
+
+	// UpdateManyFunc allows customizing UpdateMany behavior
+	UpdateManyFunc func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error)
+
+	// ReplaceOneFunc allows customizing ReplaceOne behavior
+	ReplaceOneFunc func(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error)
+
+	// DeleteOneFunc allows customizing DeleteOne behavior
+	DeleteOneFunc func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error)
+
+	// DeleteManyFunc allows customizing DeleteMany behavior
+	DeleteManyFunc func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error)
+
+	// FindOneAndUpdateFunc allows customizing FindOneAndUpdate behavior
+	FindOneAndUpdateFunc func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error)
+
+	// BulkWriteFunc allows customizing BulkWrite behavior
+	BulkWriteFunc func(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error)
+
+	// WatchFunc allows customizing Watch behavior; unset, Watch streams the
+	// events sent to ChangeStream
+	WatchFunc func(ctx context.Context, db string, collection string, pipeline any, opts ...any) (ChangeStream, error)
+
+	// WithTransactionFunc allows customizing WithTransaction behavior; it is
+	// responsible for calling fn
+	WithTransactionFunc func(ctx context.Context, fn func(txCtx context.Context) error) error
+
+	// Change event feeds per namespace, see ChangeStream
+	changeStreams map[string]*MockChangeStream
+
 	// Sequential response queues for multiple calls
-	PingQueue    []PingResponse
-	FindQueue    []FindResponse
-	FindOneQueue []FindOneResponse
+	PingQueue             []PingResponse
+	CloseQueue            []CloseResponse
+	FindQueue             []FindResponse
+	FindOneQueue          []FindOneResponse
+	FindCursorQueue       []FindCursorResponse
+	AggregateQueue        []AggregateResponse
+	CountQueue            []CountResponse
+	DistinctQueue         []DistinctResponse
+	InsertOneQueue        []InsertOneResponse
+	InsertManyQueue       []InsertManyResponse
+	UpdateOneQueue        []UpdateOneResponse
+	UpdateManyQueue       []UpdateManyResponse
+	ReplaceOneQueue       []ReplaceOneResponse
+	DeleteOneQueue        []DeleteOneResponse
+	DeleteManyQueue       []DeleteManyResponse
+	FindOneAndUpdateQueue []FindOneAndUpdateResponse
+	BulkWriteQueue        []BulkWriteResponse
+
+	// Errors queued for the next matching call of any operation, see
+	// QueueDuplicateKey
+	faults []mockFault
+
+	// Ordered steps registered via Script, the position of the current step
+	// and the calls that deviated from it
+	script           []*Expectation
+	scriptPos        int
+	scriptDeviations []string
+
+	// Scoped expectations registered via On/OnX
+	expectations     []*Expectation
+	recordNearMisses bool
+
+	// Standing per-namespace responses, see SetDefaultFind
+	defaults []*Expectation
+
+	// strict fails the test on calls the expectations do not allow, see Strict
+	strict testing.TB
+
+	// owner is the test bound by NewMockDatabaseT
+	owner testing.TB
+
+	// closed is set once Close succeeds; with failAfterClose every later
+	// operation returns ErrClientClosed
+	closed         bool
+	failAfterClose bool
+
+	// Operations that fail immediately when called, see Forbid
+	forbidden map[string]bool
+
+	// Observers notified of every call, see OnCall and OnCallAsync
+	observers      []func(Call)
+	asyncObservers []func(Call)
+
+	// ignoreContext disables the context checks, see IgnoreContextCancellation
+	ignoreContext bool
+
+	// validateBSON marshals the arguments of every call, see ValidateBSON
+	validateBSON bool
+
+	// callsChanged wakes WaitForCalls when calls are recorded or reset
+	callsChanged *sync.Cond
+
+	// Simulated latency per operation, "*" applies to every operation
+	delays  map[string]time.Duration
+	jitters map[string]time.Duration
+
+	// Random failure injection, see EnableChaos
+	chaos *chaosState
+
+	// Unified call history across operations, see History
+	history []Call
+	seq     int64
+
+	// NearMisses lists expectations that matched a call's namespace but not its filter
+	NearMisses []NearMiss
 
 	// Call tracking
-	PingCalls    []PingCall
-	FindCalls    []FindCall
-	FindOneCalls []FindOneCall
+	PingCalls             []PingCall
+	CloseCalls            []CloseCall
+	FindCalls             []FindCall
+	FindOneCalls          []FindOneCall
+	FindCursorCalls       []FindCursorCall
+	AggregateCalls        []AggregateCall
+	CountCalls            []CountCall
+	DistinctCalls         []DistinctCall
+	InsertOneCalls        []InsertOneCall
+	InsertManyCalls       []InsertManyCall
+	UpdateOneCalls        []UpdateOneCall
+	UpdateManyCalls       []UpdateManyCall
+	ReplaceOneCalls       []ReplaceOneCall
+	DeleteOneCalls        []DeleteOneCall
+	DeleteManyCalls       []DeleteManyCall
+	FindOneAndUpdateCalls []FindOneAndUpdateCall
+	BulkWriteCalls        []BulkWriteCall
+	WatchCalls            []WatchCall
+	WithTransactionCalls  []WithTransactionCall
 }
 
+var _ DatabaseInterface = (*MockDatabase)(nil)
+
 // PingResponse represents a queued response for Ping
 type PingResponse struct {
-	Err error
+	Err   error
+	Delay time.Duration
 }
 
 // FindResponse represents a queued response for Find
 type FindResponse struct {
 	Result any
 	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueFindFunc
+	Responder func(call FindCall) (any, error)
+
+	// Shared returns Result itself rather than a deep copy, see QueueFindShared
+	Shared bool
+
+	// Typed holds the original fixture when queued via the typed helpers;
+	// Result then holds its BSON-decoded form
+	Typed any
 }
 
 // FindOneResponse represents a queued response for FindOne
 type FindOneResponse struct {
 	Result any
 	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueFindOneFunc
+	Responder func(call FindOneCall) (any, error)
+
+	// Shared returns Result itself rather than a deep copy, see QueueFindOneShared
+	Shared bool
+
+	// Typed holds the original fixture when queued via the typed helpers;
+	// Result then holds its BSON-decoded form
+	Typed any
+}
+
+// FindCursorResponse represents a queued response for FindCursor
+type FindCursorResponse struct {
+	Result Cursor
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueFindCursorFunc
+	Responder func(call FindCursorCall) (Cursor, error)
+}
+
+// AggregateResponse represents a queued response for Aggregate
+type AggregateResponse struct {
+	Result any
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueAggregateFunc
+	Responder func(call AggregateCall) (any, error)
+}
+
+// CountResponse represents a queued response for Count
+type CountResponse struct {
+	Result int64
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueCountFunc
+	Responder func(call CountCall) (int64, error)
 }
 
 // PingCall records a call to Ping
 type PingCall struct {
-	Ctx context.Context
+	Ctx   context.Context
+	Chaos bool
 }
 
 // FindCall records a call to Find
 type FindCall struct {
-	Ctx        context.Context
-	Db         string
-	Collection string
-	Filter     any
-	Opts       []any
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Options     QueryOptions
+	Chaos       bool
 }
 
 // FindOneCall records a call to FindOne
 type FindOneCall struct {
-	Ctx        context.Context
-	Db         string
-	Collection string
-	Filter     any
-	Opts       []any
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Options     QueryOptions
+	Chaos       bool
 }
 
-// NewMockDatabase creates a new MockDatabase with sensible defaults
-func NewMockDatabase() *MockDatabase {
-	return &MockDatabase{
-		PingFunc: func(ctx context.Context) error {
-			return nil
-		},
-		FindFunc: func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
-			return []any{}, nil
-		},
-		FindOneFunc: func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
-			return nil, fmt.Errorf("no document found")
-		},
-		PingCalls:    []PingCall{},
-		FindCalls:    []FindCall{},
-		FindOneCalls: []FindOneCall{},
-		PingQueue:    []PingResponse{},
-		FindQueue:    []FindResponse{},
-		FindOneQueue: []FindOneResponse{},
-	}
+// FindCursorCall records a call to FindCursor
+type FindCursorCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Options     QueryOptions
+	Chaos       bool
+
+	// Cursor is the MockCursor returned to the caller, if any
+	Cursor *MockCursor
 }
 
-// Ping implements DatabaseInterface
-func (m *MockDatabase) Ping(ctx context.Context) error {
-	m.PingCalls = append(m.PingCalls, PingCall{Ctx: ctx})
+// AggregateCall records a call to Aggregate
+type AggregateCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Pipeline    any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+
+	// Stages holds the pipeline split into its stages, empty when the
+	// pipeline is not an array of single-field stage documents
+	Stages []PipelineStage
+}
+
+// PipelineStage is one stage of a recorded aggregation pipeline, with Spec
+// normalized to map[string]any and []any
+type PipelineStage struct {
+	Name string
+	Spec any
+}
 
-	// Check if there's a queued response
-	if len(m.PingQueue) > 0 {
-		response := m.PingQueue[0]
-		m.PingQueue = m.PingQueue[1:]
-		return response.Err
+// Stage returns the spec of the first stage named name, e.g. "$match"
+func (c AggregateCall) Stage(name string) (any, bool) {
+	for _, stage := range c.Stages {
+		if stage.Name == name {
+			return stage.Spec, true
+		}
 	}
+	return nil, false
+}
 
-	// Fall back to PingFunc
-	if m.PingFunc != nil {
-		return m.PingFunc(ctx)
+// pipelineStagesOf splits a pipeline into its stages, or returns nil if it
+// is not a valid pipeline
+func pipelineStagesOf(pipeline any) []PipelineStage {
+	stages, err := pipelineStages(pipeline)
+	if err != nil {
+		return nil
+	}
+	out := make([]PipelineStage, 0, len(stages))
+	for _, stage := range stages {
+		name, spec, err := stageOf(stage)
+		if err != nil {
+			return nil
+		}
+		out = append(out, PipelineStage{Name: name, Spec: normalizeDocument(spec)})
 	}
-	return nil
+	return out
 }
 
-// Find implements DatabaseInterface
-func (m *MockDatabase) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
-	m.FindCalls = append(m.FindCalls, FindCall{
-		Ctx:        ctx,
-		Db:         db,
-		Collection: collection,
-		Filter:     filter,
-		Opts:       opts,
-	})
+// CountCall records a call to Count
+type CountCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Options     QueryOptions
+	Chaos       bool
+}
 
-	// Check if there's a queued response
-	if len(m.FindQueue) > 0 {
-		response := m.FindQueue[0]
-		m.FindQueue = m.FindQueue[1:]
-		return response.Result, response.Err
-	}
+// NewMockDatabase creates a new MockDatabase with sensible defaults
+func NewMockDatabase() *MockDatabase {
+	m := &MockDatabase{}
+	m.ResetAll()
+	return m
+}
 
-	// Fall back to FindFunc
-	if m.FindFunc != nil {
-		return m.FindFunc(ctx, db, collection, filter, opts...)
+// setDefaultFuncs installs the constructor default handlers for every operation
+func (m *MockDatabase) setDefaultFuncs() {
+	m.PingFunc = func(ctx context.Context) error {
+		return nil
+	}
+	m.CloseFunc = func(ctx context.Context) error {
+		return nil
 	}
-	return []any{}, nil
+	m.FindFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+		return []any{}, nil
+	}
+	m.FindOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+		return nil, mongo.ErrNoDocuments
+	}
+	m.FindCursorFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+		return NewMockCursor(nil), nil
+	}
+	m.AggregateFunc = func(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+		return []any{}, nil
+	}
+	m.CountFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+		return 0, nil
+	}
+	m.DistinctFunc = func(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+		return []any{}, nil
+	}
+	m.setDefaultWriteFuncs()
+}
+
+// Ping implements DatabaseInterface
+func (m *MockDatabase) Ping(ctx context.Context) error {
+	_, err := invoke(m, mockCall{ctx: ctx, operation: "Ping"},
+		func(chaos bool) {
+			m.PingCalls = append(m.PingCalls, PingCall{Ctx: ctx, Chaos: chaos})
+		},
+		func() (mockResponse[struct{}], bool) {
+			r, ok := popQueue(&m.PingQueue)
+			return mockResponse[struct{}]{err: r.Err, delay: r.Delay}, ok
+		},
+		func() (struct{}, error) {
+			if m.PingFunc != nil {
+				return struct{}{}, m.PingFunc(ctx)
+			}
+			return struct{}{}, nil
+		})
+	return err
+}
+
+// Find implements DatabaseInterface
+func (m *MockDatabase) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "Find", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			m.FindCalls = append(m.FindCalls, FindCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Opts:       opts,
+				Options:    parseQueryOptions(opts),
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[any], bool) {
+			r, ok := popQueue(&m.FindQueue)
+			return mockResponse[any]{result: r.Result, err: r.Err, delay: r.Delay, shared: r.Shared, respond: responder(r.Responder)}, ok
+		},
+		func() (any, error) {
+			if m.FindFunc != nil {
+				return m.FindFunc(ctx, db, collection, filter, opts...)
+			}
+			return []any{}, nil
+		})
 }
 
 // FindOne implements DatabaseInterface
 func (m *MockDatabase) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
-	m.FindOneCalls = append(m.FindOneCalls, FindOneCall{
-		Ctx:        ctx,
-		Db:         db,
-		Collection: collection,
-		Filter:     filter,
-		Opts:       opts,
-	})
+	return invoke(m, mockCall{ctx: ctx, operation: "FindOne", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			m.FindOneCalls = append(m.FindOneCalls, FindOneCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Opts:       opts,
+				Options:    parseQueryOptions(opts),
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[any], bool) {
+			r, ok := popQueue(&m.FindOneQueue)
+			return mockResponse[any]{result: r.Result, err: r.Err, delay: r.Delay, shared: r.Shared, respond: responder(r.Responder)}, ok
+		},
+		func() (any, error) {
+			if m.FindOneFunc != nil {
+				return m.FindOneFunc(ctx, db, collection, filter, opts...)
+			}
+			return nil, mongo.ErrNoDocuments
+		})
+}
 
-	// Check if there's a queued response
-	if len(m.FindOneQueue) > 0 {
-		response := m.FindOneQueue[0]
-		m.FindOneQueue = m.FindOneQueue[1:]
-		return response.Result, response.Err
-	}
+// FindCursor implements DatabaseInterface
+func (m *MockDatabase) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	index := -1
+	cursor, err := invoke(m, mockCall{ctx: ctx, operation: "FindCursor", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			index = len(m.FindCursorCalls)
+			m.FindCursorCalls = append(m.FindCursorCalls, FindCursorCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Opts:       opts,
+				Options:    parseQueryOptions(opts),
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[Cursor], bool) {
+			r, ok := popQueue(&m.FindCursorQueue)
+			return mockResponse[Cursor]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (Cursor, error) {
+			if m.FindCursorFunc != nil {
+				return m.FindCursorFunc(ctx, db, collection, filter, opts...)
+			}
+			return NewMockCursor(nil), nil
+		})
 
-	// Fall back to FindOneFunc
-	if m.FindOneFunc != nil {
-		return m.FindOneFunc(ctx, db, collection, filter, opts...)
+	// Keep the returned cursor with the call so tests can check it was closed
+	if mc, ok := cursor.(*MockCursor); ok {
+		m.mu.Lock()
+		if index >= 0 && index < len(m.FindCursorCalls) {
+			m.FindCursorCalls[index].Cursor = mc
+		}
+		m.mu.Unlock()
 	}
-	return nil, fmt.Errorf("no document found")
+	return cursor, err
 }
 
-// Reset clears all recorded calls
+// Aggregate implements DatabaseInterface. Scoped expectation filter matchers
+// receive the pipeline.
+func (m *MockDatabase) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "Aggregate", db: db, collection: collection, filter: pipeline, opts: opts},
+		func(chaos bool) {
+			m.AggregateCalls = append(m.AggregateCalls, AggregateCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Pipeline:   pipeline,
+				Stages:     pipelineStagesOf(pipeline),
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[any], bool) {
+			r, ok := popQueue(&m.AggregateQueue)
+			return mockResponse[any]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (any, error) {
+			if m.AggregateFunc != nil {
+				return m.AggregateFunc(ctx, db, collection, pipeline, opts...)
+			}
+			return []any{}, nil
+		})
+}
+
+// Count implements DatabaseInterface
+func (m *MockDatabase) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "Count", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			m.CountCalls = append(m.CountCalls, CountCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Opts:       opts,
+				Options:    parseQueryOptions(opts),
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[int64], bool) {
+			r, ok := popQueue(&m.CountQueue)
+			return mockResponse[int64]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (int64, error) {
+			if m.CountFunc != nil {
+				return m.CountFunc(ctx, db, collection, filter, opts...)
+			}
+			return 0, nil
+		})
+}
+
+// Reset clears recorded calls, queued responses and scoped expectations.
+// Handlers installed via ExpectX or XFunc are kept; use ResetAll to restore
+// the constructor defaults as well.
 func (m *MockDatabase) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resetCalls()
+	m.resetQueues()
+}
+
+// ResetCalls clears recorded calls and near misses only
+func (m *MockDatabase) ResetCalls() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resetCalls()
+}
+
+// ResetQueues clears queued responses and scoped expectations only
+func (m *MockDatabase) ResetQueues() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resetQueues()
+}
+
+// ResetAll returns the mock to the state NewMockDatabase creates: calls,
+// queues and expectations are cleared, ExpectX/XFunc overrides are replaced by
+// the default handlers, and delay, context, forbidden-operation, observer,
+// FailAfterClose and ValidateBSON settings are dropped. Use it between subtests sharing one mock so
+// behavior cannot leak across them.
+func (m *MockDatabase) ResetAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resetCalls()
+	m.resetQueues()
+	m.setDefaultFuncs()
+	m.recordNearMisses = false
+	m.ignoreContext = false
+	m.validateBSON = false
+	m.strict = nil
+	m.forbidden = nil
+	m.failAfterClose = false
+	m.observers = nil
+	m.asyncObservers = nil
+	m.defaults = nil
+	m.delays = nil
+	m.jitters = nil
+	m.chaos = nil
+	m.WatchFunc = nil
+	m.changeStreams = nil
+	m.WithTransactionFunc = nil
+}
+
+func (m *MockDatabase) resetCalls() {
 	m.PingCalls = []PingCall{}
+	m.CloseCalls = []CloseCall{}
 	m.FindCalls = []FindCall{}
 	m.FindOneCalls = []FindOneCall{}
+	m.FindCursorCalls = []FindCursorCall{}
+	m.AggregateCalls = []AggregateCall{}
+	m.CountCalls = []CountCall{}
+	m.DistinctCalls = []DistinctCall{}
+	m.WatchCalls = []WatchCall{}
+	m.WithTransactionCalls = []WithTransactionCall{}
+	m.resetWriteCalls()
+	m.history = nil
+	m.closed = false
+	m.NearMisses = nil
+	m.callsChangedCond().Broadcast()
+}
+
+func (m *MockDatabase) resetQueues() {
 	m.PingQueue = []PingResponse{}
+	m.CloseQueue = []CloseResponse{}
 	m.FindQueue = []FindResponse{}
 	m.FindOneQueue = []FindOneResponse{}
+	m.FindCursorQueue = []FindCursorResponse{}
+	m.AggregateQueue = []AggregateResponse{}
+	m.CountQueue = []CountResponse{}
+	m.DistinctQueue = []DistinctResponse{}
+	m.resetWriteQueues()
+	m.faults = nil
+	m.script = nil
+	m.scriptPos = 0
+	m.scriptDeviations = nil
+	m.expectations = nil
 }
 
 // ExpectPing sets up an expectation for Ping
 func (m *MockDatabase) ExpectPing(err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.PingFunc = func(ctx context.Context) error {
 		return err
 	}
@@ -174,6 +657,9 @@ func (m *MockDatabase) ExpectPing(err error) *MockDatabase {
 
 // ExpectFind sets up an expectation for Find
 func (m *MockDatabase) ExpectFind(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.FindFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
 		return result, err
 	}
@@ -182,26 +668,311 @@ func (m *MockDatabase) ExpectFind(result any, err error) *MockDatabase {
 
 // ExpectFindOne sets up an expectation for FindOne
 func (m *MockDatabase) ExpectFindOne(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.FindOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
 		return result, err
 	}
 	return m
 }
 
+// ExpectFindCursor sets up an expectation for FindCursor; every call gets a
+// fresh cursor over docs, or err when it is non-nil
+func (m *MockDatabase) ExpectFindCursor(docs []any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindCursorFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+		if err != nil {
+			return nil, err
+		}
+		return NewMockCursor(docs), nil
+	}
+	return m
+}
+
+// ExpectAggregate sets up an expectation for Aggregate
+func (m *MockDatabase) ExpectAggregate(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.AggregateFunc = func(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectCount sets up an expectation for Count
+func (m *MockDatabase) ExpectCount(result int64, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CountFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+		return result, err
+	}
+	return m
+}
+
 // QueuePing adds a Ping response to the queue for sequential calls
 func (m *MockDatabase) QueuePing(err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.PingQueue = append(m.PingQueue, PingResponse{Err: err})
 	return m
 }
 
 // QueueFind adds a Find response to the queue for sequential calls
 func (m *MockDatabase) QueueFind(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.FindQueue = append(m.FindQueue, FindResponse{Result: result, Err: err})
 	return m
 }
 
+// QueueFindShared adds a Find response that is returned as is rather than as
+// a deep copy, for tests that rely on the code under test mutating it
+func (m *MockDatabase) QueueFindShared(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindQueue = append(m.FindQueue, FindResponse{Result: result, Err: err, Shared: true})
+	return m
+}
+
+// QueueFindOneShared adds a FindOne response that is returned as is rather
+// than as a deep copy, see QueueFindShared
+func (m *MockDatabase) QueueFindOneShared(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneQueue = append(m.FindOneQueue, FindOneResponse{Result: result, Err: err, Shared: true})
+	return m
+}
+
 // QueueFindOne adds a FindOne response to the queue for sequential calls
 func (m *MockDatabase) QueueFindOne(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.FindOneQueue = append(m.FindOneQueue, FindOneResponse{Result: result, Err: err})
 	return m
 }
+
+// QueueAggregate adds an Aggregate response to the queue for sequential calls
+func (m *MockDatabase) QueueAggregate(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.AggregateQueue = append(m.AggregateQueue, AggregateResponse{Result: result, Err: err})
+	return m
+}
+
+// Strict makes calls to exhausted Once/Times expectations, and calls that
+// deviate from the Script, fail t and return an error instead of falling
+// through to the next expectation or handler
+func (m *MockDatabase) Strict(t testing.TB) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.strict = t
+	return m
+}
+
+// QueueCount adds a Count response to the queue for sequential calls
+func (m *MockDatabase) QueueCount(result int64, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CountQueue = append(m.CountQueue, CountResponse{Result: result, Err: err})
+	return m
+}
+
+// IgnoreContextCancellation disables the mock's context handling. By default
+// every operation returns ctx.Err() without consuming queued responses when
+// called with a done context, and simulated delays are cut short when the
+// context ends, matching the real client. Enable this for tests that
+// deliberately pass cancelled contexts.
+func (m *MockDatabase) IgnoreContextCancellation(ignore bool) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ignoreContext = ignore
+	return m
+}
+
+// QueuePingDelayed adds a Ping response that is returned after delay
+func (m *MockDatabase) QueuePingDelayed(err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.PingQueue = append(m.PingQueue, PingResponse{Err: err, Delay: delay})
+	return m
+}
+
+// QueueFindDelayed adds a Find response that is returned after delay
+func (m *MockDatabase) QueueFindDelayed(result any, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindQueue = append(m.FindQueue, FindResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueFindOneDelayed adds a FindOne response that is returned after delay
+func (m *MockDatabase) QueueFindOneDelayed(result any, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneQueue = append(m.FindOneQueue, FindOneResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// mockCall describes a single invocation flowing through the mock pipeline
+type mockCall struct {
+	ctx        context.Context
+	operation  string
+	db         string
+	collection string
+	filter     any
+	update     any
+	opts       []any
+}
+
+// mockResponse is a scripted response selected for a call
+type mockResponse[R any] struct {
+	result R
+	err    error
+	delay  time.Duration
+
+	// shared returns result itself instead of a deep copy
+	shared bool
+
+	// respond computes the result from the recorded XCall, see QueueFindFunc
+	respond func(call any) (R, error)
+}
+
+// invoke runs the pipeline shared by every mock operation: fail fast on a
+// forbidden operation, a closed client, a done context, an invalid ByID filter
+// or, with ValidateBSON, an argument the driver could not marshal, answer
+// from the queue, the script, a scoped expectation or a namespace default,
+// then from chaos mode, otherwise fall back to the XFunc handler, applying
+// any simulated latency before returning. Results held by the mock are
+// returned as deep copies, see cloneResult, unless queued as shared;
+// responders run last, outside the lock. Every call is recorded, noting which
+// source answered it, and then passed to the OnCall observers.
+func invoke[R any](m *MockDatabase, call mockCall, record func(chaos bool), queued func() (mockResponse[R], bool), fallback func() (R, error)) (R, error) {
+	m.mu.Lock()
+	if m.forbidden[call.operation] {
+		return reject[R](m, call, record, SourceForbidden, m.forbiddenCall(call))
+	}
+	if m.failAfterClose && m.closed && call.operation != "Close" {
+		return reject[R](m, call, record, SourceClosed, ErrClientClosed)
+	}
+	if !m.ignoreContext && call.ctx != nil {
+		if err := call.ctx.Err(); err != nil {
+			return reject[R](m, call, record, SourceContext, err)
+		}
+	}
+	if err := filterError(call.filter); err != nil {
+		return reject[R](m, call, record, SourceInvalid, err)
+	}
+	if _, err := callOptions(call.ctx); err != nil {
+		return reject[R](m, call, record, SourceInvalid, err)
+	}
+	if m.validateBSON {
+		if err := m.invalidBSONCall(call); err != nil {
+			return reject[R](m, call, record, SourceInvalid, err)
+		}
+	}
+	source := SourceHandler
+	var response mockResponse[R]
+	answered := false
+	if err, ok := m.popFault(call.operation, call.collection); ok {
+		response.err = err
+		answered, source = true, SourceQueue
+	} else if response, answered = queued(); answered {
+		source = SourceQueue
+	} else if response, answered = scriptAnswer[R](m, call); answered {
+		source = SourceScript
+	} else {
+		e, err := m.matchExpectation(call.operation, call.db, call.collection, call.filter, call.opts)
+		switch {
+		case err != nil:
+			response.err = err
+			answered, source = true, SourceExpectation
+		case e != nil:
+			response.result, response.err = expectationResult[R](e)
+			response.respond = expectationResponder[R](e)
+			answered, source = true, SourceExpectation
+		}
+	}
+	if !answered {
+		if d, ok := m.namespaceDefault(call.operation, call.db, call.collection); ok {
+			response.result, response.err = expectationResult[R](d)
+			answered, source = true, SourceDefault
+		}
+	}
+	if !answered {
+		if err, ok := m.chaosError(call.operation); ok {
+			response.err = err
+			answered, source = true, SourceChaos
+		}
+	}
+	record(source == SourceChaos)
+	m.recordHistory(call.operation, source)
+	var recorded any
+	if response.respond != nil {
+		recorded = m.lastRecordedCall(call.operation)
+	}
+	observers := m.pendingObservers()
+	delay := m.delayFor(call.operation, response.delay)
+	waitCtx := call.ctx
+	if m.ignoreContext {
+		waitCtx = context.Background()
+	}
+	m.mu.Unlock()
+
+	observers.notify()
+
+	if err := sleepContext(waitCtx, delay); err != nil {
+		var zero R
+		return zero, err
+	}
+	if response.respond != nil {
+		return runResponder(m, call.operation, recorded, response.respond)
+	}
+	if answered {
+		if !response.shared {
+			response.result = cloneResult(response.result)
+		}
+		return response.result, response.err
+	}
+	return fallback()
+}
+
+// reject records a call that fails before any response is selected, notifies
+// the observers and returns err. It must be called with the lock held, which
+// it releases.
+func reject[R any](m *MockDatabase, call mockCall, record func(chaos bool), source string, err error) (R, error) {
+	record(false)
+	m.recordHistory(call.operation, source)
+	observers := m.pendingObservers()
+	m.mu.Unlock()
+	observers.notify()
+	var zero R
+	return zero, err
+}
+
+// popQueue removes and returns the first queued response, if any
+func popQueue[R any](queue *[]R) (R, bool) {
+	var zero R
+	if len(*queue) == 0 {
+		return zero, false
+	}
+	response := (*queue)[0]
+	*queue = (*queue)[1:]
+	return response, true
+}
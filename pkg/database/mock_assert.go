@@ -0,0 +1,156 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeOperations lists the DatabaseInterface methods that modify data
+var writeOperations = []string{
+	"InsertOne",
+	"InsertMany",
+	"UpdateOne",
+	"UpdateMany",
+	"ReplaceOne",
+	"DeleteOne",
+	"DeleteMany",
+	"FindOneAndUpdate",
+	"BulkWrite",
+}
+
+// callSummary is the operation-agnostic view of a recorded XCall
+type callSummary struct {
+	Operation  string
+	Db         string
+	Collection string
+	Filter     any
+	Chaos      bool
+}
+
+func (c callSummary) String() string {
+	s := fmt.Sprintf("%s %s filter=%s", c.Operation, namespaceString(c.Db, c.Collection), FilterShape(c.Filter))
+	if c.Chaos {
+		s += " (chaos)"
+	}
+	return s
+}
+
+// recordedCalls returns the calls recorded in the XCalls slice for op
+func (m *MockDatabase) recordedCalls(op string) []callSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := reflect.ValueOf(m).Elem().FieldByName(op + "Calls")
+	if !calls.IsValid() {
+		return nil
+	}
+	out := make([]callSummary, 0, calls.Len())
+	for i := 0; i < calls.Len(); i++ {
+		call := calls.Index(i)
+		summary := callSummary{Operation: op}
+		if f := call.FieldByName("Db"); f.IsValid() {
+			summary.Db = f.String()
+		}
+		if f := call.FieldByName("Collection"); f.IsValid() {
+			summary.Collection = f.String()
+		}
+		if f := call.FieldByName("Chaos"); f.IsValid() {
+			summary.Chaos = f.Bool()
+		}
+		summary.Filter = callArgument(call)
+		out = append(out, summary)
+	}
+	return out
+}
+
+// callArgument returns the argument filter matchers apply to for a recorded call
+func callArgument(call reflect.Value) any {
+	for _, name := range []string{"Filter", "Pipeline", "Document", "Documents", "Models"} {
+		if f := call.FieldByName(name); f.IsValid() {
+			return f.Interface()
+		}
+	}
+	return nil
+}
+
+// CallsTo returns how many times the named operation was called
+func (m *MockDatabase) CallsTo(op string) int {
+	return len(m.recordedCalls(op))
+}
+
+// AssertCalled fails the test unless op was called exactly times times
+func (m *MockDatabase) AssertCalled(t testing.TB, op string, times int) bool {
+	t.Helper()
+	calls := m.recordedCalls(op)
+	if len(calls) != times {
+		t.Errorf("expected %s to be called %d time(s), got %d%s", op, times, len(calls), formatCalls(calls))
+		return false
+	}
+	return true
+}
+
+// AssertCalledWith fails the test unless op was called at least once on
+// db.collection with a filter satisfying filterMatcher (nil matches any filter)
+func (m *MockDatabase) AssertCalledWith(t testing.TB, op string, db string, collection string, filterMatcher func(any) bool) bool {
+	t.Helper()
+	calls := m.recordedCalls(op)
+	for _, call := range calls {
+		if call.Db == db && call.Collection == collection && (filterMatcher == nil || filterMatcher(call.Filter)) {
+			return true
+		}
+	}
+	t.Errorf("expected %s to be called on %s with a matching filter%s", op, namespaceString(db, collection), formatCalls(calls))
+	return false
+}
+
+// AssertFindCalled fails the test unless Find was called exactly times times
+func (m *MockDatabase) AssertFindCalled(t testing.TB, times int) bool {
+	t.Helper()
+	return m.AssertCalled(t, "Find", times)
+}
+
+// AssertFindCalledWith fails the test unless Find was called on db.collection with a matching filter
+func (m *MockDatabase) AssertFindCalledWith(t testing.TB, db string, collection string, filterMatcher func(any) bool) bool {
+	t.Helper()
+	return m.AssertCalledWith(t, "Find", db, collection, filterMatcher)
+}
+
+// AssertFindOneCalled fails the test unless FindOne was called exactly times times
+func (m *MockDatabase) AssertFindOneCalled(t testing.TB, times int) bool {
+	t.Helper()
+	return m.AssertCalled(t, "FindOne", times)
+}
+
+// AssertFindOneCalledWith fails the test unless FindOne was called on db.collection with a matching filter
+func (m *MockDatabase) AssertFindOneCalledWith(t testing.TB, db string, collection string, filterMatcher func(any) bool) bool {
+	t.Helper()
+	return m.AssertCalledWith(t, "FindOne", db, collection, filterMatcher)
+}
+
+// AssertNoWrites fails the test if any write operation was called
+func (m *MockDatabase) AssertNoWrites(t testing.TB) bool {
+	t.Helper()
+	var writes []callSummary
+	for _, op := range writeOperations {
+		writes = append(writes, m.recordedCalls(op)...)
+	}
+	if len(writes) > 0 {
+		t.Errorf("expected no write operations, got %d%s", len(writes), formatCalls(writes))
+		return false
+	}
+	return true
+}
+
+// formatCalls renders recorded calls with redacted filters for failure messages
+func formatCalls(calls []callSummary) string {
+	if len(calls) == 0 {
+		return " (no calls recorded)"
+	}
+	lines := make([]string, len(calls))
+	for i, call := range calls {
+		lines[i] = fmt.Sprintf("  #%d %s", i, call)
+	}
+	return ":\n" + strings.Join(lines, "\n")
+}
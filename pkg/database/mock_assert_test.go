@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseAssertions(t *testing.T) {
+	t.Run("CallsTo", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Find(context.Background(), "testdb", "users", bson.M{})
+		mock.Find(context.Background(), "testdb", "users", bson.M{})
+		mock.InsertOne(context.Background(), "testdb", "users", bson.M{})
+
+		if mock.CallsTo("Find") != 2 || mock.CallsTo("InsertOne") != 1 || mock.CallsTo("DeleteOne") != 0 {
+			t.Errorf("unexpected call counts: Find=%d InsertOne=%d", mock.CallsTo("Find"), mock.CallsTo("InsertOne"))
+		}
+		if mock.CallsTo("Unknown") != 0 {
+			t.Error("expected unknown operation to report 0 calls")
+		}
+	})
+
+	t.Run("AssertFindCalledPasses", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Find(context.Background(), "testdb", "users", bson.M{"status": "active"})
+
+		mock.AssertFindCalled(t, 1)
+		mock.AssertFindCalledWith(t, "testdb", "users", FilterContains(map[string]any{"status": "active"}))
+		mock.AssertNoWrites(t)
+	})
+
+	t.Run("AssertFindCalledFailureMessage", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase()
+		mock.Find(context.Background(), "testdb", "users", bson.M{"email": "secret@example.com"})
+
+		if mock.AssertFindCalled(tb, 2) {
+			t.Fatal("expected assertion to fail")
+		}
+		msg := tb.errors[0]
+		if !strings.Contains(msg, "got 1") || !strings.Contains(msg, "testdb.users filter={email: string}") {
+			t.Errorf("unexpected failure message: %s", msg)
+		}
+		if strings.Contains(msg, "secret@example.com") {
+			t.Errorf("expected filter values to be redacted: %s", msg)
+		}
+	})
+
+	t.Run("AssertCalledWithWrongNamespace", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase()
+		mock.FindOne(context.Background(), "testdb", "users", bson.M{"id": 1})
+
+		if mock.AssertFindOneCalledWith(tb, "testdb", "cameras", nil) {
+			t.Fatal("expected assertion to fail")
+		}
+		if !strings.Contains(tb.errors[0], "testdb.cameras") || !strings.Contains(tb.errors[0], "#0 FindOne testdb.users") {
+			t.Errorf("unexpected failure message: %s", tb.errors[0])
+		}
+	})
+
+	t.Run("AssertNoWritesFails", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase()
+		mock.DeleteMany(context.Background(), "testdb", "sessions", bson.M{"expired": true})
+
+		if mock.AssertNoWrites(tb) {
+			t.Fatal("expected assertion to fail")
+		}
+		if !strings.Contains(tb.errors[0], "DeleteMany testdb.sessions filter={expired: bool}") {
+			t.Errorf("unexpected failure message: %s", tb.errors[0])
+		}
+	})
+}
+
+func TestFilterShape(t *testing.T) {
+	shape := FilterShape(bson.M{
+		"status": "active",
+		"age":    bson.M{"$gte": 18},
+		"tags":   bson.A{"a", "b"},
+	})
+	expected := "{age: {$gte: number}, status: string, tags: [2]string}"
+	if shape != expected {
+		t.Errorf("expected %q, got %q", expected, shape)
+	}
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ Watcher = (*MockDatabase)(nil)
+
+// WatchCall records a call to Watch
+type WatchCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Pipeline    any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+
+	// ResumeAfter is the ResumeAfter or StartAfter token of the call, if any
+	ResumeAfter bson.Raw
+}
+
+// Watch implements Watcher. Unless WatchFunc is set, the stream reads the
+// events sent to ChangeStream(db, collection) after it was opened, or after
+// its ResumeAfter token, that match the pipeline's $match stages.
+func (m *MockDatabase) Watch(ctx context.Context, db string, collection string, pipeline any, opts ...any) (ChangeStream, error) {
+	token := changeStreamResumeToken(opts)
+	return invoke(m, mockCall{ctx: ctx, operation: "Watch", db: db, collection: collection, filter: pipeline, opts: opts},
+		func(chaos bool) {
+			m.WatchCalls = append(m.WatchCalls, WatchCall{
+				Ctx:         ctx,
+				Db:          db,
+				Collection:  collection,
+				Pipeline:    pipeline,
+				Opts:        opts,
+				Chaos:       chaos,
+				ResumeAfter: token,
+			})
+		},
+		func() (mockResponse[ChangeStream], bool) {
+			return mockResponse[ChangeStream]{}, false
+		},
+		func() (ChangeStream, error) {
+			if m.WatchFunc != nil {
+				return m.WatchFunc(ctx, db, collection, pipeline, opts...)
+			}
+			return m.ChangeStream(db, collection).open(pipeline, token)
+		})
+}
+
+// ChangeStream returns the change events of db.collection that the mock's
+// Watch streams, creating the feed on first use. Tests send events to it to
+// drive change stream consumers:
+//
+//	mock.ChangeStream("vault", "events").Send(database.ChangeEvent{OperationType: "insert", ...})
+func (m *MockDatabase) ChangeStream(db string, collection string) *MockChangeStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := db + "." + collection
+	if m.changeStreams == nil {
+		m.changeStreams = map[string]*MockChangeStream{}
+	}
+	feed, ok := m.changeStreams[key]
+	if !ok {
+		feed = &MockChangeStream{}
+		m.changeStreams[key] = feed
+	}
+	return feed
+}
+
+// MockChangeStream is the feed of change events of one namespace of the
+// mock. Every stream Watch opened on the namespace reads the events sent to
+// it; the events are kept, so a stream resuming after a token replays the
+// ones that followed it, as the server does from its oplog.
+type MockChangeStream struct {
+	mu      sync.Mutex
+	events  []mockChange
+	streams []*mockChangeCursor
+	opened  int
+}
+
+// mockChange is a sent event and its resume token
+type mockChange struct {
+	token bson.Raw
+	event any
+	doc   map[string]any
+}
+
+// Send appends events, ChangeEvent values or documents, to the feed. Events
+// without an _id get a generated resume token.
+func (s *MockChangeStream) Send(events ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		token, _ := bson.Marshal(bson.M{"_data": fmt.Sprintf("%016d", len(s.events)+1)})
+		switch e := event.(type) {
+		case ChangeEvent:
+			if e.ID == nil {
+				e.ID = token
+			}
+			token, event = e.ID, e
+		case bson.M:
+			if id, err := bson.Marshal(e["_id"]); e["_id"] != nil && err == nil {
+				token = id
+			} else {
+				e = copyDocument(e)
+				e["_id"] = token
+			}
+			event = e
+		}
+		doc, _ := normalizeDocument(event).(map[string]any)
+		s.events = append(s.events, mockChange{token: token, event: event, doc: doc})
+	}
+	for _, c := range s.streams {
+		c.notify()
+	}
+}
+
+// Fail ends every open stream of the feed with err, as a dropped connection
+// or a failover does; streams opened afterwards read the feed again
+func (s *MockChangeStream) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.streams {
+		c.err = err
+		c.notify()
+	}
+	s.streams = nil
+}
+
+// Opened returns the number of streams opened on the feed so far, so tests
+// can wait for a consumer to watch or to resume
+func (s *MockChangeStream) Opened() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.opened
+}
+
+// open starts a stream at the end of the feed, or after token, reading the
+// events pipeline's $match stages select
+func (s *MockChangeStream) open(pipeline any, token bson.Raw) (ChangeStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matches []any
+	for _, stage := range pipelineStagesOf(pipeline) {
+		if stage.Name == "$match" {
+			matches = append(matches, stage.Spec)
+		}
+	}
+	c := &mockChangeCursor{feed: s, pos: len(s.events), matches: matches, token: token, wake: make(chan struct{}, 1)}
+	if token != nil {
+		c.pos = -1
+		for i, e := range s.events {
+			if bytes.Equal(e.token, token) {
+				c.pos = i + 1
+			}
+		}
+		if c.pos < 0 {
+			return nil, mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost", Message: "mock: resume token not found in the change stream"}
+		}
+	}
+	s.streams = append(s.streams, c)
+	s.opened++
+	return c, nil
+}
+
+// mockChangeCursor is a stream over a MockChangeStream; its fields are
+// guarded by the feed's lock
+type mockChangeCursor struct {
+	feed    *MockChangeStream
+	pos     int
+	matches []any
+	current any
+	token   bson.Raw
+	err     error
+	closed  bool
+	wake    chan struct{}
+}
+
+func (c *mockChangeCursor) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Next waits for the next matching event, until the stream fails, is
+// closed or ctx ends
+func (c *mockChangeCursor) Next(ctx context.Context) bool {
+	for {
+		c.feed.mu.Lock()
+		c.current = nil
+		if c.closed || c.err != nil {
+			c.feed.mu.Unlock()
+			return false
+		}
+		for c.pos < len(c.feed.events) {
+			e := c.feed.events[c.pos]
+			c.pos++
+			if c.selects(e.doc) {
+				c.current, c.token = e.event, e.token
+				c.feed.mu.Unlock()
+				return true
+			}
+		}
+		c.feed.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			c.feed.mu.Lock()
+			c.err = ctx.Err()
+			c.feed.mu.Unlock()
+			return false
+		case <-c.wake:
+		}
+	}
+}
+
+func (c *mockChangeCursor) selects(doc map[string]any) bool {
+	for _, match := range c.matches {
+		if ok, err := matchesFilter(doc, match); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode decodes the current event into val
+func (c *mockChangeCursor) Decode(val any) error {
+	c.feed.mu.Lock()
+	defer c.feed.mu.Unlock()
+
+	return decodeDocument(c.current, val)
+}
+
+// ResumeToken returns the token of the current event, or the one the stream
+// resumed after
+func (c *mockChangeCursor) ResumeToken() bson.Raw {
+	c.feed.mu.Lock()
+	defer c.feed.mu.Unlock()
+
+	return c.token
+}
+
+func (c *mockChangeCursor) Err() error {
+	c.feed.mu.Lock()
+	defer c.feed.mu.Unlock()
+
+	return c.err
+}
+
+func (c *mockChangeCursor) Close(ctx context.Context) error {
+	c.feed.mu.Lock()
+	defer c.feed.mu.Unlock()
+
+	c.closed = true
+	for i, s := range c.feed.streams {
+		if s == c {
+			c.feed.streams = append(c.feed.streams[:i], c.feed.streams[i+1:]...)
+			break
+		}
+	}
+	c.notify()
+	return nil
+}
+
+// changeStreamResumeToken returns the ResumeAfter or StartAfter token of
+// the ChangeStreamOptions in opts
+func changeStreamResumeToken(opts []any) bson.Raw {
+	var token any
+	for _, o := range optionsOf[moptions.ChangeStreamOptions](opts) {
+		if o.ResumeAfter != nil {
+			token = o.ResumeAfter
+		}
+		if o.StartAfter != nil {
+			token = o.StartAfter
+		}
+	}
+	switch t := token.(type) {
+	case nil:
+		return nil
+	case bson.Raw:
+		return t
+	}
+	raw, err := bson.Marshal(token)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"math/rand/v2"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ChaosConfig configures random failure injection on the mock
+type ChaosConfig struct {
+	// Seed makes the sequence of injected failures reproducible
+	Seed uint64
+
+	// FailureRate is the probability, between 0 and 1, that an operation fails
+	FailureRate float64
+
+	// Errors are drawn from uniformly; when empty a timeout, a transient
+	// network error and a duplicate key error are used
+	Errors []error
+
+	// PerOp overrides FailureRate for individual operations, e.g. {"Find": 0}
+	PerOp map[string]float64
+}
+
+type chaosState struct {
+	config ChaosConfig
+	rng    *rand.Rand
+}
+
+// defaultChaosErrors are recognised by the driver's mongo.IsTimeout,
+// mongo.IsNetworkError and mongo.IsDuplicateKeyError helpers
+var defaultChaosErrors = []error{
+	context.DeadlineExceeded,
+	mongo.CommandError{
+		Name:    "HostUnreachable",
+		Message: "chaos: connection reset by peer",
+		Labels:  []string{"NetworkError", "RetryableWriteError"},
+	},
+	mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "chaos: E11000 duplicate key error"}},
+	},
+}
+
+// EnableChaos makes the mock fail a random share of operations with errors
+// from config. Queued responses and scoped expectations still take priority;
+// chaos only replaces the XFunc handlers. Calls answered by chaos mode have
+// Chaos set in their recorded XCall.
+func (m *MockDatabase) EnableChaos(config ChaosConfig) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(config.Errors) == 0 {
+		config.Errors = defaultChaosErrors
+	}
+	m.chaos = &chaosState{
+		config: config,
+		rng:    rand.New(rand.NewPCG(config.Seed, config.Seed)),
+	}
+	return m
+}
+
+// DisableChaos turns chaos mode off
+func (m *MockDatabase) DisableChaos() *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.chaos = nil
+	return m
+}
+
+// chaosError decides whether chaos mode fails the call; the caller holds m.mu
+func (m *MockDatabase) chaosError(op string) (error, bool) {
+	if m.chaos == nil {
+		return nil, false
+	}
+	rate := m.chaos.config.FailureRate
+	if r, ok := m.chaos.config.PerOp[op]; ok {
+		rate = r
+	}
+	if rate <= 0 || m.chaos.rng.Float64() >= rate {
+		return nil, false
+	}
+	errs := m.chaos.config.Errors
+	return errs[m.chaos.rng.IntN(len(errs))], true
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMockDatabaseChaos(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	t.Run("SameSeedSameFailures", func(t *testing.T) {
+		run := func() []bool {
+			mock := NewMockDatabase().EnableChaos(ChaosConfig{Seed: 42, FailureRate: 0.5, Errors: []error{errBoom}})
+			for i := 0; i < 50; i++ {
+				_, err := mock.Find(ctx, "testdb", "users", nil)
+				if err != nil && !errors.Is(err, errBoom) {
+					t.Fatalf("expected configured error, got %v", err)
+				}
+			}
+			pattern := make([]bool, len(mock.FindCalls))
+			for i, call := range mock.FindCalls {
+				pattern[i] = call.Chaos
+			}
+			return pattern
+		}
+
+		first, second := run(), run()
+		failures := 0
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("expected identical failure pattern for the same seed, differs at call %d", i)
+			}
+			if first[i] {
+				failures++
+			}
+		}
+		if failures == 0 || failures == len(first) {
+			t.Errorf("expected roughly half of the calls to fail, got %d of %d", failures, len(first))
+		}
+	})
+
+	t.Run("ScriptedResponsesTakePriority", func(t *testing.T) {
+		mock := NewMockDatabase().EnableChaos(ChaosConfig{Seed: 1, FailureRate: 1})
+		mock.QueueFindOne("queued", nil)
+		mock.OnDeleteOne("testdb", "users").Return(1, nil)
+
+		if result, err := mock.FindOne(ctx, "testdb", "users", nil); err != nil || result != "queued" {
+			t.Errorf("expected queued response, got %v, %v", result, err)
+		}
+		if deleted, err := mock.DeleteOne(ctx, "testdb", "users", nil); err != nil || deleted != 1 {
+			t.Errorf("expected expectation response, got %v, %v", deleted, err)
+		}
+		if _, err := mock.FindOne(ctx, "testdb", "users", nil); err == nil {
+			t.Error("expected chaos failure once scripted responses are used up")
+		}
+		if mock.FindOneCalls[0].Chaos || !mock.FindOneCalls[1].Chaos || mock.DeleteOneCalls[0].Chaos {
+			t.Error("expected only the unscripted call to be marked as chaos")
+		}
+	})
+
+	t.Run("PerOpOverridesRate", func(t *testing.T) {
+		mock := NewMockDatabase().EnableChaos(ChaosConfig{
+			Seed:        7,
+			FailureRate: 1,
+			PerOp:       map[string]float64{"Ping": 0},
+		})
+
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected Ping to be exempt, got %v", err)
+		}
+		if _, err := mock.InsertOne(ctx, "testdb", "users", nil); err == nil {
+			t.Error("expected InsertOne to fail")
+		}
+	})
+
+	t.Run("DefaultErrorsAreDriverShaped", func(t *testing.T) {
+		mock := NewMockDatabase().EnableChaos(ChaosConfig{Seed: 3, FailureRate: 1})
+
+		seen := map[string]bool{}
+		for i := 0; i < 100; i++ {
+			_, err := mock.InsertOne(ctx, "testdb", "users", nil)
+			switch {
+			case mongo.IsTimeout(err):
+				seen["timeout"] = true
+			case mongo.IsNetworkError(err):
+				seen["network"] = true
+			case mongo.IsDuplicateKeyError(err):
+				seen["duplicate"] = true
+			default:
+				t.Fatalf("unexpected chaos error %v", err)
+			}
+		}
+		if len(seen) != 3 {
+			t.Errorf("expected all default error kinds, got %v", seen)
+		}
+	})
+
+	t.Run("DisableAndResetAll", func(t *testing.T) {
+		mock := NewMockDatabase().EnableChaos(ChaosConfig{FailureRate: 1})
+		mock.DisableChaos()
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected chaos to be disabled, got %v", err)
+		}
+
+		mock.EnableChaos(ChaosConfig{FailureRate: 1})
+		mock.ResetAll()
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected ResetAll to drop chaos, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// CloseResponse represents a queued response for Close
+type CloseResponse struct {
+	Err   error
+	Delay time.Duration
+}
+
+// CloseCall records a call to Close
+type CloseCall struct {
+	Ctx   context.Context
+	Chaos bool
+}
+
+// Close implements DatabaseInterface. Every call is recorded, so a double
+// close shows up twice in CloseCalls; like the real client it is not an error.
+func (m *MockDatabase) Close(ctx context.Context) error {
+	_, err := invoke(m, mockCall{ctx: ctx, operation: "Close"},
+		func(chaos bool) {
+			m.CloseCalls = append(m.CloseCalls, CloseCall{Ctx: ctx, Chaos: chaos})
+		},
+		func() (mockResponse[struct{}], bool) {
+			r, ok := popQueue(&m.CloseQueue)
+			return mockResponse[struct{}]{err: r.Err, delay: r.Delay}, ok
+		},
+		func() (struct{}, error) {
+			if m.CloseFunc != nil {
+				return struct{}{}, m.CloseFunc(ctx)
+			}
+			return struct{}{}, nil
+		})
+	if err == nil {
+		m.mu.Lock()
+		m.closed = true
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// Closed reports whether Close has returned successfully since the mock was
+// created or its calls were last reset
+func (m *MockDatabase) Closed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.closed
+}
+
+// FailAfterClose makes every operation other than Close return ErrClientClosed
+// once the mock is closed, as the real client does, so use-after-close bugs
+// fail in tests. Such calls are still recorded, with source "closed".
+func (m *MockDatabase) FailAfterClose(enabled bool) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failAfterClose = enabled
+	return m
+}
+
+// ExpectClose sets up an expectation for Close
+func (m *MockDatabase) ExpectClose(err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CloseFunc = func(ctx context.Context) error {
+		return err
+	}
+	return m
+}
+
+// QueueClose adds a Close response to the queue for sequential calls
+func (m *MockDatabase) QueueClose(err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CloseQueue = append(m.CloseQueue, CloseResponse{Err: err})
+	return m
+}
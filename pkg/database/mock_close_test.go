@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseClose(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("TracksClosedState", func(t *testing.T) {
+		mock := NewMockDatabase()
+		if mock.Closed() {
+			t.Fatal("expected a new mock to be open")
+		}
+		if err := mock.Close(ctx); err != nil {
+			t.Fatalf("expected nil Close error, got %v", err)
+		}
+		if !mock.Closed() || len(mock.CloseCalls) != 1 {
+			t.Errorf("expected closed mock with one CloseCall, got %v %d", mock.Closed(), len(mock.CloseCalls))
+		}
+
+		// Without FailAfterClose operations keep working
+		if _, err := mock.Find(ctx, "testdb", "users", bson.M{}); err != nil {
+			t.Errorf("expected Find to work after Close, got %v", err)
+		}
+	})
+
+	t.Run("DoubleCloseIsRecordedButNotAnError", func(t *testing.T) {
+		mock := NewMockDatabase().FailAfterClose(true)
+		mock.Close(ctx)
+		if err := mock.Close(ctx); err != nil {
+			t.Errorf("expected second Close to succeed, got %v", err)
+		}
+		if len(mock.CloseCalls) != 2 {
+			t.Errorf("expected two CloseCalls, got %d", len(mock.CloseCalls))
+		}
+	})
+
+	t.Run("FailAfterClose", func(t *testing.T) {
+		mock := NewMockDatabase().FailAfterClose(true)
+		mock.QueueFindOne("alice", nil)
+		mock.Close(ctx)
+
+		_, err := mock.FindOne(ctx, "testdb", "users", bson.M{})
+		if !errors.Is(err, ErrClientClosed) {
+			t.Fatalf("expected ErrClientClosed, got %v", err)
+		}
+		if err := mock.Ping(ctx); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("expected ErrClientClosed from Ping, got %v", err)
+		}
+		if len(mock.FindOneQueue) != 1 {
+			t.Error("expected a call after Close not to consume the queue")
+		}
+		history := mock.History()
+		if last := history[len(history)-1]; last.Source != SourceClosed {
+			t.Errorf("expected the call to be recorded as closed, got %s", last.Source)
+		}
+	})
+
+	t.Run("FailedCloseLeavesClientOpen", func(t *testing.T) {
+		mock := NewMockDatabase().FailAfterClose(true)
+		mock.QueueClose(errors.New("disconnect timed out"))
+
+		if err := mock.Close(ctx); err == nil {
+			t.Fatal("expected queued Close error")
+		}
+		if mock.Closed() {
+			t.Error("expected a failed Close to leave the mock open")
+		}
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected Ping to work, got %v", err)
+		}
+	})
+
+	t.Run("ShutdownOrder", func(t *testing.T) {
+		mock := NewMockDatabase().FailAfterClose(true)
+		mock.InsertOne(ctx, "testdb", "events", bson.M{"drained": true})
+		mock.Close(ctx)
+
+		history := mock.History()
+		if len(history) != 2 || history[0].Operation != "InsertOne" || history[1].Operation != "Close" {
+			t.Errorf("expected the drain write before Close, got %+v", history)
+		}
+	})
+
+	t.Run("ResetReopens", func(t *testing.T) {
+		mock := NewMockDatabase().FailAfterClose(true)
+		mock.Close(ctx)
+		mock.Reset()
+		if mock.Closed() {
+			t.Error("expected Reset to clear the closed state")
+		}
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected Ping after Reset to work, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package database
+
+import "reflect"
+
+// cloneResult deep-copies a result held by the mock so the code under test
+// cannot mutate a fixture shared with later calls or other tests. Maps,
+// slices, arrays, pointers and the exported fields of structs are copied
+// recursively; everything else, including ObjectIDs, time.Time and the
+// unexported fields of structs, is copied by value and so kept exactly.
+// Cursors are returned as is.
+func cloneResult[R any](result R) R {
+	if _, ok := any(result).(Cursor); ok {
+		return result
+	}
+	v := reflect.ValueOf(&result).Elem()
+	out := reflect.New(v.Type()).Elem()
+	out.Set(cloneValue(v))
+	clone, _ := out.Interface().(R)
+	return clone
+}
+
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(cloneValue(v.Elem()))
+		return out
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(cloneValue(v.Elem()))
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if out.Field(i).CanSet() {
+				out.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMockDatabaseCopiesResults(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("FixtureSharedAcrossTests", func(t *testing.T) {
+		fixture := []any{bson.M{"name": "alice", "tags": []string{"admin"}}}
+		mock := NewMockDatabase()
+
+		t.Run("Mutates", func(t *testing.T) {
+			mock.QueueFind(fixture, nil)
+			result, _ := mock.Find(ctx, "shop", "users", bson.M{})
+			user := result.([]any)[0].(bson.M)
+			user["name"] = "mallory"
+			user["tags"].([]string)[0] = "root"
+		})
+
+		t.Run("SeesOriginal", func(t *testing.T) {
+			mock.QueueFind(fixture, nil)
+			result, _ := mock.Find(ctx, "shop", "users", bson.M{})
+			user := result.([]any)[0].(bson.M)
+			if user["name"] != "alice" || user["tags"].([]string)[0] != "admin" {
+				t.Errorf("expected the fixture to be unchanged, got %v", user)
+			}
+		})
+	})
+
+	t.Run("PreservesIDsAndTimes", func(t *testing.T) {
+		id := primitive.NewObjectID()
+		now := time.Now()
+		local := time.Date(2024, 3, 1, 12, 0, 0, 5, time.FixedZone("CET", 3600))
+		mock := NewMockDatabase()
+		mock.QueueFindOne(bson.M{"_id": id, "created": now, "local": local}, nil)
+
+		result, _ := mock.FindOne(ctx, "shop", "users", bson.M{})
+		doc := result.(bson.M)
+		if doc["_id"] != id {
+			t.Errorf("expected ObjectID %v, got %v", id, doc["_id"])
+		}
+		if doc["created"] != now {
+			t.Errorf("expected time with monotonic reading %v, got %v", now, doc["created"])
+		}
+		if doc["local"] != local || doc["local"].(time.Time).Location() != local.Location() {
+			t.Errorf("expected time in its location %v, got %v", local, doc["local"])
+		}
+	})
+
+	t.Run("Structs", func(t *testing.T) {
+		type address struct{ City string }
+		type user struct {
+			Name    string
+			Address *address
+			Emails  []string
+		}
+		fixture := &user{Name: "alice", Address: &address{City: "Ghent"}, Emails: []string{"a@example.com"}}
+		mock := NewMockDatabase()
+		mock.OnFindOne("shop", "users").Return(fixture, nil).Times(2)
+
+		result, _ := mock.FindOne(ctx, "shop", "users", bson.M{})
+		got := result.(*user)
+		if got == fixture || got.Address == fixture.Address {
+			t.Fatal("expected a deep copy of the struct")
+		}
+		got.Address.City = "Paris"
+		got.Emails[0] = "b@example.com"
+
+		result, _ = mock.FindOne(ctx, "shop", "users", bson.M{})
+		if again := result.(*user); again.Address.City != "Ghent" || again.Emails[0] != "a@example.com" {
+			t.Errorf("expected the expectation's result to be unchanged, got %+v", again)
+		}
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		fixture := []any{bson.M{"name": "alice"}}
+		mock := NewMockDatabase()
+		mock.QueueFindShared(fixture, nil)
+
+		result, _ := mock.Find(ctx, "shop", "users", bson.M{})
+		result.([]any)[0].(bson.M)["name"] = "mallory"
+		if fixture[0].(bson.M)["name"] != "mallory" {
+			t.Error("expected a shared result to alias the fixture")
+		}
+	})
+
+	t.Run("HandlerResultsAreNotCopied", func(t *testing.T) {
+		fixture := bson.M{"name": "alice"}
+		mock := NewMockDatabase()
+		mock.FindOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+			return fixture, nil
+		}
+
+		result, _ := mock.FindOne(ctx, "shop", "users", bson.M{})
+		result.(bson.M)["name"] = "mallory"
+		if fixture["name"] != "mallory" {
+			t.Error("expected the handler's result to be returned as is")
+		}
+	})
+
+	t.Run("NilResults", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindOne(nil, mongo.ErrNoDocuments)
+		mock.QueueUpdateOne(nil, nil)
+
+		if result, err := mock.FindOne(ctx, "shop", "users", bson.M{}); result != nil || err != mongo.ErrNoDocuments {
+			t.Errorf("expected nil and mongo.ErrNoDocuments, got %v, %v", result, err)
+		}
+		if result, _ := mock.UpdateOne(ctx, "shop", "users", bson.M{}, bson.M{}); result != nil {
+			t.Errorf("expected a nil UpdateResult, got %v", result)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MockCursor is the Cursor returned by the mock's FindCursor. It yields its
+// documents one at a time and can fail at a chosen position or block until
+// the caller's context ends. Close calls are recorded so tests can assert
+// that consumers release their cursors.
+type MockCursor struct {
+	mu      sync.Mutex
+	docs    []any
+	failAt  int
+	failErr error
+	block   bool
+	done    chan struct{}
+	pos     int
+	current any
+	err     error
+	closed  bool
+}
+
+// NewMockCursor creates a cursor yielding docs, for use with ExpectFindCursor
+// handlers and scoped expectations
+func NewMockCursor(docs []any) *MockCursor {
+	return newMockCursor(docs, -1, nil, false)
+}
+
+func newMockCursor(docs []any, failAt int, err error, block bool) *MockCursor {
+	return &MockCursor{docs: docs, failAt: failAt, failErr: err, block: block, done: make(chan struct{})}
+}
+
+// Next advances to the next document. It returns false at the end of the
+// documents, at the failure position, or for blocking cursors once the
+// context ends.
+func (c *MockCursor) Next(ctx context.Context) bool {
+	c.mu.Lock()
+	c.current = nil
+	if c.closed || c.err != nil {
+		c.mu.Unlock()
+		return false
+	}
+	if c.failAt >= 0 && c.pos == c.failAt {
+		c.err = c.failErr
+		c.mu.Unlock()
+		return false
+	}
+	if c.pos < len(c.docs) {
+		c.current = c.docs[c.pos]
+		c.pos++
+		c.mu.Unlock()
+		return true
+	}
+	if !c.block {
+		c.mu.Unlock()
+		return false
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.err = ctx.Err()
+		c.mu.Unlock()
+	case <-c.done:
+	}
+	return false
+}
+
+// Decode decodes the current document into val, which may be a map or a struct
+func (c *MockCursor) Decode(val any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return decodeDocument(c.current, val)
+}
+
+func (c *MockCursor) document() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+}
+
+// Err returns the error that ended iteration, if any
+func (c *MockCursor) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Close marks the cursor closed and releases a blocked Next
+func (c *MockCursor) Close(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
+
+// Closed reports whether Close was called
+func (c *MockCursor) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// ClosedEarly reports whether Close was called before every document was read
+func (c *MockCursor) ClosedEarly() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed && c.err == nil && c.pos < len(c.docs)
+}
+
+// Position returns how many documents have been read
+func (c *MockCursor) Position() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pos
+}
+
+// String describes the cursor for failure messages
+func (c *MockCursor) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return fmt.Sprintf("cursor(%d docs, read %d, closed=%t)", len(c.docs), c.pos, c.closed)
+}
+
+// QueueFindCursor adds a FindCursor response yielding docs. When failAtIndex
+// is zero or more the cursor stops at that position and Err returns err.
+func (m *MockDatabase) QueueFindCursor(docs []any, failAtIndex int, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindCursorQueue = append(m.FindCursorQueue, FindCursorResponse{Result: newMockCursor(docs, failAtIndex, err, false)})
+	return m
+}
+
+// QueueFindCursorBlocking adds a FindCursor response that yields docs and then
+// blocks in Next until the context is cancelled or the cursor closed
+func (m *MockDatabase) QueueFindCursorBlocking(docs []any) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindCursorQueue = append(m.FindCursorQueue, FindCursorResponse{Result: newMockCursor(docs, -1, nil, true)})
+	return m
+}
+
+// AssertCursorsClosed fails the test if any MockCursor returned by FindCursor
+// was never closed
+func (m *MockDatabase) AssertCursorsClosed(t testing.TB) bool {
+	t.Helper()
+	m.mu.Lock()
+	calls := append([]FindCursorCall(nil), m.FindCursorCalls...)
+	m.mu.Unlock()
+
+	var open []string
+	for i, call := range calls {
+		if call.Cursor != nil && !call.Cursor.Closed() {
+			open = append(open, fmt.Sprintf("  #%d FindCursor %s %s", i, namespaceString(call.Db, call.Collection), call.Cursor))
+		}
+	}
+	if len(open) > 0 {
+		t.Errorf("expected every cursor to be closed, %d left open:\n%s", len(open), strings.Join(open, "\n"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseFindCursor(t *testing.T) {
+	ctx := context.Background()
+	docs := []any{
+		bson.M{"name": "alice", "age": 31},
+		bson.M{"name": "bob", "age": 25},
+		bson.M{"name": "carol", "age": 42},
+	}
+
+	t.Run("YieldsDocumentsInOrder", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindCursor(docs, -1, nil)
+
+		cursor, err := mock.FindCursor(ctx, "testdb", "users", bson.M{})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		defer cursor.Close(ctx)
+
+		var names []string
+		for cursor.Next(ctx) {
+			var user struct {
+				Name string `bson:"name"`
+				Age  int    `bson:"age"`
+			}
+			if err := cursor.Decode(&user); err != nil {
+				t.Fatalf("failed to decode into struct: %v", err)
+			}
+			names = append(names, user.Name)
+
+			var m map[string]any
+			if err := cursor.Decode(&m); err != nil || m["name"] != user.Name {
+				t.Fatalf("failed to decode into map: %v, %v", m, err)
+			}
+		}
+		if cursor.Err() != nil {
+			t.Errorf("expected nil cursor error, got %v", cursor.Err())
+		}
+		if strings.Join(names, ",") != "alice,bob,carol" {
+			t.Errorf("expected documents in order, got %v", names)
+		}
+	})
+
+	t.Run("FailsAtIndex", func(t *testing.T) {
+		mock := NewMockDatabase()
+		errCursor := errors.New("cursor killed")
+		mock.QueueFindCursor(docs, 2, errCursor)
+
+		cursor, _ := mock.FindCursor(ctx, "testdb", "users", bson.M{})
+		read := 0
+		for cursor.Next(ctx) {
+			read++
+		}
+		if read != 2 || !errors.Is(cursor.Err(), errCursor) {
+			t.Errorf("expected failure after 2 documents, read %d, err %v", read, cursor.Err())
+		}
+	})
+
+	t.Run("BlocksUntilContextCancelled", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindCursorBlocking(docs[:1])
+
+		cursor, _ := mock.FindCursor(ctx, "testdb", "users", bson.M{})
+		cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		if !cursor.Next(cctx) {
+			t.Fatal("expected the first document before blocking")
+		}
+		start := time.Now()
+		if cursor.Next(cctx) {
+			t.Fatal("expected blocked Next to return false")
+		}
+		if time.Since(start) < 15*time.Millisecond {
+			t.Error("expected Next to block until the context expired")
+		}
+		if !errors.Is(cursor.Err(), context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", cursor.Err())
+		}
+	})
+
+	t.Run("EarlyCloseIsRecorded", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindCursor(docs, -1, nil)
+		mock.QueueFindCursor(docs, -1, nil)
+
+		first, _ := mock.FindCursor(ctx, "testdb", "users", bson.M{})
+		first.Next(ctx)
+		first.Close(ctx)
+		mock.FindCursor(ctx, "testdb", "users", bson.M{})
+
+		if c := mock.FindCursorCalls[0].Cursor; !c.Closed() || !c.ClosedEarly() || c.Position() != 1 {
+			t.Errorf("expected first cursor closed early after 1 document, got %v", c)
+		}
+
+		tb := &recordingTB{TB: t}
+		if mock.AssertCursorsClosed(tb) {
+			t.Error("expected AssertCursorsClosed to fail for the unclosed cursor")
+		}
+		if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "#1 FindCursor testdb.users") {
+			t.Errorf("expected unclosed cursor in failure, got %v", tb.errors)
+		}
+	})
+
+	t.Run("ExpectFindCursorReturnsFreshCursors", func(t *testing.T) {
+		mock := NewMockDatabase().ExpectFindCursor(docs, nil)
+
+		for i := 0; i < 2; i++ {
+			cursor, _ := mock.FindCursor(ctx, "testdb", "users", bson.M{})
+			read := 0
+			for cursor.Next(ctx) {
+				read++
+			}
+			cursor.Close(ctx)
+			if read != len(docs) {
+				t.Errorf("call %d: expected %d documents, got %d", i, len(docs), read)
+			}
+		}
+		mock.AssertCursorsClosed(t)
+	})
+
+	t.Run("DecodeWithoutCurrentDocument", func(t *testing.T) {
+		cursor := NewMockCursor(docs)
+		var m bson.M
+		if err := cursor.Decode(&m); err == nil {
+			t.Error("expected Decode before Next to fail")
+		}
+	})
+}
+
+func TestFakeDatabaseFindCursor(t *testing.T) {
+	fake := seededFake(t)
+
+	cursor, err := fake.FindCursor(context.Background(), "testdb", "users", bson.M{"age": bson.M{"$gt": 30}})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var names []string
+	for cursor.Next(context.Background()) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		names = append(names, doc["name"].(string))
+	}
+	if strings.Join(names, ",") != "alice,carol" {
+		t.Errorf("expected alice,carol, got %v", names)
+	}
+}
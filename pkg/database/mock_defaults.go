@@ -0,0 +1,52 @@
+package database
+
+// SetDefaultFind makes Find on db.collection return result and err whenever
+// no queued response or scoped expectation answers the call. An empty db or
+// collection matches any. Defaults are kept by Reset and ResetCalls and
+// cleared by ResetAll, so a shared test helper can install them once.
+func (m *MockDatabase) SetDefaultFind(db string, collection string, result any, err error) *MockDatabase {
+	return m.setDefault("Find", db, collection, result, err)
+}
+
+// SetDefaultFindOne is SetDefaultFind for FindOne
+func (m *MockDatabase) SetDefaultFindOne(db string, collection string, result any, err error) *MockDatabase {
+	return m.setDefault("FindOne", db, collection, result, err)
+}
+
+// SetDefaultCount is SetDefaultFind for Count
+func (m *MockDatabase) SetDefaultCount(db string, collection string, result int64, err error) *MockDatabase {
+	return m.setDefault("Count", db, collection, result, err)
+}
+
+// SetDefaultDistinct is SetDefaultFind for Distinct
+func (m *MockDatabase) SetDefaultDistinct(db string, collection string, result []any, err error) *MockDatabase {
+	return m.setDefault("Distinct", db, collection, result, err)
+}
+
+// setDefault installs or replaces the default for op on db.collection. A
+// default is kept as an Expectation without filters that is never verified.
+func (m *MockDatabase) setDefault(op string, db string, collection string, result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := &Expectation{Operation: op, Db: db, Collection: collection, result: result, err: err}
+	for i, existing := range m.defaults {
+		if existing.Operation == op && existing.Db == db && existing.Collection == collection {
+			m.defaults[i] = d
+			return m
+		}
+	}
+	m.defaults = append(m.defaults, d)
+	return m
+}
+
+// namespaceDefault returns the default answering op on db.collection, if any;
+// the caller holds m.mu
+func (m *MockDatabase) namespaceDefault(op string, db string, collection string) (*Expectation, bool) {
+	for _, d := range m.defaults {
+		if d.matchesNamespace(op, db, collection) {
+			return d, true
+		}
+	}
+	return nil, false
+}
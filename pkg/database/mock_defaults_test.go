@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseNamespaceDefaults(t *testing.T) {
+	ctx := context.Background()
+	settings := map[string]any{"theme": "dark"}
+
+	t.Run("AnswersOnlyItsNamespace", func(t *testing.T) {
+		mock := NewMockDatabase().
+			SetDefaultFindOne("app", "settings", settings, nil).
+			SetDefaultCount("app", "settings", 1, nil)
+
+		doc, err := mock.FindOne(ctx, "app", "settings", bson.M{})
+		if err != nil || doc.(map[string]any)["theme"] != "dark" {
+			t.Errorf("expected the default document, got %v, %v", doc, err)
+		}
+		if n, _ := mock.Count(ctx, "app", "settings", bson.M{}); n != 1 {
+			t.Errorf("expected the default count, got %d", n)
+		}
+		if _, err := mock.FindOne(ctx, "app", "users", bson.M{}); err == nil {
+			t.Error("expected other namespaces to use the FindOne handler")
+		}
+		mock.SetDefaultFind("app", "unused", []any{}, nil)
+		if err := mock.Verify(); err != nil {
+			t.Errorf("expected defaults not to be verified, got %v", err)
+		}
+	})
+
+	t.Run("QueuesAndExpectationsComeFirst", func(t *testing.T) {
+		mock := NewMockDatabase().SetDefaultFind("app", "settings", []any{"default"}, nil)
+		mock.QueueFind([]any{"queued"}, nil)
+		mock.OnFind("app", "settings").Return([]any{"scoped"}, nil).Once()
+
+		var got []any
+		for i := 0; i < 3; i++ {
+			result, _ := mock.Find(ctx, "app", "settings", bson.M{})
+			got = append(got, result.([]any)[0])
+		}
+		if !valuesEqual(got, []any{"queued", "scoped", "default"}) {
+			t.Errorf("expected queued, scoped, then default, got %v", got)
+		}
+	})
+
+	t.Run("DefaultsBeatHandlers", func(t *testing.T) {
+		mock := NewMockDatabase().
+			ExpectFindOne(nil, errors.New("handler")).
+			SetDefaultFindOne("", "settings", nil, errors.New("default"))
+
+		if _, err := mock.FindOne(ctx, "other", "settings", bson.M{}); err == nil || err.Error() != "default" {
+			t.Errorf("expected an empty db to match any database, got %v", err)
+		}
+	})
+
+	t.Run("ReplacedPerNamespace", func(t *testing.T) {
+		mock := NewMockDatabase().
+			SetDefaultCount("app", "settings", 1, nil).
+			SetDefaultCount("app", "settings", 2, nil)
+
+		if n, _ := mock.Count(ctx, "app", "settings", bson.M{}); n != 2 {
+			t.Errorf("expected the latest default, got %d", n)
+		}
+	})
+
+	t.Run("ResetSemantics", func(t *testing.T) {
+		mock := NewMockDatabase().SetDefaultCount("app", "settings", 5, nil)
+
+		mock.ResetCalls()
+		mock.Reset()
+		if n, _ := mock.Count(ctx, "app", "settings", bson.M{}); n != 5 {
+			t.Errorf("expected defaults to survive Reset and ResetCalls, got %d", n)
+		}
+		mock.ResetAll()
+		if n, _ := mock.Count(ctx, "app", "settings", bson.M{}); n != 0 {
+			t.Errorf("expected ResetAll to clear defaults, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// WithDelay makes every call to op take at least d before responding.
+// Use "*" to delay every operation; a specific operation overrides "*".
+// Queued responses with their own delay replace this value.
+func (m *MockDatabase) WithDelay(op string, d time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.delays == nil {
+		m.delays = map[string]time.Duration{}
+	}
+	m.delays[op] = d
+	return m
+}
+
+// WithJitter adds a random extra delay in [0, max) to every call to op,
+// on top of any configured or queued delay. Use "*" for every operation.
+func (m *MockDatabase) WithJitter(op string, max time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.jitters == nil {
+		m.jitters = map[string]time.Duration{}
+	}
+	m.jitters[op] = max
+	return m
+}
+
+// delayFor resolves the simulated latency of a call; the caller holds m.mu
+func (m *MockDatabase) delayFor(op string, responseDelay time.Duration) time.Duration {
+	delay := responseDelay
+	if delay == 0 {
+		delay = lookupOperation(m.delays, op)
+	}
+	if jitter := lookupOperation(m.jitters, op); jitter > 0 {
+		delay += time.Duration(rand.Int64N(int64(jitter)))
+	}
+	return delay
+}
+
+func lookupOperation(values map[string]time.Duration, op string) time.Duration {
+	if d, ok := values[op]; ok {
+		return d
+	}
+	return values["*"]
+}
+
+// sleepContext waits for d or until ctx is done, returning ctx.Err() in the latter case
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
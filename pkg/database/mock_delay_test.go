@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseDelay(t *testing.T) {
+	t.Run("WithDelay", func(t *testing.T) {
+		mock := NewMockDatabase().WithDelay("Find", 50*time.Millisecond)
+
+		start := time.Now()
+		if _, err := mock.Find(context.Background(), "testdb", "users", bson.M{}); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected at least 50ms delay, got %v", elapsed)
+		}
+
+		// Other operations are not delayed
+		start = time.Now()
+		mock.FindOne(context.Background(), "testdb", "users", bson.M{})
+		if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+			t.Errorf("expected FindOne to be immediate, got %v", elapsed)
+		}
+	})
+
+	t.Run("QueuedDelay", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindDelayed([]any{"slow"}, nil, 50*time.Millisecond)
+
+		start := time.Now()
+		result, err := mock.Find(context.Background(), "testdb", "users", bson.M{})
+		if err != nil || result.([]any)[0] != "slow" {
+			t.Fatalf("expected delayed queued result, got %v, %v", result, err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("expected at least 50ms delay, got %v", elapsed)
+		}
+	})
+
+	t.Run("ContextExpiresDuringDelay", func(t *testing.T) {
+		mock := NewMockDatabase().WithDelay("*", time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := mock.UpdateOne(ctx, "testdb", "users", bson.M{}, bson.M{})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected delay to be cut short, took %v", elapsed)
+		}
+	})
+
+	t.Run("Jitter", func(t *testing.T) {
+		mock := NewMockDatabase().WithJitter("Ping", 20*time.Millisecond)
+
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			if err := mock.Ping(context.Background()); err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("expected jitter below bound, got %v", elapsed)
+			}
+		}
+	})
+
+	t.Run("ConcurrentCalls", func(t *testing.T) {
+		mock := NewMockDatabase().WithDelay("Find", 100*time.Millisecond)
+
+		var wg sync.WaitGroup
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mock.Find(context.Background(), "testdb", "users", bson.M{})
+			}()
+		}
+		wg.Wait()
+
+		// Delays run concurrently rather than serialized behind the mock's lock
+		if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+			t.Errorf("expected concurrent delays, took %v", elapsed)
+		}
+		if mock.CallsTo("Find") != 10 {
+			t.Errorf("expected 10 calls, got %d", mock.CallsTo("Find"))
+		}
+	})
+}
+
+func TestMockDatabaseContextCancellation(t *testing.T) {
+	t.Run("AlreadyCancelled", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{"queued"}, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := mock.Find(ctx, "testdb", "users", bson.M{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context canceled, got %v", err)
+		}
+		if len(mock.FindQueue) != 1 {
+			t.Error("expected queued response not to be consumed")
+		}
+		if mock.CallsTo("Find") != 1 {
+			t.Error("expected the call to still be recorded")
+		}
+
+		// Every operation honours the context
+		if err := mock.Ping(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected Ping to return context canceled, got %v", err)
+		}
+		if _, err := mock.InsertOne(ctx, "testdb", "users", bson.M{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected InsertOne to return context canceled, got %v", err)
+		}
+	})
+
+	t.Run("CancelledMidDelay", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindOneDelayed(map[string]any{"id": 1}, nil, time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+
+		start := time.Now()
+		result, err := mock.FindOne(ctx, "testdb", "users", bson.M{})
+		if !errors.Is(err, context.Canceled) || result != nil {
+			t.Errorf("expected cancellation to win over delayed response, got %v, %v", result, err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected prompt return, took %v", elapsed)
+		}
+	})
+
+	t.Run("OptOut", func(t *testing.T) {
+		mock := NewMockDatabase().IgnoreContextCancellation(true)
+		mock.QueueFind([]any{"queued"}, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := mock.Find(ctx, "testdb", "users", bson.M{})
+		if err != nil || result.([]any)[0] != "queued" {
+			t.Errorf("expected queued response despite cancelled context, got %v, %v", result, err)
+		}
+	})
+}
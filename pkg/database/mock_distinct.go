@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// DistinctResponse represents a queued response for Distinct
+type DistinctResponse struct {
+	Result []any
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueDistinctFunc
+	Responder func(call DistinctCall) ([]any, error)
+}
+
+// DistinctCall records a call to Distinct
+type DistinctCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Field       string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// Distinct implements DatabaseInterface. Scoped expectations and filter
+// matchers see the filter; the field is recorded in DistinctCalls.
+func (m *MockDatabase) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "Distinct", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			m.DistinctCalls = append(m.DistinctCalls, DistinctCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Field:      field,
+				Filter:     filter,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[[]any], bool) {
+			r, ok := popQueue(&m.DistinctQueue)
+			return mockResponse[[]any]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() ([]any, error) {
+			if m.DistinctFunc != nil {
+				return m.DistinctFunc(ctx, db, collection, field, filter, opts...)
+			}
+			return []any{}, nil
+		})
+}
+
+// ExpectDistinct makes Distinct on field return result and err. Each field
+// keeps its own answer, so several fields can be expected at once; other
+// fields get the previous behavior.
+func (m *MockDatabase) ExpectDistinct(field string, result []any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.DistinctFunc
+	m.DistinctFunc = func(ctx context.Context, db string, collection string, f string, filter any, opts ...any) ([]any, error) {
+		if f == field {
+			return result, err
+		}
+		if previous != nil {
+			return previous(ctx, db, collection, f, filter, opts...)
+		}
+		return []any{}, nil
+	}
+	return m
+}
+
+// QueueDistinct adds a Distinct response to the queue for sequential calls
+func (m *MockDatabase) QueueDistinct(result []any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DistinctQueue = append(m.DistinctQueue, DistinctResponse{Result: result, Err: err})
+	return m
+}
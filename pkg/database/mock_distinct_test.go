@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMockDatabaseDistinct(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("DefaultsToEmpty", func(t *testing.T) {
+		values, err := NewMockDatabase().Distinct(ctx, "db", "cameras", "site_id", bson.M{})
+		if err != nil || values == nil || len(values) != 0 {
+			t.Errorf("expected an empty slice, got %#v, %v", values, err)
+		}
+	})
+
+	t.Run("ExpectIsKeyedByField", func(t *testing.T) {
+		mock := NewMockDatabase().
+			ExpectDistinct("site_id", []any{"s1", "s2"}, nil).
+			ExpectDistinct("vendor", nil, errors.New("boom"))
+
+		if sites, _ := mock.Distinct(ctx, "db", "cameras", "site_id", bson.M{}); len(sites) != 2 {
+			t.Errorf("expected the site ids, got %v", sites)
+		}
+		if _, err := mock.Distinct(ctx, "db", "cameras", "vendor", bson.M{}); err == nil {
+			t.Error("expected the vendor error")
+		}
+		if other, err := mock.Distinct(ctx, "db", "cameras", "model", bson.M{}); err != nil || len(other) != 0 {
+			t.Errorf("expected other fields to keep the default, got %v, %v", other, err)
+		}
+	})
+
+	t.Run("QueueAndRecordedCall", func(t *testing.T) {
+		mock := NewMockDatabase().QueueDistinct([]any{"s1"}, nil)
+		values, _ := mock.Distinct(ctx, "db", "cameras", "site_id", bson.M{"tenant": "t1"})
+		if len(values) != 1 {
+			t.Errorf("expected the queued values, got %v", values)
+		}
+
+		call, ok := mock.LastDistinctCall()
+		if !ok || call.Field != "site_id" || call.Db != "db" || call.Collection != "cameras" {
+			t.Errorf("expected the call to be recorded, got %+v", call)
+		}
+		if history := mock.History(); history[0].Args[0] != "site_id" {
+			t.Errorf("expected the field first in the history args, got %v", history[0].Args)
+		}
+	})
+
+	t.Run("ScopedExpectationMatchesFilter", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnDistinct("db", "cameras").WithFilter(FilterHasKeys("tenant")).Return([]any{"s9"}, nil)
+
+		if values, _ := mock.Distinct(ctx, "db", "cameras", "site_id", bson.M{"tenant": "t1"}); len(values) != 1 {
+			t.Errorf("expected the expectation to answer, got %v", values)
+		}
+		if values, _ := mock.Distinct(ctx, "db", "cameras", "site_id", bson.M{}); len(values) != 0 {
+			t.Errorf("expected a filter mismatch to fall through, got %v", values)
+		}
+	})
+
+	t.Run("VerifyExplainsFilterMismatch", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnDistinct("db", "cameras").WithFilter(FilterHasKeys("tenant"))
+		mock.Distinct(ctx, "db", "cameras", "site_id", bson.M{"site": "s1"})
+
+		if err := mock.Verify(); err == nil || !strings.Contains(err.Error(), "filter {site: string} failed matcher 1 of 1") {
+			t.Errorf("expected the filter, not the field, to be explained, got %v", err)
+		}
+	})
+}
+
+func TestMockDatabaseAggregateStages(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockDatabase()
+	mock.OnAggregate("db", "events").
+		WithFilter(PipelineHasStage("$match", FilterHasKeys("tenant_id"))).
+		Return([]any{bson.M{"n": 1}}, nil)
+
+	result, _ := mock.Aggregate(ctx, "db", "events", mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "tenant_id", Value: "t1"}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$site", "n": bson.M{"$sum": 1}}}},
+	})
+	if len(result.([]any)) != 1 {
+		t.Errorf("expected the expectation matching the $match stage, got %v", result)
+	}
+
+	call, _ := mock.LastAggregateCall()
+	if len(call.Stages) != 2 || call.Stages[1].Name != "$group" {
+		t.Fatalf("expected two structured stages, got %+v", call.Stages)
+	}
+	match, ok := call.Stage("$match")
+	if !ok || match.(map[string]any)["tenant_id"] != "t1" {
+		t.Errorf("expected the $match stage as a normalized document, got %v", match)
+	}
+	if _, ok := call.Stage("$sort"); ok {
+		t.Error("expected no $sort stage")
+	}
+
+	mock.Aggregate(ctx, "db", "events", bson.M{"not": "a pipeline"})
+	if call, _ := mock.LastAggregateCall(); call.Stages != nil {
+		t.Errorf("expected no stages for an invalid pipeline, got %v", call.Stages)
+	}
+}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Expectation is a mock response scoped to an operation and namespace and
+// optionally narrowed by filter matchers. An empty Db or Collection matches any.
+type Expectation struct {
+	Operation  string
+	Db         string
+	Collection string
+
+	filters  []FilterMatcher
+	options  []OptionsMatcher
+	result   any
+	err      error
+	calls    int
+	optional bool
+
+	// times limits how many calls the expectation answers, 0 means no limit;
+	// overCalls counts matching calls made after it was exhausted
+	times     int
+	overCalls int
+
+	// responder computes the result from the call, see ReturnFunc
+	responder reflect.Value
+}
+
+// NearMiss records an expectation whose operation and namespace matched a call
+// but whose filter matchers did not
+type NearMiss struct {
+	Expectation *Expectation
+	Db          string
+	Collection  string
+	Filter      any
+}
+
+// WithFilter narrows the expectation to calls whose filter satisfies every matcher.
+// For InsertOne the document is matched, for InsertMany the document slice and
+// for BulkWrite the write models.
+func (e *Expectation) WithFilter(matchers ...FilterMatcher) *Expectation {
+	e.filters = append(e.filters, matchers...)
+	return e
+}
+
+// WithOptions narrows the expectation to calls whose parsed options satisfy
+// every matcher, see QueryOptions
+func (e *Expectation) WithOptions(matchers ...OptionsMatcher) *Expectation {
+	e.options = append(e.options, matchers...)
+	return e
+}
+
+// Return sets the result and error returned when the expectation matches
+func (e *Expectation) Return(result any, err error) *Expectation {
+	e.result = result
+	e.err = err
+	return e
+}
+
+// Optional marks the expectation as allowed to go unmatched during verification
+func (e *Expectation) Optional() *Expectation {
+	e.optional = true
+	return e
+}
+
+// Once limits the expectation to a single call, see Times
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Times limits the expectation to n calls. Once exhausted it no longer
+// matches, so later calls fall through to the next expectation or handler,
+// or fail the test in strict mode. Verify reports both missing and extra calls.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Calls returns how many calls the expectation has answered
+func (e *Expectation) Calls() int {
+	return e.calls
+}
+
+// String describes the expectation for debugging output
+func (e *Expectation) String() string {
+	return fmt.Sprintf("%s on %s", e.Operation, namespaceString(e.Db, e.Collection))
+}
+
+func (e *Expectation) matchesNamespace(op, db, collection string) bool {
+	return e.Operation == op &&
+		(e.Db == "" || e.Db == db) &&
+		(e.Collection == "" || e.Collection == collection)
+}
+
+func (e *Expectation) matchesFilter(filter any) bool {
+	for _, matcher := range e.filters {
+		if !matcher(filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectation) matchesOptions(opts []any) bool {
+	if len(e.options) == 0 {
+		return true
+	}
+	parsed := parseQueryOptions(opts)
+	for _, matcher := range e.options {
+		if !matcher(parsed) {
+			return false
+		}
+	}
+	return true
+}
+
+// On registers a scoped expectation for the named operation on db.collection.
+// Expectations are consulted in registration order after queued responses and
+// before the XFunc handlers.
+func (m *MockDatabase) On(operation string, db string, collection string) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := &Expectation{Operation: operation, Db: db, Collection: collection}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// OnPing registers a scoped expectation for Ping
+func (m *MockDatabase) OnPing() *Expectation {
+	return m.On("Ping", "", "")
+}
+
+// OnClose registers a scoped expectation for Close
+func (m *MockDatabase) OnClose() *Expectation {
+	return m.On("Close", "", "")
+}
+
+// OnFind registers a scoped expectation for Find
+func (m *MockDatabase) OnFind(db string, collection string) *Expectation {
+	return m.On("Find", db, collection)
+}
+
+// OnFindOne registers a scoped expectation for FindOne
+func (m *MockDatabase) OnFindOne(db string, collection string) *Expectation {
+	return m.On("FindOne", db, collection)
+}
+
+// OnFindCursor registers a scoped expectation for FindCursor; Return a Cursor
+// such as NewMockCursor(docs)
+func (m *MockDatabase) OnFindCursor(db string, collection string) *Expectation {
+	return m.On("FindCursor", db, collection)
+}
+
+// OnAggregate registers a scoped expectation for Aggregate; filter matchers
+// receive the pipeline
+func (m *MockDatabase) OnAggregate(db string, collection string) *Expectation {
+	return m.On("Aggregate", db, collection)
+}
+
+// OnCount registers a scoped expectation for Count
+func (m *MockDatabase) OnCount(db string, collection string) *Expectation {
+	return m.On("Count", db, collection)
+}
+
+// OnDistinct registers a scoped expectation for Distinct on db.collection;
+// filter matchers apply to the filter, not the field
+func (m *MockDatabase) OnDistinct(db string, collection string) *Expectation {
+	return m.On("Distinct", db, collection)
+}
+
+// OnInsertOne registers a scoped expectation for InsertOne
+func (m *MockDatabase) OnInsertOne(db string, collection string) *Expectation {
+	return m.On("InsertOne", db, collection)
+}
+
+// OnInsertMany registers a scoped expectation for InsertMany
+func (m *MockDatabase) OnInsertMany(db string, collection string) *Expectation {
+	return m.On("InsertMany", db, collection)
+}
+
+// OnUpdateOne registers a scoped expectation for UpdateOne
+func (m *MockDatabase) OnUpdateOne(db string, collection string) *Expectation {
+	return m.On("UpdateOne", db, collection)
+}
+
+// OnUpdateMany registers a scoped expectation for UpdateMany
+func (m *MockDatabase) OnUpdateMany(db string, collection string) *Expectation {
+	return m.On("UpdateMany", db, collection)
+}
+
+// OnReplaceOne registers a scoped expectation for ReplaceOne
+func (m *MockDatabase) OnReplaceOne(db string, collection string) *Expectation {
+	return m.On("ReplaceOne", db, collection)
+}
+
+// OnDeleteOne registers a scoped expectation for DeleteOne
+func (m *MockDatabase) OnDeleteOne(db string, collection string) *Expectation {
+	return m.On("DeleteOne", db, collection)
+}
+
+// OnDeleteMany registers a scoped expectation for DeleteMany
+func (m *MockDatabase) OnDeleteMany(db string, collection string) *Expectation {
+	return m.On("DeleteMany", db, collection)
+}
+
+// OnFindOneAndUpdate registers a scoped expectation for FindOneAndUpdate
+func (m *MockDatabase) OnFindOneAndUpdate(db string, collection string) *Expectation {
+	return m.On("FindOneAndUpdate", db, collection)
+}
+
+// OnBulkWrite registers a scoped expectation for BulkWrite
+func (m *MockDatabase) OnBulkWrite(db string, collection string) *Expectation {
+	return m.On("BulkWrite", db, collection)
+}
+
+// RecordNearMisses toggles recording of expectations that matched a call's
+// operation and namespace but not its filter, available via NearMisses
+func (m *MockDatabase) RecordNearMisses(enabled bool) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recordNearMisses = enabled
+	return m
+}
+
+// matchExpectation returns the first registered expectation answering the
+// call, or nil if none does. A call matching only exhausted expectations is
+// counted against them and, in strict mode, fails with an error. The caller
+// holds m.mu.
+func (m *MockDatabase) matchExpectation(op string, db string, collection string, filter any, opts []any) (*Expectation, error) {
+	var exhausted *Expectation
+	for _, e := range m.expectations {
+		if !e.matchesNamespace(op, db, collection) {
+			continue
+		}
+		if !e.matchesFilter(filter) || !e.matchesOptions(opts) {
+			if m.recordNearMisses {
+				m.NearMisses = append(m.NearMisses, NearMiss{
+					Expectation: e,
+					Db:          db,
+					Collection:  collection,
+					Filter:      filter,
+				})
+			}
+			continue
+		}
+		if e.times > 0 && e.calls >= e.times {
+			if exhausted == nil {
+				exhausted = e
+			}
+			continue
+		}
+		e.calls++
+		return e, nil
+	}
+	if exhausted == nil {
+		return nil, nil
+	}
+	exhausted.overCalls++
+	if m.strict == nil {
+		return nil, nil
+	}
+	err := fmt.Errorf("mock: %s was expected %d time(s) and is exhausted", exhausted, exhausted.times)
+	m.strict.Errorf("%v", err)
+	return nil, err
+}
+
+// expectationResult converts an expectation's result to the operation's
+// return type, converting between numeric kinds so Return(1, nil) works for int64
+func expectationResult[T any](e *Expectation) (T, error) {
+	return convertResult[T](e, e.result, e.err)
+}
+
+// convertResult converts a result produced by e to the operation's return type
+func convertResult[T any](e *Expectation, result any, err error) (T, error) {
+	var zero T
+	if result == nil {
+		return zero, err
+	}
+	if r, ok := result.(T); ok {
+		return r, err
+	}
+	rv := reflect.ValueOf(result)
+	target := reflect.TypeOf(zero)
+	if target != nil && rv.CanConvert(target) && isNumericKind(rv.Kind()) && isNumericKind(target.Kind()) {
+		return rv.Convert(target).Interface().(T), err
+	}
+	return zero, fmt.Errorf("mock: %s expectation returned %T, want %T", e, result, zero)
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+func namespaceString(db, collection string) string {
+	if db == "" {
+		db = "*"
+	}
+	if collection == "" {
+		collection = "*"
+	}
+	return db + "." + collection
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseScopedExpectations(t *testing.T) {
+	t.Run("MatchesByFilter", func(t *testing.T) {
+		mock := NewMockDatabase()
+		active := []any{map[string]any{"id": 1, "status": "active"}}
+
+		mock.OnFind("testdb", "users").
+			WithFilter(FilterContains(map[string]any{"status": "active"})).
+			Return(active, nil)
+
+		result, err := mock.Find(context.Background(), "testdb", "users", bson.M{"status": "active"})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if len(result.([]any)) != 1 {
+			t.Errorf("expected expectation result, got %v", result)
+		}
+
+		// Non-matching filter falls through to the default
+		result, _ = mock.Find(context.Background(), "testdb", "users", bson.M{"status": "inactive"})
+		if len(result.([]any)) != 0 {
+			t.Errorf("expected default empty result, got %v", result)
+		}
+	})
+
+	t.Run("NamespaceScoping", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFindOne("testdb", "settings").Return(map[string]any{"theme": "dark"}, nil)
+
+		if _, err := mock.FindOne(context.Background(), "testdb", "users", bson.M{}); err == nil {
+			t.Error("expected other namespace to fall back to default not found")
+		}
+		result, err := mock.FindOne(context.Background(), "testdb", "settings", bson.M{})
+		if err != nil || result.(map[string]any)["theme"] != "dark" {
+			t.Errorf("expected settings document, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("FallsThroughToNextExpectation", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFindOne("testdb", "users").WithFilter(FilterHasKeys("email")).Return("by-email", nil)
+		mock.OnFindOne("testdb", "users").Return("any-user", nil)
+
+		result, _ := mock.FindOne(context.Background(), "testdb", "users", bson.M{"email": "a@b"})
+		if result != "by-email" {
+			t.Errorf("expected first expectation, got %v", result)
+		}
+		result, _ = mock.FindOne(context.Background(), "testdb", "users", bson.M{"id": 1})
+		if result != "any-user" {
+			t.Errorf("expected second expectation, got %v", result)
+		}
+	})
+
+	t.Run("QueueTakesPriority", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFind("testdb", "users").Return([]any{"expectation"}, nil)
+		mock.QueueFind([]any{"queued"}, nil)
+
+		result, _ := mock.Find(context.Background(), "testdb", "users", bson.M{})
+		if result.([]any)[0] != "queued" {
+			t.Errorf("expected queued response first, got %v", result)
+		}
+		result, _ = mock.Find(context.Background(), "testdb", "users", bson.M{})
+		if result.([]any)[0] != "expectation" {
+			t.Errorf("expected expectation after queue drained, got %v", result)
+		}
+	})
+
+	t.Run("TypedWriteResults", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnDeleteMany("testdb", "sessions").Return(3, nil)
+		mock.OnUpdateOne("testdb", "users").Return(nil, errors.New("write conflict"))
+
+		deleted, err := mock.DeleteMany(context.Background(), "testdb", "sessions", bson.M{})
+		if err != nil || deleted != 3 {
+			t.Errorf("expected 3 deleted, got %d, %v", deleted, err)
+		}
+		if _, err := mock.UpdateOne(context.Background(), "testdb", "users", bson.M{}, bson.M{}); err == nil {
+			t.Error("expected write conflict error")
+		}
+
+		mock.OnReplaceOne("testdb", "users").Return("not an UpdateResult", nil)
+		if _, err := mock.ReplaceOne(context.Background(), "testdb", "users", bson.M{}, bson.M{}); err == nil {
+			t.Error("expected type mismatch error")
+		}
+	})
+
+	t.Run("RecordsNearMisses", func(t *testing.T) {
+		mock := NewMockDatabase().RecordNearMisses(true)
+		e := mock.OnFind("testdb", "users").WithFilter(FilterEquals(bson.M{"id": 1}))
+
+		mock.Find(context.Background(), "testdb", "users", bson.M{"id": 2})
+		mock.Find(context.Background(), "testdb", "cameras", bson.M{"id": 2})
+
+		if len(mock.NearMisses) != 1 {
+			t.Fatalf("expected 1 near miss, got %d", len(mock.NearMisses))
+		}
+		if mock.NearMisses[0].Expectation != e || mock.NearMisses[0].Collection != "users" {
+			t.Errorf("unexpected near miss %+v", mock.NearMisses[0])
+		}
+		if e.Calls() != 0 {
+			t.Errorf("expected expectation not to be consumed, got %d calls", e.Calls())
+		}
+	})
+}
+
+func TestMockDatabaseExpectationTimes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("OnceFallsThroughToNextExpectation", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFind("testdb", "users").Return([]any{"first"}, nil).Once()
+		mock.OnFind("testdb", "users").Return([]any{"rest"}, nil)
+
+		for i, want := range []string{"first", "rest", "rest"} {
+			result, _ := mock.Find(ctx, "testdb", "users", bson.M{})
+			if got := result.([]any)[0]; got != want {
+				t.Errorf("call %d: expected %s, got %v", i, want, got)
+			}
+		}
+	})
+
+	t.Run("TimesFallsThroughToHandler", func(t *testing.T) {
+		mock := NewMockDatabase()
+		e := mock.OnFindOne("testdb", "users").Return(map[string]any{"id": 1}, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			if _, err := mock.FindOne(ctx, "testdb", "users", bson.M{}); err != nil {
+				t.Fatalf("call %d: expected the expectation to answer, got %v", i, err)
+			}
+		}
+		if _, err := mock.FindOne(ctx, "testdb", "users", bson.M{}); err == nil {
+			t.Error("expected the default FindOne handler after the expectation was exhausted")
+		}
+		if e.Calls() != 2 {
+			t.Errorf("expected 2 answered calls, got %d", e.Calls())
+		}
+	})
+
+	t.Run("QueuesTakePrecedence", func(t *testing.T) {
+		mock := NewMockDatabase()
+		e := mock.OnFind("testdb", "users").Return([]any{"scoped"}, nil).Once()
+		mock.QueueFind([]any{"queued"}, nil)
+
+		first, _ := mock.Find(ctx, "testdb", "users", bson.M{})
+		second, _ := mock.Find(ctx, "testdb", "users", bson.M{})
+		third, _ := mock.Find(ctx, "testdb", "users", bson.M{})
+
+		if first.([]any)[0] != "queued" || second.([]any)[0] != "scoped" || len(third.([]any)) != 0 {
+			t.Errorf("expected queued, scoped, then the default, got %v, %v, %v", first, second, third)
+		}
+		if e.Calls() != 1 {
+			t.Errorf("expected the queued response not to use up the expectation, got %d calls", e.Calls())
+		}
+	})
+
+	t.Run("VerifyReportsMissingAndExtraCalls", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFind("testdb", "users").Return([]any{}, nil).Times(3)
+		mock.OnInsertOne("testdb", "users").Return("id", nil).Once()
+
+		mock.Find(ctx, "testdb", "users", bson.M{})
+		mock.InsertOne(ctx, "testdb", "users", bson.M{})
+		mock.InsertOne(ctx, "testdb", "users", bson.M{})
+
+		err := mock.Verify()
+		if err == nil {
+			t.Fatal("expected verification to fail")
+		}
+		for _, want := range []string{"matched 1 of 3 expected call(s)", "InsertOne on testdb.users: expected 1 call(s), called 1 more time(s)"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in %v", want, err)
+			}
+		}
+	})
+
+	t.Run("StrictFailsExhaustedCalls", func(t *testing.T) {
+		tb := &recordingTB{}
+		mock := NewMockDatabase().Strict(tb)
+		mock.OnFind("testdb", "users").Return([]any{}, nil).Once()
+		mock.ExpectFind([]any{"handler"}, nil)
+
+		mock.Find(ctx, "testdb", "users", bson.M{})
+		_, err := mock.Find(ctx, "testdb", "users", bson.M{})
+		if err == nil || !strings.Contains(err.Error(), "exhausted") {
+			t.Errorf("expected an exhausted error instead of the handler, got %v", err)
+		}
+		if len(tb.errors) != 1 {
+			t.Errorf("expected the test to be failed once, got %v", tb.errors)
+		}
+	})
+}
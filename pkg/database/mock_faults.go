@@ -0,0 +1,153 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mockFault is an error queued for the next matching call of any of ops, or
+// of any operation but Close when ops is empty
+type mockFault struct {
+	collection string
+	ops        []string
+	err        error
+}
+
+func (f mockFault) matches(op, collection string) bool {
+	if f.collection != "" && f.collection != collection {
+		return false
+	}
+	if len(f.ops) == 0 {
+		return op != "Close"
+	}
+	return slices.Contains(f.ops, op)
+}
+
+// uniqueIndexOperations lists the writes that can violate a unique index
+var uniqueIndexOperations = []string{
+	"InsertOne",
+	"InsertMany",
+	"UpdateOne",
+	"UpdateMany",
+	"ReplaceOne",
+	"FindOneAndUpdate",
+	"BulkWrite",
+}
+
+// DuplicateKeyError returns the error MongoClient returns when a write into
+// collection violates a unique index on the fields of key
+func DuplicateKeyError(collection string, key map[string]any) error {
+	fields := make([]string, 0, len(key))
+	for field := range key {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	index := make([]string, len(fields))
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		index[i] = field + "_1"
+		value := fmt.Sprint(key[field])
+		if s, ok := key[field].(string); ok {
+			value = fmt.Sprintf("%q", s)
+		}
+		values[i] = field + ": " + value
+	}
+	message := fmt.Sprintf("E11000 duplicate key error collection: %s index: %s dup key: { %s }",
+		collection, strings.Join(index, "_"), strings.Join(values, ", "))
+	return mapError(mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: message}}})
+}
+
+// TransientNetworkError returns the error MongoClient returns when the
+// connection to the server drops mid-operation
+func TransientNetworkError() error {
+	return mapError(mongo.CommandError{
+		Code:    6,
+		Name:    "HostUnreachable",
+		Message: "connection reset by peer",
+		Labels:  []string{"NetworkError", "RetryableWriteError"},
+	})
+}
+
+// UnauthorizedError returns the error MongoClient returns when the user lacks
+// the privileges for a command
+func UnauthorizedError() error {
+	return mapError(mongo.CommandError{
+		Code:    codeUnauthorized,
+		Name:    "Unauthorized",
+		Message: "not authorized to execute command",
+	})
+}
+
+// ThrottledError returns the error MongoClient returns when Cosmos DB
+// throttles an operation and asks to retry after retryAfter
+func ThrottledError(retryAfter time.Duration) error {
+	return mapError(mongo.CommandError{
+		Code: codeRequestRateTooLarge,
+		Name: "RequestRateTooLarge",
+		Message: fmt.Sprintf("Error=16500, RetryAfterMs=%d, Details='Response status code does not indicate success: TooManyRequests (429)'",
+			retryAfter.Milliseconds()),
+	})
+}
+
+// ServerTimeoutError returns the error MongoClient returns when an operation
+// exceeds its server-side time limit
+func ServerTimeoutError() error {
+	return mapError(mongo.CommandError{
+		Code:    50,
+		Name:    "MaxTimeMSExpired",
+		Message: "operation exceeded time limit",
+	})
+}
+
+// QueueDuplicateKey makes the next write into collection that could violate a
+// unique index fail with DuplicateKeyError(collection, key)
+func (m *MockDatabase) QueueDuplicateKey(collection string, key map[string]any) *MockDatabase {
+	return m.queueFault(mockFault{collection: collection, ops: uniqueIndexOperations, err: DuplicateKeyError(collection, key)})
+}
+
+// QueueTransientNetworkError makes the next call fail with
+// TransientNetworkError
+func (m *MockDatabase) QueueTransientNetworkError() *MockDatabase {
+	return m.queueFault(mockFault{err: TransientNetworkError()})
+}
+
+// QueueUnauthorized makes the next call fail with UnauthorizedError
+func (m *MockDatabase) QueueUnauthorized() *MockDatabase {
+	return m.queueFault(mockFault{err: UnauthorizedError()})
+}
+
+// QueueThrottled makes the next call fail with ThrottledError(retryAfter)
+func (m *MockDatabase) QueueThrottled(retryAfter time.Duration) *MockDatabase {
+	return m.queueFault(mockFault{err: ThrottledError(retryAfter)})
+}
+
+// QueueServerTimeout makes the next call fail with ServerTimeoutError
+func (m *MockDatabase) QueueServerTimeout() *MockDatabase {
+	return m.queueFault(mockFault{err: ServerTimeoutError()})
+}
+
+func (m *MockDatabase) queueFault(fault mockFault) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.faults = append(m.faults, fault)
+	return m
+}
+
+// popFault removes and returns the error of the first queued fault matching
+// the call, if any
+func (m *MockDatabase) popFault(op, collection string) (error, bool) {
+	for i, fault := range m.faults {
+		if fault.matches(op, collection) {
+			m.faults = slices.Delete(m.faults, i, i+1)
+			return fault.err, true
+		}
+	}
+	return nil, false
+}
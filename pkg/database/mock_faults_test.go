@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMockDatabaseFaults(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		queue   func(*MockDatabase)
+		classes map[string]bool
+	}{
+		{
+			name:    "DuplicateKey",
+			queue:   func(m *MockDatabase) { m.QueueDuplicateKey("users", map[string]any{"email": "x@y"}) },
+			classes: map[string]bool{"duplicate": true},
+		},
+		{
+			name:    "TransientNetworkError",
+			queue:   func(m *MockDatabase) { m.QueueTransientNetworkError() },
+			classes: map[string]bool{"transient": true},
+		},
+		{
+			name:    "Unauthorized",
+			queue:   func(m *MockDatabase) { m.QueueUnauthorized() },
+			classes: map[string]bool{"unauthorized": true},
+		},
+		{
+			name:    "ServerTimeout",
+			queue:   func(m *MockDatabase) { m.QueueServerTimeout() },
+			classes: map[string]bool{"timeout": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockDatabase()
+			tt.queue(mock)
+
+			_, err := mock.InsertOne(ctx, "shop", "users", bson.M{"email": "x@y"})
+			got := map[string]bool{
+				"duplicate":    IsDuplicateKey(err),
+				"transient":    IsTransient(err),
+				"unauthorized": IsUnauthorized(err),
+				"timeout":      IsTimeout(err),
+			}
+			for class, is := range got {
+				if is != tt.classes[class] {
+					t.Errorf("expected %s to be %v for %v", class, tt.classes[class], err)
+				}
+			}
+
+			if _, err := mock.InsertOne(ctx, "shop", "users", bson.M{}); err != nil {
+				t.Errorf("expected the fault to be used once, got %v", err)
+			}
+		})
+	}
+
+	t.Run("DuplicateKeyMatchesTheRealShape", func(t *testing.T) {
+		err := DuplicateKeyError("users", map[string]any{"tenant": 7, "email": "x@y"})
+		var we mongo.WriteException
+		if !errors.Is(err, ErrDuplicateKey) || !errors.As(err, &we) || !mongo.IsDuplicateKeyError(err) {
+			t.Fatalf("expected ErrDuplicateKey wrapping a driver WriteException, got %v", err)
+		}
+		want := `index: email_1_tenant_1 dup key: { email: "x@y", tenant: 7 }`
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected message to contain %q, got %q", want, err.Error())
+		}
+	})
+
+	t.Run("DuplicateKeyWaitsForAWriteToTheCollection", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueDuplicateKey("users", map[string]any{"email": "x@y"})
+
+		mock.FindOne(ctx, "shop", "users", bson.M{})
+		mock.DeleteOne(ctx, "shop", "users", bson.M{})
+		if _, err := mock.InsertOne(ctx, "shop", "orders", bson.M{}); err != nil {
+			t.Errorf("expected a write to another collection to succeed, got %v", err)
+		}
+		if _, err := mock.UpdateOne(ctx, "shop", "users", bson.M{}, bson.M{}); !IsDuplicateKey(err) {
+			t.Errorf("expected the update to hit the duplicate key, got %v", err)
+		}
+	})
+
+	t.Run("TakesPriorityOverQueuesAndIsReset", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{"queued"}, nil)
+		mock.QueueServerTimeout()
+
+		if _, err := mock.Find(ctx, "shop", "users", bson.M{}); !IsTimeout(err) {
+			t.Errorf("expected the timeout first, got %v", err)
+		}
+		if history := mock.History(); history[0].Source != SourceQueue {
+			t.Errorf("expected source %q, got %q", SourceQueue, history[0].Source)
+		}
+
+		mock.QueueUnauthorized()
+		mock.Reset()
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected Reset to clear queued faults, got %v", err)
+		}
+	})
+
+	t.Run("CloseIsNotAffected", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueTransientNetworkError()
+		if err := mock.Close(ctx); err != nil {
+			t.Errorf("expected Close to succeed, got %v", err)
+		}
+		if err := mock.Ping(ctx); !IsTransient(err) {
+			t.Errorf("expected Ping to get the network error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// ErrForbiddenOperation is returned by calls to operations disabled via
+// Forbid or AllowOnly
+var ErrForbiddenOperation = errors.New("mock: forbidden operation")
+
+// readOperations lists the DatabaseInterface methods that do not modify data
+var readOperations = []string{
+	"Ping",
+	"Find",
+	"FindOne",
+	"FindCursor",
+	"Aggregate",
+	"Count",
+	"Distinct",
+}
+
+// Forbid makes every later call to the named operations fail immediately:
+// the call is recorded, the test bound via NewMockDatabaseT or Strict fails
+// with the caller's stack trace, and ErrForbiddenOperation is returned. Without
+// a bound test only the error is returned. Forbidding Ping along with every
+// other operation asserts no database interaction at all.
+func (m *MockDatabase) Forbid(ops ...string) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.forbidden == nil {
+		m.forbidden = make(map[string]bool)
+	}
+	for _, op := range ops {
+		m.forbidden[op] = true
+	}
+	return m
+}
+
+// ReadOnly returns the mock wrapped in WithReadOnly, so the code under test
+// gets ErrReadOnly for writes, which never reach the mock, while reads are
+// answered and recorded as usual
+func (m *MockDatabase) ReadOnly() DatabaseInterface {
+	return Wrap(m, WithReadOnly())
+}
+
+// AllowOnly forbids every operation except the named ones, see Forbid
+func (m *MockDatabase) AllowOnly(ops ...string) *MockDatabase {
+	allowed := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		allowed[op] = true
+	}
+	var forbid []string
+	for _, op := range append(append([]string(nil), readOperations...), writeOperations...) {
+		if !allowed[op] {
+			forbid = append(forbid, op)
+		}
+	}
+	return m.Forbid(forbid...)
+}
+
+// forbiddenCall reports a call to a forbidden operation and returns the error
+// the call fails with; the caller holds m.mu
+func (m *MockDatabase) forbiddenCall(call mockCall) error {
+	desc := call.operation
+	if call.operation != "Ping" {
+		desc = fmt.Sprintf("%s on %s filter=%s", call.operation, namespaceString(call.db, call.collection), FilterShape(call.filter))
+	}
+	err := fmt.Errorf("%w: %s", ErrForbiddenOperation, desc)
+
+	t := m.strict
+	if t == nil {
+		t = m.owner
+	}
+	if t != nil {
+		t.Errorf("%v\ncalled from:\n%s", err, callerStack())
+	}
+	return err
+}
+
+// callerStack renders the stack of the goroutine calling the mock, without
+// the mock's own frames
+func callerStack() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !isMockFrame(frame.Function) {
+			fmt.Fprintf(&b, "  %s\n    %s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+func isMockFrame(function string) bool {
+	return strings.Contains(function, "/pkg/database.(*MockDatabase)") ||
+		strings.Contains(function, "/pkg/database.invoke[") ||
+		strings.HasPrefix(function, "runtime.")
+}
+
+// AssertNoCallsTo fails the test if any of the named operations appears in
+// the call history, listing each offending call with its namespace and
+// filter shape
+func (m *MockDatabase) AssertNoCallsTo(t testing.TB, ops ...string) bool {
+	t.Helper()
+	names := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		names[op] = true
+	}
+	var calls []callSummary
+	for _, call := range m.History() {
+		if !names[call.Operation] {
+			continue
+		}
+		summary := callSummary{Operation: call.Operation, Db: call.Db, Collection: call.Collection, Chaos: call.Chaos}
+		if len(call.Args) > 0 {
+			summary.Filter = call.Args[0]
+		}
+		calls = append(calls, summary)
+	}
+	if len(calls) > 0 {
+		t.Errorf("expected no calls to %s, got %d%s", strings.Join(ops, ", "), len(calls), formatCalls(calls))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseAssertNoCallsTo(t *testing.T) {
+	t.Run("Passes", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Find(context.Background(), "testdb", "users", bson.M{})
+
+		if !mock.AssertNoCallsTo(t, "InsertOne", "UpdateOne", "DeleteOne") {
+			t.Error("expected assertion to pass")
+		}
+	})
+
+	t.Run("ListsOffendingCalls", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase()
+		mock.Find(context.Background(), "testdb", "users", bson.M{})
+		mock.DeleteOne(context.Background(), "testdb", "sessions", bson.M{"token": "secret"})
+		mock.InsertOne(context.Background(), "testdb", "audit", bson.M{"event": "login"})
+
+		if mock.AssertNoCallsTo(tb, "DeleteOne", "InsertOne") {
+			t.Fatal("expected assertion to fail")
+		}
+		msg := tb.errors[0]
+		for _, want := range []string{"got 2", "DeleteOne testdb.sessions filter={token: string}", "InsertOne testdb.audit filter={event: string}"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("expected message to contain %q, got %q", want, msg)
+			}
+		}
+		if strings.Contains(msg, "secret") || strings.Contains(msg, "Find ") {
+			t.Errorf("expected redacted message without other operations, got %q", msg)
+		}
+	})
+}
+
+func TestMockDatabaseForbid(t *testing.T) {
+	t.Run("ReturnsErrorWithoutTest", func(t *testing.T) {
+		mock := NewMockDatabase().Forbid("DeleteMany")
+		mock.QueueDeleteMany(3, nil)
+
+		_, err := mock.DeleteMany(context.Background(), "testdb", "users", bson.M{"status": "inactive"})
+		if !errors.Is(err, ErrForbiddenOperation) {
+			t.Fatalf("expected ErrForbiddenOperation, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "DeleteMany on testdb.users filter={status: string}") {
+			t.Errorf("expected namespace and filter shape in error, got %v", err)
+		}
+		if len(mock.DeleteManyQueue) != 1 {
+			t.Error("expected forbidden call not to consume the queue")
+		}
+		if history := mock.History(); len(history) != 1 || history[0].Source != SourceForbidden {
+			t.Errorf("expected the call to be recorded as forbidden, got %+v", history)
+		}
+
+		if _, err := mock.DeleteOne(context.Background(), "testdb", "users", bson.M{}); err != nil {
+			t.Errorf("expected other operations to keep working, got %v", err)
+		}
+	})
+
+	t.Run("FailsBoundTestWithCallerStack", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabaseT(tb).Forbid("Ping")
+
+		if err := mock.Ping(context.Background()); !errors.Is(err, ErrForbiddenOperation) {
+			t.Errorf("expected ErrForbiddenOperation, got %v", err)
+		}
+		if len(tb.errors) != 1 {
+			t.Fatalf("expected the test to fail once, got %v", tb.errors)
+		}
+		msg := tb.errors[0]
+		if !strings.Contains(msg, "forbidden operation: Ping") || !strings.Contains(msg, "TestMockDatabaseForbid") {
+			t.Errorf("expected operation and caller in message, got %q", msg)
+		}
+		if strings.Contains(msg, "(*MockDatabase)") {
+			t.Errorf("expected mock frames to be trimmed, got %q", msg)
+		}
+	})
+
+	t.Run("StrictTest", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase().Strict(tb).Forbid("InsertOne")
+
+		mock.InsertOne(context.Background(), "testdb", "users", bson.M{})
+		if len(tb.errors) != 1 {
+			t.Errorf("expected strict test to fail, got %v", tb.errors)
+		}
+	})
+
+	t.Run("AllowOnly", func(t *testing.T) {
+		mock := NewMockDatabase().AllowOnly("Find", "FindOne")
+
+		if _, err := mock.Find(context.Background(), "testdb", "users", bson.M{}); err != nil {
+			t.Errorf("expected Find to be allowed, got %v", err)
+		}
+		if err := mock.Ping(context.Background()); !errors.Is(err, ErrForbiddenOperation) {
+			t.Errorf("expected Ping to be forbidden, got %v", err)
+		}
+		if _, err := mock.Count(context.Background(), "testdb", "users", bson.M{}); !errors.Is(err, ErrForbiddenOperation) {
+			t.Errorf("expected Count to be forbidden, got %v", err)
+		}
+		if _, err := mock.BulkWrite(context.Background(), "testdb", "users", nil); !errors.Is(err, ErrForbiddenOperation) {
+			t.Errorf("expected BulkWrite to be forbidden, got %v", err)
+		}
+	})
+
+	t.Run("ResetAllClears", func(t *testing.T) {
+		mock := NewMockDatabase().Forbid("Find")
+		mock.Reset()
+		if _, err := mock.Find(context.Background(), "testdb", "users", bson.M{}); err == nil {
+			t.Error("expected Reset to keep forbidden operations")
+		}
+		mock.ResetAll()
+		if _, err := mock.Find(context.Background(), "testdb", "users", bson.M{}); err != nil {
+			t.Errorf("expected ResetAll to clear forbidden operations, got %v", err)
+		}
+	})
+}
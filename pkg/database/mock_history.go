@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Response sources recorded in Call.Source
+const (
+	SourceQueue       = "queue"
+	SourceExpectation = "expectation"
+	SourceDefault     = "default"
+	SourceChaos       = "chaos"
+	SourceHandler     = "handler"
+	SourceContext     = "context"
+	SourceForbidden   = "forbidden"
+	SourceScript      = "script"
+	SourceClosed      = "closed"
+	SourceInvalid     = "invalid"
+)
+
+// Call is the operation-agnostic record of a mock call kept in History. Args
+// holds the operation's arguments after db and collection, e.g. the filter
+// and update for UpdateOne, without the options. Source tells what answered
+// the call: a queued response, a script step, a scoped expectation, a
+// namespace default, chaos mode, the XFunc handler, an already done context,
+// a forbidden operation, a client closed with FailAfterClose enabled, or an
+// argument rejected as an invalid ByID filter, by ValidateBSON or as an
+// invalid per-call override. CallOptions holds the per-call overrides of
+// Ctx, see WithReadPreference; the XCall records carry them too.
+type Call struct {
+	Seq         int64
+	Time        time.Time
+	Ctx         context.Context
+	Operation   string
+	Db          string
+	Collection  string
+	Args        []any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+	Source      string
+}
+
+// History returns a snapshot of every call made to the mock across all
+// operations, in call order. Seq increases by one per call, so tests can
+// assert that one call happened before another.
+func (m *MockDatabase) History() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call(nil), m.history...)
+}
+
+// recordHistory appends the call just recorded in the XCalls slice for op to
+// the history; the caller holds m.mu
+func (m *MockDatabase) recordHistory(op string, source string) {
+	calls := reflect.ValueOf(m).Elem().FieldByName(op + "Calls")
+	if !calls.IsValid() || calls.Len() == 0 {
+		return
+	}
+	last := calls.Index(calls.Len() - 1)
+
+	m.seq++
+	call := Call{Seq: m.seq, Time: time.Now(), Operation: op, Source: source}
+	for i := 0; i < last.NumField(); i++ {
+		field := last.Type().Field(i).Name
+		value := last.Field(i)
+		switch field {
+		case "Cursor", "Options", "Stages", "Err", "Committed":
+		case "CallOptions":
+			// Set from Ctx below
+		case "Ctx":
+			call.Ctx, _ = value.Interface().(context.Context)
+		case "Db":
+			call.Db = value.String()
+		case "Collection":
+			call.Collection = value.String()
+		case "Opts":
+			call.Opts = value.Interface().([]any)
+		case "Chaos":
+			call.Chaos = value.Bool()
+		default:
+			call.Args = append(call.Args, value.Interface())
+		}
+	}
+	call.CallOptions = CallOptionsFrom(call.Ctx)
+	if field := last.FieldByName("CallOptions"); field.IsValid() {
+		field.Set(reflect.ValueOf(call.CallOptions))
+	}
+	m.history = append(m.history, call)
+	m.callsChangedCond().Broadcast()
+}
+
+// maxHistoryArg is the length beyond which HistoryJSON truncates an argument
+const maxHistoryArg = 512
+
+// historyEntry is the JSON form of a Call
+type historyEntry struct {
+	Seq       int64    `json:"seq"`
+	Time      string   `json:"time"`
+	Operation string   `json:"operation"`
+	Namespace string   `json:"namespace,omitempty"`
+	Args      []string `json:"args,omitempty"`
+	Opts      []string `json:"opts,omitempty"`
+	Context   string   `json:"context"`
+	Source    string   `json:"source"`
+}
+
+// HistoryJSON renders History as an indented JSON array for debugging. Filters
+// and documents are redacted to their shape (see FilterShape) and truncated
+// when long, options are summarized by their set fields and contexts are
+// replaced by their deadline.
+func (m *MockDatabase) HistoryJSON() ([]byte, error) {
+	history := m.History()
+	entries := make([]historyEntry, len(history))
+	for i, call := range history {
+		entry := historyEntry{
+			Seq:       call.Seq,
+			Time:      call.Time.Format(time.RFC3339Nano),
+			Operation: call.Operation,
+			Context:   contextMarker(call.Ctx),
+			Source:    call.Source,
+		}
+		if call.Db != "" || call.Collection != "" {
+			entry.Namespace = call.Db + "." + call.Collection
+		}
+		for _, arg := range call.Args {
+			entry.Args = append(entry.Args, truncateHistoryArg(FilterShape(arg)))
+		}
+		for _, opt := range call.Opts {
+			entry.Opts = append(entry.Opts, truncateHistoryArg(optionSummary(opt)))
+		}
+		entries[i] = entry
+	}
+	return json.MarshalIndent(entries, "", "  ")
+}
+
+// DumpHistory logs HistoryJSON via t.Log when the test fails
+func (m *MockDatabase) DumpHistory(t testing.TB) {
+	t.Helper()
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		out, err := m.HistoryJSON()
+		if err != nil {
+			t.Logf("mock history unavailable: %v", err)
+			return
+		}
+		t.Logf("mock call history:\n%s", out)
+	})
+}
+
+func contextMarker(ctx context.Context) string {
+	if ctx == nil {
+		return "none"
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		return "deadline " + deadline.Format(time.RFC3339Nano)
+	}
+	return "no deadline"
+}
+
+// optionSummary renders a driver options struct as its type and set fields,
+// e.g. FindOptions{Limit=20}
+func optionSummary(opt any) string {
+	rv := reflect.ValueOf(opt)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Sprintf("%T", opt)
+	}
+	rv = rv.Elem()
+	var fields []string
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Field(i)
+		if !rv.Type().Field(i).IsExported() || field.IsZero() {
+			continue
+		}
+		if field.Kind() == reflect.Pointer {
+			field = field.Elem()
+		}
+		fields = append(fields, fmt.Sprintf("%s=%v", rv.Type().Field(i).Name, field.Interface()))
+	}
+	return rv.Type().Name() + "{" + strings.Join(fields, ", ") + "}"
+}
+
+func truncateHistoryArg(s string) string {
+	if len(s) <= maxHistoryArg {
+		return s
+	}
+	return fmt.Sprintf("%s... (truncated, %d bytes)", s[:maxHistoryArg], len(s))
+}
+
+// lastCall returns a copy of the last element of calls, read under m.mu
+func lastCall[T any](m *MockDatabase, calls *[]T) (T, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var zero T
+	if len(*calls) == 0 {
+		return zero, false
+	}
+	return (*calls)[len(*calls)-1], true
+}
+
+// LastPingCall returns the most recent Ping call, if any
+func (m *MockDatabase) LastPingCall() (PingCall, bool) {
+	return lastCall(m, &m.PingCalls)
+}
+
+// LastCloseCall returns the most recent Close call, if any
+func (m *MockDatabase) LastCloseCall() (CloseCall, bool) {
+	return lastCall(m, &m.CloseCalls)
+}
+
+// LastFindCall returns the most recent Find call, if any
+func (m *MockDatabase) LastFindCall() (FindCall, bool) {
+	return lastCall(m, &m.FindCalls)
+}
+
+// LastFindOneCall returns the most recent FindOne call, if any
+func (m *MockDatabase) LastFindOneCall() (FindOneCall, bool) {
+	return lastCall(m, &m.FindOneCalls)
+}
+
+// LastFindCursorCall returns the most recent FindCursor call, if any
+func (m *MockDatabase) LastFindCursorCall() (FindCursorCall, bool) {
+	return lastCall(m, &m.FindCursorCalls)
+}
+
+// LastAggregateCall returns the most recent Aggregate call, if any
+func (m *MockDatabase) LastAggregateCall() (AggregateCall, bool) {
+	return lastCall(m, &m.AggregateCalls)
+}
+
+// LastCountCall returns the most recent Count call, if any
+func (m *MockDatabase) LastCountCall() (CountCall, bool) {
+	return lastCall(m, &m.CountCalls)
+}
+
+// LastDistinctCall returns the most recent Distinct call, if any
+func (m *MockDatabase) LastDistinctCall() (DistinctCall, bool) {
+	return lastCall(m, &m.DistinctCalls)
+}
+
+// LastInsertOneCall returns the most recent InsertOne call, if any
+func (m *MockDatabase) LastInsertOneCall() (InsertOneCall, bool) {
+	return lastCall(m, &m.InsertOneCalls)
+}
+
+// LastInsertManyCall returns the most recent InsertMany call, if any
+func (m *MockDatabase) LastInsertManyCall() (InsertManyCall, bool) {
+	return lastCall(m, &m.InsertManyCalls)
+}
+
+// LastUpdateOneCall returns the most recent UpdateOne call, if any
+func (m *MockDatabase) LastUpdateOneCall() (UpdateOneCall, bool) {
+	return lastCall(m, &m.UpdateOneCalls)
+}
+
+// LastUpdateManyCall returns the most recent UpdateMany call, if any
+func (m *MockDatabase) LastUpdateManyCall() (UpdateManyCall, bool) {
+	return lastCall(m, &m.UpdateManyCalls)
+}
+
+// LastReplaceOneCall returns the most recent ReplaceOne call, if any
+func (m *MockDatabase) LastReplaceOneCall() (ReplaceOneCall, bool) {
+	return lastCall(m, &m.ReplaceOneCalls)
+}
+
+// LastDeleteOneCall returns the most recent DeleteOne call, if any
+func (m *MockDatabase) LastDeleteOneCall() (DeleteOneCall, bool) {
+	return lastCall(m, &m.DeleteOneCalls)
+}
+
+// LastDeleteManyCall returns the most recent DeleteMany call, if any
+func (m *MockDatabase) LastDeleteManyCall() (DeleteManyCall, bool) {
+	return lastCall(m, &m.DeleteManyCalls)
+}
+
+// LastFindOneAndUpdateCall returns the most recent FindOneAndUpdate call, if any
+func (m *MockDatabase) LastFindOneAndUpdateCall() (FindOneAndUpdateCall, bool) {
+	return lastCall(m, &m.FindOneAndUpdateCalls)
+}
+
+// LastBulkWriteCall returns the most recent BulkWrite call, if any
+func (m *MockDatabase) LastBulkWriteCall() (BulkWriteCall, bool) {
+	return lastCall(m, &m.BulkWriteCalls)
+}
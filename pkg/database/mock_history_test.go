@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMockDatabaseHistory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("LastCall", func(t *testing.T) {
+		mock := NewMockDatabase()
+		if _, ok := mock.LastFindCall(); ok {
+			t.Error("expected no last call before any call")
+		}
+
+		mock.Find(ctx, "testdb", "users", bson.M{"n": 1})
+		mock.Find(ctx, "testdb", "users", bson.M{"n": 2})
+
+		call, ok := mock.LastFindCall()
+		if !ok || call.Filter.(bson.M)["n"] != 2 {
+			t.Errorf("expected the second Find call, got %+v", call)
+		}
+	})
+
+	t.Run("OrderAcrossOperations", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Find(ctx, "testdb", "users", bson.M{"status": "new"})
+		mock.UpdateOne(ctx, "testdb", "users", bson.M{"_id": 1}, bson.M{"$set": bson.M{"status": "seen"}})
+		mock.Ping(ctx)
+
+		history := mock.History()
+		if len(history) != 3 {
+			t.Fatalf("expected 3 calls, got %d", len(history))
+		}
+		ops := []string{history[0].Operation, history[1].Operation, history[2].Operation}
+		if !valuesEqual(ops, []string{"Find", "UpdateOne", "Ping"}) {
+			t.Errorf("expected calls in order, got %v", ops)
+		}
+		if history[0].Seq >= history[1].Seq || history[1].Time.Before(history[0].Time) {
+			t.Error("expected Seq and Time to increase")
+		}
+		update := history[1]
+		if update.Db != "testdb" || update.Collection != "users" || len(update.Args) != 2 {
+			t.Errorf("expected namespace and filter plus update args, got %+v", update)
+		}
+	})
+
+	t.Run("SnapshotAndReset", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Ping(ctx)
+
+		snapshot := mock.History()
+		mock.Ping(ctx)
+		if len(snapshot) != 1 {
+			t.Errorf("expected the snapshot not to change, got %d calls", len(snapshot))
+		}
+
+		mock.ResetCalls()
+		if len(mock.History()) != 0 {
+			t.Error("expected ResetCalls to clear the history")
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		mock := NewMockDatabase()
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mock.Count(ctx, "testdb", "users", bson.M{})
+				mock.LastCountCall()
+				mock.History()
+			}()
+		}
+		wg.Wait()
+
+		seen := map[int64]bool{}
+		for _, call := range mock.History() {
+			seen[call.Seq] = true
+		}
+		if len(seen) != 20 {
+			t.Errorf("expected 20 distinct sequence numbers, got %d", len(seen))
+		}
+	})
+}
+
+func TestMockDatabaseHistoryJSON(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Sources", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{}, nil)
+		mock.OnFindOne("testdb", "users").Return(map[string]any{}, nil)
+		mock.SetDefaultCount("testdb", "users", 1, nil)
+
+		mock.Find(ctx, "testdb", "users", bson.M{"email": "alice@example.com"}, moptions.Find().SetLimit(20))
+		mock.FindOne(ctx, "testdb", "users", bson.M{})
+		mock.Count(ctx, "testdb", "users", bson.M{})
+		mock.EnableChaos(ChaosConfig{Seed: 1, FailureRate: 1})
+		mock.Ping(ctx)
+		mock.DisableChaos()
+		deadline, cancel := context.WithTimeout(ctx, time.Hour)
+		defer cancel()
+		mock.InsertOne(deadline, "testdb", "users", bson.M{})
+
+		out, err := mock.HistoryJSON()
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		var entries []map[string]any
+		if err := json.Unmarshal(out, &entries); err != nil {
+			t.Fatalf("expected a JSON array, got %s", out)
+		}
+		var sources []any
+		for _, e := range entries {
+			sources = append(sources, e["source"])
+		}
+		if !valuesEqual(sources, []any{"queue", "expectation", "default", "chaos", "handler"}) {
+			t.Errorf("unexpected sources %v", sources)
+		}
+
+		find := entries[0]
+		if find["namespace"] != "testdb.users" || find["context"] != "no deadline" {
+			t.Errorf("unexpected entry %v", find)
+		}
+		if args := find["args"].([]any); args[0] != "{email: string}" {
+			t.Errorf("expected the filter shape, got %v", args)
+		}
+		if opts := find["opts"].([]any); opts[0] != "FindOptions{Limit=20}" {
+			t.Errorf("expected the options summary, got %v", opts)
+		}
+		if c := entries[4]["context"].(string); !strings.HasPrefix(c, "deadline ") {
+			t.Errorf("expected a deadline marker, got %s", c)
+		}
+	})
+
+	t.Run("TruncatesLargeArgs", func(t *testing.T) {
+		mock := NewMockDatabase()
+		filter := bson.M{}
+		for i := 0; i < 100; i++ {
+			filter[fmt.Sprintf("field%03d", i)] = i
+		}
+		mock.Find(ctx, "testdb", "users", filter)
+
+		out, _ := mock.HistoryJSON()
+		if !strings.Contains(string(out), "(truncated, ") {
+			t.Errorf("expected a truncation note, got %s", out)
+		}
+	})
+
+	t.Run("DumpHistoryOnFailure", func(t *testing.T) {
+		mock := NewMockDatabase()
+		passing := &recordingTB{}
+		mock.DumpHistory(passing)
+		mock.Ping(ctx)
+		passing.runCleanups()
+		if len(passing.logs) != 0 {
+			t.Errorf("expected nothing logged for a passing test, got %v", passing.logs)
+		}
+
+		failing := &recordingTB{}
+		mock.DumpHistory(failing)
+		failing.Errorf("boom")
+		failing.runCleanups()
+		if len(failing.logs) != 1 || !strings.Contains(failing.logs[0], `"operation": "Ping"`) {
+			t.Errorf("expected the history to be logged, got %v", failing.logs)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// OnCall registers an observer invoked synchronously on the calling goroutine
+// for every call, in registration order. It runs after the call is recorded in
+// History and its source is chosen, but before any simulated delay and before
+// the response is returned, so it can advance a TestClock, queue responses for
+// later calls or trigger concurrent work. Observers run without the mock's
+// lock held and may call the mock. A panic in an observer is not swallowed:
+// it propagates to the caller of the mock, naming the call it observed.
+func (m *MockDatabase) OnCall(observer func(call Call)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observers = append(m.observers, observer)
+	return m
+}
+
+// OnCallAsync registers an observer invoked on a new goroutine for every call,
+// without waiting for it. A panicking observer crashes the test binary.
+func (m *MockDatabase) OnCallAsync(observer func(call Call)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.asyncObservers = append(m.asyncObservers, observer)
+	return m
+}
+
+// callObservers is a recorded call together with the observers to notify
+type callObservers struct {
+	call  Call
+	sync  []func(Call)
+	async []func(Call)
+}
+
+// pendingObservers captures the call just recorded in the history and the
+// registered observers; the caller holds m.mu
+func (m *MockDatabase) pendingObservers() callObservers {
+	if len(m.history) == 0 || (len(m.observers) == 0 && len(m.asyncObservers) == 0) {
+		return callObservers{}
+	}
+	return callObservers{
+		call:  m.history[len(m.history)-1],
+		sync:  slices.Clone(m.observers),
+		async: slices.Clone(m.asyncObservers),
+	}
+}
+
+// notify starts the async observers and runs the sync ones in order; the
+// caller must not hold m.mu
+func (o callObservers) notify() {
+	for _, observer := range o.async {
+		go o.run(observer)
+	}
+	for _, observer := range o.sync {
+		o.run(observer)
+	}
+}
+
+func (o callObservers) run(observer func(Call)) {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(fmt.Sprintf("mock: OnCall observer panicked on call #%d %s: %v", o.call.Seq, o.call.Operation, r))
+		}
+	}()
+	observer(o.call)
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseOnCall(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SeesEverySourceInOrder", func(t *testing.T) {
+		mock := NewMockDatabase()
+		var seen []string
+		mock.OnCall(func(call Call) {
+			seen = append(seen, fmt.Sprintf("%d:%s:%s", call.Seq, call.Operation, call.Source))
+		})
+		mock.QueueFind([]any{}, nil)
+		mock.OnFindOne("shop", "users").Return("alice", nil)
+		mock.EnableChaos(ChaosConfig{Seed: 1, FailureRate: 1, PerOp: map[string]float64{"Ping": 0}})
+
+		mock.Find(ctx, "shop", "users", bson.M{})
+		mock.FindOne(ctx, "shop", "users", bson.M{})
+		mock.Count(ctx, "shop", "users", bson.M{})
+		mock.Ping(ctx)
+
+		want := []string{"1:Find:queue", "2:FindOne:expectation", "3:Count:chaos", "4:Ping:handler"}
+		if strings.Join(seen, " ") != strings.Join(want, " ") {
+			t.Errorf("expected %v, got %v", want, seen)
+		}
+	})
+
+	t.Run("ObserversRunInRegistrationOrderAfterRecording", func(t *testing.T) {
+		mock := NewMockDatabase()
+		var order []string
+		mock.OnCall(func(call Call) {
+			order = append(order, fmt.Sprintf("first saw %d FindCalls", len(mock.FindCalls)))
+		})
+		mock.OnCall(func(call Call) {
+			order = append(order, "second")
+		})
+		mock.FindFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+			order = append(order, "handler")
+			return []any{}, nil
+		}
+
+		mock.Find(ctx, "shop", "users", bson.M{})
+		want := "first saw 1 FindCalls, second, handler"
+		if got := strings.Join(order, ", "); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("RunsBeforeDelay", func(t *testing.T) {
+		clock := NewTestClock(time.Unix(0, 0))
+		mock := NewMockDatabase().WithDelay("Find", 20*time.Millisecond)
+		var observedAt time.Time
+		mock.OnCall(func(call Call) {
+			observedAt = time.Now()
+			clock.Advance(time.Minute)
+		})
+
+		start := time.Now()
+		mock.Find(ctx, "shop", "users", bson.M{})
+		if observedAt.Sub(start) >= 20*time.Millisecond {
+			t.Errorf("expected observer to run before the delay, ran after %v", observedAt.Sub(start))
+		}
+		if !clock.Now().Equal(time.Unix(60, 0)) {
+			t.Errorf("expected observer to advance the clock, got %v", clock.Now())
+		}
+	})
+
+	t.Run("MayCallTheMock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnCall(func(call Call) {
+			if call.Operation == "InsertOne" {
+				// A concurrent writer changes the data seen by the next read
+				mock.QueueFind([]any{"inserted elsewhere"}, nil)
+			}
+		})
+
+		mock.InsertOne(ctx, "shop", "users", bson.M{})
+		result, _ := mock.Find(ctx, "shop", "users", bson.M{})
+		if len(result.([]any)) != 1 {
+			t.Errorf("expected the response queued by the observer, got %v", result)
+		}
+	})
+
+	t.Run("ObservesForbiddenAndCancelledCalls", func(t *testing.T) {
+		mock := NewMockDatabase().Forbid("DeleteMany")
+		var sources []string
+		mock.OnCall(func(call Call) { sources = append(sources, call.Source) })
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		mock.Find(cancelled, "shop", "users", bson.M{})
+		mock.DeleteMany(ctx, "shop", "users", bson.M{})
+
+		if strings.Join(sources, ",") != SourceContext+","+SourceForbidden {
+			t.Errorf("expected context and forbidden sources, got %v", sources)
+		}
+	})
+
+	t.Run("PanicPropagates", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnCall(func(call Call) { panic("observer bug") })
+
+		defer func() {
+			r := recover()
+			if r == nil || !strings.Contains(fmt.Sprint(r), "observer bug") || !strings.Contains(fmt.Sprint(r), "#1 Ping") {
+				t.Errorf("expected the observer panic to propagate, got %v", r)
+			}
+			// The mock's lock must not be held after the panic
+			mock.ResetAll()
+			if err := mock.Ping(ctx); err != nil {
+				t.Errorf("expected the mock to stay usable, got %v", err)
+			}
+		}()
+		mock.Ping(ctx)
+	})
+
+	t.Run("Async", func(t *testing.T) {
+		mock := NewMockDatabase()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		var mu sync.Mutex
+		var ops []string
+		mock.OnCallAsync(func(call Call) {
+			defer wg.Done()
+			mu.Lock()
+			ops = append(ops, call.Operation)
+			mu.Unlock()
+		})
+
+		mock.Find(ctx, "shop", "users", bson.M{})
+		mock.Count(ctx, "shop", "users", bson.M{})
+		wg.Wait()
+		if len(ops) != 2 {
+			t.Errorf("expected both calls to be observed, got %v", ops)
+		}
+	})
+
+	t.Run("ResetAllClears", func(t *testing.T) {
+		mock := NewMockDatabase()
+		calls := 0
+		mock.OnCall(func(call Call) { calls++ })
+		mock.Reset()
+		mock.Ping(ctx)
+		mock.ResetAll()
+		mock.Ping(ctx)
+		if calls != 1 {
+			t.Errorf("expected observers to survive Reset but not ResetAll, got %d calls", calls)
+		}
+	})
+}
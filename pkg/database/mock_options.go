@@ -0,0 +1,126 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryOptions is the query shape parsed from the driver options passed to a
+// read call. Later options override earlier ones, as in the driver. Options
+// that are not driver options are listed in Warnings instead of failing the call.
+type QueryOptions struct {
+	Limit      *int64
+	Skip       *int64
+	Sort       any
+	Projection any
+	Warnings   []string
+}
+
+// OptionsMatcher reports whether the parsed options of a call satisfy an
+// expectation. Any func(QueryOptions) bool can be used directly.
+type OptionsMatcher func(opts QueryOptions) bool
+
+// OptsHaveLimit matches calls whose limit is n
+func OptsHaveLimit(n int64) OptionsMatcher {
+	return func(opts QueryOptions) bool {
+		return opts.Limit != nil && *opts.Limit == n
+	}
+}
+
+// OptsHaveSkip matches calls whose skip is n
+func OptsHaveSkip(n int64) OptionsMatcher {
+	return func(opts QueryOptions) bool {
+		return opts.Skip != nil && *opts.Skip == n
+	}
+}
+
+// OptsSortedBy matches calls sorted by exactly the given fields in order.
+// A leading "-" marks a descending field, so "-created_at" is {created_at: -1}.
+func OptsSortedBy(fields ...string) OptionsMatcher {
+	want := make([]sortKey, len(fields))
+	for i, field := range fields {
+		want[i] = sortKey{path: field, direction: 1}
+		if len(field) > 1 && field[0] == '-' {
+			want[i] = sortKey{path: field[1:], direction: -1}
+		}
+	}
+	return func(opts QueryOptions) bool {
+		keys, err := sortKeys(opts.Sort)
+		if err != nil || len(keys) != len(want) {
+			return false
+		}
+		for i := range keys {
+			if keys[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// OptsProjectionIncludes matches calls whose projection includes every field
+func OptsProjectionIncludes(fields ...string) OptionsMatcher {
+	return func(opts QueryOptions) bool {
+		projection, ok := normalizeDocument(opts.Projection).(map[string]any)
+		if !ok {
+			return false
+		}
+		for _, field := range fields {
+			include, ok := projectionFlag(projection[field])
+			if !ok || !include {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// parseQueryOptions collects limit, skip, sort and projection from the find,
+// count and find-and-modify options in opts
+func parseQueryOptions(opts []any) QueryOptions {
+	var out QueryOptions
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case nil:
+		case *moptions.FindOptions:
+			out.set(o.Limit, o.Skip, o.Sort, o.Projection)
+		case *moptions.FindOneOptions:
+			out.set(nil, o.Skip, o.Sort, o.Projection)
+		case *moptions.CountOptions:
+			out.set(o.Limit, o.Skip, nil, nil)
+		case *moptions.FindOneAndUpdateOptions:
+			out.set(nil, nil, o.Sort, o.Projection)
+		default:
+			if !isDriverOption(opt) {
+				out.Warnings = append(out.Warnings, fmt.Sprintf("unrecognised option type %T ignored", opt))
+			}
+		}
+	}
+	return out
+}
+
+func (q *QueryOptions) set(limit *int64, skip *int64, sort any, projection any) {
+	if limit != nil {
+		q.Limit = limit
+	}
+	if skip != nil {
+		q.Skip = skip
+	}
+	if sort != nil {
+		q.Sort = sort
+	}
+	if projection != nil {
+		q.Projection = projection
+	}
+}
+
+var driverOptionsPackage = reflect.TypeOf(moptions.FindOptions{}).PkgPath()
+
+// isDriverOption reports whether opt is a pointer to a mongo driver options
+// struct, which carries no query shape but is still valid input
+func isDriverOption(opt any) bool {
+	t := reflect.TypeOf(opt)
+	return t.Kind() == reflect.Pointer && t.Elem().PkgPath() == driverOptionsPackage
+}
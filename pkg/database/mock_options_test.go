@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestOptionsMatchers(t *testing.T) {
+	opts := parseQueryOptions([]any{
+		moptions.Find().
+			SetLimit(20).
+			SetSkip(40).
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "name", Value: 1}}).
+			SetProjection(bson.M{"name": 1, "email": true}),
+	})
+
+	tests := []struct {
+		name    string
+		matcher OptionsMatcher
+		want    bool
+	}{
+		{"LimitEqual", OptsHaveLimit(20), true},
+		{"LimitDiffers", OptsHaveLimit(10), false},
+		{"SkipEqual", OptsHaveSkip(40), true},
+		{"SkipDiffers", OptsHaveSkip(0), false},
+		{"SortedBy", OptsSortedBy("-created_at", "name"), true},
+		{"SortedByWrongDirection", OptsSortedBy("created_at", "name"), false},
+		{"SortedByPrefixOnly", OptsSortedBy("-created_at"), false},
+		{"ProjectionIncludes", OptsProjectionIncludes("name", "email"), true},
+		{"ProjectionMissingField", OptsProjectionIncludes("password"), false},
+		{"Func", func(o QueryOptions) bool { return o.Limit != nil && *o.Limit > 10 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher(opts); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("NoOptions", func(t *testing.T) {
+		empty := parseQueryOptions(nil)
+		if OptsHaveLimit(0)(empty) || OptsSortedBy("name")(empty) || OptsProjectionIncludes("name")(empty) {
+			t.Error("expected matchers to reject a call without options")
+		}
+		if !OptsSortedBy()(empty) {
+			t.Error("expected OptsSortedBy() to match an unsorted call")
+		}
+	})
+}
+
+func TestParseQueryOptions(t *testing.T) {
+	t.Run("LaterOptionsOverride", func(t *testing.T) {
+		opts := parseQueryOptions([]any{moptions.Find().SetLimit(5), nil, moptions.Find().SetLimit(10).SetSkip(2)})
+		if *opts.Limit != 10 || *opts.Skip != 2 {
+			t.Errorf("expected limit 10 skip 2, got %v %v", *opts.Limit, *opts.Skip)
+		}
+	})
+
+	t.Run("FindOneAndCount", func(t *testing.T) {
+		opts := parseQueryOptions([]any{moptions.FindOne().SetSort(bson.M{"age": 1}), moptions.Count().SetLimit(3)})
+		if !OptsSortedBy("age")(opts) || !OptsHaveLimit(3)(opts) {
+			t.Errorf("expected sort and limit to be parsed, got %+v", opts)
+		}
+	})
+
+	t.Run("UnrecognisedOptionWarns", func(t *testing.T) {
+		opts := parseQueryOptions([]any{moptions.Update().SetUpsert(true), "limit=10"})
+		if len(opts.Warnings) != 1 || !strings.Contains(opts.Warnings[0], "string") {
+			t.Errorf("expected one warning for the string option, got %v", opts.Warnings)
+		}
+	})
+}
+
+func TestMockDatabaseWithOptions(t *testing.T) {
+	t.Run("MatchesOnOptions", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFind("db", "events").
+			WithOptions(OptsHaveLimit(20), OptsSortedBy("-created_at")).
+			Return([]any{"latest"}, nil)
+
+		opts := moptions.Find().SetLimit(20).SetSort(bson.M{"created_at": -1})
+		result, err := mock.Find(context.Background(), "db", "events", bson.M{}, opts)
+		if err != nil || len(result.([]any)) != 1 {
+			t.Errorf("expected expectation result, got %v, %v", result, err)
+		}
+
+		result, _ = mock.Find(context.Background(), "db", "events", bson.M{}, moptions.Find().SetLimit(50))
+		if len(result.([]any)) != 0 {
+			t.Errorf("expected other options to fall through to the default, got %v", result)
+		}
+	})
+
+	t.Run("OptionMismatchIsNearMiss", func(t *testing.T) {
+		mock := NewMockDatabase().RecordNearMisses(true)
+		mock.OnFind("db", "events").WithOptions(OptsHaveLimit(20)).Return([]any{}, nil)
+
+		mock.Find(context.Background(), "db", "events", bson.M{})
+		if len(mock.NearMisses) != 1 {
+			t.Errorf("expected one near miss, got %d", len(mock.NearMisses))
+		}
+	})
+
+	t.Run("CallExposesParsedOptions", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Find(context.Background(), "db", "events", bson.M{}, moptions.Find().SetLimit(20), 42)
+
+		call, _ := mock.LastFindCall()
+		if call.Options.Limit == nil || *call.Options.Limit != 20 {
+			t.Errorf("expected parsed limit 20, got %+v", call.Options)
+		}
+		if len(call.Options.Warnings) != 1 || !strings.Contains(call.Options.Warnings[0], "int") {
+			t.Errorf("expected a warning for the int option, got %v", call.Options.Warnings)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// responder adapts a typed Responder to the untyped form invoke calls
+func responder[C any, R any](fn func(call C) (R, error)) func(call any) (R, error) {
+	if fn == nil {
+		return nil
+	}
+	return func(call any) (R, error) {
+		return fn(call.(C))
+	}
+}
+
+// lastRecordedCall returns the XCall just recorded for op; the caller holds m.mu
+func (m *MockDatabase) lastRecordedCall(op string) any {
+	calls := reflect.ValueOf(m).Elem().FieldByName(op + "Calls")
+	if !calls.IsValid() || calls.Len() == 0 {
+		return nil
+	}
+	return calls.Index(calls.Len() - 1).Interface()
+}
+
+// runResponder calls respond with the recorded call. A panicking responder
+// fails the test bound via NewMockDatabaseT or Strict, with the call attached,
+// and its call returns an error; without a bound test the panic propagates
+// with the call attached.
+func runResponder[R any](m *MockDatabase, op string, recorded any, respond func(call any) (R, error)) (result R, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		msg := fmt.Sprintf("mock: %s responder panicked: %v\ncall: %+v", op, r, recorded)
+		t := m.boundTest()
+		if t == nil {
+			panic(msg)
+		}
+		t.Errorf("%s", msg)
+		var zero R
+		result, err = zero, fmt.Errorf("mock: %s responder panicked: %v", op, r)
+	}()
+	return respond(recorded)
+}
+
+// boundTest returns the test failures are reported to, if any
+func (m *MockDatabase) boundTest() testing.TB {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.strict != nil {
+		return m.strict
+	}
+	return m.owner
+}
+
+// ReturnFunc makes the expectation compute its result from each matching
+// call. fn must be a func(XCall) (R, error) for the expectation's operation,
+// e.g. func(call FindOneCall) (any, error) for OnFindOne; anything else
+// panics. It replaces the result set by Return.
+func (e *Expectation) ReturnFunc(fn any) *Expectation {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.In(0).Name() != e.Operation+"Call" ||
+		t.NumOut() != 2 || t.Out(1) != reflect.TypeOf((*error)(nil)).Elem() {
+		panic(fmt.Sprintf("mock: ReturnFunc for %s needs a func(%sCall) (result, error), got %T", e.Operation, e.Operation, fn))
+	}
+	e.responder = v
+	return e
+}
+
+// expectationResponder returns the ReturnFunc of e, adapted like
+// expectationResult, or nil if it has none
+func expectationResponder[R any](e *Expectation) func(call any) (R, error) {
+	if !e.responder.IsValid() {
+		return nil
+	}
+	return func(call any) (R, error) {
+		out := e.responder.Call([]reflect.Value{reflect.ValueOf(call)})
+		err, _ := out[1].Interface().(error)
+		return convertResult[R](e, out[0].Interface(), err)
+	}
+}
+
+// QueueFindFunc adds a Find response computed by fn from the recorded call
+func (m *MockDatabase) QueueFindFunc(fn func(call FindCall) (any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindQueue = append(m.FindQueue, FindResponse{Responder: fn})
+	return m
+}
+
+// QueueFindOneFunc adds a FindOne response computed by fn from the recorded call
+func (m *MockDatabase) QueueFindOneFunc(fn func(call FindOneCall) (any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneQueue = append(m.FindOneQueue, FindOneResponse{Responder: fn})
+	return m
+}
+
+// QueueFindCursorFunc adds a FindCursor response computed by fn from the recorded call
+func (m *MockDatabase) QueueFindCursorFunc(fn func(call FindCursorCall) (Cursor, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindCursorQueue = append(m.FindCursorQueue, FindCursorResponse{Responder: fn})
+	return m
+}
+
+// QueueAggregateFunc adds a Aggregate response computed by fn from the recorded call
+func (m *MockDatabase) QueueAggregateFunc(fn func(call AggregateCall) (any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.AggregateQueue = append(m.AggregateQueue, AggregateResponse{Responder: fn})
+	return m
+}
+
+// QueueCountFunc adds a Count response computed by fn from the recorded call
+func (m *MockDatabase) QueueCountFunc(fn func(call CountCall) (int64, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CountQueue = append(m.CountQueue, CountResponse{Responder: fn})
+	return m
+}
+
+// QueueDistinctFunc adds a Distinct response computed by fn from the recorded call
+func (m *MockDatabase) QueueDistinctFunc(fn func(call DistinctCall) ([]any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DistinctQueue = append(m.DistinctQueue, DistinctResponse{Responder: fn})
+	return m
+}
+
+// QueueInsertOneFunc adds a InsertOne response computed by fn from the recorded call
+func (m *MockDatabase) QueueInsertOneFunc(fn func(call InsertOneCall) (any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertOneQueue = append(m.InsertOneQueue, InsertOneResponse{Responder: fn})
+	return m
+}
+
+// QueueInsertManyFunc adds a InsertMany response computed by fn from the recorded call
+func (m *MockDatabase) QueueInsertManyFunc(fn func(call InsertManyCall) ([]any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertManyQueue = append(m.InsertManyQueue, InsertManyResponse{Responder: fn})
+	return m
+}
+
+// QueueUpdateOneFunc adds a UpdateOne response computed by fn from the recorded call
+func (m *MockDatabase) QueueUpdateOneFunc(fn func(call UpdateOneCall) (*UpdateResult, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateOneQueue = append(m.UpdateOneQueue, UpdateOneResponse{Responder: fn})
+	return m
+}
+
+// QueueUpdateManyFunc adds a UpdateMany response computed by fn from the recorded call
+func (m *MockDatabase) QueueUpdateManyFunc(fn func(call UpdateManyCall) (*UpdateResult, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateManyQueue = append(m.UpdateManyQueue, UpdateManyResponse{Responder: fn})
+	return m
+}
+
+// QueueReplaceOneFunc adds a ReplaceOne response computed by fn from the recorded call
+func (m *MockDatabase) QueueReplaceOneFunc(fn func(call ReplaceOneCall) (*UpdateResult, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ReplaceOneQueue = append(m.ReplaceOneQueue, ReplaceOneResponse{Responder: fn})
+	return m
+}
+
+// QueueDeleteOneFunc adds a DeleteOne response computed by fn from the recorded call
+func (m *MockDatabase) QueueDeleteOneFunc(fn func(call DeleteOneCall) (int64, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteOneQueue = append(m.DeleteOneQueue, DeleteOneResponse{Responder: fn})
+	return m
+}
+
+// QueueDeleteManyFunc adds a DeleteMany response computed by fn from the recorded call
+func (m *MockDatabase) QueueDeleteManyFunc(fn func(call DeleteManyCall) (int64, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteManyQueue = append(m.DeleteManyQueue, DeleteManyResponse{Responder: fn})
+	return m
+}
+
+// QueueFindOneAndUpdateFunc adds a FindOneAndUpdate response computed by fn from the recorded call
+func (m *MockDatabase) QueueFindOneAndUpdateFunc(fn func(call FindOneAndUpdateCall) (any, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneAndUpdateQueue = append(m.FindOneAndUpdateQueue, FindOneAndUpdateResponse{Responder: fn})
+	return m
+}
+
+// QueueBulkWriteFunc adds a BulkWrite response computed by fn from the recorded call
+func (m *MockDatabase) QueueBulkWriteFunc(fn func(call BulkWriteCall) (*BulkWriteResult, error)) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.BulkWriteQueue = append(m.BulkWriteQueue, BulkWriteResponse{Responder: fn})
+	return m
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMockDatabaseResponders(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("QueueEchoesTheInsertedDocument", func(t *testing.T) {
+		mock := NewMockDatabase()
+		id := primitive.NewObjectID()
+		mock.QueueInsertOneFunc(func(call InsertOneCall) (any, error) {
+			doc := call.Document.(bson.M)
+			doc["_id"] = id
+			return doc["_id"], nil
+		})
+
+		result, err := mock.InsertOne(ctx, "shop", "users", bson.M{"name": "alice"})
+		if err != nil || result != id {
+			t.Errorf("expected the generated id, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("ReceivesTheRecordedCall", func(t *testing.T) {
+		mock := NewMockDatabase()
+		var got FindCall
+		mock.QueueFindFunc(func(call FindCall) (any, error) {
+			got = call
+			return []any{}, nil
+		})
+
+		filter := bson.M{"tenant": "t1"}
+		mock.Find(ctx, "shop", "users", filter)
+		last, _ := mock.LastFindCall()
+		if got.Db != "shop" || got.Collection != "users" || got.Ctx != ctx || fmt.Sprint(got.Filter) != fmt.Sprint(filter) {
+			t.Errorf("expected the full call, got %+v", got)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(last) {
+			t.Errorf("expected the call as recorded, got %+v, recorded %+v", got, last)
+		}
+	})
+
+	t.Run("ExpectationReturnsDocumentMatchingFilter", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFindOne("shop", "users").ReturnFunc(func(call FindOneCall) (any, error) {
+			return bson.M{"_id": call.Filter.(bson.M)["_id"], "name": "alice"}, nil
+		})
+
+		for _, id := range []string{"a", "b"} {
+			result, _ := mock.FindOne(ctx, "shop", "users", bson.M{"_id": id})
+			if result.(bson.M)["_id"] != id {
+				t.Errorf("expected _id %s, got %v", id, result)
+			}
+		}
+	})
+
+	t.Run("ExpectationConvertsNumbers", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.When("Count").RespondFunc(func(call CountCall) (int, error) { return 3, nil })
+		if n, err := mock.Count(ctx, "shop", "users", bson.M{}); n != 3 || err != nil {
+			t.Errorf("expected 3, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("ErrorsPassThrough", func(t *testing.T) {
+		mock := NewMockDatabase()
+		boom := errors.New("boom")
+		mock.QueueDeleteManyFunc(func(call DeleteManyCall) (int64, error) { return 0, boom })
+		if _, err := mock.DeleteMany(ctx, "shop", "users", bson.M{}); err != boom {
+			t.Errorf("expected the responder's error, got %v", err)
+		}
+	})
+
+	t.Run("ReturnFuncRejectsTheWrongSignature", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "func(FindCall)") {
+				t.Errorf("expected a panic naming the expected signature, got %v", r)
+			}
+		}()
+		NewMockDatabase().OnFind("shop", "users").ReturnFunc(func(call FindOneCall) (any, error) { return nil, nil })
+	})
+
+	t.Run("PanicFailsTheBoundTest", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		mock := NewMockDatabase().Strict(rec)
+		mock.QueueFindOneFunc(func(call FindOneCall) (any, error) {
+			var doc bson.M
+			return doc["missing"].(string), nil
+		})
+
+		_, err := mock.FindOne(ctx, "shop", "users", bson.M{"_id": 7})
+		if err == nil {
+			t.Error("expected an error from the panicking responder")
+		}
+		if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "FindOne responder panicked") || !strings.Contains(rec.errors[0], "Collection:users") {
+			t.Errorf("expected a failure with the call attached, got %v", rec.errors)
+		}
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected the mock to stay usable, got %v", err)
+		}
+	})
+
+	t.Run("PanicPropagatesWithoutATest", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueCountFunc(func(call CountCall) (int64, error) { panic("bad responder") })
+		defer func() {
+			r := fmt.Sprint(recover())
+			if !strings.Contains(r, "bad responder") || !strings.Contains(r, "Db:shop") {
+				t.Errorf("expected the panic with the call attached, got %s", r)
+			}
+		}()
+		mock.Count(ctx, "shop", "users", bson.M{})
+	})
+
+	t.Run("VerifyReportsUnusedResponders", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindFunc(func(call FindCall) (any, error) { return nil, nil })
+		if err := mock.Verify(); err == nil || !strings.Contains(err.Error(), "FindQueue[0]: queued response never consumed (responder)") {
+			t.Errorf("expected the unused responder to be reported, got %v", err)
+		}
+	})
+}
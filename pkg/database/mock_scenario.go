@@ -0,0 +1,72 @@
+package database
+
+// Scenario is a fluent way to declare one clause of the database contract of
+// the code under test:
+//
+//	mock.When("Find").On("db", "cameras").Matching(FilterHasKeys("site_id")).Respond(cameras, nil).Times(2)
+//
+// It is sugar over a scoped expectation, registered by When, so it behaves
+// exactly like On and is checked by Verify and AssertExpectations.
+type Scenario struct {
+	e *Expectation
+}
+
+// When starts a Scenario for the named operation, matching every namespace
+// until On narrows it
+func (m *MockDatabase) When(operation string) *Scenario {
+	return &Scenario{e: m.On(operation, "", "")}
+}
+
+// On narrows the scenario to db.collection
+func (s *Scenario) On(db string, collection string) *Scenario {
+	s.e.Db = db
+	s.e.Collection = collection
+	return s
+}
+
+// Matching narrows the scenario to calls whose filter satisfies every matcher
+func (s *Scenario) Matching(matchers ...FilterMatcher) *Scenario {
+	s.e.WithFilter(matchers...)
+	return s
+}
+
+// WithOptions narrows the scenario to calls whose options satisfy every matcher
+func (s *Scenario) WithOptions(matchers ...OptionsMatcher) *Scenario {
+	s.e.WithOptions(matchers...)
+	return s
+}
+
+// Respond sets the result and error returned to matching calls
+func (s *Scenario) Respond(result any, err error) *Scenario {
+	s.e.Return(result, err)
+	return s
+}
+
+// RespondFunc computes the response from each matching call, see
+// Expectation.ReturnFunc
+func (s *Scenario) RespondFunc(fn any) *Scenario {
+	s.e.ReturnFunc(fn)
+	return s
+}
+
+// Times expects exactly n matching calls, see Expectation.Times
+func (s *Scenario) Times(n int) *Scenario {
+	s.e.Times(n)
+	return s
+}
+
+// Once expects exactly one matching call
+func (s *Scenario) Once() *Scenario {
+	return s.Times(1)
+}
+
+// Optional allows the scenario to go unmatched during verification
+func (s *Scenario) Optional() *Scenario {
+	s.e.Optional()
+	return s
+}
+
+// Expectation returns the scoped expectation behind the scenario
+func (s *Scenario) Expectation() *Expectation {
+	return s.e
+}
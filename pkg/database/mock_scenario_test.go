@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMockDatabaseScenario(t *testing.T) {
+	ctx := context.Background()
+	cameras := []any{bson.M{"name": "front door"}}
+
+	t.Run("DeclaresTheContract", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.When("Find").On("db", "cameras").Matching(FilterHasKeys("site_id")).Respond(cameras, nil).Times(2)
+		mock.When("Count").On("db", "cameras").Respond(1, nil).Optional()
+
+		for i := 0; i < 2; i++ {
+			result, err := mock.Find(ctx, "db", "cameras", bson.M{"site_id": "s1"})
+			if err != nil || len(result.([]any)) != 1 {
+				t.Fatalf("expected the scenario's response, got %v, %v", result, err)
+			}
+		}
+		mock.AssertExpectations(t)
+	})
+
+	t.Run("IsAScopedExpectation", func(t *testing.T) {
+		mock := NewMockDatabase()
+		e := mock.When("FindOne").On("db", "users").Respond("alice", nil).Once().Expectation()
+
+		mock.FindOne(ctx, "db", "users", bson.M{})
+		if e.Calls() != 1 || e.String() != "FindOne on db.users" {
+			t.Errorf("expected the scenario to drive its expectation, got %s with %d call(s)", e, e.Calls())
+		}
+		if result, _ := mock.FindOne(ctx, "db", "users", bson.M{}); result == "alice" {
+			t.Error("expected an exhausted scenario to fall through to the handler like Once")
+		}
+	})
+
+	t.Run("MatchesEveryNamespaceWithoutOn", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.When("Count").Respond(int64(7), nil)
+		if n, _ := mock.Count(ctx, "any", "thing", bson.M{}); n != 7 {
+			t.Errorf("expected 7, got %d", n)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		setup func(*MockDatabase)
+		calls func(*MockDatabase)
+		want  []string
+	}{
+		{
+			name:  "OperationNeverCalled",
+			setup: func(m *MockDatabase) { m.When("Find").On("db", "cameras") },
+			calls: func(m *MockDatabase) { m.FindOne(ctx, "db", "cameras", bson.M{}) },
+			want:  []string{"Find on db.cameras", "never matched, operation: Find was never called"},
+		},
+		{
+			name:  "WrongNamespace",
+			setup: func(m *MockDatabase) { m.When("Find").On("db", "cameras") },
+			calls: func(m *MockDatabase) {
+				m.Find(ctx, "db", "sites", bson.M{})
+				m.Find(ctx, "db", "users", bson.M{})
+				m.Find(ctx, "db", "sites", bson.M{})
+			},
+			want: []string{"namespace: Find was called 3 time(s) but only on db.sites, db.users"},
+		},
+		{
+			name: "FilterMismatch",
+			setup: func(m *MockDatabase) {
+				m.When("Find").On("db", "cameras").Matching(FilterHasKeys("tenant"), FilterHasKeys("site_id"))
+			},
+			calls: func(m *MockDatabase) { m.Find(ctx, "db", "cameras", bson.M{"tenant": "t1"}) },
+			want: []string{
+				"Find on db.cameras was called 1 time(s) but never matched:",
+				"call #1: filter {tenant: string} failed matcher 2 of 2",
+			},
+		},
+		{
+			name: "OptionsMismatch",
+			setup: func(m *MockDatabase) {
+				m.When("Find").On("db", "cameras").WithOptions(OptsHaveLimit(10))
+			},
+			calls: func(m *MockDatabase) { m.Find(ctx, "db", "cameras", bson.M{}, moptions.Find().SetLimit(5)) },
+			want:  []string{"call #1: options did not match"},
+		},
+		{
+			name: "AnsweredElsewhere",
+			setup: func(m *MockDatabase) {
+				m.QueueFind([]any{}, nil)
+				m.When("Find").On("db", "cameras")
+			},
+			calls: func(m *MockDatabase) { m.Find(ctx, "db", "cameras", bson.M{}) },
+			want:  []string{"call #1: answered by a queued response or an earlier expectation"},
+		},
+		{
+			name:  "ManyCallsAreCapped",
+			setup: func(m *MockDatabase) { m.When("Count").On("db", "cameras").Matching(FilterHasKeys("x")) },
+			calls: func(m *MockDatabase) {
+				for i := 0; i < 7; i++ {
+					m.Count(ctx, "db", "cameras", bson.M{})
+				}
+			},
+			want: []string{"call #5: filter", "and 2 more"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockDatabase()
+			tt.setup(mock)
+			tt.calls(mock)
+
+			err := mock.Verify()
+			if err == nil {
+				t.Fatal("expected verification to fail")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Step is one expected call in a mock script. Matcher narrows the step to
+// calls whose filter it accepts, and Times is how many consecutive calls the
+// step answers, 1 when zero. An empty Db or Collection matches any.
+type Step struct {
+	Operation  string
+	Db         string
+	Collection string
+	Matcher    FilterMatcher
+	Result     any
+	Err        error
+	Times      int
+}
+
+// Where returns a copy of the step narrowed to filters accepted by matcher
+func (s Step) Where(matcher FilterMatcher) Step {
+	s.Matcher = matcher
+	return s
+}
+
+// Repeat returns a copy of the step answering n consecutive calls
+func (s Step) Repeat(n int) Step {
+	s.Times = n
+	return s
+}
+
+// StepPing scripts a Ping call
+func StepPing(err error) Step {
+	return Step{Operation: "Ping", Err: err}
+}
+
+// StepFind scripts a Find call on db.collection
+func StepFind(db string, collection string, result any, err error) Step {
+	return Step{Operation: "Find", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepFindOne scripts a FindOne call on db.collection
+func StepFindOne(db string, collection string, result any, err error) Step {
+	return Step{Operation: "FindOne", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepFindCursor scripts a FindCursor call on db.collection returning a fresh
+// MockCursor over docs
+func StepFindCursor(db string, collection string, docs []any, err error) Step {
+	return Step{Operation: "FindCursor", Db: db, Collection: collection, Result: docs, Err: err}
+}
+
+// StepAggregate scripts an Aggregate call on db.collection
+func StepAggregate(db string, collection string, result any, err error) Step {
+	return Step{Operation: "Aggregate", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepCount scripts a Count call on db.collection
+func StepCount(db string, collection string, result int64, err error) Step {
+	return Step{Operation: "Count", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepDistinct scripts a Distinct call on db.collection
+func StepDistinct(db string, collection string, result []any, err error) Step {
+	return Step{Operation: "Distinct", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepInsertOne scripts an InsertOne call on db.collection
+func StepInsertOne(db string, collection string, result any, err error) Step {
+	return Step{Operation: "InsertOne", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepInsertMany scripts an InsertMany call on db.collection
+func StepInsertMany(db string, collection string, result []any, err error) Step {
+	return Step{Operation: "InsertMany", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepUpdateOne scripts an UpdateOne call on db.collection
+func StepUpdateOne(db string, collection string, result *UpdateResult, err error) Step {
+	return Step{Operation: "UpdateOne", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepUpdateMany scripts an UpdateMany call on db.collection
+func StepUpdateMany(db string, collection string, result *UpdateResult, err error) Step {
+	return Step{Operation: "UpdateMany", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepReplaceOne scripts a ReplaceOne call on db.collection
+func StepReplaceOne(db string, collection string, result *UpdateResult, err error) Step {
+	return Step{Operation: "ReplaceOne", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepDeleteOne scripts a DeleteOne call on db.collection
+func StepDeleteOne(db string, collection string, result int64, err error) Step {
+	return Step{Operation: "DeleteOne", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepDeleteMany scripts a DeleteMany call on db.collection
+func StepDeleteMany(db string, collection string, result int64, err error) Step {
+	return Step{Operation: "DeleteMany", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepFindOneAndUpdate scripts a FindOneAndUpdate call on db.collection
+func StepFindOneAndUpdate(db string, collection string, result any, err error) Step {
+	return Step{Operation: "FindOneAndUpdate", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// StepBulkWrite scripts a BulkWrite call on db.collection
+func StepBulkWrite(db string, collection string, result *BulkWriteResult, err error) Step {
+	return Step{Operation: "BulkWrite", Db: db, Collection: collection, Result: result, Err: err}
+}
+
+// Script appends steps the mock answers strictly in order, after queued
+// responses and before scoped expectations. A call that does not match the
+// current step, or arrives after the last one, is a deviation: in strict mode
+// it fails the test and returns an error, otherwise it falls through to the
+// rest of the pipeline and is reported by Verify. Reset discards the script.
+func (m *MockDatabase) Script(steps []Step) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, step := range steps {
+		e := &Expectation{
+			Operation:  step.Operation,
+			Db:         step.Db,
+			Collection: step.Collection,
+			result:     step.Result,
+			err:        step.Err,
+			times:      step.Times,
+		}
+		if e.times <= 0 {
+			e.times = 1
+		}
+		if step.Matcher != nil {
+			e.filters = []FilterMatcher{step.Matcher}
+		}
+		m.script = append(m.script, e)
+	}
+	return m
+}
+
+// scriptAnswer answers the call from the current script step, advancing past
+// it once it has answered Times calls; the caller holds m.mu
+func scriptAnswer[R any](m *MockDatabase, call mockCall) (mockResponse[R], bool) {
+	var response mockResponse[R]
+	if len(m.script) == 0 {
+		return response, false
+	}
+	if m.scriptPos < len(m.script) {
+		step := m.script[m.scriptPos]
+		if step.matchesNamespace(call.operation, call.db, call.collection) && step.matchesFilter(call.filter) {
+			step.calls++
+			if step.calls >= step.times {
+				m.scriptPos++
+			}
+			response.result, response.err = stepResult[R](step)
+			return response, true
+		}
+	}
+
+	deviation := m.scriptDeviation(call)
+	m.scriptDeviations = append(m.scriptDeviations, deviation)
+	if m.strict == nil {
+		return response, false
+	}
+	err := fmt.Errorf("mock: %s", deviation)
+	m.strict.Errorf("%v", err)
+	response.err = err
+	return response, true
+}
+
+// stepResult converts a step's result like expectationResult, turning the
+// documents of a FindCursor step into a new cursor per call
+func stepResult[R any](step *Expectation) (R, error) {
+	if docs, ok := step.result.([]any); ok && step.Operation == "FindCursor" {
+		if cursor, ok := any(NewMockCursor(docs)).(R); ok {
+			return cursor, step.err
+		}
+	}
+	return expectationResult[R](step)
+}
+
+// scriptDeviation describes how call differs from the current script step
+func (m *MockDatabase) scriptDeviation(call mockCall) string {
+	actual := callSummary{Operation: call.operation, Db: call.db, Collection: call.collection, Filter: call.filter}
+	if m.scriptPos >= len(m.script) {
+		return fmt.Sprintf("call after the last of %d script step(s):\n  - expected: no further calls\n  + actual:   %s",
+			len(m.script), actual)
+	}
+	step := m.script[m.scriptPos]
+	expected := step.String()
+	if len(step.filters) > 0 {
+		expected += " with a matching filter"
+	}
+	return fmt.Sprintf("call deviates from script step %d of %d:\n  - expected: %s\n  + actual:   %s",
+		m.scriptPos+1, len(m.script), expected, actual)
+}
+
+// scriptProblems lists unreached script steps and deviations for Verify; the
+// caller holds m.mu
+func (m *MockDatabase) scriptProblems() []string {
+	var problems []string
+	for i := m.scriptPos; i < len(m.script); i++ {
+		step := m.script[i]
+		if step.calls == 0 {
+			problems = append(problems, fmt.Sprintf("script step %d %s: never reached", i+1, step))
+		} else {
+			problems = append(problems, fmt.Sprintf("script step %d %s: matched %d of %d expected call(s)",
+				i+1, step, step.calls, step.times))
+		}
+	}
+	problems = append(problems, m.scriptDeviations...)
+	return problems
+}
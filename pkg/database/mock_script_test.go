@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseScript(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("AnswersStepsInOrder", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Script([]Step{
+			StepFindOne("shop", "users", bson.M{"_id": 1, "name": "Alice"}, nil),
+			StepFind("shop", "orders", []any{"o1", "o2"}, nil).Where(FilterHasKeys("user_id")),
+			StepCount("shop", "orders", 2, nil),
+			StepUpdateOne("shop", "users", &UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil),
+		})
+
+		user, err := mock.FindOne(ctx, "shop", "users", bson.M{"_id": 1})
+		if err != nil || user.(bson.M)["name"] != "Alice" {
+			t.Fatalf("expected step 1 result, got %v, %v", user, err)
+		}
+		orders, _ := mock.Find(ctx, "shop", "orders", bson.M{"user_id": 1})
+		if len(orders.([]any)) != 2 {
+			t.Errorf("expected step 2 result, got %v", orders)
+		}
+		if n, _ := mock.Count(ctx, "shop", "orders", bson.M{}); n != 2 {
+			t.Errorf("expected step 3 count, got %d", n)
+		}
+		res, _ := mock.UpdateOne(ctx, "shop", "users", bson.M{"_id": 1}, bson.M{"$set": bson.M{"orders": 2}})
+		if res.ModifiedCount != 1 {
+			t.Errorf("expected step 4 result, got %+v", res)
+		}
+
+		if err := mock.Verify(); err != nil {
+			t.Errorf("expected completed script to verify, got %v", err)
+		}
+		for _, call := range mock.History() {
+			if call.Source != SourceScript {
+				t.Errorf("expected %s to be answered by the script, got %s", call.Operation, call.Source)
+			}
+		}
+	})
+
+	t.Run("Times", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Script([]Step{
+			StepPing(nil).Repeat(2),
+			StepDeleteMany("shop", "carts", 5, nil),
+		})
+
+		mock.Ping(ctx)
+		mock.Ping(ctx)
+		if n, _ := mock.DeleteMany(ctx, "shop", "carts", bson.M{}); n != 5 {
+			t.Errorf("expected DeleteMany after two pings, got %d", n)
+		}
+		if err := mock.Verify(); err != nil {
+			t.Errorf("expected completed script to verify, got %v", err)
+		}
+	})
+
+	t.Run("FindCursorGetsFreshCursor", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Script([]Step{StepFindCursor("shop", "events", []any{"a", "b"}, nil).Repeat(2)})
+
+		for i := 0; i < 2; i++ {
+			cursor, err := mock.FindCursor(ctx, "shop", "events", bson.M{})
+			if err != nil {
+				t.Fatalf("expected cursor, got %v", err)
+			}
+			n := 0
+			for cursor.Next(ctx) {
+				n++
+			}
+			if n != 2 {
+				t.Errorf("call %d: expected 2 documents, got %d", i, n)
+			}
+		}
+	})
+
+	t.Run("QueueStillWins", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{"queued"}, nil)
+		mock.Script([]Step{StepFind("shop", "users", []any{"scripted"}, nil)})
+
+		first, _ := mock.Find(ctx, "shop", "users", bson.M{})
+		second, _ := mock.Find(ctx, "shop", "users", bson.M{})
+		if first.([]any)[0] != "queued" || second.([]any)[0] != "scripted" {
+			t.Errorf("expected queue then script, got %v then %v", first, second)
+		}
+	})
+
+	t.Run("DeviationFallsThroughAndIsVerified", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFindOne("shop", "orders").Return("from-expectation", nil)
+		mock.Script([]Step{
+			StepFindOne("shop", "users", "alice", nil),
+			StepInsertOne("shop", "audit", "audit-id", nil),
+		})
+
+		result, err := mock.FindOne(ctx, "shop", "orders", bson.M{"id": 7})
+		if err != nil || result != "from-expectation" {
+			t.Errorf("expected deviation to fall through, got %v, %v", result, err)
+		}
+		if result, _ := mock.FindOne(ctx, "shop", "users", bson.M{}); result != "alice" {
+			t.Errorf("expected script to stay on step 1, got %v", result)
+		}
+
+		err = mock.Verify()
+		if err == nil {
+			t.Fatal("expected Verify to report the deviation and the unreached step")
+		}
+		for _, want := range []string{
+			"call deviates from script step 1 of 2",
+			"- expected: FindOne on shop.users",
+			"+ actual:   FindOne shop.orders filter={id: number}",
+			"script step 2 InsertOne on shop.audit: never reached",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in %v", want, err)
+			}
+		}
+	})
+
+	t.Run("StrictFailsOnDeviation", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase().Strict(tb)
+		mock.Script([]Step{StepFind("shop", "users", []any{}, nil).Where(FilterHasKeys("tenant_id"))})
+
+		_, err := mock.Find(ctx, "shop", "users", bson.M{"email": "a@b"})
+		if err == nil || !strings.Contains(err.Error(), "Find on shop.users with a matching filter") {
+			t.Errorf("expected deviation error naming the matcher, got %v", err)
+		}
+		if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "+ actual:   Find shop.users filter={email: string}") {
+			t.Errorf("expected strict test to fail with a diff, got %v", tb.errors)
+		}
+	})
+
+	t.Run("StrictFailsAfterLastStep", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabase().Strict(tb)
+		mock.Script([]Step{StepDeleteOne("shop", "carts", 1, nil)})
+
+		mock.DeleteOne(ctx, "shop", "carts", bson.M{})
+		_, err := mock.DeleteOne(ctx, "shop", "carts", bson.M{})
+		if err == nil || !strings.Contains(err.Error(), "after the last of 1 script step(s)") {
+			t.Errorf("expected error for call after the script, got %v", err)
+		}
+		if len(tb.errors) != 1 {
+			t.Errorf("expected strict test to fail once, got %v", tb.errors)
+		}
+	})
+
+	t.Run("StepError", func(t *testing.T) {
+		boom := errors.New("boom")
+		mock := NewMockDatabase()
+		mock.Script([]Step{StepInsertMany("shop", "items", nil, boom)})
+
+		if _, err := mock.InsertMany(ctx, "shop", "items", []any{bson.M{}}); !errors.Is(err, boom) {
+			t.Errorf("expected step error, got %v", err)
+		}
+	})
+
+	t.Run("ResetDiscardsScript", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Script([]Step{StepPing(errors.New("down"))})
+		mock.Reset()
+
+		if err := mock.Ping(ctx); err != nil {
+			t.Errorf("expected default Ping after Reset, got %v", err)
+		}
+		if err := mock.Verify(); err != nil {
+			t.Errorf("expected nothing to verify after Reset, got %v", err)
+		}
+	})
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestMockDatabase(t *testing.T) {
@@ -223,6 +227,25 @@ func TestMockDatabase(t *testing.T) {
 			t.Errorf("expected 1 ping call on mock, got %d", len(mock.PingCalls))
 		}
 	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		mock := NewMockDatabase()
+		pipeline := []any{map[string]any{"$match": map[string]any{"status": "open"}}}
+
+		mock.QueueAggregate([]any{map[string]any{"n": 2}}, nil)
+		mock.OnAggregate("testdb", "orders").WithFilter(func(p any) bool { return len(p.([]any)) == 1 }).Return([]any{}, errors.New("scoped"))
+
+		result, err := mock.Aggregate(context.Background(), "testdb", "orders", pipeline)
+		if err != nil || len(result.([]any)) != 1 {
+			t.Errorf("expected the queued result, got %v, %v", result, err)
+		}
+		if _, err := mock.Aggregate(context.Background(), "testdb", "orders", pipeline); err == nil || err.Error() != "scoped" {
+			t.Errorf("expected the scoped expectation to match the pipeline, got %v", err)
+		}
+		if len(mock.AggregateCalls) != 2 || mock.AggregateCalls[0].Pipeline == nil {
+			t.Errorf("expected 2 recorded calls with the pipeline, got %v", mock.AggregateCalls)
+		}
+	})
 }
 
 func TestMockDatabaseSequentialCalls(t *testing.T) {
@@ -375,3 +398,188 @@ func TestMockDatabaseSequentialCalls(t *testing.T) {
 		}
 	})
 }
+
+func TestMockDatabaseWriteOperations(t *testing.T) {
+	t.Run("DefaultBehavior", func(t *testing.T) {
+		mock := NewMockDatabase()
+		ctx := context.Background()
+
+		id, err := mock.InsertOne(ctx, "testdb", "users", map[string]any{"name": "Alice"})
+		if err != nil {
+			t.Errorf("expected nil error, got %v", err)
+		}
+		if hex, ok := id.(string); !ok || !primitive.IsValidObjectID(hex) {
+			t.Errorf("expected generated ObjectID hex, got %v", id)
+		}
+
+		ids, err := mock.InsertMany(ctx, "testdb", "users", []any{map[string]any{"a": 1}, map[string]any{"b": 2}})
+		if err != nil {
+			t.Errorf("expected nil error, got %v", err)
+		}
+		if len(ids) != 2 {
+			t.Errorf("expected 2 generated ids, got %d", len(ids))
+		}
+
+		res, err := mock.UpdateOne(ctx, "testdb", "users", map[string]any{"id": 1}, map[string]any{"$set": map[string]any{"a": 1}})
+		if err != nil || res == nil || res.MatchedCount != 0 {
+			t.Errorf("expected empty UpdateResult, got %+v, %v", res, err)
+		}
+
+		deleted, err := mock.DeleteMany(ctx, "testdb", "users", map[string]any{})
+		if err != nil || deleted != 0 {
+			t.Errorf("expected 0 deleted, got %d, %v", deleted, err)
+		}
+
+		doc, err := mock.FindOneAndUpdate(ctx, "testdb", "users", map[string]any{"id": 1}, map[string]any{})
+		if err == nil || doc != nil {
+			t.Errorf("expected not found error, got %v, %v", doc, err)
+		}
+
+		bulk, err := mock.BulkWrite(ctx, "testdb", "users", []any{})
+		if err != nil || bulk == nil {
+			t.Errorf("expected empty BulkWriteResult, got %+v, %v", bulk, err)
+		}
+	})
+
+	t.Run("ExpectAndQueue", func(t *testing.T) {
+		mock := NewMockDatabase()
+		ctx := context.Background()
+
+		mock.ExpectUpdateOne(&UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil).
+			QueueUpdateOne(nil, errors.New("write conflict"))
+
+		_, err := mock.UpdateOne(ctx, "testdb", "users", map[string]any{"id": 1}, map[string]any{})
+		if err == nil || err.Error() != "write conflict" {
+			t.Errorf("expected queued 'write conflict' error, got %v", err)
+		}
+
+		res, err := mock.UpdateOne(ctx, "testdb", "users", map[string]any{"id": 1}, map[string]any{})
+		if err != nil || res.MatchedCount != 1 {
+			t.Errorf("expected expectation result, got %+v, %v", res, err)
+		}
+
+		mock.ExpectDeleteOne(1, nil)
+		deleted, _ := mock.DeleteOne(ctx, "testdb", "users", map[string]any{"id": 1})
+		if deleted != 1 {
+			t.Errorf("expected 1 deleted, got %d", deleted)
+		}
+	})
+
+	t.Run("CallTrackingFullArguments", func(t *testing.T) {
+		mock := NewMockDatabase()
+		filter := map[string]any{"id": 1}
+		replacement := map[string]any{"id": 1, "name": "Bob"}
+
+		mock.ReplaceOne(context.Background(), "testdb", "users", filter, replacement, "opt")
+
+		if len(mock.ReplaceOneCalls) != 1 {
+			t.Fatalf("expected 1 ReplaceOne call, got %d", len(mock.ReplaceOneCalls))
+		}
+		call := mock.ReplaceOneCalls[0]
+		if call.Db != "testdb" || call.Collection != "users" {
+			t.Errorf("unexpected namespace %s.%s", call.Db, call.Collection)
+		}
+		if call.Filter.(map[string]any)["id"] != 1 || call.Replacement.(map[string]any)["name"] != "Bob" {
+			t.Errorf("expected filter and replacement to be recorded, got %+v", call)
+		}
+		if len(call.Opts) != 1 {
+			t.Errorf("expected opts to be recorded, got %v", call.Opts)
+		}
+
+		mock.Reset()
+		if len(mock.ReplaceOneCalls) != 0 {
+			t.Error("expected Reset to clear write calls")
+		}
+	})
+}
+
+// TestMockDatabaseParity asserts every DatabaseInterface method has an XFunc,
+// XQueue and XCalls field plus ExpectX and QueueX methods on the mock
+func TestMockDatabaseParity(t *testing.T) {
+	iface := reflect.TypeOf((*DatabaseInterface)(nil)).Elem()
+	mockType := reflect.TypeOf(&MockDatabase{})
+
+	for i := 0; i < iface.NumMethod(); i++ {
+		name := iface.Method(i).Name
+		for _, field := range []string{name + "Func", name + "Queue", name + "Calls"} {
+			if _, ok := mockType.Elem().FieldByName(field); !ok {
+				t.Errorf("MockDatabase is missing field %s for interface method %s", field, name)
+			}
+		}
+		if fn, ok := mockType.Elem().FieldByName(name + "Func"); ok && fn.Type != iface.Method(i).Type {
+			t.Errorf("MockDatabase.%sFunc has type %v, expected %v", name, fn.Type, iface.Method(i).Type)
+		}
+		for _, method := range []string{"Expect" + name, "Queue" + name, "On" + name, "Last" + name + "Call"} {
+			if _, ok := mockType.MethodByName(method); !ok {
+				t.Errorf("MockDatabase is missing method %s for interface method %s", method, name)
+			}
+		}
+	}
+}
+
+func TestMockDatabaseResetSemantics(t *testing.T) {
+	t.Run("ResetKeepsHandlers", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.ExpectPing(errors.New("connection failed"))
+		mock.Ping(context.Background())
+
+		mock.Reset()
+		if len(mock.PingCalls) != 0 {
+			t.Error("expected Reset to clear calls")
+		}
+		if err := mock.Ping(context.Background()); err == nil {
+			t.Error("expected Reset to keep the ExpectPing override")
+		}
+	})
+
+	t.Run("ResetCallsKeepsQueues", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{"queued"}, nil)
+		mock.FindOne(context.Background(), "testdb", "users", map[string]any{})
+
+		mock.ResetCalls()
+		if len(mock.FindOneCalls) != 0 || len(mock.FindQueue) != 1 {
+			t.Errorf("expected calls cleared and queue kept, got %d calls, %d queued", len(mock.FindOneCalls), len(mock.FindQueue))
+		}
+	})
+
+	t.Run("ResetQueuesKeepsCalls", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{"queued"}, nil)
+		mock.OnFind("testdb", "users").Return([]any{"expected"}, nil)
+		mock.FindOne(context.Background(), "testdb", "users", map[string]any{})
+
+		mock.ResetQueues()
+		if len(mock.FindOneCalls) != 1 || len(mock.FindQueue) != 0 {
+			t.Errorf("expected queue cleared and calls kept, got %d calls, %d queued", len(mock.FindOneCalls), len(mock.FindQueue))
+		}
+		result, _ := mock.Find(context.Background(), "testdb", "users", map[string]any{})
+		if len(result.([]any)) != 0 {
+			t.Errorf("expected scoped expectations to be cleared, got %v", result)
+		}
+	})
+
+	t.Run("ResetAllIsolatesSubtests", func(t *testing.T) {
+		mock := NewMockDatabase()
+
+		t.Run("FailingPing", func(t *testing.T) {
+			mock.ResetAll()
+			mock.ExpectPing(errors.New("connection failed")).
+				ExpectFindOne(map[string]any{"id": 1}, nil).
+				WithDelay("*", time.Hour)
+
+			mock.IgnoreContextCancellation(true)
+		})
+
+		t.Run("HealthyPing", func(t *testing.T) {
+			mock.ResetAll()
+
+			if err := mock.Ping(context.Background()); err != nil {
+				t.Errorf("expected default Ping after ResetAll, got %v", err)
+			}
+			if _, err := mock.FindOne(context.Background(), "testdb", "users", map[string]any{}); err == nil {
+				t.Error("expected default FindOne not found after ResetAll")
+			}
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package database
+
+import "context"
+
+var _ Transactor = (*MockDatabase)(nil)
+
+// WithTransactionCall records a call to WithTransaction
+type WithTransactionCall struct {
+	Ctx   context.Context
+	Chaos bool
+
+	// Err is what the call returned and Committed whether fn succeeded;
+	// both are set once the call returns
+	Err       error
+	Committed bool
+}
+
+// WithTransaction implements Transactor. Unless WithTransactionFunc is set,
+// fn runs once with a context InTransaction reports true for, so the calls
+// it makes are recorded with that context; the mock keeps no data, so
+// nothing is rolled back when fn fails. Faults, expectations and chaos
+// errors fail the call without running fn, as a transaction that cannot
+// start does.
+func (m *MockDatabase) WithTransaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	index := -1
+	_, err := invoke(m, mockCall{ctx: ctx, operation: "WithTransaction"},
+		func(chaos bool) {
+			index = len(m.WithTransactionCalls)
+			m.WithTransactionCalls = append(m.WithTransactionCalls, WithTransactionCall{Ctx: ctx, Chaos: chaos})
+		},
+		func() (mockResponse[struct{}], bool) {
+			return mockResponse[struct{}]{}, false
+		},
+		func() (struct{}, error) {
+			if m.WithTransactionFunc != nil {
+				return struct{}{}, m.WithTransactionFunc(ctx, fn)
+			}
+			return struct{}{}, fn(context.WithValue(ctx, transactionKey{}, m))
+		})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// The calls may have been reset meanwhile
+	if index >= 0 && index < len(m.WithTransactionCalls) {
+		m.WithTransactionCalls[index].Err = err
+		m.WithTransactionCalls[index].Committed = err == nil
+	}
+	return err
+}
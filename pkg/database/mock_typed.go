@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ExpectFindOneAs sets up FindOne to return value after a BSON round trip,
+// so the result looks exactly like a document decoded by the real driver
+func ExpectFindOneAs[T any](m *MockDatabase, value T, err error) *MockDatabase {
+	doc, marshalErr := fixtureDocument(value)
+	if marshalErr != nil {
+		err = marshalErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+		return doc, err
+	}
+	return m
+}
+
+// ExpectFindAs sets up Find to return values after a BSON round trip
+func ExpectFindAs[T any](m *MockDatabase, values []T, err error) *MockDatabase {
+	docs, marshalErr := fixtureDocuments(values)
+	if marshalErr != nil {
+		err = marshalErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+		return docs, err
+	}
+	return m
+}
+
+// QueueFindOneAs queues value for FindOne after a BSON round trip. The queued
+// FindOneResponse keeps the typed value in Typed and the decoded form in Result.
+func QueueFindOneAs[T any](m *MockDatabase, value T, err error) *MockDatabase {
+	doc, marshalErr := fixtureDocument(value)
+	if marshalErr != nil {
+		err = marshalErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneQueue = append(m.FindOneQueue, FindOneResponse{Result: doc, Err: err, Typed: value})
+	return m
+}
+
+// QueueFindAs queues values for Find after a BSON round trip. The queued
+// FindResponse keeps the typed slice in Typed and the decoded form in Result.
+func QueueFindAs[T any](m *MockDatabase, values []T, err error) *MockDatabase {
+	docs, marshalErr := fixtureDocuments(values)
+	if marshalErr != nil {
+		err = marshalErr
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindQueue = append(m.FindQueue, FindResponse{Result: docs, Err: err, Typed: values})
+	return m
+}
+
+// fixtureDocument marshals v to BSON and decodes it back the way MongoClient
+// decodes results, preserving ObjectIDs, dates and omitempty behavior
+func fixtureDocument(v any) (any, error) {
+	if isNilValue(v) {
+		return nil, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("mock: marshal fixture %T: %w", v, err)
+	}
+	var doc any
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("mock: unmarshal fixture %T: %w", v, err)
+	}
+	return doc, nil
+}
+
+func fixtureDocuments[T any](values []T) ([]any, error) {
+	docs := make([]any, 0, len(values))
+	for _, v := range values {
+		doc, err := fixtureDocument(v)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func isNilValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
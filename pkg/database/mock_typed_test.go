@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type typedFixtureUser struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	Email     string             `bson:"email,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// decodeInto converts a mock result back into a struct like consumer code does
+func decodeInto(t *testing.T, doc any, out any) {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal result: %v", err)
+	}
+	if err := bson.Unmarshal(raw, out); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+}
+
+func TestMockDatabaseTypedHelpers(t *testing.T) {
+	user := typedFixtureUser{
+		ID:        primitive.NewObjectID(),
+		Name:      "Alice",
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+
+	t.Run("ExpectFindOneAsRoundTrips", func(t *testing.T) {
+		mock := NewMockDatabase()
+		ExpectFindOneAs(mock, user, nil)
+
+		result, err := mock.FindOne(context.Background(), "testdb", "users", bson.M{})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if _, ok := result.(bson.D); !ok {
+			t.Fatalf("expected driver-shaped bson.D result, got %T", result)
+		}
+
+		var decoded typedFixtureUser
+		decodeInto(t, result, &decoded)
+		if decoded.ID != user.ID || !decoded.CreatedAt.Equal(user.CreatedAt) || decoded.Name != "Alice" {
+			t.Errorf("expected round-tripped user, got %+v", decoded)
+		}
+
+		// omitempty fields are absent just like in a real document
+		for _, e := range result.(bson.D) {
+			if e.Key == "email" {
+				t.Error("expected omitempty email to be dropped")
+			}
+		}
+	})
+
+	t.Run("QueueFindAsKeepsTypedAndMarshalled", func(t *testing.T) {
+		mock := NewMockDatabase()
+		QueueFindAs(mock, []typedFixtureUser{user, user}, nil)
+
+		if typed, ok := mock.FindQueue[0].Typed.([]typedFixtureUser); !ok || len(typed) != 2 {
+			t.Errorf("expected typed fixture to be stored, got %T", mock.FindQueue[0].Typed)
+		}
+
+		result, err := mock.Find(context.Background(), "testdb", "users", bson.M{})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		docs := result.([]any)
+		if len(docs) != 2 {
+			t.Fatalf("expected 2 documents, got %d", len(docs))
+		}
+		var decoded typedFixtureUser
+		decodeInto(t, docs[1], &decoded)
+		if decoded.ID != user.ID {
+			t.Errorf("expected ObjectID to survive, got %v", decoded.ID)
+		}
+	})
+
+	t.Run("NilValueWithError", func(t *testing.T) {
+		mock := NewMockDatabase()
+		notFound := errors.New("not found")
+		QueueFindOneAs[*typedFixtureUser](mock, nil, notFound)
+
+		result, err := mock.FindOne(context.Background(), "testdb", "users", bson.M{})
+		if result != nil || err != notFound {
+			t.Errorf("expected nil result and not found, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("MarshalErrorSurfaces", func(t *testing.T) {
+		mock := NewMockDatabase()
+		type withChannel struct {
+			Events chan int `bson:"events"`
+		}
+		ExpectFindAs(mock, []withChannel{{Events: make(chan int)}}, nil)
+
+		if _, err := mock.Find(context.Background(), "testdb", "users", bson.M{}); err == nil {
+			t.Error("expected marshal error for unsupported fixture")
+		}
+	})
+}
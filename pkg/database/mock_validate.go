@@ -0,0 +1,232 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidBSON is returned, with ValidateBSON enabled, for calls whose
+// filter, document, update or pipeline the driver could not marshal
+var ErrInvalidBSON = errors.New("mock: argument is not BSON-marshalable")
+
+// ValidateBSON makes the mock run every filter, document, update, pipeline
+// and bulk write model through bson.Marshal, as the driver would, and fail
+// calls with an argument that does not marshal or holds a NaN float. The error
+// wraps ErrInvalidBSON and names the path of the offending value; under
+// Strict it also fails the test. Such calls are recorded with source
+// "invalid". It is off by default as it marshals every argument.
+func (m *MockDatabase) ValidateBSON(enabled bool) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.validateBSON = enabled
+	return m
+}
+
+// invalidBSONCall returns the error for the first argument of call that
+// does not marshal
+func (m *MockDatabase) invalidBSONCall(call mockCall) error {
+	err := validateArgs(call)
+	if err != nil && m.strict != nil {
+		m.strict.Errorf("%v", err)
+	}
+	return err
+}
+
+func validateArgs(call mockCall) error {
+	switch call.operation {
+	case "Ping", "Close":
+		return nil
+	case "InsertOne":
+		return validateDocument("document", call.filter)
+	case "InsertMany":
+		documents, _ := call.filter.([]any)
+		for i, document := range documents {
+			if err := validateDocument(fmt.Sprintf("documents[%d]", i), document); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Aggregate":
+		return validateDocuments("pipeline", call.filter)
+	case "BulkWrite":
+		models, _ := call.filter.([]any)
+		for i, model := range models {
+			if err := validateModel(fmt.Sprintf("models[%d]", i), model); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "ReplaceOne":
+		if err := validateDocument("filter", call.filter); err != nil {
+			return err
+		}
+		return validateDocument("replacement", call.update)
+	}
+	if err := validateDocument("filter", call.filter); err != nil {
+		return err
+	}
+	return validateDocuments("update", call.update)
+}
+
+// validateModel validates the documents held by a bulk write model
+func validateModel(path string, model any) error {
+	var fields map[string]any
+	switch wm := model.(type) {
+	case *mongo.InsertOneModel:
+		fields = map[string]any{"Document": wm.Document}
+	case *mongo.UpdateOneModel:
+		fields = map[string]any{"Filter": wm.Filter, "Update": wm.Update}
+	case *mongo.UpdateManyModel:
+		fields = map[string]any{"Filter": wm.Filter, "Update": wm.Update}
+	case *mongo.ReplaceOneModel:
+		fields = map[string]any{"Filter": wm.Filter, "Replacement": wm.Replacement}
+	case *mongo.DeleteOneModel:
+		fields = map[string]any{"Filter": wm.Filter}
+	case *mongo.DeleteManyModel:
+		fields = map[string]any{"Filter": wm.Filter}
+	}
+	for _, name := range sortedKeys(fields) {
+		if err := validateDocuments(path+"."+name, fields[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDocuments validates value as a document, or as a list of documents
+// when it is a slice such as a pipeline
+func validateDocuments(path string, value any) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || isDocumentSlice(value) {
+		return validateDocument(path, value)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := validateDocument(fmt.Sprintf("%s[%d]", path, i), v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDocumentSlice(value any) bool {
+	switch value.(type) {
+	case bson.D, []byte, bson.Raw:
+		return true
+	}
+	return false
+}
+
+// validateDocument marshals value the way the driver marshals a filter or
+// document. nil is accepted, as the mock has always allowed a nil filter.
+func validateDocument(path string, value any) error {
+	if value == nil {
+		return nil
+	}
+	if nan, ok := nanPath(reflect.ValueOf(value), path, 0); ok {
+		return fmt.Errorf("%w: %s is NaN", ErrInvalidBSON, nan)
+	}
+	if _, err := bson.Marshal(value); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidBSON, failingPath(reflect.ValueOf(value), path, 0), err)
+	}
+	return nil
+}
+
+// maxValidateDepth bounds the walks below so cyclic values terminate
+const maxValidateDepth = 32
+
+// failingPath descends from v, which does not marshal, into the first child
+// that does not marshal either and returns the path of the deepest one
+func failingPath(v reflect.Value, path string, depth int) string {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return path
+		}
+		v = v.Elem()
+	}
+	if depth >= maxValidateDepth {
+		return path
+	}
+	for _, child := range children(v, path) {
+		if !marshals(child.value) {
+			return failingPath(child.value, child.path, depth+1)
+		}
+	}
+	return path
+}
+
+// nanPath returns the path of the first NaN float in v
+func nanPath(v reflect.Value, path string, depth int) (string, bool) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64) && math.IsNaN(v.Float()) {
+		return path, true
+	}
+	if depth >= maxValidateDepth {
+		return "", false
+	}
+	for _, child := range children(v, path) {
+		if nan, ok := nanPath(child.value, child.path, depth+1); ok {
+			return nan, true
+		}
+	}
+	return "", false
+}
+
+type pathValue struct {
+	path  string
+	value reflect.Value
+}
+
+// children lists the elements, map entries and exported struct fields of v,
+// with bson.D elements named by their keys
+func children(v reflect.Value, path string) []pathValue {
+	var out []pathValue
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			out = append(out, pathValue{fmt.Sprintf("%s.%v", path, key), v.MapIndex(key)})
+		}
+	case reflect.Slice, reflect.Array:
+		if !v.CanInterface() {
+			return nil
+		}
+		if d, ok := v.Interface().(primitive.D); ok {
+			for _, e := range d {
+				out = append(out, pathValue{path + "." + e.Key, reflect.ValueOf(&e.Value).Elem()})
+			}
+			return out
+		}
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, pathValue{fmt.Sprintf("%s[%d]", path, i), v.Index(i)})
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				out = append(out, pathValue{path + "." + v.Type().Field(i).Name, v.Field(i)})
+			}
+		}
+	}
+	return out
+}
+
+func marshals(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return true
+	}
+	_, err := bson.Marshal(bson.D{{Key: "v", Value: v.Interface()}})
+	return err == nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMockDatabaseValidateBSON(t *testing.T) {
+	ctx := context.Background()
+
+	type withChannel struct {
+		Name    string
+		Updates chan int
+	}
+
+	tests := []struct {
+		name string
+		call func(*MockDatabase) error
+		path string
+	}{
+		{
+			name: "ChannelInStructFilter",
+			call: func(m *MockDatabase) error {
+				_, err := m.Find(ctx, "shop", "users", bson.M{"owner": withChannel{Name: "alice"}})
+				return err
+			},
+			path: "filter.owner.Updates",
+		},
+		{
+			name: "UnsupportedMapKey",
+			call: func(m *MockDatabase) error {
+				_, err := m.InsertOne(ctx, "shop", "users", bson.M{"scores": map[float64]int{1.5: 1}})
+				return err
+			},
+			path: "document.scores",
+		},
+		{
+			name: "NaNInUpdate",
+			call: func(m *MockDatabase) error {
+				_, err := m.UpdateOne(ctx, "shop", "users", bson.M{}, bson.D{{Key: "$set", Value: bson.D{{Key: "score", Value: math.NaN()}}}})
+				return err
+			},
+			path: "update.$set.score",
+		},
+		{
+			name: "PipelineStage",
+			call: func(m *MockDatabase) error {
+				_, err := m.Aggregate(ctx, "shop", "users", mongo.Pipeline{
+					{{Key: "$match", Value: bson.M{}}},
+					{{Key: "$match", Value: bson.M{"done": make(chan int)}}},
+				})
+				return err
+			},
+			path: "pipeline[1].$match.done",
+		},
+		{
+			name: "InsertManyDocument",
+			call: func(m *MockDatabase) error {
+				_, err := m.InsertMany(ctx, "shop", "users", []any{bson.M{}, bson.M{"f": func() {}}})
+				return err
+			},
+			path: "documents[1].f",
+		},
+		{
+			name: "BulkWriteModel",
+			call: func(m *MockDatabase) error {
+				_, err := m.BulkWrite(ctx, "shop", "users", []any{
+					mongo.NewReplaceOneModel().SetFilter(bson.M{}).SetReplacement(bson.M{"c": make(chan int)}),
+				})
+				return err
+			},
+			path: "models[0].Replacement.c",
+		},
+		{
+			name: "NonDocumentFilter",
+			call: func(m *MockDatabase) error {
+				_, err := m.Count(ctx, "shop", "users", "alice")
+				return err
+			},
+			path: "filter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockDatabase().ValidateBSON(true)
+			err := tt.call(mock)
+			if !errors.Is(err, ErrInvalidBSON) {
+				t.Fatalf("expected ErrInvalidBSON, got %v", err)
+			}
+			if !strings.Contains(err.Error(), ": "+tt.path+":") && !strings.Contains(err.Error(), ": "+tt.path+" is NaN") {
+				t.Errorf("expected the error to name %s, got %v", tt.path, err)
+			}
+			if history := mock.History(); len(history) != 1 || history[0].Source != SourceInvalid {
+				t.Errorf("expected one call with source %q, got %+v", SourceInvalid, history)
+			}
+		})
+	}
+
+	t.Run("ValidArgumentsPass", func(t *testing.T) {
+		mock := NewMockDatabase().ValidateBSON(true)
+		if _, err := mock.Find(ctx, "shop", "users", nil); err != nil {
+			t.Errorf("expected a nil filter to pass, got %v", err)
+		}
+		if _, err := mock.UpdateMany(ctx, "shop", "users", bson.M{"a": 1}, mongo.Pipeline{{{Key: "$set", Value: bson.M{"b": 2}}}}); err != nil {
+			t.Errorf("expected a pipeline update to pass, got %v", err)
+		}
+		if _, err := mock.InsertOne(ctx, "shop", "users", withChannel{Name: "alice"}); err == nil {
+			t.Error("expected the channel field to be rejected")
+		}
+	})
+
+	t.Run("OffByDefault", func(t *testing.T) {
+		mock := NewMockDatabase()
+		if _, err := mock.Find(ctx, "shop", "users", bson.M{"c": make(chan int)}); err != nil {
+			t.Errorf("expected no validation by default, got %v", err)
+		}
+	})
+
+	t.Run("StrictFailsTheTest", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		mock := NewMockDatabase().ValidateBSON(true).Strict(rec)
+		mock.Find(ctx, "shop", "users", bson.M{"c": make(chan int)})
+		if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "filter.c") {
+			t.Errorf("expected the test to fail naming filter.c, got %v", rec.errors)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// NewMockDatabaseT creates a new MockDatabase that verifies its expectations
+// when the test finishes
+func NewMockDatabaseT(t testing.TB) *MockDatabase {
+	t.Helper()
+	m := NewMockDatabase()
+	m.owner = t
+	t.Cleanup(func() {
+		m.AssertExpectations(t)
+	})
+	return m
+}
+
+// Verify returns an error listing every unconsumed queued response, every
+// non-optional scoped expectation that never matched or matched fewer times
+// than its Times limit, every expectation called again after it was
+// exhausted, and every unfinished script step or call that deviated from the
+// script, or nil if there are none
+func (m *MockDatabase) Verify() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var problems []string
+
+	mockValue := reflect.ValueOf(m).Elem()
+	iface := reflect.TypeOf((*DatabaseInterface)(nil)).Elem()
+	for i := 0; i < iface.NumMethod(); i++ {
+		name := iface.Method(i).Name + "Queue"
+		queue := mockValue.FieldByName(name)
+		for pos := 0; pos < queue.Len(); pos++ {
+			problems = append(problems, fmt.Sprintf("%s[%d]: queued response never consumed (%s)",
+				name, pos, describeResponse(queue.Index(pos))))
+		}
+	}
+
+	for i, e := range m.expectations {
+		switch {
+		case e.calls == 0 && !e.optional:
+			problems = append(problems, fmt.Sprintf("expectation #%d %s with %d filter matcher(s): never matched, %s",
+				i, e, len(e.filters), m.explainMiss(e)))
+		case e.calls < e.times && !e.optional:
+			problems = append(problems, fmt.Sprintf("expectation #%d %s: matched %d of %d expected call(s)",
+				i, e, e.calls, e.times))
+		}
+		if e.overCalls > 0 {
+			problems = append(problems, fmt.Sprintf("expectation #%d %s: expected %d call(s), called %d more time(s) after it was exhausted",
+				i, e, e.times, e.overCalls))
+		}
+	}
+
+	problems = append(problems, m.scriptProblems()...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("mock: unmet expectations:\n  " + strings.Join(problems, "\n  "))
+}
+
+// maxMissReasons caps the calls explainMiss describes
+const maxMissReasons = 5
+
+// explainMiss tells which clause of e the recorded calls failed: the
+// operation, the namespace, the filter or the options, judged by the calls
+// that got furthest; the caller holds m.mu
+func (m *MockDatabase) explainMiss(e *Expectation) string {
+	var ops, namespaces []Call
+	for _, call := range m.history {
+		if call.Operation != e.Operation {
+			continue
+		}
+		ops = append(ops, call)
+		if (e.Db == "" || e.Db == call.Db) && (e.Collection == "" || e.Collection == call.Collection) {
+			namespaces = append(namespaces, call)
+		}
+	}
+
+	switch {
+	case len(ops) == 0:
+		return fmt.Sprintf("operation: %s was never called", e.Operation)
+	case len(namespaces) == 0:
+		seen := map[string]bool{}
+		var got []string
+		for _, call := range ops {
+			ns := namespaceString(call.Db, call.Collection)
+			if !seen[ns] {
+				seen[ns] = true
+				got = append(got, ns)
+			}
+		}
+		return fmt.Sprintf("namespace: %s was called %d time(s) but only on %s",
+			e.Operation, len(ops), strings.Join(got, ", "))
+	}
+
+	var reasons []string
+	for _, call := range namespaces {
+		var filter any
+		if len(call.Args) > 0 {
+			filter = call.Args[0]
+		}
+		if call.Operation == "Distinct" && len(call.Args) > 1 {
+			// Args holds the field, then the filter
+			filter = call.Args[1]
+		}
+		if len(reasons) == maxMissReasons {
+			reasons = append(reasons, fmt.Sprintf("and %d more", len(namespaces)-maxMissReasons))
+			break
+		}
+		reason := fmt.Sprintf("call #%d: answered by a queued response or an earlier expectation", call.Seq)
+		if !e.matchesOptions(call.Opts) {
+			reason = fmt.Sprintf("call #%d: options did not match", call.Seq)
+		}
+		for j, matcher := range e.filters {
+			if !matcher(filter) {
+				reason = fmt.Sprintf("call #%d: filter %s failed matcher %d of %d", call.Seq, FilterShape(filter), j+1, len(e.filters))
+				break
+			}
+		}
+		reasons = append(reasons, reason)
+	}
+	return fmt.Sprintf("%s on %s was called %d time(s) but never matched:\n    %s",
+		e.Operation, namespaceString(e.Db, e.Collection), len(namespaces), strings.Join(reasons, "\n    "))
+}
+
+// AssertExpectations fails the test if Verify reports any unmet expectation
+func (m *MockDatabase) AssertExpectations(t testing.TB) bool {
+	t.Helper()
+	if err := m.Verify(); err != nil {
+		t.Error(err)
+		return false
+	}
+	return true
+}
+
+// describeResponse summarizes a queued XResponse struct for failure messages
+func describeResponse(v reflect.Value) string {
+	var parts []string
+	if f := v.FieldByName("Result"); f.IsValid() {
+		parts = append(parts, fmt.Sprintf("result=%v", f.Interface()))
+	}
+	if f := v.FieldByName("Err"); f.IsValid() {
+		parts = append(parts, fmt.Sprintf("err=%v", f.Interface()))
+	}
+	if f := v.FieldByName("Responder"); f.IsValid() && !f.IsNil() {
+		parts = []string{"responder"}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// recordingTB captures failures and cleanups so verification failures can be asserted
+type recordingTB struct {
+	testing.TB
+	errors   []string
+	logs     []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Failed() bool {
+	return len(r.errors) > 0
+}
+
+func (r *recordingTB) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Cleanup(fn func()) {
+	r.cleanups = append(r.cleanups, fn)
+}
+
+func (r *recordingTB) runCleanups() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestMockDatabaseVerify(t *testing.T) {
+	t.Run("AllConsumed", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{}, nil)
+		mock.OnFindOne("testdb", "users").Return(map[string]any{}, nil)
+
+		mock.Find(context.Background(), "testdb", "users", bson.M{})
+		mock.FindOne(context.Background(), "testdb", "users", bson.M{})
+
+		if err := mock.Verify(); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("UnconsumedQueue", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFind([]any{}, nil).
+			QueueFind(nil, errors.New("timeout")).
+			QueueInsertOne("id", nil)
+
+		mock.Find(context.Background(), "testdb", "users", bson.M{})
+
+		err := mock.Verify()
+		if err == nil {
+			t.Fatal("expected verification error")
+		}
+		for _, want := range []string{"FindQueue[0]", "err=timeout", "InsertOneQueue[0]"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error to mention %q, got %v", want, err)
+			}
+		}
+	})
+
+	t.Run("UnmatchedExpectation", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFind("testdb", "cameras").WithFilter(FilterHasKeys("site_id")).Return([]any{}, nil)
+
+		mock.Find(context.Background(), "testdb", "cameras", bson.M{"status": "on"})
+
+		err := mock.Verify()
+		if err == nil || !strings.Contains(err.Error(), "Find on testdb.cameras") {
+			t.Errorf("expected unmatched expectation error, got %v", err)
+		}
+	})
+
+	t.Run("OptionalExpectation", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.OnFind("testdb", "cameras").Return([]any{}, nil).Optional()
+
+		if err := mock.Verify(); err != nil {
+			t.Errorf("expected optional expectation to pass, got %v", err)
+		}
+	})
+
+	t.Run("NewMockDatabaseTVerifiesOnCleanup", func(t *testing.T) {
+		tb := &recordingTB{TB: t}
+		mock := NewMockDatabaseT(tb)
+		mock.QueuePing(nil)
+
+		tb.runCleanups()
+		if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "PingQueue[0]") {
+			t.Errorf("expected cleanup to report unconsumed ping, got %v", tb.errors)
+		}
+	})
+}
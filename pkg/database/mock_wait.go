@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// WaitForCalls blocks until op has been called at least n times or ctx is
+// done, for code under test that calls the database from a background
+// goroutine. It wakes on every recorded call rather than polling. Reset
+// clears the count, so after a Reset it waits for n new calls.
+func (m *MockDatabase) WaitForCalls(ctx context.Context, op string, n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := reflect.ValueOf(m).Elem().FieldByName(op + "Calls")
+	if !calls.IsValid() {
+		return fmt.Errorf("mock: unknown operation %q", op)
+	}
+	changed := m.callsChangedCond()
+	stop := context.AfterFunc(ctx, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		changed.Broadcast()
+	})
+	defer stop()
+
+	for {
+		// Re-read the field each time: Reset replaces the slice
+		got := reflect.ValueOf(m).Elem().FieldByName(op + "Calls").Len()
+		if got >= n {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("mock: waited for %d %s call(s), got %d: %w", n, op, got, err)
+		}
+		changed.Wait()
+	}
+}
+
+// EventuallyCalled fails the test unless op has been called at least n times
+// within timeout, see WaitForCalls
+func (m *MockDatabase) EventuallyCalled(t testing.TB, op string, n int, timeout time.Duration) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := m.WaitForCalls(ctx, op, n); err != nil {
+		t.Errorf("%v%s", err, formatCalls(m.recordedCalls(op)))
+		return false
+	}
+	return true
+}
+
+// callsChangedCond returns the condition signalled whenever a call is
+// recorded or the calls are reset; the caller holds m.mu
+func (m *MockDatabase) callsChangedCond() *sync.Cond {
+	if m.callsChanged == nil {
+		m.callsChanged = sync.NewCond(&m.mu)
+	}
+	return m.callsChanged
+}
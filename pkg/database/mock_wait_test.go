@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMockDatabaseWaitForCalls(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("WaitsForBackgroundCalls", func(t *testing.T) {
+		mock := NewMockDatabase()
+		go func() {
+			for i := 0; i < 3; i++ {
+				time.Sleep(5 * time.Millisecond)
+				mock.InsertOne(ctx, "app", "events", bson.M{"n": i})
+			}
+		}()
+
+		if err := mock.WaitForCalls(ctx, "InsertOne", 3); err != nil {
+			t.Fatalf("expected the calls to arrive, got %v", err)
+		}
+		if got := mock.CallsTo("InsertOne"); got != 3 {
+			t.Errorf("expected 3 calls, got %d", got)
+		}
+	})
+
+	t.Run("AlreadySatisfied", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Ping(ctx)
+
+		done, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := mock.WaitForCalls(done, "Ping", 1); err != nil {
+			t.Errorf("expected no wait when the count is reached, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.InsertOne(ctx, "app", "events", bson.M{})
+
+		waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := mock.WaitForCalls(waitCtx, "InsertOne", 2)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a deadline error, got %v", err)
+		}
+		if err.Error() != "mock: waited for 2 InsertOne call(s), got 1: context deadline exceeded" {
+			t.Errorf("unexpected message %q", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the wait to end at the deadline, took %v", elapsed)
+		}
+	})
+
+	t.Run("ResetRestartsTheCount", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Ping(ctx)
+
+		result := make(chan error)
+		go func() { result <- mock.WaitForCalls(ctx, "Ping", 2) }()
+		time.Sleep(5 * time.Millisecond)
+		mock.Reset()
+		mock.Ping(ctx)
+
+		select {
+		case err := <-result:
+			t.Fatalf("expected the wait to continue after Reset, returned %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		mock.Ping(ctx)
+		if err := <-result; err != nil {
+			t.Errorf("expected the wait to end after two new calls, got %v", err)
+		}
+	})
+
+	t.Run("UnknownOperation", func(t *testing.T) {
+		if err := NewMockDatabase().WaitForCalls(ctx, "Fnd", 1); err == nil {
+			t.Error("expected an error for an unknown operation")
+		}
+	})
+
+	t.Run("EventuallyCalled", func(t *testing.T) {
+		mock := NewMockDatabase()
+		go func() {
+			time.Sleep(5 * time.Millisecond)
+			mock.InsertOne(ctx, "app", "events", bson.M{})
+		}()
+		if !mock.EventuallyCalled(t, "InsertOne", 1, time.Second) {
+			t.Error("expected EventuallyCalled to succeed")
+		}
+
+		rec := &recordingTB{TB: t}
+		if mock.EventuallyCalled(rec, "InsertOne", 2, 10*time.Millisecond) || len(rec.errors) != 1 {
+			t.Errorf("expected one failure, got %v", rec.errors)
+		}
+	})
+}
@@ -0,0 +1,759 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InsertOneResponse represents a queued response for InsertOne
+type InsertOneResponse struct {
+	Result any
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueInsertOneFunc
+	Responder func(call InsertOneCall) (any, error)
+}
+
+// InsertManyResponse represents a queued response for InsertMany
+type InsertManyResponse struct {
+	Result []any
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueInsertManyFunc
+	Responder func(call InsertManyCall) ([]any, error)
+}
+
+// UpdateOneResponse represents a queued response for UpdateOne
+type UpdateOneResponse struct {
+	Result *UpdateResult
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueUpdateOneFunc
+	Responder func(call UpdateOneCall) (*UpdateResult, error)
+}
+
+// UpdateManyResponse represents a queued response for UpdateMany
+type UpdateManyResponse struct {
+	Result *UpdateResult
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueUpdateManyFunc
+	Responder func(call UpdateManyCall) (*UpdateResult, error)
+}
+
+// ReplaceOneResponse represents a queued response for ReplaceOne
+type ReplaceOneResponse struct {
+	Result *UpdateResult
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueReplaceOneFunc
+	Responder func(call ReplaceOneCall) (*UpdateResult, error)
+}
+
+// DeleteOneResponse represents a queued response for DeleteOne
+type DeleteOneResponse struct {
+	Result int64
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueDeleteOneFunc
+	Responder func(call DeleteOneCall) (int64, error)
+}
+
+// DeleteManyResponse represents a queued response for DeleteMany
+type DeleteManyResponse struct {
+	Result int64
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueDeleteManyFunc
+	Responder func(call DeleteManyCall) (int64, error)
+}
+
+// FindOneAndUpdateResponse represents a queued response for FindOneAndUpdate
+type FindOneAndUpdateResponse struct {
+	Result any
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueFindOneAndUpdateFunc
+	Responder func(call FindOneAndUpdateCall) (any, error)
+}
+
+// BulkWriteResponse represents a queued response for BulkWrite
+type BulkWriteResponse struct {
+	Result *BulkWriteResult
+	Err    error
+	Delay  time.Duration
+
+	// Responder computes the result from the recorded call, see QueueBulkWriteFunc
+	Responder func(call BulkWriteCall) (*BulkWriteResult, error)
+}
+
+// InsertOneCall records a call to InsertOne
+type InsertOneCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Document    any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// InsertManyCall records a call to InsertMany
+type InsertManyCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Documents   []any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// UpdateOneCall records a call to UpdateOne
+type UpdateOneCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Update      any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// UpdateManyCall records a call to UpdateMany
+type UpdateManyCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Update      any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// ReplaceOneCall records a call to ReplaceOne
+type ReplaceOneCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Replacement any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// DeleteOneCall records a call to DeleteOne
+type DeleteOneCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// DeleteManyCall records a call to DeleteMany
+type DeleteManyCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// FindOneAndUpdateCall records a call to FindOneAndUpdate
+type FindOneAndUpdateCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Filter      any
+	Update      any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// BulkWriteCall records a call to BulkWrite
+type BulkWriteCall struct {
+	Ctx         context.Context
+	Db          string
+	Collection  string
+	Models      []any
+	Opts        []any
+	CallOptions CallOptions
+	Chaos       bool
+}
+
+// setDefaultWriteFuncs installs the default write behaviors: inserts return
+// generated ObjectID hex strings, updates and deletes match nothing
+func (m *MockDatabase) setDefaultWriteFuncs() {
+	m.InsertOneFunc = func(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+		return primitive.NewObjectID().Hex(), nil
+	}
+	m.InsertManyFunc = func(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+		return generatedIDs(len(documents)), nil
+	}
+	m.UpdateOneFunc = func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+		return &UpdateResult{}, nil
+	}
+	m.UpdateManyFunc = func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+		return &UpdateResult{}, nil
+	}
+	m.ReplaceOneFunc = func(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+		return &UpdateResult{}, nil
+	}
+	m.DeleteOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+		return 0, nil
+	}
+	m.DeleteManyFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+		return 0, nil
+	}
+	m.FindOneAndUpdateFunc = func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+		return nil, mongo.ErrNoDocuments
+	}
+	m.BulkWriteFunc = func(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+		return &BulkWriteResult{UpsertedIDs: map[int64]any{}}, nil
+	}
+}
+
+// resetWriteCalls clears recorded write calls
+func (m *MockDatabase) resetWriteCalls() {
+	m.InsertOneCalls = []InsertOneCall{}
+	m.InsertManyCalls = []InsertManyCall{}
+	m.UpdateOneCalls = []UpdateOneCall{}
+	m.UpdateManyCalls = []UpdateManyCall{}
+	m.ReplaceOneCalls = []ReplaceOneCall{}
+	m.DeleteOneCalls = []DeleteOneCall{}
+	m.DeleteManyCalls = []DeleteManyCall{}
+	m.FindOneAndUpdateCalls = []FindOneAndUpdateCall{}
+	m.BulkWriteCalls = []BulkWriteCall{}
+}
+
+// resetWriteQueues clears queued write responses
+func (m *MockDatabase) resetWriteQueues() {
+	m.InsertOneQueue = []InsertOneResponse{}
+	m.InsertManyQueue = []InsertManyResponse{}
+	m.UpdateOneQueue = []UpdateOneResponse{}
+	m.UpdateManyQueue = []UpdateManyResponse{}
+	m.ReplaceOneQueue = []ReplaceOneResponse{}
+	m.DeleteOneQueue = []DeleteOneResponse{}
+	m.DeleteManyQueue = []DeleteManyResponse{}
+	m.FindOneAndUpdateQueue = []FindOneAndUpdateResponse{}
+	m.BulkWriteQueue = []BulkWriteResponse{}
+}
+
+// InsertOne implements DatabaseInterface
+func (m *MockDatabase) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "InsertOne", db: db, collection: collection, filter: document, opts: opts},
+		func(chaos bool) {
+			m.InsertOneCalls = append(m.InsertOneCalls, InsertOneCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Document:   document,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[any], bool) {
+			r, ok := popQueue(&m.InsertOneQueue)
+			return mockResponse[any]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (any, error) {
+			if m.InsertOneFunc != nil {
+				return m.InsertOneFunc(ctx, db, collection, document, opts...)
+			}
+			return primitive.NewObjectID().Hex(), nil
+		})
+}
+
+// InsertMany implements DatabaseInterface
+func (m *MockDatabase) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "InsertMany", db: db, collection: collection, filter: documents, opts: opts},
+		func(chaos bool) {
+			m.InsertManyCalls = append(m.InsertManyCalls, InsertManyCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Documents:  documents,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[[]any], bool) {
+			r, ok := popQueue(&m.InsertManyQueue)
+			return mockResponse[[]any]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() ([]any, error) {
+			if m.InsertManyFunc != nil {
+				return m.InsertManyFunc(ctx, db, collection, documents, opts...)
+			}
+			return generatedIDs(len(documents)), nil
+		})
+}
+
+// UpdateOne implements DatabaseInterface
+func (m *MockDatabase) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "UpdateOne", db: db, collection: collection, filter: filter, update: update, opts: opts},
+		func(chaos bool) {
+			m.UpdateOneCalls = append(m.UpdateOneCalls, UpdateOneCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Update:     update,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[*UpdateResult], bool) {
+			r, ok := popQueue(&m.UpdateOneQueue)
+			return mockResponse[*UpdateResult]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (*UpdateResult, error) {
+			if m.UpdateOneFunc != nil {
+				return m.UpdateOneFunc(ctx, db, collection, filter, update, opts...)
+			}
+			return &UpdateResult{}, nil
+		})
+}
+
+// UpdateMany implements DatabaseInterface
+func (m *MockDatabase) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "UpdateMany", db: db, collection: collection, filter: filter, update: update, opts: opts},
+		func(chaos bool) {
+			m.UpdateManyCalls = append(m.UpdateManyCalls, UpdateManyCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Update:     update,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[*UpdateResult], bool) {
+			r, ok := popQueue(&m.UpdateManyQueue)
+			return mockResponse[*UpdateResult]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (*UpdateResult, error) {
+			if m.UpdateManyFunc != nil {
+				return m.UpdateManyFunc(ctx, db, collection, filter, update, opts...)
+			}
+			return &UpdateResult{}, nil
+		})
+}
+
+// ReplaceOne implements DatabaseInterface
+func (m *MockDatabase) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "ReplaceOne", db: db, collection: collection, filter: filter, update: replacement, opts: opts},
+		func(chaos bool) {
+			m.ReplaceOneCalls = append(m.ReplaceOneCalls, ReplaceOneCall{
+				Ctx:         ctx,
+				Db:          db,
+				Collection:  collection,
+				Filter:      filter,
+				Replacement: replacement,
+				Opts:        opts,
+				Chaos:       chaos,
+			})
+		},
+		func() (mockResponse[*UpdateResult], bool) {
+			r, ok := popQueue(&m.ReplaceOneQueue)
+			return mockResponse[*UpdateResult]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (*UpdateResult, error) {
+			if m.ReplaceOneFunc != nil {
+				return m.ReplaceOneFunc(ctx, db, collection, filter, replacement, opts...)
+			}
+			return &UpdateResult{}, nil
+		})
+}
+
+// DeleteOne implements DatabaseInterface
+func (m *MockDatabase) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "DeleteOne", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			m.DeleteOneCalls = append(m.DeleteOneCalls, DeleteOneCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[int64], bool) {
+			r, ok := popQueue(&m.DeleteOneQueue)
+			return mockResponse[int64]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (int64, error) {
+			if m.DeleteOneFunc != nil {
+				return m.DeleteOneFunc(ctx, db, collection, filter, opts...)
+			}
+			return 0, nil
+		})
+}
+
+// DeleteMany implements DatabaseInterface
+func (m *MockDatabase) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "DeleteMany", db: db, collection: collection, filter: filter, opts: opts},
+		func(chaos bool) {
+			m.DeleteManyCalls = append(m.DeleteManyCalls, DeleteManyCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[int64], bool) {
+			r, ok := popQueue(&m.DeleteManyQueue)
+			return mockResponse[int64]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (int64, error) {
+			if m.DeleteManyFunc != nil {
+				return m.DeleteManyFunc(ctx, db, collection, filter, opts...)
+			}
+			return 0, nil
+		})
+}
+
+// FindOneAndUpdate implements DatabaseInterface
+func (m *MockDatabase) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "FindOneAndUpdate", db: db, collection: collection, filter: filter, update: update, opts: opts},
+		func(chaos bool) {
+			m.FindOneAndUpdateCalls = append(m.FindOneAndUpdateCalls, FindOneAndUpdateCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Filter:     filter,
+				Update:     update,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[any], bool) {
+			r, ok := popQueue(&m.FindOneAndUpdateQueue)
+			return mockResponse[any]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (any, error) {
+			if m.FindOneAndUpdateFunc != nil {
+				return m.FindOneAndUpdateFunc(ctx, db, collection, filter, update, opts...)
+			}
+			return nil, mongo.ErrNoDocuments
+		})
+}
+
+// BulkWrite implements DatabaseInterface
+func (m *MockDatabase) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	return invoke(m, mockCall{ctx: ctx, operation: "BulkWrite", db: db, collection: collection, filter: models, opts: opts},
+		func(chaos bool) {
+			m.BulkWriteCalls = append(m.BulkWriteCalls, BulkWriteCall{
+				Ctx:        ctx,
+				Db:         db,
+				Collection: collection,
+				Models:     models,
+				Opts:       opts,
+				Chaos:      chaos,
+			})
+		},
+		func() (mockResponse[*BulkWriteResult], bool) {
+			r, ok := popQueue(&m.BulkWriteQueue)
+			return mockResponse[*BulkWriteResult]{result: r.Result, err: r.Err, delay: r.Delay, respond: responder(r.Responder)}, ok
+		},
+		func() (*BulkWriteResult, error) {
+			if m.BulkWriteFunc != nil {
+				return m.BulkWriteFunc(ctx, db, collection, models, opts...)
+			}
+			return &BulkWriteResult{UpsertedIDs: map[int64]any{}}, nil
+		})
+}
+
+// ExpectInsertOne sets up an expectation for InsertOne
+func (m *MockDatabase) ExpectInsertOne(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertOneFunc = func(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectInsertMany sets up an expectation for InsertMany
+func (m *MockDatabase) ExpectInsertMany(result []any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertManyFunc = func(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectUpdateOne sets up an expectation for UpdateOne
+func (m *MockDatabase) ExpectUpdateOne(result *UpdateResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateOneFunc = func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectUpdateMany sets up an expectation for UpdateMany
+func (m *MockDatabase) ExpectUpdateMany(result *UpdateResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateManyFunc = func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectReplaceOne sets up an expectation for ReplaceOne
+func (m *MockDatabase) ExpectReplaceOne(result *UpdateResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ReplaceOneFunc = func(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectDeleteOne sets up an expectation for DeleteOne
+func (m *MockDatabase) ExpectDeleteOne(result int64, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteOneFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectDeleteMany sets up an expectation for DeleteMany
+func (m *MockDatabase) ExpectDeleteMany(result int64, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteManyFunc = func(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectFindOneAndUpdate sets up an expectation for FindOneAndUpdate
+func (m *MockDatabase) ExpectFindOneAndUpdate(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneAndUpdateFunc = func(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+		return result, err
+	}
+	return m
+}
+
+// ExpectBulkWrite sets up an expectation for BulkWrite
+func (m *MockDatabase) ExpectBulkWrite(result *BulkWriteResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.BulkWriteFunc = func(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+		return result, err
+	}
+	return m
+}
+
+// QueueInsertOne adds a InsertOne response to the queue for sequential calls
+func (m *MockDatabase) QueueInsertOne(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertOneQueue = append(m.InsertOneQueue, InsertOneResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueInsertMany adds a InsertMany response to the queue for sequential calls
+func (m *MockDatabase) QueueInsertMany(result []any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertManyQueue = append(m.InsertManyQueue, InsertManyResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueUpdateOne adds a UpdateOne response to the queue for sequential calls
+func (m *MockDatabase) QueueUpdateOne(result *UpdateResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateOneQueue = append(m.UpdateOneQueue, UpdateOneResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueUpdateMany adds a UpdateMany response to the queue for sequential calls
+func (m *MockDatabase) QueueUpdateMany(result *UpdateResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateManyQueue = append(m.UpdateManyQueue, UpdateManyResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueReplaceOne adds a ReplaceOne response to the queue for sequential calls
+func (m *MockDatabase) QueueReplaceOne(result *UpdateResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ReplaceOneQueue = append(m.ReplaceOneQueue, ReplaceOneResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueDeleteOne adds a DeleteOne response to the queue for sequential calls
+func (m *MockDatabase) QueueDeleteOne(result int64, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteOneQueue = append(m.DeleteOneQueue, DeleteOneResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueDeleteMany adds a DeleteMany response to the queue for sequential calls
+func (m *MockDatabase) QueueDeleteMany(result int64, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteManyQueue = append(m.DeleteManyQueue, DeleteManyResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueFindOneAndUpdate adds a FindOneAndUpdate response to the queue for sequential calls
+func (m *MockDatabase) QueueFindOneAndUpdate(result any, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneAndUpdateQueue = append(m.FindOneAndUpdateQueue, FindOneAndUpdateResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueBulkWrite adds a BulkWrite response to the queue for sequential calls
+func (m *MockDatabase) QueueBulkWrite(result *BulkWriteResult, err error) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.BulkWriteQueue = append(m.BulkWriteQueue, BulkWriteResponse{Result: result, Err: err})
+	return m
+}
+
+// QueueInsertOneDelayed adds a InsertOne response that is returned after delay
+func (m *MockDatabase) QueueInsertOneDelayed(result any, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertOneQueue = append(m.InsertOneQueue, InsertOneResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueInsertManyDelayed adds a InsertMany response that is returned after delay
+func (m *MockDatabase) QueueInsertManyDelayed(result []any, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.InsertManyQueue = append(m.InsertManyQueue, InsertManyResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueUpdateOneDelayed adds a UpdateOne response that is returned after delay
+func (m *MockDatabase) QueueUpdateOneDelayed(result *UpdateResult, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateOneQueue = append(m.UpdateOneQueue, UpdateOneResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueUpdateManyDelayed adds a UpdateMany response that is returned after delay
+func (m *MockDatabase) QueueUpdateManyDelayed(result *UpdateResult, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateManyQueue = append(m.UpdateManyQueue, UpdateManyResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueReplaceOneDelayed adds a ReplaceOne response that is returned after delay
+func (m *MockDatabase) QueueReplaceOneDelayed(result *UpdateResult, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ReplaceOneQueue = append(m.ReplaceOneQueue, ReplaceOneResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueDeleteOneDelayed adds a DeleteOne response that is returned after delay
+func (m *MockDatabase) QueueDeleteOneDelayed(result int64, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteOneQueue = append(m.DeleteOneQueue, DeleteOneResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueDeleteManyDelayed adds a DeleteMany response that is returned after delay
+func (m *MockDatabase) QueueDeleteManyDelayed(result int64, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteManyQueue = append(m.DeleteManyQueue, DeleteManyResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueFindOneAndUpdateDelayed adds a FindOneAndUpdate response that is returned after delay
+func (m *MockDatabase) QueueFindOneAndUpdateDelayed(result any, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FindOneAndUpdateQueue = append(m.FindOneAndUpdateQueue, FindOneAndUpdateResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// QueueBulkWriteDelayed adds a BulkWrite response that is returned after delay
+func (m *MockDatabase) QueueBulkWriteDelayed(result *BulkWriteResult, err error, delay time.Duration) *MockDatabase {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.BulkWriteQueue = append(m.BulkWriteQueue, BulkWriteResponse{Result: result, Err: err, Delay: delay})
+	return m
+}
+
+// generatedIDs returns n fresh ObjectID hex strings, mimicking server-assigned _ids
+func generatedIDs(n int) []any {
+	ids := make([]any, n)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID().Hex()
+	}
+	return ids
+}
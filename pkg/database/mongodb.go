@@ -2,25 +2,156 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	moptions "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 // MongoOptions holds the configuration for Mongo
 type MongoOptions struct {
-	Uri           string `validate:"required_without=Host"`
-	Host          string `validate:"required_without=Uri"`
-	AuthSource    string `validate:"required_without=Uri"`
-	Username      string `validate:"required_without=Uri"`
-	Password      string `validate:"required_without=Uri"`
-	Timeout       int    `validate:"required,gte=0"`
+	Uri string `validate:"required_without_all=Host SocketPath"`
+	// Host is the host section of the URI: one host, or the seed list of
+	// Hosts joined by commas
+	Host string `validate:"required_without_all=Uri SocketPath"`
+	// Hosts is the seed list of a replica set or sharded cluster, each
+	// entry a host with an optional port
+	Hosts []string
+	// SocketPath is the Unix domain socket of a local mongod, used instead
+	// of hosts
+	SocketPath string
+	AuthSource string `validate:"required_without=Uri"`
+	Username   string `validate:"required_without_all=Uri CredentialProvider"`
+	Password   string `validate:"required_without_all=Uri CredentialProvider"`
+	// CredentialProvider supplies Username and Password when connecting
+	CredentialProvider CredentialProvider
+	// Timeout bounds connecting, in milliseconds.
+	//
+	// Deprecated: use TimeoutDuration, which wins when both are set.
+	Timeout int `validate:"gte=0"`
+	// TimeoutDuration bounds connecting; zero means no deadline but must be
+	// set explicitly with SetTimeoutDuration(0)
+	TimeoutDuration time.Duration `validate:"gte=0"`
+	// ClientTimeout bounds every operation, server selection included, that
+	// runs without a context deadline; zero leaves operations unbounded
+	ClientTimeout time.Duration `validate:"gte=0"`
 	AuthMechanism string
 	ReplicaSet    string
 	RetryWrites   bool
+	// TLS enables TLS; CAFile is a PEM bundle of the certificate authorities
+	// to trust instead of the system's, and implies TLS
+	TLS    bool
+	CAFile string
+	// ReadPreference is a mode such as "primary" or "secondaryPreferred"
+	ReadPreference string
+	// ReadConcern is a level such as "local" or "majority"
+	ReadConcern string
+	// WriteConcern is "majority", a number of members or a tag set name
+	WriteConcern string
+	// CommandLogging logs every command at debug level to stderr
+	CommandLogging bool
+	// DisableCommandAttributes leaves the command out of the spans traced
+	// for each operation
+	DisableCommandAttributes bool
+	// Dialer opens the connections; ProxyURL routes them through a SOCKS5
+	// proxy, SSHTunnel through a bastion host, and KeepAlive sets their TCP
+	// keepalive period
+	Dialer    ContextDialer
+	ProxyURL  string
+	SSHTunnel *SSHConfig
+	KeepAlive time.Duration
+	BSON      *BSONOptions
+	// ReadOnly makes New refuse every write, see WithReadOnly
+	ReadOnly bool
+	// VerifyConnection makes New ping the client before returning it
+	VerifyConnection bool
+	// SRVMaxHosts limits the hosts picked from the SRV record, and
+	// SRVServiceName replaces its "mongodb" service name; both only apply to
+	// mongodb+srv connection strings
+	SRVMaxHosts    int `validate:"gte=0"`
+	SRVServiceName string
+	// TopologyChange is called with the new topology whenever the driver's
+	// view of the deployment changes, see OnTopologyChange
+	TopologyChange func(TopologyDescription) `json:"-"`
+	// RotationGracePeriod is how long RotateCredentials leaves the previous
+	// client open for the operations still running on it, 30s by default
+	RotationGracePeriod time.Duration `validate:"gte=0"`
+	// ConnectMode Eager opens MinPoolSize connections before New returns
+	ConnectMode ConnectMode
+	MinPoolSize uint64
+	MaxPoolSize uint64
+	PoolMonitor *event.PoolMonitor
+	// AutoEncryption enables client-side field level encryption
+	AutoEncryption *AutoEncryptionOptions
+
+	// timeoutSet records that the builder set TimeoutDuration, so zero is a
+	// choice
+	timeoutSet bool
+	// explicit records the other fields the builder set whose zero value is
+	// a choice too, such as RetryWrites false, for MergeOptions
+	explicit map[string]bool
+	// buildErr is an error of the builder, such as an unknown profile,
+	// returned when the options are used
+	buildErr error
+}
+
+// validateTimeout requires the timeout to be set, through the builder or as
+// a non-zero value, and rejects a TimeoutDuration under a millisecond, which
+// is most likely a count of milliseconds without its unit
+func (o *MongoOptions) validateTimeout() error {
+	if !o.timeoutSet && o.connectTimeout() == 0 {
+		return errors.New("mongo options: timeout is required, use SetTimeoutDuration(0) for no deadline")
+	}
+	if o.TimeoutDuration > 0 && o.TimeoutDuration < time.Millisecond {
+		return fmt.Errorf("mongo options: timeout %s is under a millisecond, use SetTimeoutDuration with a unit such as 5*time.Second", o.TimeoutDuration)
+	}
+	return nil
+}
+
+// connectTimeout returns TimeoutDuration when it is set and the deprecated
+// Timeout in milliseconds otherwise
+func (o *MongoOptions) connectTimeout() time.Duration {
+	if o.timeoutSet || o.TimeoutDuration != 0 {
+		return o.TimeoutDuration
+	}
+	return time.Duration(o.Timeout) * time.Millisecond
+}
+
+// validateSocketPath checks a Unix domain socket path and rejects the options
+// that do not apply to one
+func (o *MongoOptions) validateSocketPath() error {
+	if o.SocketPath == "" {
+		return nil
+	}
+	if o.Uri != "" || len(o.seedList()) > 0 {
+		return errors.New("mongo options: a socket path cannot be combined with a URI or hosts")
+	}
+	if o.TLS || o.CAFile != "" {
+		return errors.New("mongo options: TLS does not apply to a Unix domain socket")
+	}
+	// The driver dials a Unix socket for hosts ending in .sock, and would
+	// read a colon as the start of a port
+	if !strings.HasPrefix(o.SocketPath, "/") || !strings.HasSuffix(o.SocketPath, ".sock") {
+		return fmt.Errorf("mongo options: socket path %q must be absolute and end in .sock", o.SocketPath)
+	}
+	if strings.Contains(o.SocketPath, ":") {
+		return fmt.Errorf("mongo options: socket path %q must not contain ':'", o.SocketPath)
+	}
+	return nil
 }
 
 // MongoOptionsBuilder provides a fluent interface for building Mongo options
@@ -41,9 +172,25 @@ func (b *MongoOptionsBuilder) SetUri(uri string) *MongoOptionsBuilder {
 	return b
 }
 
-// SetHost sets the host
+// SetHost sets a single host, with an optional port
 func (b *MongoOptionsBuilder) SetHost(host string) *MongoOptionsBuilder {
-	b.options.Host = host
+	return b.SetHosts([]string{host})
+}
+
+// SetHosts sets the seed list, so the client still discovers the replica set
+// when one of the hosts is down at startup
+//
+//	SetHosts([]string{"db1.example.com:27017", "db2.example.com:27018", "db3.example.com"})
+func (b *MongoOptionsBuilder) SetHosts(hosts []string) *MongoOptionsBuilder {
+	b.options.Hosts = hosts
+	b.options.Host = strings.Join(hosts, ",")
+	return b
+}
+
+// SetSocketPath connects to a mongod listening on a Unix domain socket, such
+// as /tmp/mongodb-27017.sock, instead of hosts
+func (b *MongoOptionsBuilder) SetSocketPath(path string) *MongoOptionsBuilder {
+	b.options.SocketPath = path
 	return b
 }
 
@@ -77,9 +224,24 @@ func (b *MongoOptionsBuilder) SetPassword(password string) *MongoOptionsBuilder
 	return b
 }
 
-// SetTimeout sets the timeout
+// SetTimeout sets the timeout in milliseconds
 func (b *MongoOptionsBuilder) SetTimeout(timeout int) *MongoOptionsBuilder {
-	b.options.Timeout = timeout
+	return b.SetTimeoutDuration(time.Duration(timeout) * time.Millisecond)
+}
+
+// SetTimeoutDuration sets the timeout; zero means no client-imposed deadline
+func (b *MongoOptionsBuilder) SetTimeoutDuration(timeout time.Duration) *MongoOptionsBuilder {
+	b.options.TimeoutDuration = timeout
+	b.options.timeoutSet = true
+	return b
+}
+
+// SetClientTimeout sets the client-side operation timeout, which bounds each
+// operation from server selection to the reply. A context deadline on the
+// operation takes precedence, and the MaxTime of operation options is
+// ignored while it is set.
+func (b *MongoOptionsBuilder) SetClientTimeout(timeout time.Duration) *MongoOptionsBuilder {
+	b.options.ClientTimeout = timeout
 	return b
 }
 
@@ -88,6 +250,76 @@ func (b *MongoOptionsBuilder) SetTimeout(timeout int) *MongoOptionsBuilder {
 // https://stackoverflow.com/questions/70260941/documentdb-mongodb-updateone-retryable-writes-are-not-supported
 func (b *MongoOptionsBuilder) SetRetryWrites(retryWrites bool) *MongoOptionsBuilder {
 	b.options.RetryWrites = retryWrites
+	b.mark("RetryWrites")
+	return b
+}
+
+// SetTLS enables or disables TLS
+func (b *MongoOptionsBuilder) SetTLS(enabled bool) *MongoOptionsBuilder {
+	b.options.TLS = enabled
+	b.mark("TLS")
+	return b
+}
+
+// SetCAFile sets the PEM bundle of certificate authorities to trust and
+// enables TLS; an empty path clears the bundle and leaves TLS as it is
+func (b *MongoOptionsBuilder) SetCAFile(path string) *MongoOptionsBuilder {
+	b.options.CAFile = path
+	if path != "" {
+		b.SetTLS(true)
+	}
+	return b
+}
+
+// SetReadPreference sets the read preference mode: primary,
+// primaryPreferred, secondary, secondaryPreferred or nearest
+func (b *MongoOptionsBuilder) SetReadPreference(mode string) *MongoOptionsBuilder {
+	b.options.ReadPreference = mode
+	return b
+}
+
+// SetReadConcern sets the read concern level: local, available, majority,
+// linearizable or snapshot
+func (b *MongoOptionsBuilder) SetReadConcern(level string) *MongoOptionsBuilder {
+	b.options.ReadConcern = level
+	return b
+}
+
+// SetWriteConcern sets the write concern: "majority", a number of members
+// such as "1", or the name of a tag set
+func (b *MongoOptionsBuilder) SetWriteConcern(w string) *MongoOptionsBuilder {
+	b.options.WriteConcern = w
+	return b
+}
+
+// SetCommandLogging logs every command the driver sends at debug level to
+// stderr
+func (b *MongoOptionsBuilder) SetCommandLogging(enabled bool) *MongoOptionsBuilder {
+	b.options.CommandLogging = enabled
+	b.mark("CommandLogging")
+	return b
+}
+
+// SetCommandAttributesDisabled keeps commands, and the values in their
+// filters, out of the OpenTelemetry spans
+func (b *MongoOptionsBuilder) SetCommandAttributesDisabled(disabled bool) *MongoOptionsBuilder {
+	b.options.DisableCommandAttributes = disabled
+	b.mark("DisableCommandAttributes")
+	return b
+}
+
+// SetVerifyConnection makes New ping the client, within the timeout, and
+// fail when it does not answer instead of failing on the first operation
+func (b *MongoOptionsBuilder) SetVerifyConnection(verify bool) *MongoOptionsBuilder {
+	b.options.VerifyConnection = verify
+	b.mark("VerifyConnection")
+	return b
+}
+
+// SetBSONOptions sets the codec registry and encoding options used by the
+// client and by the typed helpers such as FindAs
+func (b *MongoOptionsBuilder) SetBSONOptions(opts BSONOptions) *MongoOptionsBuilder {
+	b.options.BSON = &opts
 	return b
 }
 
@@ -100,100 +332,430 @@ func (b *MongoOptionsBuilder) Build() *MongoOptions {
 type MongoClient struct {
 	Client  *mongo.Client
 	Options *MongoOptions
+
+	// tunnel is the SSH tunnel of the client, closed with it
+	tunnel *sshTunnel
+	// pool counts the open connections for Warmup
+	pool *poolCounter
 }
 
-// NewMongoClient creates a new MongoClient with the provided MongoDB settings
+var (
+	_ DatabaseInterface = (*MongoClient)(nil)
+	_ EstimatedCounter  = (*MongoClient)(nil)
+	_ Indexer           = (*MongoClient)(nil)
+	_ SchemaManager     = (*MongoClient)(nil)
+	_ Watcher           = (*MongoClient)(nil)
+	_ Transactor        = (*MongoClient)(nil)
+	_ ViewManager       = (*MongoClient)(nil)
+)
+
+// NewMongoClient creates a new MongoClient with the provided MongoDB settings.
+// options is only read, so one MongoOptions can configure several clients.
+// On an error no client is returned, and nothing is left open.
 func NewMongoClient(options *MongoOptions) (DatabaseInterface, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(options.Timeout)*time.Millisecond)
-	defer cancel()
-	if options.Uri != "" {
-		return newMongoClientFromURI(ctx, options)
+	return NewMongoClientWithContext(context.Background(), options)
+}
+
+// NewMongoClientWithContext is NewMongoClient bounded by ctx as well as the
+// timeout of options
+func NewMongoClientWithContext(ctx context.Context, options *MongoOptions) (DatabaseInterface, error) {
+	m, err := newMongoClient(ctx, options)
+	if err != nil {
+		return nil, err
 	}
-	return newMongoClientFromComponents(ctx, options)
+	return m, nil
 }
 
-func newMongoClientFromURI(ctx context.Context, options *MongoOptions) (DatabaseInterface, error) {
-	serverAPI := moptions.ServerAPI(moptions.ServerAPIVersion1)
-	opts := moptions.Client().
-		ApplyURI(options.Uri).
-		SetServerAPIOptions(serverAPI).
-		SetRetryWrites(options.RetryWrites).
-		SetMonitor(otelmongo.NewMonitor(otelmongo.WithCommandAttributeDisabled(false)))
+// newMongoClient connects within ctx and the timeout of options, fetching the
+// credentials from its provider first when it has one
+func newMongoClient(ctx context.Context, options *MongoOptions) (*MongoClient, error) {
+	if err := options.validateTimeout(); err != nil {
+		return nil, err
+	}
+	if err := options.AutoEncryption.Validate(); err != nil {
+		return nil, err
+	}
+	if timeout := options.connectTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	options, err := resolveCredentials(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts, err := buildClientOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	pool := &poolCounter{}
+	clientOpts.SetPoolMonitor(pool.monitor(clientOpts.PoolMonitor))
 
-	client, err := mongo.Connect(ctx, opts)
-	return &MongoClient{
+	client, err := mongo.Connect(ctx, clientOpts)
+	tunnel, _ := clientOpts.Dialer.(*sshTunnel)
+	if err != nil {
+		if tunnel != nil {
+			tunnel.Close()
+		}
+		return nil, err
+	}
+	m := &MongoClient{
 		Client:  client,
 		Options: options,
-	}, err
+		tunnel:  tunnel,
+		pool:    pool,
+	}
+	if options.ConnectMode == Eager {
+		if err := m.warmup(ctx, max(int(options.MinPoolSize), 1)); err != nil {
+			m.Close(context.WithoutCancel(ctx))
+			return nil, err
+		}
+	}
+	return m, nil
 }
 
-func newMongoClientFromComponents(ctx context.Context, options *MongoOptions) (DatabaseInterface, error) {
-	// Check if host contains mongodb.net (Atlas) - use mongodb+srv://
-	protocol := "mongodb://"
-	if len(options.Host) > 11 && options.Host[len(options.Host)-11:] == "mongodb.net" {
-		protocol = "mongodb+srv://"
+// buildClientOptions returns the driver configuration for options. Both ways
+// of connecting share every setting; they only differ in where the address
+// and credentials come from: the URI, or the hosts and SetAuth.
+func buildClientOptions(options *MongoOptions) (*moptions.ClientOptions, error) {
+	if options.buildErr != nil {
+		return nil, options.buildErr
+	}
+	if err := options.validateSocketPath(); err != nil {
+		return nil, err
+	}
+	if err := options.validateSRV(); err != nil {
+		return nil, err
+	}
+	opts := moptions.Client()
+	if options.Uri != "" {
+		uri, err := appendQuery(options.Uri, options.srvQuery())
+		if err != nil {
+			return nil, err
+		}
+		opts.ApplyURI(uri)
+		// Credentials from a provider take the place of any in the URI
+		if options.CredentialProvider != nil && options.Username != "" {
+			opts.SetAuth(options.credential())
+		}
+	} else {
+		uri, err := componentURI(options)
+		if err != nil {
+			return nil, err
+		}
+		opts.ApplyURI(uri).SetAuth(options.credential())
+	}
+
+	opts.
+		SetServerAPIOptions(moptions.ServerAPI(moptions.ServerAPIVersion1)).
+		SetRetryWrites(options.RetryWrites).
+		SetMonitor(otelmongo.NewMonitor(otelmongo.WithCommandAttributeDisabled(options.DisableCommandAttributes)))
+
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	if options.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(options.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("mongo options: %w", err)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("mongo options: %w", err)
+		}
+		opts.SetReadPreference(rp)
+	}
+	if options.ReadConcern != "" {
+		if !slices.Contains(readConcernLevels, options.ReadConcern) {
+			return nil, fmt.Errorf("mongo options: unknown read concern %q, expected one of %v", options.ReadConcern, readConcernLevels)
+		}
+		opts.SetReadConcern(&readconcern.ReadConcern{Level: options.ReadConcern})
+	}
+	if options.WriteConcern != "" {
+		opts.SetWriteConcern(writeConcern(options.WriteConcern))
+	}
+	if options.CommandLogging {
+		opts.SetLoggerOptions(moptions.Logger().SetComponentLevel(moptions.LogComponentCommand, moptions.LogLevelDebug))
+	}
+	dialer, err := options.contextDialer()
+	if err != nil {
+		return nil, err
+	}
+	if dialer != nil {
+		opts.SetDialer(dialer)
+	}
+
+	if options.MaxPoolSize > 0 && options.MinPoolSize > options.MaxPoolSize {
+		return nil, fmt.Errorf("mongo options: min pool size %d exceeds max pool size %d", options.MinPoolSize, options.MaxPoolSize)
+	}
+	if options.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(options.MaxPoolSize)
+	}
+	if options.MinPoolSize > 0 {
+		opts.SetMinPoolSize(options.MinPoolSize)
+	}
+	if options.PoolMonitor != nil {
+		opts.SetPoolMonitor(options.PoolMonitor)
+	}
+	if options.TopologyChange != nil {
+		opts.SetServerMonitor(topologyMonitor(options.TopologyChange))
+	}
+	if options.ClientTimeout > 0 {
+		if err := validateClientTimeout(options.ClientTimeout, opts); err != nil {
+			return nil, err
+		}
+		opts.SetTimeout(options.ClientTimeout)
+	}
+
+	opts = options.BSON.clientOptions(opts)
+	return options.AutoEncryption.clientOptions(opts), nil
+}
+
+// validateClientTimeout rejects the timeouts of opts that contradict a
+// client-side operation timeout
+func validateClientTimeout(timeout time.Duration, opts *moptions.ClientOptions) error {
+	if opts.ServerSelectionTimeout != nil && *opts.ServerSelectionTimeout > timeout {
+		return fmt.Errorf("mongo options: client timeout %s is shorter than the server selection timeout %s, so operations would time out before a server is selected",
+			timeout, *opts.ServerSelectionTimeout)
+	}
+	// The driver leaves these undefined alongside a client timeout
+	if opts.SocketTimeout != nil {
+		return errors.New("mongo options: a client timeout cannot be combined with socketTimeoutMS")
+	}
+	if opts.WriteConcern != nil && opts.WriteConcern.WTimeout > 0 {
+		return errors.New("mongo options: a client timeout cannot be combined with wtimeoutMS")
+	}
+	return nil
+}
+
+// writeConcern returns the write concern w names
+func writeConcern(w string) *writeconcern.WriteConcern {
+	if w == "majority" {
+		return writeconcern.Majority()
+	}
+	if n, err := strconv.Atoi(w); err == nil {
+		return &writeconcern.WriteConcern{W: n}
+	}
+	return writeconcern.Custom(w)
+}
+
+var readConcernLevels = []string{"local", "available", "majority", "linearizable", "snapshot"}
+
+// tlsConfig returns the TLS configuration of the options, nil when TLS is
+// not enabled by them
+func (o *MongoOptions) tlsConfig() (*tls.Config, error) {
+	if !o.TLS && o.CAFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mongo options: CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mongo options: CA file %s holds no PEM certificates", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// credential returns the credentials of a connection from hosts. The auth
+// mechanism defaults to SCRAM-SHA-256 without writing it back to options.
+func (o *MongoOptions) credential() moptions.Credential {
+	authMechanism := o.AuthMechanism
+	if authMechanism == "" {
+		authMechanism = "SCRAM-SHA-256"
+	}
+	return moptions.Credential{
+		AuthMechanism: authMechanism,
+		AuthSource:    o.AuthSource,
+		Username:      o.Username,
+		Password:      o.Password,
+	}
+}
+
+// BuildURI returns the URI the client connects with, for debugging. The
+// password of a URI set with SetUri is redacted; a URI built from hosts
+// carries no credentials, which are passed to the driver separately.
+func (o *MongoOptions) BuildURI() (string, error) {
+	if err := o.validateSocketPath(); err != nil {
+		return "", err
+	}
+	if err := o.validateSRV(); err != nil {
+		return "", err
 	}
+	if o.Uri != "" {
+		uri, err := appendQuery(o.Uri, o.srvQuery())
+		if err != nil {
+			return "", err
+		}
+		return redactURI(uri), nil
+	}
+	return componentURI(o)
+}
 
-	uri := fmt.Sprintf("%s%s:%s@%s", protocol, options.Username, options.Password, options.Host)
+// redactURI replaces the password in the userinfo of uri with xxxxx
+func redactURI(uri string) string {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return uri
+	}
+	authority := rest
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority = rest[:i]
+	}
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return uri
+	}
+	user, _, hasPassword := strings.Cut(authority[:at], ":")
+	if !hasPassword {
+		return uri
+	}
+	return scheme + "://" + user + ":xxxxx" + rest[at:]
+}
+
+// componentURI builds the connection URI from the seed list or the socket
+// path. Credentials are left out, so reserved characters in them cannot break
+// the URI, and passed with SetAuth instead.
+func componentURI(options *MongoOptions) (string, error) {
+	uri, err := hostsURI(options)
+	if err != nil {
+		return "", err
+	}
+	query := options.srvQuery()
 	// Specify the ReplicaSet if provided (not needed for SRV)
 	if options.ReplicaSet != "" {
-		uri = fmt.Sprintf("%s/?replicaSet=%s", uri, options.ReplicaSet)
+		query.Set("replicaSet", options.ReplicaSet)
+	}
+	if len(query) > 0 {
+		uri += "/?" + query.Encode()
 	}
+	return uri, nil
+}
 
-	// Default to SCRAM-SHA-256 if no AuthMechanism is provided
-	if options.AuthMechanism == "" {
-		options.AuthMechanism = "SCRAM-SHA-256"
+// hostsURI returns the scheme and host section of the connection URI
+func hostsURI(options *MongoOptions) (string, error) {
+	if options.SocketPath != "" {
+		return "mongodb://" + escapeSocketPath(options.SocketPath), nil
+	}
+	hosts := options.seedList()
+	if len(hosts) == 0 {
+		return "", errors.New("mongo options: a host is required")
+	}
+	for _, host := range hosts {
+		if err := validateHost(host); err != nil {
+			return "", err
+		}
 	}
 
-	clientOpts := moptions.Client().
-		ApplyURI(uri).
-		SetRetryWrites(options.RetryWrites).
-		SetAuth(moptions.Credential{
-			AuthMechanism: options.AuthMechanism,
-			AuthSource:    options.AuthSource,
-			Username:      options.Username,
-			Password:      options.Password,
-		})
+	// Check if host contains mongodb.net (Atlas) - use mongodb+srv://
+	protocol := "mongodb://"
+	if isAtlasHost(hosts[0]) {
+		if len(hosts) > 1 {
+			return "", fmt.Errorf("mongo options: an Atlas host %q resolves its seed list by SRV and cannot be combined with other hosts", hosts[0])
+		}
+		protocol = "mongodb+srv://"
+	}
+	return protocol + strings.Join(hosts, ","), nil
+}
+
+// escapeSocketPath percent-encodes every byte of path but the unreserved
+// characters, so slashes, spaces, '+', '@' and ',' cannot be read as URI
+// syntax: /tmp/mongodb-27017.sock becomes %2Ftmp%2Fmongodb-27017.sock
+func escapeSocketPath(path string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+// isAtlasHost reports whether host is an Atlas cluster, found by SRV lookup
+func isAtlasHost(host string) bool {
+	return strings.HasSuffix(host, "mongodb.net")
+}
 
-	// Add ServerAPI for Atlas connections
-	if protocol == "mongodb+srv://" {
-		serverAPI := moptions.ServerAPI(moptions.ServerAPIVersion1)
-		clientOpts.SetServerAPIOptions(serverAPI)
+// seedList returns Hosts, or the hosts of Host when the options were built
+// without SetHosts
+func (o *MongoOptions) seedList() []string {
+	if len(o.Hosts) > 0 {
+		return o.Hosts
+	}
+	if o.Host == "" {
+		return nil
 	}
+	return strings.Split(o.Host, ",")
+}
 
-	client, err := mongo.Connect(ctx, clientOpts)
-	return &MongoClient{
-		Client:  client,
-		Options: options,
-	}, err
+// validateHost checks one seed list entry, a host with an optional port
+func validateHost(host string) error {
+	if strings.TrimSpace(host) == "" {
+		return errors.New("mongo options: host must not be empty")
+	}
+	if strings.ContainsAny(host, "/?@, ") {
+		return fmt.Errorf("mongo options: host %q must not contain '/', '?', '@', ',' or spaces", host)
+	}
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		if name == "" {
+			return fmt.Errorf("mongo options: host %q has no name", host)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("mongo options: host %q has an invalid port", host)
+		}
+	}
+	return nil
 }
 
+// Ping checks that the server is reachable
 func (m *MongoClient) Ping(ctx context.Context) error {
-	err := m.Client.Ping(ctx, nil)
+	return mapError(m.Client.Ping(ctx, nil))
+}
+
+// Close disconnects the client. Closing an already closed client is not an
+// error; every other operation on it fails with ErrClientClosed.
+func (m *MongoClient) Close(ctx context.Context) error {
+	err := m.Client.Disconnect(ctx)
+	if errors.Is(err, mongo.ErrClientDisconnected) {
+		err = nil
+	}
+	if m.tunnel != nil {
+		err = errors.Join(err, m.tunnel.Close())
+	}
 	return err
 }
 
 // Find executes a find query on the specified database and collection
 func (m *MongoClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
-	coll := m.Client.Database(db).Collection(collection)
-
-	// Convert opts to mongo.FindOptions if provided
-	var findOpts []*moptions.FindOptions
-	for _, opt := range opts {
-		if fo, ok := opt.(*moptions.FindOptions); ok {
-			findOpts = append(findOpts, fo)
-		}
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
 	}
 
-	cursor, err := coll.Find(ctx, filter, findOpts...)
+	cursor, err := coll.Find(ctx, filter, withComment(opts, call.Comment, moptions.Find().SetComment)...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer cursor.Close(ctx)
 
-	var results []any
+	results := []any{}
 	if err = cursor.All(ctx, &results); err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return results, nil
@@ -201,21 +763,319 @@ func (m *MongoClient) Find(ctx context.Context, db string, collection string, fi
 
 // FindOne executes a findOne query on the specified database and collection
 func (m *MongoClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
-	coll := m.Client.Database(db).Collection(collection)
-
-	// Convert opts to mongo.FindOneOptions if provided
-	var findOneOpts []*moptions.FindOneOptions
-	for _, opt := range opts {
-		if fo, ok := opt.(*moptions.FindOneOptions); ok {
-			findOneOpts = append(findOneOpts, fo)
-		}
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
 	}
 
 	var result any
-	err := coll.FindOne(ctx, filter, findOneOpts...).Decode(&result)
+	err = coll.FindOne(ctx, filter, withComment(opts, call.Comment, moptions.FindOne().SetComment)...).Decode(&result)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return result, nil
+}
+
+// FindCursor executes a find query and returns a cursor over the results, so
+// large result sets can be processed one document at a time
+func (m *MongoClient) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Find(ctx, filter, withComment(opts, call.Comment, moptions.Find().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return cursor, nil
+}
+
+// Watch implements Watcher, opening a change stream on the collection
+func (m *MongoClient) Watch(ctx context.Context, db string, collection string, pipeline any, opts ...any) (ChangeStream, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := coll.Watch(ctx, pipeline, withComment(opts, call.Comment, moptions.ChangeStream().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return stream, nil
+}
+
+// WithTransaction implements Transactor with a session transaction, which
+// the driver retries on transient errors and unknown commit results
+func (m *MongoClient) WithTransaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	session, err := m.Client.StartSession()
+	if err != nil {
+		return mapError(err)
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return mapError(err)
+}
+
+// Aggregate runs an aggregation pipeline and returns every resulting document
+func (m *MongoClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline, withComment(opts, call.Comment, moptions.Aggregate().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer cursor.Close(ctx)
+
+	results := []any{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, mapError(err)
+	}
+
+	return results, nil
+}
+
+// Count returns the number of documents matching the filter
+func (m *MongoClient) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := coll.CountDocuments(ctx, filter, withComment(opts, call.Comment, moptions.Count().SetComment)...)
+	return n, mapError(err)
+}
+
+// Distinct returns the distinct values of field across the documents
+// matching the filter
+func (m *MongoClient) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := coll.Distinct(ctx, field, filter, withComment(opts, call.Comment, moptions.Distinct().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return values, nil
+}
+
+// InsertOne inserts a single document and returns its _id
+func (m *MongoClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := coll.InsertOne(ctx, document, withComment(opts, call.Comment, moptions.InsertOne().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return res.InsertedID, nil
+}
+
+// InsertMany inserts multiple documents and returns their _ids in insertion order
+func (m *MongoClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := coll.InsertMany(ctx, documents, withComment(opts, call.Comment, moptions.InsertMany().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return res.InsertedIDs, nil
+}
+
+// UpdateOne updates the first document matching the filter
+func (m *MongoClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err = updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := coll.UpdateOne(ctx, filter, update, withComment(opts, call.Comment, moptions.Update().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return newUpdateResult(res), nil
+}
+
+// UpdateMany updates every document matching the filter
+func (m *MongoClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err = updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := coll.UpdateMany(ctx, filter, update, withComment(opts, call.Comment, moptions.Update().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return newUpdateResult(res), nil
+}
+
+// ReplaceOne replaces the first document matching the filter
+func (m *MongoClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := coll.ReplaceOne(ctx, filter, replacement, withComment(opts, call.Comment, moptions.Replace().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return newUpdateResult(res), nil
+}
+
+// DeleteOne deletes the first document matching the filter and returns the deleted count
+func (m *MongoClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := coll.DeleteOne(ctx, filter, withComment(opts, call.Comment, moptions.Delete().SetComment)...)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	return res.DeletedCount, nil
+}
+
+// DeleteMany deletes every document matching the filter and returns the deleted count
+func (m *MongoClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := coll.DeleteMany(ctx, filter, withComment(opts, call.Comment, moptions.Delete().SetComment)...)
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	return res.DeletedCount, nil
+}
+
+// FindOneAndUpdate atomically updates the first document matching the filter and returns it
+func (m *MongoClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	coll, call, err := m.collection(ctx, db, collection)
 	if err != nil {
 		return nil, err
 	}
 
+	update, err = updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	var result any
+	err = coll.FindOneAndUpdate(ctx, filter, update, withComment(opts, call.Comment, moptions.FindOneAndUpdate().SetComment)...).Decode(&result)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
 	return result, nil
 }
+
+// BulkWrite executes a batch of mongo.WriteModel operations
+func (m *MongoClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	coll, call, err := m.collection(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	writeModels := make([]mongo.WriteModel, 0, len(models))
+	for i, model := range models {
+		wm, ok := model.(mongo.WriteModel)
+		if !ok {
+			return nil, fmt.Errorf("invalid write model at index %d: %T", i, model)
+		}
+		writeModels = append(writeModels, wm)
+	}
+
+	res, err := coll.BulkWrite(ctx, writeModels, withComment(opts, call.Comment, moptions.BulkWrite().SetComment)...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &BulkWriteResult{
+		InsertedCount: res.InsertedCount,
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		DeletedCount:  res.DeletedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedIDs:   res.UpsertedIDs,
+	}, nil
+}
+
+// collection returns db.name with the per-call read preference and write
+// concern of ctx applied, and the overrides, failing with
+// ErrInvalidCallOption when ctx carries one that cannot be applied
+func (m *MongoClient) collection(ctx context.Context, db string, name string) (*mongo.Collection, CallOptions, error) {
+	call, err := callOptions(ctx)
+	if err != nil {
+		return nil, call, err
+	}
+	return m.Client.Database(db).Collection(name, call.collectionOptions()), call, nil
+}
+
+// withComment picks the driver options of type *T out of opts like
+// optionsOf, followed by an option setting comment, when there is one, with
+// setComment, the SetComment method of a new *T
+func withComment[T any, C any](opts []any, comment string, setComment func(C) *T) []*T {
+	out := optionsOf[T](opts)
+	if comment != "" {
+		out = append(out, setComment(any(comment).(C)))
+	}
+	return out
+}
+
+// optionsOf picks the driver options of type *T out of the variadic opts
+func optionsOf[T any](opts []any) []*T {
+	var out []*T
+	for _, opt := range opts {
+		if o, ok := opt.(*T); ok {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func newUpdateResult(res *mongo.UpdateResult) *UpdateResult {
+	return &UpdateResult{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedID:    res.UpsertedID,
+	}
+}
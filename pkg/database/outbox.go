@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OutboxCollection is the collection WithOutbox stages events in
+const OutboxCollection = "_outbox"
+
+// Statuses of an OutboxEvent
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
+
+// Defaults of RelayConfig
+const (
+	defaultRelayPollInterval    = time.Second
+	defaultRelayLease           = 30 * time.Second
+	defaultRelayBackoff         = time.Second
+	defaultRelayMaxBackoff      = 5 * time.Minute
+	defaultRelayRetention       = 24 * time.Hour
+	defaultRelayCleanupInterval = 10 * time.Minute
+)
+
+// OutboxEvent is an event staged by WithOutbox, as stored in the outbox
+// collection
+type OutboxEvent struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Topic string             `bson:"topic"`
+	// Payload is the payload passed to Add, see DecodePayload
+	Payload bson.RawValue `bson:"payload"`
+	// IdempotencyKey identifies the event across deliveries; handlers use it
+	// to ignore an event they already processed
+	IdempotencyKey string `bson:"idempotency_key"`
+	// Status is OutboxPending, OutboxDelivered or OutboxFailed
+	Status string `bson:"status"`
+	// Attempts counts the deliveries started, the current one included
+	Attempts int `bson:"attempts"`
+	// CreatedAt is when the event was staged
+	CreatedAt time.Time `bson:"created_at"`
+	// AvailableAt is when a pending event may be claimed next: its creation,
+	// the end of the lease of the relay delivering it or its next retry
+	AvailableAt time.Time `bson:"available_at"`
+	// Claim identifies the delivery that holds the lease
+	Claim primitive.ObjectID `bson:"claim,omitempty"`
+	// DeliveredAt is when the handler succeeded
+	DeliveredAt time.Time `bson:"delivered_at,omitempty"`
+	// LastError is the error of the last failed delivery
+	LastError string `bson:"last_error,omitempty"`
+}
+
+// DecodePayload decodes the payload of the event into val
+func (e OutboxEvent) DecodePayload(val any) error {
+	if e.Payload.Type == 0 {
+		return errors.New("outbox: event has no payload")
+	}
+	return e.Payload.Unmarshal(val)
+}
+
+// OutboxOption configures WithOutbox
+type OutboxOption func(*outboxConfig)
+
+type outboxConfig struct {
+	clock Clock
+}
+
+// OutboxClock sets the clock events are stamped with, the system clock by
+// default; the relay reading them should use the same clock
+func OutboxClock(clock Clock) OutboxOption {
+	return func(c *outboxConfig) {
+		c.clock = clock
+	}
+}
+
+// Outbox stages the events of a WithOutbox transaction
+type Outbox struct {
+	now    time.Time
+	events []any
+}
+
+// Add stages an event on topic with a generated idempotency key, which it
+// returns. payload must marshal to BSON.
+func (o *Outbox) Add(topic string, payload any) string {
+	key := primitive.NewObjectID().Hex()
+	o.AddWithKey(topic, key, payload)
+	return key
+}
+
+// AddWithKey stages an event on topic with the idempotency key key, for
+// events that derive their key from the business data, such as
+// "order-42-paid"
+func (o *Outbox) AddWithKey(topic string, key string, payload any) {
+	o.events = append(o.events, bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "topic", Value: topic},
+		{Key: "payload", Value: payload},
+		{Key: "idempotency_key", Value: key},
+		{Key: "status", Value: OutboxPending},
+		{Key: "attempts", Value: 0},
+		{Key: "created_at", Value: o.now},
+		{Key: "available_at", Value: o.now},
+	})
+}
+
+// WithOutbox runs fn in a transaction, see WithTransaction, and inserts the
+// events fn adds to outbox into the OutboxCollection of db in the same
+// transaction, so the events exist if and only if the writes fn makes with
+// txCtx were committed. RunOutboxRelay delivers them.
+//
+//	err := db.WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *database.Outbox) error {
+//		if _, err := db.Client.UpdateOne(txCtx, "shop", "orders", database.ByID(id), paid); err != nil {
+//			return err
+//		}
+//		outbox.AddWithKey("order.paid", "order-"+id.Hex()+"-paid", event)
+//		return nil
+//	})
+func (d *Database) WithOutbox(ctx context.Context, db string, fn func(txCtx context.Context, outbox *Outbox) error, opts ...OutboxOption) error {
+	cfg := outboxConfig{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return WithTransaction(ctx, d.Client, func(txCtx context.Context) error {
+		// A retried transaction starts with an empty outbox
+		outbox := &Outbox{now: cfg.clock.Now()}
+		if err := fn(txCtx, outbox); err != nil {
+			return err
+		}
+		if len(outbox.events) == 0 {
+			return nil
+		}
+		if _, err := d.Client.InsertMany(txCtx, db, OutboxCollection, outbox.events); err != nil {
+			return fmt.Errorf("outbox: %w", err)
+		}
+		return nil
+	})
+}
+
+// EnsureOutboxIndexes creates the indexes RunOutboxRelay queries the
+// OutboxCollection of db with
+func EnsureOutboxIndexes(ctx context.Context, client DatabaseInterface, db string) error {
+	return EnsureIndexes(ctx, client, db, OutboxCollection,
+		IndexSpec{Keys: bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}}},
+		IndexSpec{Keys: bson.D{{Key: "status", Value: 1}, {Key: "delivered_at", Value: 1}}},
+	)
+}
+
+// RelayConfig configures RunOutboxRelay
+type RelayConfig struct {
+	// PollInterval is the wait when no event is due, one second by default
+	PollInterval time.Duration
+	// Lease is how long a claimed event stays with its relay before another
+	// may claim it again, 30s by default. The handler's context ends with it.
+	Lease time.Duration
+	// Backoff is the delay before retrying a failed event, doubled after
+	// every failed attempt up to MaxBackoff; one second and five minutes by
+	// default
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts marks an event failed after this many failed deliveries;
+	// zero retries forever
+	MaxAttempts int
+	// Retention is how long delivered events are kept, a day by default; a
+	// negative Retention keeps them
+	Retention time.Duration
+	// CleanupInterval is the time between two removals of delivered events,
+	// ten minutes by default
+	CleanupInterval time.Duration
+	// Logger receives the errors the relay recovers from, slog.Default() by
+	// default
+	Logger *slog.Logger
+	// Clock supplies the time and, when it is a TimerClock, schedules the
+	// polls; the system clock by default
+	Clock Clock
+}
+
+func (cfg RelayConfig) withDefaults() RelayConfig {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultRelayPollInterval
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultRelayLease
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultRelayBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultRelayMaxBackoff
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = defaultRelayRetention
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = defaultRelayCleanupInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	return cfg
+}
+
+// RunOutboxRelay delivers the events of the OutboxCollection of db to
+// handler, oldest due first, until ctx ends, and returns ctx's error. Each
+// event is claimed with an atomic update that leases it to this relay, so
+// several relays can run side by side. An event whose handler returns nil is
+// marked delivered and removed after the retention; one whose handler fails
+// is retried with growing delays, and one whose lease ran out, because its
+// relay stopped, is claimed again.
+//
+// Delivery is at least once: an event can reach handler again after a
+// failure or an expired lease, so handlers should ignore the idempotency
+// keys they already processed.
+//
+//	go db.RunOutboxRelay(ctx, "shop", func(ctx context.Context, event database.OutboxEvent) error {
+//		return broker.Publish(ctx, event.Topic, event.IdempotencyKey, event.Payload.Value)
+//	}, database.RelayConfig{MaxAttempts: 20})
+func (d *Database) RunOutboxRelay(ctx context.Context, db string, handler func(ctx context.Context, event OutboxEvent) error, cfg RelayConfig) error {
+	cfg = cfg.withDefaults()
+	var cleaned time.Time
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if now := cfg.Clock.Now(); cfg.Retention > 0 && now.Sub(cleaned) >= cfg.CleanupInterval {
+			if _, err := d.Client.DeleteMany(ctx, db, OutboxCollection, bson.M{
+				"status":       OutboxDelivered,
+				"delivered_at": bson.M{"$lt": now.Add(-cfg.Retention)},
+			}); err != nil && ctx.Err() == nil {
+				cfg.Logger.Warn("outbox cleanup failed", "db", db, "error", err)
+			}
+			cleaned = now
+		}
+
+		event, err := d.claimOutboxEvent(ctx, db, cfg)
+		if err == nil {
+			d.deliverOutboxEvent(ctx, db, event, handler, cfg)
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) && ctx.Err() == nil {
+			cfg.Logger.Warn("outbox claim failed", "db", db, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clockAfter(cfg.Clock, cfg.PollInterval):
+		}
+	}
+}
+
+// claimOutboxEvent leases the oldest due pending event to the caller,
+// failing with mongo.ErrNoDocuments when none is due
+func (d *Database) claimOutboxEvent(ctx context.Context, db string, cfg RelayConfig) (*OutboxEvent, error) {
+	now := cfg.Clock.Now()
+	doc, err := d.Client.FindOneAndUpdate(ctx, db, OutboxCollection,
+		bson.M{"status": OutboxPending, "available_at": bson.M{"$lte": now}},
+		bson.M{
+			"$set": bson.M{"available_at": now.Add(cfg.Lease), "claim": primitive.NewObjectID()},
+			"$inc": bson.M{"attempts": 1},
+		},
+		moptions.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "available_at", Value: 1}}).
+			SetReturnDocument(moptions.After))
+	if err != nil {
+		return nil, err
+	}
+	var event OutboxEvent
+	if err := decodeDocument(doc, &event); err != nil {
+		return nil, fmt.Errorf("outbox: decode event: %w", err)
+	}
+	return &event, nil
+}
+
+// deliverOutboxEvent runs handler on a claimed event and records the
+// outcome, unless the lease was lost meanwhile
+func (d *Database) deliverOutboxEvent(ctx context.Context, db string, event *OutboxEvent, handler func(context.Context, OutboxEvent) error, cfg RelayConfig) {
+	handlerCtx, cancel := context.WithTimeout(ctx, cfg.Lease)
+	err := handler(handlerCtx, *event)
+	cancel()
+
+	now := cfg.Clock.Now()
+	var update bson.M
+	switch {
+	case err == nil:
+		update = bson.M{"$set": bson.M{"status": OutboxDelivered, "delivered_at": now}, "$unset": bson.M{"claim": ""}}
+	case cfg.MaxAttempts > 0 && event.Attempts >= cfg.MaxAttempts:
+		update = bson.M{"$set": bson.M{"status": OutboxFailed, "last_error": err.Error()}, "$unset": bson.M{"claim": ""}}
+	default:
+		backoff := cfg.Backoff
+		for i := 1; i < event.Attempts && backoff < cfg.MaxBackoff; i++ {
+			backoff *= 2
+		}
+		update = bson.M{
+			"$set":   bson.M{"available_at": now.Add(min(backoff, cfg.MaxBackoff)), "last_error": err.Error()},
+			"$unset": bson.M{"claim": ""},
+		}
+	}
+	// The outcome is recorded even when ctx ended during the handler
+	result, uerr := d.Client.UpdateOne(context.WithoutCancel(ctx), db, OutboxCollection,
+		bson.M{"_id": event.ID, "claim": event.Claim}, update)
+	switch {
+	case uerr != nil:
+		cfg.Logger.Warn("outbox event update failed", "db", db, "id", event.ID.Hex(), "error", uerr)
+	case result.MatchedCount == 0:
+		cfg.Logger.Warn("outbox event lease lost", "db", db, "id", event.ID.Hex())
+	case err != nil:
+		cfg.Logger.Warn("outbox delivery failed", "db", db, "id", event.ID.Hex(), "topic", event.Topic, "attempts", event.Attempts, "error", err)
+	}
+}
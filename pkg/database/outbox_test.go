@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// outboxEvents returns the events stored in the outbox of db
+func outboxEvents(t *testing.T, fake *FakeDatabase, db string) []OutboxEvent {
+	t.Helper()
+	var events []OutboxEvent
+	for _, doc := range fake.Documents(db, OutboxCollection) {
+		var event OutboxEvent
+		if err := decodeDocument(doc, &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// eventually polls cond until it holds, failing the test after a few seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithOutbox(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Commit", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		d := &Database{Client: fake}
+		var key string
+		err := d.WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *Outbox) error {
+			if _, err := fake.InsertOne(txCtx, "shop", "orders", bson.M{"_id": 42}); err != nil {
+				return err
+			}
+			key = outbox.Add("order.created", bson.M{"order": 42})
+			outbox.AddWithKey("order.paid", "order-42-paid", bson.M{"order": 42})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := outboxEvents(t, fake, "shop")
+		if len(events) != 2 || len(fake.Documents("shop", "orders")) != 1 {
+			t.Fatalf("expected the order and two events, got %+v", events)
+		}
+		if events[0].Topic != "order.created" || events[0].IdempotencyKey != key || key == "" || events[1].IdempotencyKey != "order-42-paid" {
+			t.Errorf("expected the topics and idempotency keys, got %+v", events)
+		}
+		var payload struct{ Order int }
+		if err := events[0].DecodePayload(&payload); err != nil || payload.Order != 42 {
+			t.Errorf("expected the payload, got %+v, %v", payload, err)
+		}
+		if events[0].Status != OutboxPending || events[0].Attempts != 0 || events[0].AvailableAt.IsZero() {
+			t.Errorf("expected a pending event, got %+v", events[0])
+		}
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		clock := NewTestClock(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+		err := (&Database{Client: fake}).WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *Outbox) error {
+			outbox.Add("order.created", bson.M{"order": 42})
+			return nil
+		}, OutboxClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := outboxEvents(t, fake, "shop")[0]; !got.CreatedAt.Equal(clock.Now()) || !got.AvailableAt.Equal(clock.Now()) {
+			t.Errorf("expected the event to be stamped with the clock, got %+v", got)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		failure := errors.New("card declined")
+		err := (&Database{Client: fake}).WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *Outbox) error {
+			fake.InsertOne(txCtx, "shop", "orders", bson.M{"_id": 42})
+			outbox.Add("order.created", bson.M{"order": 42})
+			return failure
+		})
+		if !errors.Is(err, failure) || len(fake.Documents("shop", "orders")) != 0 || len(fake.Documents("shop", OutboxCollection)) != 0 {
+			t.Errorf("expected neither the order nor the event, got %v", err)
+		}
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		err := (&Database{Client: mock}).WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *Outbox) error {
+			outbox.Add("order.created", bson.M{"order": 42})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := mock.InsertManyCalls
+		if len(calls) != 1 || calls[0].Collection != OutboxCollection || len(calls[0].Documents) != 1 || !InTransaction(calls[0].Ctx) {
+			t.Errorf("expected the event to be inserted in the transaction, got %+v", calls)
+		}
+		if len(mock.WithTransactionCalls) != 1 || !mock.WithTransactionCalls[0].Committed {
+			t.Errorf("expected a committed transaction, got %+v", mock.WithTransactionCalls)
+		}
+	})
+}
+
+func TestRunOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// setup stages one event and starts a relay on the fake whose handler
+	// returns the errors of results in turn, then nil
+	type relay struct {
+		fake      *FakeDatabase
+		d         *Database
+		clock     *TestClock
+		delivered chan OutboxEvent
+	}
+	setup := func(t *testing.T, cfg RelayConfig, results ...error) *relay {
+		r := &relay{fake: NewFakeDatabase(), clock: NewTestClock(time.Now()), delivered: make(chan OutboxEvent, 10)}
+		r.d = &Database{Client: r.fake}
+		err := r.d.WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *Outbox) error {
+			outbox.AddWithKey("order.paid", "order-42-paid", bson.M{"order": 42})
+			return nil
+		}, OutboxClock(r.clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Clock, cfg.Logger, cfg.PollInterval = r.clock, quiet, time.Millisecond
+		relayCtx, cancel := context.WithCancel(ctx)
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- r.d.RunOutboxRelay(relayCtx, "shop", func(ctx context.Context, event OutboxEvent) error {
+				r.delivered <- event
+				if len(results) > 0 {
+					err := results[0]
+					results = results[1:]
+					return err
+				}
+				return nil
+			}, cfg)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			if err := <-stopped; !errors.Is(err, context.Canceled) {
+				t.Errorf("expected the relay to stop with the context, got %v", err)
+			}
+		})
+		return r
+	}
+	next := func(t *testing.T, r *relay) OutboxEvent {
+		t.Helper()
+		select {
+		case event := <-r.delivered:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a delivery")
+			return OutboxEvent{}
+		}
+	}
+	// advance moves the clock once the relay waits for its next poll, so the
+	// poll is due after the move
+	advance := func(t *testing.T, r *relay, d time.Duration) {
+		t.Helper()
+		eventually(t, "the relay to wait for its next poll", func() bool { return r.clock.Timers() > 0 })
+		r.clock.Advance(d)
+	}
+	status := func(r *relay, want string) func() bool {
+		return func() bool {
+			docs := r.fake.Documents("shop", OutboxCollection)
+			return len(docs) == 1 && docs[0]["status"] == want
+		}
+	}
+
+	t.Run("Deliver", func(t *testing.T) {
+		r := setup(t, RelayConfig{})
+		event := next(t, r)
+		if event.Topic != "order.paid" || event.IdempotencyKey != "order-42-paid" || event.Attempts != 1 || event.Claim.IsZero() {
+			t.Errorf("expected the claimed event, got %+v", event)
+		}
+		eventually(t, "the event to be delivered", status(r, OutboxDelivered))
+		if got := outboxEvents(t, r.fake, "shop")[0]; !got.DeliveredAt.Equal(r.clock.Now().Truncate(time.Millisecond)) || !got.Claim.IsZero() {
+			t.Errorf("expected the delivery time and no claim, got %+v", got)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		r := setup(t, RelayConfig{Backoff: time.Minute}, errors.New("broker down"), errors.New("broker down"))
+		next(t, r)
+		eventually(t, "the event to be rescheduled", func() bool {
+			events := outboxEvents(t, r.fake, "shop")
+			return events[0].LastError == "broker down" && events[0].Claim.IsZero()
+		})
+		if got := outboxEvents(t, r.fake, "shop")[0]; got.Status != OutboxPending || !got.AvailableAt.Equal(r.clock.Now().Add(time.Minute).Truncate(time.Millisecond)) {
+			t.Errorf("expected a retry after the backoff, got %+v", got)
+		}
+		select {
+		case <-r.delivered:
+			t.Fatal("expected no delivery before the backoff")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		// The second failure doubles the backoff
+		advance(t, r, time.Minute)
+		if event := next(t, r); event.Attempts != 2 {
+			t.Errorf("expected the second attempt, got %d", event.Attempts)
+		}
+		eventually(t, "the event to be rescheduled", func() bool {
+			return outboxEvents(t, r.fake, "shop")[0].AvailableAt.Equal(r.clock.Now().Add(2 * time.Minute).Truncate(time.Millisecond))
+		})
+		advance(t, r, 2*time.Minute)
+		next(t, r)
+		eventually(t, "the event to be delivered", status(r, OutboxDelivered))
+	})
+
+	t.Run("PollInterval", func(t *testing.T) {
+		r := setup(t, RelayConfig{Backoff: time.Minute}, errors.New("broker down"))
+		next(t, r)
+		eventually(t, "the event to be rescheduled", func() bool {
+			return outboxEvents(t, r.fake, "shop")[0].Claim.IsZero()
+		})
+		// The poll is due on the clock before the retry is
+		advance(t, r, time.Millisecond)
+		select {
+		case <-r.delivered:
+			t.Fatal("expected no delivery before the backoff")
+		case <-time.After(20 * time.Millisecond):
+		}
+		advance(t, r, time.Minute)
+		next(t, r)
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		r := setup(t, RelayConfig{MaxAttempts: 1}, errors.New("malformed"))
+		next(t, r)
+		eventually(t, "the event to fail", status(r, OutboxFailed))
+		if got := outboxEvents(t, r.fake, "shop")[0]; got.LastError != "malformed" {
+			t.Errorf("expected the error to be kept, got %+v", got)
+		}
+	})
+
+	t.Run("LeaseExpired", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		d := &Database{Client: fake}
+		clock := NewTestClock(time.Now())
+		cfg := RelayConfig{Clock: clock, Logger: quiet, Lease: time.Minute}.withDefaults()
+		d.WithOutbox(ctx, "shop", func(txCtx context.Context, outbox *Outbox) error {
+			outbox.Add("order.paid", bson.M{"order": 42})
+			return nil
+		}, OutboxClock(clock))
+		// A relay that claims the event and stops before delivering it
+		crashed, err := d.claimOutboxEvent(ctx, "shop", cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.claimOutboxEvent(ctx, "shop", cfg); err == nil {
+			t.Fatal("expected a leased event not to be claimed again")
+		}
+		clock.Advance(time.Minute)
+		reclaimed, err := d.claimOutboxEvent(ctx, "shop", cfg)
+		if err != nil || reclaimed.Attempts != 2 || reclaimed.Claim == crashed.Claim {
+			t.Fatalf("expected the event to be claimed again once the lease ran out, got %+v, %v", reclaimed, err)
+		}
+		// The first relay cannot record an outcome any more
+		d.deliverOutboxEvent(ctx, "shop", crashed, func(context.Context, OutboxEvent) error { return nil }, cfg)
+		if got := outboxEvents(t, fake, "shop")[0]; got.Status != OutboxPending || got.Claim != reclaimed.Claim {
+			t.Errorf("expected the stale delivery to be ignored, got %+v", got)
+		}
+		d.deliverOutboxEvent(ctx, "shop", reclaimed, func(context.Context, OutboxEvent) error { return nil }, cfg)
+		if got := outboxEvents(t, fake, "shop")[0]; got.Status != OutboxDelivered {
+			t.Errorf("expected the event to be delivered, got %+v", got)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		now := time.Now()
+		fake.Seed("shop", OutboxCollection,
+			bson.M{"_id": primitive.NewObjectID(), "status": OutboxDelivered, "delivered_at": now.Add(-48 * time.Hour)},
+			bson.M{"_id": primitive.NewObjectID(), "status": OutboxDelivered, "delivered_at": now.Add(-time.Hour)},
+			bson.M{"_id": primitive.NewObjectID(), "status": OutboxFailed, "delivered_at": now.Add(-48 * time.Hour)},
+		)
+		relayCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go (&Database{Client: fake}).RunOutboxRelay(relayCtx, "shop", func(context.Context, OutboxEvent) error { return nil },
+			RelayConfig{Clock: NewTestClock(now), Logger: quiet, PollInterval: time.Millisecond})
+		eventually(t, "the old delivered event to be removed", func() bool {
+			return len(fake.Documents("shop", OutboxCollection)) == 2
+		})
+		for _, doc := range fake.Documents("shop", OutboxCollection) {
+			if doc["status"] == OutboxDelivered && doc["delivered_at"].(primitive.DateTime).Time().Before(now.Add(-24*time.Hour)) {
+				t.Errorf("expected the event delivered two days ago to be removed, got %v", doc)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidPageToken is returned by FindAfter when a continuation token is
+// malformed or was issued for a different sort
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// Page is one page of a keyset-paginated query
+type Page struct {
+	Documents []any
+	// NextToken continues after the last document; it is empty on the last page
+	NextToken string
+}
+
+// FindAfter returns up to limit documents matching filter, ordered by sortField
+// and then _id, starting after the position encoded in token. Pass an empty
+// token for the first page and prefix sortField with "-" to sort descending.
+// Positions are keys rather than offsets, so documents inserted between pages
+// are neither repeated nor skipped. Every matching document must have the
+// sort field. It works against any DatabaseInterface, so the fake and the
+// real client issue and accept the same tokens.
+func FindAfter(ctx context.Context, client DatabaseInterface, db string, collection string, filter any, sortField string, limit int64, token string) (*Page, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("page limit must be positive, got %d", limit)
+	}
+	field, direction := strings.TrimPrefix(sortField, "-"), 1
+	if strings.HasPrefix(sortField, "-") {
+		direction = -1
+	}
+	if field == "" {
+		return nil, errors.New("page sort field is required")
+	}
+
+	query := filter
+	if token != "" {
+		value, id, err := decodePageToken(token, sortField)
+		if err != nil {
+			return nil, err
+		}
+		after := keysetFilter(field, direction, value, id)
+		if filter != nil {
+			after = bson.M{"$and": bson.A{filter, after}}
+		}
+		query = after
+	}
+
+	sort := bson.D{{Key: field, Value: direction}}
+	if field != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: direction})
+	}
+	result, err := client.Find(ctx, db, collection, query, moptions.Find().SetSort(sort).SetLimit(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	docs, _ := result.([]any)
+	if int64(len(docs)) <= limit {
+		return &Page{Documents: docs}, nil
+	}
+
+	docs = docs[:limit]
+	next, err := PageToken(sortField, docs[len(docs)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Documents: docs, NextToken: next}, nil
+}
+
+// PageToken returns the continuation token FindAfter issues for doc, so tests
+// can build tokens for documents they queued on a mock
+func PageToken(sortField string, doc any) (string, error) {
+	fields, ok := normalizeDocument(doc).(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("page token needs a document, got %T", doc)
+	}
+	id, ok := fields["_id"]
+	if !ok {
+		return "", errors.New("page token needs a document with an _id")
+	}
+	value, ok := lookupPath(fields, strings.TrimPrefix(sortField, "-"))
+	if !ok {
+		return "", fmt.Errorf("page token needs a document with the sort field %q", sortField)
+	}
+	data, err := bson.MarshalExtJSON(bson.D{{Key: "s", Value: sortField}, {Key: "v", Value: value}, {Key: "id", Value: id}}, true, false)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(token string, sortField string) (value any, id any, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidPageToken, err)
+	}
+	var position struct {
+		Sort  string `bson:"s"`
+		Value any    `bson:"v"`
+		ID    any    `bson:"id"`
+	}
+	if err := bson.UnmarshalExtJSON(data, true, &position); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidPageToken, err)
+	}
+	if position.ID == nil {
+		return nil, nil, fmt.Errorf("%w: no _id", ErrInvalidPageToken)
+	}
+	if position.Sort != sortField {
+		return nil, nil, fmt.Errorf("%w: issued for sort %q, not %q", ErrInvalidPageToken, position.Sort, sortField)
+	}
+	return position.Value, position.ID, nil
+}
+
+// keysetFilter matches the documents that sort after (value, id)
+func keysetFilter(field string, direction int, value any, id any) bson.M {
+	op := "$gt"
+	if direction < 0 {
+		op = "$lt"
+	}
+	if field == "_id" {
+		return bson.M{"_id": bson.M{op: id}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, "_id": bson.M{op: id}},
+	}}
+}
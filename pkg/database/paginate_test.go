@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// collectPages follows FindAfter until the token runs out and returns the _id
+// of every document in the order received
+func collectPages(t *testing.T, client DatabaseInterface, filter any, sortField string, limit int64) []any {
+	t.Helper()
+	var ids []any
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 10000 {
+			t.Fatal("pagination did not terminate")
+		}
+		page, err := FindAfter(context.Background(), client, "testdb", "items", filter, sortField, limit, token)
+		if err != nil {
+			t.Fatalf("page %d failed: %v", pages, err)
+		}
+		if int64(len(page.Documents)) > limit {
+			t.Fatalf("page %d has %d documents, limit is %d", pages, len(page.Documents), limit)
+		}
+		for _, doc := range page.Documents {
+			ids = append(ids, doc.(bson.M)["_id"])
+		}
+		if page.NextToken == "" {
+			return ids
+		}
+		token = page.NextToken
+	}
+}
+
+func TestFindAfterVisitsEveryDocumentOnce(t *testing.T) {
+	fake := NewFakeDatabase()
+	docs := make([]any, 1000)
+	for i := range docs {
+		// Few distinct ranks, so most page boundaries fall inside a tie
+		docs[i] = bson.M{"_id": i, "rank": (i * 7919) % 13, "even": i%2 == 0}
+	}
+	if err := fake.Seed("testdb", "items", docs...); err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+
+	tests := []struct {
+		sortField string
+		filter    any
+		want      int
+	}{
+		{"rank", nil, 1000},
+		{"-rank", nil, 1000},
+		{"_id", nil, 1000},
+		{"-_id", nil, 1000},
+		{"rank", bson.M{"even": true}, 500},
+	}
+
+	for _, tt := range tests {
+		for _, limit := range []int64{7, 13, 100, 999, 1000, 1001} {
+			t.Run(fmt.Sprintf("%s/%v/%d", tt.sortField, tt.filter, limit), func(t *testing.T) {
+				ids := collectPages(t, fake, tt.filter, tt.sortField, limit)
+				if len(ids) != tt.want {
+					t.Fatalf("expected %d documents, got %d", tt.want, len(ids))
+				}
+				seen := make(map[any]bool, len(ids))
+				for _, id := range ids {
+					if seen[id] {
+						t.Fatalf("document %v returned twice", id)
+					}
+					seen[id] = true
+				}
+			})
+		}
+	}
+}
+
+func TestFindAfter(t *testing.T) {
+	ctx := context.Background()
+	seed := func(t *testing.T, n int) *FakeDatabase {
+		t.Helper()
+		fake := NewFakeDatabase()
+		for i := 1; i <= n; i++ {
+			if err := fake.Seed("testdb", "items", bson.M{"_id": i, "rank": i * 10}); err != nil {
+				t.Fatalf("failed to seed: %v", err)
+			}
+		}
+		return fake
+	}
+
+	t.Run("ExactMultipleEndsWithoutEmptyPage", func(t *testing.T) {
+		fake := seed(t, 4)
+		first, _ := FindAfter(ctx, fake, "testdb", "items", nil, "rank", 2, "")
+		second, err := FindAfter(ctx, fake, "testdb", "items", nil, "rank", 2, first.NextToken)
+		if err != nil || len(second.Documents) != 2 || second.NextToken != "" {
+			t.Errorf("expected a full last page with no token, got %v, %v", second, err)
+		}
+	})
+
+	t.Run("EmptyCollection", func(t *testing.T) {
+		page, err := FindAfter(ctx, NewFakeDatabase(), "testdb", "items", nil, "rank", 10, "")
+		if err != nil || len(page.Documents) != 0 || page.NextToken != "" {
+			t.Errorf("expected an empty last page, got %v, %v", page, err)
+		}
+	})
+
+	t.Run("InsertsBetweenPages", func(t *testing.T) {
+		fake := seed(t, 4)
+		first, _ := FindAfter(ctx, fake, "testdb", "items", nil, "rank", 2, "")
+		fake.InsertMany(ctx, "testdb", "items", []any{
+			bson.M{"_id": 10, "rank": 5},  // before the cursor, not returned
+			bson.M{"_id": 11, "rank": 20}, // ties with the last document but sorts after it
+			bson.M{"_id": 12, "rank": 35},
+		})
+		rest := collectPagesFrom(t, fake, first.NextToken)
+		want := []any{int32(11), int32(3), int32(12), int32(4)}
+		if !valuesEqual(rest, want) {
+			t.Errorf("expected %v, got %v", want, rest)
+		}
+	})
+
+	t.Run("InvalidTokens", func(t *testing.T) {
+		fake := seed(t, 4)
+		first, _ := FindAfter(ctx, fake, "testdb", "items", nil, "rank", 2, "")
+		for name, token := range map[string]string{
+			"NotBase64":    "%%%",
+			"NotJSON":      "bm90IGpzb24",
+			"OtherSort":    first.NextToken,
+			"MissingField": "eyJzIjoicmFuayJ9",
+		} {
+			t.Run(name, func(t *testing.T) {
+				sortField := "rank"
+				if name == "OtherSort" {
+					sortField = "-rank"
+				}
+				if _, err := FindAfter(ctx, fake, "testdb", "items", nil, sortField, 2, token); !errors.Is(err, ErrInvalidPageToken) {
+					t.Errorf("expected ErrInvalidPageToken, got %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("WorksAgainstTheMock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		doc := bson.M{"_id": 2, "rank": 20}
+		mock.QueueFind([]any{bson.M{"_id": 1, "rank": 10}, doc, bson.M{"_id": 3, "rank": 30}}, nil)
+
+		page, err := FindAfter(ctx, mock, "testdb", "items", bson.M{"kind": "a"}, "rank", 2, "")
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		want, _ := PageToken("rank", doc)
+		if page.NextToken != want || len(page.Documents) != 2 {
+			t.Errorf("expected the token of the second document, got %v", page)
+		}
+
+		FindAfter(ctx, mock, "testdb", "items", bson.M{"kind": "a"}, "rank", 2, page.NextToken)
+		call, _ := mock.LastFindCall()
+		filter := normalizeDocument(call.Filter).(map[string]any)
+		if _, ok := filter["$and"]; !ok {
+			t.Errorf("expected the filter to be combined with the keyset, got %v", filter)
+		}
+	})
+}
+
+func collectPagesFrom(t *testing.T, client DatabaseInterface, token string) []any {
+	t.Helper()
+	var ids []any
+	for token != "" {
+		page, err := FindAfter(context.Background(), client, "testdb", "items", nil, "rank", 2, token)
+		if err != nil {
+			t.Fatalf("page failed: %v", err)
+		}
+		for _, doc := range page.Documents {
+			ids = append(ids, doc.(bson.M)["_id"])
+		}
+		token = page.NextToken
+	}
+	return ids
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrEmptyPatch is returned by SetFromStruct when the struct sets no fields
+var ErrEmptyPatch = errors.New("patch sets no fields")
+
+// PatchOption configures SetFromStruct
+type PatchOption = Option[patchConfig]
+
+type patchConfig struct {
+	includeZero []string
+}
+
+// IncludeZero sets the fields at the given dotted BSON paths even when they
+// hold their zero value or a nil pointer, which is set as null
+func IncludeZero(paths ...string) PatchOption {
+	return func(c *patchConfig) {
+		c.includeZero = append(c.includeZero, paths...)
+	}
+}
+
+// SetFromStruct returns a $set update document with the non-zero fields of
+// the struct v, keyed by their bson tags, for PATCH handlers that decode a
+// partial request into a struct. A nil pointer field is left untouched while
+// a pointer to a zero value sets the zero value, so optional fields should be
+// pointers. Nested structs, by value or pointer, are flattened to dotted
+// paths so their unset fields are kept; time.Time and the primitive types
+// are set as values. _id is never set. It returns ErrEmptyPatch when no
+// field is set.
+//
+//	var req struct {
+//		Name   *string `json:"name" bson:"name"`
+//		Online *bool   `json:"online" bson:"online"`
+//	}
+//	...
+//	update, err := database.SetFromStruct(req)
+//	res, err := db.Client.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": id}, update)
+func SetFromStruct(v any, opts ...PatchOption) (any, error) {
+	var cfg patchConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("set from struct: need a struct, got %T", v)
+	}
+	fields := cfg.structFields(rv, "", nil)
+	if len(fields) == 0 {
+		return nil, ErrEmptyPatch
+	}
+	return bson.D{{Key: "$set", Value: fields}}, nil
+}
+
+// structFields appends the fields of rv to set with their paths under prefix
+func (c patchConfig) structFields(rv reflect.Value, prefix string, set bson.D) bson.D {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("bson")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		value := rv.Field(i)
+		if strings.Contains(tag, ",inline") && value.Kind() == reflect.Struct {
+			set = c.structFields(value, prefix, set)
+			continue
+		}
+		path := prefix + bsonKey(f)
+		if path == "_id" {
+			continue
+		}
+		include := slices.Contains(c.includeZero, path)
+
+		switch {
+		case value.Kind() == reflect.Pointer && value.IsNil():
+			if include {
+				set = append(set, bson.E{Key: path, Value: nil})
+			}
+		case flattens(value):
+			for value.Kind() == reflect.Pointer {
+				value = value.Elem()
+			}
+			set = c.structFields(value, path+".", set)
+		case value.Kind() == reflect.Pointer:
+			set = append(set, bson.E{Key: path, Value: value.Elem().Interface()})
+		case include || !value.IsZero():
+			set = append(set, bson.E{Key: path, Value: value.Interface()})
+		}
+	}
+	return set
+}
+
+// flattens reports whether value is a plain struct, or a pointer to one, that
+// SetFromStruct turns into dotted paths rather than setting as a whole
+func flattens(value reflect.Value) bool {
+	t := value.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeFor[time.Time]() || strings.HasPrefix(t.PkgPath(), "go.mongodb.org/") {
+		return false
+	}
+	for _, m := range []reflect.Type{reflect.TypeFor[bson.Marshaler](), reflect.TypeFor[bson.ValueMarshaler]()} {
+		if t.Implements(m) || reflect.PointerTo(t).Implements(m) {
+			return false
+		}
+	}
+	return true
+}
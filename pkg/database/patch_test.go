@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type patchAddress struct {
+	City   string `bson:"city"`
+	Street string `bson:"street"`
+}
+
+type patchCamera struct {
+	ID       string        `bson:"_id,omitempty"`
+	Name     string        `bson:"name"`
+	Zoom     int           `bson:"zoom"`
+	Online   *bool         `bson:"online"`
+	Label    *string       `bson:"label"`
+	Address  patchAddress  `bson:"address"`
+	Backup   *patchAddress `bson:"backup"`
+	Seen     time.Time     `bson:"seen"`
+	Tags     []string      `bson:"tags"`
+	Internal string        `bson:"-"`
+	hidden   string
+}
+
+func TestSetFromStruct(t *testing.T) {
+	online, offline, empty := true, false, ""
+	seen := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input any
+		opts  []PatchOption
+		want  bson.D
+	}{
+		{
+			name:  "NonZeroFields",
+			input: patchCamera{ID: "cam-1", Name: "front", Zoom: 2, Seen: seen, Internal: "x", hidden: "y"},
+			want:  bson.D{{Key: "name", Value: "front"}, {Key: "zoom", Value: 2}, {Key: "seen", Value: seen}},
+		},
+		{
+			name:  "PointersToZero",
+			input: &patchCamera{Online: &offline, Label: &empty},
+			want:  bson.D{{Key: "online", Value: false}, {Key: "label", Value: ""}},
+		},
+		{
+			name:  "PointerToValue",
+			input: patchCamera{Online: &online},
+			want:  bson.D{{Key: "online", Value: true}},
+		},
+		{
+			name:  "NestedFlattened",
+			input: patchCamera{Address: patchAddress{City: "Ghent"}, Backup: &patchAddress{Street: "Main"}},
+			want:  bson.D{{Key: "address.city", Value: "Ghent"}, {Key: "backup.street", Value: "Main"}},
+		},
+		{
+			name:  "IncludeZero",
+			input: patchCamera{Name: "front"},
+			opts:  []PatchOption{IncludeZero("zoom", "label", "address.street")},
+			want:  bson.D{{Key: "name", Value: "front"}, {Key: "zoom", Value: 0}, {Key: "label", Value: nil}, {Key: "address.street", Value: ""}},
+		},
+		{
+			name:  "Slice",
+			input: patchCamera{Tags: []string{}},
+			want:  bson.D{{Key: "tags", Value: []string{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetFromStruct(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := bson.D{{Key: "$set", Value: tt.want}}
+			if !valuesEqual(normalizeDocument(got), normalizeDocument(want)) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := SetFromStruct(patchCamera{ID: "cam-1"}); !errors.Is(err, ErrEmptyPatch) {
+			t.Errorf("expected ErrEmptyPatch, got %v", err)
+		}
+	})
+
+	t.Run("NotAStruct", func(t *testing.T) {
+		if _, err := SetFromStruct(map[string]any{"name": "x"}); err == nil {
+			t.Error("expected an error for a map")
+		}
+	})
+}
+
+func TestSetFromStructPatchFlow(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeDatabase()
+	if _, err := fake.InsertOne(ctx, "vault", "cameras", bson.M{
+		"_id": "cam-1", "name": "front", "online": true, "zoom": 3,
+		"address": bson.M{"city": "Ghent", "street": "Main"},
+	}); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	// The request body of PATCH /cameras/cam-1
+	var req struct {
+		Name    *string `json:"name" bson:"name"`
+		Online  *bool   `json:"online" bson:"online"`
+		Zoom    *int    `json:"zoom" bson:"zoom"`
+		Address *struct {
+			Street *string `json:"street" bson:"street"`
+			City   *string `json:"city" bson:"city"`
+		} `json:"address" bson:"address"`
+	}
+	if err := json.Unmarshal([]byte(`{"online": false, "address": {"street": "Quay"}}`), &req); err != nil {
+		t.Fatalf("failed to decode the request: %v", err)
+	}
+
+	update, err := SetFromStruct(req)
+	if err != nil {
+		t.Fatalf("failed to build the update: %v", err)
+	}
+	if _, err := fake.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": "cam-1"}, update); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+
+	doc := fake.Documents("vault", "cameras")[0]
+	want := map[string]any{
+		"_id": "cam-1", "name": "front", "online": false, "zoom": int32(3),
+		"address": map[string]any{"city": "Ghent", "street": "Quay"},
+	}
+	if !valuesEqual(normalizeDocument(doc), want) {
+		t.Errorf("expected %v, got %v", want, doc)
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// PingResult is the outcome of the last Database.Ping
+type PingResult struct {
+	At      time.Time
+	Latency time.Duration
+	Err     error
+}
+
+// Ping checks the connection and returns its round-trip latency. The ping
+// ends at the deadline of ctx or after the Timeout of the options, whichever
+// comes first. The result is kept for LastPing.
+func (d *Database) Ping(ctx context.Context) (time.Duration, error) {
+	if d.Options != nil {
+		if timeout := d.Options.connectTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	start := time.Now()
+	err := d.Client.Ping(ctx)
+	result := &PingResult{At: start, Latency: time.Since(start), Err: err}
+	d.lastPing.Store(result)
+	if err != nil {
+		return 0, err
+	}
+	return result.Latency, nil
+}
+
+// LastPing returns the result of the last Ping, and false when there has been
+// none
+func (d *Database) LastPing() (PingResult, bool) {
+	result := d.lastPing.Load()
+	if result == nil {
+		return PingResult{}, false
+	}
+	return *result, true
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDatabasePing(t *testing.T) {
+	newDatabase := func(timeout time.Duration, mock *MockDatabase) *Database {
+		db, err := New(NewMongoOptions().SetUri("mongodb://localhost").SetTimeoutDuration(timeout).Build(), mock)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return db
+	}
+
+	t.Run("Latency", func(t *testing.T) {
+		db := newDatabase(time.Second, NewMockDatabase().WithDelay("Ping", 20*time.Millisecond))
+		if _, ok := db.LastPing(); ok {
+			t.Error("expected no last ping before the first")
+		}
+		latency, err := db.Ping(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if latency < 20*time.Millisecond || latency > time.Second {
+			t.Errorf("expected a latency of about 20ms, got %s", latency)
+		}
+		last, ok := db.LastPing()
+		if !ok || last.Latency != latency || last.Err != nil || time.Since(last.At) > time.Second {
+			t.Errorf("expected the ping to be recorded, got %+v, %v", last, ok)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		ctxTimeout time.Duration
+	}{
+		{"CallerDeadline", time.Second, 20 * time.Millisecond},
+		{"ConfiguredTimeout", 20 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDatabase(tt.timeout, NewMockDatabase().WithDelay("Ping", 500*time.Millisecond))
+			ctx, cancel := context.WithTimeout(context.Background(), tt.ctxTimeout)
+			defer cancel()
+
+			start := time.Now()
+			latency, err := db.Ping(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+				t.Errorf("expected the shorter deadline to cut the ping short, took %s", elapsed)
+			}
+			if latency != 0 {
+				t.Errorf("expected no latency for a failed ping, got %s", latency)
+			}
+			if last, ok := db.LastPing(); !ok || !errors.Is(last.Err, context.DeadlineExceeded) {
+				t.Errorf("expected the failure to be recorded, got %+v", last)
+			}
+		})
+	}
+}
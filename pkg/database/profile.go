@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Built-in profiles of NewMongoOptionsForProfile
+const (
+	// ProfileDev: 5s timeouts, no TLS, every command logged and traced
+	ProfileDev = "dev"
+	// ProfileTest: a 5s connect and 10s operation timeout, no TLS, a small
+	// pool, commands traced
+	ProfileTest = "test"
+	// ProfileStaging: the production settings with commands traced
+	ProfileStaging = "staging"
+	// ProfileProd: TLS, majority write and read concerns, retryable writes,
+	// a 10s connect and 30s operation timeout, 5 to 50 connections per
+	// server, and traces without commands
+	ProfileProd = "prod"
+)
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]func(*MongoOptionsBuilder){
+		ProfileDev: func(b *MongoOptionsBuilder) {
+			b.SetTimeoutDuration(5 * time.Second).
+				SetClientTimeout(5 * time.Second).
+				SetTLS(false).
+				SetCommandLogging(true).
+				SetCommandAttributesDisabled(false).
+				SetMaxPoolSize(10)
+		},
+		ProfileTest: func(b *MongoOptionsBuilder) {
+			b.SetTimeoutDuration(5 * time.Second).
+				SetClientTimeout(10 * time.Second).
+				SetTLS(false).
+				SetCommandAttributesDisabled(false).
+				SetMaxPoolSize(5)
+		},
+		ProfileStaging: func(b *MongoOptionsBuilder) {
+			production(b)
+			b.SetCommandAttributesDisabled(false)
+		},
+		ProfileProd: production,
+	}
+)
+
+func production(b *MongoOptionsBuilder) {
+	b.SetTimeoutDuration(10 * time.Second).
+		SetClientTimeout(30 * time.Second).
+		SetTLS(true).
+		SetRetryWrites(true).
+		SetWriteConcern("majority").
+		SetReadConcern("majority").
+		SetCommandAttributesDisabled(true).
+		SetMinPoolSize(5).
+		SetMaxPoolSize(50)
+}
+
+// NewMongoOptionsForProfile creates a builder with the settings of a
+// profile: one of ProfileDev, ProfileTest, ProfileStaging and ProfileProd,
+// or a profile added with RegisterProfile. Names are case-insensitive. The
+// settings can be overridden like any other; the address and credentials
+// still have to be set.
+//
+//	opts := database.NewMongoOptionsForProfile(os.Getenv("ENV")).SetUri(uri).Build()
+//
+// An unknown name makes New, and every other use of the options, fail.
+func NewMongoOptionsForProfile(profile string) *MongoOptionsBuilder {
+	b := NewMongoOptions()
+	profilesMu.RLock()
+	apply, ok := profiles[strings.ToLower(strings.TrimSpace(profile))]
+	profilesMu.RUnlock()
+	if !ok {
+		b.options.buildErr = fmt.Errorf("mongo options: unknown profile %q, expected one of %v", profile, ProfileNames())
+		return b
+	}
+	apply(b)
+	return b
+}
+
+// RegisterProfile adds a profile for NewMongoOptionsForProfile, replacing
+// any profile of the same name, built-in ones included
+func RegisterProfile(name string, apply func(*MongoOptionsBuilder)) {
+	if apply == nil {
+		panic("database: RegisterProfile with a nil apply function")
+	}
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles[strings.ToLower(strings.TrimSpace(name))] = apply
+}
+
+// ProfileNames returns the names of the registered profiles, sorted
+func ProfileNames() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func TestNewMongoOptionsForProfile(t *testing.T) {
+	for _, profile := range []string{ProfileDev, ProfileTest, ProfileStaging, ProfileProd} {
+		t.Run(profile, func(t *testing.T) {
+			got, err := json.MarshalIndent(NewMongoOptionsForProfile(profile).Build(), "", "  ")
+			if err != nil {
+				t.Fatalf("failed to encode the options: %v", err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "profiles", profile+".json")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatalf("failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", golden, err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("options differ from %s (run with -update to accept):\n%s", golden, got)
+			}
+
+			// Every built-in profile must build once given an address
+			opts := NewMongoOptionsForProfile(profile).SetHost("mongo.internal:27017").SetUsername("app").SetPassword("secret").SetAuthSource("admin").Build()
+			if _, err := buildClientOptions(opts); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	t.Run("CaseInsensitive", func(t *testing.T) {
+		opts := NewMongoOptionsForProfile(" Prod ").Build()
+		if opts.buildErr != nil || !opts.TLS || opts.WriteConcern != "majority" {
+			t.Errorf("expected the prod profile, got %+v", opts)
+		}
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		opts := NewMongoOptionsForProfile(ProfileProd).SetTLS(false).SetMaxPoolSize(200).Build()
+		if opts.TLS || opts.MaxPoolSize != 200 || opts.WriteConcern != "majority" {
+			t.Errorf("expected the overrides on top of the profile, got %+v", opts)
+		}
+	})
+
+	for _, name := range []string{"qa", ""} {
+		t.Run("Unknown "+name, func(t *testing.T) {
+			opts := NewMongoOptionsForProfile(name).SetUri("mongodb://localhost").SetTimeout(1000).Build()
+			if _, err := New(opts, NewMockDatabase()); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+				t.Errorf("expected an unknown profile error from New, got %v", err)
+			}
+			if _, err := buildClientOptions(opts); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+				t.Errorf("expected an unknown profile error from buildClientOptions, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisterProfile(t *testing.T) {
+	t.Run("Custom", func(t *testing.T) {
+		RegisterProfile("Edge", func(b *MongoOptionsBuilder) {
+			b.SetTimeoutDuration(2 * time.Second).SetReadPreference("nearest")
+		})
+		t.Cleanup(func() { unregisterProfile("edge") })
+
+		opts := NewMongoOptionsForProfile("edge").Build()
+		if opts.buildErr != nil || opts.TimeoutDuration != 2*time.Second || opts.ReadPreference != "nearest" {
+			t.Errorf("expected the edge profile, got %+v", opts)
+		}
+	})
+
+	t.Run("ReplaceBuiltIn", func(t *testing.T) {
+		profilesMu.RLock()
+		dev := profiles[ProfileDev]
+		profilesMu.RUnlock()
+		RegisterProfile(ProfileDev, func(b *MongoOptionsBuilder) { b.SetMaxPoolSize(3) })
+		t.Cleanup(func() { RegisterProfile(ProfileDev, dev) })
+
+		opts := NewMongoOptionsForProfile(ProfileDev).Build()
+		if opts.MaxPoolSize != 3 || opts.CommandLogging {
+			t.Errorf("expected the replaced dev profile, got %+v", opts)
+		}
+	})
+}
+
+func unregisterProfile(name string) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	delete(profiles, name)
+}
+
+func TestProfileClientOptions(t *testing.T) {
+	options := func() *MongoOptionsBuilder {
+		return NewMongoOptions().SetHost("mongo.internal:27017").SetUsername("app").SetPassword("secret").SetAuthSource("admin").SetTimeout(1000)
+	}
+
+	tests := []struct {
+		name string
+		w    string
+		want *writeconcern.WriteConcern
+	}{
+		{"Majority", "majority", writeconcern.Majority()},
+		{"Members", "2", &writeconcern.WriteConcern{W: 2}},
+		{"Tag", "dc-east", writeconcern.Custom("dc-east")},
+	}
+	for _, tt := range tests {
+		t.Run("WriteConcern "+tt.name, func(t *testing.T) {
+			clientOpts, err := buildClientOptions(options().SetWriteConcern(tt.w).Build())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if clientOpts.WriteConcern == nil || clientOpts.WriteConcern.W != tt.want.W {
+				t.Errorf("expected write concern %v, got %v", tt.want.W, clientOpts.WriteConcern)
+			}
+		})
+	}
+
+	t.Run("CommandLogging", func(t *testing.T) {
+		clientOpts, err := buildClientOptions(options().SetCommandLogging(true).Build())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if clientOpts.LoggerOptions == nil {
+			t.Error("expected logger options")
+		}
+		clientOpts, err = buildClientOptions(options().Build())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if clientOpts.LoggerOptions != nil {
+			t.Error("expected no logger options without command logging")
+		}
+	})
+}
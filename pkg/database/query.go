@@ -0,0 +1,516 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchesFilter reports whether doc satisfies a MongoDB query filter. It
+// supports the comparison, element, array and logical query operators;
+// anything else returns an error naming the operator.
+func matchesFilter(doc map[string]any, filter any) (bool, error) {
+	if filter == nil {
+		return true, nil
+	}
+	query, ok := normalizeDocument(filter).(map[string]any)
+	if !ok {
+		return false, fmt.Errorf("filter must be a document, got %T", filter)
+	}
+	return matchQuery(doc, query)
+}
+
+func matchQuery(doc map[string]any, query map[string]any) (bool, error) {
+	for key, cond := range query {
+		var ok bool
+		var err error
+		switch key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(doc, key, cond)
+		case "$text":
+			var q textQuery
+			if q, err = parseTextQuery(cond); err == nil {
+				ok = q.score(doc) > 0
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("unsupported query operator %s", key)
+			}
+			ok, err = matchField(doc, key, cond)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc map[string]any, op string, cond any) (bool, error) {
+	clauses, ok := cond.([]any)
+	if !ok || len(clauses) == 0 {
+		return false, fmt.Errorf("%s must be a non-empty array", op)
+	}
+	for _, clause := range clauses {
+		query, ok := clause.(map[string]any)
+		if !ok {
+			return false, fmt.Errorf("%s entries must be documents", op)
+		}
+		matched, err := matchQuery(doc, query)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case op == "$and" && !matched:
+			return false, nil
+		case op == "$or" && matched:
+			return true, nil
+		case op == "$nor" && matched:
+			return false, nil
+		}
+	}
+	return op != "$or", nil
+}
+
+func matchField(doc map[string]any, path string, cond any) (bool, error) {
+	values, found := resolvePath(doc, path)
+	if ops, ok := operatorDocument(cond); ok {
+		for op, arg := range ops {
+			if op == "$options" {
+				continue
+			}
+			matched, err := applyOperator(op, arg, ops, values, found)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if re, ok := cond.(primitive.Regex); ok {
+		return matchRegex(values, re.Pattern, re.Options), nil
+	}
+	return matchEquals(values, found, cond), nil
+}
+
+// operatorDocument returns cond as a map when every key is a query operator
+func operatorDocument(cond any) (map[string]any, bool) {
+	m, ok := cond.(map[string]any)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+func applyOperator(op string, arg any, ops map[string]any, values []any, found bool) (bool, error) {
+	switch op {
+	case "$eq":
+		return matchEquals(values, found, arg), nil
+	case "$ne":
+		return !matchEquals(values, found, arg), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		return matchCompare(op, values, arg), nil
+	case "$in", "$nin":
+		list, ok := arg.([]any)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array", op)
+		}
+		in := false
+		for _, candidate := range list {
+			if re, ok := candidate.(primitive.Regex); ok {
+				if matchRegex(values, re.Pattern, re.Options) {
+					in = true
+					break
+				}
+				continue
+			}
+			if matchEquals(values, found, candidate) {
+				in = true
+				break
+			}
+		}
+		return in == (op == "$in"), nil
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			f, isNum := toFloat(arg)
+			want, ok = f != 0, isNum
+		}
+		if !ok {
+			return false, fmt.Errorf("$exists needs a boolean")
+		}
+		return found == want, nil
+	case "$regex":
+		pattern, options, err := regexArgument(arg, ops["$options"])
+		if err != nil {
+			return false, err
+		}
+		return matchRegex(values, pattern, options), nil
+	case "$not":
+		if re, ok := arg.(primitive.Regex); ok {
+			return !matchRegex(values, re.Pattern, re.Options), nil
+		}
+		inner, ok := operatorDocument(arg)
+		if !ok {
+			return false, fmt.Errorf("$not needs an operator document or regex")
+		}
+		for innerOp, innerArg := range inner {
+			if innerOp == "$options" {
+				continue
+			}
+			matched, err := applyOperator(innerOp, innerArg, inner, values, found)
+			if err != nil {
+				return false, err
+			}
+			if !matched {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$size":
+		size, ok := toFloat(arg)
+		if !ok {
+			return false, fmt.Errorf("$size needs a number")
+		}
+		for _, v := range values {
+			if arr, ok := v.([]any); ok && float64(len(arr)) == size {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$all":
+		list, ok := arg.([]any)
+		if !ok {
+			return false, fmt.Errorf("$all needs an array")
+		}
+		for _, want := range list {
+			if !matchEquals(values, found, want) {
+				return false, nil
+			}
+		}
+		return len(list) > 0, nil
+	case "$elemMatch":
+		return matchElem(values, arg)
+	}
+	return false, fmt.Errorf("unsupported query operator %s", op)
+}
+
+// matchEquals implements MongoDB equality: a value matches directly, an
+// array matches when it equals want or contains it, and null matches missing
+func matchEquals(values []any, found bool, want any) bool {
+	if want == nil && !found {
+		return true
+	}
+	for _, v := range expandArrays(values) {
+		if valuesEqual(v, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchCompare(op string, values []any, arg any) bool {
+	for _, v := range expandArrays(values) {
+		if typeOrder(v) != typeOrder(arg) {
+			continue
+		}
+		c := compareValues(v, arg)
+		switch {
+		case op == "$gt" && c > 0,
+			op == "$gte" && c >= 0,
+			op == "$lt" && c < 0,
+			op == "$lte" && c <= 0:
+			return true
+		}
+	}
+	return false
+}
+
+func matchElem(values []any, arg any) (bool, error) {
+	query, ok := arg.(map[string]any)
+	if !ok {
+		return false, fmt.Errorf("$elemMatch needs a document")
+	}
+	ops, isOps := operatorDocument(query)
+	for _, v := range values {
+		arr, ok := v.([]any)
+		if !ok {
+			continue
+		}
+		for _, elem := range arr {
+			var matched bool
+			var err error
+			if isOps {
+				matched = true
+				for op, opArg := range ops {
+					if op == "$options" {
+						continue
+					}
+					ok, opErr := applyOperator(op, opArg, ops, []any{elem}, true)
+					if opErr != nil {
+						return false, opErr
+					}
+					matched = matched && ok
+				}
+			} else if doc, ok := elem.(map[string]any); ok {
+				matched, err = matchQuery(doc, query)
+				if err != nil {
+					return false, err
+				}
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func regexArgument(arg any, options any) (string, string, error) {
+	switch re := arg.(type) {
+	case string:
+		opts, _ := options.(string)
+		return re, opts, nil
+	case primitive.Regex:
+		return re.Pattern, re.Options, nil
+	}
+	return "", "", fmt.Errorf("$regex needs a string or regex")
+}
+
+func matchRegex(values []any, pattern, options string) bool {
+	flags := ""
+	for _, o := range options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false
+	}
+	for _, v := range expandArrays(values) {
+		if s, ok := v.(string); ok && re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath returns every value reachable at a dotted path, descending into
+// arrays of documents, and whether the path exists at all
+func resolvePath(doc map[string]any, path string) ([]any, bool) {
+	return resolveParts(doc, strings.Split(path, "."))
+}
+
+func resolveParts(current any, parts []string) ([]any, bool) {
+	if len(parts) == 0 {
+		return []any{current}, true
+	}
+	switch t := current.(type) {
+	case map[string]any:
+		next, ok := t[parts[0]]
+		if !ok {
+			return nil, false
+		}
+		return resolveParts(next, parts[1:])
+	case []any:
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx < 0 || idx >= len(t) {
+				return nil, false
+			}
+			return resolveParts(t[idx], parts[1:])
+		}
+		var out []any
+		found := false
+		for _, elem := range t {
+			if vals, ok := resolveParts(elem, parts); ok {
+				out = append(out, vals...)
+				found = true
+			}
+		}
+		return out, found
+	}
+	return nil, false
+}
+
+// expandArrays returns values followed by the elements of any array values
+func expandArrays(values []any) []any {
+	out := append([]any{}, values...)
+	for _, v := range values {
+		if arr, ok := v.([]any); ok {
+			out = append(out, arr...)
+		}
+	}
+	return out
+}
+
+// typeOrder ranks values following MongoDB's cross-type comparison order:
+// null, numbers, strings, documents, arrays, binary, ObjectIDs, booleans,
+// dates, timestamps, regular expressions
+func typeOrder(v any) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 0
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case string, primitive.Symbol:
+		return 3
+	case map[string]any:
+		return 4
+	case []any:
+		return 5
+	case []byte, primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case time.Time, primitive.DateTime:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	case primitive.MaxKey:
+		return 12
+	}
+	if _, ok := toFloat(v); ok {
+		return 2
+	}
+	if _, ok := v.(primitive.Decimal128); ok {
+		return 2
+	}
+	return 13
+}
+
+// compareValues totally orders two values, first by typeOrder and then by
+// value within the same type
+func compareValues(a, b any) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return compareInts(ta, tb)
+	}
+	switch ta {
+	case 2:
+		af, bf := numericValue(a), numericValue(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	case 4:
+		return compareDocuments(a.(map[string]any), b.(map[string]any))
+	case 5:
+		aa, ba := a.([]any), b.([]any)
+		for i := 0; i < len(aa) && i < len(ba); i++ {
+			if c := compareValues(aa[i], ba[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(aa), len(ba))
+	case 6:
+		return bytes.Compare(binaryBytes(a), binaryBytes(b))
+	case 7:
+		ao, bo := a.(primitive.ObjectID), b.(primitive.ObjectID)
+		return bytes.Compare(ao[:], bo[:])
+	case 8:
+		ab, bb := a.(bool), b.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case !ab:
+			return -1
+		}
+		return 1
+	case 9:
+		at, _ := asTime(a)
+		bt, _ := asTime(b)
+		return at.Compare(bt)
+	case 10:
+		at, bt := a.(primitive.Timestamp), b.(primitive.Timestamp)
+		return primitive.CompareTimestamp(at, bt)
+	case 11:
+		return strings.Compare(a.(primitive.Regex).String(), b.(primitive.Regex).String())
+	}
+	return 0
+}
+
+func compareDocuments(a, b map[string]any) int {
+	ak, bk := sortedKeys(a), sortedKeys(b)
+	for i := 0; i < len(ak) && i < len(bk); i++ {
+		if c := strings.Compare(ak[i], bk[i]); c != 0 {
+			return c
+		}
+		if c := compareValues(a[ak[i]], b[bk[i]]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(ak), len(bk))
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func numericValue(v any) float64 {
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	if d, ok := v.(primitive.Decimal128); ok {
+		f, _ := strconv.ParseFloat(d.String(), 64)
+		return f
+	}
+	return 0
+}
+
+func binaryBytes(v any) []byte {
+	if b, ok := v.(primitive.Binary); ok {
+		return b.Data
+	}
+	b, _ := v.([]byte)
+	return b
+}
+
+// asTime converts time.Time and primitive.DateTime to time.Time. A time.Time
+// is cut to the millisecond precision of a BSON date, as the driver does when
+// sending it, so bounds compare as they would on the server.
+func asTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return primitive.NewDateTimeFromTime(t).Time(), true
+	case primitive.DateTime:
+		return t.Time(), true
+	}
+	return time.Time{}, false
+}
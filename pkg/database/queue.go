@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Statuses of a queued job
+const (
+	JobPending = "pending"
+	JobClaimed = "claimed"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+// defaultQueueMaxAttempts is the number of claims after which a job is
+// dead-lettered unless PushOptions.MaxAttempts says otherwise
+const defaultQueueMaxAttempts = 5
+
+// ErrNoJob is returned by QueueClaim when no job is visible
+var ErrNoJob = errors.New("no job available")
+
+// ErrJobLost is returned by the methods of a Job whose visibility ran out
+// and that was claimed again by another worker
+var ErrJobLost = errors.New("job lost")
+
+// PushOptions configures QueuePush
+type PushOptions struct {
+	// Delay hides the job from QueueClaim for this long
+	Delay time.Duration
+	// MaxAttempts is the number of claims after which a job that was not
+	// acknowledged moves to JobDead, 5 by default
+	MaxAttempts int
+	// Clock supplies the time, the system clock by default
+	Clock Clock
+}
+
+// QueueOption configures QueueClaim
+type QueueOption func(*queueConfig)
+
+type queueConfig struct {
+	clock Clock
+}
+
+// QueueClock sets the clock visibility is measured against, the system
+// clock by default; the Job keeps using it
+func QueueClock(clock Clock) QueueOption {
+	return func(c *queueConfig) {
+		c.clock = clock
+	}
+}
+
+// QueueIndexes returns the indexes QueueClaim queries a queue collection
+// with, for EnsureIndexes:
+//
+//	err := database.EnsureIndexes(ctx, db.Client, "vault", "jobs", database.QueueIndexes()...)
+func QueueIndexes() []IndexSpec {
+	return []IndexSpec{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "visible_at", Value: 1}}},
+	}
+}
+
+// QueuePush adds job, any value that marshals to BSON, to the queue in
+// db.collection and returns the ID of the queued document
+func (d *Database) QueuePush(ctx context.Context, db string, collection string, job any, opts PushOptions) (primitive.ObjectID, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultQueueMaxAttempts
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	now := opts.Clock.Now()
+	id := primitive.NewObjectID()
+	_, err := d.Client.InsertOne(ctx, db, collection, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "payload", Value: job},
+		{Key: "status", Value: JobPending},
+		{Key: "attempts", Value: 0},
+		{Key: "max_attempts", Value: opts.MaxAttempts},
+		{Key: "created_at", Value: now},
+		{Key: "visible_at", Value: now.Add(opts.Delay)},
+	})
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("queue push %s.%s: %w", db, collection, err)
+	}
+	return id, nil
+}
+
+// QueueClaim atomically claims the job of db.collection that has been
+// visible the longest, a pending one or one whose worker did not acknowledge
+// it in time, for workerID. The job stays hidden from other workers for
+// visibility; ExtendVisibility keeps it for longer. A job claimed more than
+// its MaxAttempts times is moved to JobDead instead. QueueClaim returns
+// ErrNoJob when no job is visible.
+//
+//	for {
+//		job, err := db.QueueClaim(ctx, "vault", "jobs", worker, time.Minute)
+//		if errors.Is(err, database.ErrNoJob) {
+//			time.Sleep(time.Second)
+//			continue
+//		}
+//		...
+//		if err := process(ctx, job); err != nil {
+//			job.Nack(ctx, 30*time.Second)
+//			continue
+//		}
+//		job.Ack(ctx)
+//	}
+func (d *Database) QueueClaim(ctx context.Context, db string, collection string, workerID string, visibility time.Duration, opts ...QueueOption) (*Job, error) {
+	cfg := queueConfig{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for {
+		now := cfg.clock.Now()
+		claim := primitive.NewObjectID()
+		doc, err := d.Client.FindOneAndUpdate(ctx, db, collection,
+			bson.M{"status": bson.M{"$in": bson.A{JobPending, JobClaimed}}, "visible_at": bson.M{"$lte": now}},
+			bson.M{
+				"$set": bson.M{"status": JobClaimed, "visible_at": now.Add(visibility), "claimed_by": workerID, "claim": claim},
+				"$inc": bson.M{"attempts": 1},
+			},
+			moptions.FindOneAndUpdate().
+				SetSort(bson.D{{Key: "visible_at", Value: 1}, {Key: "_id", Value: 1}}).
+				SetReturnDocument(moptions.After))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNoJob
+		}
+		if err != nil {
+			return nil, fmt.Errorf("queue claim %s.%s: %w", db, collection, err)
+		}
+		job := &Job{client: d.Client, db: db, collection: collection, clock: cfg.clock}
+		if err := decodeDocument(doc, job); err != nil {
+			return nil, fmt.Errorf("queue claim %s.%s: decode job: %w", db, collection, err)
+		}
+		if job.Attempts <= job.MaxAttempts {
+			return job, nil
+		}
+		// Its last worker abandoned the job on its final attempt
+		if err := job.finish(ctx, bson.M{"$set": bson.M{"status": JobDead}}); err != nil && !errors.Is(err, ErrJobLost) {
+			return nil, err
+		}
+	}
+}
+
+// Job is a job claimed by QueueClaim
+type Job struct {
+	ID primitive.ObjectID `bson:"_id"`
+	// Payload is the job passed to QueuePush, see Decode
+	Payload     bson.RawValue `bson:"payload"`
+	Attempts    int           `bson:"attempts"`
+	MaxAttempts int           `bson:"max_attempts"`
+	CreatedAt   time.Time     `bson:"created_at"`
+	// VisibleAt is when the claim runs out and other workers may claim the
+	// job again
+	VisibleAt time.Time          `bson:"visible_at"`
+	ClaimedBy string             `bson:"claimed_by"`
+	Claim     primitive.ObjectID `bson:"claim"`
+
+	client     DatabaseInterface
+	db         string
+	collection string
+	clock      Clock
+}
+
+// Decode decodes the payload of the job into val
+func (j *Job) Decode(val any) error {
+	return j.Payload.Unmarshal(val)
+}
+
+// Ack marks the job JobDone
+func (j *Job) Ack(ctx context.Context) error {
+	return j.finish(ctx, bson.M{"$set": bson.M{"status": JobDone, "done_at": j.clock.Now()}})
+}
+
+// Nack returns the job to the queue, visible again after retryAfter, or
+// moves it to JobDead when it used up its attempts
+func (j *Job) Nack(ctx context.Context, retryAfter time.Duration) error {
+	if j.Attempts >= j.MaxAttempts {
+		return j.finish(ctx, bson.M{"$set": bson.M{"status": JobDead}})
+	}
+	return j.finish(ctx, bson.M{"$set": bson.M{"status": JobPending, "visible_at": j.clock.Now().Add(retryAfter)}})
+}
+
+// ExtendVisibility keeps the job hidden from other workers for d from now,
+// for work that takes longer than the visibility it was claimed with
+func (j *Job) ExtendVisibility(ctx context.Context, d time.Duration) error {
+	visibleAt := j.clock.Now().Add(d)
+	if err := j.update(ctx, bson.M{"$set": bson.M{"visible_at": visibleAt}}); err != nil {
+		return err
+	}
+	j.VisibleAt = visibleAt
+	return nil
+}
+
+// finish applies update, which ends the claim
+func (j *Job) finish(ctx context.Context, update bson.M) error {
+	update["$unset"] = bson.M{"claim": "", "claimed_by": ""}
+	return j.update(ctx, update)
+}
+
+// update applies update if the job is still claimed by j
+func (j *Job) update(ctx context.Context, update bson.M) error {
+	res, err := j.client.UpdateOne(ctx, j.db, j.collection, bson.M{"_id": j.ID, "claim": j.Claim}, update)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", j.ID.Hex(), err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("job %s: %w", j.ID.Hex(), ErrJobLost)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	// setup returns a queue database whose clock is stopped at start
+	setup := func() (*Database, *FakeDatabase, *TestClock) {
+		fake := NewFakeDatabase()
+		clock := NewTestClock(start)
+		fake.SetClock(clock)
+		return &Database{Client: fake}, fake, clock
+	}
+	status := func(t *testing.T, fake *FakeDatabase, id primitive.ObjectID) string {
+		t.Helper()
+		for _, doc := range fake.Documents("vault", "jobs") {
+			if doc["_id"] == id {
+				return doc["status"].(string)
+			}
+		}
+		t.Fatalf("job %s not found", id.Hex())
+		return ""
+	}
+
+	t.Run("ClaimAck", func(t *testing.T) {
+		d, fake, clock := setup()
+		first, _ := d.QueuePush(ctx, "vault", "jobs", bson.M{"report": "daily"}, PushOptions{Clock: clock})
+		clock.Advance(time.Second)
+		d.QueuePush(ctx, "vault", "jobs", bson.M{"report": "weekly"}, PushOptions{Clock: clock})
+
+		job, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload struct{ Report string }
+		if err := job.Decode(&payload); err != nil || payload.Report != "daily" || job.ID != first {
+			t.Errorf("expected the oldest job, got %+v, %v", payload, err)
+		}
+		if job.Attempts != 1 || job.ClaimedBy != "w1" || !job.VisibleAt.Equal(start.Add(time.Second+time.Minute)) {
+			t.Errorf("expected the claim, got %+v", job)
+		}
+		if err := job.Ack(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := status(t, fake, first); got != JobDone {
+			t.Errorf("expected the job to be done, got %s", got)
+		}
+		if _, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock)); !errors.Is(err, ErrNoJob) {
+			t.Errorf("expected an empty queue, got %v", err)
+		}
+	})
+
+	t.Run("Delay", func(t *testing.T) {
+		d, _, clock := setup()
+		d.QueuePush(ctx, "vault", "jobs", bson.M{}, PushOptions{Delay: time.Minute, Clock: clock})
+		if _, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock)); !errors.Is(err, ErrNoJob) {
+			t.Errorf("expected the delayed job to be hidden, got %v", err)
+		}
+		clock.Advance(time.Minute)
+		if _, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock)); err != nil {
+			t.Errorf("expected the job after its delay, got %v", err)
+		}
+	})
+
+	t.Run("VisibilityExpiry", func(t *testing.T) {
+		d, _, clock := setup()
+		d.QueuePush(ctx, "vault", "jobs", bson.M{}, PushOptions{Clock: clock})
+		abandoned, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.QueueClaim(ctx, "vault", "jobs", "w2", time.Minute, QueueClock(clock)); !errors.Is(err, ErrNoJob) {
+			t.Fatalf("expected the claimed job to be hidden, got %v", err)
+		}
+		// w1 dies; its job shows up again once the visibility ran out
+		clock.Advance(time.Minute)
+		job, err := d.QueueClaim(ctx, "vault", "jobs", "w2", time.Minute, QueueClock(clock))
+		if err != nil || job.ID != abandoned.ID || job.Attempts != 2 {
+			t.Fatalf("expected the abandoned job to be claimed again, got %+v, %v", job, err)
+		}
+		if err := abandoned.Ack(ctx); !errors.Is(err, ErrJobLost) {
+			t.Errorf("expected the first worker to have lost the job, got %v", err)
+		}
+		if err := job.Ack(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("ExtendVisibility", func(t *testing.T) {
+		d, _, clock := setup()
+		d.QueuePush(ctx, "vault", "jobs", bson.M{}, PushOptions{Clock: clock})
+		job, _ := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Minute, QueueClock(clock))
+		clock.Advance(50 * time.Second)
+		if err := job.ExtendVisibility(ctx, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(50 * time.Second)
+		if _, err := d.QueueClaim(ctx, "vault", "jobs", "w2", time.Minute, QueueClock(clock)); !errors.Is(err, ErrNoJob) {
+			t.Errorf("expected the extended job to stay hidden, got %v", err)
+		}
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		tests := []struct {
+			name string
+			fail func(job *Job) error
+		}{
+			{"Nack", func(job *Job) error { return job.Nack(ctx, time.Second) }},
+			{"Abandoned", func(*Job) error { return nil }},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				d, fake, clock := setup()
+				id, _ := d.QueuePush(ctx, "vault", "jobs", bson.M{}, PushOptions{MaxAttempts: 2, Clock: clock})
+				for attempt := 1; attempt <= 2; attempt++ {
+					job, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Second, QueueClock(clock))
+					if err != nil || job.Attempts != attempt {
+						t.Fatalf("expected attempt %d, got %+v, %v", attempt, job, err)
+					}
+					if err := tt.fail(job); err != nil {
+						t.Fatal(err)
+					}
+					clock.Advance(time.Second)
+				}
+				if _, err := d.QueueClaim(ctx, "vault", "jobs", "w1", time.Second, QueueClock(clock)); !errors.Is(err, ErrNoJob) {
+					t.Errorf("expected no claim after the last attempt, got %v", err)
+				}
+				if got := status(t, fake, id); got != JobDead {
+					t.Errorf("expected the job to be dead-lettered, got %s", got)
+				}
+			})
+		}
+	})
+
+	t.Run("ConcurrentWorkers", func(t *testing.T) {
+		d, fake, clock := setup()
+		const jobs = 200
+		for i := range jobs {
+			d.QueuePush(ctx, "vault", "jobs", bson.M{"n": i}, PushOptions{Clock: clock})
+		}
+		var mu sync.Mutex
+		claimed := map[primitive.ObjectID]string{}
+		var wg sync.WaitGroup
+		for w := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				worker := fmt.Sprintf("w%d", w)
+				for {
+					job, err := d.QueueClaim(ctx, "vault", "jobs", worker, time.Minute, QueueClock(clock))
+					if errors.Is(err, ErrNoJob) {
+						return
+					}
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					if other, ok := claimed[job.ID]; ok {
+						t.Errorf("job %s claimed by %s and %s", job.ID.Hex(), other, worker)
+					}
+					claimed[job.ID] = worker
+					mu.Unlock()
+					if err := job.Ack(ctx); err != nil {
+						t.Error(err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if len(claimed) != jobs {
+			t.Errorf("expected %d jobs claimed once each, got %d", jobs, len(claimed))
+		}
+		for _, doc := range fake.Documents("vault", "jobs") {
+			if doc["status"] != JobDone {
+				t.Errorf("expected every job done, got %v", doc)
+			}
+		}
+	})
+
+	t.Run("Indexes", func(t *testing.T) {
+		d, _, _ := setup()
+		if err := EnsureIndexes(ctx, d.Client, "vault", "jobs", QueueIndexes()...); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned by writes through a read-only client. The error is
+// a *ReadOnlyError naming the refused operation.
+var ErrReadOnly = errors.New("read-only client")
+
+// ReadOnlyError is the error of a write refused by a read-only client
+type ReadOnlyError struct {
+	Operation  string
+	DB         string
+	Collection string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s: %s on %s.%s refused", ErrReadOnly, e.Operation, e.DB, e.Collection)
+}
+
+// Is makes errors.Is(err, ErrReadOnly) hold
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnly
+}
+
+// SetReadOnly makes New wrap the client in WithReadOnly
+func (b *MongoOptionsBuilder) SetReadOnly(readOnly bool) *MongoOptionsBuilder {
+	b.options.ReadOnly = readOnly
+	b.mark("ReadOnly")
+	return b
+}
+
+// WithReadOnly returns middleware that refuses every write with a
+// ReadOnlyError before it reaches the client: inserts, updates, replaces,
+// deletes, bulk writes, aggregations ending in $out or $merge, index creation,
+// schema changes and view creation and removal. Reads pass through.
+//
+//	client := database.Wrap(db.Client, database.WithReadOnly())
+func WithReadOnly() Middleware {
+	return func(next DatabaseInterface) DatabaseInterface {
+		return &readOnlyClient{DatabaseInterface: next}
+	}
+}
+
+// readOnlyClient passes reads through and refuses writes
+type readOnlyClient struct {
+	DatabaseInterface
+}
+
+var (
+	_ Indexer       = (*readOnlyClient)(nil)
+	_ SchemaManager = (*readOnlyClient)(nil)
+	_ ViewManager   = (*readOnlyClient)(nil)
+)
+
+func refuse(operation string, db string, collection string) error {
+	return &ReadOnlyError{Operation: operation, DB: db, Collection: collection}
+}
+
+// Aggregate refuses pipelines that write their output to a collection
+func (c *readOnlyClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	for _, stage := range pipelineStagesOf(pipeline) {
+		if stage.Name == "$out" || stage.Name == "$merge" {
+			return nil, refuse("Aggregate with "+stage.Name, db, collection)
+		}
+	}
+	return c.DatabaseInterface.Aggregate(ctx, db, collection, pipeline, opts...)
+}
+
+func (c *readOnlyClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	return nil, refuse("InsertOne", db, collection)
+}
+
+func (c *readOnlyClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	return nil, refuse("InsertMany", db, collection)
+}
+
+func (c *readOnlyClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return nil, refuse("UpdateOne", db, collection)
+}
+
+func (c *readOnlyClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return nil, refuse("UpdateMany", db, collection)
+}
+
+func (c *readOnlyClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	return nil, refuse("ReplaceOne", db, collection)
+}
+
+func (c *readOnlyClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return 0, refuse("DeleteOne", db, collection)
+}
+
+func (c *readOnlyClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return 0, refuse("DeleteMany", db, collection)
+}
+
+func (c *readOnlyClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	return nil, refuse("FindOneAndUpdate", db, collection)
+}
+
+func (c *readOnlyClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	return nil, refuse("BulkWrite", db, collection)
+}
+
+func (c *readOnlyClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	return refuse("EnsureIndexes", db, collection)
+}
+
+func (c *readOnlyClient) ApplySchema(ctx context.Context, db string, collection string, schema map[string]any, level string, action string) error {
+	return refuse("ApplySchema", db, collection)
+}
+
+// GetSchema passes the read on to the wrapped client
+func (c *readOnlyClient) GetSchema(ctx context.Context, db string, collection string) (*SchemaValidation, error) {
+	return GetSchema(ctx, c.DatabaseInterface, db, collection)
+}
+
+func (c *readOnlyClient) CreateView(ctx context.Context, db string, name string, viewOn string, pipeline any) error {
+	return refuse("CreateView", db, name)
+}
+
+func (c *readOnlyClient) DropView(ctx context.Context, db string, name string) error {
+	return refuse("DropView", db, name)
+}
+
+// ListViews passes the read on to the wrapped client
+func (c *readOnlyClient) ListViews(ctx context.Context, db string) ([]ViewInfo, error) {
+	return ListViews(ctx, c.DatabaseInterface, db)
+}
+
+// Unwrap returns the wrapped client
+func (c *readOnlyClient) Unwrap() DatabaseInterface {
+	return c.DatabaseInterface
+}
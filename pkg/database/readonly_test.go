@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWithReadOnly(t *testing.T) {
+	ctx := context.Background()
+
+	writes := []struct {
+		operation string
+		call      func(c DatabaseInterface) error
+	}{
+		{"InsertOne", func(c DatabaseInterface) error {
+			_, err := c.InsertOne(ctx, "vault", "cameras", bson.M{"name": "cam-1"})
+			return err
+		}},
+		{"InsertMany", func(c DatabaseInterface) error {
+			_, err := c.InsertMany(ctx, "vault", "cameras", []any{bson.M{"name": "cam-1"}})
+			return err
+		}},
+		{"UpdateOne", func(c DatabaseInterface) error {
+			_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.M{"$set": bson.M{"online": true}})
+			return err
+		}},
+		{"UpdateMany", func(c DatabaseInterface) error {
+			_, err := c.UpdateMany(ctx, "vault", "cameras", bson.M{}, bson.M{"$set": bson.M{"online": true}})
+			return err
+		}},
+		{"ReplaceOne", func(c DatabaseInterface) error {
+			_, err := c.ReplaceOne(ctx, "vault", "cameras", bson.M{}, bson.M{"name": "cam-2"})
+			return err
+		}},
+		{"DeleteOne", func(c DatabaseInterface) error {
+			_, err := c.DeleteOne(ctx, "vault", "cameras", bson.M{})
+			return err
+		}},
+		{"DeleteMany", func(c DatabaseInterface) error {
+			_, err := c.DeleteMany(ctx, "vault", "cameras", bson.M{})
+			return err
+		}},
+		{"FindOneAndUpdate", func(c DatabaseInterface) error {
+			_, err := c.FindOneAndUpdate(ctx, "vault", "cameras", bson.M{}, bson.M{"$inc": bson.M{"n": 1}})
+			return err
+		}},
+		{"BulkWrite", func(c DatabaseInterface) error {
+			_, err := c.BulkWrite(ctx, "vault", "cameras", []any{mongo.NewDeleteOneModel().SetFilter(bson.M{})})
+			return err
+		}},
+		{"Aggregate with $out", func(c DatabaseInterface) error {
+			_, err := c.Aggregate(ctx, "vault", "cameras", mongo.Pipeline{{{Key: "$match", Value: bson.M{}}}, {{Key: "$out", Value: "archive"}}})
+			return err
+		}},
+		{"Aggregate with $merge", func(c DatabaseInterface) error {
+			_, err := c.Aggregate(ctx, "vault", "cameras", []bson.M{{"$merge": bson.M{"into": "archive"}}})
+			return err
+		}},
+		{"EnsureIndexes", func(c DatabaseInterface) error {
+			return EnsureIndexes(ctx, c, "vault", "cameras", IndexSpec{Keys: bson.D{{Key: "name", Value: 1}}})
+		}},
+		{"ApplySchema", func(c DatabaseInterface) error {
+			return ApplySchema(ctx, c, "vault", "cameras", map[string]any{"required": []string{"name"}}, "", "")
+		}},
+		{"CreateView", func(c DatabaseInterface) error {
+			return CreateView(ctx, c, "vault", "cameras", "camera_records", bson.A{})
+		}},
+		{"DropView", func(c DatabaseInterface) error {
+			return DropView(ctx, c, "vault", "cameras")
+		}},
+	}
+
+	for _, tt := range writes {
+		t.Run(tt.operation, func(t *testing.T) {
+			mock := NewMockDatabase()
+			err := tt.call(mock.ReadOnly())
+
+			if !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("expected ErrReadOnly, got %v", err)
+			}
+			var roErr *ReadOnlyError
+			if !errors.As(err, &roErr) || roErr.Operation != tt.operation || roErr.DB != "vault" || roErr.Collection != "cameras" {
+				t.Errorf("expected a ReadOnlyError for %s on vault.cameras, got %#v", tt.operation, roErr)
+			}
+			if !strings.Contains(err.Error(), tt.operation+" on vault.cameras") {
+				t.Errorf("expected the error to name the operation and namespace, got %q", err)
+			}
+			mock.AssertNoWrites(t)
+			if calls := len(mock.History()); calls != 0 {
+				t.Errorf("expected the write to never reach the client, got %d calls", calls)
+			}
+		})
+	}
+
+	t.Run("Reads", func(t *testing.T) {
+		mock := NewMockDatabase().
+			QueueFind([]any{bson.M{"name": "cam-1"}}, nil).
+			QueueCount(1, nil).
+			QueueAggregate([]any{bson.M{"n": 1}}, nil)
+		client := mock.ReadOnly()
+
+		if _, err := client.Find(ctx, "vault", "cameras", bson.M{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if n, err := client.Count(ctx, "vault", "cameras", bson.M{}); err != nil || n != 1 {
+			t.Errorf("expected a count of 1, got %d, %v", n, err)
+		}
+		if _, err := client.Aggregate(ctx, "vault", "cameras", mongo.Pipeline{{{Key: "$group", Value: bson.M{"_id": nil, "n": bson.M{"$sum": 1}}}}}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := client.Ping(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		mock.AssertCalled(t, "Find", 1)
+		mock.AssertCalled(t, "Aggregate", 1)
+	})
+
+	t.Run("GetSchema", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if err := ApplySchema(ctx, fake, "vault", "cameras", map[string]any{"required": []string{"name"}}, "", ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		schema, err := GetSchema(ctx, Wrap(fake, WithReadOnly()), "vault", "cameras")
+		if err != nil || schema == nil {
+			t.Errorf("expected the schema to be read through, got %v, %v", schema, err)
+		}
+	})
+
+	t.Run("ListViews", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if err := CreateView(ctx, fake, "vault", "camera_status", "cameras", bson.A{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		views, err := ListViews(ctx, Wrap(fake, WithReadOnly()), "vault")
+		if err != nil || len(views) != 1 {
+			t.Errorf("expected the views to be read through, got %v, %v", views, err)
+		}
+	})
+
+	t.Run("SetReadOnly", func(t *testing.T) {
+		mock := NewMockDatabase()
+		db, err := New(NewMongoOptions().SetUri("mongodb://localhost").SetTimeout(1000).SetReadOnly(true).Build(), mock)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := db.Client.DeleteMany(ctx, "vault", "cameras", bson.M{}); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected ErrReadOnly, got %v", err)
+		}
+		if _, err := db.Client.Find(ctx, "vault", "cameras", bson.M{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		mock.AssertNoWrites(t)
+	})
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRotationGracePeriod is how long RotateCredentials leaves the previous
+// client open when no grace period is set
+const defaultRotationGracePeriod = 30 * time.Second
+
+// SetRotationGracePeriod sets how long RotateCredentials leaves the previous
+// client open for the operations still running on it before closing it
+func (b *MongoOptionsBuilder) SetRotationGracePeriod(d time.Duration) *MongoOptionsBuilder {
+	b.options.RotationGracePeriod = d
+	return b
+}
+
+// connectMongo creates the MongoDB client of New, Reconnect and
+// RotateCredentials
+func connectMongo(ctx context.Context, opts *MongoOptions) (DatabaseInterface, error) {
+	m, err := newMongoClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reconnect replaces the client with a new one connected with the same
+// options, so a CredentialProvider is asked for credentials again, and closes
+// the old client. It only applies to a client New created from the options.
+func (d *Database) Reconnect(ctx context.Context) error {
+	d.swapMu.Lock()
+	defer d.swapMu.Unlock()
+
+	prev, err := d.replace(ctx, d.reconnectOptions, false)
+	if err != nil {
+		return fmt.Errorf("reconnect: %w", err)
+	}
+	if prev == nil {
+		return nil
+	}
+	return prev.Close(ctx)
+}
+
+// RotateCredentials switches the client to a new username and password
+// without a restart: it connects a new client with them, verifies it with a
+// ping and swaps it in, so new operations use it while those already running
+// finish on the previous client, which is closed after the grace period of
+// SetRotationGracePeriod. When the new client fails to connect or to answer
+// the ping, the previous client stays in place and the error is returned.
+// Later calls to Reconnect keep the new credentials.
+//
+//	if err := db.RotateCredentials(ctx, "app", newPassword); err != nil {
+//		log.Printf("rotation failed, still on the old password: %v", err)
+//	}
+func (d *Database) RotateCredentials(ctx context.Context, username string, password string) error {
+	d.swapMu.Lock()
+	defer d.swapMu.Unlock()
+
+	if d.reconnectOptions == nil {
+		return errors.New("rotate credentials: the client was not created by New")
+	}
+	rotated := *d.reconnectOptions
+	rotated.CredentialProvider = NewStaticCredentialProvider(username, password)
+	prev, err := d.replace(ctx, &rotated, true)
+	if err != nil {
+		return fmt.Errorf("rotate credentials: %w", err)
+	}
+	if prev != nil {
+		grace := rotated.RotationGracePeriod
+		if grace == 0 {
+			grace = defaultRotationGracePeriod
+		}
+		time.AfterFunc(grace, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), grace)
+			defer cancel()
+			prev.Close(ctx)
+		})
+	}
+	return nil
+}
+
+// Current returns the client in use right now, for the methods outside
+// DatabaseInterface such as CreateDataKey of a MongoClient. Database.Client
+// keeps reaching the current client across Reconnect and RotateCredentials,
+// but the client Current returns is replaced, so do not keep it around.
+//
+//	keyID, err := db.Current().(*database.MongoClient).CreateDataKey(ctx, "local", nil)
+func (d *Database) Current() DatabaseInterface {
+	if d.live == nil {
+		return d.Client
+	}
+	return d.live.load()
+}
+
+// replace connects a client with opts and makes it the current client,
+// returning the previous one. With verify, the new client must answer a ping
+// first. On an error the client is left unchanged. The caller holds swapMu.
+func (d *Database) replace(ctx context.Context, opts *MongoOptions, verify bool) (DatabaseInterface, error) {
+	if d.live == nil {
+		return nil, errors.New("the client was not created by New")
+	}
+	next, err := d.connect(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		if err := next.Ping(ctx); err != nil {
+			next.Close(ctx)
+			return nil, err
+		}
+	}
+	d.reconnectOptions = opts
+	return d.live.swap(next), nil
+}
+
+// liveClient forwards every call to the current client, which Reconnect and
+// RotateCredentials replace. Database.Client holds it for a client New
+// created, so the field never changes and concurrent calls through it always
+// reach the current client.
+type liveClient struct {
+	current atomic.Pointer[clientRef]
+}
+
+// clientRef boxes a client for the atomic pointer
+type clientRef struct {
+	DatabaseInterface
+}
+
+var _ Indexer = (*liveClient)(nil)
+
+func newLiveClient(client DatabaseInterface) *liveClient {
+	c := &liveClient{}
+	c.current.Store(&clientRef{client})
+	return c
+}
+
+// load returns the current client
+func (c *liveClient) load() DatabaseInterface {
+	return c.current.Load().DatabaseInterface
+}
+
+// swap makes next the current client and returns the previous one
+func (c *liveClient) swap(next DatabaseInterface) DatabaseInterface {
+	return c.current.Swap(&clientRef{next}).DatabaseInterface
+}
+
+// Unwrap returns the current client
+func (c *liveClient) Unwrap() DatabaseInterface {
+	return c.load()
+}
+
+func (c *liveClient) Ping(ctx context.Context) error {
+	return c.load().Ping(ctx)
+}
+
+func (c *liveClient) Close(ctx context.Context) error {
+	return c.load().Close(ctx)
+}
+
+func (c *liveClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return c.load().Find(ctx, db, collection, filter, opts...)
+}
+
+func (c *liveClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return c.load().FindOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *liveClient) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	return c.load().FindCursor(ctx, db, collection, filter, opts...)
+}
+
+func (c *liveClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	return c.load().Aggregate(ctx, db, collection, pipeline, opts...)
+}
+
+func (c *liveClient) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return c.load().Count(ctx, db, collection, filter, opts...)
+}
+
+func (c *liveClient) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	return c.load().Distinct(ctx, db, collection, field, filter, opts...)
+}
+
+func (c *liveClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	return c.load().InsertOne(ctx, db, collection, document, opts...)
+}
+
+func (c *liveClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	return c.load().InsertMany(ctx, db, collection, documents, opts...)
+}
+
+func (c *liveClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return c.load().UpdateOne(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *liveClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return c.load().UpdateMany(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *liveClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	return c.load().ReplaceOne(ctx, db, collection, filter, replacement, opts...)
+}
+
+func (c *liveClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return c.load().DeleteOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *liveClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return c.load().DeleteMany(ctx, db, collection, filter, opts...)
+}
+
+func (c *liveClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	return c.load().FindOneAndUpdate(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *liveClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	return c.load().BulkWrite(ctx, db, collection, models, opts...)
+}
+
+// EnsureIndexes passes the indexes on to the current client
+func (c *liveClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	return EnsureIndexes(ctx, c.load(), db, collection, specs...)
+}
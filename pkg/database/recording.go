@@ -0,0 +1,508 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RecordedCall is one operation captured by a RecordingClient. Filter holds
+// the filter, or the document(s) for inserts, the pipeline for Aggregate and
+// the models for BulkWrite; Update holds the update or replacement document
+// and Field the field of Distinct.
+type RecordedCall struct {
+	Operation  string `bson:"operation"`
+	Db         string `bson:"db,omitempty"`
+	Collection string `bson:"collection,omitempty"`
+	Field      string `bson:"field,omitempty"`
+	Filter     any    `bson:"filter,omitempty"`
+	Update     any    `bson:"update,omitempty"`
+	Options    []any  `bson:"options,omitempty"`
+	Result     any    `bson:"result,omitempty"`
+	Error      string `bson:"error,omitempty"`
+}
+
+// String describes the call for replay mismatch errors
+func (c RecordedCall) String() string {
+	if c.Db == "" && c.Collection == "" {
+		return c.Operation
+	}
+	return fmt.Sprintf("%s %s.%s filter=%s", c.Operation, c.Db, c.Collection, describeValue(c.Filter))
+}
+
+// RecordingClient passes every operation through to a real client and appends
+// it to a file, one canonical extended JSON document per line, for later
+// replay with NewReplayClient
+type RecordingClient struct {
+	real DatabaseInterface
+
+	mu   sync.Mutex
+	file *os.File
+	err  error
+}
+
+var _ DatabaseInterface = (*RecordingClient)(nil)
+
+// NewRecordingClient creates a recording of every call made through real at path
+func NewRecordingClient(real DatabaseInterface, path string) (*RecordingClient, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("recording: %w", err)
+	}
+	return &RecordingClient{real: real, file: file}, nil
+}
+
+// Close closes the real client, then the recording file, and returns the
+// first error hit while closing or writing. Close itself is not recorded.
+func (r *RecordingClient) Close(ctx context.Context) error {
+	closeErr := r.real.Close(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("recording: %w", err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return r.err
+}
+
+func (r *RecordingClient) write(call RecordedCall) {
+	line, err := bson.MarshalExtJSON(call, true, false)
+	if err != nil {
+		// Keep the recording usable even when a value cannot be serialized
+		call.Options = nil
+		call.Result = fmt.Sprint(call.Result)
+		line, err = bson.MarshalExtJSON(call, true, false)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		_, err = r.file.Write(append(line, '\n'))
+	}
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("recording: %s: %w", call.Operation, err)
+	}
+}
+
+// record runs fn against the real client and writes the call with its outcome
+func record[R any](r *RecordingClient, call RecordedCall, fn func() (R, error)) (R, error) {
+	result, err := fn()
+	call.Result = result
+	if err != nil {
+		call.Error = err.Error()
+	}
+	r.write(call)
+	return result, err
+}
+
+// Ping records a Ping call
+func (r *RecordingClient) Ping(ctx context.Context) error {
+	_, err := record(r, RecordedCall{Operation: "Ping"}, func() (any, error) {
+		return nil, r.real.Ping(ctx)
+	})
+	return err
+}
+
+// Find records a Find call
+func (r *RecordingClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	call := RecordedCall{Operation: "Find", Db: db, Collection: collection, Filter: filter, Options: opts}
+	return record(r, call, func() (any, error) {
+		return r.real.Find(ctx, db, collection, filter, opts...)
+	})
+}
+
+// FindOne records a FindOne call
+func (r *RecordingClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	call := RecordedCall{Operation: "FindOne", Db: db, Collection: collection, Filter: filter, Options: opts}
+	return record(r, call, func() (any, error) {
+		return r.real.FindOne(ctx, db, collection, filter, opts...)
+	})
+}
+
+// FindCursor records a FindCursor call. The real cursor is read to the end so
+// its documents can be recorded; the caller iterates over the recorded copy.
+// A cursor error is recorded next to the documents read before it.
+func (r *RecordingClient) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	call := RecordedCall{Operation: "FindCursor", Db: db, Collection: collection, Filter: filter, Options: opts}
+	cursor, err := r.real.FindCursor(ctx, db, collection, filter, opts...)
+	if err != nil {
+		call.Error = err.Error()
+		r.write(call)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []any{}
+	for cursor.Next(ctx) {
+		var doc bson.D
+		if err := cursor.Decode(&doc); err != nil {
+			call.Result, call.Error = docs, err.Error()
+			r.write(call)
+			return newSliceCursor(docs, err), nil
+		}
+		docs = append(docs, doc)
+	}
+	call.Result = docs
+	if err := cursor.Err(); err != nil {
+		call.Error = err.Error()
+	}
+	r.write(call)
+	return newSliceCursor(docs, cursor.Err()), nil
+}
+
+// Aggregate records an Aggregate call
+func (r *RecordingClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	call := RecordedCall{Operation: "Aggregate", Db: db, Collection: collection, Filter: pipeline, Options: opts}
+	return record(r, call, func() (any, error) {
+		return r.real.Aggregate(ctx, db, collection, pipeline, opts...)
+	})
+}
+
+// Count records a Count call
+func (r *RecordingClient) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	call := RecordedCall{Operation: "Count", Db: db, Collection: collection, Filter: filter, Options: opts}
+	return record(r, call, func() (int64, error) {
+		return r.real.Count(ctx, db, collection, filter, opts...)
+	})
+}
+
+// Distinct records a Distinct call
+func (r *RecordingClient) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	call := RecordedCall{Operation: "Distinct", Db: db, Collection: collection, Field: field, Filter: filter, Options: opts}
+	return record(r, call, func() ([]any, error) {
+		return r.real.Distinct(ctx, db, collection, field, filter, opts...)
+	})
+}
+
+// InsertOne records an InsertOne call
+func (r *RecordingClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	call := RecordedCall{Operation: "InsertOne", Db: db, Collection: collection, Filter: document, Options: opts}
+	return record(r, call, func() (any, error) {
+		return r.real.InsertOne(ctx, db, collection, document, opts...)
+	})
+}
+
+// InsertMany records an InsertMany call
+func (r *RecordingClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	call := RecordedCall{Operation: "InsertMany", Db: db, Collection: collection, Filter: documents, Options: opts}
+	return record(r, call, func() ([]any, error) {
+		return r.real.InsertMany(ctx, db, collection, documents, opts...)
+	})
+}
+
+// UpdateOne records an UpdateOne call
+func (r *RecordingClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	call := RecordedCall{Operation: "UpdateOne", Db: db, Collection: collection, Filter: filter, Update: update, Options: opts}
+	return record(r, call, func() (*UpdateResult, error) {
+		return r.real.UpdateOne(ctx, db, collection, filter, update, opts...)
+	})
+}
+
+// UpdateMany records an UpdateMany call
+func (r *RecordingClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	call := RecordedCall{Operation: "UpdateMany", Db: db, Collection: collection, Filter: filter, Update: update, Options: opts}
+	return record(r, call, func() (*UpdateResult, error) {
+		return r.real.UpdateMany(ctx, db, collection, filter, update, opts...)
+	})
+}
+
+// ReplaceOne records a ReplaceOne call
+func (r *RecordingClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	call := RecordedCall{Operation: "ReplaceOne", Db: db, Collection: collection, Filter: filter, Update: replacement, Options: opts}
+	return record(r, call, func() (*UpdateResult, error) {
+		return r.real.ReplaceOne(ctx, db, collection, filter, replacement, opts...)
+	})
+}
+
+// DeleteOne records a DeleteOne call
+func (r *RecordingClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	call := RecordedCall{Operation: "DeleteOne", Db: db, Collection: collection, Filter: filter, Options: opts}
+	return record(r, call, func() (int64, error) {
+		return r.real.DeleteOne(ctx, db, collection, filter, opts...)
+	})
+}
+
+// DeleteMany records a DeleteMany call
+func (r *RecordingClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	call := RecordedCall{Operation: "DeleteMany", Db: db, Collection: collection, Filter: filter, Options: opts}
+	return record(r, call, func() (int64, error) {
+		return r.real.DeleteMany(ctx, db, collection, filter, opts...)
+	})
+}
+
+// FindOneAndUpdate records a FindOneAndUpdate call
+func (r *RecordingClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	call := RecordedCall{Operation: "FindOneAndUpdate", Db: db, Collection: collection, Filter: filter, Update: update, Options: opts}
+	return record(r, call, func() (any, error) {
+		return r.real.FindOneAndUpdate(ctx, db, collection, filter, update, opts...)
+	})
+}
+
+// BulkWrite records a BulkWrite call
+func (r *RecordingClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	call := RecordedCall{Operation: "BulkWrite", Db: db, Collection: collection, Filter: models, Options: opts}
+	return record(r, call, func() (*BulkWriteResult, error) {
+		return r.real.BulkWrite(ctx, db, collection, models, opts...)
+	})
+}
+
+// ReplayClient implements DatabaseInterface from a file written by a
+// RecordingClient. Each incoming call consumes the first unused recorded call
+// with the same operation, namespace and normalized filter.
+type ReplayClient struct {
+	mu    sync.Mutex
+	calls []RecordedCall
+	used  []bool
+}
+
+var _ DatabaseInterface = (*ReplayClient)(nil)
+
+// NewReplayClient loads the recording at path
+func NewReplayClient(path string) (*ReplayClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	var calls []RecordedCall
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var call RecordedCall
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &call); err != nil {
+			return nil, fmt.Errorf("replay: %s:%d: %w", path, line, err)
+		}
+		calls = append(calls, call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	return &ReplayClient{calls: calls, used: make([]bool, len(calls))}, nil
+}
+
+// Remaining returns the recorded calls that have not been replayed yet
+func (c *ReplayClient) Remaining() []RecordedCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []RecordedCall
+	for i, call := range c.calls {
+		if !c.used[i] {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+// next consumes the recorded call answering want or explains the closest miss
+func (c *ReplayClient) next(want RecordedCall) (RecordedCall, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wantFilter := normalizeDocument(want.Filter)
+	closest, best := -1, -1
+	for i, call := range c.calls {
+		if c.used[i] {
+			continue
+		}
+		score := 0
+		if call.Operation == want.Operation && call.Field == want.Field {
+			score += 4
+		}
+		if call.Db == want.Db && call.Collection == want.Collection {
+			score += 2
+		}
+		if valuesEqual(normalizeDocument(call.Filter), wantFilter) {
+			score++
+		}
+		if score == 7 {
+			c.used[i] = true
+			return call, nil
+		}
+		if score > best {
+			closest, best = i, score
+		}
+	}
+
+	if closest < 0 {
+		return RecordedCall{}, fmt.Errorf("replay: no recorded call left for %s", want)
+	}
+	return RecordedCall{}, fmt.Errorf("replay: no recorded call matches %s; closest is #%d %s", want, closest, c.calls[closest])
+}
+
+// replay answers a call from the recording, decoding the stored result into R
+func replay[R any](ctx context.Context, c *ReplayClient, want RecordedCall) (R, error) {
+	var zero R
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	call, err := c.next(want)
+	if err != nil {
+		return zero, err
+	}
+	result, err := decodeRecorded[R](call.Result)
+	if err != nil {
+		return zero, fmt.Errorf("replay: %s: %w", want.Operation, err)
+	}
+	return result, recordedError(call.Error)
+}
+
+// decodeRecorded converts a value read back from extended JSON into R
+func decodeRecorded[R any](v any) (R, error) {
+	var out struct {
+		V R `bson:"v"`
+	}
+	if v == nil {
+		return out.V, nil
+	}
+	raw, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return out.V, err
+	}
+	if err := bson.Unmarshal(raw, &out); err != nil {
+		return out.V, err
+	}
+	// Find results are a []any of documents, as the real client returns them
+	if arr, ok := any(out.V).(primitive.A); ok {
+		if r, ok := any([]any(arr)).(R); ok {
+			return r, nil
+		}
+	}
+	return out.V, nil
+}
+
+// recordedError restores a recorded error, keeping well-known driver
+// sentinels comparable with errors.Is
+func recordedError(msg string) error {
+	switch msg {
+	case "":
+		return nil
+	case mongo.ErrNoDocuments.Error():
+		return mongo.ErrNoDocuments
+	case context.DeadlineExceeded.Error():
+		return context.DeadlineExceeded
+	case context.Canceled.Error():
+		return context.Canceled
+	}
+	return errors.New(msg)
+}
+
+func describeValue(v any) string {
+	if v == nil {
+		return "nil"
+	}
+	raw, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	// Strip the {"v": ...} wrapper
+	return string(raw[5 : len(raw)-1])
+}
+
+// Close is a no-op, recordings do not contain Close calls
+func (c *ReplayClient) Close(ctx context.Context) error {
+	return nil
+}
+
+// Ping replays a Ping call
+func (c *ReplayClient) Ping(ctx context.Context) error {
+	_, err := replay[any](ctx, c, RecordedCall{Operation: "Ping"})
+	return err
+}
+
+// Find replays a Find call
+func (c *ReplayClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return replay[any](ctx, c, RecordedCall{Operation: "Find", Db: db, Collection: collection, Filter: filter})
+}
+
+// FindOne replays a FindOne call
+func (c *ReplayClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	return replay[any](ctx, c, RecordedCall{Operation: "FindOne", Db: db, Collection: collection, Filter: filter})
+}
+
+// FindCursor replays a FindCursor call as a cursor over the recorded documents
+func (c *ReplayClient) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	result, err := replay[any](ctx, c, RecordedCall{Operation: "FindCursor", Db: db, Collection: collection, Filter: filter})
+	if result == nil && err != nil {
+		return nil, err
+	}
+	docs, _ := result.([]any)
+	return newSliceCursor(docs, err), nil
+}
+
+// Aggregate replays an Aggregate call
+func (c *ReplayClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	return replay[any](ctx, c, RecordedCall{Operation: "Aggregate", Db: db, Collection: collection, Filter: pipeline})
+}
+
+// Count replays a Count call
+func (c *ReplayClient) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return replay[int64](ctx, c, RecordedCall{Operation: "Count", Db: db, Collection: collection, Filter: filter})
+}
+
+// Distinct replays a Distinct call
+func (c *ReplayClient) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	return replay[[]any](ctx, c, RecordedCall{Operation: "Distinct", Db: db, Collection: collection, Field: field, Filter: filter})
+}
+
+// InsertOne replays an InsertOne call
+func (c *ReplayClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	return replay[any](ctx, c, RecordedCall{Operation: "InsertOne", Db: db, Collection: collection, Filter: document})
+}
+
+// InsertMany replays an InsertMany call
+func (c *ReplayClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	return replay[[]any](ctx, c, RecordedCall{Operation: "InsertMany", Db: db, Collection: collection, Filter: documents})
+}
+
+// UpdateOne replays an UpdateOne call
+func (c *ReplayClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return replay[*UpdateResult](ctx, c, RecordedCall{Operation: "UpdateOne", Db: db, Collection: collection, Filter: filter})
+}
+
+// UpdateMany replays an UpdateMany call
+func (c *ReplayClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	return replay[*UpdateResult](ctx, c, RecordedCall{Operation: "UpdateMany", Db: db, Collection: collection, Filter: filter})
+}
+
+// ReplaceOne replays a ReplaceOne call
+func (c *ReplayClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	return replay[*UpdateResult](ctx, c, RecordedCall{Operation: "ReplaceOne", Db: db, Collection: collection, Filter: filter})
+}
+
+// DeleteOne replays a DeleteOne call
+func (c *ReplayClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return replay[int64](ctx, c, RecordedCall{Operation: "DeleteOne", Db: db, Collection: collection, Filter: filter})
+}
+
+// DeleteMany replays a DeleteMany call
+func (c *ReplayClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	return replay[int64](ctx, c, RecordedCall{Operation: "DeleteMany", Db: db, Collection: collection, Filter: filter})
+}
+
+// FindOneAndUpdate replays a FindOneAndUpdate call
+func (c *ReplayClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	return replay[any](ctx, c, RecordedCall{Operation: "FindOneAndUpdate", Db: db, Collection: collection, Filter: filter})
+}
+
+// BulkWrite replays a BulkWrite call
+func (c *ReplayClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	return replay[*BulkWriteResult](ctx, c, RecordedCall{Operation: "BulkWrite", Db: db, Collection: collection, Filter: models})
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	_ DatabaseInterface = (*RecordingClient)(nil)
+	_ DatabaseInterface = (*ReplayClient)(nil)
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	joined := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	// Record a session against a fake standing in for a real connection
+	real := NewFakeDatabase()
+	real.Seed("testdb", "users", bson.M{"_id": id, "name": "alice", "joined": joined})
+
+	mock := NewMockDatabase()
+	mock.QueueUpdateOne(&UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil)
+	mock.QueueDeleteMany(3, nil)
+	mock.QueueDistinct([]any{"ops", "admin"}, nil)
+
+	recorder, err := NewRecordingClient(real, path)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	recorder.Ping(ctx)
+	recorder.Find(ctx, "testdb", "users", bson.M{"joined": bson.M{"$gte": joined}})
+	recorder.FindOne(ctx, "testdb", "users", bson.M{"_id": id})
+	recorder.FindOne(ctx, "testdb", "users", bson.M{"name": "nobody"})
+	recorder.real = mock
+	recorder.UpdateOne(ctx, "testdb", "users", bson.M{"_id": id}, bson.M{"$set": bson.M{"name": "Alice"}})
+	recorder.DeleteMany(ctx, "testdb", "sessions", bson.M{"user": id})
+	recorder.Distinct(ctx, "testdb", "users", "tags", bson.M{})
+	if err := recorder.Close(ctx); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+
+	replay, err := NewReplayClient(path)
+	if err != nil {
+		t.Fatalf("failed to load recording: %v", err)
+	}
+
+	t.Run("ResultsRoundTrip", func(t *testing.T) {
+		if err := replay.Ping(ctx); err != nil {
+			t.Errorf("expected nil Ping error, got %v", err)
+		}
+
+		result, err := replay.Find(ctx, "testdb", "users", map[string]any{"joined": map[string]any{"$gte": joined}})
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		docs, ok := result.([]any)
+		if !ok || len(docs) != 1 {
+			t.Fatalf("expected one document in []any, got %T %v", result, result)
+		}
+
+		var user struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			Joined time.Time          `bson:"joined"`
+		}
+		decodeInto(t, docs[0], &user)
+		if user.ID != id || !user.Joined.Equal(joined) {
+			t.Errorf("expected ObjectID and time to round-trip, got %+v", user)
+		}
+
+		if _, err := replay.FindOne(ctx, "testdb", "users", bson.D{{Key: "_id", Value: id}}); err != nil {
+			t.Errorf("expected nil error, got %v", err)
+		}
+		if _, err := replay.FindOne(ctx, "testdb", "users", bson.M{"name": "nobody"}); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected mongo.ErrNoDocuments, got %v", err)
+		}
+
+		update, err := replay.UpdateOne(ctx, "testdb", "users", bson.M{"_id": id}, bson.M{})
+		if err != nil || update.MatchedCount != 1 || update.ModifiedCount != 1 {
+			t.Errorf("expected recorded UpdateResult, got %+v, %v", update, err)
+		}
+	})
+
+	t.Run("MismatchNamesClosestCall", func(t *testing.T) {
+		_, err := replay.DeleteMany(ctx, "testdb", "sessions", bson.M{"user": "someone-else"})
+		if err == nil {
+			t.Fatal("expected mismatch error")
+		}
+		if !strings.Contains(err.Error(), "closest is #5 DeleteMany testdb.sessions") {
+			t.Errorf("expected closest recorded call in error, got %v", err)
+		}
+
+		deleted, err := replay.DeleteMany(ctx, "testdb", "sessions", bson.M{"user": id})
+		if err != nil || deleted != 3 {
+			t.Errorf("expected 3 deleted, got %d, %v", deleted, err)
+		}
+	})
+
+	t.Run("DistinctMatchesOnField", func(t *testing.T) {
+		if _, err := replay.Distinct(ctx, "testdb", "users", "name", bson.M{}); err == nil {
+			t.Error("expected a different field not to match")
+		}
+		values, err := replay.Distinct(ctx, "testdb", "users", "tags", bson.M{})
+		if err != nil || len(values) != 2 || values[0] != "ops" {
+			t.Errorf("expected the recorded values, got %v, %v", values, err)
+		}
+	})
+
+	t.Run("ExhaustedRecording", func(t *testing.T) {
+		if remaining := replay.Remaining(); len(remaining) != 0 {
+			t.Errorf("expected every call replayed, %d left", len(remaining))
+		}
+		if err := replay.Ping(ctx); err == nil || !strings.Contains(err.Error(), "no recorded call left") {
+			t.Errorf("expected exhausted error, got %v", err)
+		}
+	})
+}
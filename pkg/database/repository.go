@@ -0,0 +1,402 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultPageLimit is the page size List uses when PageRequest.Limit is 0
+const DefaultPageLimit = 100
+
+// RepoOption configures NewRepository
+type RepoOption = Option[repoConfig]
+
+type repoConfig struct {
+	idField    string
+	timestamps *TimestampConfig
+	versioned  bool
+	softDelete string
+	clock      Clock
+}
+
+// RepoIDField sets the struct field holding the document's ID, by Go field
+// name or BSON key. By default it is the field stored as _id; any other
+// field is looked up by its own key, so it should have a unique index.
+func RepoIDField(name string) RepoOption {
+	return func(c *repoConfig) {
+		c.idField = name
+	}
+}
+
+// RepoTimestamps runs the repository's writes through WithTimestamps(cfg);
+// the clock of RepoClock is used when cfg has none
+func RepoTimestamps(cfg TimestampConfig) RepoOption {
+	return func(c *repoConfig) {
+		c.timestamps = &cfg
+	}
+}
+
+// RepoVersioned keeps a version in VersionField, which T must have: Create
+// stores version 1, Update only replaces the document at the version the
+// entity carries and returns an error matching ErrVersionConflict
+// otherwise, and Patch increments it
+func RepoVersioned() RepoOption {
+	return func(c *repoConfig) {
+		c.versioned = true
+	}
+}
+
+// RepoSoftDelete makes Delete set field, "deleted_at" when empty, to the
+// current time instead of removing the document; Get, List, Update, Patch
+// and Delete then skip documents that have it
+func RepoSoftDelete(field string) RepoOption {
+	return func(c *repoConfig) {
+		if field == "" {
+			field = "deleted_at"
+		}
+		c.softDelete = field
+	}
+}
+
+// RepoClock sets the clock for soft deletes and timestamps, the system
+// clock by default
+func RepoClock(clock Clock) RepoOption {
+	return func(c *repoConfig) {
+		c.clock = clock
+	}
+}
+
+// PageRequest selects one page of List
+type PageRequest struct {
+	// Sort is the field to order by, "-" prefixed for descending; _id by
+	// default
+	Sort string
+	// Limit is the page size, DefaultPageLimit when 0
+	Limit int64
+	// Token continues from a previous page's NextToken; empty for the first
+	Token string
+}
+
+// ListPage is one page of List
+type ListPage[T any] struct {
+	Items []T
+	// NextToken continues after the last item; it is empty on the last page
+	NextToken string
+}
+
+// Repository stores values of the struct type T in one collection. It only
+// talks to d.Client through DatabaseInterface, so it behaves the same
+// against MongoDB, the mock and the fake, and encodes and decodes with the
+// BSON options of d.Options.
+type Repository[T any] struct {
+	client     DatabaseInterface
+	dbName     string
+	collection string
+	cfg        repoConfig
+	codec      *codec
+}
+
+// NewRepository returns a repository for T in dbName.collection
+//
+//	cameras := database.NewRepository[Camera](db, "vault", "cameras",
+//		database.RepoTimestamps(database.TimestampConfig{}), database.RepoSoftDelete(""))
+//	camera, err := cameras.Get(ctx, id)
+func NewRepository[T any](db *Database, dbName string, collection string, opts ...RepoOption) *Repository[T] {
+	cfg := repoConfig{idField: "_id", clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	client := db.Client
+	if cfg.timestamps != nil {
+		ts := *cfg.timestamps
+		if ts.Clock == nil {
+			ts.Clock = cfg.clock
+		}
+		client = Wrap(client, WithTimestamps(ts))
+	}
+	return &Repository[T]{client: client, dbName: dbName, collection: collection, cfg: cfg, codec: db.codec()}
+}
+
+// Get returns the entity with the given ID, or mongo.ErrNoDocuments
+func (r *Repository[T]) Get(ctx context.Context, id any) (*T, error) {
+	key, err := r.idKey()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.client.FindOne(ctx, r.dbName, r.collection, r.live(bson.D{{Key: key, Value: id}}))
+	if err != nil {
+		return nil, err
+	}
+	out := new(T)
+	if err := r.codec.decode(doc, out); err != nil {
+		return nil, fmt.Errorf("decode document into %T: %w", out, err)
+	}
+	return out, nil
+}
+
+// List returns one page of the entities matching filter, nil for all of them,
+// with keyset pagination as in FindAfter
+func (r *Repository[T]) List(ctx context.Context, filter any, page PageRequest) (*ListPage[T], error) {
+	if page.Sort == "" {
+		page.Sort = "_id"
+	}
+	if page.Limit == 0 {
+		page.Limit = DefaultPageLimit
+	}
+	if r.cfg.softDelete != "" {
+		notDeleted := bson.D{{Key: r.cfg.softDelete, Value: nil}}
+		if filter == nil {
+			filter = notDeleted
+		} else {
+			filter = bson.D{{Key: "$and", Value: bson.A{filter, notDeleted}}}
+		}
+	}
+	found, err := FindAfter(ctx, r.client, r.dbName, r.collection, filter, page.Sort, page.Limit, page.Token)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, len(found.Documents))
+	for i, doc := range found.Documents {
+		if err := r.codec.decode(doc, &items[i]); err != nil {
+			return nil, fmt.Errorf("decode document %d into %T: %w", i, items[i], err)
+		}
+	}
+	return &ListPage[T]{Items: items, NextToken: found.NextToken}, nil
+}
+
+// Create inserts entity. When its ID is the zero value and stored as _id,
+// the ID is generated by the client and set on entity, as is the version of
+// a versioned repository.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	key, err := r.idKey()
+	if err != nil {
+		return err
+	}
+	doc, err := r.encode(entity)
+	if err != nil {
+		return err
+	}
+	generated := key == "_id" && r.idIsZero(entity)
+	if generated {
+		doc = removeField(doc, "_id")
+	}
+	if r.cfg.versioned {
+		doc = setField(doc, VersionField, int64(1))
+	}
+	id, err := r.client.InsertOne(ctx, r.dbName, r.collection, doc)
+	if err != nil {
+		return err
+	}
+
+	var written bson.D
+	if generated {
+		written = append(written, bson.E{Key: "_id", Value: id})
+	}
+	if r.cfg.versioned {
+		written = append(written, bson.E{Key: VersionField, Value: int64(1)})
+	}
+	return r.writeBack(written, entity)
+}
+
+// Update replaces the entity with the given ID by entity and returns
+// mongo.ErrNoDocuments when there is none. A versioned repository only
+// replaces it at the version entity carries and sets the new version on
+// entity.
+func (r *Repository[T]) Update(ctx context.Context, id any, entity *T) error {
+	key, err := r.idKey()
+	if err != nil {
+		return err
+	}
+	doc, err := r.encode(entity)
+	if err != nil {
+		return err
+	}
+	doc = setField(doc, key, id)
+	filter := bson.D{{Key: key, Value: id}}
+
+	var version int64
+	if r.cfg.versioned {
+		current, _ := fieldValue(doc, VersionField)
+		n, ok := toFloat(current)
+		if !ok {
+			return fmt.Errorf("%T has no numeric %s field", *entity, VersionField)
+		}
+		version = int64(n)
+		doc = setField(doc, VersionField, version+1)
+		filter = append(filter, bson.E{Key: VersionField, Value: version})
+	}
+
+	res, err := r.client.ReplaceOne(ctx, r.dbName, r.collection, r.live(filter), doc)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if r.cfg.versioned {
+			return versionMiss(ctx, r.client, r.dbName, r.collection, r.live(filter[:1]), version)
+		}
+		return mongo.ErrNoDocuments
+	}
+	if r.cfg.versioned {
+		return r.writeBack(bson.D{{Key: VersionField, Value: version + 1}}, entity)
+	}
+	return nil
+}
+
+// Patch sets fields on the entity with the given ID, keyed by BSON path, and
+// returns mongo.ErrNoDocuments when there is none
+func (r *Repository[T]) Patch(ctx context.Context, id any, fields map[string]any) error {
+	key, err := r.idKey()
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return errors.New("patch has no fields")
+	}
+	var update any = bson.D{{Key: "$set", Value: bson.M(fields)}}
+	if r.cfg.versioned {
+		if update, err = versionedUpdate(update); err != nil {
+			return err
+		}
+	}
+	res, err := r.client.UpdateOne(ctx, r.dbName, r.collection, r.live(bson.D{{Key: key, Value: id}}), update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Delete removes the entity with the given ID, or marks it deleted with
+// RepoSoftDelete, and returns mongo.ErrNoDocuments when there is none
+func (r *Repository[T]) Delete(ctx context.Context, id any) error {
+	key, err := r.idKey()
+	if err != nil {
+		return err
+	}
+	filter := r.live(bson.D{{Key: key, Value: id}})
+	if r.cfg.softDelete == "" {
+		n, err := r.client.DeleteOne(ctx, r.dbName, r.collection, filter)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return mongo.ErrNoDocuments
+		}
+		return nil
+	}
+
+	res, err := r.client.UpdateOne(ctx, r.dbName, r.collection, filter, U().Set(r.cfg.softDelete, r.cfg.clock.Now().UTC()))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// live adds the soft-delete condition to filter
+func (r *Repository[T]) live(filter bson.D) bson.D {
+	if r.cfg.softDelete == "" {
+		return filter
+	}
+	return append(filter[:len(filter):len(filter)], bson.E{Key: r.cfg.softDelete, Value: nil})
+}
+
+// idKey returns the BSON key of the ID field
+func (r *Repository[T]) idKey() (string, error) {
+	if r.cfg.idField == "_id" {
+		return "_id", nil
+	}
+	field, ok := structField(reflect.TypeFor[T](), r.cfg.idField)
+	if !ok {
+		return "", fmt.Errorf("%v has no ID field %q", reflect.TypeFor[T](), r.cfg.idField)
+	}
+	return bsonKey(field), nil
+}
+
+// idIsZero reports whether the ID field of entity holds its zero value
+func (r *Repository[T]) idIsZero(entity *T) bool {
+	v := reflect.ValueOf(entity).Elem()
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	field, ok := structField(v.Type(), r.cfg.idField)
+	return ok && v.FieldByIndex(field.Index).IsZero()
+}
+
+func (r *Repository[T]) encode(entity *T) (bson.D, error) {
+	raw, err := r.codec.marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("encode %T: %w", *entity, err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// writeBack decodes the fields of doc into entity, leaving the others
+func (r *Repository[T]) writeBack(doc bson.D, entity *T) error {
+	if len(doc) == 0 {
+		return nil
+	}
+	if err := r.codec.decode(doc, entity); err != nil {
+		return fmt.Errorf("set %v on %T: %w", doc, *entity, err)
+	}
+	return nil
+}
+
+// structField finds the top-level field of struct type t with the Go name
+// or BSON key name
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.IsExported() && (f.Name == name || bsonKey(f) == name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// bsonKey returns the key the driver stores f under
+func bsonKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("bson"), ",")
+	if key == "" {
+		return strings.ToLower(f.Name)
+	}
+	return key
+}
+
+// fieldValue returns the value of key in doc
+func fieldValue(doc bson.D, key string) (any, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// removeField returns doc without key
+func removeField(doc bson.D, key string) bson.D {
+	out := doc[:0:0]
+	for _, e := range doc {
+		if e.Key != key {
+			out = append(out, e)
+		}
+	}
+	return out
+}
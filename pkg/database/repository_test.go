@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type repoCamera struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Slug      string             `bson:"slug"`
+	Name      string             `bson:"name"`
+	Version   int64              `bson:"version,omitempty"`
+	CreatedAt time.Time          `bson:"created_at,omitempty"`
+	UpdatedAt time.Time          `bson:"updated_at,omitempty"`
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("CRUD", func(t *testing.T) {
+		repo := NewRepository[repoCamera](&Database{Client: NewFakeDatabase()}, "vault", "cameras")
+
+		camera := &repoCamera{Slug: "front", Name: "Front door"}
+		if err := repo.Create(ctx, camera); err != nil || camera.ID.IsZero() {
+			t.Fatalf("expected a generated ID, got %v, %v", camera.ID, err)
+		}
+		got, err := repo.Get(ctx, camera.ID)
+		if err != nil || *got != *camera {
+			t.Fatalf("expected %+v, got %+v, %v", camera, got, err)
+		}
+
+		camera.Name = "Front gate"
+		if err := repo.Update(ctx, camera.ID, camera); err != nil {
+			t.Fatalf("failed to update: %v", err)
+		}
+		if err := repo.Patch(ctx, camera.ID, map[string]any{"slug": "gate"}); err != nil {
+			t.Fatalf("failed to patch: %v", err)
+		}
+		if got, err := repo.Get(ctx, camera.ID); err != nil || got.Name != "Front gate" || got.Slug != "gate" {
+			t.Errorf("expected the update and patch applied, got %+v, %v", got, err)
+		}
+
+		if err := repo.Delete(ctx, camera.ID); err != nil {
+			t.Fatalf("failed to delete: %v", err)
+		}
+		missing := primitive.NewObjectID()
+		for name, err := range map[string]error{
+			"Get":    func() error { _, err := repo.Get(ctx, camera.ID); return err }(),
+			"Delete": repo.Delete(ctx, camera.ID),
+			"Update": repo.Update(ctx, missing, camera),
+			"Patch":  repo.Patch(ctx, missing, map[string]any{"name": "x"}),
+		} {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				t.Errorf("%s: expected mongo.ErrNoDocuments, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := NewRepository[repoCamera](&Database{Client: NewFakeDatabase()}, "vault", "cameras")
+		for _, name := range []string{"c", "a", "d", "b"} {
+			if err := repo.Create(ctx, &repoCamera{Slug: name, Name: name}); err != nil {
+				t.Fatalf("failed to create: %v", err)
+			}
+		}
+
+		var names []string
+		page := PageRequest{Sort: "name", Limit: 3}
+		for {
+			got, err := repo.List(ctx, bson.M{"name": bson.M{"$ne": "d"}}, page)
+			if err != nil {
+				t.Fatalf("failed to list: %v", err)
+			}
+			for _, camera := range got.Items {
+				names = append(names, camera.Name)
+			}
+			if got.NextToken == "" {
+				break
+			}
+			page.Token = got.NextToken
+		}
+		if len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+			t.Errorf("expected [a b c], got %v", names)
+		}
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		deletedAt := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		repo := NewRepository[repoCamera](&Database{Client: fake}, "vault", "cameras",
+			RepoSoftDelete(""), RepoClock(NewTestClock(deletedAt)))
+
+		camera := &repoCamera{Name: "front"}
+		if err := repo.Create(ctx, camera); err != nil {
+			t.Fatalf("failed to create: %v", err)
+		}
+		if err := repo.Delete(ctx, camera.ID); err != nil {
+			t.Fatalf("failed to delete: %v", err)
+		}
+
+		if _, err := repo.Get(ctx, camera.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected a deleted entity hidden, got %v", err)
+		}
+		if page, err := repo.List(ctx, nil, PageRequest{}); err != nil || len(page.Items) != 0 {
+			t.Errorf("expected an empty list, got %+v, %v", page, err)
+		}
+		if err := repo.Delete(ctx, camera.ID); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected deleting twice to find nothing, got %v", err)
+		}
+		docs := fake.Documents("vault", "cameras")
+		if len(docs) != 1 || !valuesEqual(docs[0]["deleted_at"], deletedAt) {
+			t.Errorf("expected the document kept and marked, got %v", docs)
+		}
+	})
+
+	t.Run("Versioned", func(t *testing.T) {
+		repo := NewRepository[repoCamera](&Database{Client: NewFakeDatabase()}, "vault", "cameras", RepoVersioned())
+
+		camera := &repoCamera{Name: "front"}
+		if err := repo.Create(ctx, camera); err != nil || camera.Version != 1 {
+			t.Fatalf("expected version 1, got %d, %v", camera.Version, err)
+		}
+		stale := *camera
+
+		camera.Name = "back"
+		if err := repo.Update(ctx, camera.ID, camera); err != nil || camera.Version != 2 {
+			t.Fatalf("expected version 2, got %d, %v", camera.Version, err)
+		}
+		stale.Name = "side"
+		if err := repo.Update(ctx, stale.ID, &stale); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("expected ErrVersionConflict for a stale entity, got %v", err)
+		}
+		if err := repo.Patch(ctx, camera.ID, map[string]any{"slug": "back"}); err != nil {
+			t.Fatalf("failed to patch: %v", err)
+		}
+		if got, err := repo.Get(ctx, camera.ID); err != nil || got.Version != 3 || got.Name != "back" {
+			t.Errorf("expected version 3 named back, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Timestamps", func(t *testing.T) {
+		created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		clock := NewTestClock(created)
+		repo := NewRepository[repoCamera](&Database{Client: NewFakeDatabase()}, "vault", "cameras",
+			RepoTimestamps(TimestampConfig{}), RepoClock(clock))
+
+		camera := &repoCamera{Name: "front"}
+		if err := repo.Create(ctx, camera); err != nil {
+			t.Fatalf("failed to create: %v", err)
+		}
+		camera, err := repo.Get(ctx, camera.ID)
+		if err != nil || !camera.CreatedAt.Equal(created) || !camera.UpdatedAt.Equal(created) {
+			t.Fatalf("expected both times set on create, got %+v, %v", camera, err)
+		}
+
+		clock.Advance(time.Hour)
+		camera.Name = "back"
+		camera.UpdatedAt = time.Time{}
+		if err := repo.Update(ctx, camera.ID, camera); err != nil {
+			t.Fatalf("failed to update: %v", err)
+		}
+		if got, err := repo.Get(ctx, camera.ID); err != nil || !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(created.Add(time.Hour)) {
+			t.Errorf("expected only the update time moved, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("IDField", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindOne(bson.M{"slug": "front", "name": "Front door"}, nil)
+		repo := NewRepository[repoCamera](&Database{Client: mock}, "vault", "cameras", RepoIDField("Slug"), RepoSoftDelete(""))
+
+		got, err := repo.Get(ctx, "front")
+		if err != nil || got.Name != "Front door" {
+			t.Fatalf("expected the queued camera, got %+v, %v", got, err)
+		}
+		call, _ := mock.LastFindOneCall()
+		if !FilterEquals(bson.M{"slug": "front", "deleted_at": nil})(call.Filter) {
+			t.Errorf("expected a filter on slug, got %v", call.Filter)
+		}
+
+		mock.QueueReplaceOne(&UpdateResult{MatchedCount: 1}, nil)
+		if err := repo.Update(ctx, "front", &repoCamera{Name: "Front gate"}); err != nil {
+			t.Fatalf("failed to update: %v", err)
+		}
+		replace, _ := mock.LastReplaceOneCall()
+		if !FilterContains(bson.M{"slug": "front", "name": "Front gate"})(replace.Replacement) {
+			t.Errorf("expected the ID set on the replacement, got %v", replace.Replacement)
+		}
+	})
+
+	t.Run("UnknownIDField", func(t *testing.T) {
+		repo := NewRepository[repoCamera](&Database{Client: NewMockDatabase()}, "vault", "cameras", RepoIDField("Serial"))
+		if _, err := repo.Get(ctx, "x"); err == nil {
+			t.Error("expected an error for a missing ID field")
+		}
+	})
+}
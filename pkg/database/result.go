@@ -0,0 +1,19 @@
+package database
+
+// UpdateResult holds the outcome of an UpdateOne, UpdateMany or ReplaceOne operation
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	UpsertedID    any
+}
+
+// BulkWriteResult holds the outcome of a BulkWrite operation
+type BulkWriteResult struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	UpsertedCount int64
+	UpsertedIDs   map[int64]any
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ScanOption configures ParallelScan
+type ScanOption = Option[scanConfig]
+
+type scanConfig struct {
+	concurrency int
+	progress    func(partition int, processed int64)
+}
+
+// ScanConcurrency limits how many partitions ParallelScan reads at once. By
+// default every partition runs at the same time.
+func ScanConcurrency(n int) ScanOption {
+	return func(c *scanConfig) {
+		c.concurrency = n
+	}
+}
+
+// ScanProgress calls fn after every document with the number of documents
+// the partition has processed. It is called from the partitions' goroutines,
+// so it must be safe for concurrent use and quick.
+func ScanProgress(fn func(partition int, processed int64)) ScanOption {
+	return func(c *scanConfig) {
+		c.progress = fn
+	}
+}
+
+// PartitionError is the error of the ParallelScan partition that failed
+// first
+type PartitionError struct {
+	Partition  int
+	Partitions int
+	Err        error
+}
+
+func (e *PartitionError) Error() string {
+	return fmt.Sprintf("parallel scan: partition %d of %d: %v", e.Partition, e.Partitions, e.Err)
+}
+
+func (e *PartitionError) Unwrap() error {
+	return e.Err
+}
+
+// ParallelScan calls fn with every document matching filter, reading the
+// collection in up to partitions _id ranges at once with one cursor each, for
+// exports that take hours through one cursor. The range boundaries are the
+// _id values found by skipping through the matching documents in _id order,
+// so the ranges hold about as many documents each, and a document inserted
+// during the scan falls into exactly one of them. _id values must all be of
+// one type, such as ObjectIDs, since a range only matches its own type.
+//
+// fn is called from several goroutines at once. The first error, of fn or of
+// a cursor, cancels the other partitions and is returned as a
+// *PartitionError naming the partition.
+//
+//	err := db.ParallelScan(ctx, "vault", "events", bson.M{}, 8, func(doc map[string]any) error {
+//		return export(doc)
+//	}, database.ScanProgress(func(partition int, processed int64) {
+//		progress[partition].Store(processed)
+//	}))
+func (d *Database) ParallelScan(ctx context.Context, db string, collection string, filter any, partitions int, fn func(doc map[string]any) error, opts ...ScanOption) error {
+	if partitions < 1 {
+		return fmt.Errorf("parallel scan: partitions must be at least 1, got %d", partitions)
+	}
+	cfg := scanConfig{concurrency: partitions}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	bounds, err := d.scanBounds(ctx, db, collection, filter, partitions)
+	if err != nil {
+		return fmt.Errorf("parallel scan: split: %w", err)
+	}
+	ranges := scanRanges(filter, bounds)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+		slots = make(chan struct{}, cfg.concurrency)
+	)
+	fail := func(partition int, err error) {
+		once.Do(func() {
+			first = &PartitionError{Partition: partition, Partitions: len(ranges), Err: err}
+			cancel(first)
+		})
+	}
+	for i, query := range ranges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				fail(i, context.Cause(ctx))
+				return
+			}
+			var processed int64
+			err := d.FindEach(ctx, db, collection, query, func(doc map[string]any) error {
+				if err := fn(doc); err != nil {
+					return err
+				}
+				processed++
+				if cfg.progress != nil {
+					cfg.progress(i, processed)
+				}
+				return nil
+			})
+			if err != nil {
+				fail(i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
+
+// scanBounds returns the _id values that split the documents matching filter
+// into up to partitions ranges of about the same size
+func (d *Database) scanBounds(ctx context.Context, db string, collection string, filter any, partitions int) ([]any, error) {
+	if partitions == 1 {
+		return nil, nil
+	}
+	count, err := d.Client.Count(ctx, db, collection, orEmpty(filter))
+	if err != nil {
+		return nil, err
+	}
+	var bounds []any
+	var prev int64
+	for i := 1; i < partitions; i++ {
+		skip := count * int64(i) / int64(partitions)
+		if skip == prev {
+			// Fewer documents than partitions
+			continue
+		}
+		prev = skip
+		doc, err := d.Client.FindOne(ctx, db, collection, orEmpty(filter),
+			moptions.FindOne().SetSort(bson.D{{Key: "_id", Value: 1}}).SetSkip(skip).SetProjection(bson.M{"_id": 1}))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Documents were deleted since the count
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields, _ := normalizeDocument(doc).(map[string]any)
+		id, ok := fields["_id"]
+		if !ok {
+			return nil, fmt.Errorf("document at %d has no _id", skip)
+		}
+		if len(bounds) > 0 && compareValues(id, bounds[len(bounds)-1]) <= 0 {
+			// Documents were deleted between the queries; a bound out of
+			// order would make two ranges overlap
+			continue
+		}
+		bounds = append(bounds, id)
+	}
+	return bounds, nil
+}
+
+// scanRanges returns the filters of the _id ranges between bounds
+func scanRanges(filter any, bounds []any) []any {
+	ranges := make([]any, 0, len(bounds)+1)
+	for i := 0; i <= len(bounds); i++ {
+		r := bson.M{}
+		if i > 0 {
+			r["$gte"] = bounds[i-1]
+		}
+		if i < len(bounds) {
+			r["$lt"] = bounds[i]
+		}
+		query := any(bson.M{"_id": r})
+		if len(r) == 0 {
+			query = orEmpty(filter)
+		} else if filter != nil {
+			query = bson.M{"$and": bson.A{filter, query}}
+		}
+		ranges = append(ranges, query)
+	}
+	return ranges
+}
+
+// orEmpty returns filter, or an empty filter for nil
+func orEmpty(filter any) any {
+	if filter == nil {
+		return bson.M{}
+	}
+	return filter
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParallelScan(t *testing.T) {
+	ctx := context.Background()
+	seeded := func(t *testing.T, n int) (*Database, []primitive.ObjectID) {
+		fake := NewFakeDatabase()
+		ids := make([]primitive.ObjectID, n)
+		for i := range ids {
+			ids[i] = primitive.NewObjectID()
+			if err := fake.Seed("vault", "events", bson.M{"_id": ids[i], "n": i, "even": i%2 == 0}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &Database{Client: fake}, ids
+	}
+
+	tests := []struct {
+		name       string
+		docs       int
+		partitions int
+		filter     any
+		want       int
+	}{
+		{"FourPartitions", 103, 4, nil, 103},
+		{"Filter", 103, 4, bson.M{"even": true}, 52},
+		{"MorePartitionsThanDocuments", 3, 8, bson.M{}, 3},
+		{"OnePartition", 10, 1, nil, 10},
+		{"Empty", 0, 4, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := seeded(t, tt.docs)
+			var mu sync.Mutex
+			visits := map[primitive.ObjectID]int{}
+			progress := make([]atomic.Int64, tt.partitions)
+			err := db.ParallelScan(ctx, "vault", "events", tt.filter, tt.partitions, func(doc map[string]any) error {
+				mu.Lock()
+				defer mu.Unlock()
+				visits[doc["_id"].(primitive.ObjectID)]++
+				return nil
+			}, ScanProgress(func(partition int, processed int64) {
+				progress[partition].Store(processed)
+			}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(visits) != tt.want {
+				t.Errorf("expected %d documents, got %d", tt.want, len(visits))
+			}
+			for id, n := range visits {
+				if n != 1 {
+					t.Errorf("expected %s to be visited once, got %d", id.Hex(), n)
+				}
+			}
+			var total int64
+			for i := range progress {
+				total += progress[i].Load()
+			}
+			if total != int64(tt.want) {
+				t.Errorf("expected the progress to add up to %d, got %d", tt.want, total)
+			}
+		})
+	}
+
+	t.Run("Ranges", func(t *testing.T) {
+		db, ids := seeded(t, 8)
+		bounds, err := db.scanBounds(ctx, "vault", "events", nil, 4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []any{ids[2], ids[4], ids[6]}
+		if len(bounds) != len(want) {
+			t.Fatalf("expected bounds %v, got %v", want, bounds)
+		}
+		for i := range want {
+			if bounds[i] != want[i] {
+				t.Errorf("expected bound %d to be %v, got %v", i, want[i], bounds[i])
+			}
+		}
+	})
+
+	t.Run("FirstErrorCancels", func(t *testing.T) {
+		db, ids := seeded(t, 400)
+		failed := errors.New("export rejected")
+		var visited atomic.Int64
+		err := db.ParallelScan(ctx, "vault", "events", nil, 4, func(doc map[string]any) error {
+			visited.Add(1)
+			if doc["_id"] == ids[150] {
+				return failed
+			}
+			return nil
+		}, ScanConcurrency(2))
+		var partitionErr *PartitionError
+		if !errors.As(err, &partitionErr) || partitionErr.Partition != 1 || partitionErr.Partitions != 4 || !errors.Is(err, failed) {
+			t.Fatalf("expected partition 1 of 4 to fail, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "partition 1 of 4") {
+			t.Errorf("expected the partition in the message, got %v", err)
+		}
+		if visited.Load() >= 400 {
+			t.Errorf("expected the other partitions to stop, visited %d", visited.Load())
+		}
+	})
+
+	t.Run("SplitError", func(t *testing.T) {
+		mock := NewMockDatabase().ExpectCount(0, errors.New("connection refused"))
+		err := (&Database{Client: mock}).ParallelScan(ctx, "vault", "events", nil, 4, func(map[string]any) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "split: connection refused") {
+			t.Errorf("expected the split to fail, got %v", err)
+		}
+	})
+
+	t.Run("Partitions", func(t *testing.T) {
+		db, _ := seeded(t, 1)
+		if err := db.ParallelScan(ctx, "vault", "events", nil, 0, func(map[string]any) error { return nil }); err == nil {
+			t.Error("expected zero partitions to be rejected")
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCollectionNotFound is returned by GetSchema when the collection does
+// not exist
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// Validation levels accepted by ApplySchema
+const (
+	ValidationStrict   = "strict"
+	ValidationModerate = "moderate"
+	ValidationOff      = "off"
+)
+
+// Validation actions accepted by ApplySchema
+const (
+	ValidationError = "error"
+	ValidationWarn  = "warn"
+)
+
+// SchemaValidation is the $jsonSchema validator of a collection
+type SchemaValidation struct {
+	Schema map[string]any
+	Level  string
+	Action string
+}
+
+// SchemaManager is implemented by clients that manage collection validators
+type SchemaManager interface {
+	ApplySchema(ctx context.Context, db string, collection string, schema map[string]any, level string, action string) error
+	GetSchema(ctx context.Context, db string, collection string) (*SchemaValidation, error)
+}
+
+// ApplySchema makes schema the $jsonSchema validator of db.collection,
+// creating the collection if it does not exist and updating the validator
+// with collMod if it does. level is one of ValidationStrict,
+// ValidationModerate and ValidationOff, and action ValidationError or
+// ValidationWarn; empty values keep the server's defaults, strict and error.
+// The client must implement SchemaManager, as MongoClient and FakeDatabase
+// do.
+func ApplySchema(ctx context.Context, client DatabaseInterface, db string, collection string, schema map[string]any, level string, action string) error {
+	manager, ok := implementation[SchemaManager](client)
+	if !ok {
+		return fmt.Errorf("client %T cannot manage schemas", client)
+	}
+	if len(schema) == 0 {
+		return errors.New("apply schema: schema is empty")
+	}
+	if level != "" && !slices.Contains([]string{ValidationStrict, ValidationModerate, ValidationOff}, level) {
+		return fmt.Errorf("apply schema: validation level must be strict, moderate or off, got %q", level)
+	}
+	if action != "" && !slices.Contains([]string{ValidationError, ValidationWarn}, action) {
+		return fmt.Errorf("apply schema: validation action must be error or warn, got %q", action)
+	}
+	return manager.ApplySchema(ctx, db, collection, schema, level, action)
+}
+
+// GetSchema returns the $jsonSchema validator of db.collection, nil when it
+// has none, and an error matching ErrCollectionNotFound when the collection
+// does not exist. Compare its Schema with SchemaFor to detect drift.
+func GetSchema(ctx context.Context, client DatabaseInterface, db string, collection string) (*SchemaValidation, error) {
+	manager, ok := implementation[SchemaManager](client)
+	if !ok {
+		return nil, fmt.Errorf("client %T cannot manage schemas", client)
+	}
+	return manager.GetSchema(ctx, db, collection)
+}
+
+// ApplySchema creates the collection with the validator or updates it
+func (m *MongoClient) ApplySchema(ctx context.Context, db string, collection string, schema map[string]any, level string, action string) error {
+	database := m.Client.Database(db)
+	names, err := database.ListCollectionNames(ctx, bson.D{{Key: "name", Value: collection}})
+	if err != nil {
+		return mapError(err)
+	}
+	validator := bson.D{{Key: "$jsonSchema", Value: schema}}
+
+	if len(names) == 0 {
+		opts := moptions.CreateCollection().SetValidator(validator)
+		if level != "" {
+			opts.SetValidationLevel(level)
+		}
+		if action != "" {
+			opts.SetValidationAction(action)
+		}
+		return mapError(database.CreateCollection(ctx, collection, opts))
+	}
+
+	cmd := bson.D{{Key: "collMod", Value: collection}, {Key: "validator", Value: validator}}
+	if level != "" {
+		cmd = append(cmd, bson.E{Key: "validationLevel", Value: level})
+	}
+	if action != "" {
+		cmd = append(cmd, bson.E{Key: "validationAction", Value: action})
+	}
+	return mapError(database.RunCommand(ctx, cmd).Err())
+}
+
+// GetSchema reads the validator from the collection's options
+func (m *MongoClient) GetSchema(ctx context.Context, db string, collection string) (*SchemaValidation, error) {
+	specs, err := m.Client.Database(db).ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: collection}})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("%w: %s.%s", ErrCollectionNotFound, db, collection)
+	}
+	var options struct {
+		Validator struct {
+			JSONSchema bson.M `bson:"$jsonSchema"`
+		} `bson:"validator"`
+		ValidationLevel  string `bson:"validationLevel"`
+		ValidationAction string `bson:"validationAction"`
+	}
+	if len(specs[0].Options) > 0 {
+		if err := bson.Unmarshal(specs[0].Options, &options); err != nil {
+			return nil, err
+		}
+	}
+	if options.Validator.JSONSchema == nil {
+		return nil, nil
+	}
+	return &SchemaValidation{
+		Schema: normalizeDocument(options.Validator.JSONSchema).(map[string]any),
+		Level:  options.ValidationLevel,
+		Action: options.ValidationAction,
+	}, nil
+}
+
+// SchemaFor generates a $jsonSchema for the struct type T from its bson
+// tags and field types, as a starting point for ApplySchema. Fields are
+// required unless they are omitempty or pointers, pointers also allow null,
+// and nested structs, slices and maps are described recursively. Fields of
+// interface type accept any value.
+//
+//	schema, err := database.SchemaFor[Camera]()
+//	err = database.ApplySchema(ctx, db.Client, "vault", "cameras", schema, database.ValidationStrict, database.ValidationError)
+func SchemaFor[T any]() (map[string]any, error) {
+	t := reflect.TypeFor[T]()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema for %v: need a struct type", t)
+	}
+	return typeSchema(t, map[reflect.Type]bool{})
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	dateTimeType = reflect.TypeFor[primitive.DateTime]()
+	objectIDType = reflect.TypeFor[primitive.ObjectID]()
+	idType       = reflect.TypeFor[ID]()
+	decimalType  = reflect.TypeFor[primitive.Decimal128]()
+	binaryType   = reflect.TypeFor[primitive.Binary]()
+	uuidType     = reflect.TypeFor[UUID]()
+	bsonMType    = reflect.TypeFor[bson.M]()
+	bsonDType    = reflect.TypeFor[bson.D]()
+	bsonAType    = reflect.TypeFor[bson.A]()
+)
+
+// typeSchema describes a value of type t; seen holds the struct types being
+// described, to reject recursive types
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	switch t {
+	case timeType, dateTimeType:
+		return map[string]any{"bsonType": "date"}, nil
+	case objectIDType, idType:
+		return map[string]any{"bsonType": "objectId"}, nil
+	case decimalType:
+		return map[string]any{"bsonType": "decimal"}, nil
+	case binaryType, uuidType:
+		return map[string]any{"bsonType": "binData"}, nil
+	case bsonMType, bsonDType:
+		return map[string]any{"bsonType": "object"}, nil
+	case bsonAType:
+		return map[string]any{"bsonType": "array"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"bsonType": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"bsonType": "bool"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]any{"bsonType": "int"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		// The driver stores these as int32 when the value fits
+		return map[string]any{"bsonType": []any{"int", "long"}}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"bsonType": "double"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Pointer:
+		schema, err := typeSchema(t.Elem(), seen)
+		if err != nil || schema["bsonType"] == nil {
+			return schema, err
+		}
+		types, ok := schema["bsonType"].([]any)
+		if !ok {
+			types = []any{schema["bsonType"]}
+		}
+		schema["bsonType"] = append(types, "null")
+		return schema, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"bsonType": "binData"}, nil
+		}
+		items, err := typeSchema(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"bsonType": "array"}
+		if len(items) > 0 {
+			schema["items"] = items
+		}
+		return schema, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key of %v must be a string", t)
+		}
+		values, err := typeSchema(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"bsonType": "object"}
+		if len(values) > 0 {
+			schema["additionalProperties"] = values
+		}
+		return schema, nil
+	case reflect.Struct:
+		return structSchema(t, seen)
+	}
+	return nil, fmt.Errorf("type %v has no BSON schema", t)
+}
+
+// structSchema describes the fields of struct type t as an object
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	if seen[t] {
+		return nil, fmt.Errorf("type %v is recursive", t)
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	properties := map[string]any{}
+	var required []any
+	if err := addStructFields(t, seen, properties, &required); err != nil {
+		return nil, err
+	}
+	schema := map[string]any{"bsonType": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// addStructFields adds the fields of t, and of its inline structs, to
+// properties and required
+func addStructFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]any, required *[]any) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("bson")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		_, flags, _ := strings.Cut(tag, ",")
+		if strings.Contains(flags, "inline") && f.Type.Kind() == reflect.Struct {
+			if err := addStructFields(f.Type, seen, properties, required); err != nil {
+				return err
+			}
+			continue
+		}
+		key := bsonKey(f)
+		schema, err := typeSchema(f.Type, seen)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		properties[key] = schema
+		if f.Type.Kind() != reflect.Pointer && !strings.Contains(flags, "omitempty") {
+			*required = append(*required, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files")
+
+type SchemaAudit struct {
+	CreatedBy string    `bson:"created_by"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type schemaLocation struct {
+	Site  string    `bson:"site"`
+	Floor *int32    `bson:"floor"`
+	Coord []float64 `bson:"coord,omitempty"`
+}
+
+type schemaCamera struct {
+	ID          primitive.ObjectID   `bson:"_id"`
+	Name        string               `bson:"name"`
+	Online      bool                 `bson:"online"`
+	Zoom        int                  `bson:"zoom"`
+	Fps         int32                `bson:"fps"`
+	Price       primitive.Decimal128 `bson:"price,omitempty"`
+	Key         UUID                 `bson:"key"`
+	Thumbnail   []byte               `bson:"thumbnail,omitempty"`
+	Tags        []string             `bson:"tags"`
+	Location    schemaLocation       `bson:"location"`
+	Backup      *schemaLocation      `bson:"backup"`
+	Labels      map[string]int64     `bson:"labels,omitempty"`
+	Extra       any                  `bson:"extra,omitempty"`
+	Settings    bson.M               `bson:"settings,omitempty"`
+	Ratio       float64              `bson:"ratio"`
+	LastSeen    *time.Time           `bson:"last_seen"`
+	Internal    string               `bson:"-"`
+	SchemaAudit `bson:",inline"`
+	Firmware    string
+	secret      string
+}
+
+type schemaTenant struct {
+	ID      ID               `bson:"_id"`
+	Name    string           `bson:"name"`
+	Cameras []schemaLocation `bson:"cameras"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema func() (map[string]any, error)
+	}{
+		{"camera", SchemaFor[schemaCamera]},
+		{"tenant", SchemaFor[*schemaTenant]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := tt.schema()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				t.Fatalf("failed to encode the schema: %v", err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", "schema", tt.name+".json")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatalf("failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", golden, err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("schema differs from %s (run with -update to accept):\n%s", golden, got)
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		type node struct {
+			Children []node `bson:"children"`
+		}
+		type withChan struct {
+			Events chan int `bson:"events"`
+		}
+		type withIntKeys struct {
+			Counts map[int]string `bson:"counts"`
+		}
+		for name, schema := range map[string]func() (map[string]any, error){
+			"NotAStruct": SchemaFor[string],
+			"Recursive":  SchemaFor[node],
+			"Chan":       SchemaFor[withChan],
+			"IntKeys":    SchemaFor[withIntKeys],
+		} {
+			if _, err := schema(); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestApplySchema(t *testing.T) {
+	ctx := context.Background()
+	schema, err := SchemaFor[schemaTenant]()
+	if err != nil {
+		t.Fatalf("failed to generate the schema: %v", err)
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			schema  map[string]any
+			level   string
+			action  string
+			wantErr string
+		}{
+			{"Defaults", schema, "", "", ""},
+			{"Moderate", schema, ValidationModerate, ValidationWarn, ""},
+			{"UnknownLevel", schema, "lenient", "", "validation level"},
+			{"UnknownAction", schema, "", "reject", "validation action"},
+			{"Empty", nil, "", "", "schema is empty"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := ApplySchema(ctx, NewFakeDatabase(), "vault", "tenants", tt.schema, tt.level, tt.action)
+				if tt.wantErr == "" && err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+			})
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if _, err := GetSchema(ctx, fake, "vault", "tenants"); !errors.Is(err, ErrCollectionNotFound) {
+			t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+		}
+		fake.InsertOne(ctx, "vault", "tenants", bson.M{"name": "acme"})
+		if got, err := GetSchema(ctx, fake, "vault", "tenants"); err != nil || got != nil {
+			t.Fatalf("expected no validator, got %v, %v", got, err)
+		}
+
+		// Through middleware, which passes the call to the fake
+		client := Wrap(fake, WithTimestamps(TimestampConfig{}))
+		if err := ApplySchema(ctx, client, "vault", "tenants", schema, ValidationModerate, ""); err != nil {
+			t.Fatalf("failed to apply: %v", err)
+		}
+		got, err := GetSchema(ctx, client, "vault", "tenants")
+		if err != nil || got.Level != ValidationModerate || got.Action != ValidationError ||
+			!valuesEqual(got.Schema, normalizeDocument(schema)) {
+			t.Errorf("expected the applied schema, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if err := ApplySchema(ctx, NewMockDatabase(), "vault", "tenants", schema, "", ""); err == nil {
+			t.Error("expected an error for a client without schema support")
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SearchOperator is an Atlas Search operator such as text or range, built by
+// Text, Phrase, Autocomplete, Equals or Range
+type SearchOperator struct {
+	name string
+	spec bson.D
+}
+
+// Text matches documents where any of paths contains the terms of query
+func Text(query string, paths ...string) SearchOperator {
+	return SearchOperator{name: "text", spec: bson.D{{Key: "query", Value: query}, {Key: "path", Value: searchPath(paths)}}}
+}
+
+// Phrase matches documents where any of paths contains query as an ordered
+// sequence of terms
+func Phrase(query string, paths ...string) SearchOperator {
+	return SearchOperator{name: "phrase", spec: bson.D{{Key: "query", Value: query}, {Key: "path", Value: searchPath(paths)}}}
+}
+
+// Autocomplete matches documents where path, indexed as autocomplete, has a
+// term starting with query
+func Autocomplete(query string, path string) SearchOperator {
+	return SearchOperator{name: "autocomplete", spec: bson.D{{Key: "query", Value: query}, {Key: "path", Value: path}}}
+}
+
+// Equals matches documents where path equals value, which must be a boolean,
+// ObjectID, number, date, string or UUID
+func Equals(path string, value any) SearchOperator {
+	return SearchOperator{name: "equals", spec: bson.D{{Key: "path", Value: path}, {Key: "value", Value: value}}}
+}
+
+// Range matches documents where the number or date in path lies within the
+// bounds set with Gt, Gte, Lt and Lte
+func Range(path string) SearchOperator {
+	return SearchOperator{name: "range", spec: bson.D{{Key: "path", Value: path}}}
+}
+
+// Gt sets the exclusive lower bound of a Range
+func (o SearchOperator) Gt(value any) SearchOperator {
+	return o.with("gt", value)
+}
+
+// Gte sets the inclusive lower bound of a Range
+func (o SearchOperator) Gte(value any) SearchOperator {
+	return o.with("gte", value)
+}
+
+// Lt sets the exclusive upper bound of a Range
+func (o SearchOperator) Lt(value any) SearchOperator {
+	return o.with("lt", value)
+}
+
+// Lte sets the inclusive upper bound of a Range
+func (o SearchOperator) Lte(value any) SearchOperator {
+	return o.with("lte", value)
+}
+
+// Fuzzy matches terms within maxEdits single-character edits of the query,
+// for Text and Autocomplete
+func (o SearchOperator) Fuzzy(maxEdits int) SearchOperator {
+	return o.with("fuzzy", bson.D{{Key: "maxEdits", Value: maxEdits}})
+}
+
+// Slop allows up to slop other terms between the terms of a Phrase
+func (o SearchOperator) Slop(slop int) SearchOperator {
+	return o.with("slop", slop)
+}
+
+// Boost multiplies the score of documents matching the operator
+func (o SearchOperator) Boost(factor float64) SearchOperator {
+	return o.with("score", bson.D{{Key: "boost", Value: bson.D{{Key: "value", Value: factor}}}})
+}
+
+// with returns a copy of the operator with key set to value
+func (o SearchOperator) with(key string, value any) SearchOperator {
+	spec := make(bson.D, 0, len(o.spec)+1)
+	for _, e := range o.spec {
+		if e.Key != key {
+			spec = append(spec, e)
+		}
+	}
+	o.spec = append(spec, bson.E{Key: key, Value: value})
+	return o
+}
+
+func (o SearchOperator) document() bson.D {
+	return bson.D{{Key: o.name, Value: o.spec}}
+}
+
+func searchPath(paths []string) any {
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	out := make(bson.A, len(paths))
+	for i, path := range paths {
+		out[i] = path
+	}
+	return out
+}
+
+// Compound clauses in the order Atlas documents them
+var compoundClauses = []string{"must", "mustNot", "should", "filter"}
+
+// SearchBuilder provides a fluent interface for building an Atlas Search
+// $search stage. It marshals to the stage document, so it can be put in a
+// pipeline as is, and the mock records it as passed.
+//
+//	stage := database.Search("events").Compound().
+//		Must(database.Text("person", "description")).
+//		Filter(database.Equals("tenant_id", id))
+//	results, err := db.Client.Aggregate(ctx, "vault", "events", bson.A{stage, bson.M{"$limit": 20}})
+type SearchBuilder struct {
+	index              string
+	operator           *SearchOperator
+	compound           bool
+	clauses            map[string][]SearchOperator
+	minimumShouldMatch *int
+}
+
+// Search creates a $search stage builder on the named Atlas Search index;
+// an empty name uses the index named "default"
+func Search(index string) *SearchBuilder {
+	return &SearchBuilder{index: index, clauses: map[string][]SearchOperator{}}
+}
+
+// Operator searches with a single operator
+func (s *SearchBuilder) Operator(op SearchOperator) *SearchBuilder {
+	s.operator = &op
+	return s
+}
+
+// Compound combines the clauses added with Must, MustNot, Should and Filter
+func (s *SearchBuilder) Compound() *SearchBuilder {
+	s.compound = true
+	return s
+}
+
+// Must requires documents to match every op, which contribute to the score
+func (s *SearchBuilder) Must(ops ...SearchOperator) *SearchBuilder {
+	return s.clause("must", ops)
+}
+
+// MustNot excludes documents matching any op
+func (s *SearchBuilder) MustNot(ops ...SearchOperator) *SearchBuilder {
+	return s.clause("mustNot", ops)
+}
+
+// Should scores documents higher for each op they match
+func (s *SearchBuilder) Should(ops ...SearchOperator) *SearchBuilder {
+	return s.clause("should", ops)
+}
+
+// Filter requires documents to match every op without affecting the score
+func (s *SearchBuilder) Filter(ops ...SearchOperator) *SearchBuilder {
+	return s.clause("filter", ops)
+}
+
+// MinimumShouldMatch requires documents to match at least n Should clauses
+func (s *SearchBuilder) MinimumShouldMatch(n int) *SearchBuilder {
+	s.compound = true
+	s.minimumShouldMatch = &n
+	return s
+}
+
+func (s *SearchBuilder) clause(name string, ops []SearchOperator) *SearchBuilder {
+	s.compound = true
+	s.clauses[name] = append(s.clauses[name], ops...)
+	return s
+}
+
+// Stage returns the $search stage document
+func (s *SearchBuilder) Stage() (bson.D, error) {
+	spec, err := s.spec()
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "$search", Value: spec}}, nil
+}
+
+// MarshalBSON encodes the stage document
+func (s *SearchBuilder) MarshalBSON() ([]byte, error) {
+	stage, err := s.Stage()
+	if err != nil {
+		return nil, err
+	}
+	return bson.Marshal(stage)
+}
+
+// spec returns the index followed by the operator
+func (s *SearchBuilder) spec() (bson.D, error) {
+	operator, err := s.operatorDocument()
+	if err != nil {
+		return nil, err
+	}
+	var spec bson.D
+	if s.index != "" {
+		spec = append(spec, bson.E{Key: "index", Value: s.index})
+	}
+	return append(spec, operator...), nil
+}
+
+func (s *SearchBuilder) operatorDocument() (bson.D, error) {
+	switch {
+	case s.operator != nil && s.compound:
+		return nil, errors.New("search cannot have both an operator and compound clauses")
+	case s.operator != nil:
+		return s.operator.document(), nil
+	case !s.compound:
+		return nil, errors.New("search needs an operator or compound clauses")
+	}
+
+	var compound bson.D
+	for _, name := range compoundClauses {
+		ops := s.clauses[name]
+		if len(ops) == 0 {
+			continue
+		}
+		docs := make(bson.A, len(ops))
+		for i, op := range ops {
+			docs[i] = op.document()
+		}
+		compound = append(compound, bson.E{Key: name, Value: docs})
+	}
+	if len(compound) == 0 {
+		return nil, errors.New("compound search needs at least one clause")
+	}
+	if s.minimumShouldMatch != nil {
+		if *s.minimumShouldMatch > len(s.clauses["should"]) {
+			return nil, fmt.Errorf("minimumShouldMatch %d exceeds the %d should clauses", *s.minimumShouldMatch, len(s.clauses["should"]))
+		}
+		compound = append(compound, bson.E{Key: "minimumShouldMatch", Value: *s.minimumShouldMatch})
+	}
+	return bson.D{{Key: "compound", Value: compound}}, nil
+}
+
+// SearchFacet is a facet of a SearchMeta stage, built by StringFacet,
+// NumberFacet or DateFacet
+type SearchFacet struct {
+	name string
+	spec bson.D
+}
+
+// StringFacet counts documents per value of the string field path, for the
+// numBuckets most frequent values
+func StringFacet(name string, path string, numBuckets int) SearchFacet {
+	return SearchFacet{name: name, spec: bson.D{
+		{Key: "type", Value: "string"},
+		{Key: "path", Value: path},
+		{Key: "numBuckets", Value: numBuckets},
+	}}
+}
+
+// NumberFacet counts documents per range of the number field path, between
+// consecutive boundaries
+func NumberFacet(name string, path string, boundaries ...any) SearchFacet {
+	return SearchFacet{name: name, spec: bson.D{
+		{Key: "type", Value: "number"},
+		{Key: "path", Value: path},
+		{Key: "boundaries", Value: bson.A(boundaries)},
+	}}
+}
+
+// DateFacet counts documents per range of the date field path, between
+// consecutive boundaries
+func DateFacet(name string, path string, boundaries ...time.Time) SearchFacet {
+	values := make(bson.A, len(boundaries))
+	for i, b := range boundaries {
+		values[i] = b
+	}
+	return SearchFacet{name: name, spec: bson.D{
+		{Key: "type", Value: "date"},
+		{Key: "path", Value: path},
+		{Key: "boundaries", Value: values},
+	}}
+}
+
+// SearchMeta returns a $searchMeta stage counting the documents search
+// matches per bucket of each facet. The stage yields one document holding
+// count.lowerBound and, per facet name, facet.<name>.buckets with _id and
+// count.
+func SearchMeta(search *SearchBuilder, facets ...SearchFacet) (bson.D, error) {
+	if len(facets) == 0 {
+		return nil, errors.New("search meta needs at least one facet")
+	}
+	operator, err := search.operatorDocument()
+	if err != nil {
+		return nil, err
+	}
+	specs := make(bson.D, 0, len(facets))
+	for _, f := range facets {
+		for _, e := range specs {
+			if e.Key == f.name {
+				return nil, fmt.Errorf("duplicate facet %q", f.name)
+			}
+		}
+		specs = append(specs, bson.E{Key: f.name, Value: f.spec})
+	}
+
+	var spec bson.D
+	if search.index != "" {
+		spec = append(spec, bson.E{Key: "index", Value: search.index})
+	}
+	spec = append(spec, bson.E{Key: "facet", Value: bson.D{
+		{Key: "operator", Value: operator},
+		{Key: "facets", Value: specs},
+	}})
+	return bson.D{{Key: "$searchMeta", Value: spec}}, nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// extJSON renders v as relaxed Extended JSON, the notation of the Atlas
+// Search documentation
+func extJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		t.Fatalf("failed to marshal %v: %v", v, err)
+	}
+	return string(data)
+}
+
+func TestSearchGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		search *SearchBuilder
+		golden string
+	}{
+		{
+			name:   "Text",
+			search: Search("events").Operator(Text("person", "description")),
+			golden: `{"$search":{"index":"events","text":{"query":"person","path":"description"}}}`,
+		},
+		{
+			name:   "TextSeveralPathsFuzzy",
+			search: Search("").Operator(Text("persn", "title", "description").Fuzzy(1)),
+			golden: `{"$search":{"text":{"query":"persn","path":["title","description"],"fuzzy":{"maxEdits":1}}}}`,
+		},
+		{
+			name:   "PhraseWithSlop",
+			search: Search("events").Operator(Phrase("front door", "description").Slop(2)),
+			golden: `{"$search":{"index":"events","phrase":{"query":"front door","path":"description","slop":2}}}`,
+		},
+		{
+			name:   "Autocomplete",
+			search: Search("names").Operator(Autocomplete("gar", "title")),
+			golden: `{"$search":{"index":"names","autocomplete":{"query":"gar","path":"title"}}}`,
+		},
+		{
+			name:   "Range",
+			search: Search("events").Operator(Range("duration").Gte(5).Lt(60)),
+			golden: `{"$search":{"index":"events","range":{"path":"duration","gte":5,"lt":60}}}`,
+		},
+		{
+			name:   "RangeBoundReplaced",
+			search: Search("events").Operator(Range("duration").Gte(5).Gte(10)),
+			golden: `{"$search":{"index":"events","range":{"path":"duration","gte":10}}}`,
+		},
+		{
+			name: "Compound",
+			search: Search("events").Compound().
+				Must(Text("query", "description")).
+				Filter(Equals("tenant_id", "t1")),
+			golden: `{"$search":{"index":"events","compound":{"must":[{"text":{"query":"query","path":"description"}}],"filter":[{"equals":{"path":"tenant_id","value":"t1"}}]}}}`,
+		},
+		{
+			name: "CompoundClauseOrder",
+			search: Search("events").Compound().
+				Should(Text("car", "title").Boost(2), Text("person", "title")).
+				Filter(Range("score").Gt(0.5)).
+				MustNot(Equals("archived", true)).
+				MinimumShouldMatch(1),
+			golden: `{"$search":{"index":"events","compound":{"mustNot":[{"equals":{"path":"archived","value":true}}],` +
+				`"should":[{"text":{"query":"car","path":"title","score":{"boost":{"value":2.0}}}},{"text":{"query":"person","path":"title"}}],` +
+				`"filter":[{"range":{"path":"score","gt":0.5}}],"minimumShouldMatch":1}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := tt.search.Stage()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := extJSON(t, stage); got != tt.golden {
+				t.Errorf("expected\n%s\ngot\n%s", tt.golden, got)
+			}
+			if got := extJSON(t, tt.search); got != tt.golden {
+				t.Errorf("expected MarshalBSON to encode the stage, got %s", got)
+			}
+		})
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  *SearchBuilder
+		wantErr string
+	}{
+		{"Empty", Search("events"), "needs an operator"},
+		{"CompoundWithoutClauses", Search("events").Compound(), "at least one clause"},
+		{"OperatorAndCompound", Search("events").Operator(Text("a", "b")).Must(Text("c", "d")), "both"},
+		{"MinimumShouldMatchTooHigh", Search("events").Should(Text("a", "b")).MinimumShouldMatch(2), "exceeds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.search.Stage(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSearchMetaGolden(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stage, err := SearchMeta(Search("events").Operator(Range("duration").Gte(0)),
+		StringFacet("labels", "label", 10),
+		NumberFacet("durations", "duration", 0, 60, 3600),
+		DateFacet("months", "created_at", from, from.AddDate(0, 1, 0)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	golden := `{"$searchMeta":{"index":"events","facet":{"operator":{"range":{"path":"duration","gte":0}},"facets":{` +
+		`"labels":{"type":"string","path":"label","numBuckets":10},` +
+		`"durations":{"type":"number","path":"duration","boundaries":[0,60,3600]},` +
+		`"months":{"type":"date","path":"created_at","boundaries":[{"$date":"2024-01-01T00:00:00Z"},{"$date":"2024-02-01T00:00:00Z"}]}}}}}`
+	if got := extJSON(t, stage); got != golden {
+		t.Errorf("expected\n%s\ngot\n%s", golden, got)
+	}
+
+	if _, err := SearchMeta(Search("events").Operator(Text("a", "b"))); err == nil {
+		t.Error("expected an error without facets")
+	}
+	if _, err := SearchMeta(Search("events").Operator(Text("a", "b")), StringFacet("x", "a", 1), StringFacet("x", "b", 1)); err == nil {
+		t.Error("expected an error for duplicate facet names")
+	}
+}
+
+func TestSearchInPipeline(t *testing.T) {
+	ctx := context.Background()
+	search := Search("events").Compound().Must(Text("person", "description")).Filter(Equals("tenant_id", "t1"))
+	pipeline := bson.A{search, bson.M{"$limit": 20}}
+
+	t.Run("MockRecordsStage", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.Aggregate(ctx, "vault", "events", pipeline)
+
+		call, _ := mock.LastAggregateCall()
+		if call.Pipeline.(bson.A)[0] != search {
+			t.Error("expected the builder recorded as passed")
+		}
+		spec, ok := call.Stage("$search")
+		want := map[string]any{"index": "events", "compound": map[string]any{
+			"must":   []any{map[string]any{"text": map[string]any{"query": "person", "path": "description"}}},
+			"filter": []any{map[string]any{"equals": map[string]any{"path": "tenant_id", "value": "t1"}}},
+		}}
+		if !ok || !valuesEqual(spec, want) {
+			t.Errorf("expected the $search stage %v, got %v", want, spec)
+		}
+		if !PipelineHasStage("$search")(pipeline) {
+			t.Error("expected PipelineHasStage to see the $search stage")
+		}
+	})
+
+	t.Run("FakeRejectsStage", func(t *testing.T) {
+		_, err := NewFakeDatabase().Aggregate(ctx, "vault", "events", pipeline)
+		if err == nil || !strings.Contains(err.Error(), "$search not supported") {
+			t.Errorf("expected the fake to reject $search, got %v", err)
+		}
+	})
+}
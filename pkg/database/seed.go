@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Seed is a document to create unless a document matching Filter exists
+type Seed struct {
+	DB         string
+	Collection string
+	// Filter identifies the document; nil matches on its _id, or on every
+	// field when it has none
+	Filter any
+	// Document is a struct, map or bson.D
+	Document any
+	// Source names where the seed came from, such as a fixture file, in errors
+	Source string
+}
+
+// SeedResult counts the seeds Seed inserted and those already present
+type SeedResult struct {
+	Inserted int64
+	Present  int64
+}
+
+// Seed creates the seeds that do not exist yet, in order. Each seed is an
+// upsert that only sets fields when inserting, so running it again, or after
+// the documents were edited, changes nothing. It stops at the first error,
+// which names the seed's source or index, and returns the counts so far.
+//
+//	res, err := db.Seed(ctx, []database.Seed{
+//		{DB: "vault", Collection: "roles", Filter: bson.M{"name": "admin"}, Document: bson.M{"name": "admin", "level": 10}},
+//	})
+func (d *Database) Seed(ctx context.Context, seeds []Seed) (SeedResult, error) {
+	var result SeedResult
+	for i, seed := range seeds {
+		source := seed.Source
+		if source == "" {
+			source = fmt.Sprintf("seed %d", i)
+		}
+		inserted, err := d.seed(ctx, seed)
+		if err != nil {
+			return result, fmt.Errorf("seed: %s: %w", source, err)
+		}
+		if inserted {
+			result.Inserted++
+		} else {
+			result.Present++
+		}
+	}
+	return result, nil
+}
+
+// seed upserts one seed and reports whether it was inserted
+func (d *Database) seed(ctx context.Context, seed Seed) (bool, error) {
+	var doc bson.D
+	if existing, ok := seed.Document.(bson.D); ok {
+		doc = existing
+	} else if err := d.codec().decode(seed.Document, &doc); err != nil {
+		return false, fmt.Errorf("encode document: %w", err)
+	}
+	if len(doc) == 0 {
+		return false, fmt.Errorf("document is empty")
+	}
+
+	filter := seed.Filter
+	if filter == nil {
+		filter = doc
+		for _, e := range doc {
+			if e.Key == "_id" {
+				filter = bson.D{e}
+				break
+			}
+		}
+	}
+
+	update := bson.D{{Key: "$setOnInsert", Value: doc}}
+	res, err := d.Client.UpdateOne(ctx, seed.DB, seed.Collection, filter, update, moptions.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+// SeedFromDir seeds every .json, .yaml and .yml file in dir, in the fixture
+// format of FakeDatabase.LoadFixtures, so tests and environments can share
+// files: testdb.users.json seeds collection users of database testdb.
+// Documents are matched on their _id, or on every field when they have none.
+// Errors name the file and the index of the document within it.
+func (d *Database) SeedFromDir(ctx context.Context, dir string) (SeedResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return SeedResult{}, fmt.Errorf("seed: %w", err)
+	}
+	var seeds []Seed
+	for _, entry := range entries {
+		if entry.IsDir() || !isFixtureFile(entry.Name()) {
+			continue
+		}
+		db, collection, err := fixtureNamespace(entry.Name())
+		if err != nil {
+			return SeedResult{}, fmt.Errorf("seed: %w", err)
+		}
+		path := filepath.Join(dir, entry.Name())
+		docs, err := readFixtureDocuments(path)
+		if err != nil {
+			return SeedResult{}, fmt.Errorf("seed: %w", err)
+		}
+		for i, doc := range docs {
+			seeds = append(seeds, Seed{
+				DB:         db,
+				Collection: collection,
+				Document:   doc,
+				Source:     fmt.Sprintf("fixture %s: document %d", path, i),
+			})
+		}
+	}
+	return d.Seed(ctx, seeds)
+}
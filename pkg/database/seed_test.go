@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Idempotent", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		db := &Database{Client: fake}
+		seeds := []Seed{
+			{DB: "vault", Collection: "roles", Filter: bson.M{"name": "admin"}, Document: bson.M{"name": "admin", "level": 10}},
+			{DB: "vault", Collection: "roles", Filter: bson.M{"name": "viewer"}, Document: struct {
+				Name  string `bson:"name"`
+				Level int    `bson:"level"`
+			}{"viewer", 1}},
+			{DB: "vault", Collection: "settings", Document: bson.D{{Key: "_id", Value: "retention"}, {Key: "days", Value: 30}}},
+		}
+
+		res, err := db.Seed(ctx, seeds)
+		if err != nil || res != (SeedResult{Inserted: 3}) {
+			t.Fatalf("expected 3 inserted, got %+v, %v", res, err)
+		}
+
+		// Edits made after seeding are kept
+		fake.UpdateOne(ctx, "vault", "roles", bson.M{"name": "admin"}, bson.M{"$set": bson.M{"level": 99}})
+		res, err = db.Seed(ctx, seeds)
+		if err != nil || res != (SeedResult{Present: 3}) {
+			t.Fatalf("expected 3 present, got %+v, %v", res, err)
+		}
+		if n, _ := fake.Count(ctx, "vault", "roles", bson.M{}); n != 2 {
+			t.Errorf("expected 2 roles, got %d", n)
+		}
+		admin, _ := fake.FindOne(ctx, "vault", "roles", bson.M{"name": "admin"})
+		if level, _ := toFloat(admin.(bson.M)["level"]); level != 99 {
+			t.Errorf("expected the edited level to be kept, got %v", admin)
+		}
+	})
+
+	t.Run("ErrorNamesSeed", func(t *testing.T) {
+		db := &Database{Client: NewFakeDatabase()}
+		seeds := []Seed{
+			{DB: "vault", Collection: "roles", Document: bson.M{"name": "admin"}},
+			{DB: "vault", Collection: "roles", Document: bson.M{}},
+		}
+		res, err := db.Seed(ctx, seeds)
+		if err == nil || !strings.Contains(err.Error(), "seed 1") {
+			t.Fatalf("expected an error naming seed 1, got %v", err)
+		}
+		if res.Inserted != 1 {
+			t.Errorf("expected the first seed to be counted, got %+v", res)
+		}
+	})
+}
+
+func TestSeedFromDir(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SharesFixtureFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFixture(t, dir, "vault.roles.json", `[
+			{"_id": {"$oid": "65f1a2b3c4d5e6f708192a3b"}, "name": "admin"},
+			{"name": "viewer", "level": 1}
+		]`)
+		writeFixture(t, dir, "vault.settings.yaml", "- _id: retention\n  days: 30\n")
+
+		fake := NewFakeDatabase()
+		db := &Database{Client: fake}
+		res, err := db.SeedFromDir(ctx, dir)
+		if err != nil || res != (SeedResult{Inserted: 3}) {
+			t.Fatalf("expected 3 inserted, got %+v, %v", res, err)
+		}
+		res, err = db.SeedFromDir(ctx, dir)
+		if err != nil || res != (SeedResult{Present: 3}) {
+			t.Fatalf("expected 3 present on the second run, got %+v, %v", res, err)
+		}
+
+		// The same files load into a fake directly
+		loaded := NewFakeDatabase()
+		if err := loaded.LoadFixtures(dir); err != nil {
+			t.Fatalf("failed to load fixtures: %v", err)
+		}
+		if got, want := len(fake.Documents("vault", "roles")), len(loaded.Documents("vault", "roles")); got != want {
+			t.Errorf("expected %d seeded roles, got %d", want, got)
+		}
+	})
+
+	t.Run("ErrorNamesFileAndDocument", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeFixture(t, dir, "vault.roles.json", `[{"name": "admin"}, {"_id": {"$oid": "nothex"}}]`)
+
+		_, err := (&Database{Client: NewFakeDatabase()}).SeedFromDir(ctx, dir)
+		if err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "document 1") {
+			t.Errorf("expected file and index in error, got %v", err)
+		}
+	})
+
+	t.Run("WriteErrorNamesFileAndDocument", func(t *testing.T) {
+		dir := t.TempDir()
+		path := writeFixture(t, dir, "vault.roles.json", `[{"name": "admin"}, {"name": "viewer"}]`)
+
+		mock := NewMockDatabase()
+		mock.QueueUpdateOne(&UpdateResult{UpsertedCount: 1}, nil)
+		mock.QueueUpdateOne(nil, ErrDuplicateKey)
+		_, err := (&Database{Client: mock}).SeedFromDir(ctx, dir)
+		if err == nil || !strings.Contains(err.Error(), path+": document 1") {
+			t.Errorf("expected file and index in error, got %v", err)
+		}
+	})
+}
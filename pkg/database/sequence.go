@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CounterCollection is the collection NextSequence keeps counters in, one
+// document per sequence with the sequence name as _id
+const CounterCollection = "_counters"
+
+// counter is a document of CounterCollection
+type counter struct {
+	Seq int64 `bson:"seq"`
+}
+
+// NextSequence returns the next number of the sequence name in db, 1 for a
+// new sequence. Concurrent callers never get the same number. A number
+// whose caller fails before using it is lost, so sequences may have gaps.
+//
+//	n, err := db.NextSequence(ctx, "billing", "invoices")
+//	invoice.Number = fmt.Sprintf("INV-%06d", n)
+func (d *Database) NextSequence(ctx context.Context, db string, name string) (int64, error) {
+	_, last, err := d.NextSequenceBatch(ctx, db, name, 1)
+	return last, err
+}
+
+// NextSequenceBatch reserves the next n numbers of the sequence name in one
+// round trip, for callers that number many documents at once. The range
+// first to last is contiguous and belongs to the caller alone.
+func (d *Database) NextSequenceBatch(ctx context.Context, db string, name string, n int) (first int64, last int64, err error) {
+	if n <= 0 {
+		return 0, 0, fmt.Errorf("sequence %s: batch size must be positive, got %d", name, n)
+	}
+	opts := moptions.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(moptions.After)
+	update := bson.M{"$inc": bson.M{"seq": int64(n)}}
+	doc, err := d.Client.FindOneAndUpdate(ctx, db, CounterCollection, bson.M{"_id": name}, update, opts)
+	if errors.Is(err, ErrDuplicateKey) {
+		// Two upserts created the counter at once; it exists now
+		doc, err = d.Client.FindOneAndUpdate(ctx, db, CounterCollection, bson.M{"_id": name}, update, opts)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("sequence %s: %w", name, err)
+	}
+	var c counter
+	if err := decodeDocument(doc, &c); err != nil {
+		return 0, 0, fmt.Errorf("sequence %s: %w", name, err)
+	}
+	return c.Seq - int64(n) + 1, c.Seq, nil
+}
+
+// PeekSequence returns the last number NextSequence handed out for name,
+// 0 for a sequence that was never used, without advancing it
+func (d *Database) PeekSequence(ctx context.Context, db string, name string) (int64, error) {
+	doc, err := d.Client.FindOne(ctx, db, CounterCollection, bson.M{"_id": name})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sequence %s: %w", name, err)
+	}
+	var c counter
+	if err := decodeDocument(doc, &c); err != nil {
+		return 0, fmt.Errorf("sequence %s: %w", name, err)
+	}
+	return c.Seq, nil
+}
+
+// ResetSequence sets the sequence name so that NextSequence returns value+1
+// next, for admin tooling such as a yearly restart of invoice numbers.
+// Resetting below numbers already handed out makes them repeat.
+func (d *Database) ResetSequence(ctx context.Context, db string, name string, value int64) error {
+	_, err := d.Client.UpdateOne(ctx, db, CounterCollection, bson.M{"_id": name},
+		bson.M{"$set": bson.M{"seq": value}}, moptions.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("sequence %s: %w", name, err)
+	}
+	return nil
+}
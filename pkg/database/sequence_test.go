@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSequence(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Next", func(t *testing.T) {
+		d := &Database{Client: NewFakeDatabase()}
+		if n, err := d.PeekSequence(ctx, "billing", "invoices"); n != 0 || err != nil {
+			t.Errorf("expected an unused sequence at 0, got %d, %v", n, err)
+		}
+		for want := int64(1); want <= 3; want++ {
+			if n, err := d.NextSequence(ctx, "billing", "invoices"); n != want || err != nil {
+				t.Errorf("expected %d, got %d, %v", want, n, err)
+			}
+		}
+		if n, err := d.NextSequence(ctx, "billing", "cases"); n != 1 || err != nil {
+			t.Errorf("expected sequences to be independent, got %d, %v", n, err)
+		}
+		first, last, err := d.NextSequenceBatch(ctx, "billing", "invoices", 10)
+		if first != 4 || last != 13 || err != nil {
+			t.Errorf("expected 4 to 13, got %d to %d, %v", first, last, err)
+		}
+		if n, _ := d.PeekSequence(ctx, "billing", "invoices"); n != 13 {
+			t.Errorf("expected Peek to return the last number, got %d", n)
+		}
+		if n, _ := d.PeekSequence(ctx, "billing", "invoices"); n != 13 {
+			t.Errorf("expected Peek not to advance, got %d", n)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		d := &Database{Client: NewFakeDatabase()}
+		for _, name := range []string{"invoices", "new"} {
+			d.NextSequence(ctx, "billing", "invoices")
+			if err := d.ResetSequence(ctx, "billing", name, 1000); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := d.NextSequence(ctx, "billing", name); n != 1001 || err != nil {
+				t.Errorf("expected %s to continue after 1000, got %d, %v", name, n, err)
+			}
+		}
+	})
+
+	t.Run("InvalidBatch", func(t *testing.T) {
+		if _, _, err := (&Database{Client: NewFakeDatabase()}).NextSequenceBatch(ctx, "billing", "invoices", 0); err == nil {
+			t.Error("expected an empty batch to be rejected")
+		}
+	})
+
+	t.Run("UpsertRace", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueFindOneAndUpdate(nil, fmt.Errorf("%w: E11000", ErrDuplicateKey))
+		mock.QueueFindOneAndUpdate(bson.M{"_id": "invoices", "seq": int64(7)}, nil)
+		if n, err := (&Database{Client: mock}).NextSequence(ctx, "billing", "invoices"); n != 7 || err != nil {
+			t.Errorf("expected the increment to be retried, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		d := &Database{Client: NewFakeDatabase()}
+		const workers, calls = 16, 50
+		var mu sync.Mutex
+		seen := map[int64]bool{}
+		var wg sync.WaitGroup
+		for w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range calls {
+					first, last, err := d.NextSequenceBatch(ctx, "billing", "invoices", 1+w%3)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					for n := first; n <= last; n++ {
+						if seen[n] {
+							t.Errorf("number %d handed out twice", n)
+						}
+						seen[n] = true
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		total, _ := d.PeekSequence(ctx, "billing", "invoices")
+		if int64(len(seen)) != total {
+			t.Errorf("expected 1 to %d without gaps, got %d numbers", total, len(seen))
+		}
+		for n := int64(1); n <= total; n++ {
+			if !seen[n] {
+				t.Errorf("expected %d to be handed out", n)
+			}
+		}
+	})
+}
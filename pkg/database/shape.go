@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FilterShape renders a filter or document with every value replaced by its
+// type, so it can be logged or included in failure messages without leaking data.
+// Keys (including operators such as $in) are kept and sorted.
+func FilterShape(filter any) string {
+	return shapeOf(normalizeDocument(filter))
+}
+
+func shapeOf(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, k+": "+shapeOf(t[k]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case []any:
+		if len(t) == 0 {
+			return "[]"
+		}
+		return fmt.Sprintf("[%d]%s", len(t), shapeOf(t[0]))
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case time.Time, primitive.DateTime:
+		return "date"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.Regex:
+		return "regex"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig describes the bastion host an SSH tunnel to the database goes
+// through. Authenticate with PrivateKeyPEM, AgentSocket or both.
+type SSHConfig struct {
+	Host string
+	// Port defaults to 22
+	Port int
+	User string
+	// PrivateKeyPEM is an unencrypted private key in PEM format
+	PrivateKeyPEM []byte
+	// AgentSocket is the socket of an ssh-agent holding the key, usually
+	// os.Getenv("SSH_AUTH_SOCK")
+	AgentSocket string
+	// KnownHostsCallback verifies the host key of the bastion; without it the
+	// key must be listed in ~/.ssh/known_hosts
+	KnownHostsCallback ssh.HostKeyCallback
+	// InsecureIgnoreHostKey accepts any host key, which lets anyone on the
+	// path impersonate the bastion. Only use it against test servers.
+	InsecureIgnoreHostKey bool
+}
+
+// SetSSHTunnel connects to the hosts through an SSH tunnel to a bastion
+// host. The tunnel is opened on the first connection, shared by all of them,
+// re-established when it drops and closed by Close.
+func (b *MongoOptionsBuilder) SetSSHTunnel(config SSHConfig) *MongoOptionsBuilder {
+	b.options.SSHTunnel = &config
+	return b
+}
+
+// address returns the host and port of the bastion
+func (c SSHConfig) address() string {
+	port := c.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// hostKeyCallback returns the host key verification of the config
+func (c SSHConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	switch {
+	case c.KnownHostsCallback != nil:
+		return c.KnownHostsCallback, nil
+	case c.InsecureIgnoreHostKey:
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: known hosts: %w", err)
+	}
+	callback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: known hosts: %w; set KnownHostsCallback, or InsecureIgnoreHostKey for test servers", err)
+	}
+	return callback, nil
+}
+
+var errSSHTunnelClosed = errors.New("ssh tunnel: closed")
+
+// sshTunnel dials addresses from the bastion host over one shared SSH
+// connection
+type sshTunnel struct {
+	config  SSHConfig
+	dialer  ContextDialer
+	hostKey ssh.HostKeyCallback
+	signer  ssh.Signer
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// newSSHTunnel checks config and returns a tunnel that reaches the bastion
+// with dialer. It connects on the first dial.
+func newSSHTunnel(config SSHConfig, dialer ContextDialer) (*sshTunnel, error) {
+	if config.Host == "" || config.User == "" {
+		return nil, errors.New("ssh tunnel: a host and a user are required")
+	}
+	if len(config.PrivateKeyPEM) == 0 && config.AgentSocket == "" {
+		return nil, errors.New("ssh tunnel: a private key or an agent socket is required")
+	}
+	t := &sshTunnel{config: config, dialer: dialer}
+	if len(config.PrivateKeyPEM) > 0 {
+		signer, err := ssh.ParsePrivateKey(config.PrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("ssh tunnel: private key: %w", err)
+		}
+		t.signer = signer
+	}
+	hostKey, err := config.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	t.hostKey = hostKey
+	return t, nil
+}
+
+// DialContext connects to address from the bastion host. When the SSH
+// connection turns out to have dropped, it is re-established once.
+func (t *sshTunnel) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, address)
+	if err != nil && ctx.Err() == nil && !isAlive(client) {
+		t.drop(client)
+		if client, err = t.connect(ctx); err != nil {
+			return nil, err
+		}
+		conn, err = client.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial %s through ssh tunnel %s: %w", address, t.config.address(), err)
+	}
+	return conn, nil
+}
+
+// connect returns the SSH connection, opening it when there is none
+func (t *sshTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errSSHTunnelClosed
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	address := t.config.address()
+	var auth []ssh.AuthMethod
+	if t.signer != nil {
+		auth = append(auth, ssh.PublicKeys(t.signer))
+	}
+	if t.config.AgentSocket != "" {
+		// The agent signs during the handshake, so it stays connected
+		// until then
+		agentConn, err := net.Dial("unix", t.config.AgentSocket)
+		if err != nil {
+			return nil, fmt.Errorf("ssh tunnel: agent: %w", err)
+		}
+		defer agentConn.Close()
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	conn, err := t.dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel %s: %w", address, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, &ssh.ClientConfig{
+		User:            t.config.User,
+		Auth:            auth,
+		HostKeyCallback: t.hostKey,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh tunnel %s: %w", address, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(c, chans, reqs)
+	t.client = client
+	go func() {
+		client.Wait()
+		t.drop(client)
+	}()
+	return client, nil
+}
+
+// isAlive reports whether the server still answers on the SSH connection
+func isAlive(client *ssh.Client) bool {
+	_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// drop forgets client, so the next dial opens a new SSH connection
+func (t *sshTunnel) drop(client *ssh.Client) {
+	t.mu.Lock()
+	if t.client == client {
+		t.client = nil
+	}
+	t.mu.Unlock()
+	client.Close()
+}
+
+// Close closes the SSH connection; the tunnel cannot dial afterwards
+func (t *sshTunnel) Close() error {
+	t.mu.Lock()
+	client := t.client
+	t.client = nil
+	t.closed = true
+	t.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	if err := client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("ssh tunnel: %w", err)
+	}
+	return nil
+}
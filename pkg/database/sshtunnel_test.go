@@ -0,0 +1,398 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTestServer is an in-process SSH server that forwards direct-tcpip
+// channels for the user "tunnel" authenticated with one key
+type sshTestServer struct {
+	address string
+	hostKey ssh.PublicKey
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func startSSHServer(t *testing.T, authorized ssh.PublicKey) *sshTestServer {
+	t.Helper()
+	hostSigner := newTestSigner(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if meta.User() == "tunnel" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &sshTestServer{address: ln.Addr().String(), hostKey: hostSigner.PublicKey()}
+	t.Cleanup(func() {
+		ln.Close()
+		s.drop()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *sshTestServer) serve(nConn net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		nConn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is served")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.Prohibited, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// connections returns the number of SSH connections the server accepted
+func (s *sshTestServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// open returns the number of SSH connections still open
+func (s *sshTestServer) open() int {
+	s.mu.Lock()
+	conns := slices.Clone(s.conns)
+	s.mu.Unlock()
+	open := 0
+	for _, conn := range conns {
+		if _, _, err := conn.SendRequest("ping", true, nil); err == nil {
+			open++
+		}
+	}
+	return open
+}
+
+// drop closes every SSH connection, as a bastion restart would
+func (s *sshTestServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create a signer: %v", err)
+	}
+	return signer
+}
+
+// newTestKey returns a private key in PEM format and its signer
+func newTestKey(t *testing.T) ([]byte, ssh.Signer) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("failed to marshal the key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create a signer: %v", err)
+	}
+	return pem.EncodeToMemory(block), signer
+}
+
+func echoThrough(t *testing.T, dialer ContextDialer, address string) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		return err
+	}
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		return err
+	}
+	if string(got) != "hello" {
+		t.Errorf("expected the echo through the tunnel, got %q", got)
+	}
+	return nil
+}
+
+func TestSSHTunnel(t *testing.T) {
+	key, signer := newTestKey(t)
+	server := startSSHServer(t, signer.PublicKey())
+	echo := startEcho(t)
+	host, port, _ := net.SplitHostPort(server.address)
+	bastion := func() SSHConfig {
+		p, _ := strconv.Atoi(port)
+		return SSHConfig{Host: host, Port: p, User: "tunnel", PrivateKeyPEM: key, KnownHostsCallback: ssh.FixedHostKey(server.hostKey)}
+	}
+	tunnelDialer := func(t *testing.T, config SSHConfig) ContextDialer {
+		t.Helper()
+		opts := NewMongoOptions().SetHost("db1.internal").SetUsername("user").SetPassword("pass").SetAuthSource("admin").SetSSHTunnel(config).Build()
+		clientOpts, err := buildClientOptions(opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tunnel, ok := clientOpts.Dialer.(*sshTunnel)
+		if !ok {
+			t.Fatalf("expected the tunnel on the client options, got %T", clientOpts.Dialer)
+		}
+		t.Cleanup(func() { tunnel.Close() })
+		return tunnel
+	}
+
+	t.Run("Reuse", func(t *testing.T) {
+		before := server.connections()
+		dialer := tunnelDialer(t, bastion())
+		for range 3 {
+			if err := echoThrough(t, dialer, echo); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if got := server.connections() - before; got != 1 {
+			t.Errorf("expected one SSH connection for three dials, got %d", got)
+		}
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		before := server.connections()
+		dialer := tunnelDialer(t, bastion())
+		if err := echoThrough(t, dialer, echo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		server.drop()
+		if err := echoThrough(t, dialer, echo); err != nil {
+			t.Fatalf("expected the tunnel to be re-established, got %v", err)
+		}
+		if got := server.connections() - before; got != 2 {
+			t.Errorf("expected a second SSH connection, got %d", got)
+		}
+	})
+
+	t.Run("Agent", func(t *testing.T) {
+		keyring := agent.NewKeyring()
+		_, private, _ := ed25519.GenerateKey(rand.Reader)
+		if err := keyring.Add(agent.AddedKey{PrivateKey: private}); err != nil {
+			t.Fatalf("failed to add the key: %v", err)
+		}
+		agentSigner, _ := ssh.NewSignerFromKey(private)
+		agentServer := startSSHServer(t, agentSigner.PublicKey())
+
+		socket := filepath.Join(t.TempDir(), "agent.sock")
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go agent.ServeAgent(keyring, conn)
+			}
+		}()
+
+		h, p, _ := net.SplitHostPort(agentServer.address)
+		n, _ := strconv.Atoi(p)
+		dialer := tunnelDialer(t, SSHConfig{Host: h, Port: n, User: "tunnel", AgentSocket: socket, KnownHostsCallback: ssh.FixedHostKey(agentServer.hostKey)})
+		if err := echoThrough(t, dialer, echo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("HostKeyMismatch", func(t *testing.T) {
+		config := bastion()
+		config.KnownHostsCallback = ssh.FixedHostKey(newTestSigner(t).PublicKey())
+		err := echoThrough(t, tunnelDialer(t, config), echo)
+		if err == nil || !strings.Contains(err.Error(), "ssh tunnel "+server.address) {
+			t.Errorf("expected a host key error, got %v", err)
+		}
+	})
+
+	t.Run("KnownHostsFile", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		config := bastion()
+		config.KnownHostsCallback = nil
+
+		opts := NewMongoOptions().SetHost("db1.internal").SetUsername("user").SetPassword("pass").SetAuthSource("admin").SetSSHTunnel(config).Build()
+		if _, err := buildClientOptions(opts); err == nil || !strings.Contains(err.Error(), "InsecureIgnoreHostKey") {
+			t.Fatalf("expected verification to require known hosts, got %v", err)
+		}
+
+		os.MkdirAll(filepath.Join(home, ".ssh"), 0o700)
+		line := knownhosts.Line([]string{knownhosts.Normalize(server.address)}, server.hostKey)
+		if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0o600); err != nil {
+			t.Fatalf("failed to write known hosts: %v", err)
+		}
+		if err := echoThrough(t, tunnelDialer(t, config), echo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("InsecureIgnoreHostKey", func(t *testing.T) {
+		t.Setenv("HOME", t.TempDir())
+		config := bastion()
+		config.KnownHostsCallback = nil
+		config.InsecureIgnoreHostKey = true
+		if err := echoThrough(t, tunnelDialer(t, config), echo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("UnreachableTarget", func(t *testing.T) {
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		closed := ln.Addr().String()
+		ln.Close()
+		err := echoThrough(t, tunnelDialer(t, bastion()), closed)
+		if err == nil || !strings.Contains(err.Error(), "dial "+closed+" through ssh tunnel "+server.address) {
+			t.Errorf("expected an error naming the target and the tunnel, got %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			config  func(c *SSHConfig)
+			wantErr string
+		}{
+			{"NoHost", func(c *SSHConfig) { c.Host = "" }, "host and a user"},
+			{"NoUser", func(c *SSHConfig) { c.User = "" }, "host and a user"},
+			{"NoKey", func(c *SSHConfig) { c.PrivateKeyPEM = nil }, "private key or an agent socket"},
+			{"BadKey", func(c *SSHConfig) { c.PrivateKeyPEM = []byte("not a key") }, "private key"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				config := bastion()
+				tt.config(&config)
+				opts := NewMongoOptions().SetHost("db1.internal").SetUsername("user").SetPassword("pass").SetAuthSource("admin").SetSSHTunnel(config).Build()
+				if _, err := buildClientOptions(opts); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+			})
+		}
+	})
+
+	t.Run("CloseTearsDownTunnel", func(t *testing.T) {
+		opts := NewMongoOptions().
+			SetHost(echo).
+			SetUsername("user").
+			SetPassword("pass").
+			SetAuthSource("admin").
+			SetSSHTunnel(bastion()).
+			SetTimeout(1000).
+			Build()
+		client, err := NewMongoClient(opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tunnel := client.(*MongoClient).tunnel
+		if tunnel == nil {
+			t.Fatal("expected the client to own the tunnel")
+		}
+		if err := echoThrough(t, tunnel, echo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		before := server.open()
+		if err := client.Close(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := client.Close(context.Background()); err != nil {
+			t.Errorf("expected closing twice to succeed, got %v", err)
+		}
+		if _, err := tunnel.DialContext(context.Background(), "tcp", echo); !errors.Is(err, errSSHTunnelClosed) {
+			t.Errorf("expected the tunnel to be closed, got %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for server.open() >= before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if server.open() >= before {
+			t.Error("expected the SSH connection to be closed")
+		}
+	})
+}
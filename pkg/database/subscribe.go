@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultSubscribeBuffer is the channel buffer of Subscribe
+const defaultSubscribeBuffer = 256
+
+// Delays between attempts to reopen a failed change stream
+const (
+	subscribeMinBackoff = 100 * time.Millisecond
+	subscribeMaxBackoff = 30 * time.Second
+)
+
+// Server error codes of change streams that cannot be resumed
+const (
+	codeChangeStreamFatal       = 280
+	codeChangeStreamHistoryLost = 286
+)
+
+// SubscribeOption configures Subscribe and SubscribeWebhook
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	buffer       int
+	drop         bool
+	store        ResumeTokenStore
+	key          string
+	fullDocument bool
+	onError      func(error)
+	onDrop       func(ChangeEvent)
+}
+
+// SubscribeBuffer sets the number of events the channel of Subscribe holds
+// for a slow consumer, 256 by default
+func SubscribeBuffer(n int) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.buffer = n
+	}
+}
+
+// SubscribeDropWhenFull discards the events that arrive while the channel
+// of Subscribe is full, reporting each to onDrop when it is not nil, instead
+// of blocking until the consumer catches up
+func SubscribeDropWhenFull(onDrop func(ChangeEvent)) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.drop = true
+		c.onDrop = onDrop
+	}
+}
+
+// SubscribeResumeTokens saves the resume token of every handled event in
+// store under key, and resumes after the saved token when the subscription
+// starts, so a restarted process continues where it stopped. An empty key
+// uses "db.collection".
+func SubscribeResumeTokens(store ResumeTokenStore, key string) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.store = store
+		c.key = key
+	}
+}
+
+// SubscribeFullDocument adds the current version of the document to update
+// events
+func SubscribeFullDocument() SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.fullDocument = true
+	}
+}
+
+// SubscribeOnError reports the errors a subscription recovers from, such as
+// a change stream reopened after a failover or a resume token that could
+// not be saved, and the one that ends it
+func SubscribeOnError(fn func(error)) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.onError = fn
+	}
+}
+
+// Subscribe delivers the change events of db.collection matching filter, a
+// query on the events such as bson.M{"operationType": "insert"}, on a
+// buffered channel. The change stream is reopened after the last event when
+// it fails, with growing delays, until ctx ends or the returned function is
+// called; both close the channel. A stream that cannot be resumed, because
+// its token fell off the oplog, ends the subscription too.
+//
+// When the buffer is full, Subscribe waits for the consumer and the stream
+// stops reading meanwhile; the server keeps the events for as long as its
+// oplog does. SubscribeDropWhenFull discards them instead.
+//
+//	events, stop, err := db.Subscribe(ctx, "vault", "events", bson.M{"operationType": "insert"},
+//		database.SubscribeResumeTokens(store, "events-indexer"))
+//	defer stop()
+//	for event := range events {
+//		...
+//	}
+func (d *Database) Subscribe(ctx context.Context, db string, collection string, filter any, opts ...SubscribeOption) (<-chan ChangeEvent, func(), error) {
+	cfg := newSubscribeConfig(opts)
+	events := make(chan ChangeEvent, cfg.buffer)
+	deliver := func(ctx context.Context, event ChangeEvent) error {
+		if cfg.drop {
+			select {
+			case events <- event:
+			default:
+				if cfg.onDrop != nil {
+					cfg.onDrop(event)
+				}
+			}
+			return nil
+		}
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	stop, err := d.watchChanges(ctx, db, collection, filter, cfg, deliver, func() { close(events) })
+	if err != nil {
+		return nil, nil, err
+	}
+	return events, stop, nil
+}
+
+func newSubscribeConfig(opts []SubscribeOption) subscribeConfig {
+	cfg := subscribeConfig{buffer: defaultSubscribeBuffer}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.buffer < 0 {
+		cfg.buffer = 0
+	}
+	return cfg
+}
+
+// watchChanges opens the change stream of a subscription and hands its
+// events to deliver from a goroutine, saving the resume token of each event
+// deliver returns nil for. The returned function stops the goroutine and
+// waits for it; done runs when it ends.
+func (d *Database) watchChanges(ctx context.Context, db string, collection string, filter any, cfg subscribeConfig, deliver func(context.Context, ChangeEvent) error, done func()) (func(), error) {
+	key := cfg.key
+	if key == "" {
+		key = db + "." + collection
+	}
+	var token bson.Raw
+	if cfg.store != nil {
+		var err error
+		if token, err = cfg.store.LoadResumeToken(ctx, key); err != nil {
+			return nil, fmt.Errorf("subscribe %s.%s: %w", db, collection, err)
+		}
+	}
+	pipeline := mongo.Pipeline{}
+	if filter != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filter}})
+	}
+	open := func(ctx context.Context, token bson.Raw) (ChangeStream, error) {
+		opts := moptions.ChangeStream()
+		if token != nil {
+			opts.SetResumeAfter(token)
+		}
+		if cfg.fullDocument {
+			opts.SetFullDocument(moptions.UpdateLookup)
+		}
+		return Watch(ctx, d.Client, db, collection, pipeline, opts)
+	}
+	stream, err := open(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("subscribe %s.%s: %w", db, collection, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	report := func(err error) {
+		if cfg.onError != nil && ctx.Err() == nil {
+			cfg.onError(fmt.Errorf("subscribe %s.%s: %w", db, collection, err))
+		}
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer done()
+		backoff := subscribeMinBackoff
+		for {
+			for stream.Next(ctx) {
+				var event ChangeEvent
+				if err := stream.Decode(&event); err != nil {
+					report(fmt.Errorf("decode change event: %w", err))
+					continue
+				}
+				if deliver(ctx, event) != nil {
+					break
+				}
+				token = stream.ResumeToken()
+				if cfg.store != nil {
+					if err := cfg.store.SaveResumeToken(ctx, key, token); err != nil {
+						report(err)
+					}
+				}
+				backoff = subscribeMinBackoff
+			}
+			err := stream.Err()
+			stream.Close(context.WithoutCancel(ctx))
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+				if err == nil {
+					report(errChangeStreamEnded)
+					return
+				}
+				if !resumableChangeStream(err) {
+					report(err)
+					return
+				}
+				report(fmt.Errorf("change stream failed, reopening in %s: %w", backoff, err))
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(2*backoff, subscribeMaxBackoff)
+				if stream, err = open(ctx, token); err == nil {
+					break
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			<-finished
+		})
+	}
+	return stop, nil
+}
+
+// errChangeStreamEnded ends a subscription whose change stream was
+// invalidated, as it is when the collection is dropped or renamed
+var errChangeStreamEnded = errors.New("change stream ended")
+
+// resumableChangeStream reports whether a change stream that ended with err
+// may be reopened after its last token
+func resumableChangeStream(err error) bool {
+	return !errors.Is(err, ErrUnauthorized) && !errors.Is(err, ErrClientClosed) &&
+		!isServerErrorCode(err, codeChangeStreamFatal, codeChangeStreamHistoryLost)
+}
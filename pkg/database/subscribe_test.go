@@ -0,0 +1,227 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// receive returns the next n events of a subscription, failing the test when
+// they do not arrive within a few seconds
+func receive(t *testing.T, events <-chan ChangeEvent, n int) []ChangeEvent {
+	t.Helper()
+	var out []ChangeEvent
+	timeout := time.After(5 * time.Second)
+	for len(out) < n {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("subscription ended after %d of %d events", len(out), n)
+			}
+			out = append(out, event)
+		case <-timeout:
+			t.Fatalf("received %d of %d events", len(out), n)
+		}
+	}
+	return out
+}
+
+// insertEvent is a change event inserting a document with _id id
+func insertEvent(id any) ChangeEvent {
+	return ChangeEvent{
+		OperationType: "insert",
+		Namespace:     ChangeNamespace{DB: "vault", Collection: "events"},
+		DocumentKey:   bson.M{"_id": id},
+		FullDocument:  bson.M{"_id": id},
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Filter", func(t *testing.T) {
+		mock := NewMockDatabase()
+		events, stop, err := (&Database{Client: mock}).Subscribe(ctx, "vault", "events", bson.M{"operationType": "insert"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		mock.ChangeStream("vault", "events").Send(
+			insertEvent(1),
+			ChangeEvent{OperationType: "update", DocumentKey: bson.M{"_id": 1}, UpdateDescription: &UpdateDescription{UpdatedFields: bson.M{"seen": true}}},
+			insertEvent(2),
+		)
+		got := receive(t, events, 2)
+		if got[0].DocumentKey["_id"] != int32(1) || got[1].DocumentKey["_id"] != int32(2) || got[1].ID == nil {
+			t.Errorf("expected the inserts of 1 and 2 with resume tokens, got %+v", got)
+		}
+		if got[0].Namespace.Collection != "events" || got[0].FullDocument["_id"] != int32(1) {
+			t.Errorf("expected the namespace and full document, got %+v", got[0])
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		mock := NewMockDatabase()
+		feed := mock.ChangeStream("vault", "events")
+		store := NewMemoryResumeTokenStore()
+		var mu sync.Mutex
+		var reported []error
+		db := &Database{Client: mock}
+		events, stop, err := db.Subscribe(ctx, "vault", "events", nil,
+			SubscribeResumeTokens(store, "indexer"),
+			SubscribeOnError(func(err error) {
+				mu.Lock()
+				reported = append(reported, err)
+				mu.Unlock()
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		feed.Send(insertEvent(1), insertEvent(2))
+		second := receive(t, events, 2)[1]
+
+		// Events sent while the stream is down are read after it reopens
+		feed.Fail(errors.New("connection reset"))
+		feed.Send(insertEvent(3))
+		if got := receive(t, events, 1)[0]; got.DocumentKey["_id"] != int32(3) {
+			t.Errorf("expected event 3 after the failure, got %+v", got)
+		}
+		if calls := mock.WatchCalls; len(calls) != 2 || !bytes.Equal(calls[1].ResumeAfter, second.ID) {
+			t.Errorf("expected the stream to resume after event 2, got %+v", calls)
+		}
+		mu.Lock()
+		if len(reported) != 1 || !strings.Contains(reported[0].Error(), "connection reset") {
+			t.Errorf("expected the failure to be reported, got %v", reported)
+		}
+		mu.Unlock()
+
+		// A restarted subscription continues after the saved token
+		stop()
+		if _, ok := <-events; ok {
+			t.Fatal("expected stop to close the channel")
+		}
+		feed.Send(insertEvent(4))
+		events, stop, err = db.Subscribe(ctx, "vault", "events", nil, SubscribeResumeTokens(store, "indexer"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		if got := receive(t, events, 1)[0]; got.DocumentKey["_id"] != int32(4) {
+			t.Errorf("expected event 4 after the restart, got %+v", got)
+		}
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		mock := NewMockDatabase()
+		events, stop, err := (&Database{Client: mock}).Subscribe(ctx, "vault", "events", nil, SubscribeBuffer(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		for i := range 10 {
+			mock.ChangeStream("vault", "events").Send(insertEvent(i))
+		}
+		for i, event := range receive(t, events, 10) {
+			if event.DocumentKey["_id"] != int32(i) {
+				t.Errorf("expected event %d in order, got %v", i, event.DocumentKey)
+			}
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		mock := NewMockDatabase()
+		dropped := make(chan ChangeEvent, 10)
+		events, stop, err := (&Database{Client: mock}).Subscribe(ctx, "vault", "events", nil,
+			SubscribeBuffer(1),
+			SubscribeDropWhenFull(func(event ChangeEvent) { dropped <- event }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		for i := range 5 {
+			mock.ChangeStream("vault", "events").Send(insertEvent(i))
+		}
+		for i := 1; i < 5; i++ {
+			select {
+			case event := <-dropped:
+				if event.DocumentKey["_id"] != int32(i) {
+					t.Errorf("expected event %d to be dropped, got %v", i, event.DocumentKey)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected event %d to be dropped", i)
+			}
+		}
+		if got := receive(t, events, 1)[0]; got.DocumentKey["_id"] != int32(0) {
+			t.Errorf("expected the buffered event 0, got %v", got.DocumentKey)
+		}
+	})
+
+	t.Run("HistoryLost", func(t *testing.T) {
+		mock := NewMockDatabase()
+		var reported error
+		events, _, err := (&Database{Client: mock}).Subscribe(ctx, "vault", "events", nil,
+			SubscribeOnError(func(err error) { reported = err }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mock.ChangeStream("vault", "events").Fail(mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"})
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Fatal("expected no events")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the subscription to end")
+		}
+		if !isServerErrorCode(reported, codeChangeStreamHistoryLost) || len(mock.WatchCalls) != 1 {
+			t.Errorf("expected the lost history to end the subscription without reopening, got %v and %d watches", reported, len(mock.WatchCalls))
+		}
+
+		store := NewMemoryResumeTokenStore()
+		store.SaveResumeToken(ctx, "vault.events", bson.Raw(bsonDocument(t, bson.M{"_data": "gone"})))
+		if _, _, err := (&Database{Client: mock}).Subscribe(ctx, "vault", "events", nil, SubscribeResumeTokens(store, "")); !isServerErrorCode(err, codeChangeStreamHistoryLost) {
+			t.Errorf("expected an unknown token to fail the subscription, got %v", err)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if _, _, err := (&Database{Client: NewFakeDatabase()}).Subscribe(ctx, "vault", "events", nil); err == nil || !strings.Contains(err.Error(), "cannot watch") {
+			t.Errorf("expected the fake to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestCollectionResumeTokenStore(t *testing.T) {
+	ctx := context.Background()
+	store := &CollectionResumeTokenStore{Client: NewFakeDatabase(), DB: "vault", Collection: "resume_tokens"}
+	if token, err := store.LoadResumeToken(ctx, "indexer"); token != nil || err != nil {
+		t.Fatalf("expected no token, got %v, %v", token, err)
+	}
+	for _, data := range []string{"0001", "0002"} {
+		token := bson.Raw(bsonDocument(t, bson.M{"_data": data}))
+		if err := store.SaveResumeToken(ctx, "indexer", token); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.LoadResumeToken(ctx, "indexer")
+		if err != nil || !bytes.Equal(got, token) {
+			t.Errorf("expected %v, got %v, %v", token, got, err)
+		}
+	}
+}
+
+// bsonDocument marshals doc
+func bsonDocument(t *testing.T, doc any) []byte {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
@@ -0,0 +1,621 @@
+//go:build integration
+
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The integration suite runs against any deployment, such as a staging
+// DocumentDB or Atlas cluster before an upgrade:
+//
+//	MONGODB_URI=mongodb+srv://cluster.example.net MONGODB_USERNAME=ci MONGODB_PASSWORD=... \
+//		go test -tags integration ./pkg/database -run Suite
+//
+// MONGODB_USERNAME, MONGODB_PASSWORD, MONGODB_AUTH_SOURCE, MONGODB_TLS
+// ("true"), MONGODB_CA_FILE and MONGODB_RETRY_WRITES ("false" for DocumentDB)
+// add to the URI, and MONGODB_DATABASE names the database the suite creates
+// its collections in, "database_integration" by default. Every test creates
+// and drops its own uniquely named collections, so runs are safe against
+// shared clusters.
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	printCapabilitySkips()
+	os.Exit(code)
+}
+
+var capabilitySkips struct {
+	sync.Mutex
+	tests []string
+}
+
+// skipCapability skips a test the deployment cannot run and records it for
+// the summary printed after the tests
+func skipCapability(t *testing.T, format string, args ...any) {
+	t.Helper()
+	reason := fmt.Sprintf(format, args...)
+	capabilitySkips.Lock()
+	capabilitySkips.tests = append(capabilitySkips.tests, t.Name()+": "+reason)
+	capabilitySkips.Unlock()
+	t.Skip(reason)
+}
+
+func printCapabilitySkips() {
+	capabilitySkips.Lock()
+	defer capabilitySkips.Unlock()
+
+	if len(capabilitySkips.tests) == 0 {
+		return
+	}
+	fmt.Printf("\n%d integration tests skipped, unsupported by the deployment:\n", len(capabilitySkips.tests))
+	for _, test := range capabilitySkips.tests {
+		fmt.Printf("  %s\n", test)
+	}
+}
+
+// suiteOptions builds the connection options of the suite from the
+// environment, skipping the test when MONGODB_URI is not set
+func suiteOptions(t *testing.T) *MongoOptions {
+	t.Helper()
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+	b := NewMongoOptions().SetUri(uri).SetTimeoutDuration(10 * time.Second)
+	if username := os.Getenv("MONGODB_USERNAME"); username != "" {
+		b.SetUsername(username).SetPassword(os.Getenv("MONGODB_PASSWORD"))
+	}
+	if source := os.Getenv("MONGODB_AUTH_SOURCE"); source != "" {
+		b.SetAuthSource(source)
+	}
+	if os.Getenv("MONGODB_TLS") == "true" {
+		b.SetTLS(true)
+	}
+	if caFile := os.Getenv("MONGODB_CA_FILE"); caFile != "" {
+		b.SetCAFile(caFile)
+	}
+	if retry := os.Getenv("MONGODB_RETRY_WRITES"); retry != "" {
+		b.SetRetryWrites(retry == "true")
+	}
+	return b.Build()
+}
+
+// suiteDatabase returns the database the suite's collections go in
+func suiteDatabase() string {
+	if name := os.Getenv("MONGODB_DATABASE"); name != "" {
+		return name
+	}
+	return "database_integration"
+}
+
+// suiteClient connects a Database for one test and closes it afterwards
+func suiteClient(t *testing.T) *Database {
+	t.Helper()
+	db, err := New(suiteOptions(t))
+	if err != nil {
+		t.Fatalf("failed to create database instance: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Client.Close(ctx)
+	})
+	return db
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// suiteCollection returns a collection name unique to this test and run, and
+// drops the collection when the test ends
+func suiteCollection(t *testing.T, db *Database) string {
+	t.Helper()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := unsafeName.ReplaceAllString(strings.ToLower(t.Name()), "_")
+	name = fmt.Sprintf("it_%.40s_%s", name, hex.EncodeToString(suffix))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.Current().(*MongoClient).Client.Database(suiteDatabase()).Collection(name).Drop(ctx); err != nil {
+			t.Logf("failed to drop %s: %v", name, err)
+		}
+	})
+	return name
+}
+
+func TestSuiteOperations(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+	seed := func(t *testing.T) string {
+		t.Helper()
+		coll := suiteCollection(t, db)
+		_, err := db.Client.InsertMany(ctx, dbName, coll, []any{
+			bson.M{"_id": "a", "site": "hq", "score": 1},
+			bson.M{"_id": "b", "site": "hq", "score": 2},
+			bson.M{"_id": "c", "site": "depot", "score": 3},
+		})
+		if err != nil {
+			t.Fatalf("failed to seed: %v", err)
+		}
+		return coll
+	}
+
+	t.Run("Ping", func(t *testing.T) {
+		if err := db.Client.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("InsertOne", func(t *testing.T) {
+		coll := suiteCollection(t, db)
+		id, err := db.Client.InsertOne(ctx, dbName, coll, bson.M{"site": "hq"})
+		if err != nil || id == nil {
+			t.Fatalf("expected an inserted id, got %v, %v", id, err)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		coll := seed(t)
+		result, err := db.Client.Find(ctx, dbName, coll, bson.M{"site": "hq"}, moptions.Find().SetSort(bson.M{"score": -1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := result.([]any)
+		if len(docs) != 2 || normalizeDocument(docs[0]).(map[string]any)["_id"] != "b" {
+			t.Errorf("expected b then a, got %v", docs)
+		}
+		empty, err := db.Client.Find(ctx, dbName, coll, bson.M{"site": "none"})
+		if docs, ok := empty.([]any); err != nil || !ok || docs == nil || len(docs) != 0 {
+			t.Errorf("expected an empty non-nil result, got %#v, %v", empty, err)
+		}
+	})
+
+	t.Run("FindOne", func(t *testing.T) {
+		coll := seed(t)
+		doc, err := db.Client.FindOne(ctx, dbName, coll, bson.M{"_id": "c"})
+		if err != nil || normalizeDocument(doc).(map[string]any)["site"] != "depot" {
+			t.Errorf("expected c, got %v, %v", doc, err)
+		}
+		if _, err := db.Client.FindOne(ctx, dbName, coll, bson.M{"_id": "z"}); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected mongo.ErrNoDocuments, got %v", err)
+		}
+	})
+
+	t.Run("FindCursor", func(t *testing.T) {
+		coll := seed(t)
+		cursor, err := db.Client.FindCursor(ctx, dbName, coll, bson.M{}, moptions.Find().SetBatchSize(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cursor.Close(ctx)
+		n := 0
+		for cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		if err := cursor.Err(); err != nil || n != 3 {
+			t.Errorf("expected 3 documents, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		coll := seed(t)
+		result, err := db.Client.Aggregate(ctx, dbName, coll, bson.A{
+			bson.M{"$group": bson.M{"_id": "$site", "total": bson.M{"$sum": "$score"}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := result.([]any)
+		if len(docs) != 2 || !valuesEqual(normalizeDocument(docs[1]).(map[string]any)["total"], 3) {
+			t.Errorf("expected depot then hq with 3 each, got %v", docs)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		coll := seed(t)
+		if n, err := db.Client.Count(ctx, dbName, coll, bson.M{"site": "hq"}); err != nil || n != 2 {
+			t.Errorf("expected 2, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("Distinct", func(t *testing.T) {
+		coll := seed(t)
+		values, err := db.Client.Distinct(ctx, dbName, coll, "site", bson.M{})
+		if err != nil || len(values) != 2 {
+			t.Errorf("expected 2 sites, got %v, %v", values, err)
+		}
+	})
+
+	t.Run("UpdateOne", func(t *testing.T) {
+		coll := seed(t)
+		res, err := db.Client.UpdateOne(ctx, dbName, coll, bson.M{"_id": "a"}, bson.M{"$inc": bson.M{"score": 10}})
+		if err != nil || res.MatchedCount != 1 || res.ModifiedCount != 1 {
+			t.Errorf("expected one modified, got %+v, %v", res, err)
+		}
+		res, err = db.Client.UpdateOne(ctx, dbName, coll, bson.M{"_id": "z"}, bson.M{"$set": bson.M{"site": "x"}}, moptions.Update().SetUpsert(true))
+		if err != nil || res.UpsertedCount != 1 || res.UpsertedID != "z" {
+			t.Errorf("expected z upserted, got %+v, %v", res, err)
+		}
+	})
+
+	t.Run("UpdateMany", func(t *testing.T) {
+		coll := seed(t)
+		res, err := db.Client.UpdateMany(ctx, dbName, coll, bson.M{"site": "hq"}, bson.M{"$set": bson.M{"site": "office"}})
+		if err != nil || res.ModifiedCount != 2 {
+			t.Errorf("expected two modified, got %+v, %v", res, err)
+		}
+	})
+
+	t.Run("ReplaceOne", func(t *testing.T) {
+		coll := seed(t)
+		res, err := db.Client.ReplaceOne(ctx, dbName, coll, bson.M{"_id": "a"}, bson.M{"site": "lab"})
+		if err != nil || res.ModifiedCount != 1 {
+			t.Fatalf("expected one replaced, got %+v, %v", res, err)
+		}
+		doc, _ := db.Client.FindOne(ctx, dbName, coll, bson.M{"_id": "a"})
+		if _, ok := normalizeDocument(doc).(map[string]any)["score"]; ok {
+			t.Errorf("expected the replacement without score, got %v", doc)
+		}
+	})
+
+	t.Run("DeleteOne", func(t *testing.T) {
+		coll := seed(t)
+		if n, err := db.Client.DeleteOne(ctx, dbName, coll, bson.M{"site": "hq"}); err != nil || n != 1 {
+			t.Errorf("expected one deleted, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("DeleteMany", func(t *testing.T) {
+		coll := seed(t)
+		if n, err := db.Client.DeleteMany(ctx, dbName, coll, bson.M{"site": "hq"}); err != nil || n != 2 {
+			t.Errorf("expected two deleted, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("FindOneAndUpdate", func(t *testing.T) {
+		coll := seed(t)
+		doc, err := db.Client.FindOneAndUpdate(ctx, dbName, coll, bson.M{"_id": "a"}, bson.M{"$inc": bson.M{"score": 1}},
+			moptions.FindOneAndUpdate().SetReturnDocument(moptions.After))
+		if err != nil || !valuesEqual(normalizeDocument(doc).(map[string]any)["score"], 2) {
+			t.Errorf("expected score 2 after the update, got %v, %v", doc, err)
+		}
+	})
+
+	t.Run("BulkWrite", func(t *testing.T) {
+		coll := seed(t)
+		res, err := db.Client.BulkWrite(ctx, dbName, coll, []any{
+			mongo.NewInsertOneModel().SetDocument(bson.M{"_id": "d"}),
+			mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": "a"}).SetUpdate(bson.M{"$set": bson.M{"site": "lab"}}),
+			mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": "c"}),
+		})
+		if err != nil || res.InsertedCount != 1 || res.ModifiedCount != 1 || res.DeletedCount != 1 {
+			t.Errorf("expected one insert, update and delete, got %+v, %v", res, err)
+		}
+	})
+}
+
+func TestSuiteIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+	coll := suiteCollection(t, db)
+
+	specs := []IndexSpec{
+		{Keys: bson.D{{Key: "serial", Value: 1}}, Unique: true},
+		{Keys: bson.D{{Key: "site", Value: 1}, {Key: "created", Value: -1}}, Name: "site_recent"},
+	}
+	for range 2 {
+		// Ensuring the same indexes again is not an error
+		if err := EnsureIndexes(ctx, db.Client, dbName, coll, specs...); err != nil {
+			t.Fatalf("failed to ensure indexes: %v", err)
+		}
+	}
+	cursor, err := db.Current().(*MongoClient).Client.Database(dbName).Collection(coll).Indexes().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, index := range indexes {
+		names[fmt.Sprint(index["name"])] = true
+	}
+	if !names["serial_1"] || !names["site_recent"] {
+		t.Errorf("expected serial_1 and site_recent, got %v", names)
+	}
+
+	if _, err := db.Client.InsertOne(ctx, dbName, coll, bson.M{"serial": "x1"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Client.InsertOne(ctx, dbName, coll, bson.M{"serial": "x1"})
+	if !errors.Is(err, ErrDuplicateKey) || !IsDuplicateKey(err) {
+		t.Errorf("expected the unique index to reject the second x1 with ErrDuplicateKey, got %v", err)
+	}
+}
+
+func TestSuiteImport(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+	source, target := suiteCollection(t, db), suiteCollection(t, db)
+
+	docs := make([]any, 50)
+	for i := range docs {
+		docs[i] = bson.M{
+			"_id":     primitive.NewObjectID(),
+			"order":   int32(i),
+			"size":    int64(i) << 40,
+			"created": primitive.NewDateTimeFromTime(time.Date(2024, 3, 1, 9, i, 0, 0, time.UTC)),
+			"camera":  bson.M{"name": "gate", "tags": bson.A{"a", int32(i)}},
+		}
+	}
+	if _, err := db.Client.InsertMany(ctx, dbName, source, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if _, err := db.Export(ctx, dbName, source, nil, &out, ExportOptions{Gzip: true, Canonical: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	report, err := db.Import(ctx, dbName, target, bytes.NewReader(data), ImportOptions{BatchSize: 20})
+	if err != nil || report.Written != 50 {
+		t.Fatalf("expected 50 documents written, got %+v, %v", report, err)
+	}
+	sorted := moptions.Find().SetSort(bson.M{"_id": 1})
+	want, err := FindAs[bson.M](ctx, db, dbName, source, bson.M{}, sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FindAs[bson.M](ctx, db, dbName, target, bson.M{}, sorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the imported documents to equal the exported ones")
+	}
+
+	// Importing again fails every line on the _id index, which reports them
+	report, err = db.Import(ctx, dbName, target, bytes.NewReader(data), ImportOptions{BatchSize: 20, ContinueOnError: true})
+	if err != nil || report.Failed != 50 || report.Failures[49].Line != 50 || !errors.Is(report.Failures[0].Err, ErrDuplicateKey) {
+		t.Errorf("expected 50 duplicate key failures, got %+v, %v", report, err)
+	}
+}
+
+func TestSuiteTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+	client := db.Current().(*MongoClient).Client
+
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		t.Fatalf("hello failed: %v", err)
+	}
+	if hello["setName"] == nil && hello["msg"] != "isdbgrid" {
+		skipCapability(t, "transactions need a replica set or sharded cluster, the deployment is a standalone server")
+	}
+
+	coll := suiteCollection(t, db)
+	// Collections cannot be created inside a transaction on older servers
+	if _, err := db.Client.InsertOne(ctx, dbName, coll, bson.M{"_id": "setup"}); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(fn func(txCtx context.Context) error) error {
+		return WithTransaction(ctx, db.Client, fn)
+	}
+
+	err := run(func(txCtx context.Context) error {
+		_, err := db.Client.InsertOne(txCtx, dbName, coll, bson.M{"_id": "committed"})
+		return err
+	})
+	var se mongo.ServerError
+	if errors.As(err, &se) && (se.HasErrorCode(20) || se.HasErrorCode(303)) {
+		// IllegalOperation and NotImplemented: the deployment has no transactions
+		skipCapability(t, "transactions are not supported: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	aborted := errors.New("abort")
+	err = run(func(txCtx context.Context) error {
+		if _, err := db.Client.InsertOne(txCtx, dbName, coll, bson.M{"_id": "aborted"}); err != nil {
+			return err
+		}
+		return aborted
+	})
+	if !errors.Is(err, aborted) {
+		t.Fatalf("expected the abort error, got %v", err)
+	}
+
+	result, err := db.Client.Distinct(ctx, dbName, coll, "_id", bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := fmt.Sprint(result)
+	if !strings.Contains(ids, "committed") || strings.Contains(ids, "aborted") {
+		t.Errorf("expected the committed insert and not the aborted one, got %v", result)
+	}
+}
+
+func TestSuiteSequences(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+	// The unique collection name doubles as a unique sequence name
+	name := suiteCollection(t, db)
+	t.Cleanup(func() {
+		db.Client.DeleteOne(context.Background(), dbName, CounterCollection, bson.M{"_id": name})
+	})
+
+	const workers, calls = 8, 25
+	var mu sync.Mutex
+	seen := map[int64]bool{}
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range calls {
+				first, last, err := db.NextSequenceBatch(ctx, dbName, name, 1+w%3)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				for n := first; n <= last; n++ {
+					if seen[n] {
+						t.Errorf("number %d handed out twice", n)
+					}
+					seen[n] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	total, err := db.PeekSequence(ctx, dbName, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(seen)) != total {
+		t.Errorf("expected 1 to %d without gaps, got %d numbers", total, len(seen))
+	}
+
+	if err := db.ResetSequence(ctx, dbName, name, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.NextSequence(ctx, dbName, name); n != 1001 || err != nil {
+		t.Errorf("expected 1001 after the reset, got %d, %v", n, err)
+	}
+}
+
+func TestSuiteViews(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+	source := suiteCollection(t, db)
+	view := suiteCollection(t, db)
+	if _, err := db.Client.InsertMany(ctx, dbName, source, []any{
+		bson.M{"_id": 1, "status": "online", "deleted": false},
+		bson.M{"_id": 2, "status": "offline", "deleted": true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := bson.A{bson.M{"$match": bson.M{"deleted": false}}, bson.M{"$project": bson.M{"status": 1}}}
+	if err := CreateView(ctx, db.Client, dbName, view, source, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.Client.Count(ctx, dbName, view, bson.M{}); n != 1 || err != nil {
+		t.Errorf("expected the view to hold 1 document, got %d, %v", n, err)
+	}
+	views, err := ListViews(ctx, db.Client, dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(views, func(v ViewInfo) bool { return v.Name == view && v.ViewOn == source && len(v.Pipeline) == 2 }) {
+		t.Errorf("expected %s among the views, got %v", view, views)
+	}
+	if _, err := db.Client.InsertOne(ctx, dbName, view, bson.M{"status": "new"}); !errors.Is(err, ErrViewNotSupported) {
+		t.Errorf("expected ErrViewNotSupported for an insert, got %v", err)
+	}
+	if err := EnsureIndexes(ctx, db.Client, dbName, view, IndexSpec{Keys: bson.D{{Key: "status", Value: 1}}}); !errors.Is(err, ErrViewNotSupported) {
+		t.Errorf("expected ErrViewNotSupported for an index, got %v", err)
+	}
+	if err := DropView(ctx, db.Client, dbName, source); !errors.Is(err, ErrNotView) {
+		t.Errorf("expected ErrNotView for the source collection, got %v", err)
+	}
+	if err := DropView(ctx, db.Client, dbName, view); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuiteErrors(t *testing.T) {
+	ctx := context.Background()
+	db := suiteClient(t)
+	dbName := suiteDatabase()
+
+	t.Run("DuplicateKey", func(t *testing.T) {
+		coll := suiteCollection(t, db)
+		db.Client.InsertOne(ctx, dbName, coll, bson.M{"_id": "a"})
+		_, err := db.Client.InsertOne(ctx, dbName, coll, bson.M{"_id": "a"})
+		if !errors.Is(err, ErrDuplicateKey) || !IsDuplicateKey(err) || IsTransient(err) {
+			t.Errorf("expected a permanent ErrDuplicateKey, got %v", err)
+		}
+		_, err = db.Client.InsertMany(ctx, dbName, coll, []any{bson.M{"_id": "b"}, bson.M{"_id": "b"}})
+		if !IsDuplicateKey(err) {
+			t.Errorf("expected InsertMany to report the duplicate key, got %v", err)
+		}
+	})
+
+	t.Run("BadQuery", func(t *testing.T) {
+		coll := suiteCollection(t, db)
+		db.Client.InsertOne(ctx, dbName, coll, bson.M{"_id": "a"})
+		_, err := db.Client.Find(ctx, dbName, coll, bson.M{"score": bson.M{"$noSuchOperator": 1}})
+		var se mongo.ServerError
+		if !errors.As(err, &se) {
+			t.Fatalf("expected a server error for an unknown operator, got %v", err)
+		}
+		if IsDuplicateKey(err) || IsTransient(err) || IsUnauthorized(err) || IsThrottled(err) || IsTimeout(err) {
+			t.Errorf("expected a bad query to match no classification, got %v", err)
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		coll := suiteCollection(t, db)
+		if _, err := db.Client.FindOne(ctx, dbName, coll, ByID("not-an-id")); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		coll := suiteCollection(t, db)
+		ctx, cancel := context.WithTimeout(ctx, time.Nanosecond)
+		defer cancel()
+		time.Sleep(time.Millisecond)
+		if _, err := db.Client.Find(ctx, dbName, coll, bson.M{}); !IsTimeout(err) {
+			t.Errorf("expected a timeout, got %v", err)
+		}
+	})
+
+	t.Run("ClientClosed", func(t *testing.T) {
+		closed, err := New(suiteOptions(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		closed.Client.Close(ctx)
+		if err := closed.Client.Ping(ctx); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("expected ErrClientClosed, got %v", err)
+		}
+	})
+}
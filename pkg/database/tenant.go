@@ -0,0 +1,711 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTenantRequired is returned under StrictTenancy when a tenant-scoped
+// collection is used without going through ForTenant
+var ErrTenantRequired = errors.New("tenant scope required")
+
+// TenantConflictError is returned by a tenant-scoped client when a filter,
+// document or update names a different tenant than the scope
+type TenantConflictError struct {
+	Field  string
+	Tenant string
+	Value  any
+}
+
+func (e *TenantConflictError) Error() string {
+	return fmt.Sprintf("tenant conflict: %s %v in a scope for tenant %q", e.Field, e.Value, e.Tenant)
+}
+
+// TenantConfig configures ForTenant and StrictTenancy
+type TenantConfig struct {
+	// Field holds the tenant ID, "tenant_id" by default
+	Field string
+	// Collections lists the tenant-scoped collections; every collection is
+	// scoped when it is empty
+	Collections []string
+}
+
+func (cfg TenantConfig) field() string {
+	if cfg.Field == "" {
+		return "tenant_id"
+	}
+	return cfg.Field
+}
+
+func (cfg TenantConfig) scoped(collection string) bool {
+	return len(cfg.Collections) == 0 || slices.Contains(cfg.Collections, collection)
+}
+
+// tenantScopeKey marks contexts of calls made through ForTenant
+type tenantScopeKey struct{}
+
+// ForTenant returns a copy of d whose client only sees the documents of
+// tenantID in the tenant-scoped collections. Every filter gets
+// Field: tenantID added at the top level, so it also holds across $or and
+// $and, aggregations get a leading $match, and inserted and replacement
+// documents get the field set; upserts take it from the filter. A filter,
+// document or update that names another tenant, at any depth of $and, $or
+// and $nor, is rejected with a *TenantConflictError, as is an update that
+// sets, renames onto, unsets or projects away the field. Stages reading other collections, such as $lookup and
+// $unionWith, are not scoped. Change streams, through Watch and Subscribe,
+// only deliver the events of documents of tenantID.
+//
+//	scoped := db.ForTenant(claims.TenantID, database.TenantConfig{})
+//	cameras, err := scoped.Client.Find(ctx, "vault", "cameras", bson.M{"online": true})
+func (d *Database) ForTenant(tenantID string, cfg TenantConfig) *Database {
+	return &Database{
+		Options: d.Options,
+		Client:  &tenantClient{DatabaseInterface: d.Client, cfg: cfg, field: cfg.field(), tenant: tenantID},
+	}
+}
+
+// StrictTenancy returns middleware that refuses every operation on the
+// tenant-scoped collections of cfg unless it comes through ForTenant, so a
+// forgotten scope fails with ErrTenantRequired instead of reading across
+// tenants. Apply it to the unscoped client before calling ForTenant.
+//
+//	db.Client = database.Wrap(db.Client, database.StrictTenancy(cfg))
+//	scoped := db.ForTenant(tenantID, cfg)
+func StrictTenancy(cfg TenantConfig) Middleware {
+	return func(next DatabaseInterface) DatabaseInterface {
+		return &strictTenantClient{DatabaseInterface: next, cfg: cfg}
+	}
+}
+
+// tenantClient scopes every operation on a tenant-scoped collection
+type tenantClient struct {
+	DatabaseInterface
+	cfg    TenantConfig
+	field  string
+	tenant string
+}
+
+// scope returns ctx marked as tenant-scoped and true when collection is
+// scoped, and ctx unchanged and false otherwise
+func (c *tenantClient) scope(ctx context.Context, collection string) (context.Context, bool) {
+	if !c.cfg.scoped(collection) {
+		return ctx, false
+	}
+	return context.WithValue(ctx, tenantScopeKey{}, c.tenant), true
+}
+
+func (c *tenantClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.Find(ctx, db, collection, filter, opts...)
+}
+
+func (c *tenantClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.FindOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *tenantClient) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.FindCursor(ctx, db, collection, filter, opts...)
+}
+
+func (c *tenantClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if pipeline, err = c.scopePipeline(pipeline); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.Aggregate(ctx, db, collection, pipeline, opts...)
+}
+
+func (c *tenantClient) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return 0, err
+		}
+	}
+	return c.DatabaseInterface.Count(ctx, db, collection, filter, opts...)
+}
+
+func (c *tenantClient) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.Distinct(ctx, db, collection, field, filter, opts...)
+}
+
+func (c *tenantClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if document, err = c.stampDocument(document); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.InsertOne(ctx, db, collection, document, opts...)
+}
+
+func (c *tenantClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		stamped := make([]any, len(documents))
+		for i, document := range documents {
+			doc, err := c.stampDocument(document)
+			if err != nil {
+				return nil, err
+			}
+			stamped[i] = doc
+		}
+		documents = stamped
+	}
+	return c.DatabaseInterface.InsertMany(ctx, db, collection, documents, opts...)
+}
+
+func (c *tenantClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, update, err = c.scopeUpdate(filter, update); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.UpdateOne(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *tenantClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, update, err = c.scopeUpdate(filter, update); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.UpdateMany(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *tenantClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return nil, err
+		}
+		if replacement, err = c.stampDocument(replacement); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.ReplaceOne(ctx, db, collection, filter, replacement, opts...)
+}
+
+func (c *tenantClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return 0, err
+		}
+	}
+	return c.DatabaseInterface.DeleteOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *tenantClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, err = c.scopeFilter(filter); err != nil {
+			return 0, err
+		}
+	}
+	return c.DatabaseInterface.DeleteMany(ctx, db, collection, filter, opts...)
+}
+
+func (c *tenantClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		var err error
+		if filter, update, err = c.scopeUpdate(filter, update); err != nil {
+			return nil, err
+		}
+	}
+	return c.DatabaseInterface.FindOneAndUpdate(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *tenantClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if !ok {
+		return c.DatabaseInterface.BulkWrite(ctx, db, collection, models, opts...)
+	}
+	scoped := make([]any, len(models))
+	for i, model := range models {
+		var err error
+		switch m := model.(type) {
+		case *mongo.InsertOneModel:
+			copied := *m
+			copied.Document, err = c.stampDocument(m.Document)
+			model = &copied
+		case *mongo.UpdateOneModel:
+			copied := *m
+			copied.Filter, copied.Update, err = c.scopeUpdate(m.Filter, m.Update)
+			model = &copied
+		case *mongo.UpdateManyModel:
+			copied := *m
+			copied.Filter, copied.Update, err = c.scopeUpdate(m.Filter, m.Update)
+			model = &copied
+		case *mongo.ReplaceOneModel:
+			copied := *m
+			if copied.Filter, err = c.scopeFilter(m.Filter); err == nil {
+				copied.Replacement, err = c.stampDocument(m.Replacement)
+			}
+			model = &copied
+		case *mongo.DeleteOneModel:
+			copied := *m
+			copied.Filter, err = c.scopeFilter(m.Filter)
+			model = &copied
+		case *mongo.DeleteManyModel:
+			copied := *m
+			copied.Filter, err = c.scopeFilter(m.Filter)
+			model = &copied
+		}
+		if err != nil {
+			return nil, fmt.Errorf("model %d: %w", i, err)
+		}
+		scoped[i] = model
+	}
+	return c.DatabaseInterface.BulkWrite(ctx, db, collection, scoped, opts...)
+}
+
+// Unwrap returns the wrapped client
+func (c *tenantClient) Unwrap() DatabaseInterface {
+	return c.DatabaseInterface
+}
+
+// EnsureIndexes passes index creation on to the wrapped client
+func (c *tenantClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	ctx, _ = c.scope(ctx, collection)
+	return EnsureIndexes(ctx, c.DatabaseInterface, db, collection, specs...)
+}
+
+// EstimatedCount counts the documents of the tenant exactly in a scoped
+// collection, whose metadata count includes every tenant, and passes the
+// estimate of other collections on to the wrapped client, counting exactly
+// when it cannot estimate
+func (c *tenantClient) EstimatedCount(ctx context.Context, db string, collection string) (int64, error) {
+	if c.cfg.scoped(collection) {
+		return c.Count(ctx, db, collection, bson.D{})
+	}
+	if counter, ok := implementation[EstimatedCounter](c.DatabaseInterface); ok {
+		return counter.EstimatedCount(ctx, db, collection)
+	}
+	return c.DatabaseInterface.Count(ctx, db, collection, bson.D{})
+}
+
+// Watch opens the change stream of a scoped collection with a leading
+// $match on the tenant field of the full document, or of the document key
+// for deletes, which carries the field only when it is part of the shard
+// key. Update events have a full document only with the UpdateLookup
+// option, so without it they are not delivered.
+func (c *tenantClient) Watch(ctx context.Context, db string, collection string, pipeline any, opts ...any) (ChangeStream, error) {
+	ctx, ok := c.scope(ctx, collection)
+	if ok {
+		stages, err := pipelineStages(pipeline)
+		if err != nil {
+			return nil, err
+		}
+		match := bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "fullDocument." + c.field, Value: c.tenant}},
+			bson.D{{Key: "documentKey." + c.field, Value: c.tenant}},
+		}}}}}
+		pipeline = append(bson.A{match}, stages...)
+	}
+	return Watch(ctx, c.DatabaseInterface, db, collection, pipeline, opts...)
+}
+
+// scopeFilter returns filter with the tenant field added at the top level
+func (c *tenantClient) scopeFilter(filter any) (bson.D, error) {
+	if err := filterError(filter); err != nil {
+		return nil, err
+	}
+	doc := bson.D{}
+	if filter != nil {
+		var err error
+		if doc, err = documentD(filter); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.checkFilter(doc); err != nil {
+		return nil, err
+	}
+	return setField(doc, c.field, c.tenant), nil
+}
+
+// checkFilter rejects filters naming another tenant, looking into $and, $or
+// and $nor
+func (c *tenantClient) checkFilter(filter bson.D) error {
+	for _, e := range filter {
+		switch e.Key {
+		case c.field:
+			if !c.sameTenant(e.Value) {
+				return c.conflict(e.Value)
+			}
+		case "$and", "$or", "$nor":
+			clauses, _ := e.Value.(bson.A)
+			for _, clause := range clauses {
+				if doc, ok := clause.(bson.D); ok {
+					if err := c.checkFilter(doc); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sameTenant reports whether a filter value selects exactly the scope's
+// tenant, as the plain value or with $eq
+func (c *tenantClient) sameTenant(value any) bool {
+	if doc, ok := value.(bson.D); ok && len(doc) == 1 && doc[0].Key == "$eq" {
+		value = doc[0].Value
+	}
+	return valuesEqual(value, c.tenant)
+}
+
+// stampDocument returns document with the tenant field set
+func (c *tenantClient) stampDocument(document any) (bson.D, error) {
+	doc, err := documentD(document)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range doc {
+		if e.Key == c.field && !valuesEqual(e.Value, c.tenant) {
+			return nil, c.conflict(e.Value)
+		}
+	}
+	return setField(doc, c.field, c.tenant), nil
+}
+
+// scopeUpdate scopes filter and rejects updates that set, rename onto,
+// unset or project away the tenant field, whether update is an update
+// document, an UpdateBuilder or a pipeline. Pipelines replacing the root
+// document with $replaceWith or $replaceRoot get the field set again by a
+// final $set stage.
+func (c *tenantClient) scopeUpdate(filter any, update any) (bson.D, any, error) {
+	scoped, err := c.scopeFilter(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	stages := []any{update}
+	pipeline := false
+	if _, ok := update.(bson.D); !ok && reflect.ValueOf(update).Kind() == reflect.Slice {
+		stages, _ = pipelineStages(update)
+		pipeline = true
+	}
+	replaced := false
+	for _, stage := range stages {
+		built, err := updateDocument(stage)
+		if err != nil {
+			return nil, nil, err
+		}
+		doc, err := documentD(built)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, op := range doc {
+			switch op.Key {
+			case "$replaceWith", "$replaceRoot":
+				replaced = true
+			case "$unset":
+				if err := c.checkPaths(op.Value); err != nil {
+					return nil, nil, err
+				}
+			case "$project":
+				fields, _ := op.Value.(bson.D)
+				if err := c.checkProjection(fields); err != nil {
+					return nil, nil, err
+				}
+			default:
+				fields, _ := op.Value.(bson.D)
+				for _, f := range fields {
+					if pathsOverlap(f.Key, c.field) {
+						return nil, nil, c.conflict(f.Value)
+					}
+					if to, ok := f.Value.(string); ok && op.Key == "$rename" && pathsOverlap(to, c.field) {
+						return nil, nil, c.conflict(f.Key)
+					}
+				}
+			}
+		}
+	}
+	if pipeline && replaced {
+		stamp := bson.D{{Key: "$set", Value: bson.D{{Key: c.field, Value: c.tenant}}}}
+		update = append(append(bson.A{}, stages...), stamp)
+	}
+	return scoped, update, nil
+}
+
+// checkPaths rejects a $unset naming the tenant field, as an update
+// document, a path or an array of paths
+func (c *tenantClient) checkPaths(value any) error {
+	var paths []string
+	switch v := value.(type) {
+	case string:
+		paths = []string{v}
+	case bson.A:
+		for _, p := range v {
+			if path, ok := p.(string); ok {
+				paths = append(paths, path)
+			}
+		}
+	case bson.D:
+		for _, e := range v {
+			paths = append(paths, e.Key)
+		}
+	}
+	for _, path := range paths {
+		if pathsOverlap(path, c.field) {
+			return c.conflict(path)
+		}
+	}
+	return nil
+}
+
+// checkProjection rejects a $project stage that excludes or recomputes the
+// tenant field, or includes fields without it, which drops it
+func (c *tenantClient) checkProjection(fields bson.D) error {
+	included, kept := false, false
+	for _, f := range fields {
+		excluded := valuesEqual(f.Value, 0) || f.Value == false
+		if pathsOverlap(f.Key, c.field) {
+			if f.Key != c.field || excluded || !(valuesEqual(f.Value, 1) || f.Value == true || f.Value == "$"+c.field) {
+				return c.conflict(f.Value)
+			}
+			kept = true
+		}
+		if f.Key != "_id" && !excluded {
+			included = true
+		}
+	}
+	if included && !kept {
+		return c.conflict("$project")
+	}
+	return nil
+}
+
+// scopePipeline returns pipeline with a $match on the tenant placed first,
+// or right after a stage that must come first such as $search
+func (c *tenantClient) scopePipeline(pipeline any) (bson.A, error) {
+	stages, err := pipelineStages(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	match := bson.D{{Key: "$match", Value: bson.D{{Key: c.field, Value: c.tenant}}}}
+	at := 0
+	if len(stages) > 0 {
+		name, _, err := stageOf(stages[0])
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "$search", "$vectorSearch", "$geoNear":
+			at = 1
+		case "$searchMeta":
+			return nil, fmt.Errorf("%s cannot be scoped to a tenant; use $search with a filter on %s", name, c.field)
+		}
+	}
+	scoped := make(bson.A, 0, len(stages)+1)
+	scoped = append(scoped, stages[:at]...)
+	scoped = append(scoped, match)
+	return append(scoped, stages[at:]...), nil
+}
+
+func (c *tenantClient) conflict(value any) error {
+	return &TenantConflictError{Field: c.field, Tenant: c.tenant, Value: value}
+}
+
+// strictTenantClient refuses unscoped operations on tenant-scoped
+// collections
+type strictTenantClient struct {
+	DatabaseInterface
+	cfg TenantConfig
+}
+
+// check returns ErrTenantRequired when collection is tenant-scoped and ctx
+// does not come from ForTenant
+func (c *strictTenantClient) check(ctx context.Context, collection string) error {
+	if !c.cfg.scoped(collection) {
+		return nil
+	}
+	if _, ok := ctx.Value(tenantScopeKey{}).(string); ok {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrTenantRequired, collection)
+}
+
+func (c *strictTenantClient) Find(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.Find(ctx, db, collection, filter, opts...)
+}
+
+func (c *strictTenantClient) FindOne(ctx context.Context, db string, collection string, filter any, opts ...any) (any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.FindOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *strictTenantClient) FindCursor(ctx context.Context, db string, collection string, filter any, opts ...any) (Cursor, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.FindCursor(ctx, db, collection, filter, opts...)
+}
+
+func (c *strictTenantClient) Aggregate(ctx context.Context, db string, collection string, pipeline any, opts ...any) (any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.Aggregate(ctx, db, collection, pipeline, opts...)
+}
+
+func (c *strictTenantClient) Count(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return 0, err
+	}
+	return c.DatabaseInterface.Count(ctx, db, collection, filter, opts...)
+}
+
+func (c *strictTenantClient) Distinct(ctx context.Context, db string, collection string, field string, filter any, opts ...any) ([]any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.Distinct(ctx, db, collection, field, filter, opts...)
+}
+
+func (c *strictTenantClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.InsertOne(ctx, db, collection, document, opts...)
+}
+
+func (c *strictTenantClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.InsertMany(ctx, db, collection, documents, opts...)
+}
+
+func (c *strictTenantClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.UpdateOne(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *strictTenantClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.UpdateMany(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *strictTenantClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.ReplaceOne(ctx, db, collection, filter, replacement, opts...)
+}
+
+func (c *strictTenantClient) DeleteOne(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return 0, err
+	}
+	return c.DatabaseInterface.DeleteOne(ctx, db, collection, filter, opts...)
+}
+
+func (c *strictTenantClient) DeleteMany(ctx context.Context, db string, collection string, filter any, opts ...any) (int64, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return 0, err
+	}
+	return c.DatabaseInterface.DeleteMany(ctx, db, collection, filter, opts...)
+}
+
+func (c *strictTenantClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.FindOneAndUpdate(ctx, db, collection, filter, update, opts...)
+}
+
+func (c *strictTenantClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.BulkWrite(ctx, db, collection, models, opts...)
+}
+
+// Unwrap returns the wrapped client
+func (c *strictTenantClient) Unwrap() DatabaseInterface {
+	return c.DatabaseInterface
+}
+
+// EnsureIndexes passes index creation on to the wrapped client; indexes are
+// shared by all tenants, so it is not checked
+func (c *strictTenantClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	return EnsureIndexes(ctx, c.DatabaseInterface, db, collection, specs...)
+}
+
+// EstimatedCount passes the estimate on to the wrapped client once the call
+// is checked like Count, counting exactly when it cannot estimate
+func (c *strictTenantClient) EstimatedCount(ctx context.Context, db string, collection string) (int64, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return 0, err
+	}
+	if counter, ok := implementation[EstimatedCounter](c.DatabaseInterface); ok {
+		return counter.EstimatedCount(ctx, db, collection)
+	}
+	return c.DatabaseInterface.Count(ctx, db, collection, bson.D{})
+}
+
+// Watch refuses unscoped change streams on tenant-scoped collections, which
+// would deliver the events of every tenant
+func (c *strictTenantClient) Watch(ctx context.Context, db string, collection string, pipeline any, opts ...any) (ChangeStream, error) {
+	if err := c.check(ctx, collection); err != nil {
+		return nil, err
+	}
+	return Watch(ctx, c.DatabaseInterface, db, collection, pipeline, opts...)
+}
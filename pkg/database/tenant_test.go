@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestForTenant(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("MockRecordsScopedFilter", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			filter any
+			want   map[string]any
+		}{
+			{"Empty", bson.M{}, map[string]any{"tenant_id": "acme"}},
+			{"Nil", nil, map[string]any{"tenant_id": "acme"}},
+			{"Field", bson.M{"online": true}, map[string]any{"online": true, "tenant_id": "acme"}},
+			{"SameTenant", bson.M{"tenant_id": "acme"}, map[string]any{"tenant_id": "acme"}},
+			{
+				"Or",
+				bson.M{"$or": bson.A{bson.M{"a": 1}, bson.M{"b": 2}}},
+				map[string]any{"$or": []any{map[string]any{"a": 1}, map[string]any{"b": 2}}, "tenant_id": "acme"},
+			},
+			{
+				"AndWithSameTenant",
+				bson.M{"$and": bson.A{bson.M{"tenant_id": bson.M{"$eq": "acme"}}}},
+				map[string]any{"$and": []any{map[string]any{"tenant_id": map[string]any{"$eq": "acme"}}}, "tenant_id": "acme"},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mock := NewMockDatabase()
+				scoped := (&Database{Client: mock}).ForTenant("acme", TenantConfig{})
+				if _, err := scoped.Client.Find(ctx, "vault", "cameras", tt.filter); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				call, _ := mock.LastFindCall()
+				if !FilterEquals(tt.want)(call.Filter) {
+					t.Errorf("expected %v, got %v", tt.want, call.Filter)
+				}
+			})
+		}
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		tests := []struct {
+			name string
+			call func(client DatabaseInterface) error
+		}{
+			{"Filter", func(c DatabaseInterface) error {
+				_, err := c.Find(ctx, "vault", "cameras", bson.M{"tenant_id": "other"})
+				return err
+			}},
+			{"InsideOr", func(c DatabaseInterface) error {
+				_, err := c.Count(ctx, "vault", "cameras", bson.M{"$or": bson.A{bson.M{"name": "x"}, bson.M{"tenant_id": "other"}}})
+				return err
+			}},
+			{"In", func(c DatabaseInterface) error {
+				_, err := c.DeleteMany(ctx, "vault", "cameras", bson.M{"tenant_id": bson.M{"$in": bson.A{"acme", "other"}}})
+				return err
+			}},
+			{"Insert", func(c DatabaseInterface) error {
+				_, err := c.InsertOne(ctx, "vault", "cameras", bson.M{"tenant_id": "other"})
+				return err
+			}},
+			{"Update", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, U().Set("tenant_id", "other"))
+				return err
+			}},
+			{"PipelineUpdate", func(c DatabaseInterface) error {
+				_, err := c.UpdateMany(ctx, "vault", "cameras", bson.M{}, bson.A{bson.M{"$set": bson.M{"tenant_id": "other"}}})
+				return err
+			}},
+			{"RenameOnto", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.M{"$rename": bson.M{"other": "tenant_id"}})
+				return err
+			}},
+			{"RenameAway", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.M{"$rename": bson.M{"tenant_id": "old_tenant"}})
+				return err
+			}},
+			{"Unset", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.M{"$unset": bson.M{"tenant_id": ""}})
+				return err
+			}},
+			{"PipelineUnset", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.A{bson.M{"$unset": "tenant_id"}})
+				return err
+			}},
+			{"PipelineUnsetArray", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, mongo.Pipeline{{{Key: "$unset", Value: bson.A{"name", "tenant_id"}}}})
+				return err
+			}},
+			{"ProjectExcluding", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.A{bson.M{"$project": bson.M{"tenant_id": 0}}})
+				return err
+			}},
+			{"ProjectRecomputing", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.A{bson.M{"$project": bson.M{"tenant_id": "other"}}})
+				return err
+			}},
+			{"ProjectDropping", func(c DatabaseInterface) error {
+				_, err := c.UpdateOne(ctx, "vault", "cameras", bson.M{}, bson.A{bson.M{"$project": bson.M{"name": 1}}})
+				return err
+			}},
+			{"BulkWrite", func(c DatabaseInterface) error {
+				_, err := c.BulkWrite(ctx, "vault", "cameras", []any{mongo.NewDeleteOneModel().SetFilter(bson.M{"tenant_id": "other"})})
+				return err
+			}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mock := NewMockDatabase()
+				scoped := (&Database{Client: mock}).ForTenant("acme", TenantConfig{})
+				var conflict *TenantConflictError
+				if err := tt.call(scoped.Client); !errors.As(err, &conflict) || conflict.Tenant != "acme" {
+					t.Fatalf("expected a *TenantConflictError, got %v", err)
+				}
+				if n := len(mock.History()); n != 0 {
+					t.Errorf("expected nothing sent to the client, got %d calls", n)
+				}
+			})
+		}
+	})
+
+	t.Run("Isolation", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		db := &Database{Client: fake}
+		acme := db.ForTenant("acme", TenantConfig{})
+		globex := db.ForTenant("globex", TenantConfig{})
+
+		if _, err := acme.Client.InsertMany(ctx, "vault", "cameras", []any{bson.M{"_id": 1, "name": "front"}, bson.M{"_id": 2, "name": "back"}}); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+		if _, err := globex.Client.InsertOne(ctx, "vault", "cameras", bson.M{"_id": 3, "name": "front"}); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+
+		if n, err := acme.Client.Count(ctx, "vault", "cameras", bson.M{"$or": bson.A{bson.M{"name": "front"}, bson.M{"name": "back"}}}); err != nil || n != 2 {
+			t.Errorf("expected 2 acme cameras, got %d, %v", n, err)
+		}
+		if _, err := globex.Client.FindOne(ctx, "vault", "cameras", bson.M{"_id": 1}); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected another tenant's document hidden, got %v", err)
+		}
+		if n, err := globex.Client.DeleteMany(ctx, "vault", "cameras", bson.M{}); err != nil || n != 1 {
+			t.Errorf("expected only globex's camera deleted, got %d, %v", n, err)
+		}
+
+		res, err := globex.Client.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": 4}, U().Set("name", "side"), moptions.Update().SetUpsert(true))
+		if err != nil || res.UpsertedID == nil {
+			t.Fatalf("expected an upsert, got %+v, %v", res, err)
+		}
+		counts, err := fake.Aggregate(ctx, "vault", "cameras", bson.A{
+			bson.M{"$group": bson.M{"_id": "$tenant_id", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.M{"_id": 1}},
+		})
+		if err != nil {
+			t.Fatalf("failed to aggregate: %v", err)
+		}
+		want := []any{bson.M{"_id": "acme", "count": int32(2)}, bson.M{"_id": "globex", "count": int32(1)}}
+		if !valuesEqual(normalizeDocument(counts), normalizeDocument(want)) {
+			t.Errorf("expected %v, got %v", want, counts)
+		}
+
+		docs, err := acme.Client.Aggregate(ctx, "vault", "cameras", bson.A{bson.M{"$count": "n"}})
+		if err != nil || !valuesEqual(normalizeDocument(docs), normalizeDocument([]any{bson.M{"n": int32(2)}})) {
+			t.Errorf("expected the aggregation scoped, got %v, %v", docs, err)
+		}
+	})
+
+	t.Run("PipelineUpdates", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			update any
+			stamp  bool
+		}{
+			{"ReplaceWith", bson.A{bson.M{"$replaceWith": bson.M{"name": "front"}}}, true},
+			{"ReplaceRoot", mongo.Pipeline{{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$settings"}}}}, true},
+			{"ProjectKeeping", bson.A{bson.M{"$project": bson.M{"name": 1, "tenant_id": 1}}}, false},
+			{"UnsetOther", bson.A{bson.M{"$unset": bson.A{"name"}}}, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				mock := NewMockDatabase()
+				scoped := (&Database{Client: mock}).ForTenant("acme", TenantConfig{})
+				if _, err := scoped.Client.UpdateOne(ctx, "vault", "cameras", bson.M{}, tt.update); err != nil {
+					t.Fatal(err)
+				}
+				stages := pipelineStagesOf(mock.UpdateOneCalls[0].Update)
+				last := stages[len(stages)-1]
+				stamped := last.Name == "$set" && valuesEqual(last.Spec, map[string]any{"tenant_id": "acme"})
+				if stamped != tt.stamp {
+					t.Errorf("expected the tenant stamped %v, got %v", tt.stamp, stages)
+				}
+			})
+		}
+	})
+
+	t.Run("PipelineMatchAfterSearch", func(t *testing.T) {
+		mock := NewMockDatabase()
+		scoped := (&Database{Client: mock}).ForTenant("acme", TenantConfig{})
+		scoped.Client.Aggregate(ctx, "vault", "cameras", bson.A{bson.M{"$search": bson.M{}}, bson.M{"$limit": 5}})
+
+		call, _ := mock.LastAggregateCall()
+		if len(call.Stages) != 3 || call.Stages[0].Name != "$search" || call.Stages[1].Name != "$match" {
+			t.Errorf("expected $match right after $search, got %v", call.Stages)
+		}
+	})
+
+	t.Run("ChangeStream", func(t *testing.T) {
+		mock := NewMockDatabase()
+		cfg := TenantConfig{}
+		scoped := (&Database{Client: Wrap(mock, StrictTenancy(cfg))}).ForTenant("a", cfg)
+		events, stop, err := scoped.Subscribe(ctx, "vault", "events", bson.M{"operationType": bson.M{"$ne": "invalidate"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		mock.ChangeStream("vault", "events").Send(
+			ChangeEvent{OperationType: "insert", DocumentKey: bson.M{"_id": 1}, FullDocument: bson.M{"_id": 1, "tenant_id": "b", "secret": 1}},
+			ChangeEvent{OperationType: "insert", DocumentKey: bson.M{"_id": 2}, FullDocument: bson.M{"_id": 2, "tenant_id": "a"}},
+			ChangeEvent{OperationType: "delete", DocumentKey: bson.M{"_id": 3, "tenant_id": "b"}},
+			ChangeEvent{OperationType: "delete", DocumentKey: bson.M{"_id": 4, "tenant_id": "a"}},
+		)
+		got := receive(t, events, 2)
+		if got[0].DocumentKey["_id"] != int32(2) || got[1].DocumentKey["_id"] != int32(4) {
+			t.Errorf("expected only the events of tenant a, got %+v", got)
+		}
+		if stages := mock.WatchCalls[0].Pipeline; len(pipelineStagesOf(stages)) != 2 {
+			t.Errorf("expected the tenant $match before the filter, got %v", stages)
+		}
+	})
+
+	t.Run("UnscopedCollection", func(t *testing.T) {
+		mock := NewMockDatabase()
+		scoped := (&Database{Client: mock}).ForTenant("acme", TenantConfig{Field: "org", Collections: []string{"cameras"}})
+		scoped.Client.Find(ctx, "vault", "regions", bson.M{})
+		scoped.Client.Find(ctx, "vault", "cameras", bson.M{})
+
+		history := mock.History()
+		if !FilterEquals(bson.M{})(history[0].Args[0]) || !FilterEquals(bson.M{"org": "acme"})(history[1].Args[0]) {
+			t.Errorf("expected only cameras scoped, got %v and %v", history[0].Args[0], history[1].Args[0])
+		}
+	})
+}
+
+func TestStrictTenancy(t *testing.T) {
+	ctx := context.Background()
+	cfg := TenantConfig{Collections: []string{"cameras"}}
+	db := &Database{Client: Wrap(NewFakeDatabase(), StrictTenancy(cfg))}
+
+	if _, err := db.Client.Find(ctx, "vault", "cameras", bson.M{}); !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("expected ErrTenantRequired unscoped, got %v", err)
+	}
+	if _, err := db.Client.Find(ctx, "vault", "regions", bson.M{}); err != nil {
+		t.Errorf("expected other collections allowed, got %v", err)
+	}
+	if _, err := db.ForTenant("acme", cfg).Client.InsertOne(ctx, "vault", "cameras", bson.M{"name": "front"}); err != nil {
+		t.Errorf("expected the scoped client allowed, got %v", err)
+	}
+	if _, err := Watch(ctx, db.Client, "vault", "cameras", nil); !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("expected ErrTenantRequired for an unscoped change stream, got %v", err)
+	}
+	if err := EnsureIndexes(ctx, db.Client, "vault", "cameras", IndexSpec{Keys: bson.D{{Key: "tenant_id", Value: 1}}}); err != nil {
+		t.Errorf("expected index creation allowed, got %v", err)
+	}
+}
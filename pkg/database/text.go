@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// textScoreField is the field TextSearch projects the relevance score into
+// and removes again before returning the documents
+const textScoreField = "_textScore"
+
+// TextSearchOptions tunes a TextSearch
+type TextSearchOptions struct {
+	// Language selects the stemming and stop words, e.g. "dutch"; the index's
+	// default language is used when empty
+	Language string
+	// CaseSensitive makes terms match only with the same case
+	CaseSensitive bool
+	// Limit caps the number of documents returned; 0 returns all matches
+	Limit int64
+	// Filter is combined with the text clause, e.g. bson.M{"site": "a"}
+	Filter any
+}
+
+// ScoredDocument is a TextSearch result with its relevance score
+type ScoredDocument struct {
+	Document any
+	Score    float64
+}
+
+// TextSearch returns the documents of db.collection matching query, most
+// relevant first. The query follows MongoDB's $search syntax: words match any
+// of them, "quoted phrases" must appear as is and -words exclude documents.
+// The collection needs a text index, see TextIndex.
+//
+// FakeDatabase approximates the search with substring matching of the terms
+// against all string fields and scores documents by the number of
+// occurrences; stemming, stop words and index weights are not modelled.
+func TextSearch(ctx context.Context, client DatabaseInterface, db string, collection string, query string, opts TextSearchOptions) ([]ScoredDocument, error) {
+	if query == "" {
+		return nil, errors.New("text search query is required")
+	}
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("text search limit must not be negative, got %d", opts.Limit)
+	}
+
+	text := bson.M{"$search": query}
+	if opts.Language != "" {
+		text["$language"] = opts.Language
+	}
+	if opts.CaseSensitive {
+		text["$caseSensitive"] = true
+	}
+	filter := bson.M{"$text": text}
+	if opts.Filter != nil {
+		filter["$and"] = bson.A{opts.Filter}
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	findOpts := moptions.Find().
+		SetProjection(bson.M{textScoreField: score}).
+		SetSort(bson.D{{Key: textScoreField, Value: score}})
+	if opts.Limit > 0 {
+		findOpts.SetLimit(opts.Limit)
+	}
+	result, err := client.Find(ctx, db, collection, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, _ := result.([]any)
+	out := make([]ScoredDocument, len(docs))
+	for i, doc := range docs {
+		out[i] = scoredDocument(doc, textScoreField)
+	}
+	return out, nil
+}
+
+// TextIndex returns the spec of a text index over the fields in weights. A
+// field's weight multiplies its contribution to the score; fields weigh 1 by
+// default. A collection can have only one text index.
+func TextIndex(weights map[string]int) IndexSpec {
+	fields := make([]string, 0, len(weights))
+	for field := range weights {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	keys := make(bson.D, len(fields))
+	for i, field := range fields {
+		keys[i] = bson.E{Key: field, Value: "text"}
+	}
+	return IndexSpec{Keys: keys, Weights: weights}
+}
+
+// scoredDocument moves the score projected into field out of doc
+func scoredDocument(doc any, field string) ScoredDocument {
+	switch t := doc.(type) {
+	case bson.M:
+		score, _ := toFloat(t[field])
+		delete(t, field)
+		return ScoredDocument{Document: t, Score: score}
+	case map[string]any:
+		score, _ := toFloat(t[field])
+		delete(t, field)
+		return ScoredDocument{Document: t, Score: score}
+	case bson.D:
+		for i, e := range t {
+			if e.Key == field {
+				score, _ := toFloat(e.Value)
+				return ScoredDocument{Document: append(t[:i:i], t[i+1:]...), Score: score}
+			}
+		}
+	}
+	return ScoredDocument{Document: doc}
+}
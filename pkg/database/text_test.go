@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func textFake(t *testing.T) *FakeDatabase {
+	t.Helper()
+	fake := NewFakeDatabase()
+	err := fake.Seed("testdb", "events",
+		bson.M{"_id": 1, "title": "Person at door", "notes": "person left a parcel", "site": "a"},
+		bson.M{"_id": 2, "title": "Car in driveway", "notes": "red car", "site": "a"},
+		bson.M{"_id": 3, "title": "Person in garden", "site": "b"},
+		bson.M{"_id": 4, "title": "Cat at door", "tags": bson.A{"animal", "Person-free"}, "site": "b"},
+	)
+	if err != nil {
+		t.Fatalf("failed to seed fake: %v", err)
+	}
+	return fake
+}
+
+func TestTextSearchFake(t *testing.T) {
+	ctx := context.Background()
+	fake := textFake(t)
+
+	tests := []struct {
+		name  string
+		query string
+		opts  TextSearchOptions
+		want  []int32
+	}{
+		{"MostRelevantFirst", "person", TextSearchOptions{}, []int32{1, 3, 4}},
+		{"AnyTerm", "car cat", TextSearchOptions{}, []int32{2, 4}},
+		{"Phrase", `"at door"`, TextSearchOptions{}, []int32{1, 4}},
+		{"Negation", "person -parcel", TextSearchOptions{}, []int32{3, 4}},
+		{"CaseSensitive", "Person", TextSearchOptions{CaseSensitive: true}, []int32{1, 3, 4}},
+		{"CaseSensitiveMiss", "PERSON", TextSearchOptions{CaseSensitive: true}, []int32{}},
+		{"Filter", "person", TextSearchOptions{Filter: bson.M{"site": "b"}}, []int32{3, 4}},
+		{"Limit", "person", TextSearchOptions{Limit: 1}, []int32{1}},
+		{"NoMatch", "bicycle", TextSearchOptions{}, []int32{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := TextSearch(ctx, fake, "testdb", "events", tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make([]int32, len(results))
+			for i, r := range results {
+				doc := r.Document.(bson.M)
+				if _, ok := doc[textScoreField]; ok {
+					t.Errorf("expected the score field removed, got %v", doc)
+				}
+				if r.Score <= 0 {
+					t.Errorf("expected a positive score, got %v", r.Score)
+				}
+				if i > 0 && r.Score > results[i-1].Score {
+					t.Errorf("expected scores in descending order, got %v after %v", r.Score, results[i-1].Score)
+				}
+				got[i] = doc["_id"].(int32)
+			}
+			if !valuesEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("EmptyQuery", func(t *testing.T) {
+		if _, err := TextSearch(ctx, fake, "testdb", "events", "", TextSearchOptions{}); err == nil {
+			t.Error("expected an error for an empty query")
+		}
+	})
+
+	t.Run("StoredDocumentsUnchanged", func(t *testing.T) {
+		for _, doc := range fake.Documents("testdb", "events") {
+			if _, ok := doc[fakeTextScore]; ok {
+				t.Errorf("expected no score stored, got %v", doc)
+			}
+		}
+	})
+
+	t.Run("ProjectionKeepsOtherFieldsOut", func(t *testing.T) {
+		result, err := fake.Find(ctx, "testdb", "events", bson.M{"$text": bson.M{"$search": "car"}},
+			moptions.Find().SetProjection(bson.M{"title": 1, "score": bson.M{"$meta": "textScore"}}))
+		docs, _ := result.([]any)
+		if err != nil || len(docs) != 1 {
+			t.Fatalf("expected one document, got %v, %v", result, err)
+		}
+		doc := docs[0].(bson.M)
+		if len(doc) != 3 || doc["title"] != "Car in driveway" || doc["score"] != 2.0 {
+			t.Errorf("expected _id, title and score, got %v", doc)
+		}
+	})
+}
+
+func TestTextSearchMock(t *testing.T) {
+	mock := NewMockDatabase()
+	mock.QueueFind([]any{bson.M{"_id": 1, "title": "person", textScoreField: 1.5}}, nil)
+
+	results, err := TextSearch(context.Background(), mock, "testdb", "events", "person", TextSearchOptions{
+		Language: "dutch",
+		Limit:    5,
+		Filter:   bson.M{"site": "a"},
+	})
+	if err != nil || len(results) != 1 || results[0].Score != 1.5 {
+		t.Fatalf("expected one result scored 1.5, got %v, %v", results, err)
+	}
+
+	call, _ := mock.LastFindCall()
+	want := bson.M{
+		"$text": bson.M{"$search": "person", "$language": "dutch"},
+		"$and":  bson.A{bson.M{"site": "a"}},
+	}
+	if !valuesEqual(call.Filter, want) {
+		t.Errorf("expected filter %v, got %v", want, call.Filter)
+	}
+	opts := call.Opts[0].(*moptions.FindOptions)
+	if opts.Limit == nil || *opts.Limit != 5 {
+		t.Errorf("expected limit 5, got %v", opts.Limit)
+	}
+}
+
+func TestTextIndex(t *testing.T) {
+	spec := TextIndex(map[string]int{"title": 10, "notes": 2})
+	want := bson.D{{Key: "notes", Value: "text"}, {Key: "title", Value: "text"}}
+	if !valuesEqual(spec.Keys, want) || spec.Weights["title"] != 10 {
+		t.Errorf("expected text keys %v with weights, got %v", want, spec)
+	}
+
+	fake := NewFakeDatabase()
+	if err := EnsureIndexes(context.Background(), fake, "testdb", "events", spec); err != nil {
+		t.Errorf("expected the fake to accept a text index, got %v", err)
+	}
+	if err := EnsureIndexes(context.Background(), fake, "testdb", "events", TextIndex(map[string]int{"title": 0})); err == nil {
+		t.Error("expected an error for a zero weight")
+	}
+}
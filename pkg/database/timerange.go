@@ -0,0 +1,105 @@
+package database
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RangeOption configures TimeRange and the ranges built on it
+type RangeOption = Option[timeRange]
+
+type timeRange struct {
+	exclusiveStart bool
+	inclusiveEnd   bool
+	clock          Clock
+}
+
+// ExclusiveStart excludes from itself, using $gt instead of $gte
+func ExclusiveStart() RangeOption {
+	return func(r *timeRange) {
+		r.exclusiveStart = true
+	}
+}
+
+// InclusiveEnd includes to itself, using $lte instead of $lt
+func InclusiveEnd() RangeOption {
+	return func(r *timeRange) {
+		r.inclusiveEnd = true
+	}
+}
+
+// RangeClock sets the clock LastNDays, Today and ThisMonth read the current
+// time from, e.g. a TestClock
+func RangeClock(clock Clock) RangeOption {
+	return func(r *timeRange) {
+		r.clock = clock
+	}
+}
+
+// TimeRange returns a filter matching documents whose date in field lies
+// between from and to. By default the range is half-open, from <= t < to, so
+// consecutive ranges neither overlap nor leave gaps. Both bounds are
+// converted to UTC, the zone MongoDB stores dates in, and a zero bound leaves
+// that side open; with both zero the filter matches every document.
+func TimeRange(field string, from time.Time, to time.Time, opts ...RangeOption) bson.M {
+	r := newTimeRange(opts)
+	cond := bson.M{}
+	if !from.IsZero() {
+		op := "$gte"
+		if r.exclusiveStart {
+			op = "$gt"
+		}
+		cond[op] = from.UTC()
+	}
+	if !to.IsZero() {
+		op := "$lt"
+		if r.inclusiveEnd {
+			op = "$lte"
+		}
+		cond[op] = to.UTC()
+	}
+	if len(cond) == 0 {
+		return bson.M{}
+	}
+	return bson.M{field: cond}
+}
+
+// LastNDays returns a TimeRange from n times 24 hours ago with an open end,
+// so documents dated slightly ahead by clock skew are included
+func LastNDays(field string, n int, opts ...RangeOption) bson.M {
+	now := newTimeRange(opts).clock.Now()
+	return TimeRange(field, now.Add(-time.Duration(n)*24*time.Hour), time.Time{}, opts...)
+}
+
+// Today returns a TimeRange over the current calendar day in loc, from
+// midnight to the next midnight, which is 23 or 25 hours away on daylight
+// saving days. A nil loc means UTC.
+func Today(field string, loc *time.Location, opts ...RangeOption) bson.M {
+	now := localNow(opts, loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return TimeRange(field, start, start.AddDate(0, 0, 1), opts...)
+}
+
+// ThisMonth returns a TimeRange over the current calendar month in loc. A
+// nil loc means UTC.
+func ThisMonth(field string, loc *time.Location, opts ...RangeOption) bson.M {
+	now := localNow(opts, loc)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return TimeRange(field, start, start.AddDate(0, 1, 0), opts...)
+}
+
+func newTimeRange(opts []RangeOption) timeRange {
+	r := timeRange{clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+func localNow(opts []RangeOption, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return newTimeRange(opts).clock.Now().In(loc)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTimeRange(t *testing.T) {
+	brussels, err := time.LoadLocation("Europe/Brussels")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, brussels)
+	to := time.Date(2024, 3, 2, 0, 0, 0, 0, brussels)
+	utcFrom, utcTo := from.UTC(), to.UTC()
+	// 2024-03-31 is the spring-forward day in Brussels: 23 hours long
+	clock := NewTestClock(time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		want   bson.M
+	}{
+		{"HalfOpen", TimeRange("at", from, to), bson.M{"at": bson.M{"$gte": utcFrom, "$lt": utcTo}}},
+		{"ExclusiveStart", TimeRange("at", from, to, ExclusiveStart()), bson.M{"at": bson.M{"$gt": utcFrom, "$lt": utcTo}}},
+		{"InclusiveEnd", TimeRange("at", from, to, InclusiveEnd()), bson.M{"at": bson.M{"$gte": utcFrom, "$lte": utcTo}}},
+		{"OpenStart", TimeRange("at", time.Time{}, to), bson.M{"at": bson.M{"$lt": utcTo}}},
+		{"OpenEnd", TimeRange("at", from, time.Time{}), bson.M{"at": bson.M{"$gte": utcFrom}}},
+		{"Unbounded", TimeRange("at", time.Time{}, time.Time{}), bson.M{}},
+		{
+			"LastNDays",
+			LastNDays("at", 7, RangeClock(clock)),
+			bson.M{"at": bson.M{"$gte": time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC)}},
+		},
+		{
+			"TodayAcrossDST",
+			Today("at", brussels, RangeClock(clock)),
+			bson.M{"at": bson.M{"$gte": time.Date(2024, 3, 30, 23, 0, 0, 0, time.UTC), "$lt": time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)}},
+		},
+		{
+			"TodayUTC",
+			Today("at", nil, RangeClock(clock)),
+			bson.M{"at": bson.M{"$gte": time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), "$lt": time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			"ThisMonth",
+			ThisMonth("at", brussels, RangeClock(clock)),
+			bson.M{"at": bson.M{"$gte": time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC), "$lt": time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !valuesEqual(normalizeDocument(tt.filter), normalizeDocument(tt.want)) {
+				t.Errorf("expected %v, got %v", tt.want, tt.filter)
+			}
+			for _, cond := range tt.filter {
+				for _, bound := range cond.(bson.M) {
+					if bound.(time.Time).Location() != time.UTC {
+						t.Errorf("expected bounds in UTC, got %v", bound)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTimeRangeFake(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFakeDatabase()
+	err := fake.Seed("vault", "events",
+		bson.M{"_id": 1, "at": day.Add(-time.Millisecond)},
+		bson.M{"_id": 2, "at": day},
+		bson.M{"_id": 3, "at": day.Add(12 * time.Hour)},
+		bson.M{"_id": 4, "at": day.Add(24 * time.Hour)},
+		bson.M{"_id": 5},
+	)
+	if err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	plusOne := time.FixedZone("UTC+1", 3600)
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		want   []int32
+	}{
+		{"HalfOpenDay", TimeRange("at", day, day.Add(24*time.Hour)), []int32{2, 3}},
+		{"InclusiveEnd", TimeRange("at", day, day.Add(24*time.Hour), InclusiveEnd()), []int32{2, 3, 4}},
+		{"ExclusiveStart", TimeRange("at", day, day.Add(24*time.Hour), ExclusiveStart()), []int32{3}},
+		{"OtherZoneSameInstant", TimeRange("at", day.In(plusOne), time.Time{}), []int32{2, 3, 4}},
+		{"OpenStart", TimeRange("at", time.Time{}, day), []int32{1}},
+		{"Unbounded", TimeRange("at", time.Time{}, time.Time{}), []int32{1, 2, 3, 4, 5}},
+		{"SubMillisecondBound", TimeRange("at", time.Time{}, day.Add(500*time.Microsecond)), []int32{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fake.Find(ctx, "vault", "events", tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			docs := result.([]any)
+			got := make([]int32, len(docs))
+			for i, doc := range docs {
+				got[i] = doc.(bson.M)["_id"].(int32)
+			}
+			if !valuesEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
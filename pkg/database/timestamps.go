@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TimestampConfig configures WithTimestamps
+type TimestampConfig struct {
+	// CreatedField is set when a document is inserted, "created_at" by default
+	CreatedField string
+	// UpdatedField is set on every insert, update and replace, "updated_at"
+	// by default
+	UpdatedField string
+	// Clock supplies the time, the system clock by default; use a TestClock
+	// to assert exact timestamps
+	Clock Clock
+}
+
+// WithTimestamps returns middleware that maintains creation and update times
+// on top-level fields. Inserts get both fields, updates set the updated field
+// with $set and, when upserting, the created field with $setOnInsert, and
+// replacements get the updated field. Fields a write already sets, including
+// non-zero times in inserted structs, are left as given. Update documents and
+// UpdateBuilders are both handled; pipeline updates pass through unchanged.
+// Documents are passed on as bson.D, so the mock records them in that form.
+//
+//	client := database.Wrap(db.Client, database.WithTimestamps(database.TimestampConfig{}))
+func WithTimestamps(cfg TimestampConfig) Middleware {
+	if cfg.CreatedField == "" {
+		cfg.CreatedField = "created_at"
+	}
+	if cfg.UpdatedField == "" {
+		cfg.UpdatedField = "updated_at"
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	return func(next DatabaseInterface) DatabaseInterface {
+		return &timestampClient{DatabaseInterface: next, cfg: cfg}
+	}
+}
+
+// timestampClient passes reads through and stamps writes
+type timestampClient struct {
+	DatabaseInterface
+	cfg TimestampConfig
+}
+
+func (c *timestampClient) InsertOne(ctx context.Context, db string, collection string, document any, opts ...any) (any, error) {
+	doc, err := c.stampInsert(document, c.now())
+	if err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.InsertOne(ctx, db, collection, doc, opts...)
+}
+
+func (c *timestampClient) InsertMany(ctx context.Context, db string, collection string, documents []any, opts ...any) ([]any, error) {
+	now := c.now()
+	stamped := make([]any, len(documents))
+	for i, document := range documents {
+		doc, err := c.stampInsert(document, now)
+		if err != nil {
+			return nil, err
+		}
+		stamped[i] = doc
+	}
+	return c.DatabaseInterface.InsertMany(ctx, db, collection, stamped, opts...)
+}
+
+func (c *timestampClient) UpdateOne(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	stamped, err := c.stampUpdate(update, c.now(), updateUpsert(opts))
+	if err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.UpdateOne(ctx, db, collection, filter, stamped, opts...)
+}
+
+func (c *timestampClient) UpdateMany(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (*UpdateResult, error) {
+	stamped, err := c.stampUpdate(update, c.now(), updateUpsert(opts))
+	if err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.UpdateMany(ctx, db, collection, filter, stamped, opts...)
+}
+
+func (c *timestampClient) ReplaceOne(ctx context.Context, db string, collection string, filter any, replacement any, opts ...any) (*UpdateResult, error) {
+	stamped, err := c.stampReplacement(replacement, c.now())
+	if err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.ReplaceOne(ctx, db, collection, filter, stamped, opts...)
+}
+
+func (c *timestampClient) FindOneAndUpdate(ctx context.Context, db string, collection string, filter any, update any, opts ...any) (any, error) {
+	upsert := false
+	for _, o := range optionsOf[moptions.FindOneAndUpdateOptions](opts) {
+		if o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+	}
+	stamped, err := c.stampUpdate(update, c.now(), upsert)
+	if err != nil {
+		return nil, err
+	}
+	return c.DatabaseInterface.FindOneAndUpdate(ctx, db, collection, filter, stamped, opts...)
+}
+
+func (c *timestampClient) BulkWrite(ctx context.Context, db string, collection string, models []any, opts ...any) (*BulkWriteResult, error) {
+	now := c.now()
+	stamped := make([]any, len(models))
+	for i, model := range models {
+		var err error
+		switch m := model.(type) {
+		case *mongo.InsertOneModel:
+			copied := *m
+			copied.Document, err = c.stampInsert(m.Document, now)
+			model = &copied
+		case *mongo.UpdateOneModel:
+			copied := *m
+			copied.Update, err = c.stampUpdate(m.Update, now, m.Upsert != nil && *m.Upsert)
+			model = &copied
+		case *mongo.UpdateManyModel:
+			copied := *m
+			copied.Update, err = c.stampUpdate(m.Update, now, m.Upsert != nil && *m.Upsert)
+			model = &copied
+		case *mongo.ReplaceOneModel:
+			copied := *m
+			copied.Replacement, err = c.stampReplacement(m.Replacement, now)
+			model = &copied
+		}
+		if err != nil {
+			return nil, err
+		}
+		stamped[i] = model
+	}
+	return c.DatabaseInterface.BulkWrite(ctx, db, collection, stamped, opts...)
+}
+
+// Unwrap returns the wrapped client
+func (c *timestampClient) Unwrap() DatabaseInterface {
+	return c.DatabaseInterface
+}
+
+// EnsureIndexes passes index creation on to the wrapped client
+func (c *timestampClient) EnsureIndexes(ctx context.Context, db string, collection string, specs ...IndexSpec) error {
+	return EnsureIndexes(ctx, c.DatabaseInterface, db, collection, specs...)
+}
+
+func (c *timestampClient) now() time.Time {
+	return c.cfg.Clock.Now().UTC()
+}
+
+func (c *timestampClient) stampInsert(document any, now time.Time) (bson.D, error) {
+	doc, err := documentD(document)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range []string{c.cfg.CreatedField, c.cfg.UpdatedField} {
+		if !hasTimestamp(doc, field) {
+			doc = setField(doc, field, now)
+		}
+	}
+	return doc, nil
+}
+
+func (c *timestampClient) stampReplacement(replacement any, now time.Time) (bson.D, error) {
+	doc, err := documentD(replacement)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTimestamp(doc, c.cfg.UpdatedField) {
+		doc = setField(doc, c.cfg.UpdatedField, now)
+	}
+	return doc, nil
+}
+
+func (c *timestampClient) stampUpdate(update any, now time.Time, upsert bool) (any, error) {
+	if _, ok := update.(bson.D); !ok && reflect.ValueOf(update).Kind() == reflect.Slice {
+		// Pipeline updates pass through unchanged
+		return update, nil
+	}
+	built, err := updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := documentD(built)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 || !strings.HasPrefix(doc[0].Key, "$") {
+		// A replacement document; the client reports the error
+		return update, nil
+	}
+	if !updateTargets(doc, c.cfg.UpdatedField) {
+		doc = withOperatorField(doc, "$set", c.cfg.UpdatedField, now)
+	}
+	if upsert && !updateTargets(doc, c.cfg.CreatedField) {
+		doc = withOperatorField(doc, "$setOnInsert", c.cfg.CreatedField, now)
+	}
+	return doc, nil
+}
+
+// hasTimestamp reports whether doc sets field to something other than null
+// or the zero time
+func hasTimestamp(doc bson.D, field string) bool {
+	for _, e := range doc {
+		if e.Key != field {
+			continue
+		}
+		switch v := e.Value.(type) {
+		case nil, primitive.Null:
+			return false
+		case primitive.DateTime:
+			return !v.Time().IsZero()
+		}
+		return true
+	}
+	return false
+}
+
+// updateTargets reports whether any operator of update targets field or a
+// path overlapping it
+func updateTargets(update bson.D, field string) bool {
+	for _, op := range update {
+		fields, _ := op.Value.(bson.D)
+		for _, f := range fields {
+			if pathsOverlap(f.Key, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withOperatorField adds field: value to operator in update, adding the
+// operator when missing
+func withOperatorField(update bson.D, operator string, field string, value any) bson.D {
+	for i, op := range update {
+		if op.Key == operator {
+			fields, _ := op.Value.(bson.D)
+			update[i].Value = append(fields, bson.E{Key: field, Value: value})
+			return update
+		}
+	}
+	return append(update, bson.E{Key: operator, Value: bson.D{{Key: field, Value: value}}})
+}
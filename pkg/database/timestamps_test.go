@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestWithTimestamps(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	later := created.Add(time.Hour)
+	explicit := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	setup := func(t *testing.T) (*FakeDatabase, *TestClock, DatabaseInterface) {
+		t.Helper()
+		fake := NewFakeDatabase()
+		clock := NewTestClock(created)
+		client := Wrap(fake, WithTimestamps(TimestampConfig{Clock: clock}))
+		if _, err := client.InsertOne(ctx, "vault", "cameras", bson.M{"_id": 1, "name": "front"}); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+		clock.Advance(time.Hour)
+		return fake, clock, client
+	}
+	stored := func(fake *FakeDatabase, id int32) bson.M {
+		for _, doc := range fake.Documents("vault", "cameras") {
+			if doc["_id"] == id {
+				return doc
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		write       func(client DatabaseInterface) error
+		id          int32
+		wantCreated time.Time
+		wantUpdated time.Time
+	}{
+		{
+			name:        "Insert",
+			write:       func(DatabaseInterface) error { return nil },
+			id:          1,
+			wantCreated: created,
+			wantUpdated: created,
+		},
+		{
+			name: "UpdateDocument",
+			write: func(client DatabaseInterface) error {
+				_, err := client.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": 1}, bson.M{"$set": bson.M{"name": "back"}})
+				return err
+			},
+			id:          1,
+			wantCreated: created,
+			wantUpdated: later,
+		},
+		{
+			name: "UpdateBuilder",
+			write: func(client DatabaseInterface) error {
+				_, err := client.UpdateMany(ctx, "vault", "cameras", bson.M{}, U().Inc("views", 1))
+				return err
+			},
+			id:          1,
+			wantCreated: created,
+			wantUpdated: later,
+		},
+		{
+			name: "ExplicitUpdatedKept",
+			write: func(client DatabaseInterface) error {
+				_, err := client.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": 1}, U().Set("updated_at", explicit))
+				return err
+			},
+			id:          1,
+			wantCreated: created,
+			wantUpdated: explicit,
+		},
+		{
+			name: "Upsert",
+			write: func(client DatabaseInterface) error {
+				_, err := client.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": 2}, U().Set("name", "side"), moptions.Update().SetUpsert(true))
+				return err
+			},
+			id:          2,
+			wantCreated: later,
+			wantUpdated: later,
+		},
+		{
+			name: "FindOneAndUpdateUpsert",
+			write: func(client DatabaseInterface) error {
+				_, err := client.FindOneAndUpdate(ctx, "vault", "cameras", bson.M{"_id": 2}, bson.M{"$set": bson.M{"name": "side"}},
+					moptions.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(moptions.After))
+				return err
+			},
+			id:          2,
+			wantCreated: later,
+			wantUpdated: later,
+		},
+		{
+			name: "ReplaceKeepsGivenCreated",
+			write: func(client DatabaseInterface) error {
+				_, err := client.ReplaceOne(ctx, "vault", "cameras", bson.M{"_id": 1}, bson.M{"name": "back", "created_at": created})
+				return err
+			},
+			id:          1,
+			wantCreated: created,
+			wantUpdated: later,
+		},
+		{
+			name: "InsertExplicitCreatedKept",
+			write: func(client DatabaseInterface) error {
+				_, err := client.InsertMany(ctx, "vault", "cameras", []any{bson.M{"_id": 3, "created_at": explicit}})
+				return err
+			},
+			id:          3,
+			wantCreated: explicit,
+			wantUpdated: later,
+		},
+		{
+			name: "InsertZeroTimeStamped",
+			write: func(client DatabaseInterface) error {
+				type camera struct {
+					ID        int32     `bson:"_id"`
+					CreatedAt time.Time `bson:"created_at"`
+				}
+				_, err := client.InsertOne(ctx, "vault", "cameras", camera{ID: 3})
+				return err
+			},
+			id:          3,
+			wantCreated: later,
+			wantUpdated: later,
+		},
+		{
+			name: "BulkWrite",
+			write: func(client DatabaseInterface) error {
+				_, err := client.BulkWrite(ctx, "vault", "cameras", []any{
+					mongo.NewInsertOneModel().SetDocument(bson.M{"_id": 3}),
+					mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": 3}).SetUpdate(bson.M{"$set": bson.M{"name": "x"}}),
+				})
+				return err
+			},
+			id:          3,
+			wantCreated: later,
+			wantUpdated: later,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, _, client := setup(t)
+			if err := tt.write(client); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			doc := stored(fake, tt.id)
+			if !valuesEqual(doc["created_at"], tt.wantCreated) || !valuesEqual(doc["updated_at"], tt.wantUpdated) {
+				t.Errorf("expected created %v and updated %v, got %v", tt.wantCreated, tt.wantUpdated, doc)
+			}
+		})
+	}
+
+	t.Run("ReadsPassThrough", func(t *testing.T) {
+		_, _, client := setup(t)
+		if n, err := client.Count(ctx, "vault", "cameras", bson.M{}); err != nil || n != 1 {
+			t.Errorf("expected one document, got %d, %v", n, err)
+		}
+		if err := EnsureIndexes(ctx, client, "vault", "cameras", IndexSpec{Keys: bson.D{{Key: "name", Value: 1}}, Unique: true}); err != nil {
+			t.Errorf("expected index creation passed on, got %v", err)
+		}
+	})
+
+	t.Run("PipelineUpdateUnchanged", func(t *testing.T) {
+		mock := NewMockDatabase()
+		client := Wrap(mock, WithTimestamps(TimestampConfig{}))
+		pipeline := bson.A{bson.M{"$set": bson.M{"name": "x"}}}
+		client.UpdateOne(ctx, "vault", "cameras", bson.M{}, pipeline)
+		if call, _ := mock.LastUpdateOneCall(); !valuesEqual(normalizeDocument(call.Update), normalizeDocument(pipeline)) {
+			t.Errorf("expected the pipeline unchanged, got %v", call.Update)
+		}
+	})
+
+	t.Run("MockRecordsStampedUpdate", func(t *testing.T) {
+		mock := NewMockDatabase()
+		client := Wrap(mock, WithTimestamps(TimestampConfig{CreatedField: "createdAt", UpdatedField: "updatedAt", Clock: NewTestClock(created)}))
+		client.UpdateOne(ctx, "vault", "cameras", bson.M{"_id": 1}, U().Set("name", "x"), moptions.Update().SetUpsert(true))
+
+		call, _ := mock.LastUpdateOneCall()
+		want := map[string]any{
+			"$set":         map[string]any{"name": "x", "updatedAt": created},
+			"$setOnInsert": map[string]any{"createdAt": created},
+		}
+		if !FilterEquals(want)(call.Update) {
+			t.Errorf("expected %v, got %v", want, call.Update)
+		}
+	})
+}
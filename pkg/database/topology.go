@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// TopologyDescription is the driver's view of the deployment after a change
+type TopologyDescription struct {
+	// Kind is the kind of deployment, such as "ReplicaSetWithPrimary" or
+	// "Sharded"
+	Kind string
+	// SetName is the replica set name, if any
+	SetName string
+	Servers []ServerDescription
+	// Added and Removed are the addresses of the servers that joined and left
+	// the topology with this change
+	Added   []string
+	Removed []string
+}
+
+// ServerDescription is a server of a TopologyDescription
+type ServerDescription struct {
+	Address string
+	// Kind is the role of the server, such as "RSPrimary", "RSSecondary" or
+	// "Unknown" while it cannot be reached
+	Kind string
+}
+
+// SetSRVMaxHosts limits the hosts the client picks from the SRV record,
+// also when the driver polls it for changes. Only applies to mongodb+srv
+// connection strings, such as Atlas hosts.
+func (b *MongoOptionsBuilder) SetSRVMaxHosts(n int) *MongoOptionsBuilder {
+	b.options.SRVMaxHosts = n
+	return b
+}
+
+// SetSRVServiceName replaces the "mongodb" service name of the SRV record
+// lookup. Only applies to mongodb+srv connection strings, such as Atlas hosts.
+func (b *MongoOptionsBuilder) SetSRVServiceName(name string) *MongoOptionsBuilder {
+	b.options.SRVServiceName = name
+	return b
+}
+
+// OnTopologyChange calls fn with the new topology whenever the driver's view
+// of the deployment changes: servers joining or leaving, including through
+// the SRV polling of mongodb+srv connection strings, elections and servers
+// becoming unreachable. fn runs while the driver holds the topology lock, so
+// it must return quickly and must not run operations on the client.
+//
+//	database.NewMongoOptions().OnTopologyChange(func(t database.TopologyDescription) {
+//		log.Printf("topology %s: added %v, removed %v", t.Kind, t.Added, t.Removed)
+//	})
+func (b *MongoOptionsBuilder) OnTopologyChange(fn func(TopologyDescription)) *MongoOptionsBuilder {
+	b.options.TopologyChange = fn
+	return b
+}
+
+// topologyMonitor returns a server monitor that passes topology changes to fn
+func topologyMonitor(fn func(TopologyDescription)) *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: func(e *event.TopologyDescriptionChangedEvent) {
+			fn(newTopologyDescription(e.PreviousDescription, e.NewDescription))
+		},
+	}
+}
+
+func newTopologyDescription(previous description.Topology, next description.Topology) TopologyDescription {
+	t := TopologyDescription{Kind: next.Kind.String(), SetName: next.SetName}
+	before := make(map[string]bool, len(previous.Servers))
+	for _, s := range previous.Servers {
+		before[s.Addr.String()] = true
+	}
+	for _, s := range next.Servers {
+		address := s.Addr.String()
+		t.Servers = append(t.Servers, ServerDescription{Address: address, Kind: s.Kind.String()})
+		if !before[address] {
+			t.Added = append(t.Added, address)
+		}
+		delete(before, address)
+	}
+	t.Removed = slices.Sorted(maps.Keys(before))
+	return t
+}
+
+// validateSRV rejects the SRV options outside of a mongodb+srv connection
+// string
+func (o *MongoOptions) validateSRV() error {
+	if o.SRVMaxHosts == 0 && o.SRVServiceName == "" {
+		return nil
+	}
+	if !isSRV(o) {
+		return errors.New("mongo options: srvMaxHosts and srvServiceName only apply to a mongodb+srv URI or an Atlas host")
+	}
+	if o.SRVMaxHosts > 0 && o.ReplicaSet != "" {
+		return errors.New("mongo options: srvMaxHosts cannot be combined with a replica set name")
+	}
+	return nil
+}
+
+// srvQuery returns the URI options of the SRV settings. They must be part of
+// the URI, which the driver resolves before any other option applies.
+func (o *MongoOptions) srvQuery() url.Values {
+	query := url.Values{}
+	if o.SRVMaxHosts > 0 {
+		query.Set("srvMaxHosts", strconv.Itoa(o.SRVMaxHosts))
+	}
+	if o.SRVServiceName != "" {
+		query.Set("srvServiceName", o.SRVServiceName)
+	}
+	return query
+}
+
+// appendQuery adds the options of query to uri, refusing options uri already
+// sets
+func appendQuery(uri string, query url.Values) (string, error) {
+	if len(query) == 0 {
+		return uri, nil
+	}
+	_, rest, _ := strings.Cut(uri, "://")
+	path, existing, hasQuery := strings.Cut(rest, "?")
+	for key := range query {
+		for _, option := range strings.Split(existing, "&") {
+			name, _, _ := strings.Cut(option, "=")
+			if strings.EqualFold(name, key) {
+				return "", fmt.Errorf("mongo options: %s is set both in the URI and with the builder", key)
+			}
+		}
+	}
+	switch {
+	case hasQuery:
+		return uri + "&" + query.Encode(), nil
+	case strings.Contains(path, "/"):
+		return uri + "?" + query.Encode(), nil
+	default:
+		return uri + "/?" + query.Encode(), nil
+	}
+}
+
+// WatchHosts resolves the hosts of a seed list every interval and, when the
+// addresses they resolve to change, calls Reconnect so the client stops
+// talking to addresses that moved. It blocks until ctx ends, so run it in its
+// own goroutine for the life of the service:
+//
+//	go db.WatchHosts(ctx, time.Minute)
+//
+// A failed lookup or Reconnect is retried on the next tick. SRV connection
+// strings are polled by the driver instead, and Unix domain sockets do not
+// resolve, so both return an error.
+func (d *Database) WatchHosts(ctx context.Context, interval time.Duration) error {
+	return d.watchHosts(ctx, interval, net.DefaultResolver.LookupHost)
+}
+
+func (d *Database) watchHosts(ctx context.Context, interval time.Duration, lookup func(ctx context.Context, host string) ([]string, error)) error {
+	if interval <= 0 {
+		return errors.New("watch hosts: the interval must be positive")
+	}
+	hosts, err := d.Options.watchedHosts()
+	if err != nil {
+		return fmt.Errorf("watch hosts: %w", err)
+	}
+
+	var resolved map[string][]string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if addresses, err := resolveHosts(ctx, hosts, lookup); err == nil {
+			if resolved == nil {
+				resolved = addresses
+			} else if !maps.EqualFunc(resolved, addresses, slices.Equal) {
+				if err := d.Reconnect(ctx); err == nil {
+					resolved = addresses
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchedHosts returns the host names, without ports, of the seed list
+func (o *MongoOptions) watchedHosts() ([]string, error) {
+	if isSRV(o) {
+		return nil, errors.New("SRV connection strings are polled by the driver")
+	}
+	if o.SocketPath != "" {
+		return nil, errors.New("a Unix domain socket has no host to resolve")
+	}
+	seeds := o.seedList()
+	if o.Uri != "" {
+		cs, err := connstring.Parse(o.Uri)
+		if err != nil {
+			return nil, err
+		}
+		seeds = cs.Hosts
+	}
+	var hosts []string
+	for _, seed := range seeds {
+		host, _, err := net.SplitHostPort(seed)
+		if err != nil {
+			host = seed
+		}
+		if !isSocket(host) {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no host to resolve")
+	}
+	return hosts, nil
+}
+
+// resolveHosts returns the sorted addresses of each host
+func resolveHosts(ctx context.Context, hosts []string, lookup func(ctx context.Context, host string) ([]string, error)) (map[string][]string, error) {
+	resolved := make(map[string][]string, len(hosts))
+	for _, host := range hosts {
+		addresses, err := lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		resolved[host] = slices.Sorted(slices.Values(addresses))
+	}
+	return resolved, nil
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor is implemented by clients that run multi-document transactions
+type Transactor interface {
+	// WithTransaction runs fn in a transaction and commits it when fn
+	// returns nil; operations join the transaction by using txCtx
+	WithTransaction(ctx context.Context, fn func(txCtx context.Context) error) error
+}
+
+// transactionKey marks the contexts of fake and mock transactions
+type transactionKey struct{}
+
+// WithTransaction runs fn in a transaction of client, which must implement
+// Transactor as MongoClient, FakeDatabase and MockDatabase do. The writes fn
+// makes with txCtx are committed together when it returns nil and discarded
+// when it returns an error, which WithTransaction returns. fn may run more
+// than once when the server reports a transient error, so it should not have
+// effects outside the transaction. Transactions need a replica set or
+// sharded cluster.
+func WithTransaction(ctx context.Context, client DatabaseInterface, fn func(txCtx context.Context) error) error {
+	transactor, ok := implementation[Transactor](client)
+	if !ok {
+		return fmt.Errorf("client %T cannot run transactions", client)
+	}
+	return transactor.WithTransaction(ctx, fn)
+}
+
+// InTransaction reports whether ctx is the context of a transaction started
+// by WithTransaction
+func InTransaction(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if ctx.Value(transactionKey{}) != nil {
+		return true
+	}
+	return mongo.SessionFromContext(ctx) != nil
+}
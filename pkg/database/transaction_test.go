@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("FakeCommit", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		err := WithTransaction(ctx, fake, func(txCtx context.Context) error {
+			if !InTransaction(txCtx) {
+				t.Error("expected the context to be in a transaction")
+			}
+			_, err := fake.InsertOne(txCtx, "shop", "orders", bson.M{"_id": 1})
+			return err
+		})
+		if err != nil || len(fake.Documents("shop", "orders")) != 1 {
+			t.Errorf("expected the insert to be committed, got %v and %v", err, fake.Documents("shop", "orders"))
+		}
+	})
+
+	t.Run("FakeRollback", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		fake.Seed("shop", "orders", bson.M{"_id": 1, "status": "new"}, bson.M{"_id": 2, "status": "new"})
+		failure := errors.New("out of stock")
+		err := WithTransaction(ctx, fake, func(txCtx context.Context) error {
+			fake.InsertOne(txCtx, "shop", "orders", bson.M{"_id": 3})
+			fake.UpdateOne(txCtx, "shop", "orders", bson.M{"_id": 1}, bson.M{"$set": bson.M{"status": "paid"}})
+			fake.DeleteOne(txCtx, "shop", "orders", bson.M{"_id": 2})
+			fake.InsertOne(txCtx, "shop", "payments", bson.M{"_id": 1})
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("expected fn's error, got %v", err)
+		}
+		docs := fake.Documents("shop", "orders")
+		if len(docs) != 2 || docs[0]["status"] != "new" || docs[1]["_id"] != int32(2) {
+			t.Errorf("expected the orders to be restored, got %v", docs)
+		}
+		if payments := fake.Documents("shop", "payments"); len(payments) != 0 {
+			t.Errorf("expected no payments, got %v", payments)
+		}
+	})
+
+	t.Run("FakeNested", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		err := fake.WithTransaction(ctx, func(txCtx context.Context) error {
+			return fake.WithTransaction(txCtx, func(context.Context) error { return nil })
+		})
+		if err == nil || !strings.Contains(err.Error(), "nested") {
+			t.Errorf("expected a nested transaction to fail, got %v", err)
+		}
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		failure := errors.New("out of stock")
+		for _, want := range []error{nil, failure} {
+			err := WithTransaction(ctx, mock, func(txCtx context.Context) error {
+				mock.InsertOne(txCtx, "shop", "orders", bson.M{"_id": 1})
+				return want
+			})
+			if !errors.Is(err, want) {
+				t.Errorf("expected %v, got %v", want, err)
+			}
+		}
+		calls := mock.WithTransactionCalls
+		if len(calls) != 2 || !calls[0].Committed || calls[1].Committed || !errors.Is(calls[1].Err, failure) {
+			t.Errorf("expected a committed and an aborted transaction, got %+v", calls)
+		}
+		if len(mock.InsertOneCalls) != 2 || !InTransaction(mock.InsertOneCalls[0].Ctx) {
+			t.Errorf("expected the inserts to run in the transaction, got %+v", mock.InsertOneCalls)
+		}
+	})
+
+	t.Run("MockFault", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.On("WithTransaction", "", "").Return(nil, errors.New("no replica set"))
+		ran := false
+		if err := mock.WithTransaction(ctx, func(context.Context) error { ran = true; return nil }); err == nil || ran {
+			t.Errorf("expected the transaction to fail without running fn, got %v and ran=%v", err, ran)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if err := WithTransaction(ctx, Wrap(NewFakeDatabase(), WithReadOnly()), func(context.Context) error { return nil }); err != nil {
+			t.Errorf("expected decorators to be unwrapped, got %v", err)
+		}
+		client := struct{ DatabaseInterface }{NewFakeDatabase()}
+		if err := WithTransaction(ctx, client, func(context.Context) error { return nil }); err == nil || !strings.Contains(err.Error(), "cannot run transactions") {
+			t.Errorf("expected a client without transactions to be rejected, got %v", err)
+		}
+		if InTransaction(ctx) {
+			t.Error("expected a plain context not to be in a transaction")
+		}
+	})
+}
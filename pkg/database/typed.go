@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// FindAs runs Find on d.Client and decodes every result into T with the BSON
+// options of d.Options, so UUIDs, decimals and dates decode the same way
+// whether the client is the real one, the fake or the mock
+func FindAs[T any](ctx context.Context, d *Database, db string, collection string, filter any, opts ...any) ([]T, error) {
+	result, err := d.Client.Find(ctx, db, collection, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	docs, _ := result.([]any)
+	c := d.codec()
+	out := make([]T, len(docs))
+	for i, doc := range docs {
+		if err := c.decode(doc, &out[i]); err != nil {
+			return nil, fmt.Errorf("decode document %d into %T: %w", i, out[i], err)
+		}
+	}
+	return out, nil
+}
+
+// FindOneAs runs FindOne on d.Client and decodes the result into T like FindAs
+func FindOneAs[T any](ctx context.Context, d *Database, db string, collection string, filter any, opts ...any) (T, error) {
+	var out T
+	doc, err := d.Client.FindOne(ctx, db, collection, filter, opts...)
+	if err != nil {
+		return out, err
+	}
+	if err := d.codec().decode(doc, &out); err != nil {
+		return out, fmt.Errorf("decode document into %T: %w", out, err)
+	}
+	return out, nil
+}
+
+// FindInto runs FindCursor on d.Client and decodes the documents straight
+// into *results, reusing its backing array, so listing many documents does
+// not build a map for each of them. The real client decodes each document
+// from the wire; the fake and the mock decode with the BSON options of
+// d.Options like FindAs. On an error *results holds the documents decoded
+// before it.
+//
+//	var events []Event
+//	err := database.FindInto(ctx, db, "vault", "events", filter, &events)
+func FindInto[T any](ctx context.Context, d *Database, db string, collection string, filter any, results *[]T, opts ...any) error {
+	cursor, err := d.Client.FindCursor(ctx, db, collection, filter, opts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	c := d.codec()
+	docs, _ := cursor.(documentCursor)
+	out := (*results)[:0]
+	var buf []byte
+	var zero T
+	for cursor.Next(ctx) {
+		out = append(out, zero)
+		target := &out[len(out)-1]
+		if docs != nil {
+			buf, err = c.decodeAppend(buf, docs.document(), target)
+		} else {
+			err = cursor.Decode(target)
+		}
+		if err != nil {
+			*results = out[:len(out)-1]
+			return fmt.Errorf("decode document %d into %T: %w", len(out)-1, zero, err)
+		}
+	}
+	*results = out
+	return cursor.Err()
+}
+
+// codec returns the codec described by the database's BSON options, building
+// it again only when they change
+func (d *Database) codec() *codec {
+	var options *BSONOptions
+	if d.Options != nil {
+		options = d.Options.BSON
+	}
+	if c := d.bsonCodec.Load(); c != nil && c.options == options {
+		return c
+	}
+	c := newCodec(options)
+	d.bsonCodec.Store(c)
+	return c
+}
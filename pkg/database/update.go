@@ -0,0 +1,380 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// updateOrder fixes the order operators are applied in so results do not
+// depend on map iteration
+var updateOrder = []string{"$setOnInsert", "$set", "$unset", "$inc", "$push", "$addToSet", "$pull"}
+
+// applyUpdate applies a MongoDB update document to doc in place. $setOnInsert
+// only takes effect when inserting is true, as for an upsert. Unknown
+// operators return an error naming the operator.
+func applyUpdate(doc map[string]any, update any, inserting bool) error {
+	update, err := updateDocument(update)
+	if err != nil {
+		return err
+	}
+	ops, ok := normalizeDocument(update).(map[string]any)
+	if !ok {
+		return fmt.Errorf("update must be a document, got %T", update)
+	}
+	if len(ops) == 0 {
+		return fmt.Errorf("update document must not be empty")
+	}
+	for op := range ops {
+		if !strings.HasPrefix(op, "$") {
+			return fmt.Errorf("update document must contain only update operators, got %q", op)
+		}
+		if !isUpdateOperator(op) {
+			return fmt.Errorf("unsupported update operator %s", op)
+		}
+	}
+
+	for _, op := range updateOrder {
+		arg, ok := ops[op]
+		if !ok {
+			continue
+		}
+		fields, ok := arg.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s needs a document", op)
+		}
+		paths := sortedKeys(fields)
+		for _, path := range paths {
+			if path == "_id" || strings.HasPrefix(path, "_id.") {
+				if op != "$setOnInsert" && !(op == "$set" && inserting) {
+					return fmt.Errorf("%s would modify the immutable field '_id'", op)
+				}
+			}
+			if err := applyUpdateOperator(doc, op, path, fields[path], inserting); err != nil {
+				return fmt.Errorf("%s %s: %w", op, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isUpdateOperator(op string) bool {
+	for _, known := range updateOrder {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+func applyUpdateOperator(doc map[string]any, op string, path string, arg any, inserting bool) error {
+	switch op {
+	case "$setOnInsert":
+		if !inserting {
+			return nil
+		}
+		return setPath(doc, path, storedValue(arg))
+	case "$set":
+		return setPath(doc, path, storedValue(arg))
+	case "$unset":
+		unsetPath(doc, path)
+		return nil
+	case "$inc":
+		current, found := getPath(doc, path)
+		if !found {
+			current = int32(0)
+		}
+		sum, err := addNumbers(current, arg)
+		if err != nil {
+			return err
+		}
+		return setPath(doc, path, sum)
+	case "$push", "$addToSet":
+		values := []any{arg}
+		if m, ok := arg.(map[string]any); ok {
+			if each, ok := m["$each"]; ok {
+				list, ok := each.([]any)
+				if !ok || len(m) > 1 {
+					return fmt.Errorf("only $each is supported as a modifier and it needs an array")
+				}
+				values = list
+			}
+		}
+		current, found := getPath(doc, path)
+		arr, ok := current.([]any)
+		if found && !ok {
+			return fmt.Errorf("cannot apply %s to a non-array value", op)
+		}
+		for _, v := range values {
+			v = storedValue(v)
+			if op == "$addToSet" && containsEqual(arr, v) {
+				continue
+			}
+			arr = append(arr, v)
+		}
+		if arr == nil {
+			arr = []any{}
+		}
+		return setPath(doc, path, arr)
+	case "$pull":
+		current, found := getPath(doc, path)
+		if !found {
+			return nil
+		}
+		arr, ok := current.([]any)
+		if !ok {
+			return fmt.Errorf("cannot apply $pull to a non-array value")
+		}
+		kept := make([]any, 0, len(arr))
+		for _, elem := range arr {
+			remove, err := pullMatches(elem, arg)
+			if err != nil {
+				return err
+			}
+			if !remove {
+				kept = append(kept, elem)
+			}
+		}
+		return setPath(doc, path, kept)
+	}
+	return fmt.Errorf("unsupported update operator %s", op)
+}
+
+// pullMatches reports whether $pull removes elem: conditions use the query
+// operators, documents are matched as queries, other values by equality
+func pullMatches(elem any, cond any) (bool, error) {
+	if ops, ok := operatorDocument(cond); ok {
+		for op, arg := range ops {
+			if op == "$options" {
+				continue
+			}
+			matched, err := applyOperator(op, arg, ops, []any{elem}, true)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if query, ok := cond.(map[string]any); ok {
+		if doc, ok := elem.(map[string]any); ok {
+			return matchQuery(doc, query)
+		}
+		return false, nil
+	}
+	return valuesEqual(elem, cond), nil
+}
+
+// applyReplacement replaces every field of doc except _id with replacement
+func applyReplacement(doc map[string]any, replacement any) (map[string]any, error) {
+	fields, err := storedDocument(replacement)
+	if err != nil {
+		return nil, err
+	}
+	for k := range fields {
+		if strings.HasPrefix(k, "$") {
+			return nil, fmt.Errorf("replacement document must not contain update operators, got %q", k)
+		}
+	}
+	if id, ok := fields["_id"]; ok && doc["_id"] != nil && !valuesEqual(id, doc["_id"]) {
+		return nil, fmt.Errorf("replacement would modify the immutable field '_id'")
+	}
+	if id, ok := doc["_id"]; ok {
+		fields["_id"] = id
+	}
+	return fields, nil
+}
+
+// upsertSeed builds the document an upsert starts from: the equality
+// conditions of the filter, including those nested in $and
+func upsertSeed(filter any) map[string]any {
+	seed := map[string]any{}
+	query, _ := normalizeDocument(filter).(map[string]any)
+	collectEqualities(seed, query)
+	return seed
+}
+
+func collectEqualities(seed map[string]any, query map[string]any) {
+	for _, key := range sortedKeys(query) {
+		cond := query[key]
+		switch {
+		case key == "$and":
+			clauses, _ := cond.([]any)
+			for _, clause := range clauses {
+				if m, ok := clause.(map[string]any); ok {
+					collectEqualities(seed, m)
+				}
+			}
+		case strings.HasPrefix(key, "$"):
+		default:
+			if ops, ok := operatorDocument(cond); ok {
+				if eq, ok := ops["$eq"]; ok {
+					setPath(seed, key, storedValue(eq))
+				}
+				continue
+			}
+			setPath(seed, key, storedValue(cond))
+		}
+	}
+}
+
+// storedValue converts a value into the form documents are stored in by
+// round-tripping it through BSON, so dates and structs match seeded data
+func storedValue(v any) any {
+	raw, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return normalizeDocument(v)
+	}
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return normalizeDocument(v)
+	}
+	return normalizeDocument(m["v"])
+}
+
+// storedDocument converts doc into its stored form without assigning an _id
+func storedDocument(doc any) (map[string]any, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return normalizeDocument(m).(map[string]any), nil
+}
+
+// addNumbers adds two numbers, keeping integer types when both are integers
+func addNumbers(a, b any) (any, error) {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if !aok || !bok {
+		return nil, fmt.Errorf("cannot increment %T by %T", a, b)
+	}
+	if isInteger(a) && isInteger(b) {
+		sum := int64(af) + int64(bf)
+		if _, ok := a.(int32); ok && sum >= -1<<31 && sum < 1<<31 {
+			if _, ok := b.(int64); !ok {
+				return int32(sum), nil
+			}
+		}
+		return sum, nil
+	}
+	return af + bf, nil
+}
+
+func isInteger(v any) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+func containsEqual(arr []any, v any) bool {
+	for _, elem := range arr {
+		if valuesEqual(elem, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPath returns the value at a dotted path, indexing arrays numerically
+func getPath(doc map[string]any, path string) (any, bool) {
+	var current any = doc
+	for _, part := range strings.Split(path, ".") {
+		switch t := current.(type) {
+		case map[string]any:
+			v, ok := t[part]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []any:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return nil, false
+			}
+			current = t[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setPath sets the value at a dotted path, creating intermediate documents
+func setPath(doc map[string]any, path string, value any) error {
+	parts := strings.Split(path, ".")
+	var current any = doc
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch t := current.(type) {
+		case map[string]any:
+			if last {
+				t[part] = value
+				return nil
+			}
+			next, ok := t[part]
+			if !ok || next == nil {
+				next = map[string]any{}
+				t[part] = next
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return fmt.Errorf("cannot create field %q in array", part)
+			}
+			if last {
+				t[idx] = value
+				return nil
+			}
+			current = t[idx]
+		default:
+			return fmt.Errorf("cannot create field %q in %T", part, current)
+		}
+	}
+	return nil
+}
+
+// unsetPath removes the value at a dotted path, if present
+func unsetPath(doc map[string]any, path string) {
+	var parent any = doc
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		p, ok := getPath(doc, path[:i])
+		if !ok {
+			return
+		}
+		parent, path = p, path[i+1:]
+	}
+	if m, ok := parent.(map[string]any); ok {
+		delete(m, path)
+	}
+}
+
+// copyDocument deep-copies a stored document
+func copyDocument(doc map[string]any) map[string]any {
+	return copyValue(doc).(map[string]any)
+}
+
+func copyValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[k] = copyValue(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = copyValue(val)
+		}
+		return out
+	}
+	return v
+}
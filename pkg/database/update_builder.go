@@ -0,0 +1,169 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrConflictingUpdate is returned when an update targets the same field, or
+// a field and one of its subfields, with different operators. MongoDB rejects
+// such updates at runtime.
+var ErrConflictingUpdate = errors.New("conflicting update")
+
+// UpdateBuilder provides a fluent interface for building update documents.
+// UpdateOne, UpdateMany and FindOneAndUpdate accept it directly, and the mock
+// records it as passed so tests can inspect the operators and fields.
+type UpdateBuilder struct {
+	operators []updateOperator
+	err       error
+}
+
+type updateOperator struct {
+	name   string
+	fields bson.D
+}
+
+// U creates a new update builder
+func U() *UpdateBuilder {
+	return &UpdateBuilder{}
+}
+
+// Set sets field to value
+func (u *UpdateBuilder) Set(field string, value any) *UpdateBuilder {
+	return u.add("$set", field, value)
+}
+
+// Unset removes the fields
+func (u *UpdateBuilder) Unset(fields ...string) *UpdateBuilder {
+	for _, field := range fields {
+		u.add("$unset", field, "")
+	}
+	return u
+}
+
+// Inc increments field by amount
+func (u *UpdateBuilder) Inc(field string, amount any) *UpdateBuilder {
+	return u.add("$inc", field, amount)
+}
+
+// Push appends value to the array in field
+func (u *UpdateBuilder) Push(field string, value any) *UpdateBuilder {
+	return u.add("$push", field, value)
+}
+
+// Pull removes the elements of the array in field that equal value or match
+// it as a condition
+func (u *UpdateBuilder) Pull(field string, value any) *UpdateBuilder {
+	return u.add("$pull", field, value)
+}
+
+// SetOnInsert sets field to value only when an upsert inserts a document
+func (u *UpdateBuilder) SetOnInsert(field string, value any) *UpdateBuilder {
+	return u.add("$setOnInsert", field, value)
+}
+
+// add targets field with operator. Targeting it again with the same operator
+// replaces the value; any other overlap is a conflict reported by Build.
+func (u *UpdateBuilder) add(operator string, field string, value any) *UpdateBuilder {
+	for i := range u.operators {
+		op := &u.operators[i]
+		for j, e := range op.fields {
+			if op.name == operator && e.Key == field {
+				op.fields[j].Value = value
+				return u
+			}
+			if u.err == nil && pathsOverlap(e.Key, field) {
+				u.err = fmt.Errorf("%w: %s %q and %s %q", ErrConflictingUpdate, op.name, e.Key, operator, field)
+			}
+		}
+	}
+	for i := range u.operators {
+		if u.operators[i].name == operator {
+			u.operators[i].fields = append(u.operators[i].fields, bson.E{Key: field, Value: value})
+			return u
+		}
+	}
+	u.operators = append(u.operators, updateOperator{name: operator, fields: bson.D{{Key: field, Value: value}}})
+	return u
+}
+
+// pathsOverlap reports whether a and b are the same field or one contains the other
+func pathsOverlap(a string, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// Build returns the update document, with operators in the order first used,
+// or an error matching ErrConflictingUpdate
+func (u *UpdateBuilder) Build() (bson.D, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	if len(u.operators) == 0 {
+		return nil, errors.New("update has no operators")
+	}
+	out := make(bson.D, len(u.operators))
+	for i, op := range u.operators {
+		out[i] = bson.E{Key: op.name, Value: append(bson.D(nil), op.fields...)}
+	}
+	return out, nil
+}
+
+// MarshalBSON encodes the built update document, so the builder can be used
+// wherever the driver marshals an update
+func (u *UpdateBuilder) MarshalBSON() ([]byte, error) {
+	doc, err := u.Build()
+	if err != nil {
+		return nil, err
+	}
+	return bson.Marshal(doc)
+}
+
+// Operators returns the operators used, in the order first used
+func (u *UpdateBuilder) Operators() []string {
+	out := make([]string, len(u.operators))
+	for i, op := range u.operators {
+		out[i] = op.name
+	}
+	return out
+}
+
+// Fields returns the fields targeted by operator, such as "$set", in the
+// order added
+func (u *UpdateBuilder) Fields(operator string) []string {
+	var out []string
+	for _, op := range u.operators {
+		if op.name == operator {
+			for _, e := range op.fields {
+				out = append(out, e.Key)
+			}
+		}
+	}
+	return out
+}
+
+// Value returns the value operator applies to field
+func (u *UpdateBuilder) Value(operator string, field string) (any, bool) {
+	for _, op := range u.operators {
+		if op.name != operator {
+			continue
+		}
+		for _, e := range op.fields {
+			if e.Key == field {
+				return e.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// updateDocument builds an UpdateBuilder so its error is returned as is
+// rather than as a marshalling failure; other updates pass through
+func updateDocument(update any) (any, error) {
+	if b, ok := update.(*UpdateBuilder); ok {
+		return b.Build()
+	}
+	return update, nil
+}
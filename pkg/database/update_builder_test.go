@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestUpdateBuilder(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		builder *UpdateBuilder
+		want    bson.D
+		wantErr error
+	}{
+		{
+			name:    "OperatorsInFirstUseOrder",
+			builder: U().Set("name", "cam").Inc("views", 1).Push("tags", "new").SetOnInsert("created_at", now).Set("site", "a"),
+			want: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "name", Value: "cam"}, {Key: "site", Value: "a"}}},
+				{Key: "$inc", Value: bson.D{{Key: "views", Value: 1}}},
+				{Key: "$push", Value: bson.D{{Key: "tags", Value: "new"}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "created_at", Value: now}}},
+			},
+		},
+		{
+			name:    "UnsetAndPull",
+			builder: U().Unset("a", "b").Pull("tags", "old"),
+			want: bson.D{
+				{Key: "$unset", Value: bson.D{{Key: "a", Value: ""}, {Key: "b", Value: ""}}},
+				{Key: "$pull", Value: bson.D{{Key: "tags", Value: "old"}}},
+			},
+		},
+		{
+			name:    "SameOperatorReplacesValue",
+			builder: U().Set("name", "a").Set("name", "b"),
+			want:    bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "b"}}}},
+		},
+		{name: "SameFieldDifferentOperators", builder: U().Set("views", 0).Inc("views", 1), wantErr: ErrConflictingUpdate},
+		{name: "ParentAndChild", builder: U().Set("address", bson.M{}).Set("address.city", "Ghent"), wantErr: ErrConflictingUpdate},
+		{name: "ChildAndParent", builder: U().Unset("meta.a").Set("meta", nil), wantErr: ErrConflictingUpdate},
+		{
+			name:    "SharedPrefixIsNotAConflict",
+			builder: U().Set("tag", 1).Inc("tags", 1),
+			want: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "tag", Value: 1}}},
+				{Key: "$inc", Value: bson.D{{Key: "tags", Value: 1}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.builder.Build()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !valuesEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v, %v", tt.want, got, err)
+			}
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := U().Build(); err == nil {
+			t.Error("expected an error for an update without operators")
+		}
+	})
+
+	t.Run("Accessors", func(t *testing.T) {
+		u := U().Set("name", "cam").Inc("views", 1).Set("site", "a")
+		if ops := u.Operators(); len(ops) != 2 || ops[0] != "$set" || ops[1] != "$inc" {
+			t.Errorf("expected [$set $inc], got %v", ops)
+		}
+		if fields := u.Fields("$set"); len(fields) != 2 || fields[0] != "name" || fields[1] != "site" {
+			t.Errorf("expected [name site], got %v", fields)
+		}
+		if v, ok := u.Value("$inc", "views"); !ok || v != 1 {
+			t.Errorf("expected 1, got %v, %v", v, ok)
+		}
+	})
+
+	t.Run("MarshalBSON", func(t *testing.T) {
+		var decoded bson.M
+		data, err := bson.Marshal(U().Set("name", "cam"))
+		if err != nil || bson.Unmarshal(data, &decoded) != nil || decoded["$set"] == nil {
+			t.Errorf("expected the built document, got %v, %v", decoded, err)
+		}
+		if _, err := bson.Marshal(U().Set("a", 1).Inc("a", 1)); !errors.Is(err, ErrConflictingUpdate) {
+			t.Errorf("expected ErrConflictingUpdate, got %v", err)
+		}
+	})
+}
+
+func TestUpdateBuilderWithClients(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fake", func(t *testing.T) {
+		fake := seededFake(t)
+		res, err := fake.UpdateOne(ctx, "testdb", "users", bson.M{"_id": 1}, U().Set("name", "ann").Inc("age", 1).Push("tags", "dev"))
+		if err != nil || res.ModifiedCount != 1 {
+			t.Fatalf("expected one modified document, got %v, %v", res, err)
+		}
+		doc := fake.Documents("testdb", "users")[0]
+		if doc["name"] != "ann" || doc["age"] != int32(32) || len(doc["tags"].(bson.A)) != 3 {
+			t.Errorf("expected the update applied, got %v", doc)
+		}
+
+		upserted, err := fake.FindOneAndUpdate(ctx, "testdb", "users", bson.M{"_id": 9},
+			U().Set("name", "zoe").SetOnInsert("joined", "today"),
+			moptions.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(moptions.After))
+		if err != nil || upserted.(bson.M)["joined"] != "today" {
+			t.Errorf("expected $setOnInsert applied on upsert, got %v, %v", upserted, err)
+		}
+
+		if _, err := fake.UpdateMany(ctx, "testdb", "users", bson.M{}, U().Set("age", 1).Inc("age", 1)); !errors.Is(err, ErrConflictingUpdate) {
+			t.Errorf("expected ErrConflictingUpdate, got %v", err)
+		}
+	})
+
+	t.Run("MockRecordsTheBuilder", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.UpdateOne(ctx, "testdb", "users", bson.M{"_id": 1}, U().Set("name", "ann").Set("site", "a"))
+
+		call, _ := mock.LastUpdateOneCall()
+		update, ok := call.Update.(*UpdateBuilder)
+		if !ok {
+			t.Fatalf("expected the builder recorded, got %T", call.Update)
+		}
+		if fields := update.Fields("$set"); len(fields) != 2 || len(update.Operators()) != 1 {
+			t.Errorf("expected exactly name and site set, got %v", update.Operators())
+		}
+		if !FilterContains(map[string]any{"$set": map[string]any{"name": "ann"}})(call.Update) {
+			t.Error("expected filter matchers to see the built document")
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	base := func() map[string]any {
+		return normalizeDocument(bson.M{
+			"_id":     1,
+			"name":    "alice",
+			"visits":  int32(2),
+			"tags":    bson.A{"admin", "ops", "dev"},
+			"scores":  bson.A{3, 7, 9},
+			"items":   bson.A{bson.M{"sku": "a", "qty": 1}, bson.M{"sku": "b", "qty": 0}},
+			"profile": bson.M{"city": "Ghent"},
+		}).(map[string]any)
+	}
+
+	tests := []struct {
+		name      string
+		update    any
+		inserting bool
+		want      map[string]any
+		wantErr   string
+	}{
+		{"Set", bson.M{"$set": bson.M{"name": "bob"}}, false, map[string]any{"name": "bob"}, ""},
+		{"SetNestedCreatesDocuments", bson.M{"$set": bson.M{"address.zip": "9000"}}, false, map[string]any{"address": map[string]any{"zip": "9000"}}, ""},
+		{"SetArrayElement", bson.M{"$set": bson.M{"tags.1": "sre"}}, false, map[string]any{"tags": []any{"admin", "sre", "dev"}}, ""},
+		{"Unset", bson.M{"$unset": bson.M{"profile.city": ""}}, false, map[string]any{"profile": map[string]any{}}, ""},
+		{"Inc", bson.M{"$inc": bson.M{"visits": 3}}, false, map[string]any{"visits": int32(5)}, ""},
+		{"IncMissingField", bson.M{"$inc": bson.M{"logins": 1}}, false, map[string]any{"logins": int32(1)}, ""},
+		{"IncFloat", bson.M{"$inc": bson.M{"visits": 0.5}}, false, map[string]any{"visits": 2.5}, ""},
+		{"Push", bson.M{"$push": bson.M{"tags": "qa"}}, false, map[string]any{"tags": []any{"admin", "ops", "dev", "qa"}}, ""},
+		{"PushEach", bson.M{"$push": bson.M{"tags": bson.M{"$each": bson.A{"x", "y"}}}}, false, map[string]any{"tags": []any{"admin", "ops", "dev", "x", "y"}}, ""},
+		{"PushCreatesArray", bson.M{"$push": bson.M{"history": "created"}}, false, map[string]any{"history": []any{"created"}}, ""},
+		{"AddToSetSkipsExisting", bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": bson.A{"ops", "qa"}}}}, false, map[string]any{"tags": []any{"admin", "ops", "dev", "qa"}}, ""},
+		{"PullValue", bson.M{"$pull": bson.M{"tags": "ops"}}, false, map[string]any{"tags": []any{"admin", "dev"}}, ""},
+		{"PullCondition", bson.M{"$pull": bson.M{"scores": bson.M{"$gte": 7}}}, false, map[string]any{"scores": []any{3}}, ""},
+		{"PullDocumentQuery", bson.M{"$pull": bson.M{"items": bson.M{"qty": 0}}}, false, map[string]any{"items": []any{map[string]any{"sku": "a", "qty": 1}}}, ""},
+		{"SetOnInsertIgnoredOnUpdate", bson.M{"$setOnInsert": bson.M{"created": true}}, false, map[string]any{"name": "alice"}, ""},
+		{"SetOnInsertAppliedOnInsert", bson.M{"$setOnInsert": bson.M{"created": true}}, true, map[string]any{"created": true}, ""},
+		{"UnknownOperator", bson.M{"$rename": bson.M{"name": "fullName"}}, false, nil, "unsupported update operator $rename"},
+		{"ReplacementDocument", bson.M{"name": "bob"}, false, nil, "only update operators"},
+		{"ImmutableID", bson.M{"$set": bson.M{"_id": 2}}, false, nil, "immutable field '_id'"},
+		{"PushToNonArray", bson.M{"$push": bson.M{"name": "x"}}, false, nil, "non-array"},
+		{"IncNonNumber", bson.M{"$inc": bson.M{"name": 1}}, false, nil, "cannot increment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := base()
+			err := applyUpdate(doc, tt.update, tt.inserting)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			for key, want := range tt.want {
+				if got := doc[key]; !valuesEqual(got, want) {
+					t.Errorf("%s: expected %#v, got %#v", key, want, got)
+				}
+			}
+		})
+	}
+}
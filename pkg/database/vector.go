@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// vectorScoreField is the field VectorSearch projects the similarity score
+// into and removes again before returning the documents
+const vectorScoreField = "_vectorScore"
+
+// VectorSearchRequest describes an Atlas Vector Search query
+type VectorSearchRequest struct {
+	// Index is the name of the Atlas Vector Search index
+	Index string
+	// Path is the field holding the embeddings
+	Path string
+	// QueryVector is the embedding to search for
+	QueryVector []float32
+	// NumCandidates is the number of nearest neighbours considered; more
+	// candidates improve accuracy at the cost of latency
+	NumCandidates int64
+	// Limit is the number of documents returned, at most NumCandidates
+	Limit int64
+	// Filter optionally restricts the candidates to documents matching it;
+	// its fields must be indexed as filter fields
+	Filter any
+	// Dimensions is the number of dimensions the index is configured with.
+	// When set, query vectors of another length are rejected before they
+	// reach the server.
+	Dimensions int
+}
+
+// VectorSearch returns the documents of db.collection nearest to
+// req.QueryVector, most similar first, using the $vectorSearch stage of
+// Atlas. FakeDatabase does not support the stage, so queue results on the
+// mock instead; AggregateCall.VectorSearch returns the recorded request.
+func VectorSearch(ctx context.Context, client DatabaseInterface, db string, collection string, req VectorSearchRequest) ([]ScoredDocument, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	stage := bson.D{
+		{Key: "index", Value: req.Index},
+		{Key: "path", Value: req.Path},
+		{Key: "queryVector", Value: req.QueryVector},
+		{Key: "numCandidates", Value: req.NumCandidates},
+		{Key: "limit", Value: req.Limit},
+	}
+	if req.Filter != nil {
+		stage = append(stage, bson.E{Key: "filter", Value: req.Filter})
+	}
+	pipeline := bson.A{
+		bson.D{{Key: "$vectorSearch", Value: stage}},
+		bson.D{{Key: "$addFields", Value: bson.D{{Key: vectorScoreField, Value: bson.D{{Key: "$meta", Value: "vectorSearchScore"}}}}}},
+	}
+
+	result, err := client.Aggregate(ctx, db, collection, pipeline)
+	if err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "dimension") {
+			return nil, err
+		}
+		if req.Dimensions == 0 {
+			return nil, fmt.Errorf("vector search on %s: query vector has %d dimensions, which index %s does not accept: %w",
+				req.Path, len(req.QueryVector), req.Index, err)
+		}
+		return nil, fmt.Errorf("vector search on %s: query vector has %d dimensions, index %s is configured with %d: %w",
+			req.Path, len(req.QueryVector), req.Index, req.Dimensions, err)
+	}
+
+	docs, _ := result.([]any)
+	out := make([]ScoredDocument, len(docs))
+	for i, doc := range docs {
+		out[i] = scoredDocument(doc, vectorScoreField)
+	}
+	return out, nil
+}
+
+func (r VectorSearchRequest) validate() error {
+	switch {
+	case r.Index == "":
+		return errors.New("vector search index is required")
+	case r.Path == "":
+		return errors.New("vector search path is required")
+	case len(r.QueryVector) == 0:
+		return errors.New("vector search query vector is empty")
+	case r.Limit <= 0:
+		return fmt.Errorf("vector search limit must be positive, got %d", r.Limit)
+	case r.Limit > r.NumCandidates:
+		return fmt.Errorf("vector search limit %d exceeds numCandidates %d", r.Limit, r.NumCandidates)
+	case r.Dimensions > 0 && len(r.QueryVector) != r.Dimensions:
+		return fmt.Errorf("vector search query vector has %d dimensions, index %s is configured with %d", len(r.QueryVector), r.Index, r.Dimensions)
+	}
+	return nil
+}
+
+// VectorSearchCall is the $vectorSearch stage of a recorded Aggregate call
+type VectorSearchCall struct {
+	Index         string
+	Path          string
+	Dimensions    int
+	NumCandidates int64
+	Limit         int64
+	// Filter is the pre-filter normalized to map[string]any, nil when unset
+	Filter any
+}
+
+// VectorSearch returns the $vectorSearch stage of the recorded pipeline, so
+// tests can assert the shape of a request without the query vector itself
+func (c AggregateCall) VectorSearch() (VectorSearchCall, bool) {
+	spec, ok := c.Stage("$vectorSearch")
+	if !ok {
+		return VectorSearchCall{}, false
+	}
+	fields, _ := spec.(map[string]any)
+	call := VectorSearchCall{Filter: fields["filter"]}
+	call.Index, _ = fields["index"].(string)
+	call.Path, _ = fields["path"].(string)
+	if vector, ok := fields["queryVector"].([]any); ok {
+		call.Dimensions = len(vector)
+	}
+	if n, ok := toFloat(fields["numCandidates"]); ok {
+		call.NumCandidates = int64(n)
+	}
+	if n, ok := toFloat(fields["limit"]); ok {
+		call.Limit = int64(n)
+	}
+	return call, true
+}
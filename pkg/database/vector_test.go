@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func vectorRequest() VectorSearchRequest {
+	return VectorSearchRequest{
+		Index:         "events_embedding",
+		Path:          "embedding",
+		QueryVector:   []float32{0.1, 0.2, 0.3},
+		NumCandidates: 100,
+		Limit:         10,
+	}
+}
+
+func TestVectorSearchValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *VectorSearchRequest)
+		wantErr string
+	}{
+		{"MissingIndex", func(r *VectorSearchRequest) { r.Index = "" }, "index is required"},
+		{"MissingPath", func(r *VectorSearchRequest) { r.Path = "" }, "path is required"},
+		{"EmptyVector", func(r *VectorSearchRequest) { r.QueryVector = nil }, "empty"},
+		{"ZeroLimit", func(r *VectorSearchRequest) { r.Limit = 0 }, "must be positive"},
+		{"LimitAboveCandidates", func(r *VectorSearchRequest) { r.Limit = 101 }, "exceeds numCandidates"},
+		{"DimensionMismatch", func(r *VectorSearchRequest) { r.Dimensions = 1536 }, "configured with 1536"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockDatabase()
+			req := vectorRequest()
+			tt.modify(&req)
+			_, err := VectorSearch(context.Background(), mock, "vault", "events", req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if len(mock.AggregateCalls) != 0 {
+				t.Error("expected an invalid request not to reach the client")
+			}
+		})
+	}
+}
+
+func TestVectorSearch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("RecordsRequestShape", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueAggregate([]any{
+			bson.M{"_id": 1, vectorScoreField: 0.93},
+			bson.M{"_id": 2, vectorScoreField: 0.71},
+		}, nil)
+
+		req := vectorRequest()
+		req.Filter = bson.M{"site": "ghent"}
+		results, err := VectorSearch(ctx, mock, "vault", "events", req)
+		if err != nil || len(results) != 2 || results[0].Score != 0.93 {
+			t.Fatalf("expected two scored results, got %v, %v", results, err)
+		}
+		if _, ok := results[0].Document.(bson.M)[vectorScoreField]; ok {
+			t.Error("expected the score field removed from the document")
+		}
+
+		call, _ := mock.LastAggregateCall()
+		got, ok := call.VectorSearch()
+		want := VectorSearchCall{
+			Index:         "events_embedding",
+			Path:          "embedding",
+			Dimensions:    3,
+			NumCandidates: 100,
+			Limit:         10,
+			Filter:        map[string]any{"site": "ghent"},
+		}
+		if !ok || !valuesEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+		if _, ok := call.Stage("$addFields"); !ok {
+			t.Error("expected the score projected with $addFields")
+		}
+	})
+
+	t.Run("NoVectorSearchStage", func(t *testing.T) {
+		if _, ok := (AggregateCall{}).VectorSearch(); ok {
+			t.Error("expected no $vectorSearch stage")
+		}
+	})
+
+	t.Run("DimensionErrorIsWrapped", func(t *testing.T) {
+		serverErr := errors.New("vector field is indexed with 1536 dimensions but queried with 3")
+		mock := NewMockDatabase()
+		mock.QueueAggregate(nil, serverErr)
+
+		_, err := VectorSearch(ctx, mock, "vault", "events", vectorRequest())
+		if !errors.Is(err, serverErr) || !strings.Contains(err.Error(), "query vector has 3 dimensions") {
+			t.Errorf("expected the server error wrapped with the query dimensions, got %v", err)
+		}
+	})
+
+	t.Run("OtherErrorsPassThrough", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueAggregate(nil, ErrUnauthorized)
+
+		if _, err := VectorSearch(ctx, mock, "vault", "events", vectorRequest()); err != ErrUnauthorized {
+			t.Errorf("expected ErrUnauthorized unchanged, got %v", err)
+		}
+	})
+}
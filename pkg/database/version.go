@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VersionField is the field UpdateWithVersion and InsertWithVersion keep the
+// document version in
+const VersionField = "version"
+
+// ErrVersionConflict is returned by UpdateWithVersion when the document
+// exists but was changed since the expected version was read
+var ErrVersionConflict = errors.New("version conflict")
+
+// InsertWithVersion inserts document with its version set to 1, replacing
+// any version it already has
+func InsertWithVersion(ctx context.Context, client DatabaseInterface, db string, collection string, document any, opts ...any) (any, error) {
+	doc, err := documentD(document)
+	if err != nil {
+		return nil, fmt.Errorf("insert with version: %w", err)
+	}
+	doc = setField(doc, VersionField, int64(1))
+	return client.InsertOne(ctx, db, collection, doc, opts...)
+}
+
+// UpdateWithVersion applies update to the document with _id id only if it
+// is still at expectedVersion, and increments its version. update must use
+// operators, as a bson.M, bson.D or UpdateBuilder, and must not touch the
+// version itself. It returns an error matching ErrVersionConflict when the
+// document has moved on, so the caller can re-read it and retry, and
+// mongo.ErrNoDocuments when there is no such document.
+//
+//	for {
+//		cfg, err := load(ctx, id)
+//		...
+//		err = database.UpdateWithVersion(ctx, db.Client, "vault", "config", id, cfg.Version, database.U().Set("retention", days))
+//		if !errors.Is(err, database.ErrVersionConflict) {
+//			return err
+//		}
+//	}
+func UpdateWithVersion(ctx context.Context, client DatabaseInterface, db string, collection string, id any, expectedVersion int64, update any) error {
+	versioned, err := versionedUpdate(update)
+	if err != nil {
+		return fmt.Errorf("update with version: %w", err)
+	}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: VersionField, Value: expectedVersion}}
+	res, err := client.UpdateOne(ctx, db, collection, filter, versioned)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+	return versionMiss(ctx, client, db, collection, bson.D{{Key: "_id", Value: id}}, expectedVersion)
+}
+
+// versionMiss explains why a versioned write matched nothing: it returns
+// mongo.ErrNoDocuments when no document matches filter and an error
+// matching ErrVersionConflict when one does at another version
+func versionMiss(ctx context.Context, client DatabaseInterface, db string, collection string, filter bson.D, expectedVersion int64) error {
+	current, err := client.FindOne(ctx, db, collection, filter,
+		moptions.FindOne().SetProjection(bson.D{{Key: VersionField, Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var version any
+	if doc, ok := normalizeDocument(current).(map[string]any); ok {
+		version = doc[VersionField]
+	}
+	return fmt.Errorf("%w: %v is at version %v, expected %d", ErrVersionConflict, filter[0].Value, version, expectedVersion)
+}
+
+// versionedUpdate returns update with $inc: {version: 1} added
+func versionedUpdate(update any) (bson.D, error) {
+	built, err := updateDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := documentD(built)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, errors.New("update has no operators")
+	}
+	for _, op := range doc {
+		if !strings.HasPrefix(op.Key, "$") {
+			return nil, fmt.Errorf("update must use operators, got field %q", op.Key)
+		}
+		fields, _ := op.Value.(bson.D)
+		for _, field := range fields {
+			if pathsOverlap(field.Key, VersionField) {
+				return nil, fmt.Errorf("%w: %s %q and the version increment", ErrConflictingUpdate, op.Key, field.Key)
+			}
+		}
+	}
+	return withOperatorField(doc, "$inc", VersionField, int64(1)), nil
+}
+
+// documentD converts doc into a bson.D via a BSON round trip, keeping
+// the field order
+func documentD(doc any) (bson.D, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out bson.D
+	if err := bson.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// setField sets key in doc, in place when present and at the end otherwise
+func setField(doc bson.D, key string, value any) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			doc[i].Value = value
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestUpdateWithVersion(t *testing.T) {
+	ctx := context.Background()
+
+	newFake := func(t *testing.T) *FakeDatabase {
+		t.Helper()
+		fake := NewFakeDatabase()
+		if _, err := InsertWithVersion(ctx, fake, "vault", "config", bson.M{"_id": "site-a", "retention": 7, "version": 9}); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+		return fake
+	}
+
+	tests := []struct {
+		name        string
+		id          any
+		expected    int64
+		update      any
+		wantErr     error
+		wantVersion int64
+	}{
+		{"Applies", "site-a", 1, bson.M{"$set": bson.M{"retention": 30}}, nil, 2},
+		{"Builder", "site-a", 1, U().Set("retention", 30).Inc("edits", 1), nil, 2},
+		{"StaleVersion", "site-a", 0, bson.M{"$set": bson.M{"retention": 30}}, ErrVersionConflict, 1},
+		{"Missing", "site-b", 1, bson.M{"$set": bson.M{"retention": 30}}, mongo.ErrNoDocuments, 1},
+		{"TouchesVersion", "site-a", 1, bson.M{"$set": bson.M{"version": 5}}, ErrConflictingUpdate, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFake(t)
+			err := UpdateWithVersion(ctx, fake, "vault", "config", tt.id, tt.expected, tt.update)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			doc := fake.Documents("vault", "config")[0]
+			if doc[VersionField] != tt.wantVersion {
+				t.Errorf("expected version %d, got %v", tt.wantVersion, doc[VersionField])
+			}
+			if tt.wantErr == nil && doc["retention"] != int32(30) {
+				t.Errorf("expected the update applied, got %v", doc)
+			}
+		})
+	}
+
+	t.Run("ReplacementRejected", func(t *testing.T) {
+		err := UpdateWithVersion(ctx, newFake(t), "vault", "config", "site-a", 1, bson.M{"retention": 30})
+		if err == nil {
+			t.Error("expected an error for a replacement document")
+		}
+	})
+
+	t.Run("MergesIntoExistingInc", func(t *testing.T) {
+		got, err := versionedUpdate(bson.D{{Key: "$inc", Value: bson.D{{Key: "edits", Value: 1}}}})
+		want := bson.D{{Key: "$inc", Value: bson.D{{Key: "edits", Value: int32(1)}, {Key: VersionField, Value: int64(1)}}}}
+		if err != nil || !valuesEqual(normalizeDocument(got), normalizeDocument(want)) {
+			t.Errorf("expected %v, got %v, %v", want, got, err)
+		}
+	})
+
+	t.Run("ConcurrentRetries", func(t *testing.T) {
+		fake := NewFakeDatabase()
+		if _, err := InsertWithVersion(ctx, fake, "vault", "config", bson.M{"_id": "site-a", "edits": 0}); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+
+		const workers = 20
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					doc, err := fake.FindOne(ctx, "vault", "config", bson.M{"_id": "site-a"})
+					if err != nil {
+						t.Errorf("failed to read: %v", err)
+						return
+					}
+					version := doc.(bson.M)[VersionField].(int64)
+					err = UpdateWithVersion(ctx, fake, "vault", "config", "site-a", version, U().Inc("edits", 1))
+					if !errors.Is(err, ErrVersionConflict) {
+						if err != nil {
+							t.Errorf("unexpected error: %v", err)
+						}
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		doc := fake.Documents("vault", "config")[0]
+		if doc["edits"] != int32(workers) || doc[VersionField] != int64(workers+1) {
+			t.Errorf("expected no lost updates, got %v", doc)
+		}
+	})
+
+	t.Run("Mock", func(t *testing.T) {
+		mock := NewMockDatabase()
+		mock.QueueUpdateOne(&UpdateResult{}, nil)
+		mock.QueueFindOne(bson.M{"_id": "site-a", VersionField: int64(4)}, nil)
+
+		err := UpdateWithVersion(ctx, mock, "vault", "config", "site-a", 3, U().Set("retention", 30))
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("expected ErrVersionConflict, got %v", err)
+		}
+		call, _ := mock.LastUpdateOneCall()
+		if !FilterEquals(bson.M{"_id": "site-a", VersionField: 3})(call.Filter) ||
+			!FilterContains(map[string]any{"$inc": map[string]any{VersionField: 1}})(call.Update) {
+			t.Errorf("expected the version in the filter and increment, got %v and %v", call.Filter, call.Update)
+		}
+	})
+}
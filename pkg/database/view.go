@@ -0,0 +1,149 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrNotView is returned by DropView when the name is a collection
+var ErrNotView = errors.New("not a view")
+
+// ViewInfo describes a read-only view: the documents of ViewOn, a collection
+// or another view of the same database, passed through Pipeline
+type ViewInfo struct {
+	Name     string
+	ViewOn   string
+	Pipeline []any
+}
+
+// ViewManager is implemented by clients that manage views
+type ViewManager interface {
+	CreateView(ctx context.Context, db string, name string, viewOn string, pipeline any) error
+	ListViews(ctx context.Context, db string) ([]ViewInfo, error)
+	DropView(ctx context.Context, db string, name string) error
+}
+
+// CreateView creates the view db.name, whose documents are those pipeline,
+// an array of stages, outputs for the documents of viewOn, a collection or
+// another view of the same database. Find, Count and Aggregate read a view like a collection;
+// writes and index creation fail with an error matching
+// ErrViewNotSupported. Views cannot end in $out or $merge, so CreateView
+// rejects such pipelines before sending them. The client must implement
+// ViewManager, as MongoClient and FakeDatabase do.
+//
+//	err := database.CreateView(ctx, db.Client, "reporting", "camera_status", "cameras", mongo.Pipeline{
+//		{{Key: "$match", Value: bson.M{"deleted": false}}},
+//		{{Key: "$project", Value: bson.M{"name": 1, "status": 1}}},
+//	})
+func CreateView(ctx context.Context, client DatabaseInterface, db string, name string, viewOn string, pipeline any) error {
+	manager, ok := implementation[ViewManager](client)
+	if !ok {
+		return fmt.Errorf("client %T cannot manage views", client)
+	}
+	if name == "" || viewOn == "" {
+		return errors.New("create view: view and source names are required")
+	}
+	if err := validateViewPipeline(pipeline); err != nil {
+		return fmt.Errorf("create view %s.%s: %w", db, name, err)
+	}
+	return manager.CreateView(ctx, db, name, viewOn, pipeline)
+}
+
+// ListViews returns the views of db sorted by name
+func ListViews(ctx context.Context, client DatabaseInterface, db string) ([]ViewInfo, error) {
+	manager, ok := implementation[ViewManager](client)
+	if !ok {
+		return nil, fmt.Errorf("client %T cannot manage views", client)
+	}
+	return manager.ListViews(ctx, db)
+}
+
+// DropView drops the view db.name. Dropping a view that does not exist is
+// not an error, while a collection of that name is left alone with an error
+// matching ErrNotView, so a typo cannot drop data.
+func DropView(ctx context.Context, client DatabaseInterface, db string, name string) error {
+	manager, ok := implementation[ViewManager](client)
+	if !ok {
+		return fmt.Errorf("client %T cannot manage views", client)
+	}
+	return manager.DropView(ctx, db, name)
+}
+
+// validateViewPipeline checks that pipeline is an array of stages without
+// $out or $merge, which views forbid
+func validateViewPipeline(pipeline any) error {
+	if pipeline == nil {
+		return nil
+	}
+	stages, err := pipelineStages(pipeline)
+	if err != nil {
+		return err
+	}
+	for i, stage := range stages {
+		name, _, err := stageOf(stage)
+		if err != nil {
+			return fmt.Errorf("pipeline stage %d: %w", i, err)
+		}
+		if name == "$out" || name == "$merge" {
+			return fmt.Errorf("pipeline stage %d: views cannot use %s", i, name)
+		}
+	}
+	return nil
+}
+
+// CreateView implements ViewManager with the create command
+func (m *MongoClient) CreateView(ctx context.Context, db string, name string, viewOn string, pipeline any) error {
+	if pipeline == nil {
+		pipeline = bson.A{}
+	}
+	return mapError(m.Client.Database(db).CreateView(ctx, name, viewOn, pipeline))
+}
+
+// ListViews implements ViewManager from the collection specifications of
+// type view
+func (m *MongoClient) ListViews(ctx context.Context, db string) ([]ViewInfo, error) {
+	specs, err := m.Client.Database(db).ListCollectionSpecifications(ctx, bson.D{{Key: "type", Value: "view"}})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	views := make([]ViewInfo, 0, len(specs))
+	for _, spec := range specs {
+		var options struct {
+			ViewOn   string   `bson:"viewOn"`
+			Pipeline []bson.M `bson:"pipeline"`
+		}
+		if len(spec.Options) > 0 {
+			if err := bson.Unmarshal(spec.Options, &options); err != nil {
+				return nil, fmt.Errorf("view %s.%s: %w", db, spec.Name, err)
+			}
+		}
+		view := ViewInfo{Name: spec.Name, ViewOn: options.ViewOn, Pipeline: make([]any, len(options.Pipeline))}
+		for i, stage := range options.Pipeline {
+			view.Pipeline[i] = normalizeDocument(stage)
+		}
+		views = append(views, view)
+	}
+	slices.SortFunc(views, func(a, b ViewInfo) int { return cmp.Compare(a.Name, b.Name) })
+	return views, nil
+}
+
+// DropView implements ViewManager, checking the type of the namespace first
+func (m *MongoClient) DropView(ctx context.Context, db string, name string) error {
+	database := m.Client.Database(db)
+	specs, err := database.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: name}})
+	if err != nil {
+		return mapError(err)
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	if specs[0].Type != "view" {
+		return fmt.Errorf("drop view %s.%s: %w", db, name, ErrNotView)
+	}
+	return mapError(database.Collection(name).Drop(ctx))
+}
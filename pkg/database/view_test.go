@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCreateViewValidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		pipeline any
+		wantErr  string
+	}{
+		{"Empty", nil, ""},
+		{"Match", bson.A{bson.M{"$match": bson.M{"deleted": false}}}, ""},
+		{"Out", mongo.Pipeline{{{Key: "$match", Value: bson.M{}}}, {{Key: "$out", Value: "copy"}}}, "stage 1: views cannot use $out"},
+		{"Merge", []bson.M{{"$merge": bson.M{"into": "copy"}}}, "stage 0: views cannot use $merge"},
+		{"NotArray", bson.M{"$match": bson.M{}}, "pipeline must be an array"},
+		{"TwoFields", bson.A{bson.M{"$match": bson.M{}, "$limit": 1}}, "exactly one field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeDatabase()
+			err := CreateView(ctx, fake, "reporting", "camera_status", "cameras", tt.pipeline)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if views, _ := ListViews(ctx, fake, "reporting"); len(views) != 0 {
+				t.Errorf("expected the invalid view not to be created, got %v", views)
+			}
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if err := CreateView(ctx, NewMockDatabase(), "reporting", "camera_status", "cameras", nil); err == nil {
+			t.Error("expected a client without views to be rejected")
+		}
+	})
+}
+
+func TestFakeViews(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) *FakeDatabase {
+		fake := NewFakeDatabase()
+		fake.Seed("vault", "cameras",
+			bson.M{"_id": 1, "name": "front", "status": "online", "secret": "a", "deleted": false},
+			bson.M{"_id": 2, "name": "back", "status": "offline", "secret": "b", "deleted": false},
+			bson.M{"_id": 3, "name": "old", "status": "offline", "secret": "c", "deleted": true},
+		)
+		err := CreateView(ctx, fake, "vault", "camera_status", "cameras", mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"deleted": false}}},
+			{{Key: "$project", Value: bson.M{"name": 1, "status": 1}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fake
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		fake := setup(t)
+		db := &Database{Client: fake}
+		type status struct {
+			ID     int    `bson:"_id"`
+			Name   string `bson:"name"`
+			Status string `bson:"status"`
+			Secret string `bson:"secret"`
+		}
+		got, err := FindAs[status](ctx, db, "vault", "camera_status", bson.M{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []status{{1, "front", "online", ""}, {2, "back", "offline", ""}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+		if n, _ := fake.Count(ctx, "vault", "camera_status", bson.M{"status": "offline"}); n != 1 {
+			t.Errorf("expected the filter to apply to the view's output, got %d", n)
+		}
+		if _, err := fake.FindOne(ctx, "vault", "camera_status", bson.M{"_id": 3}); !errors.Is(err, mongo.ErrNoDocuments) {
+			t.Errorf("expected documents the view filters out to be hidden, got %v", err)
+		}
+		values, _ := fake.Distinct(ctx, "vault", "camera_status", "status", bson.M{})
+		if len(values) != 2 {
+			t.Errorf("expected 2 distinct statuses, got %v", values)
+		}
+		out, err := fake.Aggregate(ctx, "vault", "camera_status", bson.A{bson.M{"$count": "n"}})
+		if docs := out.([]any); err != nil || len(docs) != 1 || docs[0].(bson.M)["n"] != int32(2) {
+			t.Errorf("expected Aggregate to run over the view, got %v, %v", out, err)
+		}
+
+		fake.InsertOne(ctx, "vault", "cameras", bson.M{"_id": 4, "name": "side", "status": "online", "deleted": false})
+		if n, _ := fake.Count(ctx, "vault", "camera_status", bson.M{}); n != 3 {
+			t.Errorf("expected the view to follow its source, got %d documents", n)
+		}
+	})
+
+	t.Run("ViewOnView", func(t *testing.T) {
+		fake := setup(t)
+		if err := CreateView(ctx, fake, "vault", "online_cameras", "camera_status", bson.A{bson.M{"$match": bson.M{"status": "online"}}}); err != nil {
+			t.Fatal(err)
+		}
+		docs, err := fake.Find(ctx, "vault", "online_cameras", bson.M{})
+		if got := docs.([]any); err != nil || len(got) != 1 || got[0].(bson.M)["name"] != "front" {
+			t.Errorf("expected the view of a view to chain both pipelines, got %v, %v", docs, err)
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		fake := setup(t)
+		writes := map[string]func() error{
+			"InsertOne": func() error {
+				_, err := fake.InsertOne(ctx, "vault", "camera_status", bson.M{"name": "new"})
+				return err
+			},
+			"UpdateOne": func() error {
+				_, err := fake.UpdateOne(ctx, "vault", "camera_status", bson.M{"_id": 1}, bson.M{"$set": bson.M{"status": "x"}})
+				return err
+			},
+			"DeleteMany": func() error {
+				_, err := fake.DeleteMany(ctx, "vault", "camera_status", bson.M{})
+				return err
+			},
+			"EnsureIndexes": func() error {
+				return EnsureIndexes(ctx, fake, "vault", "camera_status", IndexSpec{Keys: bson.D{{Key: "name", Value: 1}}})
+			},
+		}
+		for name, write := range writes {
+			t.Run(name, func(t *testing.T) {
+				if err := write(); !errors.Is(err, ErrViewNotSupported) {
+					t.Errorf("expected ErrViewNotSupported, got %v", err)
+				}
+			})
+		}
+		if n := len(fake.Documents("vault", "cameras")); n != 3 {
+			t.Errorf("expected the source to be untouched, got %d documents", n)
+		}
+	})
+
+	t.Run("ListAndDrop", func(t *testing.T) {
+		fake := setup(t)
+		CreateView(ctx, fake, "vault", "active", "cameras", nil)
+		CreateView(ctx, fake, "other", "elsewhere", "cameras", nil)
+		views, err := ListViews(ctx, fake, "vault")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []ViewInfo{
+			{Name: "active", ViewOn: "cameras", Pipeline: []any{}},
+			{Name: "camera_status", ViewOn: "cameras", Pipeline: []any{
+				map[string]any{"$match": map[string]any{"deleted": false}},
+				map[string]any{"$project": map[string]any{"name": 1, "status": 1}},
+			}},
+		}
+		if !reflect.DeepEqual(views, want) {
+			t.Errorf("expected %v, got %v", want, views)
+		}
+
+		if err := CreateView(ctx, fake, "vault", "active", "cameras", nil); err == nil {
+			t.Error("expected an existing view to be rejected")
+		}
+		if err := CreateView(ctx, fake, "vault", "cameras", "active", nil); err == nil {
+			t.Error("expected an existing collection to be rejected")
+		}
+		if err := DropView(ctx, fake, "vault", "cameras"); !errors.Is(err, ErrNotView) {
+			t.Errorf("expected dropping a collection to fail with ErrNotView, got %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := DropView(ctx, fake, "vault", "active"); err != nil {
+				t.Errorf("drop %d: %v", i, err)
+			}
+		}
+		if views, _ := ListViews(ctx, fake, "vault"); len(views) != 1 {
+			t.Errorf("expected one view left, got %v", views)
+		}
+		if _, err := fake.InsertOne(ctx, "vault", "active", bson.M{"a": 1}); err != nil {
+			t.Errorf("expected the name to be usable as a collection after DropView, got %v", err)
+		}
+	})
+}
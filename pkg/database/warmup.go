@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// ConnectMode is when New opens connections
+type ConnectMode int
+
+const (
+	// Lazy leaves connections to the first operations, the driver's default
+	Lazy ConnectMode = iota
+	// Eager makes New warm the pool up to MinPoolSize connections, at least
+	// one, before returning
+	Eager
+)
+
+func (m ConnectMode) String() string {
+	switch m {
+	case Lazy:
+		return "Lazy"
+	case Eager:
+		return "Eager"
+	}
+	return fmt.Sprintf("ConnectMode(%d)", int(m))
+}
+
+// defaultMaxPoolSize is the driver's pool size limit when none is set
+const defaultMaxPoolSize = 100
+
+// maxStalledRounds is how many rounds of pings may leave the pool as large as
+// it was before Warmup gives up
+const maxStalledRounds = 3
+
+// SetConnectMode sets when New opens connections, Lazy or Eager
+func (b *MongoOptionsBuilder) SetConnectMode(mode ConnectMode) *MongoOptionsBuilder {
+	b.options.ConnectMode = mode
+	b.mark("ConnectMode")
+	return b
+}
+
+// SetMinPoolSize sets how many connections the driver keeps open to each
+// server; with Eager they are open before New returns
+func (b *MongoOptionsBuilder) SetMinPoolSize(size uint64) *MongoOptionsBuilder {
+	b.options.MinPoolSize = size
+	return b
+}
+
+// SetMaxPoolSize limits the connections to each server, 100 by default
+func (b *MongoOptionsBuilder) SetMaxPoolSize(size uint64) *MongoOptionsBuilder {
+	b.options.MaxPoolSize = size
+	return b
+}
+
+// SetPoolMonitor receives the connection pool events of the client, such as
+// the creation and closing of connections
+func (b *MongoOptionsBuilder) SetPoolMonitor(monitor *event.PoolMonitor) *MongoOptionsBuilder {
+	b.options.PoolMonitor = monitor
+	return b
+}
+
+// WarmupError reports a warmup that ended before opening every connection
+type WarmupError struct {
+	Requested int
+	Connected int
+	Err       error
+}
+
+func (e *WarmupError) Error() string {
+	return fmt.Sprintf("warmup: %d of %d connections open: %v", e.Connected, e.Requested, e.Err)
+}
+
+func (e *WarmupError) Unwrap() error {
+	return e.Err
+}
+
+// warmer is implemented by clients with a connection pool
+type warmer interface {
+	warmup(ctx context.Context, n int) error
+}
+
+// Warmup opens up to n connections, at most the maximum pool size, by
+// pinging concurrently until that many are open, so the first requests after
+// a deploy do not pay for connecting and TLS handshakes. When ctx ends first,
+// the error is a *WarmupError with the number of connections that did open.
+// Clients without a pool, such as the mock and the fake, are pinged once.
+func (d *Database) Warmup(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if w, ok := implementation[warmer](d.Client); ok {
+		return w.warmup(ctx, n)
+	}
+	return d.Client.Ping(ctx)
+}
+
+func (m *MongoClient) warmup(ctx context.Context, n int) error {
+	if m.pool == nil {
+		return m.Ping(ctx)
+	}
+	limit := defaultMaxPoolSize
+	if m.Options.MaxPoolSize > 0 {
+		limit = int(m.Options.MaxPoolSize)
+	}
+	n = min(n, limit)
+
+	stalled := 0
+	for {
+		open := m.pool.open()
+		if open >= n {
+			return nil
+		}
+
+		// Each ping that finds no idle connection makes the pool open one
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = m.Ping(ctx)
+			}()
+		}
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			return &WarmupError{Requested: n, Connected: m.pool.open(), Err: err}
+		}
+		if !slices.Contains(errs, nil) {
+			return &WarmupError{Requested: n, Connected: m.pool.open(), Err: errs[0]}
+		}
+		if m.pool.open() > open {
+			stalled = 0
+		} else if stalled++; stalled == maxStalledRounds {
+			return &WarmupError{Requested: n, Connected: open, Err: errors.New("the pool stopped growing")}
+		}
+	}
+}
+
+// poolCounter counts the open connections of a client from its pool events
+type poolCounter struct {
+	mu    sync.Mutex
+	ready map[poolConnection]bool
+}
+
+type poolConnection struct {
+	address string
+	id      uint64
+}
+
+// monitor returns a pool monitor that counts connections and passes every
+// event on to next
+func (p *poolCounter) monitor(next *event.PoolMonitor) *event.PoolMonitor {
+	return &event.PoolMonitor{Event: func(e *event.PoolEvent) {
+		p.observe(e)
+		if next != nil && next.Event != nil {
+			next.Event(e)
+		}
+	}}
+}
+
+func (p *poolCounter) observe(e *event.PoolEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ready == nil {
+		p.ready = make(map[poolConnection]bool)
+	}
+	conn := poolConnection{address: e.Address, id: e.ConnectionID}
+	switch e.Type {
+	case event.ConnectionReady:
+		p.ready[conn] = true
+	case event.ConnectionClosed:
+		delete(p.ready, conn)
+	case event.PoolCleared, event.PoolClosedEvent:
+		for c := range p.ready {
+			if c.address == e.Address {
+				delete(p.ready, c)
+			}
+		}
+	}
+}
+
+// open returns the number of connections ready for use
+func (p *poolCounter) open() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ready)
+}
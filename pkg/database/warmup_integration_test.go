@@ -0,0 +1,60 @@
+//go:build integration
+
+package database
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// TestWarmupLive counts the connections the pool creates for an eager client
+// and for Warmup: go test -tags integration with MONGODB_URI set
+func TestWarmupLive(t *testing.T) {
+	mongodbUri := os.Getenv("MONGODB_URI")
+	if mongodbUri == "" {
+		t.Skip("MONGODB_URI not set, skipping integration test")
+	}
+	ctx := context.Background()
+
+	var created atomic.Int64
+	monitor := &event.PoolMonitor{Event: func(e *event.PoolEvent) {
+		if e.Type == event.ConnectionCreated {
+			created.Add(1)
+		}
+	}}
+
+	db, err := New(NewMongoOptions().
+		SetUri(mongodbUri).
+		SetTimeoutDuration(30 * time.Second).
+		SetConnectMode(Eager).
+		SetMinPoolSize(5).
+		SetMaxPoolSize(20).
+		SetPoolMonitor(monitor).
+		Build())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer db.Client.Close(ctx)
+	if got := created.Load(); got < 5 {
+		t.Errorf("expected at least 5 connections when New returns, got %d", got)
+	}
+
+	warmupCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := db.Warmup(warmupCtx, 15); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := created.Load(); got < 15 {
+		t.Errorf("expected at least 15 connections after Warmup, got %d", got)
+	}
+
+	// Warmup asks for at most the maximum pool size, so 50 ends at 20
+	if err := db.Warmup(warmupCtx, 50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestWarmup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("WithoutPool", func(t *testing.T) {
+		mock := NewMockDatabase()
+		db := &Database{Client: Wrap(mock, WithReadOnly())}
+		if err := db.Warmup(ctx, 10); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := db.Warmup(ctx, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mock.AssertCalled(t, "Ping", 1)
+	})
+
+	t.Run("EagerUnreachable", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		closed := ln.Addr().String()
+		ln.Close()
+
+		start := time.Now()
+		client, err := NewMongoClient(NewMongoOptions().
+			SetUri("mongodb://" + closed + "/?directConnection=true").
+			SetTimeoutDuration(200 * time.Millisecond).
+			SetConnectMode(Eager).
+			SetMinPoolSize(4).
+			Build())
+		if client != nil {
+			defer client.Close(ctx)
+		}
+		var warmupErr *WarmupError
+		if !errors.As(err, &warmupErr) || warmupErr.Requested != 4 || warmupErr.Connected != 0 {
+			t.Fatalf("expected a warmup error with 0 of 4 connections, got %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the connect timeout to end the warmup, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the warmup to stop at the connect timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		err := &WarmupError{Requested: 10, Connected: 7, Err: context.DeadlineExceeded}
+		if !strings.Contains(err.Error(), "7 of 10 connections open") || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the partial success and the cause, got %v", err)
+		}
+	})
+}
+
+func TestPoolCounter(t *testing.T) {
+	var forwarded []string
+	p := &poolCounter{}
+	monitor := p.monitor(&event.PoolMonitor{Event: func(e *event.PoolEvent) { forwarded = append(forwarded, e.Type) }})
+
+	events := []struct {
+		typ     string
+		address string
+		id      uint64
+		want    int
+	}{
+		{event.ConnectionCreated, "db1:27017", 1, 0},
+		{event.ConnectionReady, "db1:27017", 1, 1},
+		{event.ConnectionReady, "db1:27017", 2, 2},
+		{event.ConnectionReady, "db2:27017", 1, 3},
+		{event.ConnectionClosed, "db1:27017", 2, 2},
+		// a connection that failed its handshake was never ready
+		{event.ConnectionClosed, "db1:27017", 3, 2},
+		{event.PoolCleared, "db1:27017", 0, 1},
+		{event.ConnectionClosed, "db1:27017", 1, 1},
+	}
+	for _, e := range events {
+		monitor.Event(&event.PoolEvent{Type: e.typ, Address: e.address, ConnectionID: e.id})
+		if got := p.open(); got != e.want {
+			t.Fatalf("after %s %s #%d: expected %d open connections, got %d", e.typ, e.address, e.id, e.want, got)
+		}
+	}
+	if len(forwarded) != len(events) {
+		t.Errorf("expected every event passed on to the monitor of the options, got %v", forwarded)
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Headers of the requests SubscribeWebhook sends
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+)
+
+// defaultWebhookBackoff is the delay before the first retry of a webhook
+const defaultWebhookBackoff = time.Second
+
+// Webhook configures SubscribeWebhook
+type Webhook struct {
+	URL string
+	// Secret signs every request; see SignWebhook
+	Secret []byte
+	// Retries is the number of attempts after a failed first one. Transport
+	// errors, 5xx responses, 408 and 429 are retried; other responses are not.
+	Retries int
+	// Backoff is the delay before the first retry, doubled before each next
+	// one, 1s by default
+	Backoff time.Duration
+	// Client sends the requests, an http.Client with a 10s timeout by default
+	Client *http.Client
+	// OnFailure is called with an event that could not be delivered; the
+	// subscription goes on with the next event
+	OnFailure func(event ChangeEvent, err error)
+}
+
+// SubscribeWebhook POSTs the change events of db.collection matching filter
+// to hook.URL as relaxed Extended JSON, one request per event and in order,
+// signed with hook.Secret. It reopens failed change streams as Subscribe
+// does, and a resume token is only saved once its event was delivered or
+// given up on, so with SubscribeResumeTokens a restart redelivers the event
+// in flight. The returned function stops the subscription.
+//
+//	stop, err := db.SubscribeWebhook(ctx, "vault", "events", nil, database.Webhook{
+//		URL:     "https://hooks.example.com/events",
+//		Secret:  secret,
+//		Retries: 5,
+//	}, database.SubscribeResumeTokens(store, "events-webhook"))
+func (d *Database) SubscribeWebhook(ctx context.Context, db string, collection string, filter any, hook Webhook, opts ...SubscribeOption) (func(), error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("subscribe webhook: invalid URL %q", hook.URL)
+	}
+	if hook.Client == nil {
+		hook.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if hook.Backoff <= 0 {
+		hook.Backoff = defaultWebhookBackoff
+	}
+	return d.watchChanges(ctx, db, collection, filter, newSubscribeConfig(opts), hook.deliver, func() {})
+}
+
+// SignWebhook returns the signature header value of body: "sha256=" and
+// the hex HMAC-SHA256 of body keyed by secret
+func SignWebhook(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook reports whether signature, the X-Webhook-Signature header
+// of a request, signs body with secret, for receivers of SubscribeWebhook
+func VerifyWebhook(secret []byte, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhook(secret, body)), []byte(signature))
+}
+
+// deliver posts event, retrying with growing delays, and reports it to
+// OnFailure when every attempt failed; it only fails when ctx ends
+func (w Webhook) deliver(ctx context.Context, event ChangeEvent) error {
+	body, err := bson.MarshalExtJSON(event, false, false)
+	if err != nil {
+		w.fail(event, fmt.Errorf("webhook: %w", err))
+		return nil
+	}
+	backoff := w.Backoff
+	attempts := 0
+	for {
+		attempts++
+		err = w.post(ctx, body, event.OperationType)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var status *webhookStatusError
+		if attempts > w.Retries || (errors.As(err, &status) && !status.retryable()) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	w.fail(event, fmt.Errorf("webhook: %d attempts: %w", attempts, err))
+	return nil
+}
+
+func (w Webhook) fail(event ChangeEvent, err error) {
+	if w.OnFailure != nil {
+		w.OnFailure(event, err)
+	}
+}
+
+// post sends one request
+func (w Webhook) post(ctx context.Context, body []byte, operation string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(w.Secret, body))
+	req.Header.Set(WebhookEventHeader, operation)
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// webhookStatusError is a response outside 2xx
+type webhookStatusError struct {
+	code int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("responded %d %s", e.code, http.StatusText(e.code))
+}
+
+// retryable reports whether the response may succeed later
+func (e *webhookStatusError) retryable() bool {
+	return e.code >= 500 || e.code == http.StatusRequestTimeout || e.code == http.StatusTooManyRequests
+}
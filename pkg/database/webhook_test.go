@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSubscribeWebhook(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("s3cret")
+
+	// receiver answers with the statuses in order, then 200, and records the
+	// events it accepted
+	type receiver struct {
+		mu       sync.Mutex
+		statuses []int
+		attempts int
+		received []ChangeEvent
+		accepted chan struct{}
+	}
+	serve := func(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+		r := &receiver{statuses: statuses, accepted: make(chan struct{}, 10)}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			if !VerifyWebhook(secret, body, req.Header.Get(WebhookSignatureHeader)) {
+				t.Errorf("expected a valid signature, got %q", req.Header.Get(WebhookSignatureHeader))
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.attempts++
+			if len(r.statuses) > 0 {
+				status := r.statuses[0]
+				r.statuses = r.statuses[1:]
+				if status != http.StatusOK {
+					w.WriteHeader(status)
+					return
+				}
+			}
+			var event ChangeEvent
+			if err := bson.UnmarshalExtJSON(body, false, &event); err != nil {
+				t.Errorf("expected an Extended JSON event, got %s: %v", body, err)
+			}
+			if req.Header.Get(WebhookEventHeader) != event.OperationType {
+				t.Errorf("expected the %s header %q, got %q", WebhookEventHeader, event.OperationType, req.Header.Get(WebhookEventHeader))
+			}
+			r.received = append(r.received, event)
+			r.accepted <- struct{}{}
+		}))
+		t.Cleanup(server.Close)
+		return r, server
+	}
+	wait := func(t *testing.T, r *receiver, n int) {
+		t.Helper()
+		for range n {
+			select {
+			case <-r.accepted:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected %d deliveries", n)
+			}
+		}
+	}
+
+	t.Run("Deliver", func(t *testing.T) {
+		r, server := serve(t)
+		mock := NewMockDatabase()
+		stop, err := (&Database{Client: mock}).SubscribeWebhook(ctx, "vault", "events", nil, Webhook{URL: server.URL, Secret: secret})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		mock.ChangeStream("vault", "events").Send(insertEvent("a"), insertEvent("b"))
+		wait(t, r, 2)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.received[0].DocumentKey["_id"] != "a" || r.received[1].DocumentKey["_id"] != "b" || r.received[0].ID == nil {
+			t.Errorf("expected a then b with their resume tokens, got %+v", r.received)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		r, server := serve(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		mock := NewMockDatabase()
+		store := NewMemoryResumeTokenStore()
+		stop, err := (&Database{Client: mock}).SubscribeWebhook(ctx, "vault", "events", nil,
+			Webhook{URL: server.URL, Secret: secret, Retries: 3, Backoff: time.Millisecond},
+			SubscribeResumeTokens(store, "hook"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stop()
+		mock.ChangeStream("vault", "events").Send(insertEvent("a"))
+		wait(t, r, 1)
+		// The token is saved once the response arrived
+		deadline := time.Now().Add(5 * time.Second)
+		for token, _ := store.LoadResumeToken(ctx, "hook"); token == nil; token, _ = store.LoadResumeToken(ctx, "hook") {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the delivered event's token to be saved")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.attempts != 3 || len(r.received) != 1 {
+			t.Errorf("expected delivery on the third attempt, got %d attempts and %d events", r.attempts, len(r.received))
+		}
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			statuses []int
+			attempts int
+			reason   string
+		}{
+			{"Exhausted", []int{500, 502, 503}, 3, "3 attempts: responded 503"},
+			{"Permanent", []int{http.StatusBadRequest}, 1, "1 attempts: responded 400"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				r, server := serve(t, tt.statuses...)
+				mock := NewMockDatabase()
+				failed := make(chan error, 1)
+				stop, err := (&Database{Client: mock}).SubscribeWebhook(ctx, "vault", "events", nil, Webhook{
+					URL: server.URL, Secret: secret, Retries: 2, Backoff: time.Millisecond,
+					OnFailure: func(event ChangeEvent, err error) {
+						if event.DocumentKey["_id"] != "a" {
+							t.Errorf("expected event a to fail, got %v", event.DocumentKey)
+						}
+						failed <- err
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer stop()
+				mock.ChangeStream("vault", "events").Send(insertEvent("a"), insertEvent("b"))
+				select {
+				case err := <-failed:
+					if !strings.Contains(err.Error(), tt.reason) {
+						t.Errorf("expected %q, got %v", tt.reason, err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("expected the delivery to fail")
+				}
+				// The subscription goes on with the next event
+				wait(t, r, 1)
+				r.mu.Lock()
+				defer r.mu.Unlock()
+				if r.attempts != tt.attempts+1 || r.received[0].DocumentKey["_id"] != "b" {
+					t.Errorf("expected %d attempts and then b, got %d and %+v", tt.attempts, r.attempts-1, r.received)
+				}
+			})
+		}
+	})
+
+	t.Run("InvalidURL", func(t *testing.T) {
+		if _, err := (&Database{Client: NewMockDatabase()}).SubscribeWebhook(ctx, "vault", "events", nil, Webhook{URL: "hooks.example.com"}); err == nil {
+			t.Error("expected a URL without scheme to be rejected")
+		}
+	})
+}